@@ -0,0 +1,117 @@
+package main
+
+import (
+	"time"
+
+	"github.com/achilleas-k/gg13/internal/config"
+	"github.com/achilleas-k/gg13/internal/device"
+)
+
+// allKeysMask is the mask of the keys in the input, without the stick
+// position.
+var allKeysMask = func() uint64 {
+	var mask uint64
+	for _, key := range device.AllKeys() {
+		mask |= key.Uint64()
+	}
+	return mask
+}()
+
+// keyFilter applies slow keys and sticky keys, as configured in the active
+// mapping, to the input before it reaches the bindings. A held key only
+// registers after the slow keys delay, and a tapped key bound to a modifier
+// stays down until the next key is released.
+type keyFilter struct {
+	// the filtered input of the previous event
+	prev uint64
+
+	// when each key that's held went down, and the held keys that
+	// registered
+	downSince map[device.KeyBit]time.Time
+	accepted  uint64
+
+	// the registered keys of the previous event, before latching
+	prevKeys uint64
+
+	// the latched sticky keys, the sticky keys held while another key was
+	// pressed, which don't latch because they're used as a chord, and the
+	// sticky keys pressed again while latched, which unlatch on release
+	latched    uint64
+	chorded    uint64
+	unlatching uint64
+}
+
+// filter returns the input with slow keys and sticky keys applied at the
+// time, and the filtered input of the previous event.
+func (f *keyFilter) filter(input uint64, now time.Time, g13cfg *config.G13Config) (uint64, uint64) {
+	keys := f.slowKeys(input&allKeysMask, now, g13cfg.GetSlowKeysDelay())
+	keys = f.stickyKeys(keys, g13cfg.GetStickyKeys())
+
+	filtered := input&^allKeysMask | keys
+	prev := f.prev
+	f.prev = filtered
+	return filtered, prev
+}
+
+func (f *keyFilter) slowKeys(keys uint64, now time.Time, delay time.Duration) uint64 {
+	if delay == 0 {
+		f.downSince = nil
+		f.accepted = keys
+		return keys
+	}
+	if f.downSince == nil {
+		f.downSince = make(map[device.KeyBit]time.Time)
+	}
+	for _, key := range device.AllKeys() {
+		if keys&key.Uint64() == 0 {
+			delete(f.downSince, key)
+			f.accepted &^= key.Uint64()
+			continue
+		}
+		since, ok := f.downSince[key]
+		if !ok {
+			since = now
+			f.downSince[key] = now
+		}
+		if now.Sub(since) >= delay {
+			f.accepted |= key.Uint64()
+		}
+	}
+	return f.accepted
+}
+
+func (f *keyFilter) stickyKeys(keys, sticky uint64) uint64 {
+	pressed := keys &^ f.prevKeys
+	released := f.prevKeys &^ keys
+	f.prevKeys = keys
+	// the keys stop being sticky when the bindings change
+	f.latched &= sticky
+	f.chorded &= sticky
+	f.unlatching &= sticky
+
+	if pressed&^sticky != 0 {
+		f.chorded |= keys & sticky
+	}
+	f.unlatching |= pressed & f.latched
+	f.latched &^= pressed
+
+	f.latched |= released & sticky &^ f.chorded &^ f.unlatching
+	f.chorded &^= released
+	f.unlatching &^= released
+	if released&^sticky != 0 {
+		// the latched keys applied to the released key
+		f.latched = 0
+	}
+	return keys | f.latched
+}
+
+// pending returns true if held keys are waiting for the slow keys delay to
+// register.
+func (f *keyFilter) pending() bool {
+	for key := range f.downSince {
+		if f.accepted&key.Uint64() == 0 {
+			return true
+		}
+	}
+	return false
+}
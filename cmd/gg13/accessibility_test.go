@@ -0,0 +1,75 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/achilleas-k/gg13/internal/config"
+	"github.com/achilleas-k/gg13/internal/device"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestKeyFilter(t *testing.T) {
+	assert := assert.New(t)
+
+	cfg := loadTestConfig(t, `{"mapping":{"keys":{"G1":"KeyLeftshift","G2":"KeyA","G3":"KeyLeftctrl"},"sticky_keys":true}}`)
+
+	f := &keyFilter{}
+	now := time.Now()
+	g1, g2, g3 := device.G1.Uint64(), device.G2.Uint64(), device.G3.Uint64()
+	filter := func(input uint64) uint64 {
+		filtered, _ := f.filter(input|stickCentre, now, cfg)
+		return filtered &^ stickCentre
+	}
+
+	// tapped modifiers latch until the next key is released
+	assert.Equal(g1, filter(g1))
+	assert.Equal(g1, filter(0))
+	assert.Equal(g1|g3, filter(g3))
+	assert.Equal(g1|g3, filter(0))
+	assert.Equal(g1|g2|g3, filter(g2))
+	assert.Equal(uint64(0), filter(0))
+
+	// tapping a latched modifier again releases it
+	assert.Equal(g1, filter(g1))
+	assert.Equal(g1, filter(0))
+	assert.Equal(g1, filter(g1))
+	assert.Equal(uint64(0), filter(0))
+
+	// a modifier held with another key doesn't latch
+	assert.Equal(g1|g2, filter(g1|g2))
+	assert.Equal(g1, filter(g1))
+	assert.Equal(uint64(0), filter(0))
+
+	// the previous filtered input is returned with the input
+	filtered, prev := f.filter(g1|stickCentre, now, cfg)
+	assert.Equal(g1|stickCentre, filtered)
+	assert.Equal(stickCentre, prev)
+	f.filter(stickCentre, now, cfg)
+
+	// keys stop latching when sticky keys are turned off
+	assert.Equal(g1, filter(0))
+	plain := config.NewEmpty()
+	filtered, _ = f.filter(stickCentre, now, plain)
+	assert.Equal(stickCentre, filtered)
+
+	// slow keys register after being held for the delay
+	cfg = loadTestConfig(t, `{"mapping":{"keys":{"G1":"KeyA","G2":"KeyB"},"slow_keys":"200ms"}}`)
+	f = &keyFilter{}
+	assert.Equal(uint64(0), filter(g1))
+	assert.True(f.pending())
+	now = now.Add(100 * time.Millisecond)
+	assert.Equal(uint64(0), filter(g1|g2))
+	now = now.Add(100 * time.Millisecond)
+	assert.Equal(g1, filter(g1|g2))
+	assert.True(f.pending())
+	now = now.Add(100 * time.Millisecond)
+	assert.Equal(g1|g2, filter(g1|g2))
+	assert.False(f.pending())
+	// released keys have to be held for the delay again
+	assert.Equal(g2, filter(g2))
+	assert.Equal(g2, filter(g1|g2))
+	assert.True(f.pending())
+	assert.Equal(uint64(0), filter(0))
+	assert.False(f.pending())
+}
@@ -0,0 +1,402 @@
+package main
+
+import (
+	"fmt"
+	"image"
+	"os"
+	"slices"
+	"time"
+
+	"github.com/achilleas-k/gg13/internal/applet"
+	"github.com/achilleas-k/gg13/internal/audio"
+	"github.com/achilleas-k/gg13/internal/config"
+	"github.com/achilleas-k/gg13/internal/i18n"
+	"github.com/achilleas-k/gg13/internal/joystick"
+	"github.com/achilleas-k/gg13/internal/keyboard"
+)
+
+// configurableDevice is the part of [device.Device] that the config is
+// applied to.
+type configurableDevice interface {
+	SetTimeout(dt time.Duration) error
+	SetBacklightKeepalive(dt time.Duration)
+	SetBacklightColour(r, g, b uint8) error
+	SetLCD(img image.Image) error
+}
+
+// applyConfig sets up the device according to the config.
+func applyConfig(dev configurableDevice, g13cfg *config.G13Config) error {
+	if err := dev.SetTimeout(g13cfg.GetReadTimeout()); err != nil {
+		return err
+	}
+
+	dev.SetBacklightKeepalive(g13cfg.GetBacklightKeepalive())
+	backlight := g13cfg.GetBacklight()
+	if err := dev.SetBacklightColour(backlight[0], backlight[1], backlight[2]); err != nil {
+		return err
+	}
+
+	lcdImg, err := g13cfg.GetLCDImage()
+	if err != nil {
+		return err
+	}
+	if lcdImg != nil {
+		if err := dev.SetLCD(lcdImg); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// actionDevice is the part of [device.Device] used by the actions.
+type actionDevice interface {
+	configurableDevice
+	backlightOverrider
+}
+
+// actionDispatcher runs the driver-internal actions bound to G13 keys.
+type actionDispatcher struct {
+	// loads the config file again for reload_config
+	load func() (*config.G13Config, error)
+
+	backlightOff bool
+
+	// keyboard and joystick output is paused
+	paused bool
+
+	// all output, including actions, is stopped until the unlock chord is
+	// held
+	locked bool
+
+	// all output, including actions, is stopped while the graphical session
+	// is locked, unless the screen lock profile is latched; nil if it isn't
+	// watched
+	screen *screenLock
+
+	// the screen lock profile of the config is latched, and the profile
+	// latched before it
+	screenLockProfile bool
+	unlockProfile     string
+
+	// the latched profile and the momentary profile held on top of it;
+	// empty for none
+	profile          string
+	momentaryProfile string
+
+	// the keys follow the passthrough layout
+	passthrough bool
+
+	// the passthrough version of passthroughSrc, kept until the config is
+	// reloaded
+	passthroughCfg *config.G13Config
+	passthroughSrc *config.G13Config
+
+	// the LCD applets that actions control, if configured; they're only set
+	// up at startup
+	timer    *applet.Timer
+	counters *applet.Counters
+
+	// shows what the actions did on the LCD, if enabled
+	messages *lcdMessages
+
+	// runs the commands of profiles becoming active or inactive, if enabled
+	hooks *hookRunner
+
+	// measures the stick centre for calibrate_stick, if available
+	calibrator *stickCalibrator
+
+	// switches the audio devices for the cycle_audio actions, if commands
+	// can run
+	audio *audioSwitcher
+
+	// takes the screenshots of the screenshot actions, if there is a
+	// session bus
+	screenshots *screenshotter
+
+	// the entry shown while a numpad profile is active
+	numpad numpadEntry
+}
+
+// activeProfile returns the name of the profile in use, or an empty string
+// if it's the main mapping.
+func (d *actionDispatcher) activeProfile() string {
+	if d.momentaryProfile != "" {
+		return d.momentaryProfile
+	}
+	return d.profile
+}
+
+// outputConfig returns the config that keyboard and joystick output follows:
+// g13cfg, or the version of it with the mapping of the active profile, and
+// its passthrough version if the passthrough layout is on.
+func (d *actionDispatcher) outputConfig(g13cfg *config.G13Config) *config.G13Config {
+	if profileCfg := g13cfg.WithProfile(d.activeProfile()); profileCfg != nil {
+		g13cfg = profileCfg
+	}
+	if !d.passthrough {
+		return g13cfg
+	}
+	if d.passthroughSrc != g13cfg {
+		d.passthroughCfg = g13cfg.Passthrough()
+		d.passthroughSrc = g13cfg
+	}
+	return d.passthroughCfg
+}
+
+// handleProfiles switches profiles with the M keys pressed or released since
+// the previous read. Pressing the key of a latched profile switches to it,
+// or back to the main mapping if it's active already; a momentary profile is
+// active while its key is held. The hooks of the profiles that become
+// inactive and active are queued, in that order.
+func (d *actionDispatcher) handleProfiles(input, prevInput uint64, g13cfg *config.G13Config) {
+	prev := d.activeProfile()
+	for _, profile := range g13cfg.GetProfiles() {
+		isDown := profile.Key.Uint64()&input != 0
+		wasDown := profile.Key.Uint64()&prevInput != 0
+		switch {
+		case isDown && !wasDown && profile.Momentary:
+			d.momentaryProfile = profile.Name
+		case isDown && !wasDown && d.profile == profile.Name:
+			d.profile = ""
+		case isDown && !wasDown:
+			d.profile = profile.Name
+		case !isDown && wasDown && d.momentaryProfile == profile.Name:
+			d.momentaryProfile = ""
+		}
+	}
+	d.profileChanged(prev, g13cfg)
+}
+
+// switchProfile latches the named profile, or the main mapping for
+// [config.MainProfile], as if its key was pressed. A momentary profile that's
+// held stays active on top of it.
+func (d *actionDispatcher) switchProfile(name string, g13cfg *config.G13Config) error {
+	if name == config.MainProfile {
+		name = ""
+	} else if g13cfg.WithProfile(name) == nil {
+		return fmt.Errorf("unknown profile: %s", name)
+	}
+	prev := d.activeProfile()
+	d.profile = name
+	d.profileChanged(prev, g13cfg)
+	return nil
+}
+
+// stepProfile latches the profile step places after the latched one in the
+// order of their names, with the main mapping before the first one. Momentary
+// profiles are skipped: they're meant to be held.
+func (d *actionDispatcher) stepProfile(step int, g13cfg *config.G13Config) error {
+	names := []string{""}
+	for _, profile := range g13cfg.GetProfiles() {
+		if !profile.Momentary {
+			names = append(names, profile.Name)
+		}
+	}
+	if len(names) == 1 {
+		return fmt.Errorf("no profiles to switch to")
+	}
+	// the latched profile is always one of them, unless the config was
+	// reloaded without it
+	idx := max(slices.Index(names, d.profile), 0)
+	idx = (idx + step + len(names)) % len(names)
+	prev := d.activeProfile()
+	d.profile = names[idx]
+	d.profileChanged(prev, g13cfg)
+	return nil
+}
+
+// profileChanged queues the hooks and reports the change if the active
+// profile isn't prev anymore.
+func (d *actionDispatcher) profileChanged(prev string, g13cfg *config.G13Config) {
+	active := d.activeProfile()
+	if active == prev {
+		return
+	}
+	for _, profile := range g13cfg.GetProfiles() {
+		if profile.Name == prev {
+			d.hooks.run(profileHook{profile: prev, event: "on_leave", command: profile.OnLeave, timeout: profile.HookTimeout})
+		}
+	}
+	for _, profile := range g13cfg.GetProfiles() {
+		if profile.Name == active {
+			d.hooks.run(profileHook{profile: active, event: "on_enter", command: profile.OnEnter, timeout: profile.HookTimeout})
+		}
+	}
+	if active == "" {
+		active = config.MainProfile
+	}
+	fmt.Println(i18n.Sprintf("Profile %s active", active))
+	d.messages.show("Profile: %s", active)
+}
+
+// handleActions switches profiles and pages and runs the actions bound to keys
+// that were pressed since the previous read, or only waits for the unlock chord
+// while the keys are locked, and does nothing while the screen is locked. It
+// returns the config to use from now on, which is only different from g13cfg
+// if it was reloaded.
+func (d *actionDispatcher) handleActions(input, prevInput uint64, g13cfg *config.G13Config, dev actionDevice) *config.G13Config {
+	if d.screen.isLocked() {
+		return g13cfg
+	}
+	if d.locked {
+		d.handleUnlock(input, prevInput, g13cfg)
+		return g13cfg
+	}
+	d.handleProfiles(input, prevInput, g13cfg)
+	d.handlePages(input, prevInput, g13cfg)
+	// the actions bound in the active profile
+	for gkey, action := range d.outputConfig(g13cfg).GetActions() {
+		isDown := gkey.Uint64()&input != 0
+		wasDown := gkey.Uint64()&prevInput != 0
+		if !isDown || wasDown {
+			continue
+		}
+
+		newCfg, err := d.dispatch(action, g13cfg, dev)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, i18n.Sprintf("error running action %s: %s", action, err))
+			d.messages.show("Error: %s:\n%s", action, err)
+			continue
+		}
+		g13cfg = newCfg
+		if d.locked {
+			// nothing else runs once locked
+			return g13cfg
+		}
+	}
+	d.handleNumpad(input, prevInput, g13cfg)
+	return g13cfg
+}
+
+func (d *actionDispatcher) dispatch(action config.Action, g13cfg *config.G13Config, dev actionDevice) (*config.G13Config, error) {
+	switch action {
+	case config.ActionToggleBacklight:
+		colour := g13cfg.GetBacklight()
+		if !d.backlightOff {
+			colour = [3]uint8{}
+		}
+		if err := dev.SetBacklightColour(colour[0], colour[1], colour[2]); err != nil {
+			return nil, err
+		}
+		d.backlightOff = !d.backlightOff
+		return g13cfg, nil
+	case config.ActionReloadConfig:
+		newCfg, err := d.load()
+		if err != nil {
+			return nil, err
+		}
+		if err := applyConfig(dev, newCfg); err != nil {
+			return nil, err
+		}
+		d.backlightOff = false
+		fmt.Println(i18n.T("Config reloaded"))
+		d.messages.show("Config reloaded")
+		return newCfg, nil
+	case config.ActionPause:
+		if d.paused {
+			if err := dev.ClearBacklightOverride(); err != nil {
+				return nil, err
+			}
+			fmt.Println(i18n.T("Output resumed"))
+			d.messages.show("Output resumed")
+		} else {
+			colour := config.PauseColour
+			// zero duration: keep the colour until it's cleared
+			if _, err := dev.OverrideBacklightColour(colour[0], colour[1], colour[2], 0); err != nil {
+				return nil, err
+			}
+			fmt.Println(i18n.T("Output paused"))
+			d.messages.show("Output paused")
+		}
+		d.paused = !d.paused
+		return g13cfg, nil
+	case config.ActionPassthrough:
+		d.passthrough = !d.passthrough
+		if d.passthrough {
+			fmt.Println(i18n.T("Passthrough layout on"))
+			d.messages.show("Passthrough on")
+		} else {
+			fmt.Println(i18n.T("Passthrough layout off"))
+			d.messages.show("Passthrough off")
+		}
+		return g13cfg, nil
+	case config.ActionTimerStartPause, config.ActionTimerReset:
+		if d.timer == nil {
+			return nil, fmt.Errorf("no timer is running: restart the driver to show a newly configured timer")
+		}
+		if action == config.ActionTimerReset {
+			d.timer.Reset()
+		} else {
+			d.timer.StartPause()
+		}
+		return g13cfg, nil
+	case config.ActionClearCounters:
+		if d.counters == nil {
+			return nil, fmt.Errorf("no counters are shown: restart the driver to show newly configured counters")
+		}
+		d.counters.Clear("")
+		return g13cfg, nil
+	case config.ActionCalibrateStick:
+		if d.calibrator == nil {
+			return nil, fmt.Errorf("the stick can't be calibrated here")
+		}
+		d.calibrator.start(time.Now())
+		fmt.Println(i18n.T("Calibrating stick: don't touch it"))
+		d.messages.show("Calibrating stick:\ndon't touch it")
+		return g13cfg, nil
+	case config.ActionLock:
+		d.lock(g13cfg)
+		return g13cfg, nil
+	case config.ActionCycleAudioOutput, config.ActionCycleAudioInput:
+		if d.audio == nil {
+			return nil, fmt.Errorf("audio devices can't be switched here")
+		}
+		dir := audio.Output
+		if action == config.ActionCycleAudioInput {
+			dir = audio.Input
+		}
+		d.audio.run(config.AudioSwitch{Direction: dir})
+		return g13cfg, nil
+	case config.ActionScreenshot, config.ActionScreenshotInteractive:
+		if d.screenshots == nil {
+			return nil, fmt.Errorf("screenshots can't be taken here")
+		}
+		d.screenshots.take(action == config.ActionScreenshotInteractive)
+		return g13cfg, nil
+	case config.ActionNextProfile, config.ActionPreviousProfile:
+		step := 1
+		if action == config.ActionPreviousProfile {
+			step = -1
+		}
+		if err := d.stepProfile(step, g13cfg); err != nil {
+			return nil, err
+		}
+		return g13cfg, nil
+	case config.ActionNextPage, config.ActionPreviousPage:
+		step := 1
+		if action == config.ActionPreviousPage {
+			step = -1
+		}
+		if err := d.stepPage(step, g13cfg); err != nil {
+			return nil, err
+		}
+		return g13cfg, nil
+	default:
+		return nil, fmt.Errorf("unknown action")
+	}
+}
+
+// releaseOutput releases all keyboard keys and centres the joystick, so
+// nothing stays pressed while output is paused or the bindings change.
+func releaseOutput(g13cfg *config.G13Config, vkb keyboard.Keyboard, vjs joystick.Joystick) {
+	g13cfg.ReleaseKeys(func(kbkey int) {
+		if err := vkb.KeyUp(kbkey); err != nil {
+			fmt.Fprintln(os.Stderr, i18n.Sprintf("keyboard error releasing %d: %s", kbkey, err))
+		}
+	})
+	if vjs != nil && g13cfg.GetStickMode() == config.StickModeJoystick {
+		if err := vjs.StickPosition(0, 0); err != nil {
+			fmt.Fprintln(os.Stderr, i18n.Sprintf("joystick error centring stick: %s", err))
+		}
+	}
+}
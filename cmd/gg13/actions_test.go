@@ -0,0 +1,246 @@
+package main
+
+import (
+	"image"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/achilleas-k/gg13/internal/applet"
+	"github.com/achilleas-k/gg13/internal/config"
+	"github.com/achilleas-k/gg13/internal/device"
+	"github.com/achilleas-k/gg13/internal/keyboard"
+	"github.com/achilleas-k/gg13/internal/lcd"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type testConfigurableDevice struct {
+	testOverrider
+
+	backlights [][3]uint8
+	timeout    time.Duration
+}
+
+func (d *testConfigurableDevice) SetTimeout(dt time.Duration) error {
+	d.timeout = dt
+	return nil
+}
+
+func (d *testConfigurableDevice) SetBacklightKeepalive(time.Duration) {}
+
+func (d *testConfigurableDevice) SetBacklightColour(r, g, b uint8) error {
+	d.backlights = append(d.backlights, [3]uint8{r, g, b})
+	return nil
+}
+
+func (d *testConfigurableDevice) SetLCD(image.Image) error {
+	return nil
+}
+
+func TestHandleActions(t *testing.T) {
+	tmpDir := t.TempDir()
+	cfgPath := filepath.Join(tmpDir, "config.json")
+	writeConfig := func(data string) {
+		require.NoError(t, os.WriteFile(cfgPath, []byte(data), 0o600))
+	}
+	writeConfig(`{"mapping":{"actions":{"M1":"toggle_backlight","MR":"reload_config"}},"backlight":"red"}`)
+	cfg, err := config.NewFromFile(cfgPath)
+	require.NoError(t, err)
+
+	dispatcher := &actionDispatcher{
+		load: func() (*config.G13Config, error) {
+			return config.NewFromFile(cfgPath)
+		},
+	}
+	dev := &testConfigurableDevice{}
+
+	inputs := []uint64{
+		device.M1.Uint64(), // toggle off
+		device.M1.Uint64(), // held: nothing
+		0,
+		device.M1.Uint64(), // toggle on
+		0,
+		device.M1.Uint64(), // toggle off
+		0,
+	}
+	var prevInput uint64
+	for _, input := range inputs {
+		newCfg := dispatcher.handleActions(input, prevInput, cfg, dev)
+		assert.Same(t, cfg, newCfg)
+		prevInput = input
+	}
+	assert.Equal(t, [][3]uint8{{0, 0, 0}, {255, 0, 0}, {0, 0, 0}}, dev.backlights)
+
+	// reloading applies the new config and turns the backlight back on
+	writeConfig(`{"mapping":{"actions":{"MR":"reload_config"}},"backlight":"blue","input":{"read_timeout":"1s"}}`)
+	dev.backlights = nil
+	newCfg := dispatcher.handleActions(device.MR.Uint64(), 0, cfg, dev)
+	assert.NotSame(t, cfg, newCfg)
+	assert.Equal(t, [][3]uint8{{0, 0, 255}}, dev.backlights)
+	assert.Equal(t, time.Second, dev.timeout)
+	assert.False(t, dispatcher.backlightOff)
+
+	// a broken config is not applied
+	writeConfig(`{"mapping":{"actions":{"MR":"explode"}}}`)
+	dev.backlights = nil
+	assert.Same(t, newCfg, dispatcher.handleActions(device.MR.Uint64(), 0, newCfg, dev))
+	assert.Empty(t, dev.backlights)
+}
+
+func TestHandleProfiles(t *testing.T) {
+	assert := assert.New(t)
+
+	cfg := loadTestConfig(t, `{
+		"mapping":{"keys":{"G1":"KeyA"}},
+		"profiles":{
+			"game":{"key":"M1","mapping":{"keys":{"G1":"KeyB"},"actions":{"G2":"pause"}}},
+			"shift":{"key":"M2","momentary":true,"mapping":{"keys":{"G1":"KeyC"}}}
+		}
+	}`)
+
+	dispatcher := &actionDispatcher{}
+	dev := &testConfigurableDevice{}
+	outputKey := func() map[int]bool {
+		return dispatcher.outputConfig(cfg).GetKeyStates(device.G1.Uint64())
+	}
+	assert.Equal(map[int]bool{keyboard.KeyCode("KeyA"): true}, outputKey())
+
+	// latched until pressed again
+	dispatcher.handleActions(device.M1.Uint64(), 0, cfg, dev)
+	dispatcher.handleActions(0, device.M1.Uint64(), cfg, dev)
+	assert.Equal("game", dispatcher.activeProfile())
+	assert.Equal(map[int]bool{keyboard.KeyCode("KeyB"): true}, outputKey())
+
+	// the actions of the profile run
+	dispatcher.handleActions(device.G2.Uint64(), 0, cfg, dev)
+	assert.True(dispatcher.paused)
+
+	// momentary on top of the latched profile, while held
+	dispatcher.handleActions(device.M2.Uint64(), 0, cfg, dev)
+	assert.Equal(map[int]bool{keyboard.KeyCode("KeyC"): true}, outputKey())
+	dispatcher.handleActions(0, device.M2.Uint64(), cfg, dev)
+	assert.Equal("game", dispatcher.activeProfile())
+
+	dispatcher.handleActions(device.M1.Uint64(), 0, cfg, dev)
+	assert.Equal("", dispatcher.activeProfile())
+	assert.Equal(map[int]bool{keyboard.KeyCode("KeyA"): true}, outputKey())
+}
+
+func TestProfileActions(t *testing.T) {
+	assert := assert.New(t)
+
+	cycle := `{"actions":{"G1":"next_profile","G2":"previous_profile"}}`
+	cfg := loadTestConfig(t, `{
+		"mapping":`+cycle+`,
+		"profiles":{
+			"b":{"mapping":`+cycle+`},
+			"a":{"key":"M1","mapping":`+cycle+`},
+			"shift":{"key":"M2","momentary":true}
+		}
+	}`)
+
+	dispatcher := &actionDispatcher{}
+	dev := &testConfigurableDevice{}
+	press := func(key device.KeyBit) {
+		dispatcher.handleActions(key.Uint64(), 0, cfg, dev)
+	}
+
+	// in the order of the names, around through the main mapping, without
+	// the momentary profile
+	var active []string
+	for range 4 {
+		press(device.G1)
+		active = append(active, dispatcher.activeProfile())
+	}
+	assert.Equal([]string{"a", "b", "", "a"}, active)
+	press(device.G2)
+	assert.Equal("", dispatcher.activeProfile())
+	press(device.G2)
+	assert.Equal("b", dispatcher.activeProfile())
+
+	// the key of a profile and the actions switch the same latched profile
+	press(device.M1)
+	assert.Equal("a", dispatcher.activeProfile())
+	press(device.G1)
+	assert.Equal("b", dispatcher.activeProfile())
+
+	_, err := dispatcher.dispatch(config.ActionNextProfile, loadTestConfig(t, `{}`), dev)
+	assert.EqualError(err, "no profiles to switch to")
+}
+
+func TestPauseAction(t *testing.T) {
+	assert := assert.New(t)
+
+	cfg := loadTestConfig(t, `{"mapping":{"keys":{"G1":"KeyA"},"actions":{"M3":"pause"}}}`)
+
+	dispatcher := &actionDispatcher{}
+	dev := &testConfigurableDevice{}
+
+	dispatcher.handleActions(device.M3.Uint64(), 0, cfg, dev)
+	assert.True(dispatcher.paused)
+	dispatcher.handleActions(device.M3.Uint64(), device.M3.Uint64(), cfg, dev)
+	assert.True(dispatcher.paused)
+	dispatcher.handleActions(device.M3.Uint64(), 0, cfg, dev)
+	assert.False(dispatcher.paused)
+
+	assert.Equal([]overrideEvent{
+		{action: "override", colour: config.PauseColour},
+		{action: "clear"},
+	}, dev.events)
+
+	// pausing releases the keys that are down
+	kb := newTestKeyboard(t)
+	kb.newEvent()
+	releaseOutput(cfg, kb, nil)
+	assert.Equal([][]testEvent{{{action: "up", code: keyboard.KeyCode("KeyA")}}}, kb.events)
+}
+
+func TestPassthroughAction(t *testing.T) {
+	assert := assert.New(t)
+
+	cfg := loadTestConfig(t, `{"mapping":{"keys":{"G1":"KeyA"},"actions":{"MR":"passthrough"}}}`)
+
+	dispatcher := &actionDispatcher{}
+	dev := &testConfigurableDevice{}
+	assert.Same(cfg, dispatcher.outputConfig(cfg))
+
+	dispatcher.handleActions(device.MR.Uint64(), 0, cfg, dev)
+	passthrough := dispatcher.outputConfig(cfg)
+	assert.NotSame(cfg, passthrough)
+	// kept until the config changes
+	assert.Same(passthrough, dispatcher.outputConfig(cfg))
+	assert.True(passthrough.GetKeyStates(device.G1.Uint64())[keyboard.KeyCode("KeyF13")])
+
+	dispatcher.handleActions(device.MR.Uint64(), 0, cfg, dev)
+	assert.Same(cfg, dispatcher.outputConfig(cfg))
+}
+
+func TestTimerActions(t *testing.T) {
+	assert := assert.New(t)
+
+	cfg := loadTestConfig(t, `{"timer":{"duration":"25m"},"mapping":{"actions":{"M1":"timer_start_pause","M2":"timer_reset"}}}`)
+
+	timer := applet.NewTimer(25*time.Minute, lcd.Font3x5)
+	dispatcher := &actionDispatcher{timer: timer}
+	dev := &testConfigurableDevice{}
+
+	dispatcher.handleActions(device.M1.Uint64(), 0, cfg, dev)
+	_, running := timer.Remaining()
+	assert.True(running)
+
+	dispatcher.handleActions(device.M1.Uint64(), 0, cfg, dev)
+	_, running = timer.Remaining()
+	assert.False(running)
+
+	dispatcher.handleActions(device.M2.Uint64(), 0, cfg, dev)
+	remaining, running := timer.Remaining()
+	assert.False(running)
+	assert.Equal(25*time.Minute, remaining)
+
+	// without a timer the action fails without changing the config
+	dispatcher.timer = nil
+	_, err := dispatcher.dispatch(config.ActionTimerStartPause, cfg, dev)
+	assert.Error(err)
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"sync"
+
+	"github.com/achilleas-k/gg13/internal/audio"
+	"github.com/achilleas-k/gg13/internal/config"
+)
+
+// audioQueueSize is the number of audio device switches that can wait for
+// the ones before them to finish. Switches over it are dropped with a warning
+// instead of blocking the input loop.
+const audioQueueSize = 4
+
+// audioClient switches the default devices of the sound server.
+type audioClient interface {
+	Cycle(dir audio.Direction) (audio.Device, error)
+	Set(dir audio.Direction, device string) (audio.Device, error)
+}
+
+// audioSwitcher switches the default devices of the sound server one at a
+// time, in the background, and shows the device that was switched to on the
+// LCD. A nil *audioSwitcher switches nothing.
+type audioSwitcher struct {
+	w        io.Writer
+	messages *lcdMessages
+	client   audioClient
+	queue    chan config.AudioSwitch
+	done     sync.WaitGroup
+}
+
+func startAudioSwitcher(w io.Writer, messages *lcdMessages, client audioClient) *audioSwitcher {
+	s := &audioSwitcher{
+		w:        w,
+		messages: messages,
+		client:   client,
+		queue:    make(chan config.AudioSwitch, audioQueueSize),
+	}
+	s.done.Add(1)
+	go func() {
+		defer s.done.Done()
+		for sw := range s.queue {
+			s.exec(sw)
+		}
+	}()
+	return s
+}
+
+// run queues the switch to the device, or to the next device if it has none.
+// It doesn't wait for it to run.
+func (s *audioSwitcher) run(sw config.AudioSwitch) {
+	if s == nil {
+		return
+	}
+	select {
+	case s.queue <- sw:
+	default:
+		fmt.Fprintf(s.w, "audio: too many device switches running: skipping %s\n", sw.Direction)
+	}
+}
+
+func (s *audioSwitcher) exec(sw config.AudioSwitch) {
+	var device audio.Device
+	var err error
+	if sw.Device == "" {
+		device, err = s.client.Cycle(sw.Direction)
+	} else {
+		device, err = s.client.Set(sw.Direction, sw.Device)
+	}
+	if err != nil {
+		fmt.Fprintf(s.w, "audio: failed switching %s device: %s\n", sw.Direction, err)
+		s.messages.show("Error: audio %s:\n%s", sw.Direction, err)
+		return
+	}
+	fmt.Fprintf(s.w, "Audio %s: %s\n", sw.Direction, device)
+	s.messages.show("Audio %s:\n%s", sw.Direction, device)
+}
+
+// close waits for the queued switches to finish.
+func (s *audioSwitcher) close() {
+	if s == nil {
+		return
+	}
+	close(s.queue)
+	s.done.Wait()
+}
+
+// handleAudio switches to the sound server devices of the keys pressed since
+// the previous read.
+func handleAudio(input, prevInput uint64, g13cfg *config.G13Config, run func(config.AudioSwitch)) {
+	for gkey, sw := range g13cfg.GetAudioSwitches() {
+		if input&gkey.Uint64() != 0 && prevInput&gkey.Uint64() == 0 {
+			run(sw)
+		}
+	}
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/achilleas-k/gg13/internal/audio"
+	"github.com/achilleas-k/gg13/internal/config"
+	"github.com/achilleas-k/gg13/internal/device"
+	"github.com/achilleas-k/gg13/internal/lcd"
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeAudio switches devices from a fixed list without a sound server.
+type fakeAudio struct {
+	devices []audio.Device
+	current int
+}
+
+func (a *fakeAudio) Cycle(dir audio.Direction) (audio.Device, error) {
+	a.current = (a.current + 1) % len(a.devices)
+	return a.devices[a.current], nil
+}
+
+func (a *fakeAudio) Set(dir audio.Direction, device string) (audio.Device, error) {
+	for idx, d := range a.devices {
+		if d.Name == device {
+			a.current = idx
+			return d, nil
+		}
+	}
+	return audio.Device{}, fmt.Errorf("no %s device matches %q", dir, device)
+}
+
+func TestAudioSwitch(t *testing.T) {
+	assert := assert.New(t)
+
+	cfg := loadTestConfig(t, `{"mapping":{"actions":{"G1":"cycle_audio_output"},"audio":{"G2":"output headphones","G3":"input webcam"}}}`)
+
+	messages := &lcdMessages{duration: time.Minute}
+	testDev := &testOutputDevice{}
+	messages.wrap(testDev)
+	client := &fakeAudio{devices: []audio.Device{
+		{Name: "speakers", Description: "Speakers"},
+		{Name: "headphones", Description: "Headphones"},
+	}}
+	var out bytes.Buffer
+	switcher := startAudioSwitcher(&out, messages, client)
+	dispatcher := &actionDispatcher{messages: messages, audio: switcher}
+
+	dispatcher.handleActions(device.G1.Uint64(), 0, cfg, &testConfigurableDevice{})
+	handleAudio(device.G2.Uint64(), 0, cfg, switcher.run)
+	handleAudio(device.G3.Uint64(), 0, cfg, switcher.run)
+	switcher.close()
+	assert.Equal(`Audio output: Headphones
+Audio output: Headphones
+audio: failed switching input device: no input device matches "webcam"
+`, out.String())
+	assert.Equal(lcd.TextPage(`Error: audio input:
+no input device matches "webcam"`), testDev.lcd)
+
+	// switching needs commands
+	dispatcher.audio = nil
+	_, err := dispatcher.dispatch(config.ActionCycleAudioInput, cfg, &testConfigurableDevice{})
+	assert.EqualError(err, "audio devices can't be switched here")
+}
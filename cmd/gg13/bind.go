@@ -0,0 +1,261 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/achilleas-k/gg13/internal/config"
+	"github.com/achilleas-k/gg13/internal/control"
+	"github.com/achilleas-k/gg13/internal/device"
+	"github.com/achilleas-k/gg13/internal/keyboard"
+	"github.com/spf13/cobra"
+)
+
+// bindTimeout limits how long a control request waits for the input loop to
+// change the binding.
+const bindTimeout = 3 * time.Second
+
+// bindRequest asks the input loop to change a binding, and to save the
+// change to the config file if save is set. The loop sends the outcome on
+// result.
+type bindRequest struct {
+	binding config.Binding
+	save    bool
+	result  chan error
+}
+
+// bindingEditor passes requests to change bindings to the input loop, which
+// owns the config. It keeps the changes that weren't saved, which only the
+// input loop touches, so that they're applied again when the config is
+// reloaded.
+type bindingEditor struct {
+	requests   chan bindRequest
+	configPath string
+	unsaved    []config.Binding
+}
+
+func newBindingEditor(configPath string) *bindingEditor {
+	return &bindingEditor{requests: make(chan bindRequest), configPath: configPath}
+}
+
+// request asks the input loop to change the binding and waits for it to be
+// done.
+func (e *bindingEditor) request(b config.Binding, save bool) error {
+	// buffered so the loop doesn't block if the request timed out
+	req := bindRequest{binding: b, save: save, result: make(chan error, 1)}
+	timeout := time.After(bindTimeout)
+	select {
+	case e.requests <- req:
+	case <-timeout:
+		return fmt.Errorf("timed out waiting for the input loop: is the device being reinitialised?")
+	}
+	select {
+	case err := <-req.result:
+		return err
+	case <-timeout:
+		return fmt.Errorf("timed out waiting for the binding to change")
+	}
+}
+
+// handle runs the request in the input loop. It loads the config with the
+// change, which checks it, saves the change if asked, and returns the new
+// config.
+func (req bindRequest) handle(e *bindingEditor, load func() (*config.G13Config, error)) (*config.G13Config, error) {
+	prev := e.unsaved
+	// the change replaces the unsaved ones to the same key, which would
+	// override it when the config is reloaded after it's saved
+	e.unsaved = slices.DeleteFunc(slices.Clone(prev), func(b config.Binding) bool {
+		return b.Key == req.binding.Key && sameProfile(b.Profile, req.binding.Profile)
+	})
+	e.unsaved = append(e.unsaved, req.binding)
+	g13cfg, err := load()
+	if err == nil && req.save {
+		err = saveBinding(e.configPath, req.binding)
+		e.unsaved = e.unsaved[:len(e.unsaved)-1]
+	}
+	if err != nil {
+		e.unsaved = prev
+		return nil, err
+	}
+	return g13cfg, nil
+}
+
+// sameProfile returns true if the profile names of bindings are the same,
+// with an empty name for the main mapping.
+func sameProfile(a, b string) bool {
+	if a == config.MainProfile {
+		a = ""
+	}
+	if b == config.MainProfile {
+		b = ""
+	}
+	return a == b
+}
+
+// saveBinding writes the change to the config file, or to the file of the
+// profile in a config directory if it has one. The config with the change
+// was checked when it was loaded.
+func saveBinding(configPath string, b config.Binding) error {
+	path := configPath
+	inDir, profileFile := false, false
+	if info, err := os.Stat(configPath); err == nil && info.IsDir() {
+		inDir = true
+		path = filepath.Join(configPath, config.ConfigDirFile)
+		if !sameProfile(b.Profile, "") {
+			if _, err := os.Stat(filepath.Join(configPath, b.Profile+".json")); err == nil {
+				path = filepath.Join(configPath, b.Profile+".json")
+				profileFile = true
+			}
+		}
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed reading config file %q: %w", path, err)
+	}
+	edit := b
+	if profileFile {
+		// the mapping of the profile is at the top of its file
+		edit.Profile = ""
+	}
+	data, err = config.EditBinding(data, edit)
+	if err != nil {
+		return fmt.Errorf("failed saving binding to %q: %w", path, err)
+	}
+	switch {
+	case profileFile:
+		err = writeChecked(path, path, data, func(tmpPath string) error {
+			data, err := os.ReadFile(tmpPath)
+			if err != nil {
+				return err
+			}
+			_, err = config.ReadProfile(b.Profile, data)
+			return err
+		})
+	case inDir:
+		// the main config of a directory can name the profiles in the
+		// other files, so it can't be loaded on its own
+		err = writeChecked(path, path, data, nil)
+	default:
+		err = writeConfig(path, path, data)
+	}
+	if err != nil {
+		return fmt.Errorf("failed writing config: %w", err)
+	}
+	return nil
+}
+
+// handleBind registers the control commands for changing bindings.
+func handleBind(server *control.Server, edits *bindingEditor) {
+	// the options after the keys are profile=<name> and save
+	parse := func(command string, args []string, nKeys int) (config.Binding, bool, error) {
+		if len(args) < nKeys {
+			return config.Binding{}, false, fmt.Errorf("%s: expected at least %d arguments, got %d", command, nKeys, len(args))
+		}
+		b := config.Binding{Key: args[0]}
+		if nKeys == 2 {
+			b.KeyboardKey = args[1]
+			if b.KeyboardKey == "" {
+				return config.Binding{}, false, fmt.Errorf("%s: keyboard key can't be empty", command)
+			}
+		}
+		save := false
+		for _, opt := range args[nKeys:] {
+			if name, ok := strings.CutPrefix(opt, "profile="); ok {
+				b.Profile = name
+				continue
+			}
+			if opt != "save" {
+				return config.Binding{}, false, fmt.Errorf("%s: unknown option: %s", command, opt)
+			}
+			save = true
+		}
+		return b, save, nil
+	}
+
+	server.Handle("bind", func(args []string) (any, error) {
+		b, save, err := parse("bind", args, 2)
+		if err != nil {
+			return nil, err
+		}
+		if err := edits.request(b, save); err != nil {
+			return nil, fmt.Errorf("bind: %w", err)
+		}
+		return nil, nil
+	})
+	server.Handle("unbind", func(args []string) (any, error) {
+		b, save, err := parse("unbind", args, 1)
+		if err != nil {
+			return nil, err
+		}
+		if err := edits.request(b, save); err != nil {
+			return nil, fmt.Errorf("unbind: %w", err)
+		}
+		return nil, nil
+	})
+}
+
+// bindOptions returns the options of the bind and unbind control commands
+// from the flags.
+func bindOptions(cmd *cobra.Command) ([]string, error) {
+	profile, err := cmd.Flags().GetString("profile")
+	if err != nil {
+		return nil, err
+	}
+	save, err := cmd.Flags().GetBool("save")
+	if err != nil {
+		return nil, err
+	}
+	var opts []string
+	if profile != "" {
+		opts = append(opts, "profile="+profile)
+	}
+	if save {
+		opts = append(opts, "save")
+	}
+	return opts, nil
+}
+
+func ctlBind(cmd *cobra.Command, args []string) error {
+	cmd.SilenceUsage = true
+
+	opts, err := bindOptions(cmd)
+	if err != nil {
+		return err
+	}
+	_, err = sendControl(cmd, control.Request{Command: "bind", Args: append(args, opts...)})
+	return err
+}
+
+func ctlUnbind(cmd *cobra.Command, args []string) error {
+	cmd.SilenceUsage = true
+
+	opts, err := bindOptions(cmd)
+	if err != nil {
+		return err
+	}
+	_, err = sendControl(cmd, control.Request{Command: "unbind", Args: append(args, opts...)})
+	return err
+}
+
+// completeBinding completes the arguments of the bind and unbind control
+// commands with nKeys arguments: the name of a G13 key, and then of a
+// keyboard key.
+func completeBinding(nKeys int) cobra.CompletionFunc {
+	return func(_ *cobra.Command, args []string, _ string) ([]string, cobra.ShellCompDirective) {
+		var names []string
+		switch {
+		case len(args) == 0:
+			for _, key := range device.AllKeys() {
+				names = append(names, key.String())
+			}
+		case len(args) == 1 && nKeys == 2:
+			names = keyboard.KeyNames()
+		}
+		return names, cobra.ShellCompDirectiveNoFileComp
+	}
+}
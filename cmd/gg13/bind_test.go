@@ -0,0 +1,136 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/achilleas-k/gg13/internal/config"
+	"github.com/achilleas-k/gg13/internal/control"
+	"github.com/achilleas-k/gg13/internal/device"
+	"github.com/achilleas-k/gg13/internal/keyboard"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBindRequest(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	dir := t.TempDir()
+	require.NoError(os.WriteFile(filepath.Join(dir, config.ConfigDirFile), []byte(`{"mapping":{"keys":{"G1":"KeyA"},"actions":{"G5":"pause"}}}`), 0o600))
+	require.NoError(os.WriteFile(filepath.Join(dir, "game.json"), []byte(`{"key":"M1","mapping":{"keys":{"G2":"KeyB"}}}`), 0o600))
+
+	socketPath := filepath.Join(t.TempDir(), "gg13.sock")
+	server, err := control.NewServer(socketPath)
+	require.NoError(err)
+	defer server.Close()
+
+	edits := newBindingEditor(dir)
+	handleBind(server, edits)
+	load := func() (*config.G13Config, error) {
+		return config.NewFromFileWithBindings(dir, edits.unsaved)
+	}
+	cfg, err := load()
+	require.NoError(err)
+
+	// stand-in for the input loop
+	done := make(chan struct{})
+	defer close(done)
+	configs := make(chan *config.G13Config, 1)
+	configs <- cfg
+	go func() {
+		for {
+			select {
+			case req := <-edits.requests:
+				newCfg, err := req.handle(edits, load)
+				if err == nil {
+					<-configs
+					configs <- newCfg
+				}
+				req.result <- err
+			case <-done:
+				return
+			}
+		}
+	}()
+	current := func() *config.G13Config {
+		cfg := <-configs
+		configs <- cfg
+		return cfg
+	}
+	send := func(args ...string) error {
+		_, err := control.Send(socketPath, control.Request{Command: args[0], Args: args[1:]})
+		return err
+	}
+
+	// the action is replaced
+	require.NoError(send("bind", "G5", "KeyF13"))
+	assert.True(current().GetKeyStates(device.G5.Uint64())[keyboard.KeyCode("KeyF13")])
+	assert.Empty(current().GetActions())
+	require.NoError(send("unbind", "G2", "profile=game"))
+	assert.False(current().WithProfile("game").IsBound(device.G2))
+	// not saved, but kept when the config is reloaded
+	assert.Len(edits.unsaved, 2)
+	cfg, err = load()
+	require.NoError(err)
+	assert.True(cfg.GetKeyStates(device.G5.Uint64())[keyboard.KeyCode("KeyF13")])
+	data, err := os.ReadFile(filepath.Join(dir, config.ConfigDirFile))
+	require.NoError(err)
+	assert.Contains(string(data), "pause")
+
+	// saved to the file of the profile, replacing the unsaved change
+	require.NoError(send("bind", "G2", "KeyC", "profile=game", "save"))
+	assert.Len(edits.unsaved, 1)
+	data, err = os.ReadFile(filepath.Join(dir, "game.json"))
+	require.NoError(err)
+	assert.JSONEq(`{"key":"M1","mapping":{"keys":{"G2":"KeyC"}}}`, string(data))
+	require.NoError(send("unbind", "G1", "save"))
+	data, err = os.ReadFile(filepath.Join(dir, config.ConfigDirFile))
+	require.NoError(err)
+	assert.JSONEq(`{"mapping":{"keys":{},"actions":{"G5":"pause"}}}`, string(data))
+	assert.False(current().IsBound(device.G1))
+
+	for args, expectedErr := range map[string]string{
+		"bind G5 KeyNope":        "bind: failed reading config file: unknown keyboard key name: KeyNope",
+		"bind G99 KeyA":          "bind: failed reading config file: bind: unknown G13 key name: G99",
+		"bind M1 KeyA":           "bind: failed reading config file: mapping: M1 switches to profile game and can't be bound",
+		"unbind G1 profile=nope": "unbind: failed reading config file: bind: unknown profile: nope",
+		"unbind G1 loudly":       "unbind: unknown option: loudly",
+		"bind G1":                "bind: expected at least 2 arguments, got 1",
+	} {
+		assert.EqualError(send(strings.Fields(args)...), expectedErr, args)
+	}
+	// failed changes aren't kept
+	assert.Len(edits.unsaved, 1)
+}
+
+func TestCompleteBinding(t *testing.T) {
+	assert := assert.New(t)
+
+	complete := func(args ...string) []string {
+		cmd := mkcmd()
+		var out bytes.Buffer
+		cmd.SetOut(&out)
+		cmd.SetArgs(append([]string{"__complete", "--socket", filepath.Join(t.TempDir(), "gg13.sock"), "ctl"}, args...))
+		require.NoError(t, cmd.Execute())
+		lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+		assert.Equal(":4", lines[len(lines)-1], "directive")
+		return lines[:len(lines)-1]
+	}
+
+	gKeys := complete("bind", "")
+	assert.Len(gKeys, len(device.AllKeys()))
+	assert.Contains(gKeys, "G1")
+	assert.Contains(gKeys, "M1")
+	assert.Equal(gKeys, complete("unbind", ""))
+
+	assert.Equal(keyboard.KeyNames(), complete("bind", "G1", ""))
+	assert.Empty(complete("bind", "G1", "KeyA", ""))
+	assert.Empty(complete("unbind", "G1", ""))
+
+	// profiles are only completed from a running instance
+	assert.Empty(complete("bind", "--profile", ""))
+}
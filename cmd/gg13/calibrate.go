@@ -0,0 +1,120 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/achilleas-k/gg13/internal/config"
+	"github.com/achilleas-k/gg13/internal/device"
+	"github.com/achilleas-k/gg13/internal/i18n"
+)
+
+// stickCalibrationSpread is how far, in raw units, the stick can move on
+// each axis while its centre is measured. Moving it further means it was
+// touched and the measurement is rejected.
+const stickCalibrationSpread = 16
+
+// stickCalibrator measures the resting centre of the stick by averaging the
+// positions read over [config.StickCalibrationTime].
+type stickCalibrator struct {
+	// end of the measurement; zero when there's none
+	until time.Time
+
+	samples    int
+	sumX, sumY int
+	minX, minY uint8
+	maxX, maxY uint8
+}
+
+// start starts a measurement, discarding any unfinished one.
+func (c *stickCalibrator) start(now time.Time) {
+	*c = stickCalibrator{
+		until: now.Add(config.StickCalibrationTime),
+		minX:  255,
+		minY:  255,
+	}
+}
+
+// active returns true while a measurement is running.
+func (c *stickCalibrator) active() bool {
+	return !c.until.IsZero()
+}
+
+// add adds the stick position in the input (from [device.ReadInput]) to the
+// measurement. It returns true when the measurement is complete and the
+// result is ready.
+func (c *stickCalibrator) add(input uint64, now time.Time) bool {
+	if !c.active() {
+		return false
+	}
+	x, y := device.StickPosition(input)
+	c.samples++
+	c.sumX += int(x)
+	c.sumY += int(y)
+	c.minX, c.maxX = min(c.minX, x), max(c.maxX, x)
+	c.minY, c.maxY = min(c.minY, y), max(c.maxY, y)
+	return !now.Before(c.until)
+}
+
+// result ends the measurement and returns the centre it found.
+func (c *stickCalibrator) result() (config.StickCalibration, error) {
+	defer func() { c.until = time.Time{} }()
+	if c.samples == 0 {
+		return config.StickCalibration{}, fmt.Errorf("no stick positions were read")
+	}
+	if c.maxX-c.minX > stickCalibrationSpread || c.maxY-c.minY > stickCalibrationSpread {
+		return config.StickCalibration{}, fmt.Errorf("the stick moved: leave it at rest while it's calibrated")
+	}
+	return config.StickCalibration{
+		CentreX: uint8((c.sumX + c.samples/2) / c.samples),
+		CentreY: uint8((c.sumY + c.samples/2) / c.samples),
+	}, nil
+}
+
+// inputReader is the part of [device.Device] used for calibrating the stick
+// at startup.
+type inputReader interface {
+	ReadInput() (uint64, time.Time, error)
+}
+
+// calibrateStick measures the centre of the stick by reading from the device
+// for [config.StickCalibrationTime].
+func calibrateStick(dev inputReader) (config.StickCalibration, error) {
+	var c stickCalibrator
+	start := time.Now()
+	c.start(start)
+	for {
+		input, readTime, err := dev.ReadInput()
+		if errors.Is(err, device.ErrReadTimeout) || errors.Is(err, device.ErrNotInputReport) {
+			if time.Since(start) >= config.StickCalibrationTime {
+				break
+			}
+			continue
+		}
+		if err != nil {
+			return config.StickCalibration{}, err
+		}
+		if c.add(input, readTime) {
+			break
+		}
+	}
+	return c.result()
+}
+
+// storeCalibration applies the measured calibration to the config and saves
+// it to path, if set, for the next start.
+func storeCalibration(calibration config.StickCalibration, g13cfg *config.G13Config, path string) {
+	g13cfg.SetMeasuredStickCalibration(&calibration)
+	fmt.Printf("Stick calibrated: centre at %d,%d\n", calibration.CentreX, calibration.CentreY)
+	if g13cfg.HasStickCalibration() {
+		fmt.Println("The stick calibration set in the config is used instead")
+	}
+	if path == "" {
+		return
+	}
+	if err := calibration.Save(path); err != nil {
+		fmt.Fprintln(os.Stderr, i18n.Sprintf("error saving stick calibration: %s", err))
+	}
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/achilleas-k/gg13/internal/config"
+	"github.com/achilleas-k/gg13/internal/device"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testInputReader returns the inputs in order, followed by read timeouts.
+type testInputReader struct {
+	inputs []uint64
+	now    time.Time
+}
+
+func (r *testInputReader) ReadInput() (uint64, time.Time, error) {
+	if len(r.inputs) == 0 {
+		time.Sleep(time.Millisecond)
+		return 0, time.Time{}, device.ErrReadTimeout
+	}
+	input := r.inputs[0]
+	r.inputs = r.inputs[1:]
+	r.now = r.now.Add(100 * time.Millisecond)
+	return input, r.now, nil
+}
+
+func TestStickCalibrator(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	now := time.Now()
+	var c stickCalibrator
+	assert.False(c.add(encodeStickPosition(140, 120), now))
+
+	c.start(now)
+	assert.True(c.active())
+	assert.False(c.add(encodeStickPosition(140, 120), now))
+	assert.False(c.add(encodeStickPosition(141, 121), now.Add(config.StickCalibrationTime/2)))
+	assert.True(c.add(encodeStickPosition(143, 122), now.Add(config.StickCalibrationTime)))
+	calibration, err := c.result()
+	require.NoError(err)
+	assert.Equal(config.StickCalibration{CentreX: 141, CentreY: 121}, calibration)
+	assert.False(c.active())
+
+	c.start(now)
+	c.add(encodeStickPosition(127, 127), now)
+	c.add(encodeStickPosition(200, 127), now)
+	_, err = c.result()
+	assert.EqualError(err, "the stick moved: leave it at rest while it's calibrated")
+
+	// at startup
+	calibration, err = calibrateStick(&testInputReader{
+		inputs: []uint64{encodeStickPosition(100, 110), encodeStickPosition(102, 110)},
+		now:    now,
+	})
+	require.NoError(err)
+	assert.Equal(config.StickCalibration{CentreX: 101, CentreY: 110}, calibration)
+	_, err = calibrateStick(&testInputReader{})
+	assert.EqualError(err, "no stick positions were read")
+}
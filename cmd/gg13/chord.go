@@ -0,0 +1,44 @@
+package main
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/achilleas-k/gg13/internal/config"
+	"github.com/achilleas-k/gg13/internal/keyboard"
+)
+
+// chordDecoder types the keyboard keys of chords: the G13 keys of chords
+// that are pressed together are collected until they're all released, and
+// then the key of the chord they make up is typed.
+type chordDecoder struct {
+	// the keys of chords pressed since they were last all released
+	keys uint64
+}
+
+// handle collects the keys of chords in the input and types the key of the
+// chord when they're all released. Unknown chords type nothing and are
+// reported on w.
+func (c *chordDecoder) handle(input uint64, g13cfg *config.G13Config, vkb keyboard.Keyboard, w io.Writer) {
+	held := input & g13cfg.GetChordKeys()
+	c.keys |= held
+	if held != 0 || c.keys == 0 {
+		return
+	}
+
+	chord := c.keys
+	c.keys = 0
+	kbkey, ok := g13cfg.GetChord(chord)
+	if !ok {
+		fmt.Fprintf(w, "unknown chord: %s\n", config.ChordName(chord))
+		return
+	}
+	if err := vkb.KeyPress(kbkey); err != nil {
+		fmt.Fprintf(hotPathErrors, "keyboard error typing %d: %s\n", kbkey, err)
+	}
+}
+
+// reset forgets the keys collected so far.
+func (c *chordDecoder) reset() {
+	c.keys = 0
+}
@@ -0,0 +1,444 @@
+package main
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"image"
+	"image/png"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/achilleas-k/gg13/internal/applet"
+	"github.com/achilleas-k/gg13/internal/control"
+	"github.com/achilleas-k/gg13/internal/device"
+	"github.com/achilleas-k/gg13/internal/lcd"
+	"github.com/spf13/cobra"
+	_ "golang.org/x/image/bmp" // register the BMP format for image.Decode
+)
+
+// controlTokenEnv is the environment variable holding the token for remote
+// control over TCP, unless --token-file is set.
+const controlTokenEnv = "GG13_CONTROL_TOKEN"
+
+// defaultTCPCommands are the control commands accepted over TCP unless
+// --tcp-commands is set: the ones that switch profiles, show things on the
+// LCD and report the state. Binding keys and switching the output aren't,
+// since they change what the keys type and where it goes.
+var defaultTCPCommands = []string{"clear_counters", "count", "latency", "lcd", "profile", "screenshot", "state", "status", "version"}
+
+// deviceRef holds the active device so that control socket handlers can
+// access it safely while the input loop reinitialises it.
+type deviceRef struct {
+	mu  sync.RWMutex
+	dev device.Device
+}
+
+func (r *deviceRef) get() device.Device {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.dev
+}
+
+func (r *deviceRef) set(dev device.Device) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.dev = dev
+}
+
+// startControlServer starts the control socket server and registers the
+// command handlers.
+func startControlServer(socketPath string, devRef *deviceRef, latency *latencyStats) (*control.Server, error) {
+	server, err := control.NewServer(socketPath)
+	if err != nil {
+		return nil, err
+	}
+
+	server.Handle("screenshot", func([]string) (any, error) {
+		dev := devRef.get()
+		if dev == nil {
+			return nil, fmt.Errorf("device not connected")
+		}
+		img, err := dev.LCDFrame()
+		if err != nil {
+			return nil, err
+		}
+		var buf bytes.Buffer
+		if err := png.Encode(&buf, img); err != nil {
+			return nil, fmt.Errorf("failed encoding LCD image: %w", err)
+		}
+		return buf.Bytes(), nil
+	})
+
+	server.Handle("latency", func([]string) (any, error) {
+		return latency.get(), nil
+	})
+
+	server.Handle("lcd", func(args []string) (any, error) {
+		if len(args) != 1 {
+			return nil, fmt.Errorf("lcd: expected one argument, got %d", len(args))
+		}
+		data, err := base64.StdEncoding.DecodeString(args[0])
+		if err != nil {
+			return nil, fmt.Errorf("lcd: failed decoding image data: %w", err)
+		}
+		// check the size before decoding, which allocates the whole image
+		imgCfg, _, err := image.DecodeConfig(bytes.NewReader(data))
+		if err != nil {
+			return nil, fmt.Errorf("lcd: failed decoding image: %w", err)
+		}
+		if imgCfg.Width != device.LCDWidth || imgCfg.Height != device.LCDHeight {
+			return nil, fmt.Errorf("lcd: image data has incorrect size %dx%d: %dx%d required", imgCfg.Width, imgCfg.Height, device.LCDWidth, device.LCDHeight)
+		}
+		img, _, err := image.Decode(bytes.NewReader(data))
+		if err != nil {
+			return nil, fmt.Errorf("lcd: failed decoding image: %w", err)
+		}
+		dev := devRef.get()
+		if dev == nil {
+			return nil, fmt.Errorf("device not connected")
+		}
+		return nil, dev.SetLCD(img)
+	})
+
+	return server, nil
+}
+
+// handleCounters registers the control commands that update the notification
+// counters applet.
+func handleCounters(server *control.Server, counters *applet.Counters) {
+	server.Handle("count", func(args []string) (any, error) {
+		if len(args) < 1 || len(args) > 2 {
+			return nil, fmt.Errorf("count: expected one or two arguments, got %d", len(args))
+		}
+		n := 1
+		if len(args) == 2 {
+			var err error
+			n, err = strconv.Atoi(args[1])
+			if err != nil {
+				return nil, fmt.Errorf("count: invalid increment %q: %w", args[1], err)
+			}
+		}
+		count, err := counters.Increment(args[0], n)
+		if err != nil {
+			return nil, fmt.Errorf("count: %w", err)
+		}
+		return count, nil
+	})
+
+	server.Handle("clear_counters", func(args []string) (any, error) {
+		if len(args) > 1 {
+			return nil, fmt.Errorf("clear_counters: expected at most one argument, got %d", len(args))
+		}
+		name := ""
+		if len(args) == 1 {
+			name = args[0]
+		}
+		counters.Clear(name)
+		return nil, nil
+	})
+}
+
+// listenTCP makes the control server also listen on the address set with
+// --listen-tcp, if any.
+func listenTCP(cmd *cobra.Command, server *control.Server) error {
+	addr, err := cmd.Flags().GetString("listen-tcp")
+	if err != nil {
+		return err
+	}
+	if addr == "" {
+		return nil
+	}
+	if server == nil {
+		return fmt.Errorf("control server is not running")
+	}
+
+	token, err := controlToken(cmd)
+	if err != nil {
+		return err
+	}
+	if token == "" {
+		return fmt.Errorf("a token is required for remote control: set %s or use --token-file", controlTokenEnv)
+	}
+	commands, err := cmd.Flags().GetStringSlice("tcp-commands")
+	if err != nil {
+		return err
+	}
+	return server.ListenTCP(addr, token, commands)
+}
+
+func mkCtlCmd() *cobra.Command {
+	ctlCmd := &cobra.Command{
+		Use:   "ctl",
+		Short: "Control a running instance",
+	}
+	ctlCmd.PersistentFlags().String("remote", "", "control an instance listening on TCP at this address instead of the local socket")
+
+	screenshotCmd := &cobra.Command{
+		Use:   "screenshot",
+		Short: "Save the image currently displayed on the LCD as a PNG",
+		Args:  cobra.NoArgs,
+		RunE:  ctlScreenshot,
+	}
+	screenshotCmd.Flags().StringP("output", "o", "lcd-screenshot.png", "path to write the image to")
+
+	latencyCmd := &cobra.Command{
+		Use:   "latency",
+		Short: "Show the time taken to handle input reports, from USB read to output",
+		Args:  cobra.NoArgs,
+		RunE:  ctlLatency,
+	}
+
+	lcdCmd := &cobra.Command{
+		Use:   "lcd <image>",
+		Short: "Show a BMP, PNG or SVG image on the LCD",
+		Args:  cobra.ExactArgs(1),
+		RunE:  ctlLCD,
+	}
+
+	countCmd := &cobra.Command{
+		Use:   "count <name> [increment]",
+		Short: "Increment a notification counter shown on the LCD",
+		Args:  cobra.RangeArgs(1, 2),
+		RunE:  ctlCount,
+	}
+
+	clearCountersCmd := &cobra.Command{
+		Use:   "clear-counters [name]",
+		Short: "Clear one or all of the notification counters shown on the LCD",
+		Args:  cobra.MaximumNArgs(1),
+		RunE:  ctlClearCounters,
+	}
+
+	outputCmd := &cobra.Command{
+		Use:   "output [name]",
+		Short: "Show the output backend, or switch to another one",
+		Long: `Show the output backend that key bindings and the joystick are sent to,
+or switch to another one without restarting, for example from a script run
+when the pointer moves to another machine with input-leap or Barrier.`,
+		Args: cobra.MaximumNArgs(1),
+		RunE: ctlOutput,
+	}
+
+	profileCmd := &cobra.Command{
+		Use:   "profile [name]",
+		Short: "Show the active profile, or switch to another one",
+		Long: `Show the active profile and the available ones, or switch to another one
+by name. The main mapping is the "main" profile.`,
+		Args:              cobra.MaximumNArgs(1),
+		RunE:              ctlProfile,
+		ValidArgsFunction: completeProfile,
+	}
+
+	bindCmd := &cobra.Command{
+		Use:   "bind <G13 key> <keyboard key>",
+		Short: "Bind a G13 key to a keyboard key",
+		Long: `Bind a G13 key to a keyboard key, by the names used in the config file,
+replacing whatever it was bound to. The change lasts until gg13 exits, even
+if the config is reloaded, unless it's saved to the config file with --save.`,
+		Args:              cobra.ExactArgs(2),
+		RunE:              ctlBind,
+		ValidArgsFunction: completeBinding(2),
+	}
+	unbindCmd := &cobra.Command{
+		Use:   "unbind <G13 key>",
+		Short: "Unbind a G13 key",
+		Long: `Unbind a G13 key from whatever it was bound to. The change lasts until gg13
+exits, even if the config is reloaded, unless it's saved to the config file
+with --save.`,
+		Args:              cobra.ExactArgs(1),
+		RunE:              ctlUnbind,
+		ValidArgsFunction: completeBinding(1),
+	}
+	for _, c := range []*cobra.Command{bindCmd, unbindCmd} {
+		c.Flags().String("profile", "", "change the binding of this profile instead of the main mapping")
+		c.Flags().Bool("save", false, "save the change to the config file")
+		_ = c.RegisterFlagCompletionFunc("profile", func(cmd *cobra.Command, _ []string, _ string) ([]string, cobra.ShellCompDirective) {
+			return runningProfiles(cmd), cobra.ShellCompDirectiveNoFileComp
+		})
+	}
+
+	stateCmd := &cobra.Command{
+		Use:   "state",
+		Short: "Show the keys and stick position of the G13 and the keys held down by the bindings",
+		Args:  cobra.NoArgs,
+		RunE:  ctlState,
+	}
+
+	versionCmd := &cobra.Command{
+		Use:   "version",
+		Short: "Show the version of the running instance and the latest release, if it checked for one",
+		Args:  cobra.NoArgs,
+		RunE:  ctlVersion,
+	}
+
+	ctlCmd.AddCommand(screenshotCmd)
+	ctlCmd.AddCommand(latencyCmd)
+	ctlCmd.AddCommand(lcdCmd)
+	ctlCmd.AddCommand(countCmd)
+	ctlCmd.AddCommand(clearCountersCmd)
+	ctlCmd.AddCommand(outputCmd)
+	ctlCmd.AddCommand(stateCmd)
+	ctlCmd.AddCommand(profileCmd)
+	ctlCmd.AddCommand(bindCmd)
+	ctlCmd.AddCommand(unbindCmd)
+	ctlCmd.AddCommand(versionCmd)
+	return ctlCmd
+}
+
+// controlToken returns the token for remote control over TCP, read from the
+// file set with --token-file or from the environment.
+func controlToken(cmd *cobra.Command) (string, error) {
+	return readToken(cmd, "token-file", controlTokenEnv)
+}
+
+// readToken returns the token read from the file set with the flag, or else
+// from the environment variable.
+func readToken(cmd *cobra.Command, flag, env string) (string, error) {
+	tokenFile, err := cmd.Flags().GetString(flag)
+	if err != nil {
+		return "", err
+	}
+	if tokenFile == "" {
+		return os.Getenv(env), nil
+	}
+
+	data, err := os.ReadFile(tokenFile)
+	if err != nil {
+		return "", fmt.Errorf("failed reading token file: %w", err)
+	}
+	return strings.TrimSpace(string(data)), nil
+}
+
+// sendControl sends the request to the local control socket, or to the remote
+// instance if --remote is set.
+func sendControl(cmd *cobra.Command, req control.Request) (json.RawMessage, error) {
+	remote, err := cmd.Flags().GetString("remote")
+	if err != nil {
+		return nil, err
+	}
+	if remote != "" {
+		token, err := controlToken(cmd)
+		if err != nil {
+			return nil, err
+		}
+		if token == "" {
+			return nil, fmt.Errorf("a token is required for remote control: set %s or use --token-file", controlTokenEnv)
+		}
+		return control.SendTCP(remote, token, req)
+	}
+
+	socketPath, err := cmd.Flags().GetString("socket")
+	if err != nil {
+		return nil, err
+	}
+	return control.Send(socketPath, req)
+}
+
+func ctlScreenshot(cmd *cobra.Command, _ []string) error {
+	cmd.SilenceUsage = true
+
+	outPath, err := cmd.Flags().GetString("output")
+	if err != nil {
+		return err
+	}
+
+	data, err := sendControl(cmd, control.Request{Command: "screenshot"})
+	if err != nil {
+		return err
+	}
+
+	var pngData []byte
+	if err := json.Unmarshal(data, &pngData); err != nil {
+		return fmt.Errorf("failed decoding screenshot: %w", err)
+	}
+
+	if err := os.WriteFile(outPath, pngData, 0o644); err != nil {
+		return fmt.Errorf("failed to write screenshot file %q: %w", outPath, err)
+	}
+
+	fmt.Printf("LCD screenshot written to %s\n", outPath)
+	return nil
+}
+
+func ctlLatency(cmd *cobra.Command, _ []string) error {
+	cmd.SilenceUsage = true
+
+	data, err := sendControl(cmd, control.Request{Command: "latency"})
+	if err != nil {
+		return err
+	}
+
+	var report latencyReport
+	if err := json.Unmarshal(data, &report); err != nil {
+		return fmt.Errorf("failed decoding latency report: %w", err)
+	}
+
+	fmt.Printf("reports: %d\n", report.Count)
+	fmt.Printf("last:    %s\n", report.Last)
+	fmt.Printf("mean:    %s\n", report.Mean)
+	fmt.Printf("max:     %s\n", report.Max)
+	return nil
+}
+
+func ctlLCD(cmd *cobra.Command, args []string) error {
+	cmd.SilenceUsage = true
+
+	imgPath := args[0]
+	data, err := os.ReadFile(imgPath)
+	if err != nil {
+		return fmt.Errorf("failed to read image file %q: %w", imgPath, err)
+	}
+
+	if strings.EqualFold(filepath.Ext(imgPath), ".svg") {
+		// rasterise SVG images here and send them as PNG
+		img, err := lcd.RenderSVG(bytes.NewReader(data))
+		if err != nil {
+			return fmt.Errorf("failed to read image file %q: %w", imgPath, err)
+		}
+		var buf bytes.Buffer
+		if err := png.Encode(&buf, img); err != nil {
+			return fmt.Errorf("failed to encode image: %w", err)
+		}
+		data = buf.Bytes()
+	}
+
+	// check the image locally for a more helpful error message
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return fmt.Errorf("failed to read image file %q: %w", imgPath, err)
+	}
+	if err := device.ValidateLCDImage(img); err != nil {
+		return err
+	}
+
+	_, err = sendControl(cmd, control.Request{Command: "lcd", Args: []string{base64.StdEncoding.EncodeToString(data)}})
+	return err
+}
+
+func ctlCount(cmd *cobra.Command, args []string) error {
+	cmd.SilenceUsage = true
+
+	data, err := sendControl(cmd, control.Request{Command: "count", Args: args})
+	if err != nil {
+		return err
+	}
+
+	var count int
+	if err := json.Unmarshal(data, &count); err != nil {
+		return fmt.Errorf("failed decoding counter: %w", err)
+	}
+	fmt.Printf("%s: %d\n", args[0], count)
+	return nil
+}
+
+func ctlClearCounters(cmd *cobra.Command, args []string) error {
+	cmd.SilenceUsage = true
+
+	_, err := sendControl(cmd, control.Request{Command: "clear_counters", Args: args})
+	return err
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"bytes"
+	"encoding/base64"
+	"image"
+	"image/png"
+	"path/filepath"
+	"testing"
+
+	"github.com/achilleas-k/gg13/internal/applet"
+	"github.com/achilleas-k/gg13/internal/config"
+	"github.com/achilleas-k/gg13/internal/control"
+	"github.com/achilleas-k/gg13/internal/device"
+	"github.com/achilleas-k/gg13/internal/lcd"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCounters(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	socketPath := filepath.Join(t.TempDir(), "gg13.sock")
+	server, err := control.NewServer(socketPath)
+	require.NoError(err)
+	defer server.Close()
+
+	counters := applet.NewCounters(lcd.Font3x5)
+	handleCounters(server, counters)
+
+	data, err := control.Send(socketPath, control.Request{Command: "count", Args: []string{"mail"}})
+	require.NoError(err)
+	assert.JSONEq("1", string(data))
+	data, err = control.Send(socketPath, control.Request{Command: "count", Args: []string{"mail", "4"}})
+	require.NoError(err)
+	assert.JSONEq("5", string(data))
+	_, err = control.Send(socketPath, control.Request{Command: "count", Args: []string{"mentions"}})
+	require.NoError(err)
+
+	_, err = control.Send(socketPath, control.Request{Command: "count", Args: []string{"mail", "lots"}})
+	assert.ErrorContains(err, `count: invalid increment "lots"`)
+	_, err = control.Send(socketPath, control.Request{Command: "count", Args: []string{"mail", "-1"}})
+	assert.EqualError(err, "count: invalid increment -1: it must be positive")
+	assert.Equal(map[string]int{"mail": 5, "mentions": 1}, counters.Counts())
+
+	_, err = control.Send(socketPath, control.Request{Command: "clear_counters", Args: []string{"mail"}})
+	require.NoError(err)
+	assert.Equal(map[string]int{"mentions": 1}, counters.Counts())
+
+	// the action clears all of them
+	cfg := config.NewEmpty()
+	dispatcher := &actionDispatcher{counters: counters}
+	_, err = dispatcher.dispatch(config.ActionClearCounters, cfg, &testConfigurableDevice{})
+	require.NoError(err)
+	assert.Empty(counters.Counts())
+}
+
+func TestLCDCommand(t *testing.T) {
+	socketPath := filepath.Join(t.TempDir(), "gg13.sock")
+	server, err := startControlServer(socketPath, &deviceRef{}, &latencyStats{})
+	require.NoError(t, err)
+	defer server.Close()
+
+	send := func(width, height int) error {
+		var buf bytes.Buffer
+		require.NoError(t, png.Encode(&buf, image.NewGray(image.Rect(0, 0, width, height))))
+		_, err := control.Send(socketPath, control.Request{Command: "lcd", Args: []string{base64.StdEncoding.EncodeToString(buf.Bytes())}})
+		return err
+	}
+	assert.EqualError(t, send(4000, 3000), "lcd: image data has incorrect size 4000x3000: 160x43 required")
+	assert.EqualError(t, send(device.LCDWidth, device.LCDHeight), "device not connected")
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/achilleas-k/gg13/internal/device"
+	"github.com/spf13/cobra"
+)
+
+func mkDebugCmd() *cobra.Command {
+	debugCmd := &cobra.Command{
+		Use:   "debug",
+		Short: "Debugging utilities",
+	}
+
+	dumpCmd := &cobra.Command{
+		Use:                   "dump",
+		Short:                 "Print the raw input reports from the device as they arrive",
+		Long:                  "Print each raw input report from the device in hex, along with the decoded key names and stick position. Stop with Ctrl+C.",
+		Args:                  cobra.NoArgs,
+		RunE:                  debugDump,
+		DisableFlagsInUseLine: true,
+	}
+
+	debugCmd.AddCommand(dumpCmd)
+	return debugCmd
+}
+
+func debugDump(cmd *cobra.Command, args []string) error {
+	cmd.SilenceUsage = true
+
+	dev, err := device.New()
+	if err != nil {
+		err = fmt.Errorf("device initialisation failed: %w", err)
+		return err
+	}
+	setCleanupHandler(dev.Close)
+	defer dev.Close()
+
+	for {
+		buf, _, err := dev.ReadBytes()
+		if errors.Is(err, device.ErrReadTimeout) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		fmt.Println(formatReport(buf))
+	}
+}
+
+// formatReport formats a raw input report as hex followed by the stick
+// position and the names of the keys that are down. Every bit after the stick
+// bytes is decoded, including the ones that aren't bindable keys.
+func formatReport(buf []byte) string {
+	line := hex.EncodeToString(buf)
+	state, err := device.ParseReport(buf)
+	if errors.Is(err, device.ErrShortReport) {
+		return fmt.Sprintf("%s  (short report: %d bytes)", line, len(buf))
+	}
+	if err != nil {
+		return fmt.Sprintf("%s  (%s)", line, err)
+	}
+	x, y := state.Stick()
+
+	var keys []string
+	for bit := 24; bit < 64; bit++ {
+		kb := device.KeyBit(1 << bit)
+		if !state.IsDown(kb) {
+			continue
+		}
+		keys = append(keys, kb.String())
+	}
+
+	line = fmt.Sprintf("%s  stick=%3d,%3d  byte0=%02x", line, x, y, buf[0])
+	if len(keys) > 0 {
+		line += "  keys=" + strings.Join(keys, ",")
+	}
+	return line
+}
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFormatReport(t *testing.T) {
+	type testCase struct {
+		buf      []byte
+		expected string
+	}
+
+	testCases := map[string]testCase{
+		"idle": {
+			buf:      []byte{0x01, 0x7f, 0x80, 0x00, 0x00, 0x00, 0x00, 0x80},
+			expected: "017f800000000080  stick=127,128  byte0=01  keys=MISC_TOGGLE",
+		},
+		"keys": {
+			buf:      []byte{0x01, 0x00, 0xff, 0x05, 0x00, 0x00, 0x40, 0x00},
+			expected: "0100ff0500004000  stick=  0,255  byte0=01  keys=G1,G3,M2",
+		},
+		"no-keys": {
+			buf:      []byte{0x01, 0x7f, 0x7f, 0x00, 0x00, 0x00, 0x00, 0x00},
+			expected: "017f7f0000000000  stick=127,127  byte0=01",
+		},
+		"short": {
+			buf:      []byte{0x01, 0x7f},
+			expected: "017f  (short report: 2 bytes)",
+		},
+		"not-input": {
+			buf:      []byte{0x03, 0x7f, 0x7f, 0x00, 0x00, 0x00, 0x00, 0x00},
+			expected: "037f7f0000000000  (not an input report: report ID 3)",
+		},
+	}
+
+	for name := range testCases {
+		tc := testCases[name]
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, tc.expected, formatReport(tc.buf))
+		})
+	}
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/pprof"
+	"os"
+	"runtime"
+	"time"
+
+	"github.com/achilleas-k/gg13/internal/i18n"
+)
+
+// debugHeaderTimeout limits how long a client of the debug server can take to
+// send the request headers.
+const debugHeaderTimeout = 5 * time.Second
+
+// runtimeReport is the summary of the runtime state served by the debug
+// server.
+type runtimeReport struct {
+	Goroutines   int           `json:"goroutines"`
+	Threads      int           `json:"threads"`
+	HeapAlloc    uint64        `json:"heap_alloc"`
+	HeapObjects  uint64        `json:"heap_objects"`
+	TotalAlloc   uint64        `json:"total_alloc"`
+	Mallocs      uint64        `json:"mallocs"`
+	NumGC        uint32        `json:"num_gc"`
+	GCPauseTotal time.Duration `json:"gc_pause_total"`
+	GCPauseLast  time.Duration `json:"gc_pause_last"`
+
+	Latency latencyReport `json:"latency"`
+}
+
+func getRuntimeReport(latency *latencyStats) runtimeReport {
+	var mem runtime.MemStats
+	runtime.ReadMemStats(&mem)
+	threads, _ := runtime.ThreadCreateProfile(nil)
+
+	return runtimeReport{
+		Goroutines:   runtime.NumGoroutine(),
+		Threads:      threads,
+		HeapAlloc:    mem.HeapAlloc,
+		HeapObjects:  mem.HeapObjects,
+		TotalAlloc:   mem.TotalAlloc,
+		Mallocs:      mem.Mallocs,
+		NumGC:        mem.NumGC,
+		GCPauseTotal: time.Duration(mem.PauseTotalNs),
+		GCPauseLast:  time.Duration(mem.PauseNs[(mem.NumGC+255)%256]),
+		Latency:      latency.get(),
+	}
+}
+
+// debugHandler serves the pprof profiles under /debug/pprof/ and a JSON
+// summary of the runtime state and input latency under /debug/runtime.
+func debugHandler(latency *latencyStats) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/debug/pprof/", pprof.Index)
+	mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+	mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+	mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+	mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+	mux.HandleFunc("/debug/runtime", func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(getRuntimeReport(latency)); err != nil {
+			fmt.Fprintln(os.Stderr, i18n.Sprintf("debug server error: failed sending runtime report: %s", err))
+		}
+	})
+	return mux
+}
+
+// startDebugServer serves the [debugHandler] on the address. The profiles
+// expose the memory of the process, so the address should only be reachable
+// from the local machine.
+func startDebugServer(addr string, latency *latencyStats) (*http.Server, error) {
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, fmt.Errorf("failed to listen on %q: %w", addr, err)
+	}
+
+	server := &http.Server{
+		Handler:           debugHandler(latency),
+		ReadHeaderTimeout: debugHeaderTimeout,
+	}
+	go func() {
+		if err := server.Serve(listener); !errors.Is(err, http.ErrServerClosed) {
+			fmt.Fprintln(os.Stderr, i18n.Sprintf("debug server error: %s", err))
+		}
+	}()
+	return server, nil
+}
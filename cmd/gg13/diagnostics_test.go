@@ -0,0 +1,40 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDebugHandler(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	latency := &latencyStats{}
+	latency.record(time.Now())
+	handler := debugHandler(latency)
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/debug/runtime", nil))
+	require.Equal(http.StatusOK, rec.Code)
+	var report runtimeReport
+	require.NoError(json.Unmarshal(rec.Body.Bytes(), &report))
+	assert.Positive(report.Goroutines)
+	assert.Positive(report.HeapAlloc)
+	assert.Equal(uint64(1), report.Latency.Count)
+
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/debug/pprof/", nil))
+	assert.Equal(http.StatusOK, rec.Code)
+	assert.Contains(rec.Body.String(), "goroutine")
+
+	// nothing else is served
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+	assert.Equal(http.StatusNotFound, rec.Code)
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/cobra/doc"
+)
+
+func mkManCmd() *cobra.Command {
+	manCmd := &cobra.Command{
+		Use:                   "man",
+		Short:                 "Generate man pages for all commands",
+		Args:                  cobra.NoArgs,
+		RunE:                  genMan,
+		DisableFlagsInUseLine: true,
+	}
+	manCmd.Flags().StringP("output", "o", ".", "directory to write the man pages to")
+	return manCmd
+}
+
+func genMan(cmd *cobra.Command, args []string) error {
+	cmd.SilenceUsage = true
+
+	outDir, err := cmd.Flags().GetString("output")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(outDir, 0o755); err != nil {
+		return fmt.Errorf("failed creating man page directory %q: %w", outDir, err)
+	}
+
+	header := &doc.GenManHeader{
+		Title:   "GG13",
+		Section: "1",
+		Source:  "gg13",
+		Manual:  "GG13 Manual",
+	}
+	if err := doc.GenManTree(cmd.Root(), header, outDir); err != nil {
+		return fmt.Errorf("failed generating man pages: %w", err)
+	}
+	fmt.Printf("Man pages written to %s\n", outDir)
+	return nil
+}
+
+// completeConfigFile completes the config file argument of commands that
+// take one with JSON files.
+func completeConfigFile(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	if len(args) > 0 {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+	return []string{"json"}, cobra.ShellCompDirectiveFilterFileExt
+}
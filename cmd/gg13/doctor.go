@@ -0,0 +1,239 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"os/user"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+
+	"github.com/spf13/cobra"
+)
+
+const (
+	uinputPath   = "/dev/uinput"
+	udevRuleName = "91-g13.rules"
+
+	// sysfs directory listing connected USB devices
+	sysUSBDevices = "/sys/bus/usb/devices"
+
+	// directory containing the USB device nodes
+	devUSB = "/dev/bus/usb"
+
+	// the vendor and product IDs as they appear in sysfs
+	g13VendorID  = "046d"
+	g13ProductID = "c21c"
+)
+
+// udevRuleDirs are the directories that udev loads rules from.
+var udevRuleDirs = []string{
+	"/etc/udev/rules.d",
+	"/run/udev/rules.d",
+	"/usr/lib/udev/rules.d",
+	"/lib/udev/rules.d",
+}
+
+// checkResult is the outcome of a single prerequisite check. A nil err means
+// the check passed.
+type checkResult struct {
+	name   string
+	detail string
+	err    error
+	hint   string
+}
+
+func mkDoctorCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:                   "doctor",
+		Short:                 "Check that everything needed to run the driver is set up",
+		Args:                  cobra.NoArgs,
+		RunE:                  doctor,
+		DisableFlagsInUseLine: true,
+	}
+}
+
+func doctor(cmd *cobra.Command, args []string) error {
+	cmd.SilenceUsage = true
+
+	results := runChecks()
+	if failed := writeCheckResults(os.Stdout, results); failed > 0 {
+		return fmt.Errorf("%d of %d checks failed", failed, len(results))
+	}
+	return nil
+}
+
+// runChecks checks the prerequisites for running the driver.
+func runChecks() []checkResult {
+	return []checkResult{
+		checkUinput(uinputPath),
+		checkUdevRule(udevRuleDirs),
+		checkUSBDevice(sysUSBDevices, devUSB),
+	}
+}
+
+// writeCheckResults writes a line for each check, with hints for the failed
+// ones, and returns the number of failed checks.
+func writeCheckResults(w io.Writer, results []checkResult) int {
+	failed := 0
+	for _, res := range results {
+		if res.err == nil {
+			fmt.Fprintf(w, "ok    %s: %s\n", res.name, res.detail)
+			continue
+		}
+		failed++
+		fmt.Fprintf(w, "FAIL  %s: %s\n", res.name, res.err)
+		if res.hint != "" {
+			fmt.Fprintf(w, "      hint: %s\n", res.hint)
+		}
+	}
+	return failed
+}
+
+// checkUinput checks that the uinput device node exists and is writable.
+func checkUinput(path string) checkResult {
+	res := checkResult{name: "uinput"}
+
+	info, err := os.Stat(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		res.err = fmt.Errorf("%s does not exist", path)
+		res.hint = "load the uinput kernel module (sudo modprobe uinput) and add it to /etc/modules-load.d/ to load it on boot"
+		return res
+	}
+	if err != nil {
+		res.err = err
+		return res
+	}
+
+	if err := checkWritable(path); err != nil {
+		res.err = err
+		res.hint = permissionHint(path, info, fmt.Sprintf("add a udev rule such as KERNEL==\"uinput\", GROUP=\"input\", MODE=\"0660\" to grant write access to %s", path))
+		return res
+	}
+
+	res.detail = fmt.Sprintf("%s is writable", path)
+	return res
+}
+
+// checkUdevRule checks that the udev rule for the G13 is installed in one of
+// the given directories.
+func checkUdevRule(dirs []string) checkResult {
+	res := checkResult{name: "udev rule"}
+	for _, dir := range dirs {
+		path := filepath.Join(dir, udevRuleName)
+		if _, err := os.Stat(path); err == nil {
+			res.detail = fmt.Sprintf("found %s", path)
+			return res
+		}
+	}
+
+	res.err = fmt.Errorf("%s not found in any of %s", udevRuleName, strings.Join(dirs, ", "))
+	res.hint = fmt.Sprintf("copy udev/%s from the project to /etc/udev/rules.d/ and run 'udevadm control --reload-rules && udevadm trigger'", udevRuleName)
+	return res
+}
+
+// checkUSBDevice checks that a G13 is connected and that its device node is
+// writable.
+func checkUSBDevice(sysRoot, devRoot string) checkResult {
+	res := checkResult{name: "usb device"}
+
+	path, err := findUSBDevice(sysRoot, devRoot)
+	if err != nil {
+		res.err = err
+		return res
+	}
+	if path == "" {
+		res.err = fmt.Errorf("no G13 found")
+		res.hint = "make sure the G13 is plugged in"
+		return res
+	}
+
+	if err := checkWritable(path); err != nil {
+		info, statErr := os.Stat(path)
+		if statErr != nil {
+			res.err = statErr
+			return res
+		}
+		res.err = err
+		res.hint = permissionHint(path, info, fmt.Sprintf("install the udev rule (udev/%s) and replug the device", udevRuleName))
+		return res
+	}
+
+	res.detail = fmt.Sprintf("G13 found at %s", path)
+	return res
+}
+
+// findUSBDevice returns the path to the device node of the first connected
+// G13, or an empty string if there is none.
+func findUSBDevice(sysRoot, devRoot string) (string, error) {
+	sysDir, err := findUSBSysDir(sysRoot)
+	if err != nil || sysDir == "" {
+		return "", err
+	}
+	name := filepath.Base(sysDir)
+	busnum, err := strconv.Atoi(readSysAttr(sysDir, "busnum"))
+	if err != nil {
+		return "", fmt.Errorf("failed reading bus number of USB device %s: %w", name, err)
+	}
+	devnum, err := strconv.Atoi(readSysAttr(sysDir, "devnum"))
+	if err != nil {
+		return "", fmt.Errorf("failed reading device number of USB device %s: %w", name, err)
+	}
+	return filepath.Join(devRoot, fmt.Sprintf("%03d", busnum), fmt.Sprintf("%03d", devnum)), nil
+}
+
+// findUSBSysDir returns the sysfs directory of the first connected G13, or
+// an empty string if there is none.
+func findUSBSysDir(sysRoot string) (string, error) {
+	entries, err := os.ReadDir(sysRoot)
+	if err != nil {
+		return "", fmt.Errorf("failed listing USB devices: %w", err)
+	}
+	for _, entry := range entries {
+		dir := filepath.Join(sysRoot, entry.Name())
+		if readSysAttr(dir, "idVendor") == g13VendorID && readSysAttr(dir, "idProduct") == g13ProductID {
+			return dir, nil
+		}
+	}
+	return "", nil
+}
+
+// readSysAttr returns the value of the sysfs attribute of the device, or an
+// empty string if it can't be read.
+func readSysAttr(dir, name string) string {
+	data, err := os.ReadFile(filepath.Join(dir, name))
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(data))
+}
+
+// checkWritable tries to open the file at path for writing.
+func checkWritable(path string) error {
+	file, err := os.OpenFile(path, os.O_WRONLY, 0)
+	if err != nil {
+		return err
+	}
+	_ = file.Close()
+	return nil
+}
+
+// permissionHint returns a hint for gaining write access to the file at path.
+// If the file is writable by its group, it suggests joining the group,
+// otherwise it returns the fallback.
+func permissionHint(path string, info fs.FileInfo, fallback string) string {
+	stat, ok := info.Sys().(*syscall.Stat_t)
+	if !ok || info.Mode().Perm()&0o020 == 0 {
+		return fallback
+	}
+
+	group, err := user.LookupGroupId(strconv.FormatUint(uint64(stat.Gid), 10))
+	if err != nil {
+		return fallback
+	}
+	return fmt.Sprintf("add your user to the %q group that owns %s (sudo usermod -aG %s $USER) and log in again", group.Name, path, group.Name)
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCheckUinput(t *testing.T) {
+	t.Run("missing", func(t *testing.T) {
+		res := checkUinput(filepath.Join(t.TempDir(), "uinput"))
+		assert.ErrorContains(t, res.err, "does not exist")
+		assert.Contains(t, res.hint, "modprobe uinput")
+	})
+
+	t.Run("writable", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "uinput")
+		require.NoError(t, os.WriteFile(path, nil, 0o600))
+		res := checkUinput(path)
+		assert.NoError(t, res.err)
+	})
+}
+
+func TestCheckUdevRule(t *testing.T) {
+	emptyDir := t.TempDir()
+	ruleDir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(ruleDir, udevRuleName), nil, 0o600))
+
+	res := checkUdevRule([]string{emptyDir})
+	assert.ErrorContains(t, res.err, "91-g13.rules not found")
+	assert.Contains(t, res.hint, "udevadm control --reload-rules")
+
+	res = checkUdevRule([]string{emptyDir, ruleDir})
+	assert.NoError(t, res.err)
+	assert.Contains(t, res.detail, ruleDir)
+}
+
+// writeSysfsDevice creates a fake sysfs USB device entry.
+func writeSysfsDevice(t *testing.T, sysRoot, name string, attrs map[string]string) {
+	t.Helper()
+	dir := filepath.Join(sysRoot, name)
+	require.NoError(t, os.MkdirAll(dir, 0o700))
+	for attr, value := range attrs {
+		require.NoError(t, os.WriteFile(filepath.Join(dir, attr), []byte(value+"\n"), 0o600))
+	}
+}
+
+func TestCheckUSBDevice(t *testing.T) {
+	t.Run("not-connected", func(t *testing.T) {
+		sysRoot := t.TempDir()
+		writeSysfsDevice(t, sysRoot, "1-1", map[string]string{"idVendor": "1d6b", "idProduct": "0002", "busnum": "1", "devnum": "1"})
+
+		res := checkUSBDevice(sysRoot, t.TempDir())
+		assert.EqualError(t, res.err, "no G13 found")
+	})
+
+	t.Run("connected", func(t *testing.T) {
+		sysRoot := t.TempDir()
+		devRoot := t.TempDir()
+		writeSysfsDevice(t, sysRoot, "1-1", map[string]string{"idVendor": "1d6b", "idProduct": "0002", "busnum": "1", "devnum": "1"})
+		writeSysfsDevice(t, sysRoot, "3-2", map[string]string{"idVendor": "046d", "idProduct": "c21c", "busnum": "3", "devnum": "12"})
+
+		nodePath := filepath.Join(devRoot, "003", "012")
+		require.NoError(t, os.MkdirAll(filepath.Dir(nodePath), 0o700))
+		require.NoError(t, os.WriteFile(nodePath, nil, 0o600))
+
+		res := checkUSBDevice(sysRoot, devRoot)
+		assert.NoError(t, res.err)
+		assert.Equal(t, "G13 found at "+nodePath, res.detail)
+	})
+
+	t.Run("no-sysfs", func(t *testing.T) {
+		res := checkUSBDevice(filepath.Join(t.TempDir(), "missing"), t.TempDir())
+		assert.ErrorContains(t, res.err, "failed listing USB devices")
+	})
+}
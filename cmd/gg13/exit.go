@@ -0,0 +1,90 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/achilleas-k/gg13/internal/device"
+	"github.com/achilleas-k/gg13/internal/i18n"
+	"github.com/achilleas-k/gg13/internal/joystick"
+	"github.com/achilleas-k/gg13/internal/keyboard"
+	"github.com/achilleas-k/gg13/internal/mouse"
+	"github.com/spf13/cobra"
+)
+
+// The exit codes of the errors that wrapper scripts and service managers can
+// do something about, from sysexits.h. Any other error exits with
+// exitFailure.
+const (
+	exitFailure    = 1
+	exitNoDevice   = 69 // EX_UNAVAILABLE
+	exitUinput     = 72 // EX_OSFILE
+	exitPermission = 77 // EX_NOPERM
+	exitConfig     = 78 // EX_CONFIG
+)
+
+// configError marks an error as a problem with the config, which only
+// editing the config fixes, without changing its message.
+type configError struct {
+	err error
+}
+
+func (e configError) Error() string {
+	return e.err.Error()
+}
+
+func (e configError) Unwrap() error {
+	return e.err
+}
+
+// errorKind returns the name and exit code of the kind of err.
+func errorKind(err error) (string, int) {
+	var cfgErr configError
+	switch {
+	case errors.As(err, &cfgErr):
+		return "config", exitConfig
+	case errors.Is(err, keyboard.ErrUinputUnavailable), errors.Is(err, joystick.ErrUinputUnavailable), errors.Is(err, mouse.ErrUinputUnavailable):
+		return "uinput", exitUinput
+	case errors.Is(err, device.ErrPermission):
+		return "permission", exitPermission
+	case errors.Is(err, device.ErrDeviceGone):
+		return "device_not_found", exitNoDevice
+	default:
+		return "error", exitFailure
+	}
+}
+
+// jsonError is the error written with --json-errors.
+type jsonError struct {
+	Error string `json:"error"`
+	Kind  string `json:"kind"`
+	Code  int    `json:"code"`
+	Hint  string `json:"hint,omitempty"`
+}
+
+// jsonErrors returns true if the errors are written as JSON.
+func jsonErrors(cmd *cobra.Command) bool {
+	enabled, err := cmd.PersistentFlags().GetBool("json-errors")
+	return err == nil && enabled
+}
+
+// writeError writes the error that cmd failed with, and the remediation hint
+// if there is one, as a line of JSON if enabled, and returns the exit code.
+// Without --json-errors, the error itself has already been printed by cobra.
+func writeError(w io.Writer, cmd *cobra.Command, err error) int {
+	kind, code := errorKind(err)
+	hint := remediationHint(err)
+	if !jsonErrors(cmd) || !cmd.SilenceErrors {
+		if hint != "" {
+			fmt.Fprintln(w, i18n.Sprintf("hint: %s", hint))
+			fmt.Fprintln(w, i18n.T("run 'gg13 doctor' to check all prerequisites"))
+		}
+		return code
+	}
+	if encErr := json.NewEncoder(w).Encode(jsonError{Error: err.Error(), Kind: kind, Code: code, Hint: hint}); encErr != nil {
+		fmt.Fprintln(w, cmd.ErrPrefix(), err)
+	}
+	return code
+}
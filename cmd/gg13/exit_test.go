@@ -0,0 +1,117 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/achilleas-k/gg13/internal/device"
+	"github.com/achilleas-k/gg13/internal/keyboard"
+	"github.com/achilleas-k/gg13/internal/mouse"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestErrorKind(t *testing.T) {
+	testCases := map[string]struct {
+		err     error
+		expKind string
+		expCode int
+	}{
+		"config": {
+			err:     configError{fmt.Errorf("failed reading config file: mapping: unknown G13 key name: G99")},
+			expKind: "config",
+			expCode: exitConfig,
+		},
+		"usb-permission": {
+			err:     fmt.Errorf("device initialisation failed: %w", device.ErrPermission),
+			expKind: "permission",
+			expCode: exitPermission,
+		},
+		"device-gone": {
+			err:     fmt.Errorf("device initialisation failed: %w", device.ErrDeviceGone),
+			expKind: "device_not_found",
+			expCode: exitNoDevice,
+		},
+		"keyboard-uinput": {
+			err:     fmt.Errorf("virtual keyboard initialisation failed: %w", keyboard.ErrUinputUnavailable),
+			expKind: "uinput",
+			expCode: exitUinput,
+		},
+		"mouse-uinput": {
+			err:     fmt.Errorf("virtual mouse initialisation failed: %w", mouse.ErrUinputUnavailable),
+			expKind: "uinput",
+			expCode: exitUinput,
+		},
+		"lcd-applet": {
+			err:     lcdAppletErr(t),
+			expKind: "config",
+			expCode: exitConfig,
+		},
+		"other": {
+			err:     fmt.Errorf("something else"),
+			expKind: "error",
+			expCode: exitFailure,
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			kind, code := errorKind(tc.err)
+			assert.Equal(t, tc.expKind, kind)
+			assert.Equal(t, tc.expCode, code)
+		})
+	}
+}
+
+// lcdAppletErr returns the error of the LCD applet of a config whose text
+// file is removed after it's loaded.
+func lcdAppletErr(t *testing.T) error {
+	textPath := filepath.Join(t.TempDir(), "status.txt")
+	require.NoError(t, os.WriteFile(textPath, []byte("ok"), 0o600))
+	g13cfg := loadTestConfig(t, fmt.Sprintf(`{"text_file": %q}`, textPath))
+	require.NoError(t, os.Remove(textPath))
+	_, _, err := lcdAppletOf(g13cfg)
+	require.ErrorContains(t, err, "failed reading text file")
+	return err
+}
+
+func TestWriteError(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	cfgPath := writeTestConfig(t, `{"mapping": {"keys": {"G99": "KeyA"}}}`)
+
+	run := func(asJSON bool, args ...string) (string, int) {
+		cmd := mkcmd()
+		cmd.SetArgs(args)
+		var stderr bytes.Buffer
+		cmd.SetErr(&stderr)
+		// what main does before executing
+		if asJSON {
+			cmd.SilenceErrors = true
+			cmd.SilenceUsage = true
+		}
+		err := cmd.Execute()
+		require.Error(err)
+		code := writeError(&stderr, cmd, err)
+		return stderr.String(), code
+	}
+
+	out, code := run(false, "lint", cfgPath)
+	assert.Equal(exitConfig, code)
+	assert.Contains(out, "Error: failed reading config file")
+
+	out, code = run(true, "lint", "--json-errors", cfgPath)
+	assert.Equal(exitConfig, code)
+	var jsonErr jsonError
+	require.NoError(json.Unmarshal([]byte(out), &jsonErr))
+	assert.Equal("config", jsonErr.Kind)
+	assert.Equal(exitConfig, jsonErr.Code)
+	assert.Contains(jsonErr.Error, "failed reading config file")
+	assert.Empty(jsonErr.Hint)
+
+}
@@ -0,0 +1,36 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/achilleas-k/gg13/internal/config"
+)
+
+// backlightOverrider is the part of [device.Device] used for flashing the
+// backlight.
+type backlightOverrider interface {
+	OverrideBacklightColour(r, g, b uint8, dt time.Duration) (uint64, error)
+	ClearBacklightOverride() error
+}
+
+// handleFlashes changes the backlight colour for keys with a flash configured
+// when they're pressed and, for flashes held while the key is down, restores
+// it when they're released. prevInput is the input from the previous read.
+func handleFlashes(input, prevInput uint64, g13cfg *config.G13Config, dev backlightOverrider) {
+	for gkey, flash := range g13cfg.GetBacklightFlashes() {
+		isDown := gkey.Uint64()&input != 0
+		wasDown := gkey.Uint64()&prevInput != 0
+		switch {
+		case isDown && !wasDown:
+			colour := flash.Colour
+			if _, err := dev.OverrideBacklightColour(colour[0], colour[1], colour[2], flash.Duration); err != nil {
+				fmt.Fprintf(hotPathErrors, "error flashing backlight: %s\n", err)
+			}
+		case !isDown && wasDown && flash.Duration == 0:
+			if err := dev.ClearBacklightOverride(); err != nil {
+				fmt.Fprintf(hotPathErrors, "error restoring backlight: %s\n", err)
+			}
+		}
+	}
+}
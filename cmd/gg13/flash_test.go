@@ -0,0 +1,71 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/achilleas-k/gg13/internal/device"
+	"github.com/stretchr/testify/assert"
+)
+
+type overrideEvent struct {
+	action string
+	colour [3]uint8
+	dt     time.Duration
+}
+
+// testOverrider records backlight overrides and numbers them like the
+// device. Overrides don't expire.
+type testOverrider struct {
+	events []overrideEvent
+
+	// the number of the last override and of the active one, zero if none
+	last   uint64
+	active uint64
+}
+
+func (o *testOverrider) OverrideBacklightColour(r, g, b uint8, dt time.Duration) (uint64, error) {
+	o.events = append(o.events, overrideEvent{action: "override", colour: [3]uint8{r, g, b}, dt: dt})
+	o.last++
+	o.active = o.last
+	return o.last, nil
+}
+
+func (o *testOverrider) ClearBacklightOverride() error {
+	o.events = append(o.events, overrideEvent{action: "clear"})
+	o.active = 0
+	return nil
+}
+
+func (o *testOverrider) EndBacklightOverride(id uint64) error {
+	if id != 0 && id == o.active {
+		return o.ClearBacklightOverride()
+	}
+	return nil
+}
+
+func TestHandleFlashes(t *testing.T) {
+	cfg := loadTestConfig(t, `{"backlight":{"flash":{"G1":{"colour":"red","duration":"100ms"},"MR":{"colour":"blue","duration":"hold"}}}}`)
+
+	inputs := []uint64{
+		device.G1.Uint64(),                      // G1 pressed: flash
+		device.G1.Uint64(),                      // G1 held: nothing
+		0,                                       // G1 released: nothing, the flash expires on its own
+		device.MR.Uint64(),                      // MR pressed: override
+		device.MR.Uint64() | device.G2.Uint64(), // unrelated key: nothing
+		0,                                       // MR released: clear
+	}
+
+	overrider := &testOverrider{}
+	var prevInput uint64
+	for _, input := range inputs {
+		handleFlashes(input, prevInput, cfg, overrider)
+		prevInput = input
+	}
+
+	assert.Equal(t, []overrideEvent{
+		{action: "override", colour: [3]uint8{255, 0, 0}, dt: 100 * time.Millisecond},
+		{action: "override", colour: [3]uint8{0, 0, 255}},
+		{action: "clear"},
+	}, overrider.events)
+}
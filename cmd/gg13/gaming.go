@@ -0,0 +1,50 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"runtime"
+	"syscall"
+	"unsafe"
+)
+
+// hotPathErrors receives the errors from handling each input report. Gaming
+// mode discards them, so a failing output doesn't slow down the input loop by
+// writing an error for every report.
+var hotPathErrors io.Writer = os.Stderr
+
+const (
+	// SCHED_FIFO from linux/sched.h
+	schedFIFO = 1
+
+	// gamingModePriority is the realtime priority of the input loop in gaming
+	// mode. It's low in the 1-99 range, to stay below the kernel's own
+	// realtime threads, while still preempting all normal threads.
+	gamingModePriority = 10
+)
+
+// struct sched_param from linux/sched/types.h
+type schedParam struct {
+	priority int32
+}
+
+// enterGamingMode prepares the calling goroutine, which must be the one
+// running the input loop, for the lowest input latency: it locks it to its OS
+// thread, so it's never waiting for a thread to be scheduled on, and
+// discards the errors in the hot path. It then tries to give the thread
+// realtime (SCHED_FIFO) priority, which needs CAP_SYS_NICE or a high enough
+// RLIMIT_RTPRIO. Failing to do so is returned as an error, but everything
+// else stays in effect.
+func enterGamingMode() error {
+	runtime.LockOSThread()
+	hotPathErrors = io.Discard
+
+	param := schedParam{priority: gamingModePriority}
+	// pid 0 is the calling thread
+	_, _, errno := syscall.RawSyscall(syscall.SYS_SCHED_SETSCHEDULER, 0, schedFIFO, uintptr(unsafe.Pointer(&param)))
+	if errno != 0 {
+		return fmt.Errorf("failed setting realtime priority %d (needs CAP_SYS_NICE or an RLIMIT_RTPRIO of at least %d): %w", gamingModePriority, gamingModePriority, errno)
+	}
+	return nil
+}
@@ -0,0 +1,25 @@
+package main
+
+import (
+	"io"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEnterGamingMode(t *testing.T) {
+	t.Cleanup(func() { hotPathErrors = os.Stderr })
+
+	// run it in its own goroutine: the thread it's locked to, along with its
+	// priority, is discarded when the goroutine exits
+	errChan := make(chan error)
+	go func() {
+		errChan <- enterGamingMode()
+	}()
+	if err := <-errChan; err != nil {
+		// realtime priority isn't permitted everywhere
+		assert.ErrorContains(t, err, "needs CAP_SYS_NICE")
+	}
+	assert.Equal(t, io.Discard, hotPathErrors)
+}
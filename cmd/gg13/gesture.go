@@ -0,0 +1,38 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/achilleas-k/gg13/internal/config"
+	"github.com/achilleas-k/gg13/internal/device"
+	"github.com/achilleas-k/gg13/internal/gesture"
+	"github.com/achilleas-k/gg13/internal/keyboard"
+)
+
+// newGestureDetector returns a detector for the stick gestures in the
+// config, or nil if no gestures are bound.
+func newGestureDetector(g13cfg *config.G13Config) *gesture.Detector {
+	if len(g13cfg.GetGestureBindings()) == 0 {
+		return nil
+	}
+	return gesture.NewDetector(g13cfg.GetGestureThresholds())
+}
+
+// handleGestures feeds the stick position to the detector and presses the
+// key bound to the gesture it completes, if any.
+func handleGestures(input uint64, readTime time.Time, g13cfg *config.G13Config, detector *gesture.Detector, vkb keyboard.Keyboard) {
+	x, y := g13cfg.GetStickCalibration().Normalise(device.StickPosition(input))
+	g := detector.Update(x, y, readTime)
+	if g == 0 {
+		return
+	}
+
+	kbkey, ok := g13cfg.GetGestureBindings()[g]
+	if !ok {
+		return
+	}
+	if err := vkb.KeyPress(kbkey); err != nil {
+		fmt.Fprintf(hotPathErrors, "keyboard error pressing %d for gesture %s: %s\n", kbkey, g, err)
+	}
+}
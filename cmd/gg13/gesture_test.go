@@ -0,0 +1,44 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/achilleas-k/gg13/internal/config"
+	"github.com/achilleas-k/gg13/internal/keyboard"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHandleGestures(t *testing.T) {
+	cfg := loadTestConfig(t, `{"mapping":{"stick":{"gestures":{"bindings":{"flick_left":"KeyA"}}}}}`)
+
+	detector := newGestureDetector(cfg)
+	require.NotNil(t, detector)
+
+	inputs := []uint64{
+		encodeStickPosition(127, 127),
+		encodeStickPosition(0, 127),   // left edge
+		encodeStickPosition(127, 127), // back to the centre: flick_left
+		encodeStickPosition(127, 0),   // up edge
+		encodeStickPosition(127, 127), // back to the centre: flick_up, not bound
+	}
+
+	kb := newTestKeyboard(t)
+	now := time.Now()
+	for _, input := range inputs {
+		kb.newEvent()
+		now = now.Add(20 * time.Millisecond)
+		handleGestures(input, now, cfg, detector, kb)
+	}
+
+	assert.Equal(t, [][]testEvent{
+		{},
+		{},
+		{{action: "press", code: keyboard.KeyCode("KeyA")}},
+		{},
+		{},
+	}, kb.events)
+
+	assert.Nil(t, newGestureDetector(config.NewEmpty()))
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+)
+
+// hookQueueSize is the number of profile hooks that can wait for the ones
+// before them to finish. Hooks over it are dropped with a warning instead of
+// blocking the input loop.
+const hookQueueSize = 16
+
+// profileHook is a command run when a profile becomes active or inactive.
+type profileHook struct {
+	profile string
+	// "on_enter" or "on_leave", for the log
+	event   string
+	command string
+	timeout time.Duration
+}
+
+// hookRunner runs profile hooks one at a time, in the order they're queued,
+// so that the command leaving a profile finishes before the one entering the
+// next starts. A nil *hookRunner runs nothing.
+type hookRunner struct {
+	w     io.Writer
+	queue chan profileHook
+	done  sync.WaitGroup
+}
+
+func startHookRunner(w io.Writer) *hookRunner {
+	r := &hookRunner{
+		w:     w,
+		queue: make(chan profileHook, hookQueueSize),
+	}
+	r.done.Add(1)
+	go func() {
+		defer r.done.Done()
+		for hook := range r.queue {
+			r.exec(hook)
+		}
+	}()
+	return r
+}
+
+// run queues the hook. It doesn't wait for it to run.
+func (r *hookRunner) run(hook profileHook) {
+	if r == nil || hook.command == "" {
+		return
+	}
+	select {
+	case r.queue <- hook:
+	default:
+		fmt.Fprintf(r.w, "profile %s: too many hooks running: skipping %s\n", hook.profile, hook.event)
+	}
+}
+
+// exec runs the hook with the shell and the name of the profile in
+// GG13_PROFILE, and logs its output if it fails.
+func (r *hookRunner) exec(hook profileHook) {
+	ctx, cancel := context.WithTimeout(context.Background(), hook.timeout)
+	defer cancel()
+	cmd := exec.CommandContext(ctx, "/bin/sh", "-c", hook.command)
+	cmd.Env = append(os.Environ(), "GG13_PROFILE="+hook.profile)
+	// don't wait for background processes of the command holding the output
+	// open after it's stopped
+	cmd.WaitDelay = time.Second
+	output, err := cmd.CombinedOutput()
+	if ctx.Err() == context.DeadlineExceeded {
+		err = fmt.Errorf("timed out after %s", hook.timeout)
+	}
+	if err == nil {
+		return
+	}
+	fmt.Fprintf(r.w, "profile %s: %s hook failed: %s\n", hook.profile, hook.event, err)
+	if output := strings.TrimSpace(string(output)); output != "" {
+		fmt.Fprintln(r.w, output)
+	}
+}
+
+// close waits for the queued hooks to finish.
+func (r *hookRunner) close() {
+	if r == nil {
+		return
+	}
+	close(r.queue)
+	r.done.Wait()
+}
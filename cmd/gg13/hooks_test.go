@@ -0,0 +1,50 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/achilleas-k/gg13/internal/device"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestProfileHooks(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	tmpDir := t.TempDir()
+	logPath := filepath.Join(tmpDir, "hooks.log")
+	cfgData, err := json.Marshal(map[string]any{
+		"profiles": map[string]any{
+			"game": map[string]any{
+				"key":      "M1",
+				"on_enter": `echo "enter $GG13_PROFILE" >> ` + logPath,
+				"on_leave": `echo "leave $GG13_PROFILE" >> ` + logPath,
+			},
+			"slow": map[string]any{
+				"key":          "M2",
+				"on_enter":     "sleep 10",
+				"hook_timeout": "50ms",
+			},
+		},
+	})
+	require.NoError(err)
+	cfg := loadTestConfig(t, string(cfgData))
+
+	var log bytes.Buffer
+	dispatcher := &actionDispatcher{hooks: startHookRunner(&log)}
+	dev := &testConfigurableDevice{}
+	for _, input := range []uint64{device.M1.Uint64(), 0, device.M2.Uint64(), 0} {
+		dispatcher.handleActions(input, 0, cfg, dev)
+	}
+	dispatcher.hooks.close()
+
+	data, err := os.ReadFile(logPath)
+	require.NoError(err)
+	assert.Equal("enter game\nleave game\n", string(data))
+	assert.Equal("profile slow: on_enter hook failed: timed out after 50ms\n", log.String())
+}
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"io/fs"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+	"unicode"
+
+	"github.com/achilleas-k/gg13/internal/i18n"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// sourceFiles parses the Go files of the command and of the internal
+// packages, without their tests, by path.
+func sourceFiles(t *testing.T) (*token.FileSet, map[string]*ast.File) {
+	fset := token.NewFileSet()
+	files := make(map[string]*ast.File)
+	for _, root := range []string{".", filepath.Join("..", "..", "internal")} {
+		err := filepath.WalkDir(root, func(path string, entry fs.DirEntry, err error) error {
+			if err != nil || entry.IsDir() || !strings.HasSuffix(path, ".go") || strings.HasSuffix(path, "_test.go") {
+				return err
+			}
+			if root == "." && filepath.Dir(path) != "." {
+				return nil
+			}
+			files[path], err = parser.ParseFile(fset, path, nil, 0)
+			return err
+		})
+		require.NoError(t, err)
+	}
+	return fset, files
+}
+
+// selector returns the package and name of an expression like fmt.Sprintf,
+// or empty strings if it's something else.
+func selector(expr ast.Expr) (string, string) {
+	sel, ok := expr.(*ast.SelectorExpr)
+	if !ok {
+		return "", ""
+	}
+	pkg, ok := sel.X.(*ast.Ident)
+	if !ok {
+		return "", ""
+	}
+	return pkg.Name, sel.Sel.Name
+}
+
+// stringLiteral returns the value of the expression if it's a string literal.
+func stringLiteral(t *testing.T, expr ast.Expr) (string, bool) {
+	lit, ok := expr.(*ast.BasicLit)
+	if !ok || lit.Kind != token.STRING {
+		return "", false
+	}
+	value, err := strconv.Unquote(lit.Value)
+	require.NoError(t, err)
+	return value, true
+}
+
+// translatedMessages returns the messages of the command that are translated:
+// the string literals passed to the functions of the i18n package.
+func translatedMessages(t *testing.T) map[string]bool {
+	_, files := sourceFiles(t)
+	messages := make(map[string]bool)
+	for _, f := range files {
+		ast.Inspect(f, func(node ast.Node) bool {
+			call, ok := node.(*ast.CallExpr)
+			if !ok || len(call.Args) == 0 {
+				return true
+			}
+			if pkg, _ := selector(call.Fun); pkg != "i18n" {
+				return true
+			}
+			if msg, ok := stringLiteral(t, call.Args[0]); ok {
+				messages[msg] = true
+			}
+			return true
+		})
+	}
+	return messages
+}
+
+// formatVerb matches the formatting verbs of a message.
+var formatVerb = regexp.MustCompile(`%[-+# 0-9.*\[\]]*[a-zA-Z%]`)
+
+func TestStderrTranslated(t *testing.T) {
+	fset, files := sourceFiles(t)
+	for _, f := range files {
+		ast.Inspect(f, func(node ast.Node) bool {
+			call, ok := node.(*ast.CallExpr)
+			if !ok || len(call.Args) < 2 {
+				return true
+			}
+			if pkg, name := selector(call.Fun); pkg != "fmt" || !strings.HasPrefix(name, "Fprint") {
+				return true
+			}
+			if pkg, name := selector(call.Args[0]); pkg != "os" || name != "Stderr" {
+				return true
+			}
+			// messages that are only formatting verbs, like "%s: %s", have
+			// nothing to translate
+			msg, ok := stringLiteral(t, call.Args[1])
+			if ok && strings.ContainsFunc(formatVerb.ReplaceAllString(msg, ""), unicode.IsLetter) {
+				t.Errorf("%s: message printed on stderr isn't translated: %q", fset.Position(call.Pos()), msg)
+			}
+			return true
+		})
+	}
+}
+
+func TestCataloguesComplete(t *testing.T) {
+	messages := translatedMessages(t)
+	require.Contains(t, messages, "Ready")
+	for _, locale := range i18n.Locales() {
+		t.Run(locale, func(t *testing.T) {
+			catalogue, err := i18n.Load(locale)
+			require.NoError(t, err)
+			for msg := range messages {
+				assert.NotEmpty(t, catalogue[msg], "%s: missing translation of %q", locale, msg)
+			}
+			for msg := range catalogue {
+				assert.True(t, messages[msg], "%s: %q isn't used", locale, msg)
+			}
+		})
+	}
+}
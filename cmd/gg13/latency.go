@@ -0,0 +1,45 @@
+package main
+
+import (
+	"sync"
+	"time"
+)
+
+// latencyStats records the time between reading an input report from the
+// device and finishing handling it (emitting all the resulting events).
+type latencyStats struct {
+	mu     sync.Mutex
+	report latencyReport
+}
+
+// latencyReport is the summary of the recorded latencies returned over the
+// control socket.
+type latencyReport struct {
+	Count uint64        `json:"count"`
+	Last  time.Duration `json:"last"`
+	Mean  time.Duration `json:"mean"`
+	Max   time.Duration `json:"max"`
+}
+
+// record the latency of an input report that was read at the given time.
+func (ls *latencyStats) record(readTime time.Time) {
+	dt := time.Since(readTime)
+
+	ls.mu.Lock()
+	defer ls.mu.Unlock()
+
+	r := &ls.report
+	r.Count++
+	r.Last = dt
+	// incremental mean to avoid keeping a running total that can overflow
+	r.Mean += (dt - r.Mean) / time.Duration(r.Count)
+	if dt > r.Max {
+		r.Max = dt
+	}
+}
+
+func (ls *latencyStats) get() latencyReport {
+	ls.mu.Lock()
+	defer ls.mu.Unlock()
+	return ls.report
+}
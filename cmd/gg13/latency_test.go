@@ -0,0 +1,27 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLatencyStats(t *testing.T) {
+	assert := assert.New(t)
+
+	ls := &latencyStats{}
+	assert.Equal(latencyReport{}, ls.get())
+
+	now := time.Now()
+	ls.record(now.Add(-2 * time.Millisecond))
+	ls.record(now.Add(-4 * time.Millisecond))
+
+	report := ls.get()
+	assert.Equal(uint64(2), report.Count)
+	assert.GreaterOrEqual(report.Last, 4*time.Millisecond)
+	assert.GreaterOrEqual(report.Max, 4*time.Millisecond)
+	assert.Equal(report.Last, report.Max)
+	assert.GreaterOrEqual(report.Mean, 3*time.Millisecond)
+	assert.Less(report.Mean, report.Max)
+}
@@ -0,0 +1,133 @@
+package main
+
+import (
+	"fmt"
+	"image"
+	"image/png"
+	"os"
+	"time"
+
+	"github.com/achilleas-k/gg13/internal/applet"
+	"github.com/achilleas-k/gg13/internal/config"
+	"github.com/achilleas-k/gg13/internal/device"
+	"github.com/spf13/cobra"
+)
+
+func mkLCDCmd() *cobra.Command {
+	lcdCmd := &cobra.Command{
+		Use:   "lcd",
+		Short: "LCD utilities",
+	}
+
+	previewCmd := &cobra.Command{
+		Use:                   "preview <config>",
+		Short:                 "Render the LCD content defined in a config file to a PNG image",
+		Args:                  cobra.ExactArgs(1),
+		RunE:                  lcdPreview,
+		ValidArgsFunction:     completeConfigFile,
+		DisableFlagsInUseLine: true,
+	}
+	previewCmd.Flags().StringP("output", "o", "lcd-preview.png", "path to write the rendered image to")
+
+	cheatSheetCmd := &cobra.Command{
+		Use:                   "cheatsheet <config>",
+		Short:                 "Render the key bindings defined in a config file to a PNG image",
+		Args:                  cobra.ExactArgs(1),
+		RunE:                  lcdCheatSheet,
+		ValidArgsFunction:     completeConfigFile,
+		DisableFlagsInUseLine: true,
+	}
+	cheatSheetCmd.Flags().StringP("output", "o", "lcd-cheatsheet.png", "path to write the rendered image to")
+
+	lcdCmd.AddCommand(previewCmd)
+	lcdCmd.AddCommand(cheatSheetCmd)
+	return lcdCmd
+}
+
+func lcdPreview(cmd *cobra.Command, args []string) error {
+	cmd.SilenceUsage = true
+
+	outPath, err := cmd.Flags().GetString("output")
+	if err != nil {
+		return err
+	}
+
+	g13cfg, err := config.NewFromFile(args[0])
+	if err != nil {
+		return configError{err}
+	}
+
+	img, err := g13cfg.GetLCDImage()
+	if err != nil {
+		return err
+	}
+	if img == nil {
+		lcdApplet, _, err := lcdAppletOf(g13cfg)
+		if err != nil {
+			return err
+		}
+		if lcdApplet == nil {
+			return fmt.Errorf("no LCD content defined in config")
+		}
+		img, err = lcdApplet.Render()
+		if err != nil {
+			return err
+		}
+		img = g13cfg.GetLCDMonochrome().Apply(img)
+	}
+
+	return writeLCDPNG(img, outPath)
+}
+
+func lcdCheatSheet(cmd *cobra.Command, args []string) error {
+	cmd.SilenceUsage = true
+
+	outPath, err := cmd.Flags().GetString("output")
+	if err != nil {
+		return err
+	}
+
+	g13cfg, err := config.NewFromFile(args[0])
+	if err != nil {
+		return configError{err}
+	}
+
+	return writeLCDPNG(g13cfg.CheatSheet(), outPath)
+}
+
+// writeLCDPNG renders the image as it would be displayed on the LCD and writes
+// it to a PNG file.
+func writeLCDPNG(img image.Image, outPath string) error {
+	rendered, err := device.RenderLCD(img)
+	if err != nil {
+		return err
+	}
+
+	outFile, err := os.Create(outPath)
+	if err != nil {
+		return fmt.Errorf("failed to create image file %q: %w", outPath, err)
+	}
+
+	if err := png.Encode(outFile, rendered); err != nil {
+		_ = outFile.Close()
+		return fmt.Errorf("failed to write image file %q: %w", outPath, err)
+	}
+	if err := outFile.Close(); err != nil {
+		return fmt.Errorf("failed to write image file %q: %w", outPath, err)
+	}
+
+	fmt.Printf("LCD image written to %s\n", outPath)
+	return nil
+}
+
+// lcdAppletOf returns the LCD applet of the config and its render interval.
+// The config was checked when it was loaded, so an error means something it
+// refers to changed since, like a text file that was removed, which only
+// editing the config or restoring the file fixes.
+func lcdAppletOf(g13cfg *config.G13Config) (applet.Applet, time.Duration, error) {
+	lcdApplet, interval, err := g13cfg.GetLCDApplet()
+	if err != nil {
+		return nil, 0, configError{err}
+	}
+	return lcdApplet, interval, nil
+}
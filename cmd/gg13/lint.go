@@ -0,0 +1,55 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/achilleas-k/gg13/internal/config"
+	"github.com/achilleas-k/gg13/internal/i18n"
+	"github.com/spf13/cobra"
+)
+
+func mkLintCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "lint <config>",
+		Short: "Check a config for mistakes",
+		Long: `Check that a config loads, and look for the mistakes that don't stop it
+from loading but make it work differently than it looks like it would, like
+keys that are set twice, bindings of disabled keys, chords that can't be
+typed, and profiles that can't be switched to with the G13. It fails if the
+config doesn't load or has any of them.`,
+		Args:                  cobra.ExactArgs(1),
+		RunE:                  lint,
+		ValidArgsFunction:     completeConfigFile,
+		DisableFlagsInUseLine: true,
+	}
+}
+
+func lint(cmd *cobra.Command, args []string) error {
+	cmd.SilenceUsage = true
+
+	g13cfg, err := config.NewFromFile(args[0])
+	if err != nil {
+		return configError{err}
+	}
+	switch n := writeConfigWarnings(os.Stdout, g13cfg); n {
+	case 0:
+	case 1:
+		return configError{i18n.Errorf("1 problem found")}
+	default:
+		return configError{i18n.Errorf("%d problems found", n)}
+	}
+	fmt.Println(i18n.T("No problems found"))
+	return nil
+}
+
+// writeConfigWarnings writes a line for each warning of the config and
+// returns the number of warnings.
+func writeConfigWarnings(w io.Writer, g13cfg *config.G13Config) int {
+	warnings := g13cfg.GetWarnings()
+	for _, warning := range warnings {
+		fmt.Fprintf(w, "config warning: %s\n", warning)
+	}
+	return len(warnings)
+}
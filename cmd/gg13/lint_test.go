@@ -0,0 +1,36 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"testing"
+
+	"github.com/achilleas-k/gg13/internal/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLint(t *testing.T) {
+	cfgPath := writeTestConfig(t, `{"mapping":{"keys":{"G1":"KeyA"},"disabled":["G1"]},"profiles":{"p":{}}}`)
+	cfg, err := config.NewFromFile(cfgPath)
+	require.NoError(t, err)
+	var out bytes.Buffer
+	assert.Equal(t, 2, writeConfigWarnings(&out, cfg))
+	assert.Equal(t, `config warning: mapping: G1 is bound but disabled
+config warning: profiles: p: has no key and isn't a page: only 'gg13 ctl profile' can switch to it
+`, out.String())
+
+	run := func() error {
+		cmd := mkLintCmd()
+		cmd.SetArgs([]string{cfgPath})
+		cmd.SetOut(io.Discard)
+		cmd.SetErr(io.Discard)
+		return cmd.Execute()
+	}
+	assert.EqualError(t, run(), "2 problems found")
+	require.NoError(t, os.WriteFile(cfgPath, []byte(`{"mapping":{"keys":{"G1":"KeyA"}}}`), 0o600))
+	assert.NoError(t, run())
+	require.NoError(t, os.WriteFile(cfgPath, []byte(`{"mapping":{"keys":{"G1":"KeyNope"}}}`), 0o600))
+	assert.EqualError(t, run(), "failed reading config file: unknown keyboard key name: KeyNope")
+}
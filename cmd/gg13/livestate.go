@@ -0,0 +1,150 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/achilleas-k/gg13/internal/config"
+	"github.com/achilleas-k/gg13/internal/control"
+	"github.com/achilleas-k/gg13/internal/device"
+	"github.com/achilleas-k/gg13/internal/keyboard"
+	"github.com/spf13/cobra"
+)
+
+// liveState is the latest input and how it's mapped, for external tools
+// showing the G13 state, like overlays. The input loop updates it after
+// handling each input.
+type liveState struct {
+	mu          sync.Mutex
+	input       uint64
+	outputCfg   *config.G13Config
+	paused      bool
+	passthrough bool
+	profile     string
+}
+
+func (s *liveState) update(input uint64, outputCfg *config.G13Config, paused, passthrough bool, profile string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.input = input
+	s.outputCfg = outputCfg
+	s.paused = paused
+	s.passthrough = passthrough
+	s.profile = profile
+}
+
+// activeProfile returns the name of the active profile, or an empty string
+// for the main mapping.
+func (s *liveState) activeProfile() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.profile
+}
+
+// stickState is the stick position, as read and normalised with the
+// calibration to [-1, 1].
+type stickState struct {
+	RawX uint8   `json:"raw_x"`
+	RawY uint8   `json:"raw_y"`
+	X    float32 `json:"x"`
+	Y    float32 `json:"y"`
+}
+
+// stateReport is the reply to the state control command.
+type stateReport struct {
+	// Input is the key and stick state as read from the device
+	Input uint64 `json:"input"`
+
+	// Keys are the names of the G13 keys that are down
+	Keys  []string   `json:"keys"`
+	Stick stickState `json:"stick"`
+
+	// OutputKeys are the names of the keyboard keys held down by the
+	// bindings
+	OutputKeys []string `json:"output_keys"`
+
+	Output      string `json:"output"`
+	Passthrough bool   `json:"passthrough"`
+
+	// Paused is true while output is paused or the keys are locked
+	Paused bool `json:"paused"`
+
+	// Profile is the name of the active profile, empty for the main
+	// mapping
+	Profile string `json:"profile"`
+}
+
+// report returns the state, with the name of the active output backend.
+func (s *liveState) report(output string) stateReport {
+	s.mu.Lock()
+	input, outputCfg := s.input, s.outputCfg
+	paused, passthrough, profile := s.paused, s.passthrough, s.profile
+	s.mu.Unlock()
+
+	report := stateReport{
+		Input:       input,
+		Keys:        []string{},
+		OutputKeys:  []string{},
+		Output:      output,
+		Paused:      paused,
+		Passthrough: passthrough,
+		Profile:     profile,
+	}
+	for _, key := range device.AllKeys() {
+		if key.Uint64()&input != 0 {
+			report.Keys = append(report.Keys, key.String())
+		}
+	}
+	rawX, rawY := device.StickPosition(input)
+	report.Stick = stickState{RawX: rawX, RawY: rawY}
+	if outputCfg == nil {
+		// nothing read yet
+		return report
+	}
+
+	report.Stick.X, report.Stick.Y = outputCfg.GetStickCalibration().Normalise(rawX, rawY)
+	if !paused {
+		outputCfg.EachKeyState(input, func(kbkey int, isDown bool) {
+			if isDown {
+				report.OutputKeys = append(report.OutputKeys, keyboard.KeyName(kbkey))
+			}
+		})
+	}
+	return report
+}
+
+// handleState registers the control command returning the live state.
+func handleState(server *control.Server, live *liveState, outputs *outputSwitcher) {
+	server.Handle("state", func(args []string) (any, error) {
+		if len(args) != 0 {
+			return nil, fmt.Errorf("state: expected no arguments, got %d", len(args))
+		}
+		return live.report(outputs.get()), nil
+	})
+}
+
+func ctlState(cmd *cobra.Command, _ []string) error {
+	cmd.SilenceUsage = true
+
+	data, err := sendControl(cmd, control.Request{Command: "state"})
+	if err != nil {
+		return err
+	}
+
+	var report stateReport
+	if err := json.Unmarshal(data, &report); err != nil {
+		return fmt.Errorf("failed decoding state: %w", err)
+	}
+	fmt.Printf("keys:        %s\n", strings.Join(report.Keys, " "))
+	fmt.Printf("stick:       %d %d (%.2f %.2f)\n", report.Stick.RawX, report.Stick.RawY, report.Stick.X, report.Stick.Y)
+	fmt.Printf("output keys: %s\n", strings.Join(report.OutputKeys, " "))
+	fmt.Printf("output:      %s\n", report.Output)
+	fmt.Printf("paused:      %t\n", report.Paused)
+	fmt.Printf("passthrough: %t\n", report.Passthrough)
+	if report.Profile != "" {
+		fmt.Printf("profile:     %s\n", report.Profile)
+	}
+	return nil
+}
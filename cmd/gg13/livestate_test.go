@@ -0,0 +1,45 @@
+package main
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/achilleas-k/gg13/internal/control"
+	"github.com/achilleas-k/gg13/internal/device"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestState(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	socketPath := filepath.Join(t.TempDir(), "gg13.sock")
+	server, err := control.NewServer(socketPath)
+	require.NoError(err)
+	defer server.Close()
+
+	live := &liveState{}
+	handleState(server, live, newOutputSwitcher("uinput"))
+
+	// nothing read yet
+	data, err := control.Send(socketPath, control.Request{Command: "state"})
+	require.NoError(err)
+	assert.JSONEq(`{"input":0,"keys":[],"stick":{"raw_x":0,"raw_y":0,"x":0,"y":0},"output_keys":[],"output":"uinput","paused":false,"passthrough":false,"profile":""}`, string(data))
+
+	cfg := loadTestConfig(t, `{"mapping":{"keys":{"G1":"KeyA","G2":"KeyB"}}}`)
+
+	centre := uint64(0x80)<<8 | uint64(0x80)<<16
+	live.update(device.G1.Uint64()|device.M1.Uint64()|centre, cfg, false, false, "")
+	report := live.report("uinput")
+	assert.Equal([]string{"G1", "M1"}, report.Keys)
+	assert.Equal([]string{"KeyA"}, report.OutputKeys)
+	assert.Equal(uint8(0x80), report.Stick.RawX)
+
+	// no output while paused
+	live.update(device.G1.Uint64(), cfg, true, false, "")
+	report = live.report("uinput")
+	assert.Equal([]string{"G1"}, report.Keys)
+	assert.Empty(report.OutputKeys)
+	assert.True(report.Paused)
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/achilleas-k/gg13/internal/config"
+	"github.com/achilleas-k/gg13/internal/i18n"
+	"github.com/achilleas-k/gg13/internal/lcd"
+)
+
+// muted returns true if no output is emitted: while output is paused, the
+// keys are locked, or the screen is locked, unless output follows the screen
+// lock profile.
+func (d *actionDispatcher) muted() bool {
+	return d.paused || d.locked || (d.screen.isLocked() && !d.screenLockProfile)
+}
+
+// lock stops all output until the unlock chord is held and shows the chord to
+// unlock with on the LCD.
+func (d *actionDispatcher) lock(g13cfg *config.G13Config) {
+	names := make([]string, 0, len(g13cfg.GetUnlockChord()))
+	for _, gkey := range g13cfg.GetUnlockChord() {
+		names = append(names, gkey.String())
+	}
+	chord := strings.Join(names, "+")
+	d.locked = true
+	fmt.Println(i18n.Sprintf("Keys locked: hold %s to unlock", chord))
+	d.messages.hold(lcd.TextPage("Locked\nUnlock: " + chord))
+}
+
+// handleUnlock unlocks the keys when the last key of the unlock chord is
+// pressed while the others are held.
+func (d *actionDispatcher) handleUnlock(input, prevInput uint64, g13cfg *config.G13Config) {
+	var chord uint64
+	for _, gkey := range g13cfg.GetUnlockChord() {
+		chord |= gkey.Uint64()
+	}
+	if input&chord != chord || prevInput&chord == chord {
+		return
+	}
+	d.locked = false
+	d.messages.release()
+	if d.numpad.profile != "" {
+		d.showNumpad()
+	}
+	fmt.Println(i18n.T("Keys unlocked"))
+	d.messages.show("Keys unlocked")
+}
@@ -0,0 +1,41 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/achilleas-k/gg13/internal/device"
+	"github.com/achilleas-k/gg13/internal/lcd"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLockAction(t *testing.T) {
+	assert := assert.New(t)
+
+	cfg := loadTestConfig(t, `{"mapping":{"keys":{"G1":"KeyA"},"actions":{"G22":"lock","M3":"pause"}},"profiles":{"game":{"key":"M1"}},"lock":{"unlock":["G20","G21"]}}`)
+
+	messages := &lcdMessages{}
+	testDev := &testOutputDevice{}
+	messages.wrap(testDev)
+	dispatcher := &actionDispatcher{messages: messages}
+	dev := &testConfigurableDevice{}
+
+	dispatcher.handleActions(device.G22.Uint64(), 0, cfg, dev)
+	assert.True(dispatcher.locked)
+	assert.True(dispatcher.muted())
+	assert.Equal(lcd.TextPage("Locked\nUnlock: G20+G21"), testDev.lcd)
+
+	// actions and profiles do nothing while locked
+	dispatcher.handleActions(device.M3.Uint64()|device.M1.Uint64(), 0, cfg, dev)
+	assert.False(dispatcher.paused)
+	assert.Empty(dispatcher.activeProfile())
+	dispatcher.handleActions(device.G20.Uint64(), 0, cfg, dev)
+	assert.True(dispatcher.locked)
+
+	// the whole chord unlocks, once
+	dispatcher.handleActions(device.G20.Uint64()|device.G21.Uint64(), device.G20.Uint64(), cfg, dev)
+	assert.False(dispatcher.locked)
+	assert.False(dispatcher.muted())
+	assert.Nil(testDev.lcd)
+	dispatcher.handleActions(device.G20.Uint64()|device.G21.Uint64(), device.G20.Uint64()|device.G21.Uint64(), cfg, dev)
+	assert.False(dispatcher.locked)
+}
@@ -0,0 +1,374 @@
+package main
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/achilleas-k/gg13/internal/config"
+	"github.com/achilleas-k/gg13/internal/device"
+	"github.com/achilleas-k/gg13/internal/keyboard"
+	"github.com/spf13/cobra"
+)
+
+// evdev event types and key values, from linux/input-event-codes.h
+const (
+	evKey = 0x01
+
+	keyReleased = 0
+	keyPressed  = 1
+)
+
+// evdevEvent is a struct input_event read from an evdev device.
+type evdevEvent struct {
+	Time  syscall.Timeval
+	Type  uint16
+	Code  uint16
+	Value int32
+}
+
+func (ev evdevEvent) time() time.Time {
+	return time.Unix(ev.Time.Unix())
+}
+
+// recordMacro reads the key events from the evdev device until stopKey is
+// pressed and returns them as a macro, timed like they were typed. Releases
+// of keys that were already held when recording started are left out, and
+// keys still held when it stops are released at the end.
+func recordMacro(r io.Reader, stopKey int) ([]config.MacroEvent, error) {
+	var events []config.MacroEvent
+	var held []int
+	var start, prev time.Time
+	for {
+		var ev evdevEvent
+		if err := binary.Read(r, binary.NativeEndian, &ev); err != nil {
+			if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+				return nil, fmt.Errorf("device closed before %s was pressed", keyboard.KeyName(stopKey))
+			}
+			return nil, err
+		}
+		// skip autorepeat and everything that isn't a key
+		if ev.Type != evKey || (ev.Value != keyPressed && ev.Value != keyReleased) {
+			continue
+		}
+		code := int(ev.Code)
+		if code == stopKey && ev.Value == keyPressed {
+			break
+		}
+
+		down := ev.Value == keyPressed
+		if down {
+			held = append(held, code)
+		} else {
+			idx := slices.Index(held, code)
+			if idx < 0 {
+				continue
+			}
+			held = append(held[:idx], held[idx+1:]...)
+		}
+
+		evTime := ev.time()
+		var delay time.Duration
+		if start.IsZero() {
+			start = evTime
+		} else {
+			delay = max(evTime.Sub(prev), 0)
+		}
+		prev = evTime
+		if evTime.Sub(start) > config.MaxMacroDuration {
+			return nil, fmt.Errorf("macro takes longer than the maximum of %s", config.MaxMacroDuration)
+		}
+		events = append(events, config.MacroEvent{Key: code, Down: down, Delay: delay})
+	}
+
+	for _, code := range held {
+		events = append(events, config.MacroEvent{Key: code})
+	}
+	return events, nil
+}
+
+// playMacro sends the events of the macro to the keyboard, waiting for the
+// delay of each with sleep, and releases the keys it leaves pressed. It stops
+// early, without an error, if sleep returns false because ctx is done.
+func playMacro(ctx context.Context, events []config.MacroEvent, vkb keyboard.Keyboard, sleep func(context.Context, time.Duration) bool) error {
+	var held []int
+	defer func() {
+		for _, code := range held {
+			_ = vkb.KeyUp(code)
+		}
+	}()
+	for _, event := range events {
+		if event.Delay > 0 && !sleep(ctx, event.Delay) {
+			return nil
+		}
+		if event.Down {
+			if err := vkb.KeyDown(event.Key); err != nil {
+				return err
+			}
+			held = append(held, event.Key)
+			continue
+		}
+		if err := vkb.KeyUp(event.Key); err != nil {
+			return err
+		}
+		if idx := slices.Index(held, event.Key); idx >= 0 {
+			held = append(held[:idx], held[idx+1:]...)
+		}
+	}
+	return nil
+}
+
+// sleepContext waits for d and returns true, or returns false as soon as ctx
+// is done.
+func sleepContext(ctx context.Context, d time.Duration) bool {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// playingMacro is a macro being played by a [macroPlayer].
+type playingMacro struct {
+	cancel context.CancelFunc
+}
+
+// macroPlayer plays macros in the background, so that input is still handled
+// while a macro plays, for up to [config.MaxMacroDuration]. Pressing the key
+// of a macro that is playing stops it, and macros that repeat play again
+// until their key is released. A nil *macroPlayer plays nothing.
+type macroPlayer struct {
+	w     io.Writer
+	sleep func(context.Context, time.Duration) bool
+
+	// play the macros that repeat once, for the simulator, which waits for
+	// every macro to finish
+	playOnce bool
+
+	mu      sync.Mutex
+	playing map[device.KeyBit]*playingMacro
+	done    sync.WaitGroup
+}
+
+func newMacroPlayer(w io.Writer, sleep func(context.Context, time.Duration) bool) *macroPlayer {
+	return &macroPlayer{
+		w:       w,
+		sleep:   sleep,
+		playing: make(map[device.KeyBit]*playingMacro),
+	}
+}
+
+// toggle starts playing the macro bound to the G13 key on the keyboard, or
+// stops it if it's already playing.
+func (p *macroPlayer) toggle(gkey device.KeyBit, name string, events []config.MacroEvent, vkb keyboard.Keyboard) {
+	if p == nil {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if macro, ok := p.playing[gkey]; ok {
+		macro.cancel()
+		delete(p.playing, gkey)
+		return
+	}
+	p.start(gkey, name, events, vkb, false)
+}
+
+// hold starts playing the macro bound to the G13 key on the keyboard over
+// and over, until release is called for the key.
+func (p *macroPlayer) hold(gkey device.KeyBit, name string, events []config.MacroEvent, vkb keyboard.Keyboard) {
+	if p == nil {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if _, ok := p.playing[gkey]; ok {
+		return
+	}
+	p.start(gkey, name, events, vkb, !p.playOnce)
+}
+
+// release stops the macro that the G13 key holds, which releases the keys
+// it pressed.
+func (p *macroPlayer) release(gkey device.KeyBit) {
+	if p == nil {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if macro, ok := p.playing[gkey]; ok {
+		macro.cancel()
+		delete(p.playing, gkey)
+	}
+}
+
+// start plays the macro in the background, again each time it finishes if
+// repeat is set. It's called with mu held.
+func (p *macroPlayer) start(gkey device.KeyBit, name string, events []config.MacroEvent, vkb keyboard.Keyboard, repeat bool) {
+	ctx, cancel := context.WithCancel(context.Background())
+	macro := &playingMacro{cancel: cancel}
+	p.playing[gkey] = macro
+	p.done.Add(1)
+	go func() {
+		defer p.done.Done()
+		defer cancel()
+		for {
+			if err := playMacro(ctx, events, vkb, p.sleep); err != nil {
+				fmt.Fprintf(p.w, "error playing macro %s: %s\n", name, err)
+				break
+			}
+			if !repeat || ctx.Err() != nil {
+				break
+			}
+		}
+		p.mu.Lock()
+		// the key may have stopped it and started it again meanwhile
+		if p.playing[gkey] == macro {
+			delete(p.playing, gkey)
+		}
+		p.mu.Unlock()
+	}()
+}
+
+// stop stops the macros that are playing and waits for them to release
+// their keys, before the keyboard they play on is released or closed.
+func (p *macroPlayer) stop() {
+	if p == nil {
+		return
+	}
+	p.mu.Lock()
+	for gkey, macro := range p.playing {
+		macro.cancel()
+		delete(p.playing, gkey)
+	}
+	p.mu.Unlock()
+	p.done.Wait()
+}
+
+// wait waits for the macros that are playing to finish.
+func (p *macroPlayer) wait() {
+	if p == nil {
+		return
+	}
+	p.done.Wait()
+}
+
+// handleMacros starts or stops the macros bound to keys that were pressed
+// since the previous read, and the macros that repeat while their keys are
+// held when they're pressed or released.
+func handleMacros(input, prevInput uint64, g13cfg *config.G13Config, vkb keyboard.Keyboard, macros *macroPlayer) {
+	for gkey, name := range g13cfg.GetMacroBindings() {
+		isDown := gkey.Uint64()&input != 0
+		wasDown := gkey.Uint64()&prevInput != 0
+		switch {
+		case isDown == wasDown:
+		case !g13cfg.RepeatsMacro(gkey):
+			if isDown {
+				macros.toggle(gkey, name, g13cfg.GetMacro(name), vkb)
+			}
+		case isDown:
+			macros.hold(gkey, name, g13cfg.GetMacro(name), vkb)
+		default:
+			macros.release(gkey)
+		}
+	}
+}
+
+func mkMacroCmd() *cobra.Command {
+	macroCmd := &cobra.Command{
+		Use:   "macro",
+		Short: "Record macros bound to G13 keys",
+	}
+
+	recordCmd := &cobra.Command{
+		Use:   "record <config> <name>",
+		Short: "Record a macro from a keyboard and save it in the config",
+		Long: "Record the keys typed on a keyboard, with their timing, until the stop key is pressed, and save them " +
+			"as a macro with the given name in the config. Bind it to a G13 key in the macros section of the " +
+			"mapping, and list the key in repeat_macros to play it for as long as the key is held. " +
+			"The keyboard is read from its evdev device, such as " +
+			"/dev/input/by-id/usb-<name>-event-kbd, which usually requires being in the input group.",
+		Args:                  cobra.ExactArgs(2),
+		RunE:                  macroRecord,
+		ValidArgsFunction:     completeConfigFile,
+		DisableFlagsInUseLine: true,
+	}
+	recordCmd.Flags().String("device", "", "evdev device of the keyboard to record from")
+	recordCmd.Flags().String("stop-key", "KeyEsc", "key that stops recording, which isn't recorded")
+	recordCmd.Flags().Bool("force", false, "replace a macro with the same name")
+	_ = recordCmd.MarkFlagRequired("device")
+
+	macroCmd.AddCommand(recordCmd)
+	return macroCmd
+}
+
+func macroRecord(cmd *cobra.Command, args []string) error {
+	cmd.SilenceUsage = true
+
+	configPath, name := args[0], args[1]
+	devicePath, err := cmd.Flags().GetString("device")
+	if err != nil {
+		return err
+	}
+	stopKeyName, err := cmd.Flags().GetString("stop-key")
+	if err != nil {
+		return err
+	}
+	force, err := cmd.Flags().GetBool("force")
+	if err != nil {
+		return err
+	}
+	stopKey := keyboard.KeyCode(stopKeyName)
+	if stopKey == 0 {
+		return fmt.Errorf("unknown keyboard key name: %s", stopKeyName)
+	}
+
+	// the main config of a config directory
+	if info, err := os.Stat(configPath); err == nil && info.IsDir() {
+		configPath = filepath.Join(configPath, config.ConfigDirFile)
+	}
+	data, err := os.ReadFile(configPath)
+	if err != nil {
+		return fmt.Errorf("failed reading config file %q: %w", configPath, err)
+	}
+	// fail before recording if the macro can't be added
+	if _, err := config.AddMacro(data, name, nil, force); err != nil {
+		return fmt.Errorf("failed adding macro to %q: %w", configPath, err)
+	}
+
+	dev, err := os.Open(devicePath)
+	if err != nil {
+		return fmt.Errorf("failed opening keyboard: %w", err)
+	}
+	defer func() { _ = dev.Close() }()
+
+	fmt.Printf("Recording from %s: press %s to stop\n", devicePath, stopKeyName)
+	events, err := recordMacro(dev, stopKey)
+	if err != nil {
+		return fmt.Errorf("failed recording macro: %w", err)
+	}
+	if len(events) == 0 {
+		return fmt.Errorf("no keys recorded")
+	}
+
+	data, err = config.AddMacro(data, name, events, force)
+	if err != nil {
+		return fmt.Errorf("failed adding macro to %q: %w", configPath, err)
+	}
+	if err := writeConfig(configPath, configPath, data); err != nil {
+		return fmt.Errorf("failed writing config: %w", err)
+	}
+	fmt.Printf("Macro %s with %d events saved in %s\n", name, len(events), configPath)
+	return nil
+}
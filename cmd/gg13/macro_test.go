@@ -0,0 +1,192 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"io"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/achilleas-k/gg13/internal/config"
+	"github.com/achilleas-k/gg13/internal/device"
+	"github.com/achilleas-k/gg13/internal/keyboard"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRecordMacro(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	keyA, keyB, keyEnter, keyEsc := keyboard.KeyCode("KeyA"), keyboard.KeyCode("KeyB"), keyboard.KeyCode("KeyEnter"), keyboard.KeyCode("KeyEsc")
+	var buf bytes.Buffer
+	write := func(ms int64, evType uint16, code int, value int32) {
+		ev := evdevEvent{Time: syscall.NsecToTimeval(ms * int64(time.Millisecond)), Type: evType, Code: uint16(code), Value: value}
+		require.NoError(binary.Write(&buf, binary.NativeEndian, ev))
+	}
+	// the enter key starting the recording is released
+	write(0, evKey, keyEnter, keyReleased)
+	write(100, evKey, keyA, keyPressed)
+	write(100, 0, 0, 0)
+	write(150, evKey, keyA, 2)
+	write(180, evKey, keyA, keyReleased)
+	write(300, evKey, keyB, keyPressed)
+	write(400, evKey, keyEsc, keyPressed)
+	write(500, evKey, keyA, keyPressed)
+
+	events, err := recordMacro(bytes.NewReader(buf.Bytes()), keyEsc)
+	require.NoError(err)
+	assert.Equal([]config.MacroEvent{
+		{Key: keyA, Down: true},
+		{Key: keyA, Down: false, Delay: 80 * time.Millisecond},
+		{Key: keyB, Down: true, Delay: 120 * time.Millisecond},
+		{Key: keyB, Down: false},
+	}, events)
+
+	// no stop key
+	_, err = recordMacro(bytes.NewReader(buf.Bytes()[:len(buf.Bytes())/4]), keyEsc)
+	assert.EqualError(err, "device closed before KeyEsc was pressed")
+
+	buf.Reset()
+	write(0, evKey, keyA, keyPressed)
+	write(int64(config.MaxMacroDuration/time.Millisecond)+1, evKey, keyA, keyReleased)
+	_, err = recordMacro(bytes.NewReader(buf.Bytes()), keyEsc)
+	assert.EqualError(err, "macro takes longer than the maximum of 10s")
+}
+
+func TestHandleMacros(t *testing.T) {
+	assert := assert.New(t)
+
+	keyA, keyB := keyboard.KeyCode("KeyA"), keyboard.KeyCode("KeyB")
+	g13cfg := loadTestConfig(t, `{
+		"mapping": {"keys": {"G1": "KeyC"}, "macros": {"G2": "ab"}},
+		"macros": {"ab": [
+			{"key": "KeyA", "down": true},
+			{"key": "KeyA", "down": false, "delay": "20ms"},
+			{"key": "KeyB", "down": true, "delay": "1s"}
+		]}
+	}`)
+
+	tk := newTestKeyboard(t)
+	tk.newEvent()
+	var slept time.Duration
+	macros := newMacroPlayer(io.Discard, func(_ context.Context, d time.Duration) bool {
+		slept += d
+		return true
+	})
+
+	handleMacros(device.G2.Uint64(), 0, g13cfg, tk, macros)
+	macros.wait()
+	// held, not pressed again
+	handleMacros(device.G2.Uint64(), device.G2.Uint64(), g13cfg, tk, macros)
+	// another key
+	handleMacros(device.G1.Uint64(), 0, g13cfg, tk, macros)
+	macros.wait()
+	assert.Equal([][]testEvent{{
+		{action: "down", code: keyA},
+		{action: "up", code: keyA},
+		{action: "down", code: keyB},
+		// left pressed by the macro
+		{action: "up", code: keyB},
+	}}, tk.events)
+	assert.Equal(1020*time.Millisecond, slept)
+}
+
+func TestMacroPlaysInBackground(t *testing.T) {
+	assert := assert.New(t)
+
+	keyA, keyC := keyboard.KeyCode("KeyA"), keyboard.KeyCode("KeyC")
+	g13cfg := loadTestConfig(t, `{
+		"mapping": {"keys": {"G1": "KeyC"}, "macros": {"G2": "slow"}},
+		"macros": {"slow": [
+			{"key": "KeyA", "down": true},
+			{"key": "KeyA", "down": false, "delay": "10s"}
+		]}
+	}`)
+
+	tk := newTestKeyboard(t)
+	tk.newEvent()
+	sleeping := make(chan struct{})
+	macros := newMacroPlayer(io.Discard, func(ctx context.Context, d time.Duration) bool {
+		close(sleeping)
+		<-ctx.Done()
+		return false
+	})
+
+	handleMacros(device.G2.Uint64(), 0, g13cfg, tk, macros)
+	<-sleeping
+	// the macro is waiting, and other keys still work
+	handleKeyboard(device.G1.Uint64(), g13cfg, tk)
+	// pressing the key again stops the macro, which releases its keys
+	handleMacros(device.G2.Uint64(), 0, g13cfg, tk, macros)
+	macros.wait()
+	assert.Equal([][]testEvent{{
+		{action: "down", code: keyA},
+		{action: "down", code: keyC},
+		{action: "up", code: keyA},
+	}}, tk.events)
+
+	// stopping when nothing plays does nothing
+	macros.stop()
+	var nilPlayer *macroPlayer
+	nilPlayer.stop()
+}
+
+func TestRepeatMacro(t *testing.T) {
+	assert := assert.New(t)
+
+	keyA := keyboard.KeyCode("KeyA")
+	g13cfg := loadTestConfig(t, `{
+		"mapping": {"macros": {"G2": "tap"}, "repeat_macros": ["G2"]},
+		"macros": {"tap": [
+			{"key": "KeyA", "down": true},
+			{"key": "KeyA", "down": false, "delay": "10ms"}
+		]}
+	}`)
+
+	tk := newTestKeyboard(t)
+	tk.newEvent()
+	// the third time around, wait for the key to be released while KeyA is
+	// down
+	sleeps := 0
+	waiting := make(chan struct{})
+	macros := newMacroPlayer(io.Discard, func(ctx context.Context, d time.Duration) bool {
+		sleeps++
+		if sleeps < 3 {
+			return true
+		}
+		close(waiting)
+		<-ctx.Done()
+		return false
+	})
+
+	handleMacros(device.G2.Uint64(), 0, g13cfg, tk, macros)
+	<-waiting
+	// held: nothing changes
+	handleMacros(device.G2.Uint64(), device.G2.Uint64(), g13cfg, tk, macros)
+	handleMacros(0, device.G2.Uint64(), g13cfg, tk, macros)
+	macros.wait()
+	assert.Equal([][]testEvent{{
+		{action: "down", code: keyA},
+		{action: "up", code: keyA},
+		{action: "down", code: keyA},
+		{action: "up", code: keyA},
+		{action: "down", code: keyA},
+		// released when the G13 key is
+		{action: "up", code: keyA},
+	}}, tk.events)
+	assert.Equal(3, sleeps)
+
+	// the simulator plays it once
+	tk.events = [][]testEvent{{}}
+	once := newMacroPlayer(io.Discard, func(context.Context, time.Duration) bool { return true })
+	once.playOnce = true
+	handleMacros(device.G2.Uint64(), 0, g13cfg, tk, once)
+	once.wait()
+	assert.Equal([][]testEvent{{
+		{action: "down", code: keyA},
+		{action: "up", code: keyA},
+	}}, tk.events)
+}
@@ -3,14 +3,31 @@ package main
 import (
 	"errors"
 	"fmt"
+	"io"
+	"net/http"
 	"os"
 	"os/signal"
+	"path/filepath"
+	"slices"
 	"time"
 
+	"github.com/achilleas-k/gg13/internal/applet"
+	"github.com/achilleas-k/gg13/internal/audio"
+	"github.com/achilleas-k/gg13/internal/backoff"
 	"github.com/achilleas-k/gg13/internal/config"
+	"github.com/achilleas-k/gg13/internal/control"
+	"github.com/achilleas-k/gg13/internal/dbus"
+	"github.com/achilleas-k/gg13/internal/desktop"
 	"github.com/achilleas-k/gg13/internal/device"
+	"github.com/achilleas-k/gg13/internal/i18n"
 	"github.com/achilleas-k/gg13/internal/joystick"
 	"github.com/achilleas-k/gg13/internal/keyboard"
+	"github.com/achilleas-k/gg13/internal/mouse"
+	"github.com/achilleas-k/gg13/internal/output"
+	"github.com/achilleas-k/gg13/internal/pipeline"
+	"github.com/achilleas-k/gg13/internal/state"
+	"github.com/achilleas-k/gg13/internal/stats"
+	"github.com/achilleas-k/gg13/internal/update"
 	"github.com/spf13/cobra"
 )
 
@@ -19,11 +36,48 @@ func mkcmd() *cobra.Command {
 		Use:                   "g13 <config>",
 		Args:                  cobra.ExactArgs(1),
 		Long:                  "Userspace Linux driver for the Logitech G13 gameboard",
-		Version:               "devel",
+		Version:               buildVersion(),
 		RunE:                  g13,
+		ValidArgsFunction:     completeConfigFile,
 		DisableFlagsInUseLine: true, // don't put [flags] at the end of the Use line
 	}
 
+	rootCmd.PersistentFlags().String("socket", control.DefaultSocketPath(), "path to the control socket")
+	rootCmd.PersistentFlags().String("state-file", state.DefaultPath(), "file recording the backlight and LCD state for restoring after an unclean shutdown (empty to disable)")
+	rootCmd.PersistentFlags().Bool("json-errors", false, "write the error that the command fails with to stderr as a line of JSON with its kind, exit code, and remediation hint, for scripts")
+	rootCmd.PersistentFlags().String("token-file", "", "file containing the token for control over TCP (default: $"+controlTokenEnv+")")
+	rootCmd.Flags().String("output-token-file", "", "file containing the token that the network output authenticates to the receiver with (default: $"+outputTokenEnv+"); the output is sent unencrypted")
+	rootCmd.Flags().String("listen-tcp", "", "also accept control commands on this TCP address, authenticated with the token; nothing is encrypted, so only listen on a trusted network")
+	rootCmd.Flags().StringSlice("tcp-commands", defaultTCPCommands, "control commands accepted over TCP")
+	rootCmd.Flags().Duration("read-timeout", config.DefaultReadTimeout, "timeout for each read from the device (overrides config)")
+	rootCmd.Flags().Int("error-threshold", config.DefaultErrorThreshold, "consecutive read errors before reinitialising the device (overrides config)")
+	rootCmd.Flags().Duration("retry-delay", config.DefaultRetryDelay, "time to wait after a read error before reading again, doubling with each consecutive error (overrides config)")
+	rootCmd.PersistentFlags().String("stats-file", stats.DefaultPath(), "file accumulating the key statistics")
+	rootCmd.Flags().String("calibration-file", config.DefaultStickCalibrationPath(), "file storing the measured stick calibration (empty to disable)")
+	rootCmd.Flags().Bool("record-stats", false, "record how often and how long each key is pressed in the statistics file (see 'gg13 stats')")
+	rootCmd.Flags().String("debug-listen", "", "serve pprof profiles and runtime statistics over HTTP on this address, e.g. localhost:6060 (exposes the process memory: don't make it reachable from other machines)")
+	rootCmd.Flags().Bool("gaming-mode", false, "minimise input latency: pin the input loop to an OS thread with realtime priority, if permitted, and don't print errors from handling input")
+	rootCmd.Flags().Bool("trace", false, "log every key press and release by name and every event sent to the virtual keyboard and joystick, up to 100 lines a second, for debugging bindings")
+	rootCmd.Flags().Bool("check-update", false, "check once at startup whether a newer release is available and say so, without installing anything; failing to check, for example when offline, is only a note")
+	rootCmd.Flags().Bool("sandbox", false, "restrict the daemon with seccomp and landlock after startup, limiting file access to the devices, the config directory, and the runtime files (file access is only limited in the usbfs build without cgo)")
+
+	rootCmd.AddCommand(mkLCDCmd())
+	rootCmd.AddCommand(mkCtlCmd())
+	rootCmd.AddCommand(mkDebugCmd())
+	rootCmd.AddCommand(mkDoctorCmd())
+	rootCmd.AddCommand(mkMigrateCmd())
+	rootCmd.AddCommand(mkLintCmd())
+	rootCmd.AddCommand(mkManCmd())
+	rootCmd.AddCommand(mkReceiveCmd())
+	rootCmd.AddCommand(mkSimulateCmd())
+	rootCmd.AddCommand(mkSelftestCmd())
+	rootCmd.AddCommand(mkRestoreCmd())
+	rootCmd.AddCommand(mkStatsCmd())
+	rootCmd.AddCommand(mkProfileCmd())
+	rootCmd.AddCommand(mkMacroCmd())
+	rootCmd.AddCommand(mkStatusCmd())
+	rootCmd.AddCommand(mkReportCmd())
+
 	return &rootCmd
 }
 
@@ -33,7 +87,7 @@ func setCleanupHandler(cleanup func()) {
 	go func() {
 		for sig := range signalChan {
 			if sig == os.Interrupt {
-				fmt.Println("Stopping...")
+				fmt.Println(i18n.T("Stopping..."))
 				cleanup()
 				break
 			}
@@ -42,42 +96,91 @@ func setCleanupHandler(cleanup func()) {
 	}()
 }
 
-func initialise(g13cfg *config.G13Config) (device.Device, keyboard.Keyboard, joystick.Joystick, error) {
-	dev, err := device.New()
+func initialise(g13cfg *config.G13Config, screen *screenLock, messages *lcdMessages, statePath, outputName, outputToken string) (device.Device, keyboard.Keyboard, joystick.Joystick, mouse.Mouse, error) {
+	devOpts := device.DefaultOptions()
+	devOpts.Reconnect = g13cfg.GetReconnectBackoff()
+	dev, err := device.NewWithOptions(devOpts)
 	if err != nil {
-		return nil, nil, nil, fmt.Errorf("device initialisation failed: %w", err)
+		return nil, nil, nil, nil, i18n.Errorf("device initialisation failed: %w", err)
 	}
+	// messages and the blank LCD of the screen lock aren't recorded as the
+	// state of the LCD, and messages aren't shown while the screen is locked
+	dev = newStatefulDevice(messages.wrap(screen.wrap(dev)), statePath)
 	setCleanupHandler(dev.Close)
 
-	vkb, err := keyboard.New("g13-vkb")
+	sink, err := openOutput(g13cfg, outputName, outputToken)
 	if err != nil {
-		return nil, nil, nil, fmt.Errorf("virtual keyboard initialisation failed: %w", err)
+		return nil, nil, nil, nil, err
 	}
 
-	vjs, err := joystick.New("g13-vjs")
-	if err != nil {
-		return nil, nil, nil, fmt.Errorf("virtual joystick initialisation failed: %w", err)
+	if err := applyConfig(dev, g13cfg); err != nil {
+		return nil, nil, nil, nil, err
 	}
+	return dev, sink.Keyboard, sink.Joystick, sink.Mouse, nil
+}
 
-	backlight := g13cfg.GetBacklight()
-	if err := dev.SetBacklightColour(backlight[0], backlight[1], backlight[2]); err != nil {
-		return nil, nil, nil, err
+// openOutput opens the named output backend, with a joystick if the stick is
+// in joystick mode or scripts press joystick buttons, and a mouse if keys are
+// bound to scrolling or moving the pointer.
+func openOutput(g13cfg *config.G13Config, name, token string) (output.Sink, error) {
+	opts := output.Options{
+		KeyboardName: "g13-vkb",
+		JoystickName: "g13-vjs",
+		MouseName:    "g13-vms",
+		Mouse:        mouseOptions(g13cfg),
+		Address:      g13cfg.GetNetworkOutputAddress(),
+		Token:        token,
+	}
+	// the virtual joystick only has the buttons that scripts press: no G13
+	// keys can be mapped to joystick buttons
+	if g13cfg.GetStickMode() == config.StickModeJoystick || g13cfg.GetJoystickButtons() > 0 {
+		jsOpts := joystick.DefaultOptions()
+		jsOpts.Buttons = g13cfg.GetJoystickButtons()
+		jsOpts.ForceFeedback = g13cfg.GetForceFeedback()
+		jsOpts.Axes = g13cfg.GetJoystickAxes()
+		opts.Joystick = &jsOpts
 	}
+	return output.Open(name, opts)
+}
 
-	if g13cfg.GetImagePath() != "" {
-		lcdImg, err := g13cfg.GetImage()
-		if err != nil {
-			return nil, nil, nil, err
-		}
-		if err := dev.SetLCD(lcdImg); err != nil {
-			return nil, nil, nil, err
-		}
+// wrapOutput returns the keyboard and joystick with the events traced and the
+// stick smoothed, if enabled. It's called after rumble is connected to the
+// joystick itself.
+func wrapOutput(vkb keyboard.Keyboard, vjs joystick.Joystick, g13cfg *config.G13Config, trace *tracer) (keyboard.Keyboard, joystick.Joystick) {
+	vkb, vjs = trace.wrap(vkb, vjs)
+	// the smoothed stick moves on its own: trace what it sends
+	if smoothing := g13cfg.GetStickSmoothing(); vjs != nil && smoothing > 0 {
+		vjs = joystick.NewSmoothed(vjs, smoothing)
 	}
-	return dev, vkb, vjs, nil
+	return vkb, vjs
 }
 
-// TODO: maybe make configurable
-const errorCounterThreshold = 3
+// remediationHint returns a suggestion for fixing the cause of err, or an
+// empty string if there's nothing useful to suggest. It runs the relevant
+// [doctor] checks to make the suggestion specific to the system.
+func remediationHint(err error) string {
+	var checks []checkResult
+	var fallback string
+	switch {
+	case errors.Is(err, device.ErrPermission):
+		checks = []checkResult{checkUdevRule(udevRuleDirs), checkUSBDevice(sysUSBDevices, devUSB)}
+		fallback = i18n.T("install the udev rule from the udev/ directory of the project and replug the device")
+	case errors.Is(err, keyboard.ErrUinputUnavailable), errors.Is(err, joystick.ErrUinputUnavailable), errors.Is(err, mouse.ErrUinputUnavailable):
+		checks = []checkResult{checkUinput(uinputPath)}
+		fallback = i18n.T("make sure the uinput kernel module is loaded (modprobe uinput) and /dev/uinput is writable by your user")
+	case errors.Is(err, device.ErrDeviceLocked):
+		return i18n.T("stop the gg13 instance holding the device (see the PID above) before starting a new one")
+	default:
+		return ""
+	}
+
+	for _, res := range checks {
+		if res.err != nil && res.hint != "" {
+			return res.hint
+		}
+	}
+	return fallback
+}
 
 func handleInput(input uint64, g13cfg *config.G13Config, vkb keyboard.Keyboard, vjs joystick.Joystick) {
 	handleKeyboard(input, g13cfg, vkb)
@@ -85,23 +188,28 @@ func handleInput(input uint64, g13cfg *config.G13Config, vkb keyboard.Keyboard,
 }
 
 func handleKeyboard(input uint64, g13cfg *config.G13Config, vkb keyboard.Keyboard) {
-	for kbkey, isDown := range g13cfg.GetKeyStates(input) {
+	g13cfg.EachKeyState(input, func(kbkey int, isDown bool) {
 		if isDown {
 			if err := vkb.KeyDown(kbkey); err != nil {
-				fmt.Fprintf(os.Stderr, "keyboard error pressing %d: %s\n", kbkey, err)
+				fmt.Fprintf(hotPathErrors, "keyboard error pressing %d: %s\n", kbkey, err)
 			}
 		} else if err := vkb.KeyUp(kbkey); err != nil {
-			fmt.Fprintf(os.Stderr, "keyboard error releasing %d: %s\n", kbkey, err)
+			fmt.Fprintf(hotPathErrors, "keyboard error releasing %d: %s\n", kbkey, err)
 		}
-	}
+	})
 }
 
 func handleJoystick(input uint64, g13cfg *config.G13Config, vjs joystick.Joystick) {
+	if vjs == nil {
+		// the stick was switched to joystick mode by reloading the config:
+		// the joystick is only created on (re)initialisation
+		return
+	}
 	stickPos := g13cfg.GetStickPosition(input)
 	if stickPos != nil {
 		xOutput, yOutput := stickPos.UinputPosition()
 		if err := vjs.StickPosition(xOutput, yOutput); err != nil {
-			fmt.Fprintf(os.Stderr, "joystick error setting position %f %f\n", xOutput, yOutput)
+			fmt.Fprintf(hotPathErrors, "joystick error setting position %f %f\n", xOutput, yOutput)
 		}
 	}
 }
@@ -115,67 +223,624 @@ func g13(cmd *cobra.Command, args []string) error {
 	configPath := args[0]
 	g13cfg, err := config.NewFromFile(configPath)
 	if err != nil {
+		return configError{err}
+	}
+	writeConfigWarnings(os.Stderr, g13cfg)
+	if err := applyInputFlags(cmd, g13cfg); err != nil {
 		return err
 	}
 
-	dev, vkb, vjs, err := initialise(g13cfg)
+	statePath, err := cmd.Flags().GetString("state-file")
 	if err != nil {
 		return err
 	}
+	var prevState *state.State
+	if statePath != "" {
+		// load it before initialising, which records the new state
+		prevState, err = state.Load(statePath)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, i18n.Sprintf("not restoring device state: %s", err))
+		}
+	}
+
+	outputToken, err := readOutputToken(cmd)
+	if err != nil {
+		return err
+	}
+	if g13cfg.GetOutput() == "network" && outputToken == "" {
+		return i18n.Errorf("a token is required for the network output: set %s or use --output-token-file", outputTokenEnv)
+	}
+
+	outputs := newOutputSwitcher(g13cfg.GetOutput())
+	messages := newLCDMessages(g13cfg.GetLCDMessageDuration())
+	if messages == nil {
+		// still holds pages like the numpad's
+		messages = &lcdMessages{}
+	}
+	var screen *screenLock
+	if g13cfg.GetMuteOnScreenLock() {
+		screen = &screenLock{}
+	}
+	dev, vkb, vjs, vms, err := initialise(g13cfg, screen, messages, statePath, outputs.get(), outputToken)
+	if err != nil {
+		return err
+	}
+
+	// the measured stick calibration is kept across config reloads
+	calibrationPath, err := cmd.Flags().GetString("calibration-file")
+	if err != nil {
+		return err
+	}
+	var measuredCalibration *config.StickCalibration
+	if calibrationPath != "" {
+		measuredCalibration, err = config.LoadStickCalibration(calibrationPath)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, i18n.Sprintf("not using the measured stick calibration: %s", err))
+		}
+		g13cfg.SetMeasuredStickCalibration(measuredCalibration)
+	}
+	if g13cfg.GetStickAutoCalibrate() {
+		fmt.Println(i18n.T("Calibrating stick: don't touch it"))
+		calibration, err := calibrateStick(dev)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, i18n.Sprintf("stick calibration failed: %s", err))
+		} else {
+			measuredCalibration = &calibration
+			storeCalibration(calibration, g13cfg, calibrationPath)
+		}
+	}
+
+	if prevState != nil {
+		fmt.Println(i18n.T("Restoring device state from unclean shutdown"))
+		if err := restoreState(dev, prevState); err != nil {
+			fmt.Fprintln(os.Stderr, i18n.Sprintf("failed restoring device state: %s", err))
+		}
+	}
 
 	defer func() {
 		dev.Close()
-		if err := vkb.Close(); err != nil {
-			fmt.Fprintf(os.Stderr, "error closing keyboard during shutdown: %s", err)
+		if err := (output.Sink{Keyboard: vkb, Joystick: vjs, Mouse: vms}).Close(); err != nil {
+			fmt.Fprintln(os.Stderr, i18n.Sprintf("error closing output during shutdown: %s", err))
 		}
 	}()
 
-	fmt.Println("Ready")
-	var consecutiveReadErrors uint8 = 0
+	traceEnabled, err := cmd.Flags().GetBool("trace")
+	if err != nil {
+		return err
+	}
+	var trace *tracer
+	if traceEnabled {
+		trace = newTracer(os.Stderr)
+	}
+
+	devRef := &deviceRef{}
+	devRef.set(dev)
+	handleRumble(vjs, g13cfg, devRef)
+	vkb, vjs = wrapOutput(vkb, vjs, g13cfg, trace)
+	latency := &latencyStats{}
+
+	socketPath, err := cmd.Flags().GetString("socket")
+	if err != nil {
+		return err
+	}
+	ctlServer, err := startControlServer(socketPath, devRef, latency)
+	if err != nil {
+		// the control socket is optional: warn and keep going
+		fmt.Fprintln(os.Stderr, i18n.Sprintf("control socket disabled: %s", err))
+	}
+	if err := listenTCP(cmd, ctlServer); err != nil {
+		fmt.Fprintln(os.Stderr, i18n.Sprintf("remote control disabled: %s", err))
+	}
+	defer func() {
+		if err := ctlServer.Close(); err != nil {
+			fmt.Fprintln(os.Stderr, i18n.Sprintf("error closing control socket during shutdown: %s", err))
+		}
+	}()
+
+	debugAddr, err := cmd.Flags().GetString("debug-listen")
+	if err != nil {
+		return err
+	}
+	if debugAddr != "" {
+		debugServer, err := startDebugServer(debugAddr, latency)
+		if err != nil {
+			// diagnostics are optional: warn and keep going
+			fmt.Fprintln(os.Stderr, i18n.Sprintf("debug server disabled: %s", err))
+		} else {
+			defer func() {
+				if err := debugServer.Close(); err != nil {
+					fmt.Fprintln(os.Stderr, i18n.Sprintf("error closing debug server during shutdown: %s", err))
+				}
+			}()
+		}
+	}
+
+	mqttClient, err := startMQTT(g13cfg, devRef)
+	if err != nil {
+		// the integration is optional: warn and keep going
+		fmt.Fprintln(os.Stderr, i18n.Sprintf("MQTT disabled: %s", err))
+	}
+	defer mqttClient.Close()
+
+	sandboxed, err := cmd.Flags().GetBool("sandbox")
+	if err != nil {
+		return err
+	}
+	lcdApplet, appletInterval, err := lcdAppletOf(g13cfg)
+	if err != nil {
+		return err
+	}
+	if sandboxed && g13cfg.HasTemplateExec() {
+		// every render of the page would fail
+		fmt.Fprintln(os.Stderr, i18n.T("template page disabled: commands can't run in the sandbox"))
+		lcdApplet = nil
+	}
+	if closer, ok := lcdApplet.(io.Closer); ok {
+		defer closer.Close()
+	}
+	timer, _ := lcdApplet.(*applet.Timer)
+	if timer != nil {
+		colour, _ := g13cfg.GetTimerColour()
+		timer.OnDone(func() {
+			fmt.Println(i18n.T("Timer done"))
+			dev := devRef.get()
+			if dev == nil {
+				return
+			}
+			if _, err := dev.OverrideBacklightColour(colour[0], colour[1], colour[2], config.TimerFlashDuration); err != nil {
+				fmt.Fprintln(os.Stderr, i18n.Sprintf("error flashing backlight: %s", err))
+			}
+		})
+	}
+	live := &liveState{}
+	profiles := newProfileSwitcher()
+	edits := newBindingEditor(configPath)
+	status := newDaemonStatus(time.Now())
+	checkUpdate, err := cmd.Flags().GetBool("check-update")
+	if err != nil {
+		return err
+	}
+	var updates *updateChecker
+	if checkUpdate {
+		updates = &updateChecker{}
+		// in the background, so a slow or missing network doesn't delay
+		// startup
+		go updates.check(&http.Client{}, update.DefaultURL, buildVersion(), os.Stderr)
+	}
+	if ctlServer != nil {
+		handleOutput(ctlServer, outputs)
+		handleState(ctlServer, live, outputs)
+		handleProfile(ctlServer, profiles)
+		handleBind(ctlServer, edits)
+		handleStatus(ctlServer, status, live)
+		handleVersion(ctlServer, updates)
+	}
+
+	counters, _ := lcdApplet.(*applet.Counters)
+	if counters != nil && ctlServer != nil {
+		handleCounters(ctlServer, counters)
+	}
+	lcdContent := newProfileLCD(devRef, g13cfg, lcdApplet, appletInterval, sandboxed)
+	defer lcdContent.stop()
+
+	stopStats := make(chan struct{})
+	defer close(stopStats)
+	statsRecorder, statsPath, err := startStats(cmd, stopStats)
+	if err != nil {
+		return err
+	}
+	if statsRecorder != nil {
+		defer func() {
+			if err := statsRecorder.Save(statsPath); err != nil {
+				fmt.Fprintln(os.Stderr, i18n.Sprintf("error saving key statistics during shutdown: %s", err))
+			}
+		}()
+	}
+
+	// the lock state of the session comes from logind on the system bus,
+	// which is connected to before the sandbox is applied
+	var screenLocks <-chan bool
+	if screen != nil {
+		if watcher, err := dbus.WatchScreenLock(); err == nil {
+			screenLocks = watcher.Changes()
+			defer watcher.Close()
+		} else {
+			fmt.Fprintln(os.Stderr, i18n.Sprintf("muting on screen lock disabled: %s", err))
+		}
+	}
+
+	gamingMode, err := cmd.Flags().GetBool("gaming-mode")
+	if err != nil {
+		return err
+	}
+	if gamingMode {
+		if err := enterGamingMode(); err != nil {
+			// the thread is still pinned: warn and keep going
+			fmt.Fprintln(os.Stderr, i18n.Sprintf("gaming mode: %s", err))
+		}
+	}
+
+	if sandboxed {
+		// the qemu output relinks the event devices when they're recreated
+		linkPath := filepath.Join(output.LinkDir(), "g13-vkb-event")
+		// a text file shown on the LCD is read until the daemon exits
+		var readFiles []string
+		if textFile := g13cfg.GetLCDTextFile(); textFile != "-" {
+			readFiles = append(readFiles, textFile)
+		}
+		// and the ones of profiles while they're active
+		for _, profile := range g13cfg.GetProfiles() {
+			if textFile := g13cfg.WithProfileLCD(profile.Name).GetLCDTextFile(); textFile != "" && !slices.Contains(readFiles, textFile) {
+				readFiles = append(readFiles, textFile)
+			}
+		}
+		if err := applySandbox(configPath, readFiles, socketPath, statePath, statsPath, linkPath, calibrationPath); err != nil {
+			return err
+		}
+	}
+
+	// the runner is started even without hooks, which a reloaded config may
+	// add, but the sandbox doesn't allow running commands
+	var hooks *hookRunner
+	var windows *windowManager
+	var audioSw *audioSwitcher
+	if !sandboxed {
+		hooks = startHookRunner(os.Stderr)
+		defer hooks.close()
+		windows = startWindowManager(os.Stderr)
+		defer windows.close()
+		audioSw = startAudioSwitcher(os.Stderr, messages, audio.New())
+		defer audioSw.close()
+	} else {
+		if g13cfg.HasProfileHooks() {
+			fmt.Fprintln(os.Stderr, i18n.T("profile hooks disabled: commands can't run in the sandbox"))
+		}
+		if g13cfg.HasWindowBindings() {
+			fmt.Fprintln(os.Stderr, i18n.T("window management disabled: commands can't run in the sandbox"))
+		}
+		if g13cfg.HasAudioBindings() {
+			fmt.Fprintln(os.Stderr, i18n.T("audio device switching disabled: commands can't run in the sandbox"))
+		}
+	}
+
+	// the portal is called over the session bus, which the sandbox allows
+	var screenshots *screenshotter
+	if client, err := dbus.NewPortal(); err == nil {
+		screenshots = startScreenshotter(os.Stderr, messages, client)
+		defer screenshots.close()
+	} else if g13cfg.HasScreenshotActions() {
+		fmt.Fprintln(os.Stderr, i18n.Sprintf("screenshots disabled: %s", err))
+	}
+
+	gestureDetector := newGestureDetector(g13cfg)
+	chords := &chordDecoder{}
+	scroll := &scroller{}
+	// stopped before the keyboard is closed on shutdown
+	macros := newMacroPlayer(os.Stderr, sleepContext)
+	defer macros.stop()
+	actions := &actionDispatcher{
+		load: func() (*config.G13Config, error) {
+			// the bindings changed over the control socket stay
+			newCfg, err := config.NewFromFileWithBindings(configPath, edits.unsaved)
+			if err != nil {
+				return nil, err
+			}
+			newCfg.SetMeasuredStickCalibration(measuredCalibration)
+			return newCfg, applyInputFlags(cmd, newCfg)
+		},
+		timer:      timer,
+		counters:   counters,
+		messages:   messages,
+		hooks:      hooks,
+		calibrator: &stickCalibrator{},
+		audio:      audioSw,
+		screen:     screen,
+
+		screenshots: screenshots,
+	}
+
+	retry := backoff.New(g13cfg.GetRetryBackoff())
+
+	// The default stages of the input pipeline. The input is decoded by
+	// ReadInput before entering it.
+	disabledStage := pipeline.StageFunc(func(ev pipeline.Event, next pipeline.Next) {
+		outputCfg := actions.outputConfig(g13cfg)
+		ev.Input = outputCfg.MaskDisabledKeys(ev.Input)
+		ev.PrevInput = outputCfg.MaskDisabledKeys(ev.PrevInput)
+		next(ev)
+	})
+	keys := &keyFilter{}
+	keyFilterStage := pipeline.StageFunc(func(ev pipeline.Event, next pipeline.Next) {
+		ev.Input, ev.PrevInput = keys.filter(ev.Input, ev.Time, actions.outputConfig(g13cfg))
+		next(ev)
+	})
+	actionsStage := pipeline.StageFunc(func(ev pipeline.Event, next pipeline.Next) {
+		wasMuted := actions.muted()
+		prevOutputCfg := actions.outputConfig(g13cfg)
+		if newCfg := actions.handleActions(ev.Input, ev.PrevInput, g13cfg, dev); newCfg != g13cfg {
+			g13cfg = newCfg
+			gestureDetector = newGestureDetector(g13cfg)
+			retry = backoff.New(g13cfg.GetRetryBackoff())
+		}
+		if (actions.muted() && !wasMuted) || actions.outputConfig(g13cfg) != prevOutputCfg {
+			// don't leave keys of the previous bindings pressed
+			macros.stop()
+			releaseOutput(prevOutputCfg, vkb, vjs)
+			chords.reset()
+			scroll.reset()
+		}
+		updateLCD(lcdContent, g13cfg, actions.activeProfile())
+		warnUnmapped(ev.Input, ev.PrevInput, actions.outputConfig(g13cfg), os.Stderr)
+		next(ev)
+	})
+	calibrationStage := pipeline.Observer(func(ev pipeline.Event) {
+		if !actions.calibrator.add(ev.Input, ev.Time) {
+			return
+		}
+		calibration, err := actions.calibrator.result()
+		if err != nil {
+			fmt.Fprintln(os.Stderr, i18n.Sprintf("stick calibration failed: %s", err))
+			messages.show("Calibration failed:\n%s", err)
+			return
+		}
+		measuredCalibration = &calibration
+		storeCalibration(calibration, g13cfg, calibrationPath)
+		messages.show("Stick calibrated")
+	})
+	// scripts can't reload the config, so the actions they run don't
+	// replace it
+	runAction := func(action config.Action) error {
+		_, err := actions.dispatch(action, g13cfg, dev)
+		return err
+	}
+	outputStage := pipeline.StageFunc(func(ev pipeline.Event, next pipeline.Next) {
+		if !actions.muted() {
+			// before the keys, so the modifiers they hold apply to the chord
+			chords.handle(ev.Input, actions.outputConfig(g13cfg), vkb, hotPathErrors)
+			handleInput(ev.Input, actions.outputConfig(g13cfg), vkb, vjs)
+			handleMacros(ev.Input, ev.PrevInput, actions.outputConfig(g13cfg), vkb, macros)
+			handleScripts(ev.Input, ev.PrevInput, actions.outputConfig(g13cfg), vkb, vjs, runAction, time.Sleep)
+			scroll.handle(ev.Input, ev.Time, actions.outputConfig(g13cfg), vms, hotPathErrors)
+			handleWarps(ev.Input, ev.PrevInput, actions.outputConfig(g13cfg), vms, hotPathErrors)
+			handleWindows(ev.Input, ev.PrevInput, actions.outputConfig(g13cfg), func(op desktop.Op) {
+				windows.run(op, desktopName(g13cfg))
+			})
+			handleAudio(ev.Input, ev.PrevInput, actions.outputConfig(g13cfg), audioSw.run)
+			handleFlashes(ev.Input, ev.PrevInput, g13cfg, dev)
+			if gestureDetector != nil {
+				handleGestures(ev.Input, ev.Time, g13cfg, gestureDetector, vkb)
+			}
+		}
+		next(ev)
+	})
+	reportStage := pipeline.Observer(func(ev pipeline.Event) {
+		if mqttClient != nil {
+			publishKeys(ev.Input, ev.PrevInput, mqttClient)
+		}
+		if statsRecorder != nil {
+			statsRecorder.Record(ev.Input, ev.PrevInput, ev.Time)
+		}
+	})
+	stateStage := pipeline.Observer(func(ev pipeline.Event) {
+		live.update(ev.Input, actions.outputConfig(g13cfg), actions.muted(), actions.passthrough, actions.activeProfile())
+	})
+	stages := []pipeline.Stage{disabledStage, keyFilterStage, actionsStage, calibrationStage, outputStage, reportStage, stateStage}
+	if trace != nil {
+		// disabled keys are traced too
+		traceStage := pipeline.Observer(func(ev pipeline.Event) {
+			trace.input(ev.Input, ev.PrevInput)
+		})
+		stages = append([]pipeline.Stage{traceStage}, stages...)
+	}
+	inputPipeline := pipeline.New(stages...)
+
+	fmt.Println(i18n.T("Ready"))
+	consecutiveReadErrors := 0
+	var prevInput uint64
 	for {
-		input, err := dev.ReadInput()
-		if errors.Is(err, device.ErrReadTimeout) {
+		select {
+		case locked, ok := <-screenLocks:
+			if !ok {
+				// the watcher stopped: don't stay muted without it
+				screenLocks = nil
+			}
+			wasMuted := actions.muted()
+			prevOutputCfg := actions.outputConfig(g13cfg)
+			if screen.set(locked && ok) {
+				actions.screenLockChanged(g13cfg)
+			}
+			if (actions.muted() && !wasMuted) || actions.outputConfig(g13cfg) != prevOutputCfg {
+				// don't leave keys of the previous bindings pressed
+				macros.stop()
+				releaseOutput(prevOutputCfg, vkb, vjs)
+				chords.reset()
+				scroll.reset()
+			}
+			updateLCD(lcdContent, g13cfg, actions.activeProfile())
+		case req := <-outputs.requests:
+			sink, err := openOutput(g13cfg, req.name, outputToken)
+			if err == nil {
+				// don't leave keys pressed on the previous output
+				macros.stop()
+				releaseOutput(actions.outputConfig(g13cfg), vkb, vjs)
+				if err := (output.Sink{Keyboard: vkb, Joystick: vjs, Mouse: vms}).Close(); err != nil {
+					fmt.Fprintln(os.Stderr, i18n.Sprintf("error closing output %s: %s", outputs.get(), err))
+				}
+				vkb, vjs, vms = sink.Keyboard, sink.Joystick, sink.Mouse
+				scroll.reset()
+				handleRumble(vjs, g13cfg, devRef)
+				vkb, vjs = wrapOutput(vkb, vjs, g13cfg, trace)
+				outputs.set(req.name)
+				fmt.Println(i18n.Sprintf("Switched output to %s", req.name))
+				messages.show("Output: %s", req.name)
+			}
+			req.result <- err
+		case req := <-profiles.requests:
+			prevOutputCfg := actions.outputConfig(g13cfg)
+			req.handle(actions, g13cfg)
+			if actions.outputConfig(g13cfg) != prevOutputCfg {
+				// don't leave keys of the previous bindings pressed
+				macros.stop()
+				releaseOutput(prevOutputCfg, vkb, vjs)
+			}
+			updateLCD(lcdContent, g13cfg, actions.activeProfile())
+		case req := <-edits.requests:
+			newCfg, err := req.handle(edits, actions.load)
+			if err == nil {
+				// don't leave keys of the previous bindings pressed
+				macros.stop()
+				releaseOutput(actions.outputConfig(g13cfg), vkb, vjs)
+				chords.reset()
+				scroll.reset()
+				g13cfg = newCfg
+				gestureDetector = newGestureDetector(g13cfg)
+				retry = backoff.New(g13cfg.GetRetryBackoff())
+				fmt.Println(i18n.Sprintf("Binding changed: %s", req.binding))
+				messages.show("Binding changed:\n%s", req.binding)
+			}
+			req.result <- err
+		default:
+		}
+
+		input, readTime, err := dev.ReadInput()
+		if errors.Is(err, device.ErrReadTimeout) && (keys.pending() || scroll.pending()) {
+			// held keys register after the slow keys delay, and held
+			// scroll keys keep scrolling, without a new report
+			inputPipeline.Handle(pipeline.Event{Input: prevInput, PrevInput: prevInput, Time: time.Now()})
+			continue
+		}
+		if errors.Is(err, device.ErrReadTimeout) || errors.Is(err, device.ErrNotInputReport) {
 			continue
 		}
 		if err != nil {
-			fmt.Fprintf(os.Stderr, "e: %s (%d)\n", err, consecutiveReadErrors)
+			fmt.Fprintln(os.Stderr, i18n.Sprintf("e: %s (%d)", err, consecutiveReadErrors))
+			status.readError(err, time.Now())
+			if consecutiveReadErrors == 0 {
+				messages.show("Read error:\n%s", err)
+			}
 			consecutiveReadErrors++
+			errorThreshold := g13cfg.GetErrorThreshold()
+			if errors.Is(err, device.ErrDeviceGone) {
+				// retrying the read won't help: reinitialise right away
+				consecutiveReadErrors = errorThreshold
+			}
 
-			if consecutiveReadErrors >= errorCounterThreshold {
-				fmt.Println("Reinitialising device")
+			if consecutiveReadErrors >= errorThreshold {
+				fmt.Println(i18n.T("Reinitialising device"))
+				status.disconnected()
+				devRef.set(nil)
+				mqttClient.SetDeviceConnected(false)
 				dev.Close()
 				dev = nil
-				if err := vkb.Close(); err != nil {
-					fmt.Fprintf(os.Stderr, "error closing vkb: %s\n", err)
+				macros.stop()
+				if err := (output.Sink{Keyboard: vkb, Joystick: vjs, Mouse: vms}).Close(); err != nil {
+					fmt.Fprintln(os.Stderr, i18n.Sprintf("error closing output %s: %s", outputs.get(), err))
 				}
-				// After 3 consecutive read errors, try to reinitialise the device.
+				// After too many consecutive read errors, try to reinitialise the device.
 				// This is primarily meant to handle device disconnections.
-				dev, vkb, vjs, err = initialise(g13cfg)
+				dev, vkb, vjs, vms, err = initialise(g13cfg, screen, messages, statePath, outputs.get(), outputToken)
 				if err != nil {
 					return err
 				}
+				devRef.set(dev)
+				mqttClient.SetDeviceConnected(true)
 				consecutiveReadErrors = 0
-				fmt.Println("Device restored")
+				handleRumble(vjs, g13cfg, devRef)
+				vkb, vjs = wrapOutput(vkb, vjs, g13cfg, trace)
+				retry.Reset()
+				prevInput = 0
+				chords.reset()
+				scroll.reset()
+				if gestureDetector != nil {
+					gestureDetector.Reset()
+				}
+				if statsRecorder != nil {
+					statsRecorder.Reset()
+				}
+				if err := lcdContent.redraw(); err != nil {
+					fmt.Fprintln(os.Stderr, i18n.Sprintf("error showing the LCD content of the profile: %s", err))
+				}
+				status.reconnected()
+				fmt.Println(i18n.T("Device restored"))
+				messages.show("Device reconnected")
 				continue
 			}
 
-			// wait a bit before continuing to try to read
-			time.Sleep(500 * time.Millisecond)
+			// wait a bit before continuing to try to read, longer with each
+			// consecutive error
+			delay := retry.Next()
+			fmt.Fprintln(os.Stderr, i18n.Sprintf("retrying read in %s", delay.Round(time.Millisecond)))
+			time.Sleep(delay)
 			continue
 		}
 
 		// read successful - reset error counter
+		if retry.Retrying() {
+			fmt.Fprintln(os.Stderr, i18n.Sprintf("reading recovered after %d errors", consecutiveReadErrors))
+		}
 		consecutiveReadErrors = 0
+		retry.Reset()
 
-		handleInput(input, g13cfg, vkb, vjs)
+		inputPipeline.Handle(pipeline.Event{Input: input, PrevInput: prevInput, Time: readTime})
+		prevInput = input
+		latency.record(readTime)
 	}
 }
 
+// applyInputFlags overrides the input loop settings in the config with the
+// command line flags that were explicitly set.
+func applyInputFlags(cmd *cobra.Command, g13cfg *config.G13Config) error {
+	flags := cmd.Flags()
+	if flags.Changed("read-timeout") {
+		dt, err := flags.GetDuration("read-timeout")
+		if err != nil {
+			return err
+		}
+		if dt <= 0 {
+			return i18n.Errorf("--read-timeout must be positive")
+		}
+		g13cfg.SetReadTimeout(dt)
+	}
+	if flags.Changed("error-threshold") {
+		n, err := flags.GetInt("error-threshold")
+		if err != nil {
+			return err
+		}
+		if n <= 0 {
+			return i18n.Errorf("--error-threshold must be positive")
+		}
+		g13cfg.SetErrorThreshold(n)
+	}
+	if flags.Changed("retry-delay") {
+		dt, err := flags.GetDuration("retry-delay")
+		if err != nil {
+			return err
+		}
+		if dt <= 0 {
+			return i18n.Errorf("--retry-delay must be positive")
+		}
+		g13cfg.SetRetryDelay(dt)
+	}
+	return nil
+}
+
 func main() {
+	if err := i18n.SetLocale(i18n.Detect(os.Getenv)); err != nil {
+		fmt.Fprintln(os.Stderr, i18n.Sprintf("messages aren't translated: %s", err))
+	}
 	cmd := mkcmd()
+	cmd.SetErrPrefix(i18n.T("Error:"))
+	// after parsing the flags, before checking the arguments: errors parsing
+	// the command line itself are still printed by cobra
+	cobra.OnInitialize(func() {
+		if jsonErrors(cmd) {
+			cmd.SilenceErrors = true
+			cmd.SilenceUsage = true
+		}
+	})
 	if err := cmd.Execute(); err != nil {
-		// Don't print anything: Cobra will print error message with usage if
-		// necessary
-		os.Exit(1)
+		// Cobra has printed the error message with usage if necessary,
+		// unless it's written as JSON
+		os.Exit(writeError(os.Stderr, cmd, err))
 	}
 }
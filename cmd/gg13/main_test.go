@@ -8,6 +8,8 @@ import (
 
 	"github.com/achilleas-k/gg13/internal/config"
 	"github.com/achilleas-k/gg13/internal/device"
+	"github.com/achilleas-k/gg13/internal/joystick"
+	"github.com/achilleas-k/gg13/internal/keyboard"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -55,6 +57,10 @@ func (tk *TestKeyboard) KeyUp(k int) error {
 	return tk.insert("up", k)
 }
 
+func (tk *TestKeyboard) TypeChord(mods []int, key int) error {
+	return keyboard.TypeChordKeys(tk, mods, key)
+}
+
 func (tk *TestKeyboard) newEvent() {
 	tk.events = append(tk.events, []testEvent{})
 }
@@ -282,18 +288,37 @@ func (tj *TestJoystick) StickPosition(x, y float32) error {
 	return nil
 }
 
-// createConfigWithJoystick creates a temporary config file with joystick mode enabled
-func createConfigWithJoystick(t *testing.T) *config.G13Config {
+func (tj *TestJoystick) HatPosition(x, y int) error {
+	if tj == nil {
+		return fmt.Errorf("joystick not initialised")
+	}
+	return nil
+}
+
+// writeTestConfig writes the config data to a file in a temporary directory
+// and returns its path.
+func writeTestConfig(t *testing.T, data string) string {
+	t.Helper()
+	cfgPath := filepath.Join(t.TempDir(), "config.json")
+	require.NoError(t, os.WriteFile(cfgPath, []byte(data), 0o600))
+	return cfgPath
+}
+
+// loadTestConfig writes the config data to a file in a temporary directory
+// and loads it.
+func loadTestConfig(t *testing.T, data string) *config.G13Config {
 	t.Helper()
-	tmpDir := t.TempDir()
-	cfgPath := filepath.Join(tmpDir, "config.json")
-	cfgData := `{"mapping":{"stick":{"mode":"joystick"}}}`
-	require.NoError(t, os.WriteFile(cfgPath, []byte(cfgData), 0o600))
-	cfg, err := config.NewFromFile(cfgPath)
+	cfg, err := config.NewFromFile(writeTestConfig(t, data))
 	require.NoError(t, err)
 	return cfg
 }
 
+// createConfigWithJoystick creates a temporary config file with joystick mode enabled
+func createConfigWithJoystick(t *testing.T) *config.G13Config {
+	t.Helper()
+	return loadTestConfig(t, `{"mapping":{"stick":{"mode":"joystick"}}}`)
+}
+
 // encodeStickPosition encodes stick x,y coordinates into the input uint64
 func encodeStickPosition(x, y uint8) uint64 {
 	return (uint64(x) << 8) | (uint64(y) << 16)
@@ -331,10 +356,10 @@ func TestHandleJoystick(t *testing.T) {
 				encodeStickPosition(0, 255),   // max left/down
 			},
 			expectedStickEvents: []stickEvent{
-				{x: 1.0078740, y: 1.0078740}, // max right/down
-				{x: -1.0, y: -1.0},           // max left/up
-				{x: 1.0078740, y: -1.0},      // max right/up
-				{x: -1.0, y: 1.0078740},      // max left/down
+				{x: 1.0, y: 1.0},   // max right/down
+				{x: -1.0, y: -1.0}, // max left/up
+				{x: 1.0, y: -1.0},  // max right/up
+				{x: -1.0, y: 1.0},  // max left/down
 			},
 			useJoystickMode: true,
 		},
@@ -350,10 +375,10 @@ func TestHandleJoystick(t *testing.T) {
 			},
 			expectedStickEvents: []stickEvent{
 				{x: 0.0, y: 0.0},
-				{x: 0.5748032, y: 0.0},
-				{x: 0.5748032, y: 0.5748032},
-				{x: 0.0, y: 0.5748032},
-				{x: -0.6062992, y: 0.5748032},
+				{x: 0.5703125, y: 0.0},
+				{x: 0.5703125, y: 0.5703125},
+				{x: 0.0, y: 0.5703125},
+				{x: -0.6062992, y: 0.5703125},
 				{x: -0.6062992, y: 0.0},
 				{x: 0.0, y: 0.0},
 			},
@@ -418,7 +443,7 @@ func TestHandleInput(t *testing.T) {
 			},
 			expectedStickEvents: []stickEvent{
 				{x: 0.0, y: 0.0},
-				{x: 0.5748032, y: 0.0},
+				{x: 0.5703125, y: 0.0},
 				{x: 0.0, y: 0.0},
 			},
 			useJoystickMode: true,
@@ -452,8 +477,8 @@ func TestHandleInput(t *testing.T) {
 			expectedKeyEvents: [][]testEvent{{}, {}, {}},
 			expectedStickEvents: []stickEvent{
 				{x: 0.0, y: 0.0},
-				{x: 1.0078740, y: -1.0},
-				{x: -1.0, y: 1.0078740},
+				{x: 1.0, y: -1.0},
+				{x: -1.0, y: 1.0},
 			},
 			useJoystickMode: true,
 		},
@@ -493,8 +518,8 @@ func TestHandleInput(t *testing.T) {
 			},
 			expectedStickEvents: []stickEvent{
 				{x: -0.21259843, y: -0.21259843},
-				{x: 0.18110237, y: 0.18110237},
-				{x: 0.5748032, y: 0.5748032},
+				{x: 0.1796875, y: 0.1796875},
+				{x: 0.5703125, y: 0.5703125},
 				{x: 0.0, y: 0.0},
 			},
 			useJoystickMode: true,
@@ -535,8 +560,8 @@ func TestHandleInput(t *testing.T) {
 			},
 			expectedStickEvents: []stickEvent{
 				{x: 0.0, y: 0.0},
-				{x: 0.5748032, y: 0.5748032},
-				{x: 1.0078740, y: 1.0078740},
+				{x: 0.5703125, y: 0.5703125},
+				{x: 1.0, y: 1.0},
 				{x: 0.0, y: 0.0},
 			},
 			useJoystickMode: true,
@@ -608,3 +633,42 @@ func TestNoPanic(t *testing.T) {
 		})
 	}
 }
+
+func TestRemediationHint(t *testing.T) {
+	testCases := map[string]struct {
+		err         error
+		expContains string
+	}{
+		"usb-permission": {
+			err:         fmt.Errorf("device initialisation failed: %w", device.ErrPermission),
+			expContains: "udev",
+		},
+		"keyboard-uinput": {
+			err:         fmt.Errorf("virtual keyboard initialisation failed: %w", keyboard.ErrUinputUnavailable),
+			expContains: "uinput",
+		},
+		"joystick-uinput": {
+			err:         fmt.Errorf("virtual joystick initialisation failed: %w", joystick.ErrUinputUnavailable),
+			expContains: "uinput",
+		},
+		"device-locked": {
+			err:         fmt.Errorf("device initialisation failed: %w", device.ErrDeviceLocked),
+			expContains: "PID",
+		},
+		"other": {
+			err:         fmt.Errorf("something else"),
+			expContains: "",
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			hint := remediationHint(tc.err)
+			if tc.expContains == "" {
+				assert.Empty(t, hint)
+				return
+			}
+			assert.Contains(t, hint, tc.expContains)
+		})
+	}
+}
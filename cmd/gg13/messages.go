@@ -0,0 +1,194 @@
+package main
+
+import (
+	"fmt"
+	"image"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/achilleas-k/gg13/internal/device"
+	"github.com/achilleas-k/gg13/internal/i18n"
+	"github.com/achilleas-k/gg13/internal/lcd"
+)
+
+// lcdMessages shows driver events as transient messages on the LCD, for users
+// without a terminal, and the pages of features that take over the LCD for a
+// while, like the numpad. The content that the config, applets and the
+// control socket set on the LCD is held back while a message or page is
+// shown and restored when it's gone. Messages are shown over a page. A nil
+// *lcdMessages shows nothing.
+type lcdMessages struct {
+	// how long messages are shown; zero doesn't show them
+	duration time.Duration
+
+	mu sync.Mutex
+	// the device messages are shown on, nil while it's being reinitialised
+	dev device.Device
+	// sets the last content of the LCD again
+	last func() error
+	// the message shown; zero when there's none
+	shown int
+	timer *time.Timer
+	// the page held on the LCD; nil when there's none
+	page image.Image
+}
+
+// newLCDMessages returns an [lcdMessages] that shows each message for the
+// duration, or nil if the duration is zero.
+func newLCDMessages(duration time.Duration) *lcdMessages {
+	if duration == 0 {
+		return nil
+	}
+	return &lcdMessages{duration: duration}
+}
+
+// wrap returns the device with its LCD content held back while messages are
+// shown. Messages are shown on it from now on.
+func (m *lcdMessages) wrap(dev device.Device) device.Device {
+	if m == nil {
+		return dev
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.dev = dev
+	m.last = nil
+	return &messageDevice{Device: dev, messages: m}
+}
+
+// show displays the message until it expires or another message replaces it.
+func (m *lcdMessages) show(format string, args ...any) {
+	if m == nil {
+		return
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.dev == nil || m.duration == 0 {
+		return
+	}
+	if err := m.dev.SetLCD(lcd.TextPage(fmt.Sprintf(format, args...))); err != nil {
+		fmt.Fprintln(os.Stderr, i18n.Sprintf("error showing message on the LCD: %s", err))
+		return
+	}
+
+	if m.timer != nil {
+		m.timer.Stop()
+	}
+	m.shown++
+	shown := m.shown
+	m.timer = time.AfterFunc(m.duration, func() { m.expire(shown) })
+}
+
+// expire restores the content of the LCD if the message is still shown.
+func (m *lcdMessages) expire(shown int) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.timer == nil || m.shown != shown || m.dev == nil {
+		return
+	}
+	m.timer = nil
+	if err := m.restore(); err != nil {
+		fmt.Fprintln(os.Stderr, i18n.Sprintf("error restoring the LCD after a message: %s", err))
+	}
+}
+
+// restore shows the held page, or sets the last content of the LCD again if
+// there's none.
+func (m *lcdMessages) restore() error {
+	switch {
+	case m.page != nil:
+		return m.dev.SetLCD(m.page)
+	case m.last != nil:
+		return m.last()
+	default:
+		return m.dev.ResetLCD()
+	}
+}
+
+// hold shows the page on the LCD until it's released or another page
+// replaces it. A message shown at the time stays on top of it until it
+// expires.
+func (m *lcdMessages) hold(page image.Image) {
+	if m == nil {
+		return
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.page = page
+	if m.dev == nil || m.timer != nil {
+		return
+	}
+	if err := m.dev.SetLCD(page); err != nil {
+		fmt.Fprintln(os.Stderr, i18n.Sprintf("error showing page on the LCD: %s", err))
+	}
+}
+
+// release stops holding the page and restores the content of the LCD.
+func (m *lcdMessages) release() {
+	if m == nil {
+		return
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.page == nil {
+		return
+	}
+	m.page = nil
+	if m.dev == nil || m.timer != nil {
+		return
+	}
+	if err := m.restore(); err != nil {
+		fmt.Fprintln(os.Stderr, i18n.Sprintf("error restoring the LCD after a page: %s", err))
+	}
+}
+
+// setContent records the content and sets it, unless a message or a page is
+// shown.
+func (m *lcdMessages) setContent(set func() error) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.last = set
+	if m.timer != nil || m.page != nil {
+		return nil
+	}
+	return set()
+}
+
+// detach stops showing messages on the device, which is being closed.
+func (m *lcdMessages) detach(dev device.Device) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.dev != dev {
+		return
+	}
+	if m.timer != nil {
+		m.timer.Stop()
+		m.timer = nil
+	}
+	m.dev = nil
+	m.last = nil
+}
+
+// messageDevice passes the LCD content to [lcdMessages].
+type messageDevice struct {
+	device.Device
+
+	messages *lcdMessages
+}
+
+func (d *messageDevice) SetLCD(img image.Image) error {
+	return d.messages.setContent(func() error { return d.Device.SetLCD(img) })
+}
+
+func (d *messageDevice) WriteFrame(frame []byte) error {
+	return d.messages.setContent(func() error { return d.Device.WriteFrame(frame) })
+}
+
+func (d *messageDevice) ResetLCD() error {
+	return d.messages.setContent(d.Device.ResetLCD)
+}
+
+func (d *messageDevice) Close() {
+	d.messages.detach(d.Device)
+	d.Device.Close()
+}
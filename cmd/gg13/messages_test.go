@@ -0,0 +1,59 @@
+package main
+
+import (
+	"image"
+	"testing"
+	"time"
+
+	"github.com/achilleas-k/gg13/internal/device"
+	"github.com/achilleas-k/gg13/internal/lcd"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLCDMessages(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	// messages are expired by the test
+	messages := newLCDMessages(time.Hour)
+	testDev := &testOutputDevice{}
+	dev := messages.wrap(testDev)
+
+	// without content, the LCD is reset after a message
+	messages.show("Config reloaded")
+	assert.Equal(lcd.TextPage("Config reloaded"), testDev.lcd)
+	messages.expire(messages.shown)
+	assert.Nil(testDev.lcd)
+
+	// content set while a message is shown is held back until it expires
+	img := image.NewGray(image.Rect(0, 0, device.LCDWidth, device.LCDHeight))
+	img.Pix[10] = 255
+	require.NoError(dev.SetLCD(img))
+	assert.Equal(img, testDev.lcd)
+	messages.show("Output paused")
+	messages.show("Output resumed")
+	other := image.NewGray(img.Rect)
+	require.NoError(dev.SetLCD(other))
+	assert.Equal(lcd.TextPage("Output resumed"), testDev.lcd)
+	// the replaced message doesn't expire the new one
+	messages.expire(messages.shown - 1)
+	assert.Equal(lcd.TextPage("Output resumed"), testDev.lcd)
+	messages.expire(messages.shown)
+	assert.Equal(other, testDev.lcd)
+
+	// nothing is shown on a closed device
+	messages.show("Read error")
+	dev.Close()
+	assert.True(testDev.closed)
+	testDev.lcd = nil
+	messages.show("Device reconnected")
+	messages.expire(messages.shown)
+	assert.Nil(testDev.lcd)
+
+	// disabled messages
+	var disabled *lcdMessages
+	assert.Same(testDev, disabled.wrap(testDev))
+	disabled.show("Config reloaded")
+	assert.Nil(newLCDMessages(0))
+}
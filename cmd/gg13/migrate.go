@@ -0,0 +1,110 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/achilleas-k/gg13/internal/config"
+	"github.com/spf13/cobra"
+)
+
+func mkMigrateCmd() *cobra.Command {
+	migrateCmd := &cobra.Command{
+		Use:                   "migrate <config>",
+		Short:                 "Upgrade a config file to the latest format version",
+		Args:                  cobra.ExactArgs(1),
+		RunE:                  migrate,
+		ValidArgsFunction:     completeConfigFile,
+		DisableFlagsInUseLine: true,
+	}
+	migrateCmd.Flags().StringP("output", "o", "", "path to write the upgraded config to (default: overwrite the config file)")
+	return migrateCmd
+}
+
+func migrate(cmd *cobra.Command, args []string) error {
+	cmd.SilenceUsage = true
+
+	configPath := args[0]
+	outPath, err := cmd.Flags().GetString("output")
+	if err != nil {
+		return err
+	}
+	if outPath == "" {
+		outPath = configPath
+	}
+
+	data, err := os.ReadFile(configPath)
+	if err != nil {
+		return fmt.Errorf("failed reading config file %q: %w", configPath, err)
+	}
+
+	migrated, changed, err := config.Migrate(data)
+	if err != nil {
+		return fmt.Errorf("failed migrating config file %q: %w", configPath, err)
+	}
+	if !changed {
+		fmt.Printf("Config file %s is already at version %d\n", configPath, config.CurrentVersion)
+		return nil
+	}
+
+	if err := writeConfig(configPath, outPath, migrated); err != nil {
+		return fmt.Errorf("failed writing upgraded config: %w", err)
+	}
+	fmt.Printf("Config upgraded to version %d and written to %s\n", config.CurrentVersion, outPath)
+	return nil
+}
+
+// writeConfig checks the config data and writes it to outPath, keeping the
+// permissions of the config file at configPath.
+func writeConfig(configPath, outPath string, data []byte) error {
+	return writeChecked(configPath, outPath, data, func(path string) error {
+		if _, err := config.NewFromFile(path); err != nil {
+			return fmt.Errorf("config is invalid: %w", err)
+		}
+		return nil
+	})
+}
+
+// writeChecked writes the data to outPath, keeping the permissions of the file
+// at configPath, if check passes for the file with the data. A nil check
+// passes.
+func writeChecked(configPath, outPath string, data []byte, check func(path string) error) error {
+	// Write to a temporary file next to the output so relative paths in the
+	// config resolve the same way, validate it, then move it into place.
+	tmpFile, err := os.CreateTemp(filepath.Dir(outPath), ".gg13-config-*.json")
+	if err != nil {
+		return fmt.Errorf("failed creating temporary file: %w", err)
+	}
+	tmpPath := tmpFile.Name()
+	defer func() {
+		// no-op if the file was moved into place
+		_ = os.Remove(tmpPath)
+	}()
+
+	if _, err := tmpFile.Write(data); err != nil {
+		_ = tmpFile.Close()
+		return err
+	}
+	if err := tmpFile.Close(); err != nil {
+		return err
+	}
+
+	// keep the permissions of the original file
+	if info, err := os.Stat(configPath); err == nil {
+		if err := os.Chmod(tmpPath, info.Mode().Perm()); err != nil {
+			return fmt.Errorf("failed setting permissions: %w", err)
+		}
+	}
+
+	if check != nil {
+		if err := check(tmpPath); err != nil {
+			return err
+		}
+	}
+
+	if err := os.Rename(tmpPath, outPath); err != nil {
+		return fmt.Errorf("failed moving config to %q: %w", outPath, err)
+	}
+	return nil
+}
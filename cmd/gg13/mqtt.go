@@ -0,0 +1,65 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/achilleas-k/gg13/internal/config"
+	"github.com/achilleas-k/gg13/internal/device"
+	"github.com/achilleas-k/gg13/internal/lcd"
+	"github.com/achilleas-k/gg13/internal/mqtt"
+)
+
+// keyPublisher is the part of [mqtt.Client] used for publishing key events.
+type keyPublisher interface {
+	PublishKey(key string, down bool)
+}
+
+// startMQTT connects to the MQTT broker if the integration is enabled in the
+// config. It returns nil if it isn't.
+func startMQTT(g13cfg *config.G13Config, devRef *deviceRef) (*mqtt.Client, error) {
+	opts := g13cfg.GetMQTTOptions()
+	if opts == nil {
+		return nil, nil
+	}
+
+	// the handlers are called one at a time, so they can share the face
+	face, err := g13cfg.GetLCDFace()
+	if err != nil {
+		return nil, err
+	}
+
+	handlers := mqtt.Handlers{
+		Backlight: func(colour string) error {
+			rgb, err := config.ParseColour(colour)
+			if err != nil {
+				return err
+			}
+			dev := devRef.get()
+			if dev == nil {
+				return fmt.Errorf("device not connected")
+			}
+			return dev.SetBacklightColour(rgb[0], rgb[1], rgb[2])
+		},
+		LCD: func(text string) error {
+			dev := devRef.get()
+			if dev == nil {
+				return fmt.Errorf("device not connected")
+			}
+			return dev.SetLCD(lcd.TextPageFace(text, face))
+		},
+	}
+	return mqtt.New(*opts, handlers, devRef.get() != nil)
+}
+
+// publishKeys publishes the keys that changed state since prevInput.
+func publishKeys(input, prevInput uint64, publisher keyPublisher) {
+	changed := input ^ prevInput
+	if changed == 0 {
+		return
+	}
+	for _, gkey := range device.AllKeys() {
+		if gkey.Uint64()&changed != 0 {
+			publisher.PublishKey(gkey.String(), gkey.Uint64()&input != 0)
+		}
+	}
+}
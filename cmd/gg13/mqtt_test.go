@@ -0,0 +1,43 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/achilleas-k/gg13/internal/device"
+	"github.com/stretchr/testify/assert"
+)
+
+type testPublisher struct {
+	events []testEvent
+}
+
+func (p *testPublisher) PublishKey(key string, down bool) {
+	action := "up"
+	if down {
+		action = "down"
+	}
+	p.events = append(p.events, testEvent{action: action, code: int(device.KeyCode(key))})
+}
+
+func TestPublishKeys(t *testing.T) {
+	publisher := &testPublisher{}
+
+	inputs := []uint64{
+		device.G1.Uint64(),
+		device.G1.Uint64() | device.M2.Uint64(),
+		device.M2.Uint64() | encodeStickPosition(200, 10), // stick movements aren't key events
+		0,
+	}
+	var prevInput uint64
+	for _, input := range inputs {
+		publishKeys(input, prevInput, publisher)
+		prevInput = input
+	}
+
+	assert.Equal(t, []testEvent{
+		{action: "down", code: int(device.G1)},
+		{action: "down", code: int(device.M2)},
+		{action: "up", code: int(device.G1)},
+		{action: "up", code: int(device.M2)},
+	}, publisher.events)
+}
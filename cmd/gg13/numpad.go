@@ -0,0 +1,100 @@
+package main
+
+import (
+	"github.com/achilleas-k/gg13/internal/config"
+	"github.com/achilleas-k/gg13/internal/device"
+	"github.com/achilleas-k/gg13/internal/keyboard"
+	"github.com/achilleas-k/gg13/internal/lcd"
+)
+
+// maxNumpadEntry limits the number of characters of the entry shown on the
+// LCD while a numpad profile is active; the oldest ones are dropped.
+const maxNumpadEntry = 20
+
+// numpadChars are the characters that the keys of the numpad layout add to
+// the entry shown on the LCD.
+var numpadChars = map[int]byte{
+	keyboard.KeyCode("KeyKp0"): '0', keyboard.KeyCode("KeyKp1"): '1', keyboard.KeyCode("KeyKp2"): '2',
+	keyboard.KeyCode("KeyKp3"): '3', keyboard.KeyCode("KeyKp4"): '4', keyboard.KeyCode("KeyKp5"): '5',
+	keyboard.KeyCode("KeyKp6"): '6', keyboard.KeyCode("KeyKp7"): '7', keyboard.KeyCode("KeyKp8"): '8',
+	keyboard.KeyCode("KeyKp9"): '9', keyboard.KeyCode("KeyKpdot"): '.', keyboard.KeyCode("KeyKpslash"): '/',
+	keyboard.KeyCode("KeyKpasterisk"): '*', keyboard.KeyCode("KeyKpminus"): '-', keyboard.KeyCode("KeyKpplus"): '+',
+}
+
+// numpadEntry is what was typed while a profile with the numpad layout is
+// active, shown on the LCD in place of its content.
+type numpadEntry struct {
+	// the numpad profile shown; empty for none
+	profile string
+	text    []byte
+}
+
+// numpadLayout returns true if the named profile uses the numpad layout.
+func numpadLayout(g13cfg *config.G13Config, name string) bool {
+	profile, ok := g13cfg.GetProfile(name)
+	return ok && profile.Layout == config.LayoutNumpad
+}
+
+// handleNumpad shows the numpad page on the LCD while a profile with the
+// numpad layout is active, and adds the keys pressed since the previous read
+// to the entry on it. Only the G13 keys are looked up, without touching the
+// stick state of the mapping. Backspace deletes the last character and Enter
+// clears the entry. The keys typed while output is paused aren't added.
+func (d *actionDispatcher) handleNumpad(input, prevInput uint64, g13cfg *config.G13Config) {
+	active := d.activeProfile()
+	if !numpadLayout(g13cfg, active) {
+		active = ""
+	}
+	if active != d.numpad.profile {
+		d.numpad = numpadEntry{profile: active}
+		if active == "" {
+			d.messages.release()
+			return
+		}
+		d.showNumpad()
+	}
+	if active == "" || d.paused {
+		return
+	}
+
+	pressed := input &^ prevInput
+	if pressed == 0 {
+		return
+	}
+	outCfg := d.outputConfig(g13cfg)
+	changed := false
+	for _, gkey := range device.AllKeys() {
+		if pressed&gkey.Uint64() == 0 {
+			continue
+		}
+		kbkey, ok := outCfg.GetKey(gkey)
+		if !ok {
+			continue
+		}
+		switch kbkey {
+		case keyboard.KeyCode("KeyBackspace"):
+			if len(d.numpad.text) > 0 {
+				d.numpad.text = d.numpad.text[:len(d.numpad.text)-1]
+			}
+		case keyboard.KeyCode("KeyKpenter"):
+			d.numpad.text = nil
+		default:
+			char, ok := numpadChars[kbkey]
+			if !ok {
+				continue
+			}
+			d.numpad.text = append(d.numpad.text, char)
+			if len(d.numpad.text) > maxNumpadEntry {
+				d.numpad.text = d.numpad.text[len(d.numpad.text)-maxNumpadEntry:]
+			}
+		}
+		changed = true
+	}
+	if changed {
+		d.showNumpad()
+	}
+}
+
+func (d *actionDispatcher) showNumpad() {
+	d.messages.hold(lcd.TextPage("Numpad\n" + string(d.numpad.text)))
+}
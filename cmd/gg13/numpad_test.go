@@ -0,0 +1,74 @@
+package main
+
+import (
+	"image"
+	"strings"
+	"testing"
+
+	"github.com/achilleas-k/gg13/internal/device"
+	"github.com/achilleas-k/gg13/internal/lcd"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNumpad(t *testing.T) {
+	assert := assert.New(t)
+
+	cfg := loadTestConfig(t, `{"mapping":{"keys":{"G1":"KeyA"}},"profiles":{"pin":{"key":"M1","layout":"numpad"}}}`)
+
+	// messages are disabled, but pages are still shown
+	messages := &lcdMessages{}
+	testDev := &testOutputDevice{}
+	dev := messages.wrap(testDev)
+	img := image.NewGray(image.Rect(0, 0, device.LCDWidth, device.LCDHeight))
+	img.Pix[10] = 255
+	require.NoError(t, dev.SetLCD(img))
+
+	dispatcher := &actionDispatcher{messages: messages}
+	press := func(gkeys ...device.KeyBit) {
+		for _, gkey := range gkeys {
+			dispatcher.handleActions(gkey.Uint64(), 0, cfg, &testConfigurableDevice{})
+			dispatcher.handleActions(0, gkey.Uint64(), cfg, &testConfigurableDevice{})
+		}
+	}
+
+	press(device.M1)
+	assert.Equal(lcd.TextPage("Numpad\n"), testDev.lcd)
+	press(device.G2, device.G10, device.G18, device.G21, device.G13)
+	assert.Equal(lcd.TextPage("Numpad\n75.0+"), testDev.lcd)
+	press(device.G7)
+	assert.Equal(lcd.TextPage("Numpad\n75.0"), testDev.lcd)
+
+	// content set meanwhile is held back until the profile is left
+	other := image.NewGray(img.Rect)
+	require.NoError(t, dev.SetLCD(other))
+	assert.Equal(lcd.TextPage("Numpad\n75.0"), testDev.lcd)
+
+	// keys aren't added while output is paused
+	dispatcher.paused = true
+	press(device.G15)
+	dispatcher.paused = false
+	press(device.G16)
+	assert.Equal(lcd.TextPage("Numpad\n75.02"), testDev.lcd)
+
+	// Enter clears the entry and only the last characters are kept
+	press(device.G22)
+	assert.Equal(lcd.TextPage("Numpad\n"), testDev.lcd)
+	for range maxNumpadEntry {
+		press(device.G15)
+	}
+	press(device.G16)
+	assert.Equal(lcd.TextPage("Numpad\n"+strings.Repeat("1", maxNumpadEntry-1)+"2"), testDev.lcd)
+
+	// leaving the profile restores the content and clears the entry
+	press(device.M1)
+	assert.Equal(other, testDev.lcd)
+	press(device.M1)
+	assert.Equal(lcd.TextPage("Numpad\n"), testDev.lcd)
+
+	// it's called for every input report
+	allocs := testing.AllocsPerRun(100, func() {
+		dispatcher.handleNumpad(device.G1.Uint64(), 0, cfg)
+	})
+	assert.Zero(allocs)
+}
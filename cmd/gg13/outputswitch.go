@@ -0,0 +1,119 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/achilleas-k/gg13/internal/control"
+	"github.com/achilleas-k/gg13/internal/output"
+	"github.com/spf13/cobra"
+)
+
+// outputSwitchTimeout limits how long a control request waits for the input
+// loop to switch the output. The loop checks for requests between reads, so
+// it's normally much quicker.
+const outputSwitchTimeout = 3 * time.Second
+
+// outputSwitchRequest asks the input loop to switch to the named output. The
+// loop sends the outcome on result.
+type outputSwitchRequest struct {
+	name   string
+	result chan error
+}
+
+// outputSwitcher passes requests to switch the output backend at runtime to
+// the input loop, which owns the keyboard and joystick, and tracks the
+// active backend so reinitialising the device keeps it.
+type outputSwitcher struct {
+	requests chan outputSwitchRequest
+
+	mu     sync.Mutex
+	active string
+}
+
+func newOutputSwitcher(active string) *outputSwitcher {
+	return &outputSwitcher{
+		requests: make(chan outputSwitchRequest),
+		active:   active,
+	}
+}
+
+// get returns the name of the active output.
+func (s *outputSwitcher) get() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.active
+}
+
+// set records the name of the active output, once the loop switched to it.
+func (s *outputSwitcher) set(name string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.active = name
+}
+
+// request asks the input loop to switch to the named output and waits for it
+// to be done.
+func (s *outputSwitcher) request(name string) error {
+	if err := output.Validate(name); err != nil {
+		return err
+	}
+
+	// buffered so the loop doesn't block if the request timed out
+	req := outputSwitchRequest{name: name, result: make(chan error, 1)}
+	timeout := time.After(outputSwitchTimeout)
+	select {
+	case s.requests <- req:
+	case <-timeout:
+		return fmt.Errorf("timed out waiting for the input loop: is the device being reinitialised?")
+	}
+	select {
+	case err := <-req.result:
+		return err
+	case <-timeout:
+		return fmt.Errorf("timed out waiting for the output to switch")
+	}
+}
+
+// outputStatus is the reply to the output control command.
+type outputStatus struct {
+	Active    string   `json:"active"`
+	Available []string `json:"available"`
+}
+
+// handleOutput registers the control command for showing and switching the
+// output backend, for example from a script run when the pointer moves to
+// another machine with input-leap or Barrier.
+func handleOutput(server *control.Server, outputs *outputSwitcher) {
+	server.Handle("output", func(args []string) (any, error) {
+		if len(args) > 1 {
+			return nil, fmt.Errorf("output: expected at most one argument, got %d", len(args))
+		}
+		if len(args) == 1 && args[0] != outputs.get() {
+			if err := outputs.request(args[0]); err != nil {
+				return nil, fmt.Errorf("output: %w", err)
+			}
+		}
+		return outputStatus{Active: outputs.get(), Available: output.Names()}, nil
+	})
+}
+
+func ctlOutput(cmd *cobra.Command, args []string) error {
+	cmd.SilenceUsage = true
+
+	data, err := sendControl(cmd, control.Request{Command: "output", Args: args})
+	if err != nil {
+		return err
+	}
+
+	var status outputStatus
+	if err := json.Unmarshal(data, &status); err != nil {
+		return fmt.Errorf("failed decoding output status: %w", err)
+	}
+	fmt.Printf("active:    %s\n", status.Active)
+	fmt.Printf("available: %s\n", strings.Join(status.Available, ", "))
+	return nil
+}
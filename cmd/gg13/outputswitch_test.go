@@ -0,0 +1,59 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"testing"
+
+	"github.com/achilleas-k/gg13/internal/control"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOutputSwitch(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	socketPath := filepath.Join(t.TempDir(), "gg13.sock")
+	server, err := control.NewServer(socketPath)
+	require.NoError(err)
+	defer server.Close()
+
+	outputs := newOutputSwitcher("uinput")
+	handleOutput(server, outputs)
+
+	// stand-in for the input loop, failing to open the network output
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		for {
+			select {
+			case req := <-outputs.requests:
+				if req.name == "network" {
+					req.result <- fmt.Errorf("network output: a token is required")
+					continue
+				}
+				outputs.set(req.name)
+				req.result <- nil
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	data, err := control.Send(socketPath, control.Request{Command: "output"})
+	require.NoError(err)
+	assert.JSONEq(`{"active":"uinput","available":["dry-run","network","qemu","uinput"]}`, string(data))
+
+	data, err = control.Send(socketPath, control.Request{Command: "output", Args: []string{"dry-run"}})
+	require.NoError(err)
+	assert.JSONEq(`{"active":"dry-run","available":["dry-run","network","qemu","uinput"]}`, string(data))
+	assert.Equal("dry-run", outputs.get())
+
+	_, err = control.Send(socketPath, control.Request{Command: "output", Args: []string{"network"}})
+	assert.EqualError(err, "output: network output: a token is required")
+	assert.Equal("dry-run", outputs.get())
+
+	_, err = control.Send(socketPath, control.Request{Command: "output", Args: []string{"smoke-signals"}})
+	assert.ErrorContains(err, `output: unknown output "smoke-signals"`)
+}
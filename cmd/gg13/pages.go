@@ -0,0 +1,56 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+
+	"github.com/achilleas-k/gg13/internal/config"
+)
+
+// handlePages steps through the pages with the soft keys pressed since the
+// previous read.
+func (d *actionDispatcher) handlePages(input, prevInput uint64, g13cfg *config.G13Config) {
+	pages := g13cfg.GetPages()
+	if pages == nil {
+		return
+	}
+	step := 0
+	for key, dir := range map[uint64]int{pages.Previous.Uint64(): -1, pages.Next.Uint64(): 1} {
+		if input&key != 0 && prevInput&key == 0 {
+			step += dir
+		}
+	}
+	if step == 0 {
+		return
+	}
+	// there are pages
+	_ = d.stepPage(step, g13cfg)
+}
+
+// stepPage latches the profile of the page step places after the active one
+// and shows its name on the LCD. While no page is active, stepping forward
+// goes to the first page and stepping back to the last one.
+func (d *actionDispatcher) stepPage(step int, g13cfg *config.G13Config) error {
+	pages := g13cfg.GetPages()
+	if pages == nil {
+		return fmt.Errorf("no pages are configured")
+	}
+
+	active := d.activeProfile()
+	if active == "" {
+		active = config.MainProfile
+	}
+	idx := slices.Index(pages.Names, active)
+	switch {
+	case idx < 0 && step > 0:
+		idx = 0
+	case idx < 0:
+		idx = len(pages.Names) - 1
+	default:
+		idx = (idx + step + len(pages.Names)) % len(pages.Names)
+	}
+	// the pages are checked when the config is loaded
+	_ = d.switchProfile(pages.Names[idx], g13cfg)
+	d.messages.show("Page %d/%d:\n%s", idx+1, len(pages.Names), pages.Names[idx])
+	return nil
+}
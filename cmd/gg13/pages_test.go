@@ -0,0 +1,82 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/achilleas-k/gg13/internal/config"
+	"github.com/achilleas-k/gg13/internal/device"
+	"github.com/achilleas-k/gg13/internal/lcd"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPages(t *testing.T) {
+	assert := assert.New(t)
+
+	cfg := loadTestConfig(t, `{"profiles":{"media":{},"obs":{},"game":{"key":"M1"}},"pages":{"names":["main","media","obs"]}}`)
+
+	messages := &lcdMessages{duration: time.Minute}
+	testDev := &testOutputDevice{}
+	messages.wrap(testDev)
+	dispatcher := &actionDispatcher{messages: messages}
+	press := func(key device.KeyBit) {
+		dispatcher.handleActions(key.Uint64(), 0, cfg, &testConfigurableDevice{})
+	}
+
+	press(device.L4)
+	assert.Equal("media", dispatcher.activeProfile())
+	assert.Equal(lcd.TextPage("Page 2/3:\nmedia"), testDev.lcd)
+	press(device.L4)
+	press(device.L4)
+	// around to the first page
+	assert.Equal("", dispatcher.activeProfile())
+	assert.Equal(lcd.TextPage("Page 1/3:\nmain"), testDev.lcd)
+	press(device.L1)
+	assert.Equal("obs", dispatcher.activeProfile())
+
+	// from a profile that isn't a page
+	press(device.M1)
+	assert.Equal("game", dispatcher.activeProfile())
+	press(device.L1)
+	assert.Equal("obs", dispatcher.activeProfile())
+	press(device.M1)
+	press(device.L4)
+	assert.Equal("", dispatcher.activeProfile())
+	assert.Equal(lcd.TextPage("Page 1/3:\nmain"), testDev.lcd)
+
+	// held keys don't step again
+	dispatcher.handleActions(device.L4.Uint64(), device.L4.Uint64(), cfg, &testConfigurableDevice{})
+	assert.Equal("", dispatcher.activeProfile())
+}
+
+func TestPageActions(t *testing.T) {
+	assert := assert.New(t)
+
+	step := `{"actions":{"G1":"next_page","G2":"previous_page"}}`
+	cfg := loadTestConfig(t, `{
+		"mapping":`+step+`,
+		"profiles":{"media":{"mapping":`+step+`},"obs":{"mapping":`+step+`}},
+		"pages":{"names":["main","media","obs"]}
+	}`)
+
+	messages := &lcdMessages{duration: time.Minute}
+	testDev := &testOutputDevice{}
+	messages.wrap(testDev)
+	dispatcher := &actionDispatcher{messages: messages}
+	press := func(key device.KeyBit) {
+		dispatcher.handleActions(key.Uint64(), 0, cfg, &testConfigurableDevice{})
+	}
+
+	press(device.G1)
+	assert.Equal("media", dispatcher.activeProfile())
+	assert.Equal(lcd.TextPage("Page 2/3:\nmedia"), testDev.lcd)
+	press(device.G2)
+	press(device.G2)
+	assert.Equal("obs", dispatcher.activeProfile())
+	// the soft keys step through the same pages
+	press(device.L4)
+	assert.Equal("", dispatcher.activeProfile())
+
+	_, err := dispatcher.dispatch(config.ActionNextPage, loadTestConfig(t, `{}`), &testConfigurableDevice{})
+	assert.EqualError(err, "no pages are configured")
+}
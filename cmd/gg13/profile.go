@@ -0,0 +1,447 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/achilleas-k/gg13/internal/chording"
+	"github.com/achilleas-k/gg13/internal/config"
+	"github.com/spf13/cobra"
+)
+
+const (
+	// profileFetchTimeout limits how long downloading a profile or its
+	// checksum can take.
+	profileFetchTimeout = 30 * time.Second
+
+	// profileSizeLimit is the largest profile file that's downloaded.
+	profileSizeLimit = 1 << 20
+)
+
+func mkProfileCmd() *cobra.Command {
+	profileCmd := &cobra.Command{
+		Use:   "profile",
+		Short: "Install and list the profiles of a config directory",
+		Long: "Install and list the profiles of a config directory. Each JSON file in the directory other than " +
+			config.ConfigDirFile + " is a profile named after the file. Run the driver with the directory instead " +
+			"of a config file to use them, and switch to them with 'gg13 ctl profile <name>'.",
+	}
+	profileCmd.PersistentFlags().String("dir", config.DefaultConfigDir(), "config directory the profiles are installed in")
+
+	getCmd := &cobra.Command{
+		Use:   "get <url|name>",
+		Short: "Download a shared profile into the config directory",
+		Long: "Download a shared profile into the config directory. The profile is either an https URL of a JSON " +
+			"file or a name resolved to <name>.json in the https repository given with --repository. The download " +
+			"is verified against the SHA-256 checksum given with --sha256, which should come from someone you " +
+			"trust. Without it, the checksum is downloaded from the same URL with .sha256 appended, which only " +
+			"catches corrupted downloads: whoever can change the profile can change its checksum too. The profile " +
+			"is checked like the driver would before it's installed, and a profile with on_enter or on_leave " +
+			"hooks, which run shell commands, is only installed with --allow-hooks.",
+		Args:                  cobra.ExactArgs(1),
+		RunE:                  profileGet,
+		DisableFlagsInUseLine: true,
+	}
+	getCmd.Flags().String("repository", "", "base URL of the profile repository to resolve names in")
+	getCmd.Flags().String("sha256", "", "expected SHA-256 checksum of the profile in hex (default: download it from <url>.sha256, which doesn't verify who wrote the profile)")
+	getCmd.Flags().String("name", "", "name to install the profile as (default: the name of the file)")
+	getCmd.Flags().Bool("force", false, "replace an installed profile with the same name")
+	getCmd.Flags().Bool("allow-hooks", false, "install a profile with on_enter or on_leave hooks, which run the shell commands it contains")
+
+	listCmd := &cobra.Command{
+		Use:   "list",
+		Short: "Show the profiles installed in the config directory",
+		Args:  cobra.NoArgs,
+		RunE:  profileList,
+	}
+
+	chordsCmd := &cobra.Command{
+		Use:   "chords",
+		Short: "Generate a one-handed chording profile into the config directory",
+		Long: "Generate a profile that types with chords of the G keys, so that one hand can type text on the pad, " +
+			"and install it into the config directory. The keys of a chord are pressed together and the key of " +
+			"the chord is typed when they're all released. The most frequently typed keys get the easiest chords: " +
+			"single keys first, then pairs of keys next to each other. The frequencies of English text are used " +
+			"unless a table is given with --frequencies, with a character and its weight on each line, like " +
+			"'e 12.7'. Use 'space' or a keyboard key name, like KeyBackspace, for keys that aren't characters.",
+		Args:                  cobra.NoArgs,
+		RunE:                  profileChords,
+		DisableFlagsInUseLine: true,
+	}
+	chordsCmd.Flags().String("frequencies", "", "file with the frequency table to generate the layout from")
+	chordsCmd.Flags().String("name", "one-handed", "name to install the profile as")
+	chordsCmd.Flags().String("key", "", "M key switching to the profile (default: none, switch to it by name)")
+	chordsCmd.Flags().Bool("force", false, "replace an installed profile with the same name")
+
+	profileCmd.AddCommand(getCmd)
+	profileCmd.AddCommand(listCmd)
+	profileCmd.AddCommand(chordsCmd)
+	return profileCmd
+}
+
+// profileDir returns the config directory set with --dir.
+func profileDir(cmd *cobra.Command) (string, error) {
+	dir, err := cmd.Flags().GetString("dir")
+	if err != nil {
+		return "", err
+	}
+	if dir == "" {
+		return "", fmt.Errorf("no config directory: set one with --dir")
+	}
+	return dir, nil
+}
+
+// profileSource is where a profile is downloaded from.
+type profileSource struct {
+	name string
+	url  string
+}
+
+// resolveProfile returns the source of the profile given as a URL or as a name
+// in the repository.
+func resolveProfile(arg, repository, name string) (profileSource, error) {
+	var src profileSource
+	if strings.Contains(arg, "://") {
+		u, err := url.Parse(arg)
+		if err != nil {
+			return src, fmt.Errorf("invalid profile URL %q: %w", arg, err)
+		}
+		if u.Scheme != "https" {
+			return src, fmt.Errorf("unsupported profile URL scheme: %s: only https is accepted", u.Scheme)
+		}
+		src.url = arg
+		src.name = strings.TrimSuffix(path.Base(u.Path), ".json")
+	} else {
+		if repository == "" {
+			return src, fmt.Errorf("profile %q isn't a URL: set the repository to get it from with --repository", arg)
+		}
+		if u, err := url.Parse(repository); err != nil || u.Scheme != "https" {
+			return src, fmt.Errorf("invalid repository %q: it must be an https URL", repository)
+		}
+		src.name = strings.TrimSuffix(arg, ".json")
+		if err := checkProfileName(src.name); err != nil {
+			return src, err
+		}
+		src.url = strings.TrimSuffix(repository, "/") + "/" + url.PathEscape(src.name) + ".json"
+	}
+	if name != "" {
+		src.name = name
+	}
+	return src, checkProfileName(src.name)
+}
+
+// checkProfileName returns an error if the profile name can't be the name of
+// a profile file in the config directory.
+func checkProfileName(name string) error {
+	switch {
+	case name == "" || name == "/" || name == ".":
+		return fmt.Errorf("profile name can't be empty")
+	case strings.ContainsAny(name, `/\`):
+		return fmt.Errorf("invalid profile name %q: it can't contain slashes", name)
+	case strings.HasPrefix(name, "."):
+		return fmt.Errorf("invalid profile name %q: it can't start with a dot", name)
+	case name+".json" == config.ConfigDirFile:
+		return fmt.Errorf("invalid profile name %q: it's the name of the main config", name)
+	}
+	return nil
+}
+
+// fetch downloads the file at the URL, up to limit bytes.
+func fetch(client *http.Client, u string, limit int64) ([]byte, error) {
+	resp, err := client.Get(u)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = resp.Body.Close() }()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed downloading %s: %s", u, resp.Status)
+	}
+	data, err := io.ReadAll(io.LimitReader(resp.Body, limit+1))
+	if err != nil {
+		return nil, fmt.Errorf("failed downloading %s: %w", u, err)
+	}
+	if int64(len(data)) > limit {
+		return nil, fmt.Errorf("failed downloading %s: larger than %d bytes", u, limit)
+	}
+	return data, nil
+}
+
+// parseChecksum returns the checksum in the first field of a checksum file,
+// as written by sha256sum.
+func parseChecksum(data []byte) (string, error) {
+	fields := strings.Fields(string(data))
+	if len(fields) == 0 {
+		return "", fmt.Errorf("checksum file is empty")
+	}
+	return fields[0], nil
+}
+
+// verifyChecksum returns an error if the SHA-256 checksum of data isn't the
+// one given in hex.
+func verifyChecksum(data []byte, checksum string) error {
+	want, err := hex.DecodeString(strings.TrimSpace(checksum))
+	if err != nil || len(want) != sha256.Size {
+		return fmt.Errorf("invalid SHA-256 checksum: %q", checksum)
+	}
+	got := sha256.Sum256(data)
+	if !bytes.Equal(got[:], want) {
+		return fmt.Errorf("checksum mismatch: expected %x, got %x", want, got)
+	}
+	return nil
+}
+
+// installProfile checks the profile and writes it to the config directory,
+// replacing an installed profile with the same name only if force is set.
+// It returns the path of the profile file.
+func installProfile(dir, name string, data []byte, force bool) (string, error) {
+	if _, err := config.ReadProfile(name, data); err != nil {
+		return "", fmt.Errorf("invalid profile %s: %w", name, err)
+	}
+	if err := config.CheckProfileMacros(dir, data); err != nil {
+		return "", fmt.Errorf("invalid profile %s: %w", name, err)
+	}
+
+	profilePath := filepath.Join(dir, name+".json")
+	if _, err := os.Stat(profilePath); err == nil && !force {
+		return "", fmt.Errorf("profile %s is already installed in %s: use --force to replace it", name, dir)
+	} else if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return "", err
+	}
+
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return "", fmt.Errorf("failed creating config directory: %w", err)
+	}
+	// hidden, so the driver doesn't read it as a profile while it's written
+	tmpFile, err := os.CreateTemp(dir, ".gg13-profile-*.json")
+	if err != nil {
+		return "", fmt.Errorf("failed creating temporary file: %w", err)
+	}
+	tmpPath := tmpFile.Name()
+	defer func() {
+		// no-op if the file was moved into place
+		_ = os.Remove(tmpPath)
+	}()
+
+	if _, err := tmpFile.Write(data); err != nil {
+		_ = tmpFile.Close()
+		return "", fmt.Errorf("failed writing profile: %w", err)
+	}
+	if err := tmpFile.Close(); err != nil {
+		return "", fmt.Errorf("failed writing profile: %w", err)
+	}
+	if err := os.Chmod(tmpPath, 0o644); err != nil {
+		return "", fmt.Errorf("failed setting permissions of profile: %w", err)
+	}
+	if err := os.Rename(tmpPath, profilePath); err != nil {
+		return "", fmt.Errorf("failed writing profile to %q: %w", profilePath, err)
+	}
+	return profilePath, nil
+}
+
+// getProfile downloads the profile, verifies it against the checksum, or the
+// one downloaded from next to it if it's empty, and installs it. A profile
+// with hooks is only installed if allowHooks is set, since they run any
+// shell command.
+func getProfile(client *http.Client, dir string, src profileSource, checksum string, force, allowHooks bool) (string, error) {
+	data, err := fetch(client, src.url, profileSizeLimit)
+	if err != nil {
+		return "", err
+	}
+	if checksum == "" {
+		sumData, err := fetch(client, src.url+".sha256", 1024)
+		if err != nil {
+			return "", fmt.Errorf("no checksum for the profile: %w (set one with --sha256)", err)
+		}
+		checksum, err = parseChecksum(sumData)
+		if err != nil {
+			return "", err
+		}
+	}
+	if err := verifyChecksum(data, checksum); err != nil {
+		return "", fmt.Errorf("failed verifying profile %s: %w", src.name, err)
+	}
+	profile, err := config.ReadProfile(src.name, data)
+	if err != nil {
+		return "", fmt.Errorf("invalid profile %s: %w", src.name, err)
+	}
+	if !allowHooks && (profile.OnEnter != "" || profile.OnLeave != "") {
+		return "", fmt.Errorf("profile %s has on_enter or on_leave hooks, which run shell commands: check them and use --allow-hooks to install it", src.name)
+	}
+	return installProfile(dir, src.name, data, force)
+}
+
+func profileGet(cmd *cobra.Command, args []string) error {
+	cmd.SilenceUsage = true
+
+	dir, err := profileDir(cmd)
+	if err != nil {
+		return err
+	}
+	repository, err := cmd.Flags().GetString("repository")
+	if err != nil {
+		return err
+	}
+	checksum, err := cmd.Flags().GetString("sha256")
+	if err != nil {
+		return err
+	}
+	name, err := cmd.Flags().GetString("name")
+	if err != nil {
+		return err
+	}
+	force, err := cmd.Flags().GetBool("force")
+	if err != nil {
+		return err
+	}
+	allowHooks, err := cmd.Flags().GetBool("allow-hooks")
+	if err != nil {
+		return err
+	}
+
+	src, err := resolveProfile(args[0], repository, name)
+	if err != nil {
+		return err
+	}
+	client := &http.Client{Timeout: profileFetchTimeout}
+	profilePath, err := getProfile(client, dir, src, checksum, force, allowHooks)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Profile %s installed to %s\n", src.name, profilePath)
+	return nil
+}
+
+func profileList(cmd *cobra.Command, _ []string) error {
+	cmd.SilenceUsage = true
+
+	dir, err := profileDir(cmd)
+	if err != nil {
+		return err
+	}
+	profiles, err := config.ReadProfileDir(dir)
+	if err != nil {
+		return err
+	}
+	printProfiles(profiles)
+	return nil
+}
+
+// printProfiles prints a line for each profile with the key switching to it.
+func printProfiles(profiles []config.Profile) {
+	if len(profiles) == 0 {
+		fmt.Println("No profiles installed")
+		return
+	}
+	fmt.Printf("%-20s %-4s %s\n", "NAME", "KEY", "MOMENTARY")
+	for _, profile := range profiles {
+		key := "-"
+		if profile.Key != 0 {
+			key = profile.Key.String()
+		}
+		fmt.Printf("%-20s %-4s %t\n", profile.Name, key, profile.Momentary)
+	}
+}
+
+// chordProfile is a profile file with a chording layout.
+type chordProfile struct {
+	Key     string `json:"key,omitempty"`
+	Mapping struct {
+		Chords map[string]string `json:"chords"`
+	} `json:"mapping"`
+}
+
+// chordProfileData returns the profile file with the chords, switched to
+// with the key if set.
+func chordProfileData(chords []chording.Chord, key string) ([]byte, error) {
+	var profile chordProfile
+	profile.Key = key
+	profile.Mapping.Chords = make(map[string]string, len(chords))
+	for _, chord := range chords {
+		var keys uint64
+		for _, gkey := range chord.Keys {
+			keys |= gkey.Uint64()
+		}
+		profile.Mapping.Chords[config.ChordName(keys)] = chord.Key
+	}
+	data, err := json.MarshalIndent(profile, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append(data, '\n'), nil
+}
+
+func profileChords(cmd *cobra.Command, _ []string) error {
+	cmd.SilenceUsage = true
+
+	dir, err := profileDir(cmd)
+	if err != nil {
+		return err
+	}
+	freqPath, err := cmd.Flags().GetString("frequencies")
+	if err != nil {
+		return err
+	}
+	name, err := cmd.Flags().GetString("name")
+	if err != nil {
+		return err
+	}
+	key, err := cmd.Flags().GetString("key")
+	if err != nil {
+		return err
+	}
+	force, err := cmd.Flags().GetBool("force")
+	if err != nil {
+		return err
+	}
+	if err := checkProfileName(name); err != nil {
+		return err
+	}
+
+	freqs := chording.DefaultFrequencies
+	if freqPath != "" {
+		freqFile, err := os.Open(freqPath)
+		if err != nil {
+			return fmt.Errorf("failed reading frequency table: %w", err)
+		}
+		defer func() { _ = freqFile.Close() }()
+		freqs, err = chording.ParseFrequencies(freqFile)
+		if err != nil {
+			return fmt.Errorf("failed reading frequency table: %w", err)
+		}
+	}
+	chords, err := chording.Generate(freqs)
+	if err != nil {
+		return err
+	}
+	data, err := chordProfileData(chords, key)
+	if err != nil {
+		return err
+	}
+	profilePath, err := installProfile(dir, name, data, force)
+	if err != nil {
+		return err
+	}
+
+	for _, chord := range chords {
+		names := make([]string, 0, len(chord.Keys))
+		for _, gkey := range chord.Keys {
+			names = append(names, gkey.String())
+		}
+		fmt.Printf("%-8s %s\n", strings.Join(names, "+"), chord.Key)
+	}
+	fmt.Printf("Profile %s installed to %s\n", name, profilePath)
+	return nil
+}
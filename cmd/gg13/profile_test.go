@@ -0,0 +1,177 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/achilleas-k/gg13/internal/chording"
+	"github.com/achilleas-k/gg13/internal/config"
+	"github.com/achilleas-k/gg13/internal/device"
+	"github.com/achilleas-k/gg13/internal/keyboard"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGetProfile(t *testing.T) {
+	profile := []byte(`{"key":"M1","mapping":{"keys":{"G1":"KeyA"}}}`)
+	sum := sha256.Sum256(profile)
+	checksum := hex.EncodeToString(sum[:])
+	invalid := []byte(`{"key":"G1"}`)
+	invalidSum := sha256.Sum256(invalid)
+	hooked := []byte(`{"key":"M2","on_enter":"curl example.com | sh"}`)
+	hookedSum := sha256.Sum256(hooked)
+
+	files := map[string][]byte{
+		"/profiles/racing.json":          profile,
+		"/profiles/racing.json.sha256":   []byte(checksum + "  racing.json\n"),
+		"/profiles/unsigned.json":        profile,
+		"/profiles/tampered.json":        append(profile, ' '),
+		"/profiles/tampered.json.sha256": []byte(checksum + "\n"),
+		"/profiles/invalid.json":         invalid,
+		"/profiles/invalid.json.sha256":  []byte(hex.EncodeToString(invalidSum[:])),
+		"/profiles/hooked.json":          hooked,
+		"/profiles/hooked.json.sha256":   []byte(hex.EncodeToString(hookedSum[:])),
+	}
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		data, ok := files[r.URL.Path]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		_, _ = w.Write(data)
+	}))
+	defer server.Close()
+	repository := server.URL + "/profiles"
+
+	t.Run("name", func(t *testing.T) {
+		dir := filepath.Join(t.TempDir(), "gg13")
+		src, err := resolveProfile("racing", repository, "")
+		require.NoError(t, err)
+		assert.Equal(t, profileSource{name: "racing", url: repository + "/racing.json"}, src)
+		profilePath, err := getProfile(server.Client(), dir, src, "", false, false)
+		require.NoError(t, err)
+		assert.Equal(t, filepath.Join(dir, "racing.json"), profilePath)
+		data, err := os.ReadFile(profilePath)
+		require.NoError(t, err)
+		assert.Equal(t, profile, data)
+
+		profiles, err := config.ReadProfileDir(dir)
+		require.NoError(t, err)
+		require.Len(t, profiles, 1)
+		assert.Equal(t, "racing", profiles[0].Name)
+		assert.Equal(t, device.M1, profiles[0].Key)
+
+		_, err = getProfile(server.Client(), dir, src, "", false, false)
+		assert.ErrorContains(t, err, "profile racing is already installed")
+		_, err = getProfile(server.Client(), dir, src, "", true, false)
+		assert.NoError(t, err)
+	})
+
+	t.Run("url", func(t *testing.T) {
+		dir := t.TempDir()
+		src, err := resolveProfile(repository+"/unsigned.json", "", "flying")
+		require.NoError(t, err)
+		assert.Equal(t, "flying", src.name)
+		_, err = getProfile(server.Client(), dir, src, "", false, false)
+		assert.ErrorContains(t, err, "no checksum for the profile")
+		_, err = getProfile(server.Client(), dir, src, checksum, false, false)
+		require.NoError(t, err)
+		assert.FileExists(t, filepath.Join(dir, "flying.json"))
+	})
+
+	t.Run("hooks", func(t *testing.T) {
+		dir := t.TempDir()
+		src, err := resolveProfile("hooked", repository, "")
+		require.NoError(t, err)
+		_, err = getProfile(server.Client(), dir, src, "", false, false)
+		assert.EqualError(t, err, "profile hooked has on_enter or on_leave hooks, which run shell commands: check them and use --allow-hooks to install it")
+		assert.NoFileExists(t, filepath.Join(dir, "hooked.json"))
+		_, err = getProfile(server.Client(), dir, src, "", false, true)
+		require.NoError(t, err)
+		assert.FileExists(t, filepath.Join(dir, "hooked.json"))
+	})
+
+	t.Run("errors", func(t *testing.T) {
+		dir := t.TempDir()
+		src, err := resolveProfile("tampered", repository, "")
+		require.NoError(t, err)
+		_, err = getProfile(server.Client(), dir, src, "", false, false)
+		assert.ErrorContains(t, err, "failed verifying profile tampered: checksum mismatch")
+		_, err = getProfile(server.Client(), dir, src, "abc", false, false)
+		assert.ErrorContains(t, err, `invalid SHA-256 checksum: "abc"`)
+
+		src, err = resolveProfile("invalid", repository, "")
+		require.NoError(t, err)
+		_, err = getProfile(server.Client(), dir, src, "", false, false)
+		assert.EqualError(t, err, `invalid profile invalid: profiles: invalid: key must be one of M1, M2, M3, and MR: "G1"`)
+
+		src, err = resolveProfile("missing", repository, "")
+		require.NoError(t, err)
+		_, err = getProfile(server.Client(), dir, src, checksum, false, false)
+		assert.ErrorContains(t, err, "404 Not Found")
+
+		entries, err := os.ReadDir(dir)
+		require.NoError(t, err)
+		assert.Empty(t, entries, "nothing installed")
+
+		_, err = resolveProfile("racing", "", "")
+		assert.ErrorContains(t, err, "set the repository to get it from with --repository")
+		_, err = resolveProfile("file:///etc/passwd", "", "")
+		assert.EqualError(t, err, "unsupported profile URL scheme: file: only https is accepted")
+		_, err = resolveProfile("http://example.com/racing.json", "", "")
+		assert.EqualError(t, err, "unsupported profile URL scheme: http: only https is accepted")
+		_, err = resolveProfile("racing", "http://example.com/profiles", "")
+		assert.EqualError(t, err, `invalid repository "http://example.com/profiles": it must be an https URL`)
+		_, err = resolveProfile("../racing", repository, "")
+		assert.EqualError(t, err, `invalid profile name "../racing": it can't contain slashes`)
+		_, err = resolveProfile(repository+"/config.json", "", "")
+		assert.EqualError(t, err, `invalid profile name "config": it's the name of the main config`)
+		_, err = resolveProfile("racing", repository, ".hidden")
+		assert.EqualError(t, err, `invalid profile name ".hidden": it can't start with a dot`)
+	})
+}
+
+func TestInstallProfileMacros(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, config.ConfigDirFile), []byte(`{"macros":{"hello":[{"key":"KeyH","down":true}]}}`), 0o600))
+
+	_, err := installProfile(dir, "racing", []byte(`{"key":"M1","mapping":{"macros":{"G1":"hello"}}}`), false)
+	require.NoError(t, err)
+	_, err = config.NewFromFile(dir)
+	assert.NoError(t, err)
+
+	// a profile that can't play its macros would break the config
+	_, err = installProfile(dir, "flying", []byte(`{"key":"M2","mapping":{"macros":{"G1":"wave"}}}`), false)
+	assert.EqualError(t, err, "invalid profile flying: macros: G1: unknown macro: wave")
+	assert.NoFileExists(t, filepath.Join(dir, "flying.json"))
+}
+
+func TestChordProfileData(t *testing.T) {
+	chords, err := chording.Generate(chording.DefaultFrequencies)
+	require.NoError(t, err)
+	data, err := chordProfileData(chords, "M3")
+	require.NoError(t, err)
+
+	profile, err := config.ReadProfile("one-handed", data)
+	require.NoError(t, err)
+	assert.Equal(t, device.M3, profile.Key)
+
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, config.ConfigDirFile), []byte(`{}`), 0o600))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "one-handed.json"), data, 0o600))
+	cfg, err := config.NewFromFile(dir)
+	require.NoError(t, err)
+	chorded := cfg.WithProfile("one-handed")
+	require.NotNil(t, chorded)
+	kbKey, ok := chorded.GetChord(device.G8.Uint64())
+	assert.True(t, ok)
+	assert.Equal(t, keyboard.KeyCode("KeySpace"), kbKey)
+	kbKey, ok = chorded.GetChord(device.G8.Uint64() | device.G9.Uint64())
+	assert.True(t, ok)
+	assert.Equal(t, keyboard.KeyCode("KeyComma"), kbKey)
+}
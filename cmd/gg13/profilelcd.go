@@ -0,0 +1,201 @@
+package main
+
+import (
+	"fmt"
+	"image"
+	"image/draw"
+	"io"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/achilleas-k/gg13/internal/applet"
+	"github.com/achilleas-k/gg13/internal/config"
+	"github.com/achilleas-k/gg13/internal/device"
+	"github.com/achilleas-k/gg13/internal/i18n"
+)
+
+// profileLCD shows the LCD content of the active profile: its own, if it
+// sets any, or the content of the main config. The applet of the main config
+// is started once with the driver and keeps its state, like a running timer,
+// while a profile shows something else. The applets of profiles are created
+// each time they're switched to.
+type profileLCD struct {
+	devRef *deviceRef
+	mono   device.Monochrome
+
+	// the applet of the main config and its render interval, nil if it has
+	// none
+	main         applet.Applet
+	mainInterval time.Duration
+
+	// applets that run commands aren't started in the sandbox
+	sandboxed bool
+
+	mu sync.Mutex
+
+	// applets are drawn on it and it's flushed to the device after each
+	// render
+	fb *device.Framebuffer
+
+	// incremented on every switch, so that a render that finishes after it
+	// isn't shown over the new content
+	gen int
+
+	// the config of the profile whose content is shown, nil for the main
+	// config
+	shown *config.G13Config
+
+	// the running applet and its runner
+	applet applet.Applet
+	runner *applet.Runner
+}
+
+// newProfileLCD starts showing the applet of the main config, if there is
+// one.
+func newProfileLCD(devRef *deviceRef, g13cfg *config.G13Config, main applet.Applet, interval time.Duration, sandboxed bool) *profileLCD {
+	p := &profileLCD{
+		devRef:       devRef,
+		mono:         g13cfg.GetLCDMonochrome(),
+		main:         main,
+		mainInterval: interval,
+		sandboxed:    sandboxed,
+		fb:           device.NewFramebuffer(),
+	}
+	if main != nil {
+		p.start(main, interval)
+	}
+	return p
+}
+
+// start runs the applet. Each image it renders is drawn on the framebuffer,
+// which is then written to the device: applets render again at their
+// interval, so the frame doesn't need to be refreshed like an image set with
+// SetLCD. It's called with mu held, or before p is shared.
+func (p *profileLCD) start(a applet.Applet, interval time.Duration) {
+	gen := p.gen
+	p.applet = a
+	p.runner = applet.Start(a, interval, func(img image.Image) error {
+		p.mu.Lock()
+		defer p.mu.Unlock()
+		if gen != p.gen {
+			// switched away while rendering
+			return nil
+		}
+		dev := p.devRef.get()
+		if dev == nil {
+			// device is being reinitialised
+			return nil
+		}
+		if err := device.ValidateLCDImage(img); err != nil {
+			return err
+		}
+		draw.Draw(p.fb, p.fb.Bounds(), p.mono.Apply(img), image.Point{}, draw.Src)
+		return p.fb.Flush(dev)
+	})
+}
+
+// stopApplet stops the running applet, and closes it unless it's the one of
+// the main config. It's called with mu held.
+func (p *profileLCD) stopApplet() {
+	p.gen++
+	p.runner.Stop()
+	if closer, ok := p.applet.(io.Closer); ok && p.applet != p.main {
+		if err := closer.Close(); err != nil {
+			fmt.Fprintln(os.Stderr, i18n.Sprintf("error closing LCD applet: %s", err))
+		}
+	}
+	p.applet = nil
+	p.runner = nil
+}
+
+// update switches to the content of the active profile, if it isn't shown
+// already. It's called whenever the profile may have changed.
+func (p *profileLCD) update(g13cfg *config.G13Config, active string) error {
+	var shown *config.G13Config
+	if profileCfg := g13cfg.WithProfileLCD(active); profileCfg != g13cfg {
+		shown = profileCfg
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if shown == p.shown {
+		return nil
+	}
+	p.stopApplet()
+	p.shown = shown
+	switch {
+	case shown != nil:
+		return p.show(shown)
+	case p.main != nil:
+		p.start(p.main, p.mainInterval)
+		return nil
+	}
+
+	// the main config has no applet, or it was disabled at startup
+	dev := p.devRef.get()
+	if dev == nil {
+		return nil
+	}
+	img, err := g13cfg.GetLCDImage()
+	if err != nil {
+		return err
+	}
+	if img == nil {
+		return dev.ResetLCD()
+	}
+	return dev.SetLCD(img)
+}
+
+// show shows the image or starts the applet of the profile. It's called with
+// mu held.
+func (p *profileLCD) show(profileCfg *config.G13Config) error {
+	img, err := profileCfg.GetLCDImage()
+	if err != nil {
+		return err
+	}
+	if img != nil {
+		dev := p.devRef.get()
+		if dev == nil {
+			return nil
+		}
+		return dev.SetLCD(img)
+	}
+
+	a, interval, err := lcdAppletOf(profileCfg)
+	if err != nil {
+		return err
+	}
+	if page, ok := a.(*applet.TemplatePage); ok && p.sandboxed && page.RunsCommands() {
+		return fmt.Errorf("template page disabled: commands can't run in the sandbox")
+	}
+	p.start(a, interval)
+	return nil
+}
+
+// redraw shows the image of the profile again on a device that was
+// reinitialised with the content of the main config. Applets draw on it
+// themselves.
+func (p *profileLCD) redraw() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.shown == nil || p.applet != nil {
+		return nil
+	}
+	return p.show(p.shown)
+}
+
+// stop stops the running applet.
+func (p *profileLCD) stop() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.stopApplet()
+}
+
+// updateLCD updates the content of the LCD for the active profile, printing
+// errors: the profile is switched either way.
+func updateLCD(lcdContent *profileLCD, g13cfg *config.G13Config, active string) {
+	if err := lcdContent.update(g13cfg, active); err != nil {
+		fmt.Fprintln(os.Stderr, i18n.Sprintf("error showing the LCD content of the profile: %s", err))
+	}
+}
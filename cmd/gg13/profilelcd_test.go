@@ -0,0 +1,130 @@
+package main
+
+import (
+	"image"
+	"image/draw"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/achilleas-k/gg13/internal/config"
+	"github.com/achilleas-k/gg13/internal/device"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testLCDDevice records the LCD content set or written on it from any
+// goroutine. Calling any other method panics.
+type testLCDDevice struct {
+	device.Device
+
+	mu     sync.Mutex
+	lcd    image.Image
+	resets int
+}
+
+func (d *testLCDDevice) SetLCD(img image.Image) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.lcd = img
+	return nil
+}
+
+func (d *testLCDDevice) WriteFrame(frame []byte) error {
+	fb, err := device.ParseFrame(frame)
+	if err != nil {
+		return err
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.lcd = fb
+	return nil
+}
+
+func (d *testLCDDevice) ResetLCD() error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.lcd = nil
+	d.resets++
+	return nil
+}
+
+func (d *testLCDDevice) get() (image.Image, int) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.lcd, d.resets
+}
+
+func TestProfileLCD(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	dir := t.TempDir()
+	cfgPath := filepath.Join(dir, "config.json")
+	require.NoError(os.WriteFile(filepath.Join(dir, "game.svg"), []byte(`<svg><rect width="10" height="10"/></svg>`), 0o600))
+	require.NoError(os.WriteFile(filepath.Join(dir, "notes.txt"), []byte("notes"), 0o600))
+	require.NoError(os.WriteFile(cfgPath, []byte(`{
+		"profiles":{
+			"game":{"key":"M1","image_file":"game.svg"},
+			"notes":{"key":"M2","text_file":"notes.txt"},
+			"plain":{"key":"M3"}
+		}
+	}`), 0o600))
+	cfg, err := config.NewFromFile(cfgPath)
+	require.NoError(err)
+
+	dev := &testLCDDevice{}
+	devRef := &deviceRef{dev: dev}
+	lcdContent := newProfileLCD(devRef, cfg, nil, 0, false)
+	defer lcdContent.stop()
+
+	// the main config is shown already
+	require.NoError(lcdContent.update(cfg, ""))
+	img, resets := dev.get()
+	assert.Nil(img)
+	assert.Equal(0, resets)
+
+	require.NoError(lcdContent.update(cfg, "game"))
+	gameImg, err := cfg.WithProfileLCD("game").GetLCDImage()
+	require.NoError(err)
+	img, _ = dev.get()
+	assert.Equal(gameImg, img)
+
+	// the applet of the profile draws on the LCD through the framebuffer
+	// until it's switched away
+	require.NoError(lcdContent.update(cfg, "notes"))
+	notesApplet, _, err := lcdAppletOf(cfg.WithProfileLCD("notes"))
+	require.NoError(err)
+	defer notesApplet.(io.Closer).Close()
+	notesImg, err := notesApplet.Render()
+	require.NoError(err)
+	require.Eventually(func() bool {
+		img, _ := dev.get()
+		_, ok := img.(*device.Framebuffer)
+		return ok
+	}, time.Second, time.Millisecond)
+	img, _ = dev.get()
+	expected := device.NewFramebuffer()
+	draw.Draw(expected, expected.Bounds(), notesImg, image.Point{}, draw.Src)
+	assert.Equal(expected, img)
+
+	// a profile without content shows the one of the main config, which
+	// has none
+	require.NoError(lcdContent.update(cfg, "plain"))
+	img, resets = dev.get()
+	assert.Nil(img)
+	assert.Equal(1, resets)
+	require.NoError(lcdContent.update(cfg, config.MainProfile))
+	_, resets = dev.get()
+	assert.Equal(1, resets)
+
+	// the image of the profile is shown again on a reinitialised device
+	require.NoError(lcdContent.update(cfg, "game"))
+	require.NoError(dev.ResetLCD())
+	require.NoError(lcdContent.redraw())
+	img, _ = dev.get()
+	assert.Equal(gameImg, img)
+}
@@ -0,0 +1,142 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/achilleas-k/gg13/internal/config"
+	"github.com/achilleas-k/gg13/internal/control"
+	"github.com/spf13/cobra"
+)
+
+// profileSwitchTimeout limits how long a control request waits for the input
+// loop to switch the profile.
+const profileSwitchTimeout = 3 * time.Second
+
+// profileStatus is the reply to the profile control command.
+type profileStatus struct {
+	Active    string   `json:"active"`
+	Available []string `json:"available"`
+}
+
+// profileRequest asks the input loop to switch to the named profile, or only
+// for the status if the name is empty. The loop sends the outcome on result.
+type profileRequest struct {
+	name   string
+	result chan profileReply
+}
+
+type profileReply struct {
+	status profileStatus
+	err    error
+}
+
+// profileSwitcher passes requests to switch profiles by name to the input
+// loop, which owns the active profile.
+type profileSwitcher struct {
+	requests chan profileRequest
+}
+
+func newProfileSwitcher() *profileSwitcher {
+	return &profileSwitcher{requests: make(chan profileRequest)}
+}
+
+// request asks the input loop to switch to the named profile and waits for
+// the status after it's done.
+func (s *profileSwitcher) request(name string) (profileStatus, error) {
+	// buffered so the loop doesn't block if the request timed out
+	req := profileRequest{name: name, result: make(chan profileReply, 1)}
+	timeout := time.After(profileSwitchTimeout)
+	select {
+	case s.requests <- req:
+	case <-timeout:
+		return profileStatus{}, fmt.Errorf("timed out waiting for the input loop: is the device being reinitialised?")
+	}
+	select {
+	case reply := <-req.result:
+		return reply.status, reply.err
+	case <-timeout:
+		return profileStatus{}, fmt.Errorf("timed out waiting for the profile to switch")
+	}
+}
+
+// handle runs the request in the input loop and replies to it.
+func (req profileRequest) handle(actions *actionDispatcher, g13cfg *config.G13Config) {
+	var reply profileReply
+	if req.name != "" {
+		reply.err = actions.switchProfile(req.name, g13cfg)
+	}
+	reply.status.Active = actions.activeProfile()
+	if reply.status.Active == "" {
+		reply.status.Active = config.MainProfile
+	}
+	reply.status.Available = []string{config.MainProfile}
+	for _, profile := range g13cfg.GetProfiles() {
+		reply.status.Available = append(reply.status.Available, profile.Name)
+	}
+	req.result <- reply
+}
+
+// handleProfile registers the control command for showing and switching the
+// profile by name.
+func handleProfile(server *control.Server, profiles *profileSwitcher) {
+	server.Handle("profile", func(args []string) (any, error) {
+		if len(args) > 1 {
+			return nil, fmt.Errorf("profile: expected at most one argument, got %d", len(args))
+		}
+		var name string
+		if len(args) == 1 {
+			name = args[0]
+			if name == "" {
+				return nil, fmt.Errorf("profile: name can't be empty")
+			}
+		}
+		status, err := profiles.request(name)
+		if err != nil {
+			return nil, fmt.Errorf("profile: %w", err)
+		}
+		return status, nil
+	})
+}
+
+func ctlProfile(cmd *cobra.Command, args []string) error {
+	cmd.SilenceUsage = true
+
+	data, err := sendControl(cmd, control.Request{Command: "profile", Args: args})
+	if err != nil {
+		return err
+	}
+
+	var status profileStatus
+	if err := json.Unmarshal(data, &status); err != nil {
+		return fmt.Errorf("failed decoding profile status: %w", err)
+	}
+	fmt.Printf("active:    %s\n", status.Active)
+	fmt.Printf("available: %s\n", strings.Join(status.Available, ", "))
+	return nil
+}
+
+// completeProfile completes the name of a profile of the running instance.
+// Nothing is completed if it isn't running.
+func completeProfile(cmd *cobra.Command, args []string, _ string) ([]string, cobra.ShellCompDirective) {
+	if len(args) > 0 {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+	return runningProfiles(cmd), cobra.ShellCompDirectiveNoFileComp
+}
+
+// runningProfiles returns the names of the profiles of the running instance,
+// or nil if it can't be asked for them.
+func runningProfiles(cmd *cobra.Command) []string {
+	data, err := sendControl(cmd, control.Request{Command: "profile"})
+	if err != nil {
+		return nil
+	}
+	var status profileStatus
+	if err := json.Unmarshal(data, &status); err != nil {
+		return nil
+	}
+	return status.Available
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"bytes"
+	"path/filepath"
+	"testing"
+
+	"github.com/achilleas-k/gg13/internal/control"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestProfileRequest(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	cfg := loadTestConfig(t, `{"profiles":{"game":{"key":"M1"},"music":{}}}`)
+
+	dispatcher := &actionDispatcher{}
+	request := func(name string) profileReply {
+		req := profileRequest{name: name, result: make(chan profileReply, 1)}
+		req.handle(dispatcher, cfg)
+		return <-req.result
+	}
+
+	reply := request("")
+	require.NoError(reply.err)
+	assert.Equal(profileStatus{Active: "main", Available: []string{"main", "game", "music"}}, reply.status)
+
+	// profiles without a key are switched to by name
+	reply = request("music")
+	require.NoError(reply.err)
+	assert.Equal("music", reply.status.Active)
+	assert.Same(cfg.WithProfile("music"), dispatcher.outputConfig(cfg))
+
+	reply = request("nope")
+	assert.EqualError(reply.err, "unknown profile: nope")
+	assert.Equal("music", reply.status.Active)
+
+	reply = request("main")
+	require.NoError(reply.err)
+	assert.Equal("main", reply.status.Active)
+	assert.Same(cfg, dispatcher.outputConfig(cfg))
+}
+
+func TestCompleteProfile(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	socketPath := filepath.Join(t.TempDir(), "gg13.sock")
+	complete := func(args ...string) string {
+		cmd := mkcmd()
+		var out bytes.Buffer
+		cmd.SetOut(&out)
+		cmd.SetArgs(append([]string{"__complete", "--socket", socketPath, "ctl", "profile"}, args...))
+		require.NoError(cmd.Execute())
+		return out.String()
+	}
+
+	// nothing is completed without a running instance
+	assert.Equal(":4\n", complete(""))
+
+	server, err := control.NewServer(socketPath)
+	require.NoError(err)
+	defer server.Close()
+	profiles := newProfileSwitcher()
+	defer close(profiles.requests)
+	handleProfile(server, profiles)
+	cfg := loadTestConfig(t, `{"profiles":{"game":{"key":"M1"},"music":{}}}`)
+	go func() {
+		for req := range profiles.requests {
+			req.handle(&actionDispatcher{}, cfg)
+		}
+	}()
+
+	assert.Equal("main\ngame\nmusic\n:4\n", complete(""))
+	assert.Equal(":4\n", complete("game", ""))
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"os"
+
+	"github.com/achilleas-k/gg13/internal/i18n"
+	"github.com/achilleas-k/gg13/internal/output"
+	"github.com/spf13/cobra"
+)
+
+// outputTokenEnv is the environment variable holding the token that the
+// network output authenticates to the receiver with, unless
+// --output-token-file is set. It's separate from the remote control token,
+// so a receiver can't control the driver.
+const outputTokenEnv = "GG13_OUTPUT_TOKEN"
+
+// readOutputToken returns the token of the network output, read from the file set
+// with --output-token-file or from the environment.
+func readOutputToken(cmd *cobra.Command) (string, error) {
+	return readToken(cmd, "output-token-file", outputTokenEnv)
+}
+
+func mkReceiveCmd() *cobra.Command {
+	receiveCmd := &cobra.Command{
+		Use:   "receive",
+		Short: "Receive the output of a gg13 instance on another machine using the network output",
+		Long: `Receive the output of a gg13 instance on another machine using the network
+output and send it to local devices, for example to use the G13 with a second
+PC or a virtual machine. Senders authenticate with the token set with
+--output-token-file or $` + outputTokenEnv + `, which has to be the same on both
+ends.
+
+Nothing is encrypted, not even the token: anyone on the network can read every
+key typed on the G13 and, with the token, type on this machine. Only receive
+on a trusted network, or connect the machines through an encrypted tunnel,
+like SSH port forwarding or WireGuard, and listen on its address.`,
+		Args: cobra.NoArgs,
+		RunE: receive,
+	}
+	receiveCmd.Flags().String("listen", "", "TCP address to accept connections on, e.g. :7313")
+	receiveCmd.Flags().String("output", output.DefaultSink, "output backend to send the received events to")
+	receiveCmd.Flags().String("output-token-file", "", "file containing the token that senders authenticate with (default: $"+outputTokenEnv+")")
+	_ = receiveCmd.MarkFlagRequired("listen")
+	return receiveCmd
+}
+
+func receive(cmd *cobra.Command, _ []string) error {
+	cmd.SilenceUsage = true
+
+	addr, err := cmd.Flags().GetString("listen")
+	if err != nil {
+		return err
+	}
+	backend, err := cmd.Flags().GetString("output")
+	if err != nil {
+		return err
+	}
+	token, err := readOutputToken(cmd)
+	if err != nil {
+		return err
+	}
+	if token == "" {
+		return fmt.Errorf("a token is required for receiving output: set %s or use --output-token-file", outputTokenEnv)
+	}
+	if err := output.Validate(backend); err != nil {
+		return err
+	}
+
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return fmt.Errorf("failed to listen on %q: %w", addr, err)
+	}
+	fmt.Println(i18n.Sprintf("Listening on %s", listener.Addr()))
+	fmt.Fprintln(os.Stderr, i18n.T("warning: the output and the token are received unencrypted: only use this on a trusted network"))
+	return output.Receive(listener, token, backend)
+}
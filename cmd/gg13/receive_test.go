@@ -0,0 +1,32 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReadOutputToken(t *testing.T) {
+	t.Setenv(controlTokenEnv, "control")
+	t.Setenv(outputTokenEnv, "")
+
+	cmd := mkReceiveCmd()
+	token, err := readOutputToken(cmd)
+	require.NoError(t, err)
+	assert.Empty(t, token, "the remote control token isn't used")
+
+	t.Setenv(outputTokenEnv, "output")
+	token, err = readOutputToken(cmd)
+	require.NoError(t, err)
+	assert.Equal(t, "output", token)
+
+	tokenFile := filepath.Join(t.TempDir(), "token")
+	require.NoError(t, os.WriteFile(tokenFile, []byte("from-file\n"), 0o600))
+	require.NoError(t, cmd.Flags().Set("output-token-file", tokenFile))
+	token, err = readOutputToken(cmd)
+	require.NoError(t, err)
+	assert.Equal(t, "from-file", token)
+}
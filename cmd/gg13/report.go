@@ -0,0 +1,264 @@
+package main
+
+import (
+	"archive/tar"
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"runtime/debug"
+	"strings"
+	"time"
+
+	"github.com/achilleas-k/gg13/internal/config"
+	"github.com/spf13/cobra"
+)
+
+// defaultReportLogLines is the number of lines from the end of the log that
+// a bug report includes by default.
+const defaultReportLogLines = 200
+
+// usbAttrs are the sysfs attributes of the G13 included in bug reports.
+var usbAttrs = []string{
+	"idVendor", "idProduct", "bcdDevice", "manufacturer", "product",
+	"version", "speed", "bMaxPower", "bNumConfigurations", "busnum", "devnum",
+}
+
+// reportFile is a file in the bug report bundle.
+type reportFile struct {
+	name string
+	data []byte
+}
+
+func mkReportCmd() *cobra.Command {
+	reportCmd := &cobra.Command{
+		Use:   "report [config]",
+		Short: "Collect information for a bug report into a tarball",
+		Long: "Collect the version, the config with values that can identify you or hold secrets removed, the " +
+			"checks of 'gg13 doctor', the USB descriptors of the G13, and the end of the log into a tarball for " +
+			"attaching to an issue. The log isn't kept by the driver: pass the file it's written to, or - to read " +
+			"it from the standard input, for example: journalctl --user -u <unit> | gg13 report --log-file - <config>. " +
+			"Check the contents before sharing them.",
+		Args:              cobra.MaximumNArgs(1),
+		RunE:              writeReport,
+		ValidArgsFunction: completeConfigFile,
+	}
+	reportCmd.Flags().StringP("output", "o", "", "path to write the tarball to (default: gg13-report-<time>.tar.gz)")
+	reportCmd.Flags().String("log-file", "", "file with the driver log, or - for the standard input")
+	reportCmd.Flags().Int("log-lines", defaultReportLogLines, "number of lines from the end of the log to include")
+	return reportCmd
+}
+
+func writeReport(cmd *cobra.Command, args []string) error {
+	cmd.SilenceUsage = true
+
+	outPath, err := cmd.Flags().GetString("output")
+	if err != nil {
+		return err
+	}
+	logPath, err := cmd.Flags().GetString("log-file")
+	if err != nil {
+		return err
+	}
+	logLines, err := cmd.Flags().GetInt("log-lines")
+	if err != nil {
+		return err
+	}
+	if logLines < 0 {
+		return fmt.Errorf("log-lines can't be negative: %d", logLines)
+	}
+	now := time.Now()
+	if outPath == "" {
+		outPath = fmt.Sprintf("gg13-report-%s.tar.gz", now.Format("20060102-150405"))
+	}
+
+	files := []reportFile{
+		{name: "version.txt", data: versionInfo(cmd.Root().Version)},
+		{name: "checks.txt", data: checksInfo(runChecks())},
+		{name: "usb.txt", data: usbInfo(sysUSBDevices, runLsusb)},
+	}
+	if len(args) == 1 {
+		homeDir, _ := os.UserHomeDir()
+		files = append(files, configFiles(args[0], homeDir)...)
+	}
+	if logPath != "" {
+		files = append(files, reportFile{name: "log.txt", data: logTail(logPath, logLines)})
+	}
+
+	out, err := os.OpenFile(outPath, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o600)
+	if err != nil {
+		return fmt.Errorf("failed creating report: %w", err)
+	}
+	defer func() { _ = out.Close() }()
+	if err := writeTarball(out, files, now); err != nil {
+		_ = os.Remove(outPath)
+		return fmt.Errorf("failed writing report: %w", err)
+	}
+	if err := out.Close(); err != nil {
+		_ = os.Remove(outPath)
+		return fmt.Errorf("failed writing report: %w", err)
+	}
+	fmt.Printf("Report written to %s: check its contents before sharing it\n", outPath)
+	return nil
+}
+
+// versionInfo describes the build of the driver and the system it runs on.
+func versionInfo(version string) []byte {
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "version: %s\n", version)
+	if info, ok := debug.ReadBuildInfo(); ok {
+		fmt.Fprintf(&buf, "module:  %s\n", info.Main.Version)
+		for _, setting := range info.Settings {
+			if strings.HasPrefix(setting.Key, "vcs.") {
+				fmt.Fprintf(&buf, "%s: %s\n", setting.Key, setting.Value)
+			}
+		}
+	}
+	fmt.Fprintf(&buf, "go:      %s %s/%s\n", runtime.Version(), runtime.GOOS, runtime.GOARCH)
+	if kernel, err := os.ReadFile("/proc/sys/kernel/osrelease"); err == nil {
+		fmt.Fprintf(&buf, "kernel:  %s\n", strings.TrimSpace(string(kernel)))
+	}
+	return buf.Bytes()
+}
+
+// checksInfo describes the outcome of the prerequisite checks.
+func checksInfo(results []checkResult) []byte {
+	var buf bytes.Buffer
+	writeCheckResults(&buf, results)
+	return buf.Bytes()
+}
+
+// runLsusb returns the verbose output of lsusb for the G13.
+func runLsusb() ([]byte, error) {
+	return exec.Command("lsusb", "-v", "-d", g13VendorID+":"+g13ProductID).CombinedOutput()
+}
+
+// usbInfo describes the connected G13 with its sysfs attributes and raw
+// descriptors, and the output of lsusb, which decodes them, if it runs.
+func usbInfo(sysRoot string, lsusb func() ([]byte, error)) []byte {
+	var buf bytes.Buffer
+	sysDir, err := findUSBSysDir(sysRoot)
+	switch {
+	case err != nil:
+		fmt.Fprintf(&buf, "error: %s\n", err)
+	case sysDir == "":
+		fmt.Fprintln(&buf, "no G13 found")
+	default:
+		fmt.Fprintf(&buf, "sysfs: %s\n", sysDir)
+		for _, attr := range usbAttrs {
+			fmt.Fprintf(&buf, "%s: %s\n", attr, readSysAttr(sysDir, attr))
+		}
+		if descriptors, err := os.ReadFile(filepath.Join(sysDir, "descriptors")); err == nil {
+			fmt.Fprintf(&buf, "descriptors: %x\n", descriptors)
+		}
+	}
+
+	fmt.Fprintln(&buf, "\nlsusb:")
+	output, err := lsusb()
+	if err != nil {
+		fmt.Fprintf(&buf, "error: %s\n", err)
+	}
+	buf.Write(output)
+	return buf.Bytes()
+}
+
+// configFiles returns the sanitised config file, or the JSON files of a
+// config directory. Files that can't be read are replaced with the error.
+func configFiles(configPath, homeDir string) []reportFile {
+	paths := []string{configPath}
+	if info, err := os.Stat(configPath); err == nil && info.IsDir() {
+		matches, err := filepath.Glob(filepath.Join(configPath, "*.json"))
+		if err != nil {
+			return []reportFile{{name: "config/error.txt", data: []byte(err.Error() + "\n")}}
+		}
+		paths = matches
+	}
+
+	files := make([]reportFile, 0, len(paths))
+	for _, path := range paths {
+		name := "config/" + filepath.Base(path)
+		data, err := os.ReadFile(path)
+		if err == nil {
+			data, err = config.Sanitise(data, homeDir)
+		}
+		if err != nil {
+			files = append(files, reportFile{name: name + ".error.txt", data: []byte(err.Error() + "\n")})
+			continue
+		}
+		files = append(files, reportFile{name: name, data: data})
+	}
+	return files
+}
+
+// logTail returns the last n lines of the log file, or of the standard input
+// for "-", or the error reading it.
+func logTail(path string, n int) []byte {
+	var r io.Reader = os.Stdin
+	if path != "-" {
+		file, err := os.Open(path)
+		if err != nil {
+			return []byte(fmt.Sprintf("error: %s\n", err))
+		}
+		defer func() { _ = file.Close() }()
+		r = file
+	}
+	data, err := tailLines(r, n)
+	if err != nil {
+		data = fmt.Appendf(data, "error: %s\n", err)
+	}
+	return data
+}
+
+// tailLines returns the last n lines read from r.
+func tailLines(r io.Reader, n int) ([]byte, error) {
+	if n == 0 {
+		return nil, nil
+	}
+	// ring of the last n lines
+	lines := make([]string, n)
+	count := 0
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(nil, 1<<20)
+	for scanner.Scan() {
+		lines[count%n] = scanner.Text()
+		count++
+	}
+
+	var buf bytes.Buffer
+	for idx := max(count-n, 0); idx < count; idx++ {
+		buf.WriteString(lines[idx%n])
+		buf.WriteByte('\n')
+	}
+	return buf.Bytes(), scanner.Err()
+}
+
+// writeTarball writes the files into a gzipped tarball, in a directory named
+// after the time of the report.
+func writeTarball(w io.Writer, files []reportFile, modTime time.Time) error {
+	dir := "gg13-report-" + modTime.Format("20060102-150405")
+	gz := gzip.NewWriter(w)
+	tw := tar.NewWriter(gz)
+	for _, file := range files {
+		hdr := &tar.Header{
+			Name:    dir + "/" + file.name,
+			Mode:    0o644,
+			Size:    int64(len(file.data)),
+			ModTime: modTime,
+		}
+		if err := tw.WriteHeader(hdr); err != nil {
+			return err
+		}
+		if _, err := tw.Write(file.data); err != nil {
+			return err
+		}
+	}
+	if err := tw.Close(); err != nil {
+		return err
+	}
+	return gz.Close()
+}
@@ -0,0 +1,223 @@
+package main
+
+import (
+	"fmt"
+	"image"
+	"os"
+	"reflect"
+	"sync"
+	"time"
+
+	"github.com/achilleas-k/gg13/internal/device"
+	"github.com/achilleas-k/gg13/internal/i18n"
+	"github.com/achilleas-k/gg13/internal/state"
+	"github.com/spf13/cobra"
+)
+
+// stateSaveDelay is how long the state is kept before it's saved after the
+// output changes, so that the frames of an applet or a flashing backlight
+// are saved once.
+const stateSaveDelay = time.Second
+
+// statefulDevice records the output applied to the device in the state file,
+// and removes the file when the device is closed cleanly.
+type statefulDevice struct {
+	device.Device
+
+	path  string
+	delay time.Duration
+
+	mu    sync.Mutex
+	state state.State
+
+	// the state in the file, to skip saving it again; the fields of state
+	// are replaced on every change, never modified, so it can share them
+	saved state.State
+
+	// saves the state after the delay; nil if no change is waiting for it
+	saveTimer *time.Timer
+
+	closed bool
+}
+
+// newStatefulDevice wraps the device to record its output in the state file
+// at path. An empty path disables it and returns the device as is.
+func newStatefulDevice(dev device.Device, path string) device.Device {
+	if path == "" {
+		return dev
+	}
+	return &statefulDevice{Device: dev, path: path, delay: stateSaveDelay}
+}
+
+func (d *statefulDevice) SetBacklightColour(r, g, b uint8) error {
+	if err := d.Device.SetBacklightColour(r, g, b); err != nil {
+		return err
+	}
+	d.update(func(s *state.State) error {
+		s.Backlight = &[3]uint8{r, g, b}
+		return nil
+	})
+	return nil
+}
+
+func (d *statefulDevice) SetLCD(img image.Image) error {
+	if err := d.Device.SetLCD(img); err != nil {
+		return err
+	}
+	d.update(func(s *state.State) error {
+		return s.SetLCD(img)
+	})
+	return nil
+}
+
+func (d *statefulDevice) WriteFrame(frame []byte) error {
+	if err := d.Device.WriteFrame(frame); err != nil {
+		return err
+	}
+	d.update(func(s *state.State) error {
+		fb, err := device.ParseFrame(frame)
+		if err != nil {
+			return err
+		}
+		return s.SetLCD(fb)
+	})
+	return nil
+}
+
+func (d *statefulDevice) ResetLCD() error {
+	if err := d.Device.ResetLCD(); err != nil {
+		return err
+	}
+	d.update(func(s *state.State) error {
+		s.LCD = nil
+		return nil
+	})
+	return nil
+}
+
+func (d *statefulDevice) Close() {
+	d.Device.Close()
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	// nothing to restore after a clean shutdown
+	d.closed = true
+	if d.saveTimer != nil {
+		d.saveTimer.Stop()
+		d.saveTimer = nil
+	}
+	if err := state.Remove(d.path); err != nil {
+		fmt.Fprintln(os.Stderr, i18n.Sprintf("error during shutdown: %s", err))
+	}
+}
+
+// update modifies the recorded state and saves it after the delay, along
+// with the changes made until then. The delay isn't restarted, so output
+// that keeps changing is still saved. Failing to save doesn't affect the
+// device, so errors are only reported.
+func (d *statefulDevice) update(modify func(*state.State) error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.closed {
+		return
+	}
+
+	if err := modify(&d.state); err != nil {
+		fmt.Fprintln(os.Stderr, i18n.Sprintf("error recording device state: %s", err))
+		return
+	}
+	if d.saveTimer == nil {
+		d.saveTimer = time.AfterFunc(d.delay, d.flush)
+	}
+}
+
+// flush saves the state, if it changed since it was last saved.
+func (d *statefulDevice) flush() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.saveTimer != nil {
+		d.saveTimer.Stop()
+		d.saveTimer = nil
+	}
+	if d.closed || reflect.DeepEqual(d.state, d.saved) {
+		return
+	}
+	if err := d.state.Save(d.path); err != nil {
+		fmt.Fprintln(os.Stderr, i18n.Sprintf("error recording device state: %s", err))
+		return
+	}
+	d.saved = d.state
+}
+
+// outputDevice is the part of [device.Device] that the state is restored to.
+type outputDevice interface {
+	SetBacklightColour(r, g, b uint8) error
+	SetLCD(img image.Image) error
+}
+
+// restoreState applies the recorded state to the device.
+func restoreState(dev outputDevice, s *state.State) error {
+	if s.Backlight != nil {
+		colour := *s.Backlight
+		if err := dev.SetBacklightColour(colour[0], colour[1], colour[2]); err != nil {
+			return err
+		}
+	}
+
+	img, err := s.LCDImage()
+	if err != nil {
+		return err
+	}
+	if img != nil {
+		if err := dev.SetLCD(img); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func mkRestoreCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:                   "restore",
+		Short:                 "Re-apply the backlight and LCD state left by an unclean shutdown",
+		Long:                  "Re-apply the backlight colour and LCD image recorded in the state file by an instance that didn't shut down cleanly. The device keeps showing them after the command exits.",
+		Args:                  cobra.NoArgs,
+		RunE:                  restore,
+		DisableFlagsInUseLine: true,
+	}
+}
+
+func restore(cmd *cobra.Command, args []string) error {
+	cmd.SilenceUsage = true
+
+	statePath, err := cmd.Flags().GetString("state-file")
+	if err != nil {
+		return err
+	}
+	if statePath == "" {
+		return fmt.Errorf("no state file set")
+	}
+
+	s, err := state.Load(statePath)
+	if err != nil {
+		return err
+	}
+	if s == nil {
+		fmt.Printf("Nothing to restore: %s does not exist\n", statePath)
+		return nil
+	}
+
+	dev, err := device.New()
+	if err != nil {
+		err = fmt.Errorf("device initialisation failed: %w", err)
+		return err
+	}
+	// keep showing the restored output after exiting
+	defer dev.Release()
+
+	if err := restoreState(dev, s); err != nil {
+		return fmt.Errorf("failed restoring device state: %w", err)
+	}
+	fmt.Printf("Restored device state from %s\n", statePath)
+	return nil
+}
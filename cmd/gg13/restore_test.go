@@ -0,0 +1,121 @@
+package main
+
+import (
+	"image"
+	"image/color"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/achilleas-k/gg13/internal/device"
+	"github.com/achilleas-k/gg13/internal/state"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testOutputDevice implements the output methods of [device.Device]. Calling
+// any other method panics.
+type testOutputDevice struct {
+	device.Device
+
+	backlight [3]uint8
+	lcd       image.Image
+	closed    bool
+}
+
+func (d *testOutputDevice) SetBacklightColour(r, g, b uint8) error {
+	d.backlight = [3]uint8{r, g, b}
+	return nil
+}
+
+func (d *testOutputDevice) SetLCD(img image.Image) error {
+	d.lcd = img
+	return nil
+}
+
+func (d *testOutputDevice) WriteFrame(frame []byte) error {
+	fb, err := device.ParseFrame(frame)
+	d.lcd = fb
+	return err
+}
+
+func (d *testOutputDevice) ResetLCD() error {
+	d.lcd = nil
+	return nil
+}
+
+func (d *testOutputDevice) Close() {
+	d.closed = true
+}
+
+func TestStatefulDevice(t *testing.T) {
+	assert := assert.New(t)
+	statePath := filepath.Join(t.TempDir(), "state.json")
+
+	testDev := &testOutputDevice{}
+	dev := newStatefulDevice(testDev, statePath)
+	// saved when flushed
+	statefulDev := dev.(*statefulDevice)
+	statefulDev.delay = time.Hour
+
+	img := image.NewGray(image.Rect(0, 0, device.LCDWidth, device.LCDHeight))
+	img.Pix[10] = 255
+	require.NoError(t, dev.SetBacklightColour(10, 20, 30))
+	require.NoError(t, dev.SetLCD(img))
+	assert.NoFileExists(statePath)
+	statefulDev.flush()
+
+	// the state left by a crash is restored to the new device
+	saved, err := state.Load(statePath)
+	require.NoError(t, err)
+	require.NotNil(t, saved)
+	newDev := &testOutputDevice{}
+	require.NoError(t, restoreState(newDev, saved))
+	assert.Equal([3]uint8{10, 20, 30}, newDev.backlight)
+	assert.Equal(img.Pix, newDev.lcd.(*image.Gray).Pix)
+
+	// frames written directly are recorded as images
+	fb := device.NewFramebuffer()
+	fb.Set(3, 4, color.Black)
+	require.NoError(t, fb.Flush(dev))
+	statefulDev.flush()
+	saved, err = state.Load(statePath)
+	require.NoError(t, err)
+	savedImg, err := saved.LCDImage()
+	require.NoError(t, err)
+	assert.Equal(fb.At(3, 4), savedImg.At(3, 4))
+	assert.Equal(fb.At(4, 4), savedImg.At(4, 4))
+
+	require.NoError(t, dev.ResetLCD())
+	statefulDev.flush()
+	saved, err = state.Load(statePath)
+	require.NoError(t, err)
+	assert.Empty(saved.LCD)
+
+	// the same output isn't saved again
+	require.NoError(t, os.Remove(statePath))
+	require.NoError(t, dev.SetBacklightColour(10, 20, 30))
+	statefulDev.flush()
+	assert.NoFileExists(statePath)
+
+	// changes are saved after the delay
+	statefulDev.delay = time.Millisecond
+	require.NoError(t, dev.SetBacklightColour(40, 50, 60))
+	require.Eventually(t, func() bool {
+		saved, err := state.Load(statePath)
+		return err == nil && saved != nil && *saved.Backlight == [3]uint8{40, 50, 60}
+	}, time.Second, time.Millisecond)
+
+	// a clean shutdown removes the state, which isn't saved again after it
+	statefulDev.delay = time.Hour
+	require.NoError(t, dev.SetBacklightColour(70, 80, 90))
+	dev.Close()
+	assert.True(testDev.closed)
+	assert.NoFileExists(statePath)
+	statefulDev.flush()
+	assert.NoFileExists(statePath)
+
+	// an empty path disables recording
+	assert.Same(testDev, newStatefulDevice(testDev, ""))
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/achilleas-k/gg13/internal/config"
+	"github.com/achilleas-k/gg13/internal/device"
+	"github.com/achilleas-k/gg13/internal/joystick"
+)
+
+// forceFeedbackReporter is implemented by joysticks reporting the force
+// feedback effects played by games.
+type forceFeedbackReporter interface {
+	OnForceFeedback(f func(joystick.FFEvent))
+}
+
+// handleRumble shows the rumble colour set in the config on the backlight
+// while a game plays a force feedback effect on the joystick, if the
+// joystick reports them. When the effect stops, the colour is only restored
+// if the rumble override is still showing, so that it doesn't cancel a flash
+// or the pause colour that replaced it.
+func handleRumble(vjs joystick.Joystick, g13cfg *config.G13Config, devRef *deviceRef) {
+	reporter, ok := vjs.(forceFeedbackReporter)
+	if !ok {
+		return
+	}
+	colour, ok := g13cfg.GetRumbleColour()
+	if !ok {
+		return
+	}
+
+	// the device showing the rumble colour and the number of the override;
+	// effects are reported from a single goroutine
+	var overridden device.Device
+	var override uint64
+	reporter.OnForceFeedback(func(ev joystick.FFEvent) {
+		dev := devRef.get()
+		if dev == nil {
+			return
+		}
+		if !ev.Playing {
+			if dev != overridden {
+				// reinitialised since, the override is gone with the old
+				// device
+				return
+			}
+			overridden = nil
+			if err := dev.EndBacklightOverride(override); err != nil {
+				fmt.Fprintf(hotPathErrors, "error restoring backlight: %s\n", err)
+			}
+			return
+		}
+		// effects without a length are shown until they're stopped
+		id, err := dev.OverrideBacklightColour(colour[0], colour[1], colour[2], ev.Length)
+		if err != nil {
+			fmt.Fprintf(hotPathErrors, "error flashing backlight: %s\n", err)
+			return
+		}
+		overridden, override = dev, id
+	})
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/achilleas-k/gg13/internal/config"
+	"github.com/achilleas-k/gg13/internal/device"
+	"github.com/achilleas-k/gg13/internal/joystick"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testFFJoystick is a joystick reporting force feedback effects.
+type testFFJoystick struct {
+	TestJoystick
+	onFF func(joystick.FFEvent)
+}
+
+func (js *testFFJoystick) OnForceFeedback(f func(joystick.FFEvent)) {
+	js.onFF = f
+}
+
+// testRumbleDevice records the backlight overrides of a [device.Device].
+type testRumbleDevice struct {
+	device.Device
+	overrider testOverrider
+}
+
+func (d *testRumbleDevice) OverrideBacklightColour(r, g, b uint8, dt time.Duration) (uint64, error) {
+	return d.overrider.OverrideBacklightColour(r, g, b, dt)
+}
+
+func (d *testRumbleDevice) ClearBacklightOverride() error {
+	return d.overrider.ClearBacklightOverride()
+}
+
+func (d *testRumbleDevice) EndBacklightOverride(id uint64) error {
+	return d.overrider.EndBacklightOverride(id)
+}
+
+func TestHandleRumble(t *testing.T) {
+	assert := assert.New(t)
+
+	cfg := loadTestConfig(t, `{"mapping":{"stick":{"mode":"joystick","rumble_colour":"#ff8000"}}}`)
+
+	dev := &testRumbleDevice{}
+	devRef := &deviceRef{}
+	devRef.set(dev)
+
+	vjs := &testFFJoystick{}
+	handleRumble(vjs, cfg, devRef)
+	require.NotNil(t, vjs.onFF)
+
+	vjs.onFF(joystick.FFEvent{Playing: true, Length: 200 * time.Millisecond})
+	vjs.onFF(joystick.FFEvent{Playing: false})
+	vjs.onFF(joystick.FFEvent{Playing: true})
+
+	expected := []overrideEvent{
+		{action: "override", colour: [3]uint8{0xff, 0x80, 0}, dt: 200 * time.Millisecond},
+		{action: "clear"},
+		{action: "override", colour: [3]uint8{0xff, 0x80, 0}},
+	}
+	assert.Equal(expected, dev.overrider.events)
+
+	// an override that replaced the rumble colour stays when the effect
+	// stops
+	_, err := dev.OverrideBacklightColour(0, 0, 0xff, 0)
+	require.NoError(t, err)
+	vjs.onFF(joystick.FFEvent{Playing: false})
+	expected = append(expected, overrideEvent{action: "override", colour: [3]uint8{0, 0, 0xff}})
+	assert.Equal(expected, dev.overrider.events)
+
+	// neither does the override of a device that was reinitialised since,
+	// even with the same number
+	vjs.onFF(joystick.FFEvent{Playing: true})
+	newDev := &testRumbleDevice{overrider: testOverrider{last: dev.overrider.last - 1}}
+	devRef.set(newDev)
+	_, err = newDev.OverrideBacklightColour(0, 0, 0xff, 0)
+	require.NoError(t, err)
+	vjs.onFF(joystick.FFEvent{Playing: false})
+	assert.Equal([]overrideEvent{{action: "override", colour: [3]uint8{0, 0, 0xff}}}, newDev.overrider.events)
+
+	// nothing to do without a colour
+	vjs = &testFFJoystick{}
+	handleRumble(vjs, config.NewEmpty(), devRef)
+	assert.Nil(vjs.onFF)
+}
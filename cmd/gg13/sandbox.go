@@ -0,0 +1,80 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/achilleas-k/gg13/internal/device"
+	"github.com/achilleas-k/gg13/internal/sandbox"
+)
+
+// sandboxPaths returns the paths the daemon needs after startup: the devices
+// for reinitialising after a disconnect, the config directory for reloading,
+// the directories of the files it reads while running, and the directories of
+// the files it writes while running. Empty paths are skipped. Images
+// referenced by the config must be in the config directory to be reloaded.
+func sandboxPaths(configPath string, readFiles []string, runtimeFiles ...string) sandbox.Paths {
+	configDir := filepath.Dir(configPath)
+	if info, err := os.Stat(configPath); err == nil && info.IsDir() {
+		configDir = configPath
+	}
+	if abs, err := filepath.Abs(configDir); err == nil {
+		configDir = abs
+	}
+
+	paths := sandbox.Paths{
+		ReadOnly: []string{
+			configDir,
+			// libusb enumerates devices through sysfs
+			"/sys",
+			// name resolution and certificates for MQTT and applets
+			"/etc",
+			// system statistics for LCD template pages
+			"/proc/stat",
+			"/proc/meminfo",
+		},
+		ReadWrite: []string{
+			devUSB,
+			uinputPath,
+			device.LockDir(),
+		},
+	}
+	for _, path := range readFiles {
+		if path != "" {
+			paths.ReadOnly = append(paths.ReadOnly, filepath.Dir(path))
+		}
+	}
+	for _, path := range runtimeFiles {
+		if path != "" {
+			paths.ReadWrite = append(paths.ReadWrite, filepath.Dir(path))
+		}
+	}
+	return paths
+}
+
+// sandboxWarning returns the warning for a sandbox that was only partly
+// applied, with how to get the rest where a rebuild helps.
+func sandboxWarning(err error) string {
+	warning := fmt.Sprintf("warning: the sandbox is incomplete: %s", err)
+	if errors.Is(err, sandbox.ErrCgo) {
+		warning += "\nfile access is NOT restricted, only the seccomp filter is applied: " +
+			"build gg13 without cgo for landlock: CGO_ENABLED=0 go build -tags usbfs ./cmd/gg13"
+	}
+	return warning
+}
+
+// applySandbox sandboxes the daemon. A mechanism being unavailable is only a
+// warning, since the other one is still applied.
+func applySandbox(configPath string, readFiles []string, runtimeFiles ...string) error {
+	err := sandbox.Apply(sandboxPaths(configPath, readFiles, runtimeFiles...))
+	if errors.Is(err, sandbox.ErrUnsupported) {
+		fmt.Fprintln(os.Stderr, sandboxWarning(err))
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("sandboxing failed: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,37 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/achilleas-k/gg13/internal/sandbox"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSandboxPaths(t *testing.T) {
+	paths := sandboxPaths("configs/default.json", []string{"/home/user/status.txt", ""}, "/run/user/1000/gg13.sock", "", "/home/user/.local/state/gg13/stats.json")
+
+	cwd, err := os.Getwd()
+	require.NoError(t, err)
+	assert.Contains(t, paths.ReadOnly, filepath.Join(cwd, "configs"))
+	assert.Contains(t, paths.ReadOnly, "/home/user")
+	assert.Len(t, paths.ReadOnly, 6, "empty read path added")
+	assert.Contains(t, paths.ReadWrite, devUSB)
+	assert.Contains(t, paths.ReadWrite, uinputPath)
+	assert.Contains(t, paths.ReadWrite, "/run/user/1000")
+	assert.Contains(t, paths.ReadWrite, "/home/user/.local/state/gg13")
+	assert.Len(t, paths.ReadWrite, 5, "empty state path added")
+}
+
+func TestSandboxWarning(t *testing.T) {
+	cgoErr := fmt.Errorf("landlock: %w: %w", sandbox.ErrUnsupported, sandbox.ErrCgo)
+	assert.Equal(t, "warning: the sandbox is incomplete: landlock: not supported: binaries built with cgo can't restrict every thread\n"+
+		"file access is NOT restricted, only the seccomp filter is applied: build gg13 without cgo for landlock: CGO_ENABLED=0 go build -tags usbfs ./cmd/gg13",
+		sandboxWarning(cgoErr))
+
+	kernelErr := fmt.Errorf("landlock: %w by the kernel", sandbox.ErrUnsupported)
+	assert.Equal(t, "warning: the sandbox is incomplete: landlock: not supported by the kernel", sandboxWarning(kernelErr))
+}
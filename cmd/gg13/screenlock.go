@@ -0,0 +1,160 @@
+package main
+
+import (
+	"fmt"
+	"image"
+	"os"
+	"sync"
+
+	"github.com/achilleas-k/gg13/internal/config"
+	"github.com/achilleas-k/gg13/internal/device"
+	"github.com/achilleas-k/gg13/internal/i18n"
+	"github.com/achilleas-k/gg13/internal/lcd"
+)
+
+// screenLock blanks the LCD while the graphical session of the user is
+// locked, so nothing shown on it is readable from the lock screen. Output
+// stops meanwhile, or follows the screen lock profile if the config sets one. The
+// content that the config, applets and the control socket set on the LCD is
+// held back while locked and the latest of it is restored on unlock. A nil
+// *screenLock is never locked.
+type screenLock struct {
+	mu sync.Mutex
+	// the device the LCD is blanked on, nil while it's being reinitialised
+	dev device.Device
+	// sets the last content of the LCD again
+	last   func() error
+	locked bool
+}
+
+// wrap returns the device with its LCD content held back while the screen is
+// locked. The LCD is blanked on it from now on.
+func (s *screenLock) wrap(dev device.Device) device.Device {
+	if s == nil {
+		return dev
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.dev = dev
+	s.last = nil
+	if s.locked {
+		if err := dev.SetLCD(lcd.TextPage("")); err != nil {
+			fmt.Fprintln(os.Stderr, i18n.Sprintf("error blanking the LCD: %s", err))
+		}
+	}
+	return &screenLockDevice{Device: dev, screen: s}
+}
+
+// isLocked returns true while the screen is locked.
+func (s *screenLock) isLocked() bool {
+	if s == nil {
+		return false
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.locked
+}
+
+// set blanks the LCD when the screen is locked and restores its latest
+// content when it's unlocked. It returns true if the state changed.
+func (s *screenLock) set(locked bool) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if locked == s.locked {
+		return false
+	}
+	s.locked = locked
+	if s.dev == nil {
+		return true
+	}
+
+	var err error
+	switch {
+	case locked:
+		err = s.dev.SetLCD(lcd.TextPage(""))
+	case s.last != nil:
+		err = s.last()
+	default:
+		err = s.dev.ResetLCD()
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, i18n.Sprintf("error updating the LCD for the screen lock: %s", err))
+	}
+	return true
+}
+
+// setContent records the content and sets it, unless the screen is locked.
+func (s *screenLock) setContent(set func() error) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.last = set
+	if s.locked {
+		return nil
+	}
+	return set()
+}
+
+// detach stops blanking the LCD of the device, which is being closed.
+func (s *screenLock) detach(dev device.Device) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.dev != dev {
+		return
+	}
+	s.dev = nil
+	s.last = nil
+}
+
+// screenLockChanged reports that the screen was locked or unlocked. If the
+// config sets a screen lock profile, it's latched when the screen is locked,
+// so that output follows its bindings instead of stopping, and the profile
+// latched before it is latched again when the screen is unlocked. A momentary
+// profile held at the time is dropped: profile keys and actions are ignored
+// while the screen is locked.
+func (d *actionDispatcher) screenLockChanged(g13cfg *config.G13Config) {
+	prev := d.activeProfile()
+	switch {
+	case !d.screen.isLocked():
+		fmt.Println(i18n.T("Screen unlocked: output resumed"))
+		if !d.screenLockProfile {
+			return
+		}
+		d.profile = d.unlockProfile
+		d.unlockProfile = ""
+		d.screenLockProfile = false
+	case g13cfg.GetScreenLockProfile() == "":
+		fmt.Println(i18n.T("Screen locked: output stopped"))
+		return
+	default:
+		fmt.Println(i18n.T("Screen locked"))
+		d.unlockProfile = d.profile
+		d.profile = g13cfg.GetScreenLockProfile()
+		d.momentaryProfile = ""
+		d.screenLockProfile = true
+	}
+	d.profileChanged(prev, g13cfg)
+}
+
+// screenLockDevice passes the LCD content to [screenLock].
+type screenLockDevice struct {
+	device.Device
+
+	screen *screenLock
+}
+
+func (d *screenLockDevice) SetLCD(img image.Image) error {
+	return d.screen.setContent(func() error { return d.Device.SetLCD(img) })
+}
+
+func (d *screenLockDevice) WriteFrame(frame []byte) error {
+	return d.screen.setContent(func() error { return d.Device.WriteFrame(frame) })
+}
+
+func (d *screenLockDevice) ResetLCD() error {
+	return d.screen.setContent(d.Device.ResetLCD)
+}
+
+func (d *screenLockDevice) Close() {
+	d.screen.detach(d.Device)
+	d.Device.Close()
+}
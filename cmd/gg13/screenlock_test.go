@@ -0,0 +1,126 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/achilleas-k/gg13/internal/device"
+	"github.com/achilleas-k/gg13/internal/lcd"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestScreenLock(t *testing.T) {
+	assert := assert.New(t)
+
+	screen := &screenLock{}
+	testDev := &testOutputDevice{}
+	dev := screen.wrap(testDev)
+
+	page := lcd.TextPage("content")
+	require.NoError(t, dev.SetLCD(page))
+	assert.Same(page, testDev.lcd)
+
+	// the LCD is blank while locked and the content set meanwhile is held
+	// back
+	screen.set(true)
+	assert.True(screen.isLocked())
+	assert.Equal(lcd.TextPage(""), testDev.lcd)
+	newPage := lcd.TextPage("new content")
+	require.NoError(t, dev.SetLCD(newPage))
+	assert.Equal(lcd.TextPage(""), testDev.lcd)
+
+	// the latest content is restored on unlock
+	screen.set(false)
+	assert.False(screen.isLocked())
+	assert.Same(newPage, testDev.lcd)
+
+	// a device reinitialised while locked starts blank
+	screen.set(true)
+	dev.Close()
+	assert.True(testDev.closed)
+	newDev := &testOutputDevice{}
+	dev = screen.wrap(newDev)
+	assert.Equal(lcd.TextPage(""), newDev.lcd)
+	screen.set(false)
+	assert.Nil(newDev.lcd)
+
+	// a nil screen lock is never locked and doesn't wrap the device
+	var disabled *screenLock
+	assert.False(disabled.isLocked())
+	assert.Same(testDev, disabled.wrap(testDev))
+}
+
+func TestScreenLockMutes(t *testing.T) {
+	assert := assert.New(t)
+
+	cfg := loadTestConfig(t, `{"mapping":{"keys":{"G1":"KeyA"},"actions":{"M3":"pause"}},"mute_on_screen_lock":true}`)
+	screen := &screenLock{}
+	screen.wrap(&testOutputDevice{})
+	dispatcher := &actionDispatcher{screen: screen}
+	dev := &testConfigurableDevice{}
+
+	screen.set(true)
+	assert.True(dispatcher.muted())
+
+	// actions do nothing while the screen is locked
+	dispatcher.handleActions(device.M3.Uint64(), 0, cfg, dev)
+	assert.False(dispatcher.paused)
+
+	screen.set(false)
+	assert.False(dispatcher.muted())
+	assert.Equal(cfg, dispatcher.handleActions(0, 0, cfg, dev))
+
+	// without a screen lock only pausing mutes
+	assert.False((&actionDispatcher{}).muted())
+}
+
+func TestScreenLockProfile(t *testing.T) {
+	assert := assert.New(t)
+
+	cfg := loadTestConfig(t, `{
+		"mapping":{"keys":{"G1":"KeyA"}},
+		"profiles":{
+			"game":{"key":"M1","mapping":{"keys":{"G1":"KeyB"}}},
+			"hold":{"key":"M2","momentary":true},
+			"locked":{"mapping":{"keys":{"G22":"KeyPlaypause"}}}
+		},
+		"mute_on_screen_lock":true,
+		"screen_lock_profile":"locked"
+	}`)
+	screen := &screenLock{}
+	screen.wrap(&testOutputDevice{})
+	dispatcher := &actionDispatcher{screen: screen}
+	dev := &testConfigurableDevice{}
+	dispatcher.handleActions(device.M1.Uint64(), 0, cfg, dev)
+	dispatcher.handleActions(device.M1.Uint64()|device.M2.Uint64(), device.M1.Uint64(), cfg, dev)
+	assert.Equal("hold", dispatcher.activeProfile())
+
+	// output follows the screen lock profile instead of stopping
+	assert.True(screen.set(true))
+	dispatcher.screenLockChanged(cfg)
+	assert.False(dispatcher.muted())
+	assert.Equal("locked", dispatcher.activeProfile())
+	assert.Same(cfg.WithProfile("locked"), dispatcher.outputConfig(cfg))
+
+	// profile keys do nothing while the screen is locked
+	dispatcher.handleActions(0, device.M1.Uint64()|device.M2.Uint64(), cfg, dev)
+	assert.Equal("locked", dispatcher.activeProfile())
+
+	// the profile latched before is latched again on unlock
+	assert.False(screen.set(true))
+	assert.True(screen.set(false))
+	dispatcher.screenLockChanged(cfg)
+	assert.False(dispatcher.muted())
+	assert.Equal("game", dispatcher.activeProfile())
+
+	// without a screen lock profile output stops
+	noProfile := loadTestConfig(t, `{"mapping":{"keys":{"G1":"KeyA"}},"mute_on_screen_lock":true}`)
+	assert.True(screen.set(true))
+	dispatcher.screenLockChanged(noProfile)
+	assert.True(dispatcher.muted())
+	assert.Equal("game", dispatcher.activeProfile())
+	assert.True(screen.set(false))
+	dispatcher.screenLockChanged(noProfile)
+	assert.False(dispatcher.muted())
+	assert.Equal("game", dispatcher.activeProfile())
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+
+	"github.com/achilleas-k/gg13/internal/dbus"
+)
+
+// screenshotClient takes screenshots and returns the paths of the images.
+type screenshotClient interface {
+	Screenshot(interactive bool) (string, error)
+}
+
+// screenshotter takes screenshots through the desktop portal in the
+// background, one at a time, since the portal may wait for the user in its
+// dialogs. A nil *screenshotter takes nothing.
+type screenshotter struct {
+	w        io.Writer
+	messages *lcdMessages
+	client   screenshotClient
+	queue    chan bool
+	done     sync.WaitGroup
+}
+
+func startScreenshotter(w io.Writer, messages *lcdMessages, client screenshotClient) *screenshotter {
+	s := &screenshotter{
+		w:        w,
+		messages: messages,
+		client:   client,
+		// presses while a screenshot is being taken are dropped
+		queue: make(chan bool, 1),
+	}
+	s.done.Add(1)
+	go func() {
+		defer s.done.Done()
+		for interactive := range s.queue {
+			s.exec(interactive)
+		}
+	}()
+	return s
+}
+
+// take queues a screenshot without waiting for it.
+func (s *screenshotter) take(interactive bool) {
+	if s == nil {
+		return
+	}
+	select {
+	case s.queue <- interactive:
+	default:
+		fmt.Fprintln(s.w, "screenshot: already taking a screenshot: skipping")
+	}
+}
+
+func (s *screenshotter) exec(interactive bool) {
+	path, err := s.client.Screenshot(interactive)
+	if errors.Is(err, dbus.ErrScreenshotCancelled) {
+		fmt.Fprintln(s.w, "Screenshot cancelled")
+		s.messages.show("Screenshot cancelled")
+		return
+	}
+	if err != nil {
+		fmt.Fprintf(s.w, "screenshot: failed taking screenshot: %s\n", err)
+		s.messages.show("Error: screenshot:\n%s", err)
+		return
+	}
+	fmt.Fprintf(s.w, "Screenshot saved: %s\n", path)
+	s.messages.show("Screenshot saved:\n%s", path)
+}
+
+// close waits for the queued screenshot to finish.
+func (s *screenshotter) close() {
+	if s == nil {
+		return
+	}
+	close(s.queue)
+	s.done.Wait()
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/achilleas-k/gg13/internal/config"
+	"github.com/achilleas-k/gg13/internal/dbus"
+	"github.com/achilleas-k/gg13/internal/device"
+	"github.com/achilleas-k/gg13/internal/lcd"
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeScreenshots returns the results in turn.
+type fakeScreenshots struct {
+	results []error
+	taken   []bool
+}
+
+func (f *fakeScreenshots) Screenshot(interactive bool) (string, error) {
+	f.taken = append(f.taken, interactive)
+	err := f.results[0]
+	f.results = f.results[1:]
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("/home/user/Pictures/Screenshot-%d.png", len(f.taken)), nil
+}
+
+func TestScreenshot(t *testing.T) {
+	assert := assert.New(t)
+
+	cfg := loadTestConfig(t, `{"mapping":{"actions":{"G1":"screenshot","G2":"screenshot_interactive"}}}`)
+
+	messages := &lcdMessages{duration: time.Minute}
+	testDev := &testOutputDevice{}
+	messages.wrap(testDev)
+	client := &fakeScreenshots{results: []error{nil, dbus.ErrScreenshotCancelled, errors.New("no portal")}}
+	var out bytes.Buffer
+	shots := startScreenshotter(&out, messages, client)
+	dispatcher := &actionDispatcher{messages: messages, screenshots: shots}
+
+	// one at a time, so each is waited for
+	for _, input := range []uint64{device.G1.Uint64(), device.G2.Uint64(), device.G1.Uint64()} {
+		dispatcher.handleActions(input, 0, cfg, &testConfigurableDevice{})
+		assert.Eventually(func() bool { return len(shots.queue) == 0 }, time.Second, time.Millisecond)
+	}
+	shots.close()
+	assert.Equal([]bool{false, true, false}, client.taken)
+	assert.Equal(`Screenshot saved: /home/user/Pictures/Screenshot-1.png
+Screenshot cancelled
+screenshot: failed taking screenshot: no portal
+`, out.String())
+	assert.Equal(lcd.TextPage("Error: screenshot:\nno portal"), testDev.lcd)
+
+	// screenshots need the session bus
+	dispatcher.screenshots = nil
+	_, err := dispatcher.dispatch(config.ActionScreenshot, cfg, &testConfigurableDevice{})
+	assert.EqualError(err, "screenshots can't be taken here")
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/achilleas-k/gg13/internal/config"
+	"github.com/achilleas-k/gg13/internal/i18n"
+	"github.com/achilleas-k/gg13/internal/joystick"
+	"github.com/achilleas-k/gg13/internal/keyboard"
+)
+
+// runScript runs the steps of a script, waiting with sleep and running
+// actions with runAction. Like a macro, input isn't read while it runs.
+func runScript(steps []config.ScriptStep, vkb keyboard.Keyboard, vjs joystick.Joystick, runAction func(config.Action) error, sleep func(time.Duration)) error {
+	for _, step := range steps {
+		switch step.Kind {
+		case config.ScriptKeys:
+			last := len(step.Keys) - 1
+			if err := vkb.TypeChord(step.Keys[:last], step.Keys[last]); err != nil {
+				return err
+			}
+		case config.ScriptWait:
+			sleep(step.Delay)
+		case config.ScriptButton:
+			if vjs == nil {
+				return fmt.Errorf("no joystick to press button %d on", step.Button)
+			}
+			if err := vjs.ButtonPress(step.Button); err != nil {
+				return err
+			}
+		case config.ScriptAction:
+			if err := runAction(step.Action); err != nil {
+				return fmt.Errorf("action %s: %w", step.Action, err)
+			}
+		}
+	}
+	return nil
+}
+
+// handleScripts runs the scripts bound to keys that were pressed since the
+// previous read.
+func handleScripts(input, prevInput uint64, g13cfg *config.G13Config, vkb keyboard.Keyboard, vjs joystick.Joystick, runAction func(config.Action) error, sleep func(time.Duration)) {
+	for gkey, steps := range g13cfg.GetScripts() {
+		isDown := gkey.Uint64()&input != 0
+		wasDown := gkey.Uint64()&prevInput != 0
+		if !isDown || wasDown {
+			continue
+		}
+		if err := runScript(steps, vkb, vjs, runAction, sleep); err != nil {
+			fmt.Fprintln(os.Stderr, i18n.Sprintf("error running script of %s: %s", gkey, err))
+		}
+	}
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/achilleas-k/gg13/internal/config"
+	"github.com/achilleas-k/gg13/internal/device"
+	"github.com/achilleas-k/gg13/internal/mouse"
+)
+
+const (
+	// scrollRate is how many notches a held scroll key turns the wheel by
+	// per second at first, speeding up to maxScrollRate over
+	// scrollRampTime.
+	scrollRate     = 10.0
+	maxScrollRate  = 40.0
+	scrollRampTime = 2 * time.Second
+)
+
+// scrollDistance returns the notches that a scroll key held for the duration
+// has turned the wheel by, after the first one.
+func scrollDistance(held time.Duration) float64 {
+	ramp := scrollRampTime.Seconds()
+	accel := (maxScrollRate - scrollRate) / ramp
+	secs := held.Seconds()
+	if secs < ramp {
+		return scrollRate*secs + accel*secs*secs/2
+	}
+	return scrollRate*ramp + accel*ramp*ramp/2 + maxScrollRate*(secs-ramp)
+}
+
+// scroller turns the mouse wheel while keys with scroll bindings are held:
+// one notch when the key is pressed, and then faster the longer it's held.
+type scroller struct {
+	// when each held scroll key was pressed, and the notches it has turned
+	// the wheel by
+	since map[device.KeyBit]time.Time
+	sent  map[device.KeyBit]int
+}
+
+// handle turns the wheel for the scroll keys held in the input, by the
+// notches due since the previous event. Errors are written to w.
+func (s *scroller) handle(input uint64, now time.Time, g13cfg *config.G13Config, vms mouse.Mouse, w io.Writer) {
+	scroll := g13cfg.GetScroll()
+	if vms == nil {
+		// scroll bindings were added by reloading the config: the mouse
+		// is only created on (re)initialisation
+		s.reset()
+		return
+	}
+	if s.since == nil {
+		s.since = make(map[device.KeyBit]time.Time)
+		s.sent = make(map[device.KeyBit]int)
+	}
+	for gkey := range s.since {
+		if _, ok := scroll[gkey]; !ok || input&gkey.Uint64() == 0 {
+			delete(s.since, gkey)
+			delete(s.sent, gkey)
+		}
+	}
+
+	for gkey, dir := range scroll {
+		if input&gkey.Uint64() == 0 {
+			continue
+		}
+		since, ok := s.since[gkey]
+		if !ok {
+			since = now
+			s.since[gkey] = now
+		}
+		due := 1 + int(scrollDistance(now.Sub(since)))
+		notches := due - s.sent[gkey]
+		if notches <= 0 {
+			continue
+		}
+		s.sent[gkey] = due
+		if err := vms.Wheel(dir.Horizontal(), notches*dir.Notches()); err != nil {
+			fmt.Fprintf(w, "mouse error scrolling %s: %s\n", dir, err)
+		}
+	}
+}
+
+// pending returns true if scroll keys are held, which keep turning the wheel
+// without a new report.
+func (s *scroller) pending() bool {
+	return len(s.since) > 0
+}
+
+// reset stops scrolling until the keys are pressed again.
+func (s *scroller) reset() {
+	s.since = nil
+	s.sent = nil
+}
@@ -0,0 +1,17 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestScrollDistance(t *testing.T) {
+	assert := assert.New(t)
+	assert.Zero(scrollDistance(0))
+	assert.InDelta(scrollRate*0.1, scrollDistance(100*time.Millisecond), 0.1)
+	// at the maximum rate after the ramp
+	afterRamp := scrollDistance(scrollRampTime)
+	assert.InDelta(maxScrollRate, scrollDistance(scrollRampTime+time.Second)-afterRamp, 0.001)
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/achilleas-k/gg13/internal/device"
+	"github.com/achilleas-k/gg13/internal/keyboard"
+	"github.com/achilleas-k/gg13/internal/lcd"
+	"github.com/spf13/cobra"
+)
+
+// how long each colour is shown while cycling the backlight
+const selftestColourDuration = 500 * time.Millisecond
+
+// the colours the backlight cycles through, ending on white
+var selftestColours = []struct {
+	name   string
+	colour [3]uint8
+}{
+	{"red", [3]uint8{255, 0, 0}},
+	{"green", [3]uint8{0, 255, 0}},
+	{"blue", [3]uint8{0, 0, 255}},
+	{"white", [3]uint8{255, 255, 255}},
+}
+
+func mkSelftestCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:                   "selftest",
+		Short:                 "Test the device: cycle the backlight, draw a test pattern on the LCD, and echo key presses",
+		Long:                  "Test the device end to end: cycle the backlight through red, green, and blue, draw a test pattern on the LCD, and print the name of each key as it's pressed. Stop with Ctrl+C.",
+		Args:                  cobra.NoArgs,
+		RunE:                  selftest,
+		DisableFlagsInUseLine: true,
+	}
+}
+
+func selftest(cmd *cobra.Command, args []string) error {
+	cmd.SilenceUsage = true
+
+	dev, err := device.New()
+	if err != nil {
+		err = fmt.Errorf("device initialisation failed: %w", err)
+		return err
+	}
+	setCleanupHandler(dev.Close)
+	defer dev.Close()
+	fmt.Println("ok    device opened")
+
+	// the keys aren't sent to the virtual keyboard, but creating it checks
+	// that uinput is usable
+	vkb, err := keyboard.New("g13-selftest")
+	if err != nil {
+		err = fmt.Errorf("virtual keyboard initialisation failed: %w", err)
+		return err
+	}
+	if err := vkb.Close(); err != nil {
+		return fmt.Errorf("failed closing virtual keyboard: %w", err)
+	}
+	fmt.Println("ok    virtual keyboard created")
+
+	for _, c := range selftestColours {
+		fmt.Printf("      backlight: %s\n", c.name)
+		if err := dev.SetBacklightColour(c.colour[0], c.colour[1], c.colour[2]); err != nil {
+			return fmt.Errorf("failed setting backlight: %w", err)
+		}
+		time.Sleep(selftestColourDuration)
+	}
+	fmt.Println("ok    backlight")
+
+	if err := dev.SetLCD(lcd.TestPattern()); err != nil {
+		return fmt.Errorf("failed drawing test pattern: %w", err)
+	}
+	fmt.Println("ok    LCD test pattern")
+
+	fmt.Println("Press keys to test them (Ctrl+C to stop)")
+	var prevInput uint64
+	for {
+		input, _, err := dev.ReadInput()
+		if errors.Is(err, device.ErrReadTimeout) || errors.Is(err, device.ErrNotInputReport) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		for _, key := range pressedKeys(input, prevInput) {
+			fmt.Printf("      key: %s\n", key)
+		}
+		prevInput = input
+	}
+}
+
+// pressedKeys returns the keys that are down in input but weren't in
+// prevInput.
+func pressedKeys(input, prevInput uint64) []device.KeyBit {
+	var pressed []device.KeyBit
+	for _, key := range device.AllKeys() {
+		if key.Uint64()&input != 0 && key.Uint64()&prevInput == 0 {
+			pressed = append(pressed, key)
+		}
+	}
+	return pressed
+}
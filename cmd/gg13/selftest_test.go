@@ -0,0 +1,19 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/achilleas-k/gg13/internal/device"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPressedKeys(t *testing.T) {
+	assert := assert.New(t)
+
+	assert.Empty(pressedKeys(0, 0))
+	assert.Equal([]device.KeyBit{device.G1}, pressedKeys(device.G1.Uint64(), 0))
+	assert.Empty(pressedKeys(device.G1.Uint64(), device.G1.Uint64()))
+	assert.Equal([]device.KeyBit{device.TOP}, pressedKeys(device.G1.Uint64()|device.TOP.Uint64(), device.G1.Uint64()))
+	// releases and stick movements aren't presses
+	assert.Empty(pressedKeys(encodeStickPosition(0, 255), device.M1.Uint64()))
+}
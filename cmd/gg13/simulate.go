@@ -0,0 +1,349 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"image"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/achilleas-k/gg13/internal/config"
+	"github.com/achilleas-k/gg13/internal/desktop"
+	"github.com/achilleas-k/gg13/internal/device"
+	"github.com/achilleas-k/gg13/internal/joystick"
+	"github.com/achilleas-k/gg13/internal/keyboard"
+	"github.com/achilleas-k/gg13/internal/output"
+	"github.com/spf13/cobra"
+)
+
+// stickCentre is the input with the stick at rest, which simulations start
+// from.
+const stickCentre = uint64(0x80)<<8 | uint64(0x80)<<16
+
+// simEvent is a line of a simulation events file.
+type simEvent struct {
+	line string
+
+	// keys pressed or released
+	down, up uint64
+
+	// new raw stick position
+	stick  bool
+	stickX uint8
+	stickY uint8
+	wait   time.Duration
+}
+
+// parseSimEvents reads the events of a simulation, one per line:
+//
+//	down <key>...     press G13 keys
+//	up <key>...       release G13 keys
+//	stick <x> <y>     move the stick to the raw position, 0 to 255
+//	wait <duration>   let time pass, for gestures and flashes
+//
+// Empty lines and lines starting with # are ignored.
+func parseSimEvents(r io.Reader) ([]simEvent, error) {
+	var events []simEvent
+	scanner := bufio.NewScanner(r)
+	for lineNum := 1; scanner.Scan(); lineNum++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		ev, err := parseSimEvent(line)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", lineNum, err)
+		}
+		events = append(events, ev)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed reading events: %w", err)
+	}
+	return events, nil
+}
+
+func parseSimEvent(line string) (simEvent, error) {
+	ev := simEvent{line: line}
+	fields := strings.Fields(line)
+	cmd, args := fields[0], fields[1:]
+	switch cmd {
+	case "down", "up":
+		if len(args) == 0 {
+			return ev, fmt.Errorf("%s: no keys", cmd)
+		}
+		var keys uint64
+		for _, name := range args {
+			key := device.KeyCode(name)
+			if key == 0 {
+				return ev, fmt.Errorf("%s: unknown G13 key name: %s", cmd, name)
+			}
+			keys |= key.Uint64()
+		}
+		if cmd == "down" {
+			ev.down = keys
+		} else {
+			ev.up = keys
+		}
+	case "stick":
+		if len(args) != 2 {
+			return ev, fmt.Errorf("stick: expected x and y, got %d values", len(args))
+		}
+		x, err := strconv.ParseUint(args[0], 10, 8)
+		if err != nil {
+			return ev, fmt.Errorf("stick: invalid x %q: it must be between 0 and 255", args[0])
+		}
+		y, err := strconv.ParseUint(args[1], 10, 8)
+		if err != nil {
+			return ev, fmt.Errorf("stick: invalid y %q: it must be between 0 and 255", args[1])
+		}
+		ev.stick, ev.stickX, ev.stickY = true, uint8(x), uint8(y)
+	case "wait":
+		if len(args) != 1 {
+			return ev, fmt.Errorf("wait: expected a duration")
+		}
+		dt, err := time.ParseDuration(args[0])
+		if err != nil || dt < 0 {
+			return ev, fmt.Errorf("wait: invalid duration %q", args[0])
+		}
+		ev.wait = dt
+	default:
+		return ev, fmt.Errorf("unknown event %q", cmd)
+	}
+	return ev, nil
+}
+
+// simDevice stands in for the G13 in a simulation, writing the changes to
+// the backlight and LCD.
+type simDevice struct {
+	w io.Writer
+}
+
+func (d *simDevice) SetTimeout(time.Duration) error {
+	return nil
+}
+
+func (d *simDevice) SetBacklightKeepalive(time.Duration) {}
+
+func (d *simDevice) SetBacklightColour(r, g, b uint8) error {
+	_, err := fmt.Fprintf(d.w, "backlight #%02x%02x%02x\n", r, g, b)
+	return err
+}
+
+func (d *simDevice) SetLCD(image.Image) error {
+	_, err := fmt.Fprintf(d.w, "lcd image\n")
+	return err
+}
+
+func (d *simDevice) OverrideBacklightColour(r, g, b uint8, dt time.Duration) (uint64, error) {
+	_, err := fmt.Fprintf(d.w, "backlight flash #%02x%02x%02x for %s\n", r, g, b, dt)
+	return 1, err
+}
+
+func (d *simDevice) ClearBacklightOverride() error {
+	_, err := fmt.Fprintf(d.w, "backlight restore\n")
+	return err
+}
+
+// simKeyboard passes on only the key events that change the state of a key,
+// like the kernel does for the uinput keyboard, since the input is handled
+// in full on every read.
+type simKeyboard struct {
+	keyboard.Keyboard
+	down map[int]bool
+}
+
+func (kb *simKeyboard) KeyDown(k int) error {
+	if kb.down[k] {
+		return nil
+	}
+	kb.down[k] = true
+	return kb.Keyboard.KeyDown(k)
+}
+
+func (kb *simKeyboard) KeyUp(k int) error {
+	if !kb.down[k] {
+		return nil
+	}
+	delete(kb.down, k)
+	return kb.Keyboard.KeyUp(k)
+}
+
+// simJoystick passes on only the joystick events that change its state, like
+// simKeyboard.
+type simJoystick struct {
+	joystick.Joystick
+	down map[int]bool
+
+	stickX, stickY float32
+	hatX, hatY     int
+}
+
+func (js *simJoystick) ButtonDown(b int) error {
+	if js.down[b] {
+		return nil
+	}
+	js.down[b] = true
+	return js.Joystick.ButtonDown(b)
+}
+
+func (js *simJoystick) ButtonUp(b int) error {
+	if !js.down[b] {
+		return nil
+	}
+	delete(js.down, b)
+	return js.Joystick.ButtonUp(b)
+}
+
+func (js *simJoystick) StickPosition(x, y float32) error {
+	if x == js.stickX && y == js.stickY {
+		return nil
+	}
+	js.stickX, js.stickY = x, y
+	return js.Joystick.StickPosition(x, y)
+}
+
+func (js *simJoystick) HatPosition(x, y int) error {
+	if x == js.hatX && y == js.hatY {
+		return nil
+	}
+	js.hatX, js.hatY = x, y
+	return js.Joystick.HatPosition(x, y)
+}
+
+// simulate feeds the events through the actions and output handling of the
+// input loop with the config loaded from cfgPath, and writes each event
+// followed by the output it caused to w.
+func simulate(cfgPath string, events []simEvent, w io.Writer) error {
+	g13cfg, err := config.NewFromFile(cfgPath)
+	if err != nil {
+		return configError{err}
+	}
+
+	sink := output.NewDryRun(w, g13cfg.GetStickMode() == config.StickModeJoystick || g13cfg.GetJoystickButtons() > 0, mouseOptions(g13cfg) != nil)
+	var vkb keyboard.Keyboard = &simKeyboard{Keyboard: sink.Keyboard, down: map[int]bool{}}
+	var vjs joystick.Joystick
+	if sink.Joystick != nil {
+		vjs = &simJoystick{Joystick: sink.Joystick, down: map[int]bool{}}
+	}
+	dev := &simDevice{w: w}
+	actions := &actionDispatcher{
+		load: func() (*config.G13Config, error) {
+			return config.NewFromFile(cfgPath)
+		},
+	}
+	gestureDetector := newGestureDetector(g13cfg)
+	keys := &keyFilter{prev: stickCentre}
+	runAction := func(action config.Action) error {
+		_, err := actions.dispatch(action, g13cfg, dev)
+		return err
+	}
+	chords := &chordDecoder{}
+	scroll := &scroller{}
+
+	now := time.Unix(0, 0)
+	// macros take simulated time, and the ones that repeat while their key
+	// is held play once
+	macros := newMacroPlayer(w, func(_ context.Context, d time.Duration) bool {
+		now = now.Add(d)
+		return true
+	})
+	macros.playOnce = true
+	input := stickCentre
+	for _, ev := range events {
+		fmt.Fprintf(w, "> %s\n", ev.line)
+		if ev.wait > 0 {
+			now = now.Add(ev.wait)
+			if !keys.pending() && !scroll.pending() {
+				continue
+			}
+			// held keys register after the slow keys delay, and held
+			// scroll keys keep scrolling
+		}
+		input = (input | ev.down) &^ ev.up
+		if ev.stick {
+			input = input&^(device.XMask|device.YMask) | uint64(ev.stickX)<<8 | uint64(ev.stickY)<<16
+		}
+
+		// disabled keys do nothing
+		outputCfg := actions.outputConfig(g13cfg)
+		in := outputCfg.MaskDisabledKeys(input)
+		in, prevIn := keys.filter(in, now, outputCfg)
+
+		wasMuted := actions.muted()
+		prevOutputCfg := actions.outputConfig(g13cfg)
+		if newCfg := actions.handleActions(in, prevIn, g13cfg, dev); newCfg != g13cfg {
+			g13cfg = newCfg
+			gestureDetector = newGestureDetector(g13cfg)
+		}
+		if (actions.muted() && !wasMuted) || actions.outputConfig(g13cfg) != prevOutputCfg {
+			releaseOutput(prevOutputCfg, vkb, vjs)
+			chords.reset()
+			scroll.reset()
+		}
+		warnUnmapped(in, prevIn, actions.outputConfig(g13cfg), w)
+		if !actions.muted() {
+			chords.handle(in, actions.outputConfig(g13cfg), vkb, w)
+			handleInput(in, actions.outputConfig(g13cfg), vkb, vjs)
+			// macros finish before the next event
+			handleMacros(in, prevIn, actions.outputConfig(g13cfg), vkb, macros)
+			macros.wait()
+			handleScripts(in, prevIn, actions.outputConfig(g13cfg), vkb, vjs, runAction, func(d time.Duration) { now = now.Add(d) })
+			scroll.handle(in, now, actions.outputConfig(g13cfg), sink.Mouse, w)
+			handleWarps(in, prevIn, actions.outputConfig(g13cfg), sink.Mouse, w)
+			// the commands aren't run
+			handleWindows(in, prevIn, actions.outputConfig(g13cfg), func(op desktop.Op) {
+				fmt.Fprintf(w, "window %s\n", op)
+			})
+			handleAudio(in, prevIn, actions.outputConfig(g13cfg), func(sw config.AudioSwitch) {
+				fmt.Fprintf(w, "audio %s\n", sw)
+			})
+			handleFlashes(in, prevIn, g13cfg, dev)
+			if gestureDetector != nil {
+				handleGestures(in, now, g13cfg, gestureDetector, vkb)
+			}
+		}
+	}
+	return nil
+}
+
+func mkSimulateCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "simulate <config> <events-file>",
+		Short: "Show the output a config produces for a sequence of key and stick events",
+		Long: `Show the output a config produces for a sequence of key and stick events,
+without a G13 or virtual devices, for testing complex configs. The events
+file has one event per line ('-' reads it from standard input):
+
+  down <key>...     press G13 keys
+  up <key>...       release G13 keys
+  stick <x> <y>     move the stick to the raw position, 0 to 255
+  wait <duration>   let time pass, for gestures and flashes
+
+Empty lines and lines starting with # are ignored.`,
+		Args: cobra.ExactArgs(2),
+		RunE: runSimulate,
+	}
+}
+
+func runSimulate(cmd *cobra.Command, args []string) error {
+	cmd.SilenceUsage = true
+
+	eventsFile := os.Stdin
+	if args[1] != "-" {
+		file, err := os.Open(args[1])
+		if err != nil {
+			return fmt.Errorf("failed to open events file: %w", err)
+		}
+		defer file.Close()
+		eventsFile = file
+	}
+	events, err := parseSimEvents(eventsFile)
+	if err != nil {
+		return fmt.Errorf("failed reading events file: %w", err)
+	}
+	return simulate(args[0], events, os.Stdout)
+}
@@ -0,0 +1,302 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSimulate(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	cfgPath := writeTestConfig(t, `{"mapping":{"keys":{"G1":"KeyA","G2":"KeyB"}}}`)
+
+	events, err := parseSimEvents(strings.NewReader(`
+# press and release
+down G1 G2
+wait 50ms
+up G1
+up G2
+`))
+	require.NoError(err)
+
+	var out bytes.Buffer
+	require.NoError(simulate(cfgPath, events, &out))
+	assert.Equal(`> down G1 G2
+key down KeyA
+key down KeyB
+> wait 50ms
+> up G1
+key up KeyA
+> up G2
+key up KeyB
+`, out.String())
+}
+
+func TestSimulateDisabledAndUnmapped(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	cfgPath := writeTestConfig(t, `{"mapping":{"keys":{"G1":"KeyA","G2":"KeyB"},"disabled":["G2"],"warn_unmapped":true}}`)
+
+	events, err := parseSimEvents(strings.NewReader(`
+down G1 G2 G3
+up G1 G2 G3
+`))
+	require.NoError(err)
+
+	var out bytes.Buffer
+	require.NoError(simulate(cfgPath, events, &out))
+	assert.Equal(`> down G1 G2 G3
+warning: G3 isn't bound to a key or action
+key down KeyA
+> up G1 G2 G3
+key up KeyA
+`, out.String())
+}
+
+func TestSimulateAccessibility(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	cfgPath := writeTestConfig(t, `{"mapping":{"keys":{"G1":"KeyLeftctrl","G2":"KeyC"},"sticky_keys":true,"slow_keys":"100ms"}}`)
+
+	events, err := parseSimEvents(strings.NewReader(`
+# too short for slow keys
+down G2
+wait 50ms
+up G2
+# tapped modifier latches
+down G1
+wait 100ms
+up G1
+down G2
+wait 100ms
+up G2
+`))
+	require.NoError(err)
+
+	var out bytes.Buffer
+	require.NoError(simulate(cfgPath, events, &out))
+	assert.Equal(`> down G2
+> wait 50ms
+> up G2
+> down G1
+> wait 100ms
+key down KeyLeftctrl
+> up G1
+> down G2
+> wait 100ms
+key down KeyC
+> up G2
+key up KeyLeftctrl
+key up KeyC
+`, out.String())
+}
+
+func TestSimulateChords(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	cfgPath := writeTestConfig(t, `{"mapping":{"keys":{"G5":"KeyLeftshift"},"chords":{"G1":"KeyE","G1+G2":"KeyT"},"sticky_keys":true}}`)
+
+	events, err := parseSimEvents(strings.NewReader(`
+down G1
+up G1
+down G1
+down G2
+up G1
+up G2
+down G2
+up G2
+down G5
+up G5
+down G1
+up G1
+`))
+	require.NoError(err)
+
+	var out bytes.Buffer
+	require.NoError(simulate(cfgPath, events, &out))
+	assert.Equal(`> down G1
+> up G1
+key press KeyE
+> down G1
+> down G2
+> up G1
+> up G2
+key press KeyT
+> down G2
+> up G2
+unknown chord: G2
+> down G5
+key down KeyLeftshift
+> up G5
+> down G1
+> up G1
+key press KeyE
+key up KeyLeftshift
+`, out.String())
+}
+
+func TestSimulateScripts(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	cfgPath := writeTestConfig(t, `{"mapping":{"scripts":{"G1":"KeyA, wait 50ms, KeyLeftctrl+KeyB, button 1","G2":"action pause"}}}`)
+
+	events, err := parseSimEvents(strings.NewReader(`
+down G1
+up G1
+down G2
+down G1
+`))
+	require.NoError(err)
+
+	var out bytes.Buffer
+	require.NoError(simulate(cfgPath, events, &out))
+	assert.Equal(`> down G1
+key down KeyA
+key up KeyA
+key down KeyLeftctrl
+key down KeyB
+key up KeyB
+key up KeyLeftctrl
+button press 1
+> up G1
+> down G2
+backlight flash #ff6000 for 0s
+> down G1
+`, out.String())
+}
+
+func TestSimulateScroll(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	cfgPath := writeTestConfig(t, `{"mapping":{"scroll":{"LEFT":"up","DOWN":"down"}}}`)
+
+	events, err := parseSimEvents(strings.NewReader(`
+down LEFT
+wait 500ms
+wait 2s
+up LEFT
+wait 1s
+down DOWN
+`))
+	require.NoError(err)
+
+	// a notch right away, then faster the longer the key is held
+	var out bytes.Buffer
+	require.NoError(simulate(cfgPath, events, &out))
+	assert.Equal(`> down LEFT
+wheel 1
+> wait 500ms
+wheel 6
+> wait 2s
+wheel 64
+> up LEFT
+> wait 1s
+> down DOWN
+wheel -1
+`, out.String())
+}
+
+func TestSimulateWarp(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	cfgPath := writeTestConfig(t, `{"screen":{"width":2560,"height":1440},"mapping":{"warp":{"G5":"1200,640","G6":"0,0"}}}`)
+
+	events, err := parseSimEvents(strings.NewReader(`
+down G5
+down G6
+up G5
+down G5
+`))
+	require.NoError(err)
+
+	// only pressing a key moves the pointer
+	var out bytes.Buffer
+	require.NoError(simulate(cfgPath, events, &out))
+	assert.Equal(`> down G5
+pointer 1200 640
+> down G6
+pointer 0 0
+> up G5
+> down G5
+pointer 1200 640
+`, out.String())
+}
+
+func TestSimulateWindows(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	cfgPath := writeTestConfig(t, `{"desktop":"sway","mapping":{"windows":{"G7":"focus firefox","G8":"workspace 2"}}}`)
+
+	events, err := parseSimEvents(strings.NewReader(`
+down G7
+up G7
+down G8
+`))
+	require.NoError(err)
+
+	var out bytes.Buffer
+	require.NoError(simulate(cfgPath, events, &out))
+	assert.Equal(`> down G7
+window focus firefox
+> up G7
+> down G8
+window workspace 2
+`, out.String())
+}
+
+func TestSimulateAudio(t *testing.T) {
+	cfgPath := writeTestConfig(t, `{"mapping":{"audio":{"G9":"output USB Headset"}}}`)
+
+	events, err := parseSimEvents(strings.NewReader("down G9\n"))
+	require.NoError(t, err)
+	var out bytes.Buffer
+	require.NoError(t, simulate(cfgPath, events, &out))
+	assert.Equal(t, "> down G9\naudio output USB Headset\n", out.String())
+}
+
+func TestParseSimEventsErrors(t *testing.T) {
+	tests := map[string]struct {
+		events string
+		errMsg string
+	}{
+		"unknown-event": {
+			events: "press G1",
+			errMsg: `line 1: unknown event "press"`,
+		},
+		"unknown-key": {
+			events: "# comment\ndown G99",
+			errMsg: "line 2: down: unknown G13 key name: G99",
+		},
+		"no-keys": {
+			events: "up",
+			errMsg: "line 1: up: no keys",
+		},
+		"stick-range": {
+			events: "stick 300 0",
+			errMsg: `line 1: stick: invalid x "300": it must be between 0 and 255`,
+		},
+		"bad-wait": {
+			events: "wait soon",
+			errMsg: `line 1: wait: invalid duration "soon"`,
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			_, err := parseSimEvents(strings.NewReader(tc.events))
+			assert.EqualError(t, err, tc.errMsg)
+		})
+	}
+}
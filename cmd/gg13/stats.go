@@ -0,0 +1,130 @@
+package main
+
+import (
+	"cmp"
+	"fmt"
+	"image/png"
+	"maps"
+	"os"
+	"slices"
+	"time"
+
+	"github.com/achilleas-k/gg13/internal/i18n"
+	"github.com/achilleas-k/gg13/internal/stats"
+	"github.com/spf13/cobra"
+)
+
+// statsSaveInterval is how often the key statistics are written to the file
+// while recording.
+const statsSaveInterval = time.Minute
+
+// startStats loads the key statistics from the file set with --stats-file and
+// returns a recorder adding to them, or nil if --record-stats isn't set. The
+// statistics are saved right away, to catch problems with the file early, and
+// then every [statsSaveInterval] until stop is closed.
+func startStats(cmd *cobra.Command, stop <-chan struct{}) (*stats.Recorder, string, error) {
+	record, err := cmd.Flags().GetBool("record-stats")
+	if err != nil || !record {
+		return nil, "", err
+	}
+	path, err := cmd.Flags().GetString("stats-file")
+	if err != nil {
+		return nil, "", err
+	}
+
+	s, err := stats.Load(path)
+	if err != nil {
+		return nil, "", err
+	}
+	recorder := stats.NewRecorder(s)
+	if err := recorder.Save(path); err != nil {
+		return nil, "", err
+	}
+
+	go func() {
+		ticker := time.NewTicker(statsSaveInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+				if err := recorder.Save(path); err != nil {
+					fmt.Fprintln(os.Stderr, i18n.Sprintf("error saving key statistics: %s", err))
+				}
+			}
+		}
+	}()
+	return recorder, path, nil
+}
+
+func mkStatsCmd() *cobra.Command {
+	statsCmd := &cobra.Command{
+		Use:   "stats",
+		Short: "Show how often and how long each key was pressed",
+		Long: "Show how often and how long each key was pressed, as recorded by the driver with --record-stats, " +
+			"most pressed first. The statistics are saved every minute while the driver runs.",
+		Args: cobra.NoArgs,
+		RunE: showStats,
+	}
+	statsCmd.Flags().String("heatmap", "", "also draw the key layout coloured by use and save it as a PNG to this path")
+	return statsCmd
+}
+
+func showStats(cmd *cobra.Command, _ []string) error {
+	cmd.SilenceUsage = true
+
+	path, err := cmd.Flags().GetString("stats-file")
+	if err != nil {
+		return err
+	}
+	s, err := stats.Load(path)
+	if err != nil {
+		return err
+	}
+
+	printStats(s)
+
+	heatmapPath, err := cmd.Flags().GetString("heatmap")
+	if err != nil {
+		return err
+	}
+	if heatmapPath == "" {
+		return nil
+	}
+	heatmapFile, err := os.Create(heatmapPath)
+	if err != nil {
+		return fmt.Errorf("failed to create heatmap file %q: %w", heatmapPath, err)
+	}
+	defer func() { _ = heatmapFile.Close() }()
+	if err := png.Encode(heatmapFile, s.Heatmap()); err != nil {
+		return fmt.Errorf("failed to write heatmap file %q: %w", heatmapPath, err)
+	}
+	if err := heatmapFile.Close(); err != nil {
+		return fmt.Errorf("failed to write heatmap file %q: %w", heatmapPath, err)
+	}
+	fmt.Printf("Heatmap written to %s\n", heatmapPath)
+	return nil
+}
+
+// printStats prints a line for each key that was pressed, most pressed first.
+func printStats(s *stats.Stats) {
+	if len(s.Keys) == 0 {
+		fmt.Println("No key presses recorded")
+		return
+	}
+
+	names := slices.Collect(maps.Keys(s.Keys))
+	slices.SortFunc(names, func(a, b string) int {
+		if c := cmp.Compare(s.Keys[b].Presses, s.Keys[a].Presses); c != 0 {
+			return c
+		}
+		return cmp.Compare(a, b)
+	})
+
+	fmt.Printf("%-6s %10s %12s %12s\n", "KEY", "PRESSES", "HELD", "MEAN HELD")
+	for _, name := range names {
+		ks := s.Keys[name]
+		fmt.Printf("%-6s %10d %12s %12s\n", name, ks.Presses, ks.Held.Round(time.Millisecond), ks.MeanHeld().Round(time.Millisecond))
+	}
+}
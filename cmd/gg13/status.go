@@ -0,0 +1,153 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/achilleas-k/gg13/internal/config"
+	"github.com/achilleas-k/gg13/internal/control"
+	"github.com/spf13/cobra"
+)
+
+// daemonStatus records the health of the connection to the device for the
+// status command. The input loop updates it.
+type daemonStatus struct {
+	mu         sync.Mutex
+	started    time.Time
+	connected  bool
+	reconnects int
+	lastError  string
+	errorTime  time.Time
+}
+
+func newDaemonStatus(started time.Time) *daemonStatus {
+	return &daemonStatus{started: started, connected: true}
+}
+
+// readError records an error reading from the device.
+func (s *daemonStatus) readError(err error, t time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.lastError = err.Error()
+	s.errorTime = t
+}
+
+// disconnected records that the device is being reinitialised.
+func (s *daemonStatus) disconnected() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.connected = false
+}
+
+// reconnected records that the device was reinitialised.
+func (s *daemonStatus) reconnected() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.connected = true
+	s.reconnects++
+}
+
+// statusReport is the reply to the status control command.
+type statusReport struct {
+	Connected  bool          `json:"connected"`
+	Started    time.Time     `json:"started"`
+	Uptime     time.Duration `json:"uptime"`
+	Profile    string        `json:"profile"`
+	Reconnects int           `json:"reconnects"`
+
+	// LastError is the last error reading from the device, empty if there
+	// was none, and ErrorTime is when it happened
+	LastError string     `json:"last_error"`
+	ErrorTime *time.Time `json:"error_time,omitempty"`
+}
+
+// report returns the status at the given time, with the name of the active
+// profile, or an empty string for the main mapping.
+func (s *daemonStatus) report(profile string, now time.Time) statusReport {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if profile == "" {
+		profile = config.MainProfile
+	}
+	report := statusReport{
+		Connected:  s.connected,
+		Started:    s.started,
+		Uptime:     now.Sub(s.started),
+		Profile:    profile,
+		Reconnects: s.reconnects,
+		LastError:  s.lastError,
+	}
+	if s.lastError != "" {
+		errorTime := s.errorTime
+		report.ErrorTime = &errorTime
+	}
+	return report
+}
+
+// handleStatus registers the control command returning the status.
+func handleStatus(server *control.Server, status *daemonStatus, live *liveState) {
+	server.Handle("status", func(args []string) (any, error) {
+		if len(args) != 0 {
+			return nil, fmt.Errorf("status: expected no arguments, got %d", len(args))
+		}
+		return status.report(live.activeProfile(), time.Now()), nil
+	})
+}
+
+func mkStatusCmd() *cobra.Command {
+	statusCmd := &cobra.Command{
+		Use:   "status",
+		Short: "Show the status of the running driver",
+		Long: "Show whether the running driver is connected to the device, how long it has been running, the " +
+			"active profile, how many times the device was reconnected, and the last error reading from it.",
+		Args: cobra.NoArgs,
+		RunE: showStatus,
+	}
+	statusCmd.Flags().String("remote", "", "query an instance listening on TCP at this address instead of the local socket")
+	statusCmd.Flags().Bool("json", false, "print the status as JSON")
+	return statusCmd
+}
+
+func showStatus(cmd *cobra.Command, _ []string) error {
+	cmd.SilenceUsage = true
+
+	asJSON, err := cmd.Flags().GetBool("json")
+	if err != nil {
+		return err
+	}
+	data, err := sendControl(cmd, control.Request{Command: "status"})
+	if err != nil {
+		return fmt.Errorf("failed querying the driver: %w", err)
+	}
+	if asJSON {
+		fmt.Println(string(data))
+		return nil
+	}
+
+	var report statusReport
+	if err := json.Unmarshal(data, &report); err != nil {
+		return fmt.Errorf("failed decoding status: %w", err)
+	}
+	fmt.Print(formatStatus(report))
+	return nil
+}
+
+// formatStatus returns the status as lines for reading.
+func formatStatus(report statusReport) string {
+	device := "disconnected"
+	if report.Connected {
+		device = "connected"
+	}
+	lastError := "none"
+	if report.ErrorTime != nil {
+		lastError = fmt.Sprintf("%s (%s)", report.LastError, report.ErrorTime.Format(time.DateTime))
+	}
+	return fmt.Sprintf("device:     %s\n", device) +
+		fmt.Sprintf("uptime:     %s (since %s)\n", report.Uptime.Round(time.Second), report.Started.Format(time.DateTime)) +
+		fmt.Sprintf("profile:    %s\n", report.Profile) +
+		fmt.Sprintf("reconnects: %d\n", report.Reconnects) +
+		fmt.Sprintf("last error: %s\n", lastError)
+}
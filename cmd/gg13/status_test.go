@@ -0,0 +1,65 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/achilleas-k/gg13/internal/config"
+	"github.com/achilleas-k/gg13/internal/control"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStatus(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	socketPath := filepath.Join(t.TempDir(), "gg13.sock")
+	server, err := control.NewServer(socketPath)
+	require.NoError(err)
+	defer server.Close()
+
+	started := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	status := newDaemonStatus(started)
+	live := &liveState{}
+	handleStatus(server, status, live)
+
+	data, err := control.Send(socketPath, control.Request{Command: "status"})
+	require.NoError(err)
+	var report statusReport
+	require.NoError(json.Unmarshal(data, &report))
+	assert.True(report.Connected)
+	assert.Equal(config.MainProfile, report.Profile)
+	assert.Empty(report.LastError)
+	assert.Nil(report.ErrorTime)
+
+	errorTime := started.Add(time.Hour)
+	status.readError(fmt.Errorf("device gone"), errorTime)
+	status.disconnected()
+	live.update(0, nil, false, false, "game")
+	report = status.report(live.activeProfile(), started.Add(2*time.Hour))
+	assert.Equal(statusReport{
+		Connected: false,
+		Started:   started,
+		Uptime:    2 * time.Hour,
+		Profile:   "game",
+		LastError: "device gone",
+		ErrorTime: &errorTime,
+	}, report)
+	status.reconnected()
+	report = status.report("", started.Add(2*time.Hour))
+	assert.True(report.Connected)
+	assert.Equal(1, report.Reconnects)
+	assert.Equal(strings.Join([]string{
+		"device:     connected",
+		"uptime:     2h0m0s (since 2024-01-01 12:00:00)",
+		"profile:    main",
+		"reconnects: 1",
+		"last error: device gone (2024-01-01 13:00:00)",
+		"",
+	}, "\n"), formatStatus(report))
+}
@@ -0,0 +1,161 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/achilleas-k/gg13/internal/device"
+	"github.com/achilleas-k/gg13/internal/joystick"
+	"github.com/achilleas-k/gg13/internal/keyboard"
+)
+
+// traceMaxLines is the number of trace lines written each second. Lines over
+// it are counted and reported when the next second starts, so that a stick
+// moving in joystick mode doesn't flood the log.
+const traceMaxLines = 100
+
+// tracer logs the decoded G13 input and the events sent to the virtual
+// keyboard and joystick, for debugging bindings that don't seem to do
+// anything. A nil *tracer logs nothing.
+type tracer struct {
+	w   io.Writer
+	now func() time.Time
+
+	mu sync.Mutex
+	// start of the current second and the lines written or suppressed in it
+	windowStart time.Time
+	lines       int
+	suppressed  int
+}
+
+func newTracer(w io.Writer) *tracer {
+	return &tracer{w: w, now: time.Now}
+}
+
+func (t *tracer) printf(format string, args ...any) {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	now := t.now()
+	if now.Sub(t.windowStart) >= time.Second {
+		if t.suppressed > 0 {
+			fmt.Fprintf(t.w, "%s trace: %d lines suppressed\n", now.Format("15:04:05.000"), t.suppressed)
+		}
+		t.windowStart = now
+		t.lines = 0
+		t.suppressed = 0
+	}
+	if t.lines >= traceMaxLines {
+		t.suppressed++
+		return
+	}
+	t.lines++
+	fmt.Fprintf(t.w, "%s trace: "+format+"\n", append([]any{now.Format("15:04:05.000")}, args...)...)
+}
+
+// input logs the G13 keys pressed and released since prevInput.
+func (t *tracer) input(input, prevInput uint64) {
+	if t == nil {
+		return
+	}
+	for _, key := range device.AllKeys() {
+		isDown := key.Uint64()&input != 0
+		wasDown := key.Uint64()&prevInput != 0
+		switch {
+		case isDown && !wasDown:
+			t.printf("press %s", key)
+		case !isDown && wasDown:
+			t.printf("release %s", key)
+		}
+	}
+}
+
+// wrap returns the keyboard and joystick with the events sent to them logged.
+// The joystick is nil if vjs is nil.
+func (t *tracer) wrap(vkb keyboard.Keyboard, vjs joystick.Joystick) (keyboard.Keyboard, joystick.Joystick) {
+	if t == nil {
+		return vkb, vjs
+	}
+	vkb = &traceKeyboard{Keyboard: vkb, tracer: t}
+	if vjs != nil {
+		vjs = &traceJoystick{Joystick: vjs, tracer: t}
+	}
+	return vkb, vjs
+}
+
+type traceKeyboard struct {
+	keyboard.Keyboard
+
+	tracer *tracer
+}
+
+func (kb *traceKeyboard) KeyPress(k int) error {
+	kb.tracer.printf("emit key press %s", traceKeyName(k))
+	return kb.Keyboard.KeyPress(k)
+}
+
+func (kb *traceKeyboard) KeyDown(k int) error {
+	kb.tracer.printf("emit key down %s", traceKeyName(k))
+	return kb.Keyboard.KeyDown(k)
+}
+
+func (kb *traceKeyboard) KeyUp(k int) error {
+	kb.tracer.printf("emit key up %s", traceKeyName(k))
+	return kb.Keyboard.KeyUp(k)
+}
+
+func (kb *traceKeyboard) TypeChord(mods []int, key int) error {
+	names := make([]string, 0, len(mods)+1)
+	for _, k := range mods {
+		names = append(names, traceKeyName(k))
+	}
+	kb.tracer.printf("emit key chord %s", strings.Join(append(names, traceKeyName(key)), "+"))
+	return kb.Keyboard.TypeChord(mods, key)
+}
+
+// traceKeyName returns the name of the keyboard key, or its code if it has
+// none.
+func traceKeyName(k int) string {
+	if name := keyboard.KeyName(k); name != "" {
+		return name
+	}
+	return strconv.Itoa(k)
+}
+
+type traceJoystick struct {
+	joystick.Joystick
+
+	tracer *tracer
+}
+
+func (js *traceJoystick) ButtonPress(b int) error {
+	js.tracer.printf("emit button press %d", b)
+	return js.Joystick.ButtonPress(b)
+}
+
+func (js *traceJoystick) ButtonDown(b int) error {
+	js.tracer.printf("emit button down %d", b)
+	return js.Joystick.ButtonDown(b)
+}
+
+func (js *traceJoystick) ButtonUp(b int) error {
+	js.tracer.printf("emit button up %d", b)
+	return js.Joystick.ButtonUp(b)
+}
+
+func (js *traceJoystick) StickPosition(x, y float32) error {
+	js.tracer.printf("emit stick %.3f %.3f", x, y)
+	return js.Joystick.StickPosition(x, y)
+}
+
+func (js *traceJoystick) HatPosition(x, y int) error {
+	js.tracer.printf("emit hat %d %d", x, y)
+	return js.Joystick.HatPosition(x, y)
+}
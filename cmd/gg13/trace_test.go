@@ -0,0 +1,54 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/achilleas-k/gg13/internal/device"
+	"github.com/achilleas-k/gg13/internal/keyboard"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTracer(t *testing.T) {
+	assert := assert.New(t)
+
+	var buf bytes.Buffer
+	trace := newTracer(&buf)
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	trace.now = func() time.Time { return now }
+
+	tk := newTestKeyboard(t)
+	tk.newEvent()
+	vkb, vjs := trace.wrap(tk, newTestJoystick(t))
+	trace.input(device.G9.Uint64(), 0)
+	assert.NoError(vkb.KeyDown(keyboard.KeyCode("KeyA")))
+	assert.NoError(vjs.ButtonUp(3))
+	trace.input(0, device.G9.Uint64())
+	assert.Equal(strings.Join([]string{
+		"12:00:00.000 trace: press G9",
+		"12:00:00.000 trace: emit key down KeyA",
+		"12:00:00.000 trace: emit button up 3",
+		"12:00:00.000 trace: release G9",
+		"",
+	}, "\n"), buf.String())
+
+	// lines over the limit are counted in the next second
+	buf.Reset()
+	for range traceMaxLines {
+		trace.printf("line")
+	}
+	assert.Equal(traceMaxLines-4, strings.Count(buf.String(), "\n"))
+	now = now.Add(time.Second)
+	trace.printf("line")
+	assert.True(strings.HasSuffix(buf.String(), "12:00:01.000 trace: 4 lines suppressed\n12:00:01.000 trace: line\n"))
+
+	// disabled tracing
+	var disabled *tracer
+	kb := &TestKeyboard{}
+	wrapped, js := disabled.wrap(kb, nil)
+	assert.Same(kb, wrapped)
+	assert.Nil(js)
+	disabled.input(device.G9.Uint64(), 0)
+}
@@ -0,0 +1,22 @@
+package main
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/achilleas-k/gg13/internal/config"
+)
+
+// warnUnmapped writes a warning to w for each key pressed since prevInput that
+// isn't bound to anything, if the config asks for it, so that accidental
+// presses of unused keys don't look like a broken driver.
+func warnUnmapped(input, prevInput uint64, g13cfg *config.G13Config, w io.Writer) {
+	if !g13cfg.GetWarnUnmapped() {
+		return
+	}
+	for _, key := range pressedKeys(input, prevInput) {
+		if !g13cfg.IsBound(key) {
+			fmt.Fprintf(w, "warning: %s isn't bound to a key or action\n", key)
+		}
+	}
+}
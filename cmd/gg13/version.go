@@ -0,0 +1,135 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"runtime"
+	"runtime/debug"
+	"sync"
+	"time"
+
+	"github.com/achilleas-k/gg13/internal/control"
+	"github.com/achilleas-k/gg13/internal/update"
+	"github.com/spf13/cobra"
+)
+
+// version is the version of the driver, set when building a release with
+// -ldflags "-X main.version=v1.2.3".
+var version = "devel"
+
+// updateCheckTimeout limits how long checking for a newer release can take.
+const updateCheckTimeout = 10 * time.Second
+
+// buildVersion returns the version of the driver, or the version of the
+// module if it was installed at a tagged version with go install.
+func buildVersion() string {
+	if version != "devel" {
+		return version
+	}
+	if info, ok := debug.ReadBuildInfo(); ok && info.Main.Version != "" && info.Main.Version != "(devel)" {
+		return info.Main.Version
+	}
+	return version
+}
+
+// updateChecker looks for a newer release once and keeps the outcome for the
+// version control command. A nil *updateChecker never checks.
+type updateChecker struct {
+	mu      sync.Mutex
+	checked bool
+	latest  update.Release
+	newer   bool
+}
+
+// check looks up the latest release at url and tells the user on w if it's
+// newer than current. Failing to look it up, for example when offline, is
+// only a note.
+func (c *updateChecker) check(client *http.Client, url, current string, w io.Writer) {
+	ctx, cancel := context.WithTimeout(context.Background(), updateCheckTimeout)
+	defer cancel()
+	latest, err := update.Latest(ctx, client, url)
+	if err != nil {
+		fmt.Fprintf(w, "update check failed: %s\n", err)
+		return
+	}
+
+	newer, err := update.Newer(current, latest.Tag)
+	switch {
+	case err != nil:
+		fmt.Fprintf(w, "update check: running version %s, the latest release is %s: %s\n", current, latest.Tag, latest.URL)
+	case newer:
+		fmt.Fprintf(w, "update check: release %s is available, running %s: %s\n", latest.Tag, current, latest.URL)
+	default:
+		fmt.Fprintf(w, "update check: %s is the latest release\n", current)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.checked = true
+	c.latest = latest
+	c.newer = err == nil && newer
+}
+
+// versionReport is the reply to the version control command.
+type versionReport struct {
+	Version string `json:"version"`
+	Go      string `json:"go"`
+
+	// Latest is the tag of the latest release and LatestURL its page, if
+	// the driver checked for updates successfully
+	Latest          string `json:"latest,omitempty"`
+	LatestURL       string `json:"latest_url,omitempty"`
+	UpdateAvailable bool   `json:"update_available"`
+}
+
+func (c *updateChecker) report(current string) versionReport {
+	report := versionReport{Version: current, Go: runtime.Version()}
+	if c == nil {
+		return report
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.checked {
+		report.Latest = c.latest.Tag
+		report.LatestURL = c.latest.URL
+		report.UpdateAvailable = c.newer
+	}
+	return report
+}
+
+// handleVersion registers the control command returning the version of the
+// running driver.
+func handleVersion(server *control.Server, updates *updateChecker) {
+	server.Handle("version", func(args []string) (any, error) {
+		if len(args) != 0 {
+			return nil, fmt.Errorf("version: expected no arguments, got %d", len(args))
+		}
+		return updates.report(buildVersion()), nil
+	})
+}
+
+func ctlVersion(cmd *cobra.Command, _ []string) error {
+	cmd.SilenceUsage = true
+
+	data, err := sendControl(cmd, control.Request{Command: "version"})
+	if err != nil {
+		return err
+	}
+
+	var report versionReport
+	if err := json.Unmarshal(data, &report); err != nil {
+		return fmt.Errorf("failed decoding version: %w", err)
+	}
+	fmt.Printf("version: %s (%s)\n", report.Version, report.Go)
+	if report.Latest != "" {
+		latest := report.Latest
+		if report.UpdateAvailable {
+			latest += " (update available: " + report.LatestURL + ")"
+		}
+		fmt.Printf("latest:  %s\n", latest)
+	}
+	return nil
+}
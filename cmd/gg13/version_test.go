@@ -0,0 +1,56 @@
+package main
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"runtime"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUpdateChecker(t *testing.T) {
+	assert := assert.New(t)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`{"tag_name":"v1.2.0","html_url":"https://example.com/v1.2.0"}`))
+	}))
+	defer server.Close()
+
+	// not checking
+	var disabled *updateChecker
+	assert.Equal(versionReport{Version: "v1.1.0", Go: runtime.Version()}, disabled.report("v1.1.0"))
+
+	var buf bytes.Buffer
+	updates := &updateChecker{}
+	updates.check(server.Client(), server.URL, "v1.1.0", &buf)
+	assert.Equal("update check: release v1.2.0 is available, running v1.1.0: https://example.com/v1.2.0\n", buf.String())
+	assert.Equal(versionReport{
+		Version:         "v1.1.0",
+		Go:              runtime.Version(),
+		Latest:          "v1.2.0",
+		LatestURL:       "https://example.com/v1.2.0",
+		UpdateAvailable: true,
+	}, updates.report("v1.1.0"))
+
+	buf.Reset()
+	updates = &updateChecker{}
+	updates.check(server.Client(), server.URL, "v1.2.0", &buf)
+	assert.Equal("update check: v1.2.0 is the latest release\n", buf.String())
+	assert.False(updates.report("v1.2.0").UpdateAvailable)
+
+	buf.Reset()
+	updates = &updateChecker{}
+	updates.check(server.Client(), server.URL, "devel", &buf)
+	assert.Equal("update check: running version devel, the latest release is v1.2.0: https://example.com/v1.2.0\n", buf.String())
+	assert.False(updates.report("devel").UpdateAvailable)
+
+	// offline
+	buf.Reset()
+	updates = &updateChecker{}
+	server.Close()
+	updates.check(server.Client(), server.URL, "v1.1.0", &buf)
+	assert.Contains(buf.String(), "update check failed: ")
+	assert.Empty(updates.report("v1.1.0").Latest)
+}
@@ -0,0 +1,40 @@
+package main
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/achilleas-k/gg13/internal/config"
+	"github.com/achilleas-k/gg13/internal/mouse"
+)
+
+// handleWarps moves the pointer to the positions of the warp keys pressed
+// since the previous read. Errors are written to w.
+func handleWarps(input, prevInput uint64, g13cfg *config.G13Config, vms mouse.Mouse, w io.Writer) {
+	if vms == nil {
+		// warp bindings were added by reloading the config: the mouse is
+		// only created on (re)initialisation
+		return
+	}
+	for gkey, point := range g13cfg.GetWarps() {
+		if input&gkey.Uint64() == 0 || prevInput&gkey.Uint64() != 0 {
+			continue
+		}
+		if err := vms.MoveTo(point.X, point.Y); err != nil {
+			fmt.Fprintf(w, "mouse error moving the pointer to %s: %s\n", point, err)
+		}
+	}
+}
+
+// mouseOptions returns the options of the virtual mouse for the config, or
+// nil if no keys are bound to scrolling or moving the pointer.
+func mouseOptions(g13cfg *config.G13Config) *mouse.Options {
+	if !g13cfg.HasScrollBindings() && !g13cfg.HasWarpBindings() {
+		return nil
+	}
+	var opts mouse.Options
+	if screen := g13cfg.GetScreenSize(); screen != nil {
+		opts.ScreenWidth, opts.ScreenHeight = screen.Width, screen.Height
+	}
+	return &opts
+}
@@ -0,0 +1,21 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/achilleas-k/gg13/internal/config"
+	"github.com/achilleas-k/gg13/internal/mouse"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMouseOptions(t *testing.T) {
+	assert := assert.New(t)
+
+	assert.Nil(mouseOptions(config.NewEmpty()))
+
+	cfg := loadTestConfig(t, `{"mapping":{"scroll":{"LEFT":"up"}}}`)
+	assert.Equal(&mouse.Options{}, mouseOptions(cfg))
+
+	cfg = loadTestConfig(t, `{"screen":{"width":1920,"height":1080},"mapping":{"warp":{"G1":"10,10"}}}`)
+	assert.Equal(&mouse.Options{ScreenWidth: 1920, ScreenHeight: 1080}, mouseOptions(cfg))
+}
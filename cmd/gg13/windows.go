@@ -0,0 +1,115 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/achilleas-k/gg13/internal/config"
+	"github.com/achilleas-k/gg13/internal/desktop"
+)
+
+const (
+	// windowQueueSize is the number of window management commands that can
+	// wait for the ones before them to finish. Commands over it are dropped
+	// with a warning instead of blocking the input loop.
+	windowQueueSize = 16
+
+	// windowCommandTimeout limits how long a window management command
+	// can run.
+	windowCommandTimeout = 5 * time.Second
+)
+
+// desktopName returns the name of the desktop backend set in the config, or
+// the one detected from the environment of the session.
+func desktopName(g13cfg *config.G13Config) string {
+	if name := g13cfg.GetDesktop(); name != "" {
+		return name
+	}
+	return desktop.Detect(os.Getenv)
+}
+
+// windowManager runs the commands of window management operations one at a
+// time, in the order they're queued, like the profile hooks. A nil
+// *windowManager runs nothing.
+type windowManager struct {
+	w     io.Writer
+	queue chan []string
+	done  sync.WaitGroup
+}
+
+func startWindowManager(w io.Writer) *windowManager {
+	m := &windowManager{
+		w:     w,
+		queue: make(chan []string, windowQueueSize),
+	}
+	m.done.Add(1)
+	go func() {
+		defer m.done.Done()
+		for command := range m.queue {
+			m.exec(command)
+		}
+	}()
+	return m
+}
+
+// run queues the command of the operation for the named desktop backend. It
+// doesn't wait for it to run.
+func (m *windowManager) run(op desktop.Op, backend string) {
+	if m == nil {
+		return
+	}
+	command, err := desktop.Command(backend, op)
+	if err != nil {
+		fmt.Fprintf(m.w, "window management: %s: %s\n", op, err)
+		return
+	}
+	select {
+	case m.queue <- command:
+	default:
+		fmt.Fprintf(m.w, "window management: too many commands running: skipping %s\n", op)
+	}
+}
+
+// exec runs the command and logs its output if it fails.
+func (m *windowManager) exec(command []string) {
+	ctx, cancel := context.WithTimeout(context.Background(), windowCommandTimeout)
+	defer cancel()
+	cmd := exec.CommandContext(ctx, command[0], command[1:]...)
+	cmd.WaitDelay = time.Second
+	output, err := cmd.CombinedOutput()
+	if ctx.Err() == context.DeadlineExceeded {
+		err = fmt.Errorf("timed out after %s", windowCommandTimeout)
+	}
+	if err == nil {
+		return
+	}
+	fmt.Fprintf(m.w, "window management: %s failed: %s\n", command[0], err)
+	if output := strings.TrimSpace(string(output)); output != "" {
+		fmt.Fprintln(m.w, output)
+	}
+}
+
+// close waits for the queued commands to finish.
+func (m *windowManager) close() {
+	if m == nil {
+		return
+	}
+	close(m.queue)
+	m.done.Wait()
+}
+
+// handleWindows runs the window management operations of the keys pressed
+// since the previous read.
+func handleWindows(input, prevInput uint64, g13cfg *config.G13Config, run func(desktop.Op)) {
+	for gkey, op := range g13cfg.GetWindows() {
+		if input&gkey.Uint64() != 0 && prevInput&gkey.Uint64() == 0 {
+			run(op)
+		}
+	}
+}
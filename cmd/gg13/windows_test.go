@@ -0,0 +1,36 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/achilleas-k/gg13/internal/desktop"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWindowManager(t *testing.T) {
+	assert := assert.New(t)
+
+	// registered once, for -count
+	if desktop.Validate("test-fail") != nil {
+		desktop.Register("test-fail", func(op desktop.Op) []string {
+			return []string{"/bin/sh", "-c", "echo no such window >&2; exit 1"}
+		})
+	}
+
+	var out bytes.Buffer
+	windows := startWindowManager(&out)
+	op := desktop.Op{Kind: desktop.OpFocus, Class: "firefox"}
+	windows.run(op, "test-fail")
+	windows.run(op, "cde")
+	windows.close()
+	assert.Equal(`window management: focus firefox: unknown desktop "cde": available desktops: hyprland, sway, test-fail, x11
+window management: /bin/sh failed: exit status 1
+no such window
+`, out.String())
+
+	// a nil manager runs nothing
+	var none *windowManager
+	none.run(op, "x11")
+	none.close()
+}
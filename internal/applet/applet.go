@@ -0,0 +1,68 @@
+// Package applet provides LCD applets: sources of LCD content that are
+// rendered periodically and sent to the device.
+package applet
+
+import (
+	"fmt"
+	"image"
+	"os"
+	"time"
+
+	"github.com/achilleas-k/gg13/internal/i18n"
+)
+
+// Applet produces images for the LCD.
+type Applet interface {
+	// Render returns the image to display on the LCD.
+	Render() (image.Image, error)
+}
+
+// Runner renders an [Applet] at a fixed interval until stopped.
+type Runner struct {
+	stopChan chan struct{}
+}
+
+// Start renders the applet immediately and then every interval, passing each
+// image to the display function. Errors are printed and don't stop the
+// runner.
+func Start(a Applet, dt time.Duration, display func(image.Image) error) *Runner {
+	r := &Runner{
+		stopChan: make(chan struct{}),
+	}
+
+	update := func() {
+		img, err := a.Render()
+		if err != nil {
+			fmt.Fprintln(os.Stderr, i18n.Sprintf("applet error: %s", err))
+			return
+		}
+		if err := display(img); err != nil {
+			fmt.Fprintln(os.Stderr, i18n.Sprintf("applet display error: %s", err))
+		}
+	}
+
+	go func() {
+		ticker := time.NewTicker(dt)
+		defer ticker.Stop()
+
+		update()
+		for {
+			select {
+			case <-r.stopChan:
+				return
+			case <-ticker.C:
+				update()
+			}
+		}
+	}()
+
+	return r
+}
+
+// Stop the runner.
+func (r *Runner) Stop() {
+	if r == nil {
+		return
+	}
+	close(r.stopChan)
+}
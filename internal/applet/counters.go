@@ -0,0 +1,86 @@
+package applet
+
+import (
+	"fmt"
+	"image"
+	"maps"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/achilleas-k/gg13/internal/lcd"
+	"golang.org/x/image/font"
+)
+
+// Counters is an [Applet] showing named notification counters, for example
+// chat mentions or unread mails, one badge per line. The counters are
+// incremented by external scripts through the control socket and cleared with
+// a key binding. It is safe for concurrent use.
+type Counters struct {
+	mu     sync.Mutex
+	counts map[string]int
+	face   font.Face
+}
+
+// NewCounters returns a [Counters] applet with no counters, rendered with the
+// face.
+func NewCounters(face font.Face) *Counters {
+	return &Counters{
+		counts: make(map[string]int),
+		face:   face,
+	}
+}
+
+// Increment adds n to the named counter, creating it if needed, and returns
+// the new count.
+func (c *Counters) Increment(name string, n int) (int, error) {
+	if name == "" || strings.ContainsFunc(name, func(r rune) bool { return r == '\n' || r == ' ' }) {
+		return 0, fmt.Errorf("invalid counter name %q: it must be a non-empty single word", name)
+	}
+	if n <= 0 {
+		return 0, fmt.Errorf("invalid increment %d: it must be positive", n)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.counts[name] += n
+	return c.counts[name], nil
+}
+
+// Clear removes the named counter, or all of them if name is empty.
+func (c *Counters) Clear(name string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if name == "" {
+		clear(c.counts)
+		return
+	}
+	delete(c.counts, name)
+}
+
+// Counts returns a copy of the counters.
+func (c *Counters) Counts() map[string]int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return maps.Clone(c.counts)
+}
+
+// Text returns the counters as text, one per line, sorted by name.
+func (c *Counters) Text() string {
+	counts := c.Counts()
+	if len(counts) == 0 {
+		return "NO NOTIFICATIONS"
+	}
+
+	names := slices.Sorted(maps.Keys(counts))
+	lines := make([]string, len(names))
+	for idx, name := range names {
+		lines[idx] = fmt.Sprintf("%s [%d]", name, counts[name])
+	}
+	return strings.Join(lines, "\n")
+}
+
+// Render implements [Applet].
+func (c *Counters) Render() (image.Image, error) {
+	return lcd.TextPageFace(c.Text(), c.face), nil
+}
@@ -0,0 +1,73 @@
+package applet_test
+
+import (
+	"testing"
+
+	"github.com/achilleas-k/gg13/internal/applet"
+	"github.com/achilleas-k/gg13/internal/lcd"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCounters(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	c := applet.NewCounters(lcd.Font3x5)
+	assert.Equal("NO NOTIFICATIONS", c.Text())
+
+	n, err := c.Increment("mentions", 1)
+	require.NoError(err)
+	assert.Equal(1, n)
+	n, err = c.Increment("mentions", 2)
+	require.NoError(err)
+	assert.Equal(3, n)
+	_, err = c.Increment("mail", 1)
+	require.NoError(err)
+
+	assert.Equal(map[string]int{"mail": 1, "mentions": 3}, c.Counts())
+	assert.Equal("mail [1]\nmentions [3]", c.Text())
+
+	img, err := c.Render()
+	require.NoError(err)
+	assert.Equal(lcd.TextPage("mail [1]\nmentions [3]"), img)
+
+	c.Clear("mail")
+	assert.Equal(map[string]int{"mentions": 3}, c.Counts())
+	c.Clear("")
+	assert.Empty(c.Counts())
+}
+
+func TestCountersErrors(t *testing.T) {
+	c := applet.NewCounters(lcd.Font3x5)
+
+	testCases := map[string]struct {
+		name        string
+		n           int
+		expectedErr string
+	}{
+		"empty-name": {
+			name:        "",
+			n:           1,
+			expectedErr: `invalid counter name "": it must be a non-empty single word`,
+		},
+		"two-words": {
+			name:        "new mail",
+			n:           1,
+			expectedErr: `invalid counter name "new mail": it must be a non-empty single word`,
+		},
+		"zero": {
+			name:        "mail",
+			n:           0,
+			expectedErr: "invalid increment 0: it must be positive",
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			_, err := c.Increment(tc.name, tc.n)
+			assert.EqualError(t, err, tc.expectedErr)
+		})
+	}
+	assert.Empty(t, c.Counts())
+}
@@ -0,0 +1,10 @@
+package applet
+
+import "time"
+
+// SetTemplateSources replaces the directory the template page reads system
+// statistics from and its clock, for testing.
+func SetTemplateSources(a *TemplatePage, procDir string, now func() time.Time) {
+	a.procDir = procDir
+	a.now = now
+}
@@ -0,0 +1,85 @@
+package applet
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"image"
+	"io"
+	"net/http"
+	"text/template"
+	"time"
+
+	"github.com/achilleas-k/gg13/internal/lcd"
+	"golang.org/x/image/font"
+)
+
+// maxResponseSize is the largest JSON document that's read.
+const maxResponseSize = 1 << 20
+
+// HTTPJSON is an [Applet] that fetches a JSON document from a URL and renders
+// it as a text page using a template. The decoded document is the data for
+// the template, so fields can be selected with, e.g., {{.speed}}.
+type HTTPJSON struct {
+	url      string
+	template *template.Template
+	client   *http.Client
+	face     font.Face
+}
+
+// NewHTTPJSON returns an [HTTPJSON] applet for the URL and template text,
+// rendered with the face. Requests time out after the given duration.
+func NewHTTPJSON(url string, tmpl string, timeout time.Duration, face font.Face) (*HTTPJSON, error) {
+	t, err := template.New("http_page").Parse(tmpl)
+	if err != nil {
+		return nil, fmt.Errorf("failed parsing template: %w", err)
+	}
+
+	return &HTTPJSON{
+		url:      url,
+		template: t,
+		client:   &http.Client{Timeout: timeout},
+		face:     face,
+	}, nil
+}
+
+// Text fetches the document and returns the expanded template.
+func (a *HTTPJSON) Text() (string, error) {
+	resp, err := a.client.Get(a.url)
+	if err != nil {
+		return "", fmt.Errorf("failed fetching %q: %w", a.url, err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("failed fetching %q: %s", a.url, resp.Status)
+	}
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxResponseSize+1))
+	if err != nil {
+		return "", fmt.Errorf("failed fetching %q: %w", a.url, err)
+	}
+	if len(body) > maxResponseSize {
+		return "", fmt.Errorf("response from %q is larger than %d bytes", a.url, maxResponseSize)
+	}
+
+	var data any
+	if err := json.Unmarshal(body, &data); err != nil {
+		return "", fmt.Errorf("failed decoding response from %q: %w", a.url, err)
+	}
+
+	var buf bytes.Buffer
+	if err := a.template.Execute(&buf, data); err != nil {
+		return "", fmt.Errorf("failed executing template: %w", err)
+	}
+	return buf.String(), nil
+}
+
+// Render implements [Applet].
+func (a *HTTPJSON) Render() (image.Image, error) {
+	text, err := a.Text()
+	if err != nil {
+		return nil, err
+	}
+	return lcd.TextPageFace(text, a.face), nil
+}
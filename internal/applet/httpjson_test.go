@@ -0,0 +1,77 @@
+package applet_test
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/achilleas-k/gg13/internal/applet"
+	"github.com/achilleas-k/gg13/internal/lcd"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestServer(t *testing.T, body string) *httptest.Server {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/data.json" {
+			http.NotFound(w, r)
+			return
+		}
+		fmt.Fprint(w, body)
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestHTTPJSON(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	server := newTestServer(t, `{"speed":142,"gear":"4","engine":{"rpm":6500}}`)
+
+	a, err := applet.NewHTTPJSON(server.URL+"/data.json", "SPEED {{.speed}}\nGEAR {{.gear}} RPM {{.engine.rpm}}", time.Second, lcd.Font3x5)
+	require.NoError(err)
+
+	text, err := a.Text()
+	require.NoError(err)
+	assert.Equal("SPEED 142\nGEAR 4 RPM 6500", text)
+
+	img, err := a.Render()
+	require.NoError(err)
+	assert.Equal(lcd.TextPage(text), img)
+}
+
+func TestHTTPJSONErrors(t *testing.T) {
+	server := newTestServer(t, `not json`)
+
+	t.Run("bad-template", func(t *testing.T) {
+		_, err := applet.NewHTTPJSON(server.URL, "{{", time.Second, lcd.Font3x5)
+		assert.ErrorContains(t, err, "failed parsing template")
+	})
+
+	t.Run("not-found", func(t *testing.T) {
+		a, err := applet.NewHTTPJSON(server.URL+"/nope", "", time.Second, lcd.Font3x5)
+		require.NoError(t, err)
+		_, err = a.Render()
+		assert.ErrorContains(t, err, "404 Not Found")
+	})
+
+	t.Run("bad-json", func(t *testing.T) {
+		a, err := applet.NewHTTPJSON(server.URL+"/data.json", "", time.Second, lcd.Font3x5)
+		require.NoError(t, err)
+		_, err = a.Render()
+		assert.ErrorContains(t, err, "failed decoding response")
+	})
+
+	t.Run("too-large", func(t *testing.T) {
+		large := newTestServer(t, `{"data":"`+strings.Repeat("x", 1<<20)+`"}`)
+		a, err := applet.NewHTTPJSON(large.URL+"/data.json", "", time.Second, lcd.Font3x5)
+		require.NoError(t, err)
+		_, err = a.Render()
+		assert.ErrorContains(t, err, "larger than 1048576 bytes")
+	})
+}
@@ -0,0 +1,262 @@
+package applet
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"image"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"sync"
+	"text/template"
+	"text/template/parse"
+	"time"
+
+	"github.com/achilleas-k/gg13/internal/lcd"
+	"golang.org/x/image/font"
+)
+
+// execTimeout is how long a command run by a template page can take.
+const execTimeout = 5 * time.Second
+
+// TemplatePage is an [Applet] rendering a text page from a template with
+// system information, like conky. The template uses the text/template syntax
+// with these functions:
+//
+//	{{cpu}}            CPU usage since the previous render, in percent
+//	{{mem}}            memory in use, in percent
+//	{{time "15:04"}}   the current time in the Go layout, 15:04:05 if omitted
+//	{{hostname}}       the host name
+//	{{exec "cmd"}}     the output of a shell command, run on every render
+//	{{execi 60 "cmd"}} the output of a shell command, run at most every 60s
+//
+// Commands can't be run when the daemon is sandboxed.
+type TemplatePage struct {
+	template *template.Template
+	face     font.Face
+
+	// directory to read system statistics from
+	procDir string
+	now     func() time.Time
+
+	mu sync.Mutex
+	// CPU time counters from the previous render
+	prevIdle, prevTotal uint64
+	// output of commands run with execi, by command
+	execCache map[string]execResult
+}
+
+type execResult struct {
+	output string
+	expiry time.Time
+}
+
+// NewTemplatePage returns a [TemplatePage] for the template text, rendered with
+// the face.
+func NewTemplatePage(tmpl string, face font.Face) (*TemplatePage, error) {
+	a := &TemplatePage{
+		face:      face,
+		procDir:   "/proc",
+		now:       time.Now,
+		execCache: make(map[string]execResult),
+	}
+	t, err := template.New("template_page").Funcs(template.FuncMap{
+		"cpu":      a.cpu,
+		"mem":      a.mem,
+		"time":     a.time,
+		"hostname": os.Hostname,
+		"exec":     a.exec,
+		"execi":    a.execi,
+	}).Parse(tmpl)
+	if err != nil {
+		return nil, fmt.Errorf("failed parsing template: %w", err)
+	}
+	a.template = t
+	return a, nil
+}
+
+// RunsCommands returns true if the template runs shell commands with exec or
+// execi.
+func (a *TemplatePage) RunsCommands() bool {
+	for _, t := range a.template.Templates() {
+		if t.Tree != nil && callsExec(t.Tree.Root) {
+			return true
+		}
+	}
+	return false
+}
+
+// callsExec returns true if the node of a template, or any node below it,
+// calls exec or execi.
+func callsExec(node parse.Node) bool {
+	switch node := node.(type) {
+	case *parse.IdentifierNode:
+		return node.Ident == "exec" || node.Ident == "execi"
+	case *parse.ListNode:
+		if node == nil {
+			return false
+		}
+		for _, n := range node.Nodes {
+			if callsExec(n) {
+				return true
+			}
+		}
+	case *parse.ActionNode:
+		return callsExec(node.Pipe)
+	case *parse.PipeNode:
+		if node == nil {
+			return false
+		}
+		for _, cmd := range node.Cmds {
+			if callsExec(cmd) {
+				return true
+			}
+		}
+	case *parse.CommandNode:
+		for _, arg := range node.Args {
+			if callsExec(arg) {
+				return true
+			}
+		}
+	case *parse.ChainNode:
+		return callsExec(node.Node)
+	case *parse.IfNode:
+		return callsExec(node.Pipe) || callsExec(node.List) || callsExec(node.ElseList)
+	case *parse.RangeNode:
+		return callsExec(node.Pipe) || callsExec(node.List) || callsExec(node.ElseList)
+	case *parse.WithNode:
+		return callsExec(node.Pipe) || callsExec(node.List) || callsExec(node.ElseList)
+	case *parse.TemplateNode:
+		return callsExec(node.Pipe)
+	}
+	return false
+}
+
+// Text returns the expanded template.
+func (a *TemplatePage) Text() (string, error) {
+	var buf bytes.Buffer
+	if err := a.template.Execute(&buf, nil); err != nil {
+		return "", fmt.Errorf("failed executing template: %w", err)
+	}
+	return buf.String(), nil
+}
+
+// Render implements [Applet].
+func (a *TemplatePage) Render() (image.Image, error) {
+	text, err := a.Text()
+	if err != nil {
+		return nil, err
+	}
+	return lcd.TextPageFace(text, a.face), nil
+}
+
+// cpu returns the CPU usage since it was last called, or since boot the first
+// time.
+func (a *TemplatePage) cpu() (int, error) {
+	data, err := os.ReadFile(a.procDir + "/stat")
+	if err != nil {
+		return 0, err
+	}
+	line, _, _ := strings.Cut(string(data), "\n")
+	fields := strings.Fields(line)
+	if len(fields) < 5 || fields[0] != "cpu" {
+		return 0, fmt.Errorf("unexpected format of %s/stat", a.procDir)
+	}
+	var idle, total uint64
+	for idx, field := range fields[1:] {
+		n, err := strconv.ParseUint(field, 10, 64)
+		if err != nil {
+			return 0, fmt.Errorf("unexpected format of %s/stat: %w", a.procDir, err)
+		}
+		total += n
+		// idle and iowait
+		if idx == 3 || idx == 4 {
+			idle += n
+		}
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	dIdle, dTotal := idle-a.prevIdle, total-a.prevTotal
+	a.prevIdle, a.prevTotal = idle, total
+	if dTotal == 0 {
+		return 0, nil
+	}
+	return int(100 * (dTotal - dIdle) / dTotal), nil
+}
+
+// mem returns the memory in use, which is all but the available memory.
+func (a *TemplatePage) mem() (int, error) {
+	file, err := os.Open(a.procDir + "/meminfo")
+	if err != nil {
+		return 0, err
+	}
+	defer file.Close()
+
+	values := make(map[string]uint64)
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		name, value, ok := strings.Cut(scanner.Text(), ":")
+		if !ok {
+			continue
+		}
+		if n, err := strconv.ParseUint(strings.TrimSuffix(strings.TrimSpace(value), " kB"), 10, 64); err == nil {
+			values[name] = n
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return 0, err
+	}
+	total, available := values["MemTotal"], values["MemAvailable"]
+	if total == 0 || available > total {
+		return 0, fmt.Errorf("unexpected format of %s/meminfo", a.procDir)
+	}
+	return int(100 * (total - available) / total), nil
+}
+
+func (a *TemplatePage) time(layout ...string) (string, error) {
+	switch len(layout) {
+	case 0:
+		return a.now().Format(time.TimeOnly), nil
+	case 1:
+		return a.now().Format(layout[0]), nil
+	default:
+		return "", fmt.Errorf("time: expected at most one layout, got %d", len(layout))
+	}
+}
+
+// exec runs the command with the shell and returns its output without the
+// trailing newline.
+func (a *TemplatePage) exec(command string) (string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), execTimeout)
+	defer cancel()
+	output, err := exec.CommandContext(ctx, "/bin/sh", "-c", command).Output()
+	if err != nil {
+		return "", fmt.Errorf("command %q failed: %w", command, err)
+	}
+	return strings.TrimRight(string(output), "\n"), nil
+}
+
+// execi is like exec, but runs the command again only after the interval, in
+// seconds, has passed.
+func (a *TemplatePage) execi(interval int, command string) (string, error) {
+	a.mu.Lock()
+	cached, ok := a.execCache[command]
+	a.mu.Unlock()
+	now := a.now()
+	if ok && now.Before(cached.expiry) {
+		return cached.output, nil
+	}
+
+	output, err := a.exec(command)
+	if err != nil {
+		return "", err
+	}
+	a.mu.Lock()
+	a.execCache[command] = execResult{output: output, expiry: now.Add(time.Duration(interval) * time.Second)}
+	a.mu.Unlock()
+	return output, nil
+}
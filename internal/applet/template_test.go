@@ -0,0 +1,118 @@
+package applet_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/achilleas-k/gg13/internal/applet"
+	"github.com/achilleas-k/gg13/internal/lcd"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func writeProcFiles(t *testing.T, dir string, stat string) {
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "stat"), []byte(stat), 0o600))
+	meminfo := "MemTotal:       16000000 kB\nMemFree:         2000000 kB\nMemAvailable:    4000000 kB\n"
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "meminfo"), []byte(meminfo), 0o600))
+}
+
+func TestTemplatePage(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	procDir := t.TempDir()
+	// user nice system idle iowait irq softirq
+	writeProcFiles(t, procDir, "cpu  100 0 100 700 100 0 0\ncpu0 100 0 100 700 100 0 0\n")
+	now := time.Date(2026, 10, 16, 9, 30, 5, 0, time.UTC)
+
+	a, err := applet.NewTemplatePage(`CPU {{cpu}}% MEM {{mem}}%
+{{time "15:04"}} {{time}}`, lcd.Font3x5)
+	require.NoError(err)
+	applet.SetTemplateSources(a, procDir, func() time.Time { return now })
+
+	// the first reading is since boot
+	text, err := a.Text()
+	require.NoError(err)
+	assert.Equal("CPU 20% MEM 75%\n09:30 09:30:05", text)
+
+	// 300 of the 400 new ticks were busy
+	writeProcFiles(t, procDir, "cpu  300 0 200 750 150 0 0\n")
+	text, err = a.Text()
+	require.NoError(err)
+	assert.Equal("CPU 75% MEM 75%\n09:30 09:30:05", text)
+
+	img, err := a.Render()
+	require.NoError(err)
+	assert.Equal(lcd.TextPage("CPU 0% MEM 75%\n09:30 09:30:05"), img)
+
+	hostname, err := os.Hostname()
+	require.NoError(err)
+	a, err = applet.NewTemplatePage("{{hostname}}", lcd.Font3x5)
+	require.NoError(err)
+	text, err = a.Text()
+	require.NoError(err)
+	assert.Equal(hostname, text)
+}
+
+func TestTemplatePageExec(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	counter := filepath.Join(t.TempDir(), "runs")
+	now := time.Date(2026, 10, 16, 9, 30, 0, 0, time.UTC)
+	a, err := applet.NewTemplatePage(`{{exec "echo hello"}} {{execi 60 "echo x >> `+counter+`; wc -l < `+counter+`"}}`, lcd.Font3x5)
+	require.NoError(err)
+	applet.SetTemplateSources(a, "/proc", func() time.Time { return now })
+
+	text, err := a.Text()
+	require.NoError(err)
+	assert.Equal("hello 1", text)
+
+	// cached until the interval passes
+	now = now.Add(30 * time.Second)
+	text, err = a.Text()
+	require.NoError(err)
+	assert.Equal("hello 1", text)
+
+	now = now.Add(30 * time.Second)
+	text, err = a.Text()
+	require.NoError(err)
+	assert.Equal("hello 2", text)
+
+	a, err = applet.NewTemplatePage(`{{exec "exit 3"}}`, lcd.Font3x5)
+	require.NoError(err)
+	_, err = a.Text()
+	assert.ErrorContains(err, `failed executing template: template: template_page:1:2: executing "template_page" at <exec "exit 3">: error calling exec: command "exit 3" failed: exit status 3`)
+}
+
+func TestTemplatePageRunsCommands(t *testing.T) {
+	for tmpl, expected := range map[string]bool{
+		`CPU {{cpu}}% {{time "15:04"}}`:                            false,
+		`{{exec "uptime"}}`:                                        true,
+		`{{if true}}{{execi 60 "uptime"}}{{end}}`:                  true,
+		`{{with $x := cpu}}{{$x}}{{else}}{{exec "date"}}{{end}}`:   true,
+		`{{printf "%s" (exec "date")}}`:                            true,
+		`{{define "up"}}{{exec "uptime"}}{{end}}{{template "up"}}`: true,
+		`{{printf "%s" "exec"}} {{hostname}} {{mem}}%`:             false,
+	} {
+		a, err := applet.NewTemplatePage(tmpl, lcd.Font3x5)
+		require.NoError(t, err)
+		assert.Equal(t, expected, a.RunsCommands(), tmpl)
+	}
+}
+
+func TestTemplatePageErrors(t *testing.T) {
+	_, err := applet.NewTemplatePage("{{cpu", lcd.Font3x5)
+	assert.ErrorContains(t, err, "failed parsing template: ")
+
+	_, err = applet.NewTemplatePage("{{disk}}", lcd.Font3x5)
+	assert.ErrorContains(t, err, `failed parsing template: template: template_page:1: function "disk" not defined`)
+
+	a, err := applet.NewTemplatePage("{{cpu}}", lcd.Font3x5)
+	require.NoError(t, err)
+	applet.SetTemplateSources(a, t.TempDir(), time.Now)
+	_, err = a.Text()
+	assert.ErrorContains(t, err, "error calling cpu: open ")
+}
@@ -0,0 +1,173 @@
+package applet
+
+import (
+	"bufio"
+	"fmt"
+	"image"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/achilleas-k/gg13/internal/i18n"
+	"github.com/achilleas-k/gg13/internal/lcd"
+	"golang.org/x/image/font"
+)
+
+// textFileMaxLines is the number of lines kept from a stream. It's more than
+// fit on the LCD with the built-in font.
+const textFileMaxLines = 16
+
+// TextFile is an [Applet] showing the contents of a text file, so that
+// external scripts can update the LCD by writing to it. A regular file is
+// re-read when it changes. A FIFO, or standard input for the path "-", is read
+// in the background and the page shows the last lines written since a writer
+// last opened it. It is safe for concurrent use.
+type TextFile struct {
+	path string
+	face font.Face
+
+	mu   sync.Mutex
+	text string
+
+	// modification time and size of a regular file when it was last read
+	modTime time.Time
+	size    int64
+
+	// stream is true for a FIFO or standard input
+	stream bool
+	// the open FIFO, if a writer is connected
+	fifo   *os.File
+	closed bool
+}
+
+// NewTextFile returns a [TextFile] applet for the path, rendered with the face.
+// Reading starts immediately for a FIFO or standard input.
+func NewTextFile(path string, face font.Face) (*TextFile, error) {
+	a := &TextFile{
+		path: path,
+		face: face,
+	}
+	if path == "-" {
+		a.stream = true
+		go a.readLines(os.Stdin)
+		return a, nil
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed reading text file: %w", err)
+	}
+	if info.Mode()&os.ModeNamedPipe != 0 {
+		a.stream = true
+		go a.readFIFO()
+	}
+	return a, nil
+}
+
+// readFIFO reads the FIFO until the applet is closed, reopening it every time
+// the writer closes it.
+func (a *TextFile) readFIFO() {
+	for !a.isClosed() {
+		// blocks until a writer opens the FIFO
+		file, err := os.Open(a.path)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, i18n.Sprintf("text file applet: %s", err))
+			return
+		}
+
+		a.mu.Lock()
+		if a.closed {
+			a.mu.Unlock()
+			_ = file.Close()
+			return
+		}
+		a.fifo = file
+		a.mu.Unlock()
+
+		a.readLines(file)
+
+		a.mu.Lock()
+		a.fifo = nil
+		a.mu.Unlock()
+		_ = file.Close()
+	}
+}
+
+func (a *TextFile) isClosed() bool {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.closed
+}
+
+// readLines shows the last lines read from r until the end of the stream.
+// The first line replaces the previous text.
+func (a *TextFile) readLines(r io.Reader) {
+	var lines []string
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		lines = append(lines, scanner.Text())
+		if len(lines) > textFileMaxLines {
+			lines = lines[1:]
+		}
+		a.mu.Lock()
+		a.text = strings.Join(lines, "\n")
+		a.mu.Unlock()
+	}
+}
+
+// Text returns the text to show, re-reading a regular file if it changed.
+func (a *TextFile) Text() (string, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.stream {
+		return a.text, nil
+	}
+
+	info, err := os.Stat(a.path)
+	if err != nil {
+		return "", fmt.Errorf("failed reading text file: %w", err)
+	}
+	if info.ModTime().Equal(a.modTime) && info.Size() == a.size {
+		return a.text, nil
+	}
+	data, err := os.ReadFile(a.path)
+	if err != nil {
+		return "", fmt.Errorf("failed reading text file: %w", err)
+	}
+	a.text = strings.TrimRight(string(data), "\n")
+	a.modTime, a.size = info.ModTime(), info.Size()
+	return a.text, nil
+}
+
+// Render implements [Applet].
+func (a *TextFile) Render() (image.Image, error) {
+	text, err := a.Text()
+	if err != nil {
+		return nil, err
+	}
+	return lcd.TextPageFace(text, a.face), nil
+}
+
+// Close stops reading a FIFO.
+func (a *TextFile) Close() error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.closed || !a.stream || a.path == "-" {
+		a.closed = true
+		return nil
+	}
+	a.closed = true
+	if a.fifo != nil {
+		return a.fifo.Close()
+	}
+	// the reader is waiting for a writer: connecting as one lets it see that
+	// the applet is closed
+	writer, err := os.OpenFile(a.path, os.O_WRONLY|syscall.O_NONBLOCK, 0)
+	if err != nil {
+		return nil
+	}
+	return writer.Close()
+}
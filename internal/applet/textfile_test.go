@@ -0,0 +1,107 @@
+package applet_test
+
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/achilleas-k/gg13/internal/applet"
+	"github.com/achilleas-k/gg13/internal/lcd"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTextFile(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	path := filepath.Join(t.TempDir(), "status.txt")
+	require.NoError(os.WriteFile(path, []byte("BUILD OK\n"), 0o600))
+
+	a, err := applet.NewTextFile(path, lcd.Font3x5)
+	require.NoError(err)
+	defer a.Close()
+
+	text, err := a.Text()
+	require.NoError(err)
+	assert.Equal("BUILD OK", text)
+	img, err := a.Render()
+	require.NoError(err)
+	assert.Equal(lcd.TextPage("BUILD OK"), img)
+
+	// changes are picked up on the next render
+	require.NoError(os.WriteFile(path, []byte("BUILD FAILED\nTESTS"), 0o600))
+	text, err = a.Text()
+	require.NoError(err)
+	assert.Equal("BUILD FAILED\nTESTS", text)
+
+	require.NoError(os.Remove(path))
+	_, err = a.Text()
+	assert.ErrorContains(err, "failed reading text file: ")
+
+	_, err = applet.NewTextFile(path, lcd.Font3x5)
+	assert.ErrorContains(err, "failed reading text file: ")
+}
+
+func TestTextFileFIFO(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	path := filepath.Join(t.TempDir(), "status.fifo")
+	require.NoError(syscall.Mkfifo(path, 0o600))
+
+	a, err := applet.NewTextFile(path, lcd.Font3x5)
+	require.NoError(err)
+
+	text, err := a.Text()
+	require.NoError(err)
+	assert.Empty(text)
+
+	write := func(data string) {
+		w, err := os.OpenFile(path, os.O_WRONLY, 0)
+		require.NoError(err)
+		_, err = w.WriteString(data)
+		require.NoError(err)
+		require.NoError(w.Close())
+	}
+	showsText := func(expected string) func() bool {
+		return func() bool {
+			text, err := a.Text()
+			return err == nil && text == expected
+		}
+	}
+
+	write("one\ntwo\n")
+	assert.Eventually(showsText("one\ntwo"), time.Second, time.Millisecond)
+
+	// a new writer replaces the text, unless it connected before the reader
+	// saw the previous one close
+	write("three\n")
+	assert.Eventually(func() bool {
+		text, err := a.Text()
+		return err == nil && (text == "three" || text == "one\ntwo\nthree")
+	}, time.Second, time.Millisecond)
+
+	// only the last lines are kept
+	var lines []string
+	for idx := range 20 {
+		lines = append(lines, strconv.Itoa(idx))
+	}
+	write(strings.Join(lines, "\n") + "\n")
+	assert.Eventually(showsText(strings.Join(lines[4:], "\n")), time.Second, time.Millisecond)
+
+	// closing stops the reader waiting for the next writer
+	require.NoError(a.Close())
+	assert.Eventually(func() bool {
+		w, err := os.OpenFile(path, os.O_WRONLY|syscall.O_NONBLOCK, 0)
+		if err == nil {
+			w.Close()
+		}
+		// no reader
+		return err != nil
+	}, time.Second, time.Millisecond)
+}
@@ -0,0 +1,140 @@
+package applet
+
+import (
+	"fmt"
+	"image"
+	"sync"
+	"time"
+
+	"github.com/achilleas-k/gg13/internal/lcd"
+	"golang.org/x/image/font"
+)
+
+// Timer is an [Applet] counting down from a fixed duration, for example a
+// Pomodoro timer. It is started, paused, and reset with [Timer.StartPause] and
+// [Timer.Reset], which are meant to be bound to G13 keys. It is safe for
+// concurrent use.
+type Timer struct {
+	mu       sync.Mutex
+	duration time.Duration
+	face     font.Face
+
+	// time left when the timer isn't running
+	remaining time.Duration
+
+	// when the timer runs out; zero when the timer isn't running
+	deadline time.Time
+	expiry   *time.Timer
+
+	onDone func()
+}
+
+// NewTimer returns a stopped [Timer] for the duration, rendered with the face.
+func NewTimer(duration time.Duration, face font.Face) *Timer {
+	return &Timer{
+		duration:  duration,
+		face:      face,
+		remaining: duration,
+	}
+}
+
+// OnDone sets a function to call, in its own goroutine, when the timer runs
+// out.
+func (t *Timer) OnDone(f func()) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.onDone = f
+}
+
+// StartPause starts the timer if it's stopped and pauses it if it's running.
+// Starting a timer that ran out starts it again from the full duration.
+func (t *Timer) StartPause() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.running() {
+		t.remaining = time.Until(t.deadline)
+		t.stop()
+		return
+	}
+
+	if t.remaining <= 0 {
+		t.remaining = t.duration
+	}
+	t.deadline = time.Now().Add(t.remaining)
+	t.expiry = time.AfterFunc(t.remaining, t.expire)
+}
+
+// Reset stops the timer and sets it back to the full duration.
+func (t *Timer) Reset() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.stop()
+	t.remaining = t.duration
+}
+
+// Remaining returns the time left and whether the timer is running.
+func (t *Timer) Remaining() (time.Duration, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.running() {
+		return max(time.Until(t.deadline), 0), true
+	}
+	return t.remaining, false
+}
+
+// Render implements [Applet].
+func (t *Timer) Render() (image.Image, error) {
+	remaining, running := t.Remaining()
+
+	var status string
+	switch {
+	case running:
+		status = "RUNNING"
+	case remaining <= 0:
+		status = "DONE"
+	case remaining == t.duration:
+		status = "READY"
+	default:
+		status = "PAUSED"
+	}
+	return lcd.TextPageFace(fmt.Sprintf("TIMER %s\n%s", status, formatRemaining(remaining)), t.face), nil
+}
+
+// formatRemaining formats the duration as minutes and seconds, rounding up so
+// that the timer only shows 00:00 once it ran out.
+func formatRemaining(dt time.Duration) string {
+	seconds := int((dt + time.Second - 1) / time.Second)
+	return fmt.Sprintf("%02d:%02d", seconds/60, seconds%60)
+}
+
+func (t *Timer) running() bool {
+	return !t.deadline.IsZero()
+}
+
+// stop stops the timer without changing the remaining time. The lock must be
+// held.
+func (t *Timer) stop() {
+	if t.expiry != nil {
+		t.expiry.Stop()
+		t.expiry = nil
+	}
+	t.deadline = time.Time{}
+}
+
+func (t *Timer) expire() {
+	t.mu.Lock()
+	if !t.running() || time.Now().Before(t.deadline) {
+		// paused or restarted after the expiry was scheduled
+		t.mu.Unlock()
+		return
+	}
+	t.stop()
+	t.remaining = 0
+	onDone := t.onDone
+	t.mu.Unlock()
+
+	if onDone != nil {
+		go onDone()
+	}
+}
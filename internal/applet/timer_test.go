@@ -0,0 +1,90 @@
+package applet_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/achilleas-k/gg13/internal/applet"
+	"github.com/achilleas-k/gg13/internal/lcd"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTimer(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	timer := applet.NewTimer(time.Minute, lcd.Font3x5)
+	remaining, running := timer.Remaining()
+	assert.Equal(time.Minute, remaining)
+	assert.False(running)
+
+	img, err := timer.Render()
+	require.NoError(err)
+	assert.Equal(lcd.TextPage("TIMER READY\n01:00"), img)
+
+	timer.StartPause()
+	remaining, running = timer.Remaining()
+	assert.True(running)
+	assert.LessOrEqual(remaining, time.Minute)
+
+	timer.StartPause()
+	paused, running := timer.Remaining()
+	assert.False(running)
+	assert.Greater(paused, time.Minute-time.Second)
+	// paused timers don't count down
+	time.Sleep(10 * time.Millisecond)
+	remaining, _ = timer.Remaining()
+	assert.Equal(paused, remaining)
+
+	img, err = timer.Render()
+	require.NoError(err)
+	assert.Equal(lcd.TextPage("TIMER PAUSED\n01:00"), img)
+
+	timer.Reset()
+	remaining, running = timer.Remaining()
+	assert.Equal(time.Minute, remaining)
+	assert.False(running)
+}
+
+func TestTimerDone(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	timer := applet.NewTimer(20*time.Millisecond, lcd.Font3x5)
+	done := make(chan struct{})
+	timer.OnDone(func() { close(done) })
+	timer.StartPause()
+
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("timer didn't run out")
+	}
+
+	remaining, running := timer.Remaining()
+	assert.Zero(remaining)
+	assert.False(running)
+	img, err := timer.Render()
+	require.NoError(err)
+	assert.Equal(lcd.TextPage("TIMER DONE\n00:00"), img)
+
+	// starting again uses the full duration
+	timer.StartPause()
+	remaining, running = timer.Remaining()
+	assert.True(running)
+	assert.Greater(remaining, time.Duration(0))
+	timer.Reset()
+}
+
+func TestTimerPausedDoesNotRunOut(t *testing.T) {
+	timer := applet.NewTimer(20*time.Millisecond, lcd.Font3x5)
+	timer.OnDone(func() { t.Error("paused timer ran out") })
+	timer.StartPause()
+	timer.StartPause()
+	time.Sleep(50 * time.Millisecond)
+
+	remaining, running := timer.Remaining()
+	assert.False(t, running)
+	assert.Greater(t, remaining, time.Duration(0))
+}
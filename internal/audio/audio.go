@@ -0,0 +1,142 @@
+// Package audio switches the default output and input devices of the sound
+// server with pactl, which talks to PipeWire through its PulseAudio
+// compatibility as well as to PulseAudio itself.
+package audio
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os/exec"
+	"slices"
+	"strings"
+	"time"
+)
+
+// commandTimeout limits how long each pactl command can run.
+const commandTimeout = 5 * time.Second
+
+// Direction is whether a device plays or records sound.
+type Direction string
+
+const (
+	Output Direction = "output"
+	Input  Direction = "input"
+)
+
+// pactl calls the output devices sinks and the input devices sources.
+func (dir Direction) pactlName() string {
+	if dir == Input {
+		return "source"
+	}
+	return "sink"
+}
+
+// Device is an output or input device of the sound server.
+type Device struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+}
+
+// String returns the description of the device, or its name if it has none.
+func (d Device) String() string {
+	if d.Description != "" {
+		return d.Description
+	}
+	return d.Name
+}
+
+// Client runs pactl commands.
+type Client struct {
+	// run runs pactl with the arguments and returns its output
+	run func(args ...string) ([]byte, error)
+}
+
+// New returns a [Client] running the pactl on the PATH.
+func New() *Client {
+	return &Client{run: runPactl}
+}
+
+func runPactl(args ...string) ([]byte, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), commandTimeout)
+	defer cancel()
+	output, err := exec.CommandContext(ctx, "pactl", args...).Output()
+	if ctx.Err() == context.DeadlineExceeded {
+		return nil, fmt.Errorf("pactl timed out after %s", commandTimeout)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("pactl %s failed: %w", args[0], err)
+	}
+	return output, nil
+}
+
+// Devices returns the devices in the direction, in the order of the sound
+// server. The monitors of the outputs aren't listed as inputs.
+func (c *Client) Devices(dir Direction) ([]Device, error) {
+	output, err := c.run("--format=json", "list", dir.pactlName()+"s")
+	if err != nil {
+		return nil, err
+	}
+	var devices []Device
+	if err := json.Unmarshal(output, &devices); err != nil {
+		return nil, fmt.Errorf("failed reading the %s devices: %w", dir, err)
+	}
+	return slices.DeleteFunc(devices, func(d Device) bool {
+		return strings.HasSuffix(d.Name, ".monitor")
+	}), nil
+}
+
+// Default returns the name of the default device in the direction.
+func (c *Client) Default(dir Direction) (string, error) {
+	output, err := c.run("get-default-" + dir.pactlName())
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(output)), nil
+}
+
+func (c *Client) setDefault(dir Direction, device Device) (Device, error) {
+	if _, err := c.run("set-default-"+dir.pactlName(), device.Name); err != nil {
+		return Device{}, err
+	}
+	return device, nil
+}
+
+// Cycle makes the device after the default one the default in the direction,
+// going back to the first after the last, and returns it.
+func (c *Client) Cycle(dir Direction) (Device, error) {
+	devices, err := c.Devices(dir)
+	if err != nil {
+		return Device{}, err
+	}
+	if len(devices) == 0 {
+		return Device{}, fmt.Errorf("no %s devices", dir)
+	}
+	current, err := c.Default(dir)
+	if err != nil {
+		return Device{}, err
+	}
+	// the first device if the default isn't listed
+	idx := slices.IndexFunc(devices, func(d Device) bool { return d.Name == current })
+	return c.setDefault(dir, devices[(idx+1)%len(devices)])
+}
+
+// Set makes the device the default in the direction and returns it. The
+// device is given by its name, or by a part of its description, in any case.
+func (c *Client) Set(dir Direction, device string) (Device, error) {
+	devices, err := c.Devices(dir)
+	if err != nil {
+		return Device{}, err
+	}
+	for _, d := range devices {
+		if d.Name == device {
+			return c.setDefault(dir, d)
+		}
+	}
+	for _, d := range devices {
+		if strings.Contains(strings.ToLower(d.Description), strings.ToLower(device)) {
+			return c.setDefault(dir, d)
+		}
+	}
+	return Device{}, fmt.Errorf("no %s device matches %q", dir, device)
+}
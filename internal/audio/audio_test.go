@@ -0,0 +1,99 @@
+package audio
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakePactl answers the pactl commands from the devices and records the
+// default devices that are set.
+type fakePactl struct {
+	sinks, sources string
+	defaults       map[string]string
+}
+
+func (f *fakePactl) run(args ...string) ([]byte, error) {
+	switch args[0] {
+	case "--format=json":
+		if args[2] == "sinks" {
+			return []byte(f.sinks), nil
+		}
+		return []byte(f.sources), nil
+	case "get-default-sink", "get-default-source":
+		return []byte(f.defaults[strings.TrimPrefix(args[0], "get-default-")] + "\n"), nil
+	case "set-default-sink", "set-default-source":
+		f.defaults[strings.TrimPrefix(args[0], "set-default-")] = args[1]
+		return nil, nil
+	}
+	return nil, fmt.Errorf("unexpected command: %v", args)
+}
+
+func newFake() (*Client, *fakePactl) {
+	fake := &fakePactl{
+		sinks: `[{"name":"alsa_output.speakers","description":"Speakers"},
+			{"name":"bluez_output.headphones","description":"WH-1000XM4 Headphones"}]`,
+		sources: `[{"name":"alsa_output.speakers.monitor","description":"Monitor of Speakers"},
+			{"name":"alsa_input.mic","description":"Built-in Microphone"}]`,
+		defaults: map[string]string{"sink": "alsa_output.speakers", "source": "alsa_input.mic"},
+	}
+	return &Client{run: fake.run}, fake
+}
+
+func TestDevices(t *testing.T) {
+	client, _ := newFake()
+	devices, err := client.Devices(Input)
+	require.NoError(t, err)
+	// no monitors
+	assert.Equal(t, []Device{{Name: "alsa_input.mic", Description: "Built-in Microphone"}}, devices)
+	assert.Equal(t, "Built-in Microphone", devices[0].String())
+	assert.Equal(t, "alsa_input.mic", Device{Name: "alsa_input.mic"}.String())
+}
+
+func TestCycle(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	client, fake := newFake()
+	device, err := client.Cycle(Output)
+	require.NoError(err)
+	assert.Equal("WH-1000XM4 Headphones", device.String())
+	assert.Equal("bluez_output.headphones", fake.defaults["sink"])
+
+	// back to the first after the last
+	device, err = client.Cycle(Output)
+	require.NoError(err)
+	assert.Equal("alsa_output.speakers", device.Name)
+
+	device, err = client.Cycle(Input)
+	require.NoError(err)
+	assert.Equal("alsa_input.mic", device.Name)
+
+	fake.sources = `[]`
+	_, err = client.Cycle(Input)
+	assert.EqualError(err, "no input devices")
+	fake.sinks = `nope`
+	_, err = client.Cycle(Output)
+	assert.EqualError(err, "failed reading the output devices: invalid character 'o' in literal null (expecting 'u')")
+}
+
+func TestSet(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	client, fake := newFake()
+	device, err := client.Set(Output, "headphones")
+	require.NoError(err)
+	assert.Equal("bluez_output.headphones", device.Name)
+	assert.Equal("bluez_output.headphones", fake.defaults["sink"])
+
+	device, err = client.Set(Output, "alsa_output.speakers")
+	require.NoError(err)
+	assert.Equal("Speakers", device.String())
+
+	_, err = client.Set(Input, "webcam")
+	assert.EqualError(err, `no input device matches "webcam"`)
+}
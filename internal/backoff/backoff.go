@@ -0,0 +1,57 @@
+// Package backoff computes exponentially increasing delays, with jitter, for
+// retrying operations that keep failing, like reopening an unplugged device.
+package backoff
+
+import (
+	"math/rand/v2"
+	"time"
+)
+
+// Jitter is the fraction by which each delay is randomly lengthened or
+// shortened, so retries from different sources don't line up.
+const Jitter = 0.2
+
+// Policy defines the delays between retries: the first delay is Min and each
+// one after that is twice the previous one, up to Max.
+type Policy struct {
+	Min time.Duration
+	Max time.Duration
+}
+
+// Backoff returns the delays for consecutive retries following a [Policy].
+type Backoff struct {
+	policy Policy
+
+	// delay before jitter for the next retry; zero until the first one
+	next time.Duration
+}
+
+// New returns a [Backoff] following the policy.
+func New(policy Policy) *Backoff {
+	return &Backoff{policy: policy}
+}
+
+// Next returns the delay before the next retry.
+func (b *Backoff) Next() time.Duration {
+	if b.next == 0 {
+		b.next = b.policy.Min
+	}
+	delay := b.next
+	b.next = min(b.next*2, b.policy.Max)
+
+	// uniform in [1-Jitter, 1+Jitter)
+	factor := 1 - Jitter + 2*Jitter*rand.Float64()
+	return time.Duration(float64(delay) * factor)
+}
+
+// Reset starts the delays over from the minimum, after the operation
+// succeeded.
+func (b *Backoff) Reset() {
+	b.next = 0
+}
+
+// Retrying returns true if Next was called since the backoff was created or
+// reset.
+func (b *Backoff) Retrying() bool {
+	return b.next != 0
+}
@@ -0,0 +1,31 @@
+package backoff_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/achilleas-k/gg13/internal/backoff"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBackoff(t *testing.T) {
+	assert := assert.New(t)
+
+	b := backoff.New(backoff.Policy{Min: time.Second, Max: 5 * time.Second})
+	assert.False(b.Retrying())
+
+	inRange := func(expected, actual time.Duration) {
+		t.Helper()
+		assert.GreaterOrEqual(actual, time.Duration(float64(expected)*(1-backoff.Jitter)))
+		assert.Less(actual, time.Duration(float64(expected)*(1+backoff.Jitter)))
+	}
+
+	for _, expected := range []time.Duration{time.Second, 2 * time.Second, 4 * time.Second, 5 * time.Second, 5 * time.Second} {
+		inRange(expected, b.Next())
+	}
+	assert.True(b.Retrying())
+
+	b.Reset()
+	assert.False(b.Retrying())
+	inRange(time.Second, b.Next())
+}
@@ -0,0 +1,201 @@
+// Package chording generates one-handed chording layouts for the G keys of
+// the G13, which give the easiest chords to the most frequently typed keys.
+package chording
+
+import (
+	"bufio"
+	"cmp"
+	"fmt"
+	"io"
+	"slices"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/achilleas-k/gg13/internal/device"
+	"github.com/achilleas-k/gg13/internal/keyboard"
+)
+
+// Frequency is how often a keyboard key is typed, relative to the others.
+type Frequency struct {
+	// Key is the name of the keyboard key, like KeyE
+	Key    string
+	Weight float64
+}
+
+// DefaultFrequencies are the frequencies of the letters in English text, with
+// the space, punctuation, digits and editing keys.
+var DefaultFrequencies = []Frequency{
+	{"KeySpace", 18.0},
+	{"KeyE", 12.7}, {"KeyT", 9.1}, {"KeyA", 8.2}, {"KeyO", 7.5}, {"KeyI", 7.0},
+	{"KeyN", 6.7}, {"KeyS", 6.3}, {"KeyH", 6.1}, {"KeyR", 6.0}, {"KeyD", 4.3},
+	{"KeyL", 4.0}, {"KeyBackspace", 3.0}, {"KeyC", 2.8}, {"KeyU", 2.8}, {"KeyM", 2.4},
+	{"KeyW", 2.4}, {"KeyF", 2.2}, {"KeyG", 2.0}, {"KeyY", 2.0}, {"KeyP", 1.9},
+	{"KeyB", 1.5}, {"KeyComma", 1.2}, {"KeyDot", 1.1}, {"KeyEnter", 1.0}, {"KeyV", 1.0},
+	{"KeyK", 0.8}, {"KeyApostrophe", 0.3}, {"KeyJ", 0.15}, {"KeyX", 0.15}, {"KeyQ", 0.1},
+	{"KeyZ", 0.07},
+	{"Key0", 0.05}, {"Key1", 0.05}, {"Key2", 0.05}, {"Key3", 0.05}, {"Key4", 0.05},
+	{"Key5", 0.05}, {"Key6", 0.05}, {"Key7", 0.05}, {"Key8", 0.05}, {"Key9", 0.05},
+}
+
+// punctuationKeys are the keyboard keys typing punctuation characters without
+// shift.
+var punctuationKeys = map[rune]string{
+	'.': "KeyDot", ',': "KeyComma", ';': "KeySemicolon", '\'': "KeyApostrophe",
+	'-': "KeyMinus", '=': "KeyEqual", '/': "KeySlash", '\\': "KeyBackslash",
+	'[': "KeyLeftbrace", ']': "KeyRightbrace", '`': "KeyGrave",
+}
+
+// keyName returns the name of the keyboard key typing the character, given
+// as the character itself, "space", or the name of the key.
+func keyName(char string) (string, error) {
+	if char == "space" {
+		return "KeySpace", nil
+	}
+	if keyboard.KeyCode(char) != 0 {
+		return char, nil
+	}
+	runes := []rune(char)
+	if len(runes) != 1 {
+		return "", fmt.Errorf("unknown key: %s", char)
+	}
+	r := unicode.ToLower(runes[0])
+	switch {
+	case r >= 'a' && r <= 'z', r >= '0' && r <= '9':
+		return "Key" + strings.ToUpper(string(r)), nil
+	case punctuationKeys[r] != "":
+		return punctuationKeys[r], nil
+	}
+	return "", fmt.Errorf("no key types %q", char)
+}
+
+// ParseFrequencies reads a frequency table with a character and its weight on
+// each line, like "e 12.7". The character can also be "space" or the name of
+// a keyboard key, like KeyBackspace. Upper case letters count towards the
+// lower case ones. Empty lines and lines starting with # are ignored.
+func ParseFrequencies(r io.Reader) ([]Frequency, error) {
+	weights := make(map[string]float64)
+	var order []string
+	scanner := bufio.NewScanner(r)
+	for lineNum := 1; scanner.Scan(); lineNum++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		fields := strings.Fields(line)
+		if len(fields) != 2 {
+			return nil, fmt.Errorf("line %d: expected a character and a weight: %s", lineNum, line)
+		}
+		key, err := keyName(fields[0])
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", lineNum, err)
+		}
+		weight, err := strconv.ParseFloat(fields[1], 64)
+		if err != nil || weight <= 0 {
+			return nil, fmt.Errorf("line %d: weight must be a positive number: %s", lineNum, fields[1])
+		}
+		if _, ok := weights[key]; !ok {
+			order = append(order, key)
+		}
+		weights[key] += weight
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if len(order) == 0 {
+		return nil, fmt.Errorf("frequency table is empty")
+	}
+
+	freqs := make([]Frequency, 0, len(order))
+	for _, key := range order {
+		freqs = append(freqs, Frequency{Key: key, Weight: weights[key]})
+	}
+	return freqs, nil
+}
+
+// keyPositions are the column and row of the G keys on the pad: the top two
+// rows have seven keys, the third five and the bottom three, centred under
+// them.
+var keyPositions = map[device.KeyBit][2]int{
+	device.G1: {0, 0}, device.G2: {1, 0}, device.G3: {2, 0}, device.G4: {3, 0},
+	device.G5: {4, 0}, device.G6: {5, 0}, device.G7: {6, 0},
+	device.G8: {0, 1}, device.G9: {1, 1}, device.G10: {2, 1}, device.G11: {3, 1},
+	device.G12: {4, 1}, device.G13: {5, 1}, device.G14: {6, 1},
+	device.G15: {1, 2}, device.G16: {2, 2}, device.G17: {3, 2}, device.G18: {4, 2}, device.G19: {5, 2},
+	device.G20: {2, 3}, device.G21: {3, 3}, device.G22: {4, 3},
+}
+
+// homeRow is the row the fingers rest on.
+const homeRow = 1
+
+// Chord is a chord of G keys typing a keyboard key.
+type Chord struct {
+	Keys []device.KeyBit
+	// Key is the name of the keyboard key
+	Key string
+}
+
+type candidate struct {
+	keys   []device.KeyBit
+	effort float64
+}
+
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
+
+// candidates returns the chords of one and two G keys, from the easiest to
+// the hardest: single keys come first, nearest to the home row first, and
+// pairs of keys are harder the further apart they are.
+func candidates() []candidate {
+	var gkeys []device.KeyBit
+	for _, key := range device.AllKeys() {
+		if _, ok := keyPositions[key]; ok {
+			gkeys = append(gkeys, key)
+		}
+	}
+	reach := func(key device.KeyBit) float64 {
+		return 0.1 * float64(abs(keyPositions[key][1]-homeRow))
+	}
+
+	var chords []candidate
+	for idx, first := range gkeys {
+		chords = append(chords, candidate{keys: []device.KeyBit{first}, effort: 1 + reach(first)})
+		for _, second := range gkeys[idx+1:] {
+			pos1, pos2 := keyPositions[first], keyPositions[second]
+			distance := abs(pos1[0]-pos2[0]) + abs(pos1[1]-pos2[1])
+			effort := 2 + float64(distance) + (reach(first)+reach(second))/2
+			chords = append(chords, candidate{keys: []device.KeyBit{first, second}, effort: effort})
+		}
+	}
+	slices.SortStableFunc(chords, func(a, b candidate) int { return cmp.Compare(a.effort, b.effort) })
+	return chords
+}
+
+// Generate returns a chord for each key of the frequency table, giving the
+// easiest chords to the most frequent keys.
+func Generate(freqs []Frequency) ([]Chord, error) {
+	chords := candidates()
+	if len(freqs) > len(chords) {
+		return nil, fmt.Errorf("too many keys for the chords: %d, at most %d", len(freqs), len(chords))
+	}
+	sorted := slices.Clone(freqs)
+	slices.SortStableFunc(sorted, func(a, b Frequency) int { return cmp.Compare(b.Weight, a.Weight) })
+
+	layout := make([]Chord, 0, len(sorted))
+	seen := make(map[string]bool, len(sorted))
+	for idx, freq := range sorted {
+		if keyboard.KeyCode(freq.Key) == 0 {
+			return nil, fmt.Errorf("unknown keyboard key name: %s", freq.Key)
+		}
+		if seen[freq.Key] {
+			return nil, fmt.Errorf("%s is listed twice", freq.Key)
+		}
+		seen[freq.Key] = true
+		layout = append(layout, Chord{Keys: chords[idx].keys, Key: freq.Key})
+	}
+	return layout, nil
+}
@@ -0,0 +1,89 @@
+package chording_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/achilleas-k/gg13/internal/chording"
+	"github.com/achilleas-k/gg13/internal/device"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseFrequencies(t *testing.T) {
+	freqs, err := chording.ParseFrequencies(strings.NewReader(`
+# letters
+e 10
+E 2.5
+space 20
+. 1
+KeyBackspace 3
+7 0.5
+`))
+	require.NoError(t, err)
+	assert.Equal(t, []chording.Frequency{
+		{Key: "KeyE", Weight: 12.5},
+		{Key: "KeySpace", Weight: 20},
+		{Key: "KeyDot", Weight: 1},
+		{Key: "KeyBackspace", Weight: 3},
+		{Key: "Key7", Weight: 0.5},
+	}, freqs)
+
+	testCases := map[string]string{
+		"e":          "line 1: expected a character and a weight: e",
+		"é 1":        `line 1: no key types "é"`,
+		"KeyNope 1":  "line 1: unknown key: KeyNope",
+		"e 0":        "line 1: weight must be a positive number: 0",
+		"e often":    "line 1: weight must be a positive number: often",
+		"# nothing":  "frequency table is empty",
+		"a 1\n! 2\n": `line 2: no key types "!"`,
+	}
+	for table, expectedErr := range testCases {
+		_, err := chording.ParseFrequencies(strings.NewReader(table))
+		assert.EqualError(t, err, expectedErr, table)
+	}
+}
+
+func TestGenerate(t *testing.T) {
+	assert := assert.New(t)
+
+	chords, err := chording.Generate([]chording.Frequency{
+		{Key: "KeyT", Weight: 9},
+		{Key: "KeyE", Weight: 12},
+	})
+	require.NoError(t, err)
+	// the most frequent key gets the first key of the home row
+	assert.Equal([]chording.Chord{
+		{Keys: []device.KeyBit{device.G8}, Key: "KeyE"},
+		{Keys: []device.KeyBit{device.G9}, Key: "KeyT"},
+	}, chords)
+
+	chords, err = chording.Generate(chording.DefaultFrequencies)
+	require.NoError(t, err)
+	require.Len(t, chords, len(chording.DefaultFrequencies))
+	seen := make(map[string]bool)
+	for idx, chord := range chords {
+		// single keys first, then pairs
+		if idx < 22 {
+			assert.Len(chord.Keys, 1, chord.Key)
+		} else {
+			assert.Len(chord.Keys, 2, chord.Key)
+		}
+		name := ""
+		for _, key := range chord.Keys {
+			name += key.String() + "+"
+		}
+		assert.False(seen[name], name)
+		seen[name] = true
+	}
+	assert.Equal("KeySpace", chords[0].Key)
+	// the first pair is next to each other on the home row
+	assert.Equal([]device.KeyBit{device.G8, device.G9}, chords[22].Keys)
+
+	_, err = chording.Generate([]chording.Frequency{{Key: "KeyNope", Weight: 1}})
+	assert.EqualError(err, "unknown keyboard key name: KeyNope")
+	_, err = chording.Generate([]chording.Frequency{{Key: "KeyA", Weight: 1}, {Key: "KeyA", Weight: 2}})
+	assert.EqualError(err, "KeyA is listed twice")
+	_, err = chording.Generate(make([]chording.Frequency, 300))
+	assert.EqualError(err, "too many keys for the chords: 300, at most 253")
+}
@@ -0,0 +1,74 @@
+package config
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/achilleas-k/gg13/internal/keyboard"
+)
+
+// MaxSlowKeysDelay limits how long a key has to be held before it registers
+// with slow keys.
+const MaxSlowKeysDelay = 5 * time.Second
+
+// modifierKeys are the keyboard keys that latch with sticky keys.
+var modifierKeys = map[int]bool{
+	keyboard.KeyCode("KeyLeftctrl"):   true,
+	keyboard.KeyCode("KeyRightctrl"):  true,
+	keyboard.KeyCode("KeyLeftshift"):  true,
+	keyboard.KeyCode("KeyRightshift"): true,
+	keyboard.KeyCode("KeyLeftalt"):    true,
+	keyboard.KeyCode("KeyRightalt"):   true,
+	keyboard.KeyCode("KeyLeftmeta"):   true,
+	keyboard.KeyCode("KeyRightmeta"):  true,
+}
+
+// accessibilityCfg changes how key presses register, for users who have
+// trouble pressing keys together or pressing them accurately.
+type accessibilityCfg struct {
+	// modifiers latch when tapped until the next key is released
+	stickyKeys bool
+
+	// how long a key has to be held before it registers; zero registers it
+	// right away
+	slowKeys time.Duration
+}
+
+func loadAccessibility(m fileMapping) (accessibilityCfg, error) {
+	ac := accessibilityCfg{stickyKeys: m.StickyKeys}
+	if m.SlowKeys == "" {
+		return ac, nil
+	}
+	delay, err := time.ParseDuration(m.SlowKeys)
+	if err != nil {
+		return ac, fmt.Errorf("invalid slow_keys %q: %w", m.SlowKeys, err)
+	}
+	if delay <= 0 || delay > MaxSlowKeysDelay {
+		return ac, fmt.Errorf("slow_keys must be positive and at most %s: %s", MaxSlowKeysDelay, m.SlowKeys)
+	}
+	ac.slowKeys = delay
+	return ac, nil
+}
+
+// GetStickyKeys returns the G13 keys that latch with sticky keys, as a mask
+// of [device.KeyBit]s: the keys bound to modifiers, if sticky keys are on. A
+// latched key stays down after it's released until another key is pressed
+// and released, or it's pressed again.
+func (cfg *G13Config) GetStickyKeys() uint64 {
+	if !cfg.mapping.accessibility.stickyKeys {
+		return 0
+	}
+	var sticky uint64
+	for gkey, kbkey := range cfg.mapping.keyMap {
+		if modifierKeys[kbkey] {
+			sticky |= gkey.Uint64()
+		}
+	}
+	return sticky
+}
+
+// GetSlowKeysDelay returns how long a G13 key has to be held before it
+// registers, or zero if slow keys are off.
+func (cfg *G13Config) GetSlowKeysDelay() time.Duration {
+	return cfg.mapping.accessibility.slowKeys
+}
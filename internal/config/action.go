@@ -0,0 +1,141 @@
+package config
+
+import (
+	"fmt"
+
+	"github.com/achilleas-k/gg13/internal/device"
+)
+
+// Action is a driver-internal operation that can be bound to a G13 key
+// instead of a keyboard key.
+type Action string
+
+const (
+	// ActionToggleBacklight turns the backlight off and back on to the
+	// configured colour.
+	ActionToggleBacklight Action = "toggle_backlight"
+
+	// ActionReloadConfig reloads the config file and applies it.
+	ActionReloadConfig Action = "reload_config"
+
+	// ActionPause stops all keyboard and joystick output until it's pressed
+	// again. The backlight shows [PauseColour] while paused.
+	ActionPause Action = "pause"
+
+	// ActionPassthrough toggles between the configured key bindings and the
+	// passthrough layout (see [G13Config.Passthrough]).
+	ActionPassthrough Action = "passthrough"
+
+	// ActionTimerStartPause starts the LCD timer, or pauses it if it's
+	// running.
+	ActionTimerStartPause Action = "timer_start_pause"
+
+	// ActionTimerReset stops the LCD timer and sets it back to its full
+	// duration.
+	ActionTimerReset Action = "timer_reset"
+
+	// ActionClearCounters clears all the notification counters shown on the
+	// LCD.
+	ActionClearCounters Action = "clear_counters"
+
+	// ActionCalibrateStick measures the resting centre of the stick, which
+	// must not be touched for [StickCalibrationTime], and stores it as the
+	// stick calibration.
+	ActionCalibrateStick Action = "calibrate_stick"
+
+	// ActionLock stops all output, including the other actions and profile
+	// switches, until the unlock chord (see [G13Config.GetUnlockChord]) is
+	// held, to guard against accidental input. The LCD shows that the keys
+	// are locked.
+	ActionLock Action = "lock"
+
+	// ActionCycleAudioOutput and ActionCycleAudioInput make the next output
+	// or input device of the sound server the default. The LCD shows the
+	// name of the device.
+	ActionCycleAudioOutput Action = "cycle_audio_output"
+	ActionCycleAudioInput  Action = "cycle_audio_input"
+
+	// ActionScreenshot takes a screenshot of the whole screen through the
+	// desktop portal, and ActionScreenshotInteractive lets the user choose
+	// what to capture in the dialog of the portal first. The LCD shows
+	// where the image was saved.
+	ActionScreenshot            Action = "screenshot"
+	ActionScreenshotInteractive Action = "screenshot_interactive"
+
+	// ActionNextProfile and ActionPreviousProfile latch the next or previous
+	// profile, by name, after the latched one, going around through the main
+	// mapping. Momentary profiles are skipped.
+	ActionNextProfile     Action = "next_profile"
+	ActionPreviousProfile Action = "previous_profile"
+
+	// ActionNextPage and ActionPreviousPage step through the pages like the
+	// soft keys that step through them (see [G13Config.GetPages]).
+	ActionNextPage     Action = "next_page"
+	ActionPreviousPage Action = "previous_page"
+)
+
+// PauseColour is the backlight colour shown while output is paused.
+var PauseColour = [3]uint8{255, 96, 0}
+
+var knownActions = map[Action]bool{
+	ActionToggleBacklight: true,
+	ActionReloadConfig:    true,
+	ActionPause:           true,
+	ActionPassthrough:     true,
+	ActionTimerStartPause: true,
+	ActionTimerReset:      true,
+	ActionClearCounters:   true,
+	ActionCalibrateStick:  true,
+	ActionLock:            true,
+
+	ActionCycleAudioOutput: true,
+	ActionCycleAudioInput:  true,
+
+	ActionScreenshot:            true,
+	ActionScreenshotInteractive: true,
+
+	ActionNextProfile:     true,
+	ActionPreviousProfile: true,
+	ActionNextPage:        true,
+	ActionPreviousPage:    true,
+}
+
+func loadActions(actions map[string]string, km keyMap) (map[device.KeyBit]Action, error) {
+	if len(actions) == 0 {
+		return nil, nil
+	}
+
+	loaded := make(map[device.KeyBit]Action, len(actions))
+	for keyName, actionName := range actions {
+		gKey := device.KeyCode(keyName)
+		if gKey == 0 {
+			return nil, fmt.Errorf("actions: unknown G13 key name: %s", keyName)
+		}
+		if _, ok := km[gKey]; ok {
+			return nil, fmt.Errorf("actions: %s is already bound to a keyboard key", keyName)
+		}
+		action := Action(actionName)
+		if !knownActions[action] {
+			return nil, fmt.Errorf("actions: %s: unknown action: %s", keyName, actionName)
+		}
+		loaded[gKey] = action
+	}
+	return loaded, nil
+}
+
+// HasScreenshotActions returns true if the mapping or a profile binds keys to
+// the screenshot actions.
+func (cfg *G13Config) HasScreenshotActions() bool {
+	mappings := []Mapping{cfg.mapping}
+	for _, profile := range cfg.profiles {
+		mappings = append(mappings, profile.config.mapping)
+	}
+	for _, mapping := range mappings {
+		for _, action := range mapping.actions {
+			if action == ActionScreenshot || action == ActionScreenshotInteractive {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,83 @@
+package config
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/achilleas-k/gg13/internal/audio"
+	"github.com/achilleas-k/gg13/internal/device"
+)
+
+// AudioSwitch makes a device of the sound server the default.
+type AudioSwitch struct {
+	Direction audio.Direction
+	// Device is the name of the device, or a part of its description
+	Device string
+}
+
+func (s AudioSwitch) String() string {
+	return fmt.Sprintf("%s %s", s.Direction, s.Device)
+}
+
+// parseAudioSwitch parses a switch written as "output <device>" or "input
+// <device>". The device can contain spaces.
+func parseAudioSwitch(str string) (AudioSwitch, error) {
+	dirName, device, _ := strings.Cut(strings.TrimSpace(str), " ")
+	device = strings.TrimSpace(device)
+	dir := audio.Direction(dirName)
+	if (dir != audio.Output && dir != audio.Input) || device == "" {
+		return AudioSwitch{}, fmt.Errorf("invalid audio device %q: expected output <device> or input <device>", str)
+	}
+	return AudioSwitch{Direction: dir, Device: device}, nil
+}
+
+// loadAudio returns the sound server devices that G13 keys make the default,
+// which can't also have other bindings in the mapping.
+func loadAudio(switches map[string]string, mapping Mapping) (map[device.KeyBit]AudioSwitch, error) {
+	if len(switches) == 0 {
+		return nil, nil
+	}
+
+	loaded := make(map[device.KeyBit]AudioSwitch, len(switches))
+	for keyName, switchStr := range switches {
+		gKey := device.KeyCode(keyName)
+		if gKey == 0 {
+			return nil, fmt.Errorf("audio: unknown G13 key name: %s", keyName)
+		}
+		if mapping.binds(gKey) {
+			return nil, fmt.Errorf("audio: %s is already bound", keyName)
+		}
+		sw, err := parseAudioSwitch(switchStr)
+		if err != nil {
+			return nil, fmt.Errorf("audio: %s: %w", keyName, err)
+		}
+		loaded[gKey] = sw
+	}
+	return loaded, nil
+}
+
+// GetAudioSwitches returns the sound server devices that G13 keys make the
+// default when they're pressed.
+func (cfg *G13Config) GetAudioSwitches() map[device.KeyBit]AudioSwitch {
+	return cfg.mapping.audio
+}
+
+// HasAudioBindings returns true if the mapping or a profile binds keys to
+// switching audio devices, with the audio section or the cycle_audio actions.
+func (cfg *G13Config) HasAudioBindings() bool {
+	mappings := []Mapping{cfg.mapping}
+	for _, profile := range cfg.profiles {
+		mappings = append(mappings, profile.config.mapping)
+	}
+	for _, mapping := range mappings {
+		if len(mapping.audio) > 0 {
+			return true
+		}
+		for _, action := range mapping.actions {
+			if action == ActionCycleAudioOutput || action == ActionCycleAudioInput {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,136 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"slices"
+
+	"github.com/achilleas-k/gg13/internal/device"
+)
+
+// Binding is a change to what a G13 key of the main mapping, or of a profile,
+// is bound to, made while the driver is running. The key is unbound from
+// everything in the mapping first, and then bound to the keyboard key, if
+// there is one. Chords and disabled keys aren't changed.
+type Binding struct {
+	// the profile whose mapping changes; empty or [MainProfile] for the
+	// main mapping
+	Profile string
+
+	Key string
+	// the name of the keyboard key; empty unbinds the key
+	KeyboardKey string
+}
+
+// String returns the key and what it's bound to, with the profile if it
+// isn't the main mapping.
+func (b Binding) String() string {
+	target := b.KeyboardKey
+	if target == "" {
+		target = "unbound"
+	}
+	if b.isMain() {
+		return b.Key + " " + target
+	}
+	return b.Profile + ": " + b.Key + " " + target
+}
+
+func (b Binding) isMain() bool {
+	return b.Profile == "" || b.Profile == MainProfile
+}
+
+// mappingSections are the sections of a mapping that bind G13 keys by name.
+var mappingSections = []string{"keys", "actions", "macros", "scripts", "scroll", "warp", "windows", "audio"}
+
+// edit applies the change to the mapping of the config file.
+func (b Binding) edit(m *fileMapping) {
+	for _, section := range []map[string]string{m.Keys, m.Actions, m.Macros, m.Scripts, m.Scroll, m.Warp, m.Windows, m.Audio} {
+		delete(section, b.Key)
+	}
+	if b.KeyboardKey == "" {
+		return
+	}
+	if m.Keys == nil {
+		m.Keys = map[string]string{}
+	}
+	m.Keys[b.Key] = b.KeyboardKey
+}
+
+// applyBindings applies the changes to the mappings of the config file, in
+// order.
+func applyBindings(cfg *fileConfig, bindings []Binding) error {
+	for _, b := range bindings {
+		if device.KeyCode(b.Key) == 0 {
+			return fmt.Errorf("bind: unknown G13 key name: %s", b.Key)
+		}
+		if b.isMain() {
+			b.edit(&cfg.Mapping)
+			continue
+		}
+		profile, ok := cfg.Profiles[b.Profile]
+		if !ok {
+			return fmt.Errorf("bind: unknown profile: %s", b.Profile)
+		}
+		b.edit(&profile.Mapping)
+		cfg.Profiles[b.Profile] = profile
+	}
+	return nil
+}
+
+// NewFromFileWithBindings loads the config like [NewFromFile], with the
+// changes to the bindings applied in order before it's checked.
+func NewFromFileWithBindings(path string, bindings []Binding) (*G13Config, error) {
+	return loadConfig(path, bindings...)
+}
+
+// EditBinding applies the change to the JSON encoded config data and returns
+// the new data. The data of a profile file in a config directory has the
+// mapping of the profile at the top, like the main mapping, so the change
+// is applied to it with an empty profile. Whether the result is a valid
+// config is only checked when it's loaded.
+func EditBinding(data []byte, b Binding) ([]byte, error) {
+	if device.KeyCode(b.Key) == 0 {
+		return nil, fmt.Errorf("unknown G13 key name: %s", b.Key)
+	}
+	raw := map[string]any{}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, err
+	}
+
+	parent := raw
+	if !b.isMain() {
+		profiles, _ := raw["profiles"].(map[string]any)
+		profile, ok := profiles[b.Profile].(map[string]any)
+		if !ok {
+			return nil, fmt.Errorf("unknown profile: %s", b.Profile)
+		}
+		parent = profile
+	}
+	mapping, ok := parent["mapping"].(map[string]any)
+	if !ok {
+		if _, isSet := parent["mapping"]; isSet {
+			return nil, fmt.Errorf("mapping: not an object")
+		}
+		mapping = map[string]any{}
+	}
+	for name, section := range mapping {
+		if bindings, ok := section.(map[string]any); ok && slices.Contains(mappingSections, name) {
+			delete(bindings, b.Key)
+		}
+	}
+	if b.KeyboardKey != "" {
+		keys, ok := mapping["keys"].(map[string]any)
+		if !ok {
+			keys = map[string]any{}
+		}
+		keys[b.Key] = b.KeyboardKey
+		mapping["keys"] = keys
+	}
+	parent["mapping"] = mapping
+
+	edited, err := json.MarshalIndent(raw, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append(edited, '\n'), nil
+}
@@ -0,0 +1,87 @@
+package config
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/achilleas-k/gg13/internal/device"
+	"github.com/achilleas-k/gg13/internal/keyboard"
+)
+
+// ChordName returns the name of the chord of G13 keys, as a mask of
+// [device.KeyBit]s, in the format of the chords of the mapping: the key names
+// joined with +, like G1+G2.
+func ChordName(keys uint64) string {
+	names := make([]string, 0, 2)
+	for _, key := range device.AllKeys() {
+		if keys&key.Uint64() != 0 {
+			names = append(names, key.String())
+		}
+	}
+	return strings.Join(names, "+")
+}
+
+// parseChord returns the mask of the G13 keys of a chord name like G1+G2.
+func parseChord(name string) (uint64, error) {
+	var keys uint64
+	for keyName := range strings.SplitSeq(name, "+") {
+		gKey := device.KeyCode(keyName)
+		if gKey == 0 {
+			return 0, fmt.Errorf("unknown G13 key name: %s", keyName)
+		}
+		if keys&gKey.Uint64() != 0 {
+			return 0, fmt.Errorf("%s is listed twice", keyName)
+		}
+		keys |= gKey.Uint64()
+	}
+	return keys, nil
+}
+
+func loadChords(chords map[string]string, km keyMap, actions map[device.KeyBit]Action, macros map[device.KeyBit]string, scripts map[device.KeyBit][]ScriptStep) (map[uint64]int, error) {
+	if len(chords) == 0 {
+		return nil, nil
+	}
+
+	loaded := make(map[uint64]int, len(chords))
+	for chordName, kbKeyName := range chords {
+		keys, err := parseChord(chordName)
+		if err != nil {
+			return nil, fmt.Errorf("chords: %s: %w", chordName, err)
+		}
+		if _, ok := loaded[keys]; ok {
+			return nil, fmt.Errorf("chords: %s is listed twice", ChordName(keys))
+		}
+		for _, gKey := range device.AllKeys() {
+			if keys&gKey.Uint64() == 0 {
+				continue
+			}
+			_, isKey := km[gKey]
+			_, isAction := actions[gKey]
+			_, isMacro := macros[gKey]
+			_, isScript := scripts[gKey]
+			if isKey || isAction || isMacro || isScript {
+				return nil, fmt.Errorf("chords: %s: %s is already bound", chordName, gKey)
+			}
+		}
+		kbKey := keyboard.KeyCode(kbKeyName)
+		if kbKey == 0 {
+			return nil, fmt.Errorf("chords: %s: unknown keyboard key name: %s", chordName, kbKeyName)
+		}
+		loaded[keys] = kbKey
+	}
+	return loaded, nil
+}
+
+// GetChordKeys returns the G13 keys that are part of chords, as a mask of
+// [device.KeyBit]s. They type the keyboard key of the chord of all the keys
+// that were held together once they're all released.
+func (cfg *G13Config) GetChordKeys() uint64 {
+	return cfg.mapping.chordKeys
+}
+
+// GetChord returns the keyboard key typed by the chord of G13 keys, as a mask
+// of [device.KeyBit]s, and true if the chord is bound.
+func (cfg *G13Config) GetChord(keys uint64) (int, bool) {
+	kbKey, ok := cfg.mapping.chords[keys]
+	return kbKey, ok
+}
@@ -0,0 +1,60 @@
+package config
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// colourNames maps the colour names accepted in the config to their RGB
+// values. Names are matched case-insensitively.
+var colourNames = map[string][3]uint8{
+	"black":   {0, 0, 0},
+	"white":   {255, 255, 255},
+	"red":     {255, 0, 0},
+	"green":   {0, 255, 0},
+	"blue":    {0, 0, 255},
+	"yellow":  {255, 255, 0},
+	"cyan":    {0, 255, 255},
+	"magenta": {255, 0, 255},
+	"orange":  {255, 136, 0},
+	"purple":  {128, 0, 255},
+	"pink":    {255, 64, 160},
+	"teal":    {0, 128, 128},
+	"lime":    {128, 255, 0},
+	"amber":   {255, 191, 0},
+	"gold":    {255, 215, 0},
+	"violet":  {160, 64, 255},
+	"indigo":  {75, 0, 130},
+	"off":     {0, 0, 0},
+}
+
+// ParseColour parses a colour given as a hex string (#rrggbb or #rgb) or a
+// colour name and returns its RGB values.
+func ParseColour(spec string) ([3]uint8, error) {
+	if hex, ok := strings.CutPrefix(spec, "#"); ok {
+		return parseHexColour(spec, hex)
+	}
+
+	if rgb, ok := colourNames[strings.ToLower(spec)]; ok {
+		return rgb, nil
+	}
+	return [3]uint8{}, fmt.Errorf("invalid colour %q: expected a hex value (#rrggbb or #rgb) or a colour name", spec)
+}
+
+func parseHexColour(spec, hex string) ([3]uint8, error) {
+	switch len(hex) {
+	case 3:
+		// expand #rgb to #rrggbb
+		hex = string([]byte{hex[0], hex[0], hex[1], hex[1], hex[2], hex[2]})
+	case 6:
+	default:
+		return [3]uint8{}, fmt.Errorf("invalid colour %q: hex value must have 3 or 6 digits", spec)
+	}
+
+	value, err := strconv.ParseUint(hex, 16, 32)
+	if err != nil {
+		return [3]uint8{}, fmt.Errorf("invalid colour %q: not a hex value", spec)
+	}
+	return [3]uint8{uint8(value >> 16), uint8(value >> 8), uint8(value)}, nil
+}
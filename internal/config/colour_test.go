@@ -0,0 +1,35 @@
+package config_test
+
+import (
+	"testing"
+
+	"github.com/achilleas-k/gg13/internal/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseColour(t *testing.T) {
+	testCases := map[string][3]uint8{
+		"#ff8800": {255, 136, 0},
+		"#FF8800": {255, 136, 0},
+		"#f80":    {255, 136, 0},
+		"#000000": {0, 0, 0},
+		"orange":  {255, 136, 0},
+		"Blue":    {0, 0, 255},
+		"off":     {0, 0, 0},
+	}
+
+	for spec, expected := range testCases {
+		t.Run(spec, func(t *testing.T) {
+			rgb, err := config.ParseColour(spec)
+			assert.NoError(t, err)
+			assert.Equal(t, expected, rgb)
+		})
+	}
+
+	for _, spec := range []string{"", "#", "#12345", "#1234567", "#-12345", "notacolour", "ff8800"} {
+		t.Run("invalid-"+spec, func(t *testing.T) {
+			_, err := config.ParseColour(spec)
+			assert.Error(t, err)
+		})
+	}
+}
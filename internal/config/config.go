@@ -3,15 +3,27 @@
 package config
 
 import (
+	"bytes"
 	"encoding/json"
 	"fmt"
 	"image"
 	"maps"
 	"os"
 	"path/filepath"
+	"slices"
+	"strings"
+	"time"
 
+	"github.com/achilleas-k/gg13/internal/applet"
+	"github.com/achilleas-k/gg13/internal/backoff"
+	"github.com/achilleas-k/gg13/internal/desktop"
 	"github.com/achilleas-k/gg13/internal/device"
+	"github.com/achilleas-k/gg13/internal/gesture"
+	"github.com/achilleas-k/gg13/internal/joystick"
 	"github.com/achilleas-k/gg13/internal/keyboard"
+	"github.com/achilleas-k/gg13/internal/lcd"
+	"github.com/achilleas-k/gg13/internal/mqtt"
+	"github.com/achilleas-k/gg13/internal/output"
 	"golang.org/x/image/bmp"
 )
 
@@ -22,8 +34,134 @@ type G13Config struct {
 	// backlight rgb
 	backlight [3]uint8
 
+	// interval for resending the backlight colour; zero disables it
+	backlightKeepalive time.Duration
+
+	// backlight colour changes shown when keys are pressed
+	backlightFlashes map[device.KeyBit]BacklightFlash
+
 	// path to image configured for the display
 	lcdImage string
+
+	// show the binding cheat sheet on the display
+	lcdCheatSheet bool
+
+	// show a text page fed by an HTTP endpoint on the display
+	httpPage *httpPageCfg
+
+	// show a countdown timer on the display
+	timer *timerCfg
+
+	// path to a text file, FIFO or "-" for standard input, shown on the display
+	lcdTextFile string
+
+	// show a text page with system information on the display
+	templatePage *templatePageCfg
+
+	// show notification counters on the display
+	lcdCounters bool
+
+	// font for text pages on the display; nil uses the built-in font
+	lcdFont *lcdFontCfg
+
+	// conversion of the display content to black and white; nil uses the
+	// default
+	lcdMonochrome *device.Monochrome
+
+	// how long driver messages are shown on the display; zero doesn't show
+	// them
+	lcdMessageDuration time.Duration
+
+	// input loop tuning; zero values use the defaults
+	input inputCfg
+
+	// stick calibration measured by the driver, used unless the config sets
+	// one
+	measuredCalibration *StickCalibration
+
+	// MQTT broker connection, if enabled
+	mqtt *mqtt.Options
+
+	// name of the output backend; empty uses the default
+	output string
+
+	// name of the desktop backend for window management
+	desktop string
+
+	// address of the receiver for the network output
+	networkOutputAddress string
+
+	// profiles replacing the mapping while they're active, by name
+	profiles map[string]*profileCfg
+
+	// keyboard key sequences played by G keys, by name
+	macros map[string][]MacroEvent
+
+	// the keys held together to unlock the keys after the lock action
+	unlockChord []device.KeyBit
+
+	// size of the screen that the pointer is moved on
+	screen *ScreenSize
+
+	// profiles that the soft keys step through, if any
+	pages *Pages
+
+	// problems found in the config file that don't stop it from loading
+	warnings []string
+
+	// stop all output and blank the LCD while the graphical session is
+	// locked
+	muteOnScreenLock bool
+
+	// the profile latched while the graphical session is locked, if any
+	screenLockProfile string
+}
+
+// BacklightFlash is a backlight colour change triggered by a key press.
+type BacklightFlash struct {
+	Colour [3]uint8
+
+	// how long to show the colour for; zero keeps it while the key is held
+	Duration time.Duration
+}
+
+// DefaultFlashDuration is the duration of a backlight flash when none is set.
+const DefaultFlashDuration = 150 * time.Millisecond
+
+// DefaultFlashColour is the colour of a backlight flash when none is set.
+var DefaultFlashColour = [3]uint8{255, 255, 255}
+
+type inputCfg struct {
+	readTimeout    time.Duration
+	errorThreshold int
+	retryDelay     time.Duration
+	retryMaxDelay  time.Duration
+
+	reconnectDelay    time.Duration
+	reconnectMaxDelay time.Duration
+}
+
+const (
+	// DefaultReadTimeout is the timeout for each read from the device.
+	DefaultReadTimeout = 100 * time.Millisecond
+
+	// DefaultErrorThreshold is the number of consecutive read errors after
+	// which the device is reinitialised.
+	DefaultErrorThreshold = 3
+
+	// DefaultRetryDelay is the time to wait after a read error before reading
+	// again. It doubles with each consecutive error, up to
+	// DefaultRetryMaxDelay.
+	DefaultRetryDelay = 500 * time.Millisecond
+
+	// DefaultRetryMaxDelay is the longest time to wait after a read error.
+	DefaultRetryMaxDelay = 5 * time.Second
+)
+
+type httpPageCfg struct {
+	url      string
+	interval time.Duration
+	template string
 }
 
 type Mapping struct {
@@ -32,6 +170,51 @@ type Mapping struct {
 
 	// stick configuration and mapping
 	stick stickCfg
+
+	// driver-internal actions bound to G keys
+	actions map[device.KeyBit]Action
+
+	// names of the macros bound to G keys, and the mask of the keys whose
+	// macros replay for as long as they're held
+	macros       map[device.KeyBit]string
+	repeatMacros uint64
+
+	// scripts of output steps run by G keys
+	scripts map[device.KeyBit][]ScriptStep
+
+	// keyboard keys typed by chords of G keys, by the mask of their keys,
+	// and the mask of all the keys of chords
+	chords    map[uint64]int
+	chordKeys uint64
+
+	// mouse wheel directions of G keys that scroll while held
+	scroll map[device.KeyBit]ScrollDirection
+
+	// screen positions that G keys move the pointer to
+	warps map[device.KeyBit]Point
+
+	// window management operations that G keys run
+	windows map[device.KeyBit]desktop.Op
+
+	// sound server devices that G keys make the default
+	audio map[device.KeyBit]AudioSwitch
+
+	// G13 keys whose input is ignored, as a mask of [device.KeyBit]s
+	disabled uint64
+
+	// warn when a key that isn't bound to anything is pressed
+	warnUnmapped bool
+
+	// sticky and slow keys
+	accessibility accessibilityCfg
+
+	// keyboard keys pressed by the key map and the stick, built by
+	// indexOutputs
+	outputs []keyOutput
+
+	// stick directions pushed past the run threshold in keys mode, kept
+	// between input reports for the hysteresis of the threshold
+	running stickDirection
 }
 
 type keyMap map[device.KeyBit]int
@@ -43,11 +226,173 @@ const (
 	StickModeJoystick
 	StickModeKeys
 	StickModeMouse
+
+	// StickModeModifier holds a keyboard key, usually a modifier, while the
+	// stick is pushed past a threshold in any direction.
+	StickModeModifier
 )
 
+// DefaultStickModifierThreshold is how far the stick needs to be pushed, out
+// of 1, to hold the key in modifier mode when no threshold is set.
+const DefaultStickModifierThreshold = 0.5
+
+// DefaultStickRunThreshold is how far the stick needs to be pushed in a
+// direction, out of 1, to press the run key of the direction in keys mode.
+const DefaultStickRunThreshold = 0.9
+
+// stickRunHysteresis is how far back from the run threshold the stick needs
+// to return to release the run key, so that it doesn't flap between the walk
+// and run keys while the stick rests near the threshold.
+const stickRunHysteresis = 0.1
+
+func (m StickMode) String() string {
+	switch m {
+	case StickModeOff:
+		return "off"
+	case StickModeJoystick:
+		return "joystick"
+	case StickModeKeys:
+		return "keys"
+	case StickModeMouse:
+		return "mouse"
+	case StickModeModifier:
+		return "modifier"
+	default:
+		return "unknown"
+	}
+}
+
 type stickCfg struct {
 	mode StickMode
 	keys StickKeys
+
+	// keyboard keys pressed instead of the ones in keys when the stick is
+	// pushed past the run threshold, in keys mode
+	runKeys      StickKeys
+	runThreshold float32
+
+	// keyboard key held in modifier mode, and how far the stick needs to be
+	// pushed to hold it
+	modifier          int
+	modifierThreshold float32
+
+	// calibration for joystick mode; nil uses the measured one or the
+	// default
+	calibration *StickCalibration
+
+	// measure the centre of the stick at startup
+	autoCalibrate bool
+
+	// keyboard keys pressed for stick gestures, in any mode; zero thresholds
+	// use the defaults
+	gestures          map[gesture.Gesture]int
+	gestureThresholds gesture.Thresholds
+
+	// advertise force feedback on the joystick, and the backlight colour
+	// shown while a game plays an effect, if any
+	forceFeedback bool
+	rumbleColour  *[3]uint8
+
+	// strength of the low-pass filter on the joystick stick; zero turns it
+	// off
+	smoothing float32
+
+	// axes of the joystick the stick is sent to
+	axes joystick.StickAxes
+}
+
+// GetOutput returns the name of the output backend that key bindings and
+// the joystick are sent to.
+func (cfg *G13Config) GetOutput() string {
+	if cfg.output == "" {
+		return output.DefaultSink
+	}
+	return cfg.output
+}
+
+// GetNetworkOutputAddress returns the address of the receiver that the
+// network output sends to.
+func (cfg *G13Config) GetNetworkOutputAddress() string {
+	return cfg.networkOutputAddress
+}
+
+// GetStickMode returns the mode of the thumb stick.
+func (cfg *G13Config) GetStickMode() StickMode {
+	return cfg.mapping.stick.mode
+}
+
+// GetGestureBindings returns the keyboard keys to press for each stick
+// gesture. It's empty if no gestures are bound.
+func (cfg *G13Config) GetGestureBindings() map[gesture.Gesture]int {
+	return cfg.mapping.stick.gestures
+}
+
+// GetGestureThresholds returns the thresholds for detecting stick gestures.
+func (cfg *G13Config) GetGestureThresholds() gesture.Thresholds {
+	if cfg.mapping.stick.gestureThresholds == (gesture.Thresholds{}) {
+		return gesture.DefaultThresholds()
+	}
+	return cfg.mapping.stick.gestureThresholds
+}
+
+// GetForceFeedback returns true if the joystick should advertise force
+// feedback.
+func (cfg *G13Config) GetForceFeedback() bool {
+	return cfg.mapping.stick.forceFeedback
+}
+
+// GetStickSmoothing returns the strength of the smoothing of the joystick
+// stick, from 0 for none to less than 1.
+func (cfg *G13Config) GetStickSmoothing() float32 {
+	return cfg.mapping.stick.smoothing
+}
+
+// GetRumbleColour returns the backlight colour to show while a game plays a
+// force feedback effect. The second return value is false if none is set.
+func (cfg *G13Config) GetRumbleColour() ([3]uint8, bool) {
+	if c := cfg.mapping.stick.rumbleColour; c != nil {
+		return *c, true
+	}
+	return [3]uint8{}, false
+}
+
+// GetJoystickAxes returns the axes of the joystick that the stick is sent
+// to.
+func (cfg *G13Config) GetJoystickAxes() joystick.StickAxes {
+	return cfg.mapping.stick.axes
+}
+
+// GetStickCalibration returns the calibration used for normalising the stick
+// position in joystick mode: the one set in the config, or the measured one
+// set with [G13Config.SetMeasuredStickCalibration], or the default.
+func (cfg *G13Config) GetStickCalibration() StickCalibration {
+	if c := cfg.mapping.stick.calibration; c != nil {
+		return *c
+	}
+	if c := cfg.measuredCalibration; c != nil {
+		return *c
+	}
+	return DefaultStickCalibration()
+}
+
+// SetMeasuredStickCalibration sets the calibration measured by the driver. It
+// doesn't override a calibration set in the config.
+func (cfg *G13Config) SetMeasuredStickCalibration(c *StickCalibration) {
+	cfg.measuredCalibration = c
+	for _, profile := range cfg.profiles {
+		profile.config.measuredCalibration = c
+	}
+}
+
+// HasStickCalibration returns true if the config sets the stick calibration.
+func (cfg *G13Config) HasStickCalibration() bool {
+	return cfg.mapping.stick.calibration != nil
+}
+
+// GetStickAutoCalibrate returns true if the centre of the stick should be
+// measured at startup.
+func (cfg *G13Config) GetStickAutoCalibrate() bool {
+	return cfg.mapping.stick.autoCalibrate
 }
 
 type StickKeys struct {
@@ -57,6 +402,30 @@ type StickKeys struct {
 	Right int
 }
 
+// loadStickKeys returns the keyboard keys of the stick directions set in the
+// mapping. Directions without a key are left at zero.
+func loadStickKeys(mapping fileStickMapping) (StickKeys, error) {
+	var keys StickKeys
+	for _, dir := range []struct {
+		name string
+		key  *int
+	}{
+		{mapping.Up, &keys.Up},
+		{mapping.Down, &keys.Down},
+		{mapping.Left, &keys.Left},
+		{mapping.Right, &keys.Right},
+	} {
+		if dir.name == "" {
+			continue
+		}
+		*dir.key = keyboard.KeyCode(dir.name)
+		if *dir.key == 0 {
+			return StickKeys{}, fmt.Errorf("unknown keyboard key name: %s", dir.name)
+		}
+	}
+	return keys, nil
+}
+
 // NewEmpty returns an empty [G13Config].
 func NewEmpty() *G13Config {
 	return &G13Config{
@@ -69,7 +438,9 @@ func NewEmpty() *G13Config {
 	}
 }
 
-// NewFromFile returns a [G13Config] initialised from the file at the given path.
+// NewFromFile returns a [G13Config] initialised from the file at the given
+// path. If the path is a directory, the main config is read from
+// [ConfigDirFile] in it and the profiles from the other JSON files.
 func NewFromFile(path string) (*G13Config, error) {
 	cfg, err := loadConfig(path)
 	if err != nil {
@@ -79,61 +450,102 @@ func NewFromFile(path string) (*G13Config, error) {
 	return cfg, nil
 }
 
+// GetActions returns the driver-internal actions bound to G13 keys.
+func (cfg *G13Config) GetActions() map[device.KeyBit]Action {
+	return cfg.mapping.actions
+}
+
+// MaskDisabledKeys returns the input (from [device.ReadInput]) with the keys
+// that are disabled in the config released, so that they do nothing.
+func (cfg *G13Config) MaskDisabledKeys(input uint64) uint64 {
+	return input &^ cfg.mapping.disabled
+}
+
+// IsBound returns true if the G13 key is mapped to a keyboard key, bound to
+// an action, or switches profiles or pages.
+func (cfg *G13Config) IsBound(gkey device.KeyBit) bool {
+	return cfg.mapping.binds(gkey) || cfg.isProfileKey(gkey) || cfg.isPageKey(gkey)
+}
+
+// GetKey returns the keyboard key the G13 key is mapped to, if it's mapped
+// to one. Unlike [G13Config.EachKeyState], it doesn't look at the stick or
+// change any state.
+func (cfg *G13Config) GetKey(gkey device.KeyBit) (int, bool) {
+	kbkey, ok := cfg.mapping.keyMap[gkey]
+	return kbkey, ok
+}
+
+// binds returns true if the G13 key is bound to a keyboard key, an action, a
+// macro, a script, scrolling, moving the pointer, window management or
+// switching audio devices, or is part of a chord.
+func (m Mapping) binds(gkey device.KeyBit) bool {
+	if m.chordKeys&gkey.Uint64() != 0 {
+		return true
+	}
+	if _, ok := m.scroll[gkey]; ok {
+		return true
+	}
+	if _, ok := m.warps[gkey]; ok {
+		return true
+	}
+	if _, ok := m.windows[gkey]; ok {
+		return true
+	}
+	if _, ok := m.audio[gkey]; ok {
+		return true
+	}
+	if _, ok := m.keyMap[gkey]; ok {
+		return true
+	}
+	if _, ok := m.actions[gkey]; ok {
+		return true
+	}
+	if _, ok := m.scripts[gkey]; ok {
+		return true
+	}
+	_, ok := m.macros[gkey]
+	return ok
+}
+
+// GetWarnUnmapped returns true if pressing a key that isn't bound to anything
+// should print a warning.
+func (cfg *G13Config) GetWarnUnmapped() bool {
+	return cfg.mapping.warnUnmapped
+}
+
 // SetKey maps a G13 key to the given keyboard key.
 func (m *G13Config) SetKey(gkey device.KeyBit, kbKey int) {
 	m.mapping.keyMap[gkey] = kbKey
+	m.mapping.indexOutputs()
 }
 
 // SetKeys maps one or more G13 keys to the given keyboard key. It does not
 // override any mappings not present in keyMap.
 func (m *G13Config) SetKeys(km keyMap) {
 	maps.Copy(m.mapping.keyMap, km)
+	m.mapping.indexOutputs()
 }
 
 // UnsetKey unmaps a gkey.
 func (m *G13Config) UnsetKey(gkey device.KeyBit) {
 	delete(m.mapping.keyMap, gkey)
+	m.mapping.indexOutputs()
 }
 
 // Reset unmaps all G13 keys.
 func (m *G13Config) Reset() {
 	m.mapping.keyMap = make(keyMap, len(device.AllKeys()))
+	m.mapping.indexOutputs()
 }
 
 // GetKeyStates returns the state of each mapped keyboard key for the given
 // input (from [device.ReadInput]). The result maps a keyboard keycode to a
 // state, true for down (pressed) and false for up (released).
 func (cfg *G13Config) GetKeyStates(input uint64) map[int]bool {
-	kbkeys := make(map[int]bool, len(cfg.mapping.keyMap))
-	for gkey, kbkey := range cfg.mapping.keyMap {
-		kbkeys[kbkey] = (gkey.Uint64() & input) != 0
-	}
-
-	if cfg.mapping.stick.mode == StickModeKeys {
-		var activeZone uint8 = 64 // TODO: make this configurable
-
-		stickKeys := cfg.mapping.stick.keys
-
-		kbkeys[stickKeys.Up] = false
-		kbkeys[stickKeys.Down] = false
-		kbkeys[stickKeys.Left] = false
-		kbkeys[stickKeys.Right] = false
-
-		// get the stick position and add the mapped key(s)
-		x, y := device.StickPosition(input)
-		if y <= activeZone && stickKeys.Up != 0 {
-			kbkeys[stickKeys.Up] = true
-		}
-		if y >= 255-activeZone && stickKeys.Down != 0 {
-			kbkeys[stickKeys.Down] = true
-		}
-		if x <= activeZone && stickKeys.Left != 0 {
-			kbkeys[stickKeys.Left] = true
-		}
-		if x >= 255-activeZone && stickKeys.Right != 0 {
-			kbkeys[stickKeys.Right] = true
-		}
-	}
+	kbkeys := make(map[int]bool, len(cfg.mapping.outputs))
+	cfg.EachKeyState(input, func(kbkey int, isDown bool) {
+		kbkeys[kbkey] = isDown
+	})
 	return kbkeys
 }
 
@@ -146,17 +558,31 @@ func (cfg *G13Config) GetStickPosition(input uint64) *StickPosition {
 	}
 
 	x, y := device.StickPosition(input)
-	return &StickPosition{posX: x, posY: y}
+	return &StickPosition{posX: x, posY: y, calibration: cfg.GetStickCalibration()}
 }
 
 func (cfg *G13Config) GetBacklight() [3]uint8 {
 	return cfg.backlight
 }
 
+// GetBacklightFlashes returns the backlight colour changes to show when keys
+// are pressed.
+func (cfg *G13Config) GetBacklightFlashes() map[device.KeyBit]BacklightFlash {
+	return cfg.backlightFlashes
+}
+
+// GetBacklightKeepalive returns the interval at which the backlight colour
+// should be resent to the device. Zero means the keepalive is off.
+func (cfg *G13Config) GetBacklightKeepalive() time.Duration {
+	return cfg.backlightKeepalive
+}
+
 func (cfg *G13Config) GetImagePath() string {
 	return cfg.lcdImage
 }
 
+// GetImage returns the image file configured for the display. BMP images are
+// used as is and SVG images are rendered at the size of the LCD.
 func (cfg *G13Config) GetImage() (image.Image, error) {
 	path := cfg.lcdImage
 	if path == "" {
@@ -166,7 +592,14 @@ func (cfg *G13Config) GetImage() (image.Image, error) {
 	if err != nil {
 		return nil, fmt.Errorf("failed to open image file %q: %w", path, err)
 	}
-	img, err := bmp.Decode(file)
+	defer file.Close()
+
+	var img image.Image
+	if strings.EqualFold(filepath.Ext(path), ".svg") {
+		img, err = lcd.RenderSVG(file)
+	} else {
+		img, err = bmp.Decode(file)
+	}
 	if err != nil {
 		return nil, fmt.Errorf("failed to read image file %q: %w", path, err)
 	}
@@ -175,21 +608,275 @@ func (cfg *G13Config) GetImage() (image.Image, error) {
 
 }
 
+// GetReadTimeout returns the timeout for reads from the device.
+func (cfg *G13Config) GetReadTimeout() time.Duration {
+	if cfg.input.readTimeout == 0 {
+		return DefaultReadTimeout
+	}
+	return cfg.input.readTimeout
+}
+
+// SetReadTimeout sets the timeout for reads from the device.
+func (cfg *G13Config) SetReadTimeout(dt time.Duration) {
+	cfg.input.readTimeout = dt
+}
+
+// GetErrorThreshold returns the number of consecutive read errors after which
+// the device should be reinitialised.
+func (cfg *G13Config) GetErrorThreshold() int {
+	if cfg.input.errorThreshold == 0 {
+		return DefaultErrorThreshold
+	}
+	return cfg.input.errorThreshold
+}
+
+// SetErrorThreshold sets the number of consecutive read errors after which the
+// device should be reinitialised.
+func (cfg *G13Config) SetErrorThreshold(n int) {
+	cfg.input.errorThreshold = n
+}
+
+// GetRetryDelay returns the time to wait after a read error before reading
+// again.
+func (cfg *G13Config) GetRetryDelay() time.Duration {
+	if cfg.input.retryDelay == 0 {
+		return DefaultRetryDelay
+	}
+	return cfg.input.retryDelay
+}
+
+// SetRetryDelay sets the time to wait after a read error before reading again.
+func (cfg *G13Config) SetRetryDelay(dt time.Duration) {
+	cfg.input.retryDelay = dt
+}
+
+// GetRetryBackoff returns the backoff between reads after consecutive read
+// errors, starting from the retry delay.
+func (cfg *G13Config) GetRetryBackoff() backoff.Policy {
+	maxDelay := cfg.input.retryMaxDelay
+	if maxDelay == 0 {
+		maxDelay = DefaultRetryMaxDelay
+	}
+	minDelay := cfg.GetRetryDelay()
+	return backoff.Policy{Min: minDelay, Max: max(minDelay, maxDelay)}
+}
+
+// GetReconnectBackoff returns the backoff between attempts to open the device
+// while it's not connected.
+func (cfg *G13Config) GetReconnectBackoff() backoff.Policy {
+	policy := device.DefaultReconnectPolicy
+	if cfg.input.reconnectDelay != 0 {
+		policy.Min = cfg.input.reconnectDelay
+	}
+	if cfg.input.reconnectMaxDelay != 0 {
+		policy.Max = cfg.input.reconnectMaxDelay
+	}
+	policy.Max = max(policy.Min, policy.Max)
+	return policy
+}
+
+// GetMQTTOptions returns the options for connecting to an MQTT broker, or nil
+// if the integration isn't enabled.
+func (cfg *G13Config) GetMQTTOptions() *mqtt.Options {
+	return cfg.mqtt
+}
+
+// GetMuteOnScreenLock returns true if all output should stop and the LCD
+// blank while the graphical session of the user is locked.
+func (cfg *G13Config) GetMuteOnScreenLock() bool {
+	return cfg.muteOnScreenLock
+}
+
+// GetScreenLockProfile returns the name of the profile to latch while the
+// graphical session of the user is locked, or an empty string if output
+// stops instead. It's only set with [G13Config.GetMuteOnScreenLock].
+func (cfg *G13Config) GetScreenLockProfile() string {
+	return cfg.screenLockProfile
+}
+
+// GetLCDImage returns the image that should be displayed on the LCD: the
+// binding cheat sheet or the configured image file, converted to black and
+// white with [G13Config.GetLCDMonochrome]. It returns nil if the config doesn't
+// define any LCD content.
+func (cfg *G13Config) GetLCDImage() (image.Image, error) {
+	if cfg.lcdCheatSheet {
+		return cfg.GetLCDMonochrome().Apply(cfg.CheatSheet()), nil
+	}
+	if cfg.lcdImage != "" {
+		img, err := cfg.GetImage()
+		if err != nil {
+			return nil, err
+		}
+		return cfg.GetLCDMonochrome().Apply(img), nil
+	}
+	return nil, nil
+}
+
+// GetLCDTextFile returns the path of the text file shown on the LCD, which is
+// "-" for standard input, or an empty string if none is configured.
+func (cfg *G13Config) GetLCDTextFile() string {
+	return cfg.lcdTextFile
+}
+
+// GetLCDApplet returns the applet configured for the LCD and the interval at
+// which it should be rendered. It returns a nil applet if none is configured.
+// The images of the applet should be converted to black and white with
+// [G13Config.GetLCDMonochrome] before they are displayed.
+func (cfg *G13Config) GetLCDApplet() (applet.Applet, time.Duration, error) {
+	if cfg.httpPage == nil && cfg.timer == nil && !cfg.lcdCounters && cfg.lcdTextFile == "" && cfg.templatePage == nil {
+		return nil, 0, nil
+	}
+	face, err := cfg.GetLCDFace()
+	if err != nil {
+		return nil, 0, err
+	}
+	if cfg.timer != nil {
+		return applet.NewTimer(cfg.timer.duration, face), timerRenderInterval, nil
+	}
+	if cfg.lcdCounters {
+		return applet.NewCounters(face), countersRenderInterval, nil
+	}
+	if cfg.templatePage != nil {
+		a, err := applet.NewTemplatePage(cfg.templatePage.template, face)
+		if err != nil {
+			return nil, 0, err
+		}
+		return a, cfg.templatePage.interval, nil
+	}
+	if cfg.lcdTextFile != "" {
+		a, err := applet.NewTextFile(cfg.lcdTextFile, face)
+		if err != nil {
+			return nil, 0, err
+		}
+		return a, textFileRenderInterval, nil
+	}
+	a, err := applet.NewHTTPJSON(cfg.httpPage.url, cfg.httpPage.template, cfg.httpPage.interval, face)
+	if err != nil {
+		return nil, 0, err
+	}
+	return a, cfg.httpPage.interval, nil
+}
+
+// CheatSheet renders the current key mapping as an image for the LCD.
+func (cfg *G13Config) CheatSheet() image.Image {
+	bindings := make(map[device.KeyBit]string, len(cfg.mapping.keyMap))
+	for gkey, kbkey := range cfg.mapping.keyMap {
+		bindings[gkey] = keyboard.KeyName(kbkey)
+	}
+	return lcd.CheatSheet(bindings, cfg.mapping.stick.mode.String())
+}
+
 // fileConfig describes the on-disk file format for the config file.
 type fileConfig struct {
-	Mapping   fileMapping         `json:"mapping"`
-	Backlight backlightFileConfig `json:"backlight"`
-	ImageFile string              `json:"image_file"`
+	Version    int                 `json:"version"`
+	Mapping    fileMapping         `json:"mapping"`
+	Backlight  backlightFileConfig `json:"backlight"`
+	ImageFile  string              `json:"image_file"`
+	CheatSheet bool                `json:"cheatsheet"`
+	HTTPPage   *httpPageFileConfig `json:"http_page"`
+	Timer      *timerFileConfig    `json:"timer"`
+	Counters   bool                `json:"counters"`
+	TextFile   string              `json:"text_file"`
+	LCDFont    *lcdFontFileConfig  `json:"lcd_font"`
+	Input      inputFileConfig     `json:"input"`
+	MQTT       *mqttFileConfig     `json:"mqtt"`
+	Output     string              `json:"output"`
+	Desktop    string              `json:"desktop"`
+
+	NetworkOutput *networkOutputFileConfig `json:"network_output"`
+	LCDMonochrome *lcdMonochromeFileConfig `json:"lcd_monochrome"`
+	TemplatePage  *templatePageFileConfig  `json:"template_page"`
+	LCDMessages   *lcdMessagesFileConfig   `json:"lcd_messages"`
+	Lock          *lockFileConfig          `json:"lock"`
+	Screen        *screenFileConfig        `json:"screen"`
+	Pages         *pagesFileConfig         `json:"pages"`
+
+	Profiles map[string]fileProfile      `json:"profiles"`
+	Macros   map[string][]fileMacroEvent `json:"macros"`
+
+	MuteOnScreenLock  bool   `json:"mute_on_screen_lock"`
+	ScreenLockProfile string `json:"screen_lock_profile"`
+}
+
+type networkOutputFileConfig struct {
+	Address string `json:"address"`
+}
+
+type mqttFileConfig struct {
+	Broker       string `json:"broker"`
+	ClientID     string `json:"client_id"`
+	Username     string `json:"username"`
+	PasswordFile string `json:"password_file"`
+	TopicPrefix  string `json:"topic_prefix"`
+}
+
+type inputFileConfig struct {
+	ReadTimeout    string `json:"read_timeout"`
+	ErrorThreshold int    `json:"error_threshold"`
+	RetryDelay     string `json:"retry_delay"`
+	RetryMaxDelay  string `json:"retry_max_delay"`
+
+	ReconnectDelay    string `json:"reconnect_delay"`
+	ReconnectMaxDelay string `json:"reconnect_max_delay"`
 }
 
 type fileMapping struct {
-	Keys  map[string]string `json:"keys"`
-	Stick fileStickConfig   `json:"stick"`
+	Keys    map[string]string `json:"keys"`
+	Actions map[string]string `json:"actions"`
+	Macros  map[string]string `json:"macros"`
+	Chords  map[string]string `json:"chords"`
+	Scripts map[string]string `json:"scripts"`
+	Scroll  map[string]string `json:"scroll"`
+	Warp    map[string]string `json:"warp"`
+	Windows map[string]string `json:"windows"`
+	Audio   map[string]string `json:"audio"`
+	Stick   fileStickConfig   `json:"stick"`
+
+	Disabled     []string `json:"disabled"`
+	RepeatMacros []string `json:"repeat_macros"`
+	WarnUnmapped bool     `json:"warn_unmapped"`
+
+	StickyKeys bool   `json:"sticky_keys"`
+	SlowKeys   string `json:"slow_keys"`
 }
 
 type fileStickConfig struct {
-	Mode string           `json:"mode"`
-	Keys fileStickMapping `json:"keys"`
+	Mode         string                `json:"mode"`
+	Keys         fileStickMapping      `json:"keys"`
+	RunKeys      *fileStickMapping     `json:"run_keys"`
+	RunThreshold *float32              `json:"run_threshold"`
+	Modifier     string                `json:"modifier"`
+	Threshold    *float32              `json:"threshold"`
+	Calibration  *fileStickCalibration `json:"calibration"`
+	Gestures     *fileGestureConfig    `json:"gestures"`
+
+	ForceFeedback bool   `json:"force_feedback"`
+	RumbleColour  string `json:"rumble_colour"`
+
+	Smoothing float32 `json:"smoothing"`
+
+	Axes *fileStickAxes `json:"axes"`
+}
+
+type fileStickAxes struct {
+	X       string `json:"x"`
+	Y       string `json:"y"`
+	InvertX bool   `json:"invert_x"`
+	InvertY bool   `json:"invert_y"`
+}
+
+type fileGestureConfig struct {
+	Bindings   map[string]string `json:"bindings"`
+	Edge       *float64          `json:"edge"`
+	Rest       *float64          `json:"rest"`
+	FlickTime  string            `json:"flick_time"`
+	CircleTime string            `json:"circle_time"`
+}
+
+type fileStickCalibration struct {
+	CentreX *uint8 `json:"centre_x"`
+	CentreY *uint8 `json:"centre_y"`
+	Auto    bool   `json:"auto"`
 }
 
 type fileStickMapping struct {
@@ -199,120 +886,823 @@ type fileStickMapping struct {
 	Right string `json:"Right"`
 }
 
+type httpPageFileConfig struct {
+	URL      string `json:"url"`
+	Interval string `json:"interval"`
+	Template string `json:"template"`
+}
+
 type backlightFileConfig struct {
-	Red   uint8 `json:"red"`
-	Green uint8 `json:"green"`
-	Blue  uint8 `json:"blue"`
+	Red       uint8  `json:"red"`
+	Green     uint8  `json:"green"`
+	Blue      uint8  `json:"blue"`
+	Colour    string `json:"colour"`
+	Keepalive string `json:"keepalive"`
+
+	Flash map[string]flashFileConfig `json:"flash"`
+}
+
+type flashFileConfig struct {
+	Colour   string `json:"colour"`
+	Duration string `json:"duration"`
+}
+
+// UnmarshalJSON accepts either the full backlight object or a colour string
+// as a shorthand for {"colour": "..."}.
+func (b *backlightFileConfig) UnmarshalJSON(data []byte) error {
+	var colour string
+	if err := json.Unmarshal(data, &colour); err == nil {
+		*b = backlightFileConfig{Colour: colour}
+		return nil
+	}
+
+	// decode into a type without the UnmarshalJSON method to avoid
+	// recursion, keeping unknown fields disallowed like the rest of the file
+	type plainBacklight backlightFileConfig
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	return decoder.Decode((*plainBacklight)(b))
 }
 
-func loadConfig(path string) (*G13Config, error) {
-	configFile, err := os.Open(path)
+func loadConfig(path string, bindings ...Binding) (*G13Config, error) {
+	// a config directory has the main config in a file, and files are
+	// resolved relative to it
+	var dir string
+	if info, err := os.Stat(path); err == nil && info.IsDir() {
+		dir = path
+		path = filepath.Join(dir, ConfigDirFile)
+	}
+
+	data, err := os.ReadFile(path)
 	if err != nil {
 		return nil, fmt.Errorf("failed opening config file %q: %w", path, err)
 	}
 
+	// before migrating, which keeps only the last of them
+	duplicates := duplicateKeys(data)
+	data, _, err = Migrate(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed decoding config file %q: %w", path, err)
+	}
+
 	cfg := fileConfig{}
-	decoder := json.NewDecoder(configFile)
+	decoder := json.NewDecoder(bytes.NewReader(data))
 	decoder.DisallowUnknownFields()
 	if err := decoder.Decode(&cfg); err != nil {
 		return nil, fmt.Errorf("failed decoding config file %q: %w", path, err)
 	}
 
 	errPrefix := "failed reading config file"
-	km := make(keyMap, len(cfg.Mapping.Keys))
-	for gKeyStr, kbKeyStr := range cfg.Mapping.Keys {
-		gKey := device.KeyCode(gKeyStr)
-		if gKey == 0 {
-			return nil, fmt.Errorf("%s: unknown G13 key name: %s", errPrefix, gKeyStr)
-		}
-		kbKey := keyboard.KeyCode(kbKeyStr)
-		if kbKey == 0 {
-			return nil, fmt.Errorf("%s: unknown keyboard key name: %s", errPrefix, kbKeyStr)
+	if dir != "" {
+		dirProfiles, err := loadProfileDir(dir)
+		if err != nil {
+			return nil, err
 		}
-		km[gKey] = kbKey
-	}
-
-	stickConfig := stickCfg{}
-	switch stick := cfg.Mapping.Stick; stick.Mode {
-	case "":
-		stickConfig.mode = StickModeOff
-	case "joystick":
-		stickConfig.mode = StickModeJoystick
-	case "mouse":
-		return nil, fmt.Errorf("stick mode 'mouse' not yet supported")
-	case "keys":
-		stickConfig.mode = StickModeKeys
-
-		var up, down, left, right int
-		if stick.Keys.Up != "" {
-			up = keyboard.KeyCode(stick.Keys.Up)
-			if up == 0 {
-				return nil, fmt.Errorf("%s: unknown keyboard key name: %s", errPrefix, stick.Keys.Up)
+		for name, profile := range dirProfiles {
+			if _, ok := cfg.Profiles[name]; ok {
+				return nil, fmt.Errorf("%s: profiles: %s: defined in both %s and %s.json", errPrefix, name, ConfigDirFile, name)
+			}
+			if cfg.Profiles == nil {
+				cfg.Profiles = make(map[string]fileProfile, len(dirProfiles))
 			}
+			cfg.Profiles[name] = profile
 		}
+	}
+	if err := applyBindings(&cfg, bindings); err != nil {
+		return nil, fmt.Errorf("%s: %w", errPrefix, err)
+	}
+	mapping, err := loadMapping(cfg.Mapping)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", errPrefix, err)
+	}
+	profiles, err := loadProfiles(cfg.Profiles, mapping)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", errPrefix, err)
+	}
+	pages, err := loadPages(cfg.Pages, mapping, profiles)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", errPrefix, err)
+	}
+	macros, err := loadMacros(cfg.Macros)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", errPrefix, err)
+	}
+	if err := checkMacroBindings(mapping, macros); err != nil {
+		return nil, fmt.Errorf("%s: %w", errPrefix, err)
+	}
+	for _, name := range slices.Sorted(maps.Keys(profiles)) {
+		if err := checkMacroBindings(profiles[name].config.mapping, macros); err != nil {
+			return nil, fmt.Errorf("%s: profiles: %s: %w", errPrefix, name, err)
+		}
+	}
+
+	backlight := [3]uint8{cfg.Backlight.Red, cfg.Backlight.Green, cfg.Backlight.Blue}
+	if cfg.Backlight.Colour != "" {
+		if backlight != [3]uint8{} {
+			return nil, fmt.Errorf("%s: backlight: colour can't be combined with red, green, and blue", errPrefix)
+		}
+		backlight, err = ParseColour(cfg.Backlight.Colour)
+		if err != nil {
+			return nil, fmt.Errorf("%s: backlight: %w", errPrefix, err)
+		}
+	}
 
-		if stick.Keys.Down != "" {
-			down = keyboard.KeyCode(stick.Keys.Down)
-			if down == 0 {
-				return nil, fmt.Errorf("%s: unknown keyboard key name: %s", errPrefix, stick.Keys.Down)
+	flashes, err := loadFlashes(cfg.Backlight.Flash)
+	if err != nil {
+		return nil, fmt.Errorf("%s: backlight: %w", errPrefix, err)
+	}
+
+	var keepalive time.Duration
+	switch cfg.Backlight.Keepalive {
+	case "", "off":
+		// keepalive disabled
+	default:
+		keepalive, err = time.ParseDuration(cfg.Backlight.Keepalive)
+		if err != nil {
+			return nil, fmt.Errorf("%s: backlight: invalid keepalive %q: %w", errPrefix, cfg.Backlight.Keepalive, err)
+		}
+		if keepalive <= 0 {
+			return nil, fmt.Errorf("%s: backlight: keepalive must be positive or \"off\": %s", errPrefix, cfg.Backlight.Keepalive)
+		}
+	}
+
+	imageFile := cfg.ImageFile
+
+	lcdSources := 0
+	for _, isSet := range []bool{imageFile != "", cfg.CheatSheet, cfg.HTTPPage != nil, cfg.Timer != nil, cfg.Counters, cfg.TextFile != "", cfg.TemplatePage != nil} {
+		if isSet {
+			lcdSources++
+		}
+	}
+	if lcdSources > 1 {
+		return nil, fmt.Errorf("%s: only one of image_file, cheatsheet, http_page, timer, counters, text_file, and template_page can be set", errPrefix)
+	}
+
+	var httpPage *httpPageCfg
+	if cfg.HTTPPage != nil {
+		httpPage, err = loadHTTPPage(cfg.HTTPPage)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", errPrefix, err)
+		}
+	}
+
+	var templatePage *templatePageCfg
+	if cfg.TemplatePage != nil {
+		templatePage, err = loadTemplatePage(cfg.TemplatePage)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", errPrefix, err)
+		}
+	}
+
+	var timer *timerCfg
+	if cfg.Timer != nil {
+		timer, err = loadTimer(cfg.Timer)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", errPrefix, err)
+		}
+	}
+	// the actions of the main mapping and of each profile, by the prefix of
+	// their errors
+	actionSets := map[string]map[device.KeyBit]Action{"": mapping.actions}
+	for name, profile := range profiles {
+		actionSets["profiles: "+name+": "] = profile.config.mapping.actions
+	}
+	for where, actions := range actionSets {
+		for gKey, action := range actions {
+			if timerActions[action] && timer == nil {
+				return nil, fmt.Errorf("%s: %sactions: %s: %s requires a timer", errPrefix, where, gKey, action)
+			}
+			if action == ActionClearCounters && !cfg.Counters {
+				return nil, fmt.Errorf("%s: %sactions: %s: %s requires counters", errPrefix, where, gKey, action)
 			}
 		}
+	}
+
+	var lcdFont *lcdFontCfg
+	if cfg.LCDFont != nil {
+		lcdFont, err = loadLCDFont(cfg.LCDFont, path)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", errPrefix, err)
+		}
+	}
+
+	var lcdMonochrome *device.Monochrome
+	if cfg.LCDMonochrome != nil {
+		lcdMonochrome, err = loadLCDMonochrome(cfg.LCDMonochrome)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", errPrefix, err)
+		}
+	}
+
+	var lcdMessageDuration time.Duration
+	if cfg.LCDMessages != nil {
+		lcdMessageDuration, err = loadLCDMessages(cfg.LCDMessages)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", errPrefix, err)
+		}
+	}
+
+	unlockChord, err := loadLock(cfg.Lock)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", errPrefix, err)
+	}
+
+	screen, err := loadScreen(cfg.Screen)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", errPrefix, err)
+	}
+	if err := checkWarps(mapping.warps, screen); err != nil {
+		return nil, fmt.Errorf("%s: %w", errPrefix, err)
+	}
+	for name, profile := range profiles {
+		if err := checkWarps(profile.config.mapping.warps, screen); err != nil {
+			return nil, fmt.Errorf("%s: profiles: %s: %w", errPrefix, name, err)
+		}
+	}
+
+	if cfg.ScreenLockProfile != "" {
+		if !cfg.MuteOnScreenLock {
+			return nil, fmt.Errorf("%s: screen_lock_profile requires mute_on_screen_lock", errPrefix)
+		}
+		if _, ok := profiles[cfg.ScreenLockProfile]; !ok {
+			return nil, fmt.Errorf("%s: screen_lock_profile: unknown profile: %s", errPrefix, cfg.ScreenLockProfile)
+		}
+	}
+
+	input, err := loadInput(cfg.Input)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", errPrefix, err)
+	}
+
+	if cfg.Output != "" {
+		if err := output.Validate(cfg.Output); err != nil {
+			return nil, fmt.Errorf("%s: %w", errPrefix, err)
+		}
+	}
+	if cfg.Desktop != "" {
+		if err := desktop.Validate(cfg.Desktop); err != nil {
+			return nil, fmt.Errorf("%s: %w", errPrefix, err)
+		}
+	}
+	var networkOutputAddress string
+	if cfg.NetworkOutput != nil {
+		networkOutputAddress = cfg.NetworkOutput.Address
+	}
+	if cfg.Output == "network" && networkOutputAddress == "" {
+		return nil, fmt.Errorf("%s: network_output: address is required for the network output", errPrefix)
+	}
+
+	var mqttOpts *mqtt.Options
+	if cfg.MQTT != nil {
+		mqttOpts, err = loadMQTT(cfg.MQTT, path)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", errPrefix, err)
+		}
+	}
+
+	if imageFile != "" {
+		imageFile, err = checkImageFile(imageFile, path)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", errPrefix, err)
+		}
+	}
 
-		if stick.Keys.Left != "" {
-			left = keyboard.KeyCode(stick.Keys.Left)
-			if left == 0 {
-				return nil, fmt.Errorf("%s: unknown keyboard key name: %s", errPrefix, stick.Keys.Left)
+	textFile := cfg.TextFile
+	if textFile != "" && textFile != "-" {
+		textFile, err = checkTextFile(textFile, path)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", errPrefix, err)
+		}
+	}
+
+	for name, profile := range cfg.Profiles {
+		profiles[name].lcd, err = loadProfileLCD(profile, path)
+		if err != nil {
+			return nil, fmt.Errorf("%s: profiles: %s: %w", errPrefix, name, err)
+		}
+	}
+
+	g13cfg := &G13Config{
+		mapping:              mapping,
+		profiles:             profiles,
+		backlight:            backlight,
+		backlightKeepalive:   keepalive,
+		backlightFlashes:     flashes,
+		lcdImage:             imageFile,
+		lcdCheatSheet:        cfg.CheatSheet,
+		httpPage:             httpPage,
+		timer:                timer,
+		lcdTextFile:          textFile,
+		templatePage:         templatePage,
+		lcdCounters:          cfg.Counters,
+		lcdFont:              lcdFont,
+		lcdMonochrome:        lcdMonochrome,
+		lcdMessageDuration:   lcdMessageDuration,
+		input:                input,
+		output:               cfg.Output,
+		desktop:              cfg.Desktop,
+		networkOutputAddress: networkOutputAddress,
+		mqtt:                 mqttOpts,
+		macros:               macros,
+		unlockChord:          unlockChord,
+		screen:               screen,
+		pages:                pages,
+		muteOnScreenLock:     cfg.MuteOnScreenLock,
+		screenLockProfile:    cfg.ScreenLockProfile,
+	}
+	g13cfg.warnings = append(duplicates, g13cfg.lint(&cfg)...)
+	g13cfg.setProfileConfigs()
+	return g13cfg, nil
+}
+
+// checkImageFile returns the path of the image file for the LCD, relative to
+// the config file at cfgPath, and checks that it exists.
+func checkImageFile(imageFile, cfgPath string) (string, error) {
+	path, err := resolvePath(imageFile, cfgPath)
+	if err != nil {
+		return "", fmt.Errorf("image_file: %w", err)
+	}
+
+	// Check if the image file exists and is stat-able; no need for any
+	// extra validation right now
+	_, err = os.Stat(path)
+	if os.IsNotExist(err) {
+		return "", fmt.Errorf("image file %q (%s) set in config file does not exist", imageFile, path)
+	}
+	if err != nil {
+		return "", err
+	}
+	return path, nil
+}
+
+// checkTextFile returns the path of the text file for the LCD, relative to
+// the config file at cfgPath, and checks that it exists.
+func checkTextFile(textFile, cfgPath string) (string, error) {
+	path, err := resolvePath(textFile, cfgPath)
+	if err != nil {
+		return "", fmt.Errorf("text_file: %w", err)
+	}
+	if _, err := os.Stat(path); err != nil {
+		return "", fmt.Errorf("text_file: %w", err)
+	}
+	return path, nil
+}
+
+// loadMapping returns the bindings described in the mapping section of the
+// config file, or of a profile.
+func loadMapping(m fileMapping) (Mapping, error) {
+	km := make(keyMap, len(m.Keys))
+	for gKeyStr, kbKeyStr := range m.Keys {
+		gKey := device.KeyCode(gKeyStr)
+		if gKey == 0 {
+			return Mapping{}, fmt.Errorf("unknown G13 key name: %s", gKeyStr)
+		}
+		kbKey := keyboard.KeyCode(kbKeyStr)
+		if kbKey == 0 {
+			return Mapping{}, fmt.Errorf("unknown keyboard key name: %s", kbKeyStr)
+		}
+		km[gKey] = kbKey
+	}
+
+	actions, err := loadActions(m.Actions, km)
+	if err != nil {
+		return Mapping{}, err
+	}
+
+	macros, err := loadMacroBindings(m.Macros, km, actions)
+	if err != nil {
+		return Mapping{}, err
+	}
+	repeatMacros, err := loadRepeatMacros(m.RepeatMacros, macros)
+	if err != nil {
+		return Mapping{}, err
+	}
+
+	scripts, err := loadScripts(m.Scripts, km, actions, macros)
+	if err != nil {
+		return Mapping{}, err
+	}
+
+	chords, err := loadChords(m.Chords, km, actions, macros, scripts)
+	if err != nil {
+		return Mapping{}, err
+	}
+	var chordKeys uint64
+	for chord := range chords {
+		chordKeys |= chord
+	}
+
+	bound := Mapping{keyMap: km, actions: actions, macros: macros, scripts: scripts, chordKeys: chordKeys}
+	scroll, err := loadScroll(m.Scroll, bound)
+	if err != nil {
+		return Mapping{}, err
+	}
+	bound.scroll = scroll
+	warps, err := loadWarps(m.Warp, bound)
+	if err != nil {
+		return Mapping{}, err
+	}
+	bound.warps = warps
+	windows, err := loadWindows(m.Windows, bound)
+	if err != nil {
+		return Mapping{}, err
+	}
+	bound.windows = windows
+	audioSwitches, err := loadAudio(m.Audio, bound)
+	if err != nil {
+		return Mapping{}, err
+	}
+
+	var disabled uint64
+	for _, gKeyStr := range m.Disabled {
+		gKey := device.KeyCode(gKeyStr)
+		if gKey == 0 {
+			return Mapping{}, fmt.Errorf("disabled: unknown G13 key name: %s", gKeyStr)
+		}
+		disabled |= gKey.Uint64()
+	}
+
+	stickConfig := stickCfg{}
+	switch stick := m.Stick; stick.Mode {
+	case "":
+		stickConfig.mode = StickModeOff
+	case "joystick":
+		stickConfig.mode = StickModeJoystick
+		if c := stick.Calibration; c != nil && c.Auto {
+			if c.CentreX != nil || c.CentreY != nil {
+				return Mapping{}, fmt.Errorf("stick: calibration: auto can't be combined with centre_x and centre_y")
+			}
+			stickConfig.autoCalibrate = true
+		} else if c != nil {
+			calibration := DefaultStickCalibration()
+			if c.CentreX != nil {
+				calibration.CentreX = *c.CentreX
+			}
+			if c.CentreY != nil {
+				calibration.CentreY = *c.CentreY
 			}
+			stickConfig.calibration = &calibration
 		}
+	case "mouse":
+		return Mapping{}, fmt.Errorf("stick mode 'mouse' not yet supported")
+	case "keys":
+		stickConfig.mode = StickModeKeys
 
-		if stick.Keys.Right != "" {
-			right = keyboard.KeyCode(stick.Keys.Right)
-			if right == 0 {
-				return nil, fmt.Errorf("%s: unknown keyboard key name: %s", errPrefix, stick.Keys.Right)
+		stickConfig.keys, err = loadStickKeys(stick.Keys)
+		if err != nil {
+			return Mapping{}, err
+		}
+		if stick.RunKeys != nil {
+			stickConfig.runKeys, err = loadStickKeys(*stick.RunKeys)
+			if err != nil {
+				return Mapping{}, fmt.Errorf("stick: run_keys: %w", err)
 			}
+			stickConfig.runThreshold = DefaultStickRunThreshold
 		}
-		stickConfig.keys = StickKeys{
-			Up:    up,
-			Down:  down,
-			Left:  left,
-			Right: right,
+		if stick.RunThreshold != nil {
+			if *stick.RunThreshold <= stickRunHysteresis || *stick.RunThreshold > 1 {
+				return Mapping{}, fmt.Errorf("stick: run_threshold must be more than %g and at most 1: %g", stickRunHysteresis, *stick.RunThreshold)
+			}
+			stickConfig.runThreshold = *stick.RunThreshold
+		}
+	case "modifier":
+		stickConfig.mode = StickModeModifier
+		if stick.Modifier == "" {
+			return Mapping{}, fmt.Errorf("stick: modifier is required for the modifier mode")
+		}
+		stickConfig.modifier = keyboard.KeyCode(stick.Modifier)
+		if stickConfig.modifier == 0 {
+			return Mapping{}, fmt.Errorf("unknown keyboard key name: %s", stick.Modifier)
+		}
+		stickConfig.modifierThreshold = DefaultStickModifierThreshold
+		if stick.Threshold != nil {
+			if *stick.Threshold <= 0 || *stick.Threshold > 1 {
+				return Mapping{}, fmt.Errorf("stick: threshold must be more than 0 and at most 1: %g", *stick.Threshold)
+			}
+			stickConfig.modifierThreshold = *stick.Threshold
 		}
 	default:
-		return nil, fmt.Errorf("%s: unknown stick mode: %s", errPrefix, stick.Mode)
+		return Mapping{}, fmt.Errorf("unknown stick mode: %s", stick.Mode)
+	}
+	if stickConfig.mode != StickModeModifier && (m.Stick.Modifier != "" || m.Stick.Threshold != nil) {
+		return Mapping{}, fmt.Errorf("stick: modifier and threshold require the modifier mode")
+	}
+	if stickConfig.mode != StickModeKeys && (m.Stick.RunKeys != nil || m.Stick.RunThreshold != nil) {
+		return Mapping{}, fmt.Errorf("stick: run_keys and run_threshold require the keys mode")
+	}
+	if stickConfig.mode != StickModeJoystick && m.Stick.Calibration != nil {
+		// the other modes use the measured calibration
+		return Mapping{}, fmt.Errorf("stick: calibration requires the joystick mode")
 	}
 
-	backlight := [3]uint8{cfg.Backlight.Red, cfg.Backlight.Green, cfg.Backlight.Blue}
+	if stick := m.Stick; stick.ForceFeedback || stick.RumbleColour != "" {
+		if stickConfig.mode != StickModeJoystick {
+			return Mapping{}, fmt.Errorf("stick: force feedback requires the joystick mode")
+		}
+		stickConfig.forceFeedback = true
+		if stick.RumbleColour != "" {
+			colour, err := ParseColour(stick.RumbleColour)
+			if err != nil {
+				return Mapping{}, fmt.Errorf("stick: rumble_colour: %w", err)
+			}
+			stickConfig.rumbleColour = &colour
+		}
+	}
 
-	imageFile := cfg.ImageFile
+	if smoothing := m.Stick.Smoothing; smoothing != 0 {
+		if stickConfig.mode != StickModeJoystick {
+			return Mapping{}, fmt.Errorf("stick: smoothing requires the joystick mode")
+		}
+		if smoothing < 0 || smoothing >= 1 {
+			return Mapping{}, fmt.Errorf("stick: smoothing must be at least 0 and less than 1: %g", smoothing)
+		}
+		stickConfig.smoothing = smoothing
+	}
 
-	if imageFile != "" {
-		// The image file, if defined, should be relative to the config file
-		// (unless it's already absolute)
-		if !filepath.IsAbs(imageFile) {
-			cfgDir, err := filepath.Abs(filepath.Dir(path))
+	if axes := m.Stick.Axes; axes != nil {
+		if stickConfig.mode != StickModeJoystick {
+			return Mapping{}, fmt.Errorf("stick: axes require the joystick mode")
+		}
+		stickConfig.axes = joystick.StickAxes{
+			X:       joystick.Axis(axes.X),
+			Y:       joystick.Axis(axes.Y),
+			InvertX: axes.InvertX,
+			InvertY: axes.InvertY,
+		}
+		if err := stickConfig.axes.Validate(); err != nil {
+			return Mapping{}, fmt.Errorf("stick: axes: %w", err)
+		}
+	}
+
+	if m.Stick.Gestures != nil {
+		stickConfig.gestures, stickConfig.gestureThresholds, err = loadGestures(m.Stick.Gestures)
+		if err != nil {
+			return Mapping{}, err
+		}
+	}
+
+	accessibility, err := loadAccessibility(m)
+	if err != nil {
+		return Mapping{}, err
+	}
+
+	mapping := Mapping{
+		keyMap:        km,
+		stick:         stickConfig,
+		actions:       actions,
+		macros:        macros,
+		repeatMacros:  repeatMacros,
+		scripts:       scripts,
+		chords:        chords,
+		chordKeys:     chordKeys,
+		scroll:        scroll,
+		warps:         warps,
+		windows:       windows,
+		audio:         audioSwitches,
+		disabled:      disabled,
+		warnUnmapped:  m.WarnUnmapped,
+		accessibility: accessibility,
+	}
+	mapping.indexOutputs()
+	return mapping, nil
+}
+
+func loadFlashes(flashes map[string]flashFileConfig) (map[device.KeyBit]BacklightFlash, error) {
+	if len(flashes) == 0 {
+		return nil, nil
+	}
+
+	loaded := make(map[device.KeyBit]BacklightFlash, len(flashes))
+	for keyName, flash := range flashes {
+		gKey := device.KeyCode(keyName)
+		if gKey == 0 {
+			return nil, fmt.Errorf("flash: unknown G13 key name: %s", keyName)
+		}
+
+		colour := DefaultFlashColour
+		if flash.Colour != "" {
+			var err error
+			colour, err = ParseColour(flash.Colour)
 			if err != nil {
-				return nil, fmt.Errorf("failed to get absolute path of config file %q: %w", path, err)
+				return nil, fmt.Errorf("flash: %s: %w", keyName, err)
 			}
-			imageFile = filepath.Clean(filepath.Join(cfgDir, imageFile))
 		}
 
-		// Check if the image file exists and is stat-able if it's set; no
-		// need for any extra validation right now
-		_, err := os.Stat(imageFile)
-		if os.IsNotExist(err) {
-			return nil, fmt.Errorf("%s: image file %q (%s) set in config file does not exist", errPrefix, cfg.ImageFile, imageFile)
+		var duration time.Duration
+		switch flash.Duration {
+		case "":
+			duration = DefaultFlashDuration
+		case "hold":
+			// zero: keep the colour while the key is held
+		default:
+			var err error
+			duration, err = time.ParseDuration(flash.Duration)
+			if err != nil {
+				return nil, fmt.Errorf("flash: %s: invalid duration %q: %w", keyName, flash.Duration, err)
+			}
+			if duration <= 0 {
+				return nil, fmt.Errorf("flash: %s: duration must be positive or \"hold\": %s", keyName, flash.Duration)
+			}
 		}
+
+		loaded[gKey] = BacklightFlash{Colour: colour, Duration: duration}
+	}
+	return loaded, nil
+}
+
+func loadGestures(cfg *fileGestureConfig) (map[gesture.Gesture]int, gesture.Thresholds, error) {
+	thresholds := gesture.DefaultThresholds()
+	if cfg.Edge != nil {
+		thresholds.Edge = *cfg.Edge
+	}
+	if cfg.Rest != nil {
+		thresholds.Rest = *cfg.Rest
+	}
+	if cfg.FlickTime != "" {
+		dt, err := time.ParseDuration(cfg.FlickTime)
 		if err != nil {
-			return nil, fmt.Errorf("%s: %w", errPrefix, err)
+			return nil, thresholds, fmt.Errorf("gestures: invalid flick_time %q: %w", cfg.FlickTime, err)
+		}
+		thresholds.FlickTime = dt
+	}
+	if cfg.CircleTime != "" {
+		dt, err := time.ParseDuration(cfg.CircleTime)
+		if err != nil {
+			return nil, thresholds, fmt.Errorf("gestures: invalid circle_time %q: %w", cfg.CircleTime, err)
 		}
+		thresholds.CircleTime = dt
+	}
+	if err := thresholds.Validate(); err != nil {
+		return nil, thresholds, fmt.Errorf("gestures: %w", err)
 	}
 
-	return &G13Config{
-		mapping: Mapping{
-			keyMap: km,
-			stick:  stickConfig,
-		},
-		backlight: backlight,
-		lcdImage:  imageFile,
+	bindings := make(map[gesture.Gesture]int, len(cfg.Bindings))
+	for gestureName, kbKeyName := range cfg.Bindings {
+		g, err := gesture.Parse(gestureName)
+		if err != nil {
+			return nil, thresholds, fmt.Errorf("gestures: %w", err)
+		}
+		kbKey := keyboard.KeyCode(kbKeyName)
+		if kbKey == 0 {
+			return nil, thresholds, fmt.Errorf("gestures: unknown keyboard key name: %s", kbKeyName)
+		}
+		bindings[g] = kbKey
+	}
+	return bindings, thresholds, nil
+}
+
+func loadMQTT(cfg *mqttFileConfig, cfgPath string) (*mqtt.Options, error) {
+	if cfg.Broker == "" {
+		return nil, fmt.Errorf("mqtt: broker is required")
+	}
+
+	var password string
+	if cfg.PasswordFile != "" {
+		passwordFile, err := resolvePath(cfg.PasswordFile, cfgPath)
+		if err != nil {
+			return nil, fmt.Errorf("mqtt: password_file: %w", err)
+		}
+		data, err := os.ReadFile(passwordFile)
+		if err != nil {
+			return nil, fmt.Errorf("mqtt: failed reading password file: %w", err)
+		}
+		password = strings.TrimSpace(string(data))
+	}
+
+	return &mqtt.Options{
+		Broker:      cfg.Broker,
+		ClientID:    cfg.ClientID,
+		Username:    cfg.Username,
+		Password:    password,
+		TopicPrefix: cfg.TopicPrefix,
+	}, nil
+}
+
+// resolvePath expands environment variables and a leading ~ in a path set in
+// the config file at cfgPath. Relative paths are resolved against the
+// directory of the config file, not the working directory.
+func resolvePath(path, cfgPath string) (string, error) {
+	var undefined []string
+	path = os.Expand(path, func(name string) string {
+		value, ok := os.LookupEnv(name)
+		if !ok {
+			undefined = append(undefined, name)
+		}
+		return value
+	})
+	if len(undefined) > 0 {
+		return "", fmt.Errorf("undefined environment variable(s) in path: %s", strings.Join(undefined, ", "))
+	}
+
+	if path == "~" || strings.HasPrefix(path, "~/") {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return "", fmt.Errorf("failed to expand ~ in path: %w", err)
+		}
+		path = filepath.Join(home, path[1:])
+	}
+
+	if !filepath.IsAbs(path) {
+		cfgDir, err := filepath.Abs(filepath.Dir(cfgPath))
+		if err != nil {
+			return "", fmt.Errorf("failed to get absolute path of config file %q: %w", cfgPath, err)
+		}
+		path = filepath.Join(cfgDir, path)
+	}
+	return filepath.Clean(path), nil
+}
+
+// countersRenderInterval is how often the notification counters are redrawn.
+const countersRenderInterval = time.Second
+
+// textFileRenderInterval is how often the text file is checked for changes
+// and redrawn.
+const textFileRenderInterval = 250 * time.Millisecond
+
+// defaultHTTPPageInterval is the update interval for the http_page when none
+// is set.
+const defaultHTTPPageInterval = time.Second
+
+func loadHTTPPage(page *httpPageFileConfig) (*httpPageCfg, error) {
+	if page.URL == "" {
+		return nil, fmt.Errorf("http_page: url is required")
+	}
+
+	interval := defaultHTTPPageInterval
+	if page.Interval != "" {
+		var err error
+		interval, err = time.ParseDuration(page.Interval)
+		if err != nil {
+			return nil, fmt.Errorf("http_page: invalid interval %q: %w", page.Interval, err)
+		}
+		if interval <= 0 {
+			return nil, fmt.Errorf("http_page: interval must be positive: %s", page.Interval)
+		}
+	}
+
+	// validate the template early
+	if _, err := applet.NewHTTPJSON(page.URL, page.Template, interval, lcd.Font3x5); err != nil {
+		return nil, fmt.Errorf("http_page: %w", err)
+	}
+
+	return &httpPageCfg{
+		url:      page.URL,
+		interval: interval,
+		template: page.Template,
+	}, nil
+}
+
+func loadInput(input inputFileConfig) (inputCfg, error) {
+	parsePositive := func(name, value string) (time.Duration, error) {
+		if value == "" {
+			return 0, nil
+		}
+		dt, err := time.ParseDuration(value)
+		if err != nil {
+			return 0, fmt.Errorf("input: invalid %s %q: %w", name, value, err)
+		}
+		if dt <= 0 {
+			return 0, fmt.Errorf("input: %s must be positive: %s", name, value)
+		}
+		return dt, nil
+	}
+
+	readTimeout, err := parsePositive("read_timeout", input.ReadTimeout)
+	if err != nil {
+		return inputCfg{}, err
+	}
+	retryDelay, err := parsePositive("retry_delay", input.RetryDelay)
+	if err != nil {
+		return inputCfg{}, err
+	}
+	retryMaxDelay, err := parsePositive("retry_max_delay", input.RetryMaxDelay)
+	if err != nil {
+		return inputCfg{}, err
+	}
+	if retryMaxDelay != 0 && retryMaxDelay < retryDelay {
+		return inputCfg{}, fmt.Errorf("input: retry_max_delay %s is shorter than retry_delay %s", input.RetryMaxDelay, input.RetryDelay)
+	}
+	reconnectDelay, err := parsePositive("reconnect_delay", input.ReconnectDelay)
+	if err != nil {
+		return inputCfg{}, err
+	}
+	reconnectMaxDelay, err := parsePositive("reconnect_max_delay", input.ReconnectMaxDelay)
+	if err != nil {
+		return inputCfg{}, err
+	}
+	if reconnectMaxDelay != 0 && reconnectMaxDelay < reconnectDelay {
+		return inputCfg{}, fmt.Errorf("input: reconnect_max_delay %s is shorter than reconnect_delay %s", input.ReconnectMaxDelay, input.ReconnectDelay)
+	}
+	if input.ErrorThreshold < 0 {
+		return inputCfg{}, fmt.Errorf("input: error_threshold must be positive: %d", input.ErrorThreshold)
+	}
+
+	return inputCfg{
+		readTimeout:       readTimeout,
+		errorThreshold:    input.ErrorThreshold,
+		retryDelay:        retryDelay,
+		retryMaxDelay:     retryMaxDelay,
+		reconnectDelay:    reconnectDelay,
+		reconnectMaxDelay: reconnectMaxDelay,
 	}, nil
 }
@@ -5,8 +5,11 @@ import (
 	"os"
 	"path/filepath"
 	"testing"
+	"time"
 
 	"github.com/achilleas-k/gg13/internal/device"
+	"github.com/achilleas-k/gg13/internal/gesture"
+	"github.com/achilleas-k/gg13/internal/mqtt"
 	"github.com/bendahl/uinput"
 	"github.com/stretchr/testify/assert"
 )
@@ -184,6 +187,155 @@ func TestLoadConfig(t *testing.T) {
 				lcdImage:  "here.bmp",
 			},
 		},
+		"cheatsheet": {
+			configData: `{"mapping":{"keys":{"G1":"KeyEsc"}},"cheatsheet":true}`,
+			expectedConfig: G13Config{
+				mapping: Mapping{
+					keyMap: map[device.KeyBit]int{
+						device.G1: uinput.KeyEsc,
+					},
+				},
+				lcdCheatSheet: true,
+			},
+		},
+		"http-page": {
+			configData: `{"http_page":{"url":"http://localhost:8888/data.json","template":"SPEED {{.speed}}"}}`,
+			expectedConfig: G13Config{
+				mapping: Mapping{
+					keyMap: map[device.KeyBit]int{},
+				},
+				httpPage: &httpPageCfg{
+					url:      "http://localhost:8888/data.json",
+					interval: time.Second,
+					template: "SPEED {{.speed}}",
+				},
+			},
+		},
+		"backlight-keepalive": {
+			configData: `{"backlight":{"red":10,"green":20,"blue":30,"keepalive":"30s"}}`,
+			expectedConfig: G13Config{
+				mapping: Mapping{
+					keyMap: map[device.KeyBit]int{},
+				},
+				backlight:          [3]uint8{10, 20, 30},
+				backlightKeepalive: 30 * time.Second,
+			},
+		},
+		"backlight-colour-hex": {
+			configData: `{"backlight":{"colour":"#ff8800","keepalive":"1s"}}`,
+			expectedConfig: G13Config{
+				mapping: Mapping{
+					keyMap: map[device.KeyBit]int{},
+				},
+				backlight:          [3]uint8{255, 136, 0},
+				backlightKeepalive: time.Second,
+			},
+		},
+		"backlight-flash": {
+			configData: `{"backlight":{"colour":"blue","flash":{"G5":{},"MR":{"colour":"red","duration":"hold"},"M1":{"colour":"#0f0","duration":"1s"}}}}`,
+			expectedConfig: G13Config{
+				mapping: Mapping{
+					keyMap: map[device.KeyBit]int{},
+				},
+				backlight: [3]uint8{0, 0, 255},
+				backlightFlashes: map[device.KeyBit]BacklightFlash{
+					device.G5: {Colour: DefaultFlashColour, Duration: DefaultFlashDuration},
+					device.MR: {Colour: [3]uint8{255, 0, 0}},
+					device.M1: {Colour: [3]uint8{0, 255, 0}, Duration: time.Second},
+				},
+			},
+		},
+		"backlight-colour-shorthand": {
+			configData: `{"backlight":"Teal"}`,
+			expectedConfig: G13Config{
+				mapping: Mapping{
+					keyMap: map[device.KeyBit]int{},
+				},
+				backlight: [3]uint8{0, 128, 128},
+			},
+		},
+		"backlight-keepalive-off": {
+			configData: `{"backlight":{"keepalive":"off"}}`,
+			expectedConfig: G13Config{
+				mapping: Mapping{
+					keyMap: map[device.KeyBit]int{},
+				},
+			},
+		},
+		"stick-calibration": {
+			configData: `{"mapping":{"stick":{"mode":"joystick","calibration":{"centre_x":120}}}}`,
+			expectedConfig: G13Config{
+				mapping: Mapping{
+					keyMap: map[device.KeyBit]int{},
+					stick: stickCfg{
+						mode:        StickModeJoystick,
+						calibration: &StickCalibration{CentreX: 120, CentreY: DefaultStickCentre},
+					},
+				},
+			},
+		},
+		"actions": {
+			configData: `{"mapping":{"keys":{"G1":"KeyA"},"actions":{"M1":"toggle_backlight","M3":"pause","MR":"reload_config"}}}`,
+			expectedConfig: G13Config{
+				mapping: Mapping{
+					keyMap: map[device.KeyBit]int{
+						device.G1: uinput.KeyA,
+					},
+					actions: map[device.KeyBit]Action{
+						device.M1: ActionToggleBacklight,
+						device.M3: ActionPause,
+						device.MR: ActionReloadConfig,
+					},
+				},
+			},
+		},
+		"stick-gestures": {
+			configData: `{"mapping":{"stick":{"gestures":{"bindings":{"flick_up":"KeySpace","circle":"KeyR"},"edge":0.9,"flick_time":"200ms"}}}}`,
+			expectedConfig: G13Config{
+				mapping: Mapping{
+					keyMap: map[device.KeyBit]int{},
+					stick: stickCfg{
+						gestures: map[gesture.Gesture]int{
+							gesture.FlickUp: uinput.KeySpace,
+							gesture.Circle:  uinput.KeyR,
+						},
+						gestureThresholds: gesture.Thresholds{
+							Edge:       0.9,
+							Rest:       gesture.DefaultRest,
+							FlickTime:  200 * time.Millisecond,
+							CircleTime: gesture.DefaultCircleTime,
+						},
+					},
+				},
+			},
+		},
+		"input": {
+			configData: `{"input":{"read_timeout":"250ms","error_threshold":5,"retry_delay":"2s"}}`,
+			expectedConfig: G13Config{
+				mapping: Mapping{
+					keyMap: map[device.KeyBit]int{},
+				},
+				input: inputCfg{
+					readTimeout:    250 * time.Millisecond,
+					errorThreshold: 5,
+					retryDelay:     2 * time.Second,
+				},
+			},
+		},
+		"mqtt": {
+			configData: `{"mqtt":{"broker":"tcp://localhost:1883","username":"g13","password_file":"mqtt-password","topic_prefix":"home/g13"}}`,
+			expectedConfig: G13Config{
+				mapping: Mapping{
+					keyMap: map[device.KeyBit]int{},
+				},
+				mqtt: &mqtt.Options{
+					Broker:      "tcp://localhost:1883",
+					Username:    "g13",
+					Password:    "hunter2",
+					TopicPrefix: "home/g13",
+				},
+			},
+		},
 		"stick-keys-ignored": { // stick keys are ignored when the mode is not "keys"
 			configData: `{"mapping":{"stick":{"mode":"","keys":{"Up":"not-a-key-but-ignored"}}}}`,
 			expectedConfig: G13Config{
@@ -193,6 +345,7 @@ func TestLoadConfig(t *testing.T) {
 						mode: StickModeOff,
 					},
 				},
+				warnings: []string{"mapping: stick: keys are only used in the keys mode, not the off mode"},
 			},
 		},
 	}
@@ -209,6 +362,7 @@ func TestLoadConfig(t *testing.T) {
 			assert.NoError(err)
 
 			expectedConfig := tc.expectedConfig
+			expectedConfig.mapping.indexOutputs()
 
 			if expectedConfig.lcdImage != "" && !filepath.IsAbs(expectedConfig.lcdImage) {
 				// adjust the image file path to the tmpdir
@@ -222,6 +376,10 @@ func TestLoadConfig(t *testing.T) {
 				expectedConfig.lcdImage = imgPath
 			}
 
+			// password file for the mqtt test case
+			err = os.WriteFile(filepath.Join(tmpdir, "mqtt-password"), []byte("hunter2\n"), 0o600)
+			assert.NoError(err)
+
 			cfg, err := loadConfig(cfgPath)
 			assert.NoError(err)
 
@@ -229,3 +387,54 @@ func TestLoadConfig(t *testing.T) {
 		})
 	}
 }
+
+func TestResolvePath(t *testing.T) {
+	home, err := os.UserHomeDir()
+	assert.NoError(t, err)
+	t.Setenv("GG13_TEST_IMAGES", "/srv/images")
+
+	type testCase struct {
+		path     string
+		expected string
+	}
+
+	testCases := map[string]testCase{
+		"absolute": {
+			path:     "/usr/share/gg13/image.bmp",
+			expected: "/usr/share/gg13/image.bmp",
+		},
+		"relative": {
+			path:     "../images/image.bmp",
+			expected: "/etc/images/image.bmp",
+		},
+		"home": {
+			path:     "~/image.bmp",
+			expected: filepath.Join(home, "image.bmp"),
+		},
+		"variable": {
+			path:     "$GG13_TEST_IMAGES/image.bmp",
+			expected: "/srv/images/image.bmp",
+		},
+		"braced-variable": {
+			path:     "${GG13_TEST_IMAGES}/image.bmp",
+			expected: "/srv/images/image.bmp",
+		},
+		"not-home": {
+			path:     "~user/image.bmp",
+			expected: "/etc/gg13/~user/image.bmp",
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			path, err := resolvePath(tc.path, "/etc/gg13/config.json")
+			assert.NoError(t, err)
+			assert.Equal(t, tc.expected, path)
+		})
+	}
+
+	t.Run("undefined-variable", func(t *testing.T) {
+		_, err := resolvePath("$GG13_TEST_UNDEFINED/image.bmp", "/etc/gg13/config.json")
+		assert.EqualError(t, err, "undefined environment variable(s) in path: GG13_TEST_UNDEFINED")
+	})
+}
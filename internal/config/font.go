@@ -0,0 +1,60 @@
+package config
+
+import (
+	"fmt"
+
+	"github.com/achilleas-k/gg13/internal/lcd"
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/opentype"
+)
+
+// DefaultLCDFontSize is the size of the LCD font, in pixels, when none is set.
+const DefaultLCDFontSize = 10
+
+type lcdFontFileConfig struct {
+	Path string  `json:"path"`
+	Size float64 `json:"size"`
+}
+
+// lcdFontCfg is the font used for LCD text pages.
+type lcdFontCfg struct {
+	font *opentype.Font
+	size float64
+}
+
+func loadLCDFont(fc *lcdFontFileConfig, cfgPath string) (*lcdFontCfg, error) {
+	if fc.Path == "" {
+		return nil, fmt.Errorf("lcd_font: path is required")
+	}
+	path, err := resolvePath(fc.Path, cfgPath)
+	if err != nil {
+		return nil, fmt.Errorf("lcd_font: %w", err)
+	}
+
+	size := fc.Size
+	if size == 0 {
+		size = DefaultLCDFontSize
+	}
+	if size < 0 {
+		return nil, fmt.Errorf("lcd_font: size must be positive: %g", fc.Size)
+	}
+
+	f, err := lcd.LoadFont(path)
+	if err != nil {
+		return nil, fmt.Errorf("lcd_font: %w", err)
+	}
+	// check the size works with the font
+	if _, err := lcd.NewFace(f, size); err != nil {
+		return nil, fmt.Errorf("lcd_font: %w", err)
+	}
+	return &lcdFontCfg{font: f, size: size}, nil
+}
+
+// GetLCDFace returns a new face of the font configured for LCD text pages,
+// or [lcd.Font3x5] if none is configured.
+func (cfg *G13Config) GetLCDFace() (font.Face, error) {
+	if cfg.lcdFont == nil {
+		return lcd.Font3x5, nil
+	}
+	return lcd.NewFace(cfg.lcdFont.font, cfg.lcdFont.size)
+}
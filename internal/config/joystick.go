@@ -1,8 +1,109 @@
 package config
 
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+const (
+	// DefaultStickCentre is the raw value of each stick axis at rest.
+	DefaultStickCentre = 127
+
+	// StickCalibrationTime is how long the stick is sampled for when its
+	// centre is measured.
+	StickCalibrationTime = 500 * time.Millisecond
+)
+
+// StickCalibration maps raw stick positions, 0 to 255 on each axis, to the
+// [-1, 1] range. Each half of an axis is scaled separately, so the centre
+// maps to 0 and the extremes map to exactly -1 and 1 regardless of where the
+// centre is.
+type StickCalibration struct {
+	CentreX uint8 `json:"centre_x"`
+	CentreY uint8 `json:"centre_y"`
+}
+
+// DefaultStickCalibration returns the calibration for a stick centred at
+// [DefaultStickCentre] on both axes.
+func DefaultStickCalibration() StickCalibration {
+	return StickCalibration{
+		CentreX: DefaultStickCentre,
+		CentreY: DefaultStickCentre,
+	}
+}
+
+// DefaultStickCalibrationPath returns the default location of the file
+// storing the measured stick calibration, under $XDG_STATE_HOME if it is set.
+func DefaultStickCalibrationPath() string {
+	stateDir := os.Getenv("XDG_STATE_HOME")
+	if stateDir == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return filepath.Join(os.TempDir(), fmt.Sprintf("gg13-%d-calibration.json", os.Getuid()))
+		}
+		stateDir = filepath.Join(home, ".local", "state")
+	}
+	return filepath.Join(stateDir, "gg13", "calibration.json")
+}
+
+// LoadStickCalibration reads the measured stick calibration from the file at
+// path. It returns nil if the file doesn't exist.
+func LoadStickCalibration(path string) (*StickCalibration, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed reading stick calibration file %q: %w", path, err)
+	}
+	var c StickCalibration
+	if err := json.Unmarshal(data, &c); err != nil {
+		return nil, fmt.Errorf("failed decoding stick calibration file %q: %w", path, err)
+	}
+	return &c, nil
+}
+
+// Save writes the calibration to the file at path, creating its directory if
+// needed.
+func (c StickCalibration) Save(path string) error {
+	data, err := json.Marshal(c)
+	if err != nil {
+		return fmt.Errorf("failed encoding stick calibration: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return fmt.Errorf("failed creating stick calibration directory: %w", err)
+	}
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		return fmt.Errorf("failed writing stick calibration file: %w", err)
+	}
+	return nil
+}
+
+// Normalise returns the x, y position mapped to the [-1, 1] range.
+func (c StickCalibration) Normalise(x, y uint8) (float32, float32) {
+	return normaliseAxis(x, c.CentreX), normaliseAxis(y, c.CentreY)
+}
+
+func normaliseAxis(raw, centre uint8) float32 {
+	if raw >= centre {
+		if centre == 255 {
+			return 0
+		}
+		return float32(raw-centre) / float32(255-centre)
+	}
+	return -float32(centre-raw) / float32(centre)
+}
+
 type StickPosition struct {
 	posX uint8
 	posY uint8
+
+	calibration StickCalibration
 }
 
 func (sp *StickPosition) Position() (uint8, uint8) {
@@ -18,13 +119,13 @@ func (sp *StickPosition) Y() uint8 {
 }
 
 func (sp *StickPosition) UinputPosition() (float32, float32) {
-	return sp.UinputX(), sp.UinputY()
+	return sp.calibration.Normalise(sp.posX, sp.posY)
 }
 
 func (sp *StickPosition) UinputX() float32 {
-	return (float32(sp.posX) - float32(127)) / float32(127)
+	return normaliseAxis(sp.posX, sp.calibration.CentreX)
 }
 
 func (sp *StickPosition) UinputY() float32 {
-	return (float32(sp.posY) - float32(127)) / float32(127)
+	return normaliseAxis(sp.posY, sp.calibration.CentreY)
 }
@@ -0,0 +1,76 @@
+package config_test
+
+import (
+	"testing"
+
+	"github.com/achilleas-k/gg13/internal/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStickCalibrationNormalise(t *testing.T) {
+	type testCase struct {
+		calibration config.StickCalibration
+		x, y        uint8
+		expX, expY  float32
+	}
+
+	testCases := map[string]testCase{
+		"default-centre": {
+			calibration: config.DefaultStickCalibration(),
+			x:           127,
+			y:           127,
+			expX:        0,
+			expY:        0,
+		},
+		"default-max": {
+			calibration: config.DefaultStickCalibration(),
+			x:           255,
+			y:           255,
+			expX:        1,
+			expY:        1,
+		},
+		"default-min": {
+			calibration: config.DefaultStickCalibration(),
+			x:           0,
+			y:           0,
+			expX:        -1,
+			expY:        -1,
+		},
+		"offset-centre": {
+			calibration: config.StickCalibration{CentreX: 100, CentreY: 155},
+			x:           100,
+			y:           155,
+			expX:        0,
+			expY:        0,
+		},
+		"offset-halfway": {
+			calibration: config.StickCalibration{CentreX: 100, CentreY: 155},
+			x:           50,
+			y:           205,
+			expX:        -0.5,
+			expY:        0.5,
+		},
+		"offset-extremes": {
+			calibration: config.StickCalibration{CentreX: 100, CentreY: 155},
+			x:           255,
+			y:           0,
+			expX:        1,
+			expY:        -1,
+		},
+		"centre-at-edge": {
+			calibration: config.StickCalibration{CentreX: 0, CentreY: 255},
+			x:           0,
+			y:           255,
+			expX:        0,
+			expY:        0,
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			x, y := tc.calibration.Normalise(tc.x, tc.y)
+			assert.Equal(t, tc.expX, x)
+			assert.Equal(t, tc.expY, y)
+		})
+	}
+}
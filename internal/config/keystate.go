@@ -0,0 +1,198 @@
+package config
+
+import (
+	"slices"
+
+	"github.com/achilleas-k/gg13/internal/device"
+)
+
+// stickKeysActiveZone is how close to the edge, out of the 0-255 range of
+// each axis, the stick needs to be pushed to press the key of a direction in
+// stick keys mode.
+const stickKeysActiveZone = 64 // TODO: make this configurable
+
+// stickDirection is a set of directions the stick is pushed in.
+type stickDirection uint8
+
+const (
+	stickUp stickDirection = 1 << iota
+	stickDown
+	stickLeft
+	stickRight
+
+	// the same directions pushed past the run threshold, for the run keys
+	stickUpRun
+	stickDownRun
+	stickLeftRun
+	stickRightRun
+)
+
+// stickRunShift turns a direction into its run direction.
+const stickRunShift = 4
+
+// stickDirections returns the directions the stick is pushed in beyond the
+// active zone.
+func stickDirections(input uint64) stickDirection {
+	var dirs stickDirection
+	x, y := device.StickPosition(input)
+	if y <= stickKeysActiveZone {
+		dirs |= stickUp
+	}
+	if y >= 255-stickKeysActiveZone {
+		dirs |= stickDown
+	}
+	if x <= stickKeysActiveZone {
+		dirs |= stickLeft
+	}
+	if x >= 255-stickKeysActiveZone {
+		dirs |= stickRight
+	}
+	return dirs
+}
+
+// keyOutput is a mapped keyboard key and the inputs that press it.
+type keyOutput struct {
+	kbkey int
+
+	// G13 keys bound to the keyboard key
+	gkeys uint64
+
+	// stick directions bound to the keyboard key, in stick keys mode
+	stick stickDirection
+
+	// the key is held while the stick is pushed, in modifier mode
+	stickPushed bool
+}
+
+// indexOutputs rebuilds the list of keyboard keys from the key map and the
+// stick config. It must be called whenever either of them changes. The list
+// is what [G13Config.EachKeyState] goes through for every input report, so
+// it's built once here, merging the inputs bound to the same keyboard key,
+// instead of on every report.
+func (m *Mapping) indexOutputs() {
+	outputs := make(map[int]*keyOutput, len(m.keyMap)+4)
+	output := func(kbkey int) *keyOutput {
+		out, ok := outputs[kbkey]
+		if !ok {
+			out = &keyOutput{kbkey: kbkey}
+			outputs[kbkey] = out
+		}
+		return out
+	}
+
+	for gkey, kbkey := range m.keyMap {
+		output(kbkey).gkeys |= gkey.Uint64()
+	}
+	if m.stick.mode == StickModeKeys {
+		for dir, kbkey := range map[stickDirection]int{
+			stickUp:    m.stick.keys.Up,
+			stickDown:  m.stick.keys.Down,
+			stickLeft:  m.stick.keys.Left,
+			stickRight: m.stick.keys.Right,
+		} {
+			if kbkey != 0 {
+				output(kbkey).stick |= dir
+			}
+		}
+		for dir, kbkey := range map[stickDirection]int{
+			stickUpRun:    m.stick.runKeys.Up,
+			stickDownRun:  m.stick.runKeys.Down,
+			stickLeftRun:  m.stick.runKeys.Left,
+			stickRightRun: m.stick.runKeys.Right,
+		} {
+			if kbkey != 0 {
+				output(kbkey).stick |= dir
+			}
+		}
+	}
+	if m.stick.mode == StickModeModifier {
+		output(m.stick.modifier).stickPushed = true
+	}
+
+	m.outputs = make([]keyOutput, 0, len(outputs))
+	for _, out := range outputs {
+		m.outputs = append(m.outputs, *out)
+	}
+	slices.SortFunc(m.outputs, func(a, b keyOutput) int { return a.kbkey - b.kbkey })
+}
+
+// EachKeyState calls f with the state of each mapped keyboard key for the
+// given input (from [device.ReadInput]), true for down (pressed) and false
+// for up (released). A keyboard key bound to more than one input is down if
+// any of them is. Unlike [G13Config.GetKeyStates], it doesn't allocate, so
+// it's the one to use for every input report. With run keys in stick keys
+// mode, it keeps the state of the run threshold between calls, so it must
+// be called from one goroutine.
+func (cfg *G13Config) EachKeyState(input uint64, f func(kbkey int, isDown bool)) {
+	var dirs stickDirection
+	var pushed bool
+	switch cfg.mapping.stick.mode {
+	case StickModeKeys:
+		dirs = stickDirections(input)
+		if cfg.mapping.stick.runKeys != (StickKeys{}) {
+			dirs = cfg.stickRunning(input, dirs)
+		}
+	case StickModeModifier:
+		pushed = cfg.stickPushed(input)
+	}
+	for _, out := range cfg.mapping.outputs {
+		f(out.kbkey, input&out.gkeys != 0 || dirs&out.stick != 0 || pushed && out.stickPushed)
+	}
+}
+
+// ReleaseKeys calls f with each mapped keyboard key, for releasing them when
+// the mapping stops being used, and forgets the stick directions running in
+// keys mode, so that they start walking again when it's used next. Like
+// [G13Config.EachKeyState], it must be called from the goroutine reading the
+// input.
+func (cfg *G13Config) ReleaseKeys(f func(kbkey int)) {
+	cfg.mapping.running = 0
+	for _, out := range cfg.mapping.outputs {
+		f(out.kbkey)
+	}
+}
+
+// stickPushed returns true if the stick is pushed past the threshold of the
+// modifier mode, in any direction.
+func (cfg *G13Config) stickPushed(input uint64) bool {
+	x, y := cfg.GetStickCalibration().Normalise(device.StickPosition(input))
+	threshold := cfg.mapping.stick.modifierThreshold
+	return x*x+y*y >= threshold*threshold
+}
+
+// stickRunning updates the directions the stick is pushed past the run
+// threshold in and returns dirs with the run keys that are bound replacing
+// the walk keys of the same directions. A direction keeps running until the
+// stick returns below the threshold by [stickRunHysteresis], so the state is
+// kept in the mapping between input reports.
+func (cfg *G13Config) stickRunning(input uint64, dirs stickDirection) stickDirection {
+	x, y := cfg.GetStickCalibration().Normalise(device.StickPosition(input))
+	threshold := cfg.mapping.stick.runThreshold
+	for _, d := range []struct {
+		dir        stickDirection
+		deflection float32
+		bound      bool
+	}{
+		{stickUp, -y, cfg.mapping.stick.runKeys.Up != 0},
+		{stickDown, y, cfg.mapping.stick.runKeys.Down != 0},
+		{stickLeft, -x, cfg.mapping.stick.runKeys.Left != 0},
+		{stickRight, x, cfg.mapping.stick.runKeys.Right != 0},
+	} {
+		running := cfg.mapping.running&d.dir != 0
+		switch {
+		case d.deflection >= threshold:
+			running = true
+		case d.deflection < threshold-stickRunHysteresis:
+			running = false
+		}
+		if running {
+			cfg.mapping.running |= d.dir
+		} else {
+			cfg.mapping.running &^= d.dir
+		}
+		if running && d.bound {
+			dirs = dirs&^d.dir | d.dir<<stickRunShift
+		}
+	}
+	return dirs
+}
@@ -0,0 +1,148 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"slices"
+
+	"github.com/achilleas-k/gg13/internal/device"
+)
+
+// duplicateKeys returns a warning for each key that's set more than once in
+// an object of the JSON data, which keeps only the last value. The search
+// stops at invalid data, which decoding the config reports.
+func duplicateKeys(data []byte) []string {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	var warnings []string
+	var walk func(path string) error
+	walk = func(path string) error {
+		token, err := decoder.Token()
+		if err != nil {
+			return err
+		}
+		switch token {
+		case json.Delim('{'):
+			seen := map[string]bool{}
+			for decoder.More() {
+				token, err := decoder.Token()
+				if err != nil {
+					return err
+				}
+				key, _ := token.(string)
+				if seen[key] {
+					warnings = append(warnings, fmt.Sprintf("%s%s is set more than once: only the last one is used", path, key))
+				}
+				seen[key] = true
+				if err := walk(path + key + ": "); err != nil {
+					return err
+				}
+			}
+		case json.Delim('['):
+			for decoder.More() {
+				if err := walk(path); err != nil {
+					return err
+				}
+			}
+		default:
+			return nil
+		}
+		// the closing delimiter
+		_, err = decoder.Token()
+		return err
+	}
+	_ = walk("")
+	return warnings
+}
+
+// lintMapping returns the warnings for bindings of the mapping that can't
+// work, with the prefix, given the mapping in the config file.
+func lintMapping(prefix string, m Mapping, fm fileMapping) []string {
+	var warnings []string
+	for _, gKey := range device.AllKeys() {
+		if m.disabled&gKey.Uint64() != 0 && m.chordKeys&gKey.Uint64() == 0 && m.binds(gKey) {
+			warnings = append(warnings, fmt.Sprintf("%s%s is bound but disabled", prefix, gKey))
+		}
+	}
+	for chord := range m.chords {
+		if chord&m.disabled != 0 {
+			warnings = append(warnings, fmt.Sprintf("%schords: %s can't be typed: %s is disabled", prefix, ChordName(chord), ChordName(chord&m.disabled)))
+		}
+	}
+	if m.stick.mode != StickModeKeys && fm.Stick.Keys != (fileStickMapping{}) {
+		warnings = append(warnings, fmt.Sprintf("%sstick: keys are only used in the keys mode, not the %s mode", prefix, m.stick.mode))
+	}
+	return warnings
+}
+
+// lint returns the warnings for the parts of the config that load but can't
+// work the way they look like they would: bindings of disabled keys,
+// profiles and pages that can't be switched to with the G13, an unlock
+// chord that can't be held, and macros that no key plays. fc is the config file the config was loaded from.
+func (cfg *G13Config) lint(fc *fileConfig) []string {
+	warnings := lintMapping("mapping: ", cfg.mapping, fc.Mapping)
+	for _, profile := range cfg.GetProfiles() {
+		prefix := "profiles: " + profile.Name + ": "
+		mapping := cfg.profiles[profile.Name].config.mapping
+		warnings = append(warnings, lintMapping(prefix, mapping, fc.Profiles[profile.Name].Mapping)...)
+
+		switch {
+		case profile.Key == 0 && (cfg.pages == nil || !slices.Contains(cfg.pages.Names, profile.Name)):
+			warnings = append(warnings, prefix+"has no key and isn't a page: only 'gg13 ctl profile' can switch to it")
+		case profile.Key == 0:
+		case cfg.mapping.disabled&profile.Key.Uint64() != 0:
+			warnings = append(warnings, fmt.Sprintf("%s%s switches to it but the main mapping disables it", prefix, profile.Key))
+		case !profile.Momentary && mapping.disabled&profile.Key.Uint64() != 0:
+			warnings = append(warnings, fmt.Sprintf("%sdisables %s, which switches back from it", prefix, profile.Key))
+		}
+	}
+
+	if cfg.pages != nil {
+		for _, name := range cfg.pages.Names {
+			mapping := cfg.mapping
+			if profile, ok := cfg.profiles[name]; ok {
+				mapping = profile.config.mapping
+			}
+			for _, key := range []device.KeyBit{cfg.pages.Previous, cfg.pages.Next} {
+				if mapping.disabled&key.Uint64() != 0 {
+					warnings = append(warnings, fmt.Sprintf("pages: %s disables %s, which switches pages", name, key))
+				}
+			}
+		}
+	}
+
+	// the chord is held with the bindings that were active when the keys
+	// were locked
+	var unlock uint64
+	for _, key := range cfg.GetUnlockChord() {
+		unlock |= key.Uint64()
+	}
+	mappings := map[string]Mapping{"mapping": cfg.mapping}
+	for name, profile := range cfg.profiles {
+		mappings["profiles: "+name] = profile.config.mapping
+	}
+	for where, mapping := range mappings {
+		locks := false
+		for _, action := range mapping.actions {
+			locks = locks || action == ActionLock
+		}
+		if locks && mapping.disabled&unlock != 0 {
+			warnings = append(warnings, fmt.Sprintf("%s: %s locks the keys, but the unlock chord can't be held: %s is disabled", where, ActionLock, ChordName(mapping.disabled&unlock)))
+		}
+	}
+
+	// macros are only played by the keys bound to them
+	for _, name := range cfg.unusedMacros() {
+		warnings = append(warnings, fmt.Sprintf("macros: %s isn't bound to any key", name))
+	}
+
+	slices.Sort(warnings)
+	return warnings
+}
+
+// GetWarnings returns the problems found in the config file that don't stop
+// it from loading, like duplicate keys, bindings of disabled keys, and
+// profiles that can't be switched to with the G13.
+func (cfg *G13Config) GetWarnings() []string {
+	return cfg.warnings
+}
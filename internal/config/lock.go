@@ -0,0 +1,46 @@
+package config
+
+import (
+	"fmt"
+	"slices"
+
+	"github.com/achilleas-k/gg13/internal/device"
+)
+
+// DefaultUnlockChord is the chord that unlocks the keys after [ActionLock]
+// when lock sets none.
+var DefaultUnlockChord = []device.KeyBit{device.M1, device.M3, device.MR}
+
+type lockFileConfig struct {
+	Unlock []string `json:"unlock"`
+}
+
+func loadLock(lc *lockFileConfig) ([]device.KeyBit, error) {
+	if lc == nil || len(lc.Unlock) == 0 {
+		return nil, nil
+	}
+	if len(lc.Unlock) < 2 {
+		return nil, fmt.Errorf("lock: unlock needs at least two keys")
+	}
+	chord := make([]device.KeyBit, 0, len(lc.Unlock))
+	for _, keyName := range lc.Unlock {
+		gKey := device.KeyCode(keyName)
+		if gKey == 0 {
+			return nil, fmt.Errorf("lock: unlock: unknown G13 key name: %s", keyName)
+		}
+		if slices.Contains(chord, gKey) {
+			return nil, fmt.Errorf("lock: unlock: %s is listed twice", keyName)
+		}
+		chord = append(chord, gKey)
+	}
+	return chord, nil
+}
+
+// GetUnlockChord returns the G13 keys that have to be held together to unlock
+// the keys after [ActionLock].
+func (cfg *G13Config) GetUnlockChord() []device.KeyBit {
+	if len(cfg.unlockChord) == 0 {
+		return DefaultUnlockChord
+	}
+	return cfg.unlockChord
+}
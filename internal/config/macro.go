@@ -0,0 +1,277 @@
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"time"
+
+	"github.com/achilleas-k/gg13/internal/device"
+	"github.com/achilleas-k/gg13/internal/keyboard"
+)
+
+// MaxMacroDuration is the longest a macro can take to play, counting the
+// delays of all its events. The input loop waits for a macro to finish, so
+// the G13 doesn't respond while one plays.
+const MaxMacroDuration = 10 * time.Second
+
+// MacroEvent is a keyboard key pressed or released by a macro, after waiting
+// for Delay since the previous event.
+type MacroEvent struct {
+	Key   int
+	Down  bool
+	Delay time.Duration
+}
+
+type fileMacroEvent struct {
+	Key   string `json:"key"`
+	Down  bool   `json:"down"`
+	Delay string `json:"delay,omitempty"`
+}
+
+// loadMacros returns the macros described in the config file, by name.
+func loadMacros(macros map[string][]fileMacroEvent) (map[string][]MacroEvent, error) {
+	if len(macros) == 0 {
+		return nil, nil
+	}
+
+	loaded := make(map[string][]MacroEvent, len(macros))
+	for name, events := range macros {
+		if name == "" {
+			return nil, fmt.Errorf("macros: macro name can't be empty")
+		}
+		if len(events) == 0 {
+			return nil, fmt.Errorf("macros: %s: no events", name)
+		}
+		var total time.Duration
+		loadedEvents := make([]MacroEvent, 0, len(events))
+		for idx, event := range events {
+			kbKey := keyboard.KeyCode(event.Key)
+			if kbKey == 0 {
+				return nil, fmt.Errorf("macros: %s: event %d: unknown keyboard key name: %s", name, idx, event.Key)
+			}
+			var delay time.Duration
+			if event.Delay != "" {
+				var err error
+				delay, err = time.ParseDuration(event.Delay)
+				if err != nil {
+					return nil, fmt.Errorf("macros: %s: event %d: invalid delay %q: %w", name, idx, event.Delay, err)
+				}
+				if delay < 0 {
+					return nil, fmt.Errorf("macros: %s: event %d: delay can't be negative: %s", name, idx, event.Delay)
+				}
+			}
+			total += delay
+			loadedEvents = append(loadedEvents, MacroEvent{Key: kbKey, Down: event.Down, Delay: delay})
+		}
+		if total > MaxMacroDuration {
+			return nil, fmt.Errorf("macros: %s: takes %s to play, more than the maximum of %s", name, total, MaxMacroDuration)
+		}
+		loaded[name] = loadedEvents
+	}
+	return loaded, nil
+}
+
+// loadMacroBindings returns the G13 keys bound to macros, which can't also be
+// bound to keyboard keys or actions. The names of the macros are checked
+// against the config once they're loaded.
+func loadMacroBindings(bindings map[string]string, km keyMap, actions map[device.KeyBit]Action) (map[device.KeyBit]string, error) {
+	if len(bindings) == 0 {
+		return nil, nil
+	}
+
+	loaded := make(map[device.KeyBit]string, len(bindings))
+	for keyName, macro := range bindings {
+		gKey := device.KeyCode(keyName)
+		if gKey == 0 {
+			return nil, fmt.Errorf("macros: unknown G13 key name: %s", keyName)
+		}
+		if _, ok := km[gKey]; ok {
+			return nil, fmt.Errorf("macros: %s is already bound to a keyboard key", keyName)
+		}
+		if _, ok := actions[gKey]; ok {
+			return nil, fmt.Errorf("macros: %s is already bound to an action", keyName)
+		}
+		loaded[gKey] = macro
+	}
+	return loaded, nil
+}
+
+// loadRepeatMacros returns the mask of the G13 keys whose macros replay
+// while they're held. They must be bound to macros.
+func loadRepeatMacros(keys []string, macros map[device.KeyBit]string) (uint64, error) {
+	var repeat uint64
+	for _, keyName := range keys {
+		gKey := device.KeyCode(keyName)
+		if gKey == 0 {
+			return 0, fmt.Errorf("repeat_macros: unknown G13 key name: %s", keyName)
+		}
+		if _, ok := macros[gKey]; !ok {
+			return 0, fmt.Errorf("repeat_macros: %s isn't bound to a macro", keyName)
+		}
+		repeat |= gKey.Uint64()
+	}
+	return repeat, nil
+}
+
+// checkMacroBindings returns an error if a key of the mapping is bound to a
+// macro that isn't defined, or repeats a macro that takes no time to play,
+// which would flood the keyboard. The keys are checked in order, so the same
+// config always reports the same key.
+func checkMacroBindings(m Mapping, macros map[string][]MacroEvent) error {
+	for _, gKey := range device.AllKeys() {
+		macro, ok := m.macros[gKey]
+		if !ok {
+			continue
+		}
+		events, ok := macros[macro]
+		if !ok {
+			return fmt.Errorf("macros: %s: unknown macro: %s", gKey, macro)
+		}
+		if m.repeatMacros&gKey.Uint64() != 0 && macroDuration(events) == 0 {
+			return fmt.Errorf("repeat_macros: %s: macro %s takes no time to play, so it can't repeat: add a delay to it", gKey, macro)
+		}
+	}
+	return nil
+}
+
+// macroDuration returns how long the macro takes to play.
+func macroDuration(events []MacroEvent) time.Duration {
+	var total time.Duration
+	for _, event := range events {
+		total += event.Delay
+	}
+	return total
+}
+
+// CheckProfileMacros returns an error if the profile file data binds a macro
+// that the main config of the config directory doesn't define: profiles
+// share the macros of the main config, so a profile file can't be loaded
+// without them.
+func CheckProfileMacros(dir string, data []byte) error {
+	profile, err := decodeProfile(data)
+	if err != nil {
+		return fmt.Errorf("failed decoding profile: %w", err)
+	}
+	if len(profile.Mapping.Macros) == 0 {
+		return nil
+	}
+
+	var library struct {
+		Macros map[string]json.RawMessage `json:"macros"`
+	}
+	mainPath := filepath.Join(dir, ConfigDirFile)
+	mainData, err := os.ReadFile(mainPath)
+	switch {
+	case errors.Is(err, fs.ErrNotExist):
+		// no macros to use
+	case err != nil:
+		return fmt.Errorf("failed opening config file %q: %w", mainPath, err)
+	default:
+		if mainData, _, err = Migrate(mainData); err != nil {
+			return fmt.Errorf("failed decoding config file %q: %w", mainPath, err)
+		}
+		if err := json.Unmarshal(mainData, &library); err != nil {
+			return fmt.Errorf("failed decoding config file %q: %w", mainPath, err)
+		}
+	}
+	for _, gKey := range device.AllKeys() {
+		macro, ok := profile.Mapping.Macros[gKey.String()]
+		if !ok {
+			continue
+		}
+		if _, ok := library.Macros[macro]; !ok {
+			return fmt.Errorf("macros: %s: unknown macro: %s", gKey, macro)
+		}
+	}
+	return nil
+}
+
+// unusedMacros returns the names of the macros that no mapping binds, sorted.
+func (cfg *G13Config) unusedMacros() []string {
+	bound := map[string]bool{}
+	mappings := []Mapping{cfg.mapping}
+	for _, profile := range cfg.profiles {
+		mappings = append(mappings, profile.config.mapping)
+	}
+	for _, mapping := range mappings {
+		for _, macro := range mapping.macros {
+			bound[macro] = true
+		}
+	}
+	var unused []string
+	for name := range cfg.macros {
+		if !bound[name] {
+			unused = append(unused, name)
+		}
+	}
+	slices.Sort(unused)
+	return unused
+}
+
+// GetMacroBindings returns the G13 keys bound to macros and the names of the
+// macros.
+func (cfg *G13Config) GetMacroBindings() map[device.KeyBit]string {
+	return cfg.mapping.macros
+}
+
+// RepeatsMacro returns true if the macro bound to the G13 key replays for as
+// long as the key is held, instead of playing once each time it's pressed.
+func (cfg *G13Config) RepeatsMacro(gkey device.KeyBit) bool {
+	return cfg.mapping.repeatMacros&gkey.Uint64() != 0
+}
+
+// GetMacro returns the events of the named macro, or nil if there's no such
+// macro.
+func (cfg *G13Config) GetMacro(name string) []MacroEvent {
+	return cfg.macros[name]
+}
+
+// AddMacro adds the macro to the JSON encoded config data under the given
+// name and returns the new data. An existing macro with the same name is
+// only replaced if replace is set.
+func AddMacro(data []byte, name string, events []MacroEvent, replace bool) ([]byte, error) {
+	if name == "" {
+		return nil, fmt.Errorf("macro name can't be empty")
+	}
+	raw := map[string]any{}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, err
+	}
+
+	macros, ok := raw["macros"].(map[string]any)
+	if !ok {
+		if _, isSet := raw["macros"]; isSet {
+			return nil, fmt.Errorf("macros: not an object")
+		}
+		macros = map[string]any{}
+	}
+	if _, exists := macros[name]; exists && !replace {
+		return nil, fmt.Errorf("macro %s already exists", name)
+	}
+
+	fileEvents := make([]fileMacroEvent, 0, len(events))
+	for _, event := range events {
+		keyName := keyboard.KeyName(event.Key)
+		if keyName == "" {
+			return nil, fmt.Errorf("no name for keyboard key %d", event.Key)
+		}
+		fileEvent := fileMacroEvent{Key: keyName, Down: event.Down}
+		if event.Delay > 0 {
+			fileEvent.Delay = event.Delay.String()
+		}
+		fileEvents = append(fileEvents, fileEvent)
+	}
+	macros[name] = fileEvents
+	raw["macros"] = macros
+
+	added, err := json.MarshalIndent(raw, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append(added, '\n'), nil
+}
@@ -0,0 +1,35 @@
+package config
+
+import (
+	"fmt"
+	"time"
+)
+
+// DefaultLCDMessageDuration is how long a message is shown on the display when
+// lcd_messages sets no duration.
+const DefaultLCDMessageDuration = 2 * time.Second
+
+type lcdMessagesFileConfig struct {
+	Duration string `json:"duration"`
+}
+
+func loadLCDMessages(mc *lcdMessagesFileConfig) (time.Duration, error) {
+	if mc.Duration == "" {
+		return DefaultLCDMessageDuration, nil
+	}
+	duration, err := time.ParseDuration(mc.Duration)
+	if err != nil {
+		return 0, fmt.Errorf("lcd_messages: invalid duration %q: %w", mc.Duration, err)
+	}
+	if duration <= 0 {
+		return 0, fmt.Errorf("lcd_messages: duration must be positive: %s", mc.Duration)
+	}
+	return duration, nil
+}
+
+// GetLCDMessageDuration returns how long driver messages, like a reloaded
+// config or a reconnected device, are shown on the display. It returns zero if
+// messages aren't shown.
+func (cfg *G13Config) GetLCDMessageDuration() time.Duration {
+	return cfg.lcdMessageDuration
+}
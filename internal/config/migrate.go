@@ -0,0 +1,190 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math"
+	"slices"
+	"strconv"
+)
+
+// CurrentVersion is the version of the config file format written and
+// understood by this version of the driver. Config files without a version
+// are treated as version 0.
+const CurrentVersion = 1
+
+// migrations[n] upgrades a raw config from version n to version n+1.
+var migrations = []func(raw map[string]any) error{
+	migrateV0,
+}
+
+// migrateV0 upgrades unversioned configs. The format didn't change, only the
+// version field was added.
+func migrateV0(raw map[string]any) error {
+	return nil
+}
+
+// Migrate upgrades the JSON encoded config data to [CurrentVersion]. It
+// returns the upgraded data and whether any migration was applied. Data that
+// is already at the current version is returned unchanged.
+func Migrate(data []byte) ([]byte, bool, error) {
+	raw := map[string]any{}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, false, err
+	}
+
+	version, err := configVersion(raw)
+	if err != nil {
+		return nil, false, err
+	}
+	if version > CurrentVersion {
+		return nil, false, fmt.Errorf("config version %d is newer than the latest supported version %d", version, CurrentVersion)
+	}
+	if version == CurrentVersion {
+		return data, false, nil
+	}
+
+	for ; version < CurrentVersion; version++ {
+		if err := migrations[version](raw); err != nil {
+			return nil, false, fmt.Errorf("failed migrating config from version %d to %d: %w", version, version+1, err)
+		}
+	}
+	raw["version"] = CurrentVersion
+
+	migrated, err := marshalInOrder(raw, data)
+	if err != nil {
+		return nil, false, err
+	}
+	return migrated, true, nil
+}
+
+// marshalInOrder encodes the raw config like [json.MarshalIndent], but with
+// the keys of each object in the order they have in the original data, so
+// that rewriting a config file doesn't shuffle it. Keys that aren't in the
+// original, like an added version, come first, sorted.
+func marshalInOrder(raw map[string]any, original []byte) ([]byte, error) {
+	order := keyOrder(original)
+	var compact bytes.Buffer
+	if err := writeInOrder(&compact, raw, "", order); err != nil {
+		return nil, err
+	}
+	var indented bytes.Buffer
+	if err := json.Indent(&indented, compact.Bytes(), "", "  "); err != nil {
+		return nil, err
+	}
+	indented.WriteByte('\n')
+	return indented.Bytes(), nil
+}
+
+// keyOrder returns the keys of each object of the JSON data in order, by the
+// path of the object: the keys and array indices leading to it. Like
+// [duplicateKeys], it's a token-level pass that stops at invalid data.
+func keyOrder(data []byte) map[string][]string {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	order := map[string][]string{}
+	var walk func(path string) error
+	walk = func(path string) error {
+		token, err := decoder.Token()
+		if err != nil {
+			return err
+		}
+		switch token {
+		case json.Delim('{'):
+			for decoder.More() {
+				token, err := decoder.Token()
+				if err != nil {
+					return err
+				}
+				key, _ := token.(string)
+				if !slices.Contains(order[path], key) {
+					order[path] = append(order[path], key)
+				}
+				if err := walk(path + "/" + key); err != nil {
+					return err
+				}
+			}
+		case json.Delim('['):
+			for idx := 0; decoder.More(); idx++ {
+				if err := walk(path + "/" + strconv.Itoa(idx)); err != nil {
+					return err
+				}
+			}
+		default:
+			return nil
+		}
+		// the closing delimiter
+		_, err = decoder.Token()
+		return err
+	}
+	_ = walk("")
+	return order
+}
+
+// writeInOrder writes the compact encoding of the value at the path to buf,
+// with the keys of objects in the order.
+func writeInOrder(buf *bytes.Buffer, value any, path string, order map[string][]string) error {
+	switch value := value.(type) {
+	case map[string]any:
+		keys := make([]string, 0, len(value))
+		for key := range value {
+			if !slices.Contains(order[path], key) {
+				keys = append(keys, key)
+			}
+		}
+		slices.Sort(keys)
+		for _, key := range order[path] {
+			if _, ok := value[key]; ok {
+				keys = append(keys, key)
+			}
+		}
+
+		buf.WriteByte('{')
+		for idx, key := range keys {
+			if idx > 0 {
+				buf.WriteByte(',')
+			}
+			encodedKey, err := json.Marshal(key)
+			if err != nil {
+				return err
+			}
+			buf.Write(encodedKey)
+			buf.WriteByte(':')
+			if err := writeInOrder(buf, value[key], path+"/"+key, order); err != nil {
+				return err
+			}
+		}
+		buf.WriteByte('}')
+	case []any:
+		buf.WriteByte('[')
+		for idx, elem := range value {
+			if idx > 0 {
+				buf.WriteByte(',')
+			}
+			if err := writeInOrder(buf, elem, path+"/"+strconv.Itoa(idx), order); err != nil {
+				return err
+			}
+		}
+		buf.WriteByte(']')
+	default:
+		encoded, err := json.Marshal(value)
+		if err != nil {
+			return err
+		}
+		buf.Write(encoded)
+	}
+	return nil
+}
+
+// configVersion returns the value of the version field of the raw config.
+func configVersion(raw map[string]any) (int, error) {
+	value, ok := raw["version"]
+	if !ok {
+		return 0, nil
+	}
+	version, ok := value.(float64)
+	if !ok || version < 0 || version != math.Trunc(version) {
+		return 0, fmt.Errorf("invalid config version: %v", value)
+	}
+	return int(version), nil
+}
@@ -0,0 +1,105 @@
+package config_test
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/achilleas-k/gg13/internal/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMigrate(t *testing.T) {
+	t.Run("unversioned", func(t *testing.T) {
+		migrated, changed, err := config.Migrate([]byte(`{"mapping":{"keys":{"G1":"KeyA"}}}`))
+		require.NoError(t, err)
+		assert.True(t, changed)
+
+		raw := map[string]any{}
+		require.NoError(t, json.Unmarshal(migrated, &raw))
+		assert.Equal(t, map[string]any{
+			"version": float64(config.CurrentVersion),
+			"mapping": map[string]any{
+				"keys": map[string]any{"G1": "KeyA"},
+			},
+		}, raw)
+	})
+
+	t.Run("order", func(t *testing.T) {
+		// the keys keep their order, and the version leads
+		migrated, changed, err := config.Migrate([]byte(`{
+			"mapping": {"keys": {"G2": "KeyB", "G1": "KeyA"}, "actions": {"M1": "pause"}},
+			"backlight": {"colour": "red"},
+			"macros": {"hi": [{"key": "KeyH", "down": true}, {"down": false, "key": "KeyH"}]}
+		}`))
+		require.NoError(t, err)
+		assert.True(t, changed)
+		assert.Equal(t, `{
+  "version": 1,
+  "mapping": {
+    "keys": {
+      "G2": "KeyB",
+      "G1": "KeyA"
+    },
+    "actions": {
+      "M1": "pause"
+    }
+  },
+  "backlight": {
+    "colour": "red"
+  },
+  "macros": {
+    "hi": [
+      {
+        "key": "KeyH",
+        "down": true
+      },
+      {
+        "down": false,
+        "key": "KeyH"
+      }
+    ]
+  }
+}
+`, string(migrated))
+	})
+
+	t.Run("current", func(t *testing.T) {
+		data := []byte(`{"version":1,"mapping":{}}`)
+		migrated, changed, err := config.Migrate(data)
+		require.NoError(t, err)
+		assert.False(t, changed)
+		assert.Equal(t, data, migrated)
+	})
+
+	t.Run("errors", func(t *testing.T) {
+		testCases := map[string]struct {
+			data        string
+			expectedErr string
+		}{
+			"too-new": {
+				data:        `{"version":99}`,
+				expectedErr: "config version 99 is newer than the latest supported version 1",
+			},
+			"not-a-number": {
+				data:        `{"version":"one"}`,
+				expectedErr: "invalid config version: one",
+			},
+			"fractional": {
+				data:        `{"version":0.5}`,
+				expectedErr: "invalid config version: 0.5",
+			},
+			"bad-json": {
+				data:        `{"version":`,
+				expectedErr: "unexpected end of JSON input",
+			},
+		}
+
+		for name, tc := range testCases {
+			t.Run(name, func(t *testing.T) {
+				_, _, err := config.Migrate([]byte(tc.data))
+				assert.EqualError(t, err, tc.expectedErr)
+			})
+		}
+	})
+}
@@ -0,0 +1,37 @@
+package config
+
+import (
+	"fmt"
+
+	"github.com/achilleas-k/gg13/internal/device"
+)
+
+type lcdMonochromeFileConfig struct {
+	Threshold *float64 `json:"threshold"`
+	Gamma     float64  `json:"gamma"`
+	Invert    bool     `json:"invert"`
+}
+
+func loadLCDMonochrome(mc *lcdMonochromeFileConfig) (*device.Monochrome, error) {
+	mono := device.DefaultMonochrome()
+	if mc.Threshold != nil {
+		mono.Threshold = *mc.Threshold
+	}
+	if mc.Gamma != 0 {
+		mono.Gamma = mc.Gamma
+	}
+	mono.Invert = mc.Invert
+	if err := mono.Validate(); err != nil {
+		return nil, fmt.Errorf("lcd_monochrome: %w", err)
+	}
+	return &mono, nil
+}
+
+// GetLCDMonochrome returns the conversion of the LCD content to black and
+// white, or [device.DefaultMonochrome] if none is configured.
+func (cfg *G13Config) GetLCDMonochrome() device.Monochrome {
+	if cfg.lcdMonochrome == nil {
+		return device.DefaultMonochrome()
+	}
+	return *cfg.lcdMonochrome
+}
@@ -0,0 +1,103 @@
+package config
+
+import (
+	"fmt"
+	"slices"
+
+	"github.com/achilleas-k/gg13/internal/device"
+)
+
+// pageKeys are the soft keys under the LCD that can step through the pages.
+var pageKeys = []device.KeyBit{device.L1, device.L2, device.L3, device.L4}
+
+// DefaultPreviousPageKey and DefaultNextPageKey step through the pages when
+// pages sets no keys.
+const (
+	DefaultPreviousPageKey = device.L1
+	DefaultNextPageKey     = device.L4
+)
+
+// Pages are profiles that the soft keys under the LCD step through in order,
+// like the pages of a Stream Deck, multiplying the bindings of the G keys.
+// The LCD shows the name of the page that's switched to.
+type Pages struct {
+	// the names of the profiles, with [MainProfile] for the main mapping
+	Names []string
+
+	Previous device.KeyBit
+	Next     device.KeyBit
+}
+
+type pagesFileConfig struct {
+	Names    []string `json:"names"`
+	Previous string   `json:"previous"`
+	Next     string   `json:"next"`
+}
+
+// loadPages returns the pages, checking that they name the main mapping or
+// profiles and that their keys aren't bound in the main mapping or any
+// profile.
+func loadPages(pc *pagesFileConfig, main Mapping, profiles map[string]*profileCfg) (*Pages, error) {
+	if pc == nil {
+		return nil, nil
+	}
+	if len(pc.Names) < 2 {
+		return nil, fmt.Errorf("pages: names needs at least two pages")
+	}
+	for idx, name := range pc.Names {
+		if _, ok := profiles[name]; !ok && name != MainProfile {
+			return nil, fmt.Errorf("pages: unknown profile: %s", name)
+		}
+		if slices.Contains(pc.Names[:idx], name) {
+			return nil, fmt.Errorf("pages: %s is listed twice", name)
+		}
+	}
+
+	pages := &Pages{
+		Names:    pc.Names,
+		Previous: DefaultPreviousPageKey,
+		Next:     DefaultNextPageKey,
+	}
+	for _, key := range []struct {
+		field string
+		name  string
+		key   *device.KeyBit
+	}{
+		{"previous", pc.Previous, &pages.Previous},
+		{"next", pc.Next, &pages.Next},
+	} {
+		if key.name == "" {
+			continue
+		}
+		*key.key = device.KeyCode(key.name)
+		if !slices.Contains(pageKeys, *key.key) {
+			return nil, fmt.Errorf("pages: %s must be one of L1, L2, L3, and L4: %q", key.field, key.name)
+		}
+	}
+	if pages.Previous == pages.Next {
+		return nil, fmt.Errorf("pages: previous and next can't be the same key: %s", pages.Next)
+	}
+
+	for _, key := range []device.KeyBit{pages.Previous, pages.Next} {
+		if main.binds(key) {
+			return nil, fmt.Errorf("mapping: %s switches pages and can't be bound", key)
+		}
+		for name, profile := range profiles {
+			if profile.config.mapping.binds(key) {
+				return nil, fmt.Errorf("profiles: %s: %s switches pages and can't be bound", name, key)
+			}
+		}
+	}
+	return pages, nil
+}
+
+// GetPages returns the pages that the soft keys step through, or nil if
+// there are none.
+func (cfg *G13Config) GetPages() *Pages {
+	return cfg.pages
+}
+
+// isPageKey returns true if the G13 key steps through the pages.
+func (cfg *G13Config) isPageKey(gkey device.KeyBit) bool {
+	return cfg.pages != nil && (gkey == cfg.pages.Previous || gkey == cfg.pages.Next)
+}
@@ -0,0 +1,46 @@
+package config
+
+import (
+	"github.com/achilleas-k/gg13/internal/device"
+	"github.com/achilleas-k/gg13/internal/keyboard"
+)
+
+// passthroughLayout maps the G keys to keys that applications rarely bind by
+// default, so the pad can be used as a generic macro keypad: F13 to F24 for
+// the top two rows and the numpad digits for the rest.
+var passthroughLayout = map[device.KeyBit]string{
+	device.G1: "KeyF13", device.G2: "KeyF14", device.G3: "KeyF15", device.G4: "KeyF16",
+	device.G5: "KeyF17", device.G6: "KeyF18", device.G7: "KeyF19", device.G8: "KeyF20",
+	device.G9: "KeyF21", device.G10: "KeyF22", device.G11: "KeyF23", device.G12: "KeyF24",
+	device.G13: "KeyKp1", device.G14: "KeyKp2", device.G15: "KeyKp3", device.G16: "KeyKp4",
+	device.G17: "KeyKp5", device.G18: "KeyKp6", device.G19: "KeyKp7", device.G20: "KeyKp8",
+	device.G21: "KeyKp9", device.G22: "KeyKp0",
+}
+
+// Passthrough returns a copy of the config with the G keys mapped to the
+// passthrough layout instead of the configured keys and the stick turned
+// off. Keys bound to actions keep them and aren't remapped, so the action
+// that toggles the layout keeps working, disabled keys stay disabled, and
+// slow keys stay on.
+func (cfg *G13Config) Passthrough() *G13Config {
+	km := make(keyMap, len(passthroughLayout))
+	for gkey, kbKeyName := range passthroughLayout {
+		if _, ok := cfg.mapping.actions[gkey]; ok {
+			continue
+		}
+		km[gkey] = keyboard.KeyCode(kbKeyName)
+	}
+
+	passthrough := *cfg
+	passthrough.mapping = Mapping{
+		keyMap:       km,
+		stick:        stickCfg{mode: StickModeOff},
+		actions:      cfg.mapping.actions,
+		disabled:     cfg.mapping.disabled,
+		warnUnmapped: cfg.mapping.warnUnmapped,
+
+		accessibility: cfg.mapping.accessibility,
+	}
+	passthrough.mapping.indexOutputs()
+	return &passthrough
+}
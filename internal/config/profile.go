@@ -0,0 +1,409 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/achilleas-k/gg13/internal/device"
+)
+
+// profileKeys are the G13 keys that can switch profiles.
+var profileKeys = []device.KeyBit{device.M1, device.M2, device.M3, device.MR}
+
+// MainProfile is the name that switches back to the main mapping, which
+// can't be used for a profile.
+const MainProfile = "main"
+
+// LayoutNumpad is the built-in layout of a profile that turns the G keys into
+// a numeric keypad.
+const LayoutNumpad = "numpad"
+
+// numpadLayout is the numeric keypad of [LayoutNumpad]: the digits in the
+// middle of the top three rows and the bottom row, with the operators to
+// their right.
+var numpadLayout = map[device.KeyBit]string{
+	device.G1: "KeyNumlock", device.G2: "KeyKp7", device.G3: "KeyKp8", device.G4: "KeyKp9",
+	device.G5: "KeyKpslash", device.G6: "KeyKpasterisk", device.G7: "KeyBackspace",
+	device.G9: "KeyKp4", device.G10: "KeyKp5", device.G11: "KeyKp6", device.G12: "KeyKpminus", device.G13: "KeyKpplus",
+	device.G15: "KeyKp1", device.G16: "KeyKp2", device.G17: "KeyKp3", device.G18: "KeyKpdot", device.G19: "KeyKpenter",
+	device.G20: "KeyKp0", device.G21: "KeyKp0", device.G22: "KeyKpenter",
+}
+
+// DefaultProfileHookTimeout is how long the commands run when a profile
+// becomes active or inactive can take when the profile sets no timeout.
+const DefaultProfileHookTimeout = 5 * time.Second
+
+// Profile is a named set of bindings that replaces the mapping while it's
+// active. Pressing its M key latches it until the key is pressed again, or,
+// for a momentary profile, activates it only while the key is held. A
+// profile without a key is only switched to by name.
+type Profile struct {
+	Name string
+	// zero if the profile has no key
+	Key       device.KeyBit
+	Momentary bool
+
+	// Layout is the built-in layout the mapping of the profile starts from,
+	// like [LayoutNumpad], or empty for none
+	Layout string
+
+	// OnEnter and OnLeave are shell commands run when the profile becomes
+	// active and inactive, if set. They're stopped after HookTimeout.
+	OnEnter     string
+	OnLeave     string
+	HookTimeout time.Duration
+}
+
+type profileCfg struct {
+	Profile
+
+	// the config with the mapping of the profile, built once so that it
+	// can be compared with the active one
+	config *G13Config
+
+	// the LCD content of the profile, or nil if it shows the content of the
+	// main config
+	lcd *profileLCDCfg
+}
+
+// profileLCDCfg is the LCD content shown while a profile is active. Like the
+// main config, a profile sets at most one of them.
+type profileLCDCfg struct {
+	image        string
+	cheatSheet   bool
+	textFile     string
+	httpPage     *httpPageCfg
+	templatePage *templatePageCfg
+}
+
+type fileProfile struct {
+	Key       string      `json:"key"`
+	Momentary bool        `json:"momentary"`
+	Layout    string      `json:"layout"`
+	Mapping   fileMapping `json:"mapping"`
+
+	OnEnter     string `json:"on_enter"`
+	OnLeave     string `json:"on_leave"`
+	HookTimeout string `json:"hook_timeout"`
+
+	ImageFile    string                  `json:"image_file"`
+	CheatSheet   bool                    `json:"cheatsheet"`
+	TextFile     string                  `json:"text_file"`
+	HTTPPage     *httpPageFileConfig     `json:"http_page"`
+	TemplatePage *templatePageFileConfig `json:"template_page"`
+}
+
+// loadProfiles returns the profiles described in the config file, checking
+// that their keys don't collide with the bindings of the main mapping or of
+// any profile. The configs of the profiles only have their mapping until
+// [G13Config.setProfileConfigs] completes them.
+func loadProfiles(profiles map[string]fileProfile, main Mapping) (map[string]*profileCfg, error) {
+	if len(profiles) == 0 {
+		return nil, nil
+	}
+
+	loaded := make(map[string]*profileCfg, len(profiles))
+	mappings := map[string]Mapping{}
+	keys := make(map[device.KeyBit]string, len(profiles))
+	for name, profile := range profiles {
+		if name == "" {
+			return nil, fmt.Errorf("profiles: profile name can't be empty")
+		}
+		if name == MainProfile {
+			return nil, fmt.Errorf("profiles: %s is the name of the main mapping", MainProfile)
+		}
+		// profiles without a key are only switched to by name
+		var key device.KeyBit
+		if profile.Key != "" {
+			key = device.KeyCode(profile.Key)
+			if !slices.Contains(profileKeys, key) {
+				return nil, fmt.Errorf("profiles: %s: key must be one of M1, M2, M3, and MR: %q", name, profile.Key)
+			}
+			if other, ok := keys[key]; ok {
+				return nil, fmt.Errorf("profiles: %s: %s already switches to profile %s", name, key, other)
+			}
+			keys[key] = name
+		} else if profile.Momentary {
+			return nil, fmt.Errorf("profiles: %s: momentary requires a key", name)
+		}
+
+		hookTimeout := DefaultProfileHookTimeout
+		if profile.HookTimeout != "" {
+			var err error
+			hookTimeout, err = time.ParseDuration(profile.HookTimeout)
+			if err != nil {
+				return nil, fmt.Errorf("profiles: %s: invalid hook_timeout %q: %w", name, profile.HookTimeout, err)
+			}
+			if hookTimeout <= 0 {
+				return nil, fmt.Errorf("profiles: %s: hook_timeout must be positive: %s", name, profile.HookTimeout)
+			}
+		}
+
+		fileMapping := profile.Mapping
+		switch profile.Layout {
+		case "":
+		case LayoutNumpad:
+			keys, err := withLayout(fileMapping.Keys, numpadLayout)
+			if err != nil {
+				return nil, fmt.Errorf("profiles: %s: %w", name, err)
+			}
+			fileMapping.Keys = keys
+		default:
+			return nil, fmt.Errorf("profiles: %s: unknown layout: %s", name, profile.Layout)
+		}
+		mapping, err := loadMapping(fileMapping)
+		if err != nil {
+			return nil, fmt.Errorf("profiles: %s: %w", name, err)
+		}
+		mappings[name] = mapping
+		loaded[name] = &profileCfg{
+			Profile: Profile{
+				Name:        name,
+				Key:         key,
+				Momentary:   profile.Momentary,
+				Layout:      profile.Layout,
+				OnEnter:     profile.OnEnter,
+				OnLeave:     profile.OnLeave,
+				HookTimeout: hookTimeout,
+			},
+			config: &G13Config{mapping: mapping},
+		}
+	}
+
+	for key, profile := range keys {
+		if main.binds(key) {
+			return nil, fmt.Errorf("mapping: %s switches to profile %s and can't be bound", key, profile)
+		}
+		for name, mapping := range mappings {
+			if mapping.binds(key) {
+				return nil, fmt.Errorf("profiles: %s: %s switches to profile %s and can't be bound", name, key, profile)
+			}
+		}
+	}
+	return loaded, nil
+}
+
+// loadProfileLCD returns the LCD content of the profile, or nil if it sets
+// none. The paths of files are relative to the config file at cfgPath. The
+// timer and counters keep their state in the applet started with the driver,
+// so only the main config can show them.
+func loadProfileLCD(profile fileProfile, cfgPath string) (*profileLCDCfg, error) {
+	sources := 0
+	for _, isSet := range []bool{profile.ImageFile != "", profile.CheatSheet, profile.TextFile != "", profile.HTTPPage != nil, profile.TemplatePage != nil} {
+		if isSet {
+			sources++
+		}
+	}
+	if sources == 0 {
+		return nil, nil
+	}
+	if sources > 1 {
+		return nil, fmt.Errorf("only one of image_file, cheatsheet, http_page, text_file, and template_page can be set")
+	}
+
+	lcdCfg := &profileLCDCfg{cheatSheet: profile.CheatSheet}
+	var err error
+	switch {
+	case profile.ImageFile != "":
+		lcdCfg.image, err = checkImageFile(profile.ImageFile, cfgPath)
+	case profile.TextFile == "-":
+		// standard input is read once, by the applet of the main config
+		err = fmt.Errorf("text_file: only the main config can show standard input")
+	case profile.TextFile != "":
+		lcdCfg.textFile, err = checkTextFile(profile.TextFile, cfgPath)
+	case profile.HTTPPage != nil:
+		lcdCfg.httpPage, err = loadHTTPPage(profile.HTTPPage)
+	case profile.TemplatePage != nil:
+		lcdCfg.templatePage, err = loadTemplatePage(profile.TemplatePage)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return lcdCfg, nil
+}
+
+// withLayout returns the keys of a mapping with the keys of the layout added.
+// The keys of the layout can't be bound again.
+func withLayout(keys map[string]string, layout map[device.KeyBit]string) (map[string]string, error) {
+	merged := make(map[string]string, len(keys)+len(layout))
+	for gKey, kbKey := range layout {
+		merged[gKey.String()] = kbKey
+	}
+	for gKeyStr, kbKey := range keys {
+		if _, ok := layout[device.KeyCode(gKeyStr)]; ok {
+			return nil, fmt.Errorf("%s is part of the layout and can't be bound", gKeyStr)
+		}
+		merged[gKeyStr] = kbKey
+	}
+	return merged, nil
+}
+
+// setProfileConfigs completes the configs of the profiles with everything
+// but the mapping, and the LCD content of the profiles that set their own,
+// from cfg.
+func (cfg *G13Config) setProfileConfigs() {
+	for _, profile := range cfg.profiles {
+		mapping := profile.config.mapping
+		*profile.config = *cfg
+		profile.config.mapping = mapping
+		if lcdCfg := profile.lcd; lcdCfg != nil {
+			profile.config.lcdImage = lcdCfg.image
+			profile.config.lcdCheatSheet = lcdCfg.cheatSheet
+			profile.config.lcdTextFile = lcdCfg.textFile
+			profile.config.httpPage = lcdCfg.httpPage
+			profile.config.templatePage = lcdCfg.templatePage
+			profile.config.timer = nil
+			profile.config.lcdCounters = false
+		}
+	}
+}
+
+// GetProfiles returns the profiles, sorted by name.
+func (cfg *G13Config) GetProfiles() []Profile {
+	profiles := make([]Profile, 0, len(cfg.profiles))
+	for _, profile := range cfg.profiles {
+		profiles = append(profiles, profile.Profile)
+	}
+	slices.SortFunc(profiles, func(a, b Profile) int { return strings.Compare(a.Name, b.Name) })
+	return profiles
+}
+
+// GetProfile returns the named profile, if there is one. Unlike
+// [G13Config.GetProfiles], it doesn't allocate.
+func (cfg *G13Config) GetProfile(name string) (Profile, bool) {
+	profile, ok := cfg.profiles[name]
+	if !ok {
+		return Profile{}, false
+	}
+	return profile.Profile, true
+}
+
+// WithProfile returns the config with the mapping of the named profile, or
+// nil if there's no such profile. The same config is returned every time.
+func (cfg *G13Config) WithProfile(name string) *G13Config {
+	profile, ok := cfg.profiles[name]
+	if !ok {
+		return nil
+	}
+	return profile.config
+}
+
+// WithProfileLCD returns the config whose LCD content is shown while the
+// named profile is active: the config of the profile if it sets its own
+// content, or cfg if it doesn't or there's no such profile.
+func (cfg *G13Config) WithProfileLCD(name string) *G13Config {
+	profile, ok := cfg.profiles[name]
+	if !ok || profile.lcd == nil {
+		return cfg
+	}
+	return profile.config
+}
+
+// isProfileKey returns true if the G13 key switches profiles.
+func (cfg *G13Config) isProfileKey(gkey device.KeyBit) bool {
+	for _, profile := range cfg.profiles {
+		if profile.Key != 0 && profile.Key == gkey {
+			return true
+		}
+	}
+	return false
+}
+
+// HasProfileHooks returns true if any profile runs commands when it becomes
+// active or inactive.
+func (cfg *G13Config) HasProfileHooks() bool {
+	for _, profile := range cfg.profiles {
+		if profile.OnEnter != "" || profile.OnLeave != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// ConfigDirFile is the file with the main config in a config directory. The
+// other JSON files in the directory are profiles, named after the files.
+const ConfigDirFile = "config.json"
+
+// loadProfileDir returns the profiles in the files of the config directory.
+func loadProfileDir(dir string) (map[string]fileProfile, error) {
+	paths, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		return nil, err
+	}
+	profiles := make(map[string]fileProfile, len(paths))
+	for _, path := range paths {
+		file := filepath.Base(path)
+		if file == ConfigDirFile || strings.HasPrefix(file, ".") {
+			continue
+		}
+
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed opening profile file %q: %w", path, err)
+		}
+		profile, err := decodeProfile(data)
+		if err != nil {
+			return nil, fmt.Errorf("failed decoding profile file %q: %w", path, err)
+		}
+		profiles[strings.TrimSuffix(file, ".json")] = profile
+	}
+	return profiles, nil
+}
+
+// decodeProfile decodes the contents of a profile file, disallowing unknown
+// fields like the config file.
+func decodeProfile(data []byte) (fileProfile, error) {
+	var profile fileProfile
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	err := decoder.Decode(&profile)
+	return profile, err
+}
+
+// ReadProfile checks the contents of a profile file for a config directory
+// and returns the profile in it with the given name. Whether its key
+// collides with the bindings of the config is only checked when the config is
+// loaded.
+func ReadProfile(name string, data []byte) (Profile, error) {
+	profile, err := decodeProfile(data)
+	if err != nil {
+		return Profile{}, fmt.Errorf("failed decoding profile: %w", err)
+	}
+	profiles, err := loadProfiles(map[string]fileProfile{name: profile}, Mapping{})
+	if err != nil {
+		return Profile{}, err
+	}
+	return profiles[name].Profile, nil
+}
+
+// ReadProfileDir returns the profiles in the files of the config directory,
+// sorted by name, without the ones in [ConfigDirFile].
+func ReadProfileDir(dir string) ([]Profile, error) {
+	files, err := loadProfileDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	profiles, err := loadProfiles(files, Mapping{})
+	if err != nil {
+		return nil, err
+	}
+	cfg := G13Config{profiles: profiles}
+	return cfg.GetProfiles(), nil
+}
+
+// DefaultConfigDir returns the default config directory, under
+// $XDG_CONFIG_HOME, or ~/.config if it isn't set. It returns an empty string
+// if neither is known.
+func DefaultConfigDir() string {
+	configDir, err := os.UserConfigDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(configDir, "gg13")
+}
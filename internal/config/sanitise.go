@@ -0,0 +1,75 @@
+package config
+
+import (
+	"encoding/json"
+	"net/url"
+	"strings"
+)
+
+// Redacted replaces the values that [Sanitise] removes.
+const Redacted = "<redacted>"
+
+// sensitiveFields are the fields of the config file, in any section, whose
+// values can identify the user or hold secrets. The profile hooks are shell
+// commands, and templates run them with exec and execi, so either could
+// contain anything.
+var sensitiveFields = map[string]bool{
+	"username": true,
+	"on_enter": true,
+	"on_leave": true,
+	"template": true,
+}
+
+// Sanitise returns the JSON encoded config data, or a profile file, with the
+// values that can identify the user or hold secrets removed, for sharing in
+// bug reports. Sensitive fields are replaced with [Redacted], credentials and
+// queries are removed from URLs, and the home directory is shortened to ~ in
+// all strings.
+func Sanitise(data []byte, homeDir string) ([]byte, error) {
+	var raw any
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, err
+	}
+	sanitised, err := json.MarshalIndent(sanitiseValue(raw, homeDir), "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append(sanitised, '\n'), nil
+}
+
+func sanitiseValue(value any, homeDir string) any {
+	switch value := value.(type) {
+	case map[string]any:
+		for key, field := range value {
+			if sensitiveFields[key] {
+				value[key] = Redacted
+				continue
+			}
+			value[key] = sanitiseValue(field, homeDir)
+		}
+		return value
+	case []any:
+		for idx, item := range value {
+			value[idx] = sanitiseValue(item, homeDir)
+		}
+		return value
+	case string:
+		return sanitiseString(value, homeDir)
+	default:
+		return value
+	}
+}
+
+func sanitiseString(value, homeDir string) string {
+	if u, err := url.Parse(value); err == nil && u.Scheme != "" && u.Host != "" {
+		u.User = nil
+		u.RawQuery = ""
+		u.ForceQuery = false
+		u.Fragment = ""
+		return u.String()
+	}
+	if homeDir != "" && (value == homeDir || strings.HasPrefix(value, homeDir+"/")) {
+		return "~" + strings.TrimPrefix(value, homeDir)
+	}
+	return value
+}
@@ -0,0 +1,169 @@
+package config
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/achilleas-k/gg13/internal/device"
+	"github.com/achilleas-k/gg13/internal/keyboard"
+)
+
+// MaxScriptButton limits the joystick buttons that scripts can press, which
+// are numbered from 0.
+const MaxScriptButton = 31
+
+// ScriptStepKind is what a step of a script does.
+type ScriptStepKind uint8
+
+const (
+	// ScriptKeys types keyboard keys as a chord.
+	ScriptKeys ScriptStepKind = iota
+	// ScriptWait waits before the next step.
+	ScriptWait
+	// ScriptButton presses and releases a joystick button.
+	ScriptButton
+	// ScriptAction runs a driver-internal action.
+	ScriptAction
+)
+
+// ScriptStep is a step of a script bound to a G13 key. Only the field of its
+// kind is set.
+type ScriptStep struct {
+	Kind ScriptStepKind
+
+	// Keys are typed as a chord: the modifiers in order and then the last
+	// key, released in reverse
+	Keys   []int
+	Delay  time.Duration
+	Button int
+	Action Action
+}
+
+// parseScript parses a script: steps separated by commas, each one of
+//
+//	KeyA                type a key
+//	KeyLeftctrl+KeyC    type a chord of keys
+//	wait 50ms           wait before the next step
+//	button 0            press and release a joystick button
+//	action pause        run an action
+//
+// Reloading the config can't be part of a script.
+func parseScript(script string) ([]ScriptStep, error) {
+	var steps []ScriptStep
+	var total time.Duration
+	for stepStr := range strings.SplitSeq(script, ",") {
+		fields := strings.Fields(stepStr)
+		if len(fields) == 0 {
+			return nil, fmt.Errorf("empty step")
+		}
+		var step ScriptStep
+		switch fields[0] {
+		case "wait", "button", "action":
+			if len(fields) != 2 {
+				return nil, fmt.Errorf("%s: expected one argument", strings.TrimSpace(stepStr))
+			}
+		default:
+			if len(fields) != 1 {
+				return nil, fmt.Errorf("unknown step: %s", strings.TrimSpace(stepStr))
+			}
+		}
+
+		switch fields[0] {
+		case "wait":
+			delay, err := time.ParseDuration(fields[1])
+			if err != nil {
+				return nil, fmt.Errorf("invalid wait %q: %w", fields[1], err)
+			}
+			if delay <= 0 {
+				return nil, fmt.Errorf("wait must be positive: %s", fields[1])
+			}
+			total += delay
+			step = ScriptStep{Kind: ScriptWait, Delay: delay}
+		case "button":
+			button, err := strconv.Atoi(fields[1])
+			if err != nil || button < 0 || button > MaxScriptButton {
+				return nil, fmt.Errorf("button must be a number from 0 to %d: %s", MaxScriptButton, fields[1])
+			}
+			step = ScriptStep{Kind: ScriptButton, Button: button}
+		case "action":
+			action := Action(fields[1])
+			if !knownActions[action] {
+				return nil, fmt.Errorf("unknown action: %s", fields[1])
+			}
+			if action == ActionReloadConfig {
+				return nil, fmt.Errorf("%s can't be part of a script", action)
+			}
+			step = ScriptStep{Kind: ScriptAction, Action: action}
+		default:
+			step = ScriptStep{Kind: ScriptKeys}
+			for keyName := range strings.SplitSeq(fields[0], "+") {
+				kbKey := keyboard.KeyCode(keyName)
+				if kbKey == 0 {
+					return nil, fmt.Errorf("unknown keyboard key name: %s", keyName)
+				}
+				step.Keys = append(step.Keys, kbKey)
+			}
+		}
+		steps = append(steps, step)
+	}
+	if total > MaxMacroDuration {
+		return nil, fmt.Errorf("takes %s to run, more than the maximum of %s", total, MaxMacroDuration)
+	}
+	return steps, nil
+}
+
+// loadScripts returns the scripts bound to G13 keys, which can't also be
+// bound to keyboard keys, actions or macros.
+func loadScripts(scripts map[string]string, km keyMap, actions map[device.KeyBit]Action, macros map[device.KeyBit]string) (map[device.KeyBit][]ScriptStep, error) {
+	if len(scripts) == 0 {
+		return nil, nil
+	}
+
+	loaded := make(map[device.KeyBit][]ScriptStep, len(scripts))
+	for keyName, script := range scripts {
+		gKey := device.KeyCode(keyName)
+		if gKey == 0 {
+			return nil, fmt.Errorf("scripts: unknown G13 key name: %s", keyName)
+		}
+		_, isKey := km[gKey]
+		_, isAction := actions[gKey]
+		_, isMacro := macros[gKey]
+		if isKey || isAction || isMacro {
+			return nil, fmt.Errorf("scripts: %s is already bound", keyName)
+		}
+		steps, err := parseScript(script)
+		if err != nil {
+			return nil, fmt.Errorf("scripts: %s: %w", keyName, err)
+		}
+		loaded[gKey] = steps
+	}
+	return loaded, nil
+}
+
+// GetScripts returns the scripts bound to G13 keys, run when the key is
+// pressed.
+func (cfg *G13Config) GetScripts() map[device.KeyBit][]ScriptStep {
+	return cfg.mapping.scripts
+}
+
+// GetJoystickButtons returns the number of joystick buttons that the scripts
+// of the mapping and the profiles press, which the virtual joystick needs.
+func (cfg *G13Config) GetJoystickButtons() int {
+	buttons := 0
+	mappings := []Mapping{cfg.mapping}
+	for _, profile := range cfg.profiles {
+		mappings = append(mappings, profile.config.mapping)
+	}
+	for _, mapping := range mappings {
+		for _, steps := range mapping.scripts {
+			for _, step := range steps {
+				if step.Kind == ScriptButton {
+					buttons = max(buttons, step.Button+1)
+				}
+			}
+		}
+	}
+	return buttons
+}
@@ -0,0 +1,80 @@
+package config
+
+import (
+	"fmt"
+
+	"github.com/achilleas-k/gg13/internal/device"
+)
+
+// ScrollDirection is the direction that a scroll binding turns the mouse
+// wheel in.
+type ScrollDirection string
+
+const (
+	ScrollUp    ScrollDirection = "up"
+	ScrollDown  ScrollDirection = "down"
+	ScrollLeft  ScrollDirection = "left"
+	ScrollRight ScrollDirection = "right"
+)
+
+// Horizontal returns true if the direction turns the horizontal wheel.
+func (dir ScrollDirection) Horizontal() bool {
+	return dir == ScrollLeft || dir == ScrollRight
+}
+
+// Notches returns the notches that the wheel turns by for each step in the
+// direction: positive up and right, negative down and left, like the wheel
+// events of the mouse.
+func (dir ScrollDirection) Notches() int {
+	if dir == ScrollDown || dir == ScrollLeft {
+		return -1
+	}
+	return 1
+}
+
+// loadScroll returns the directions that G13 keys scroll in, which can't also
+// have other bindings in the mapping.
+func loadScroll(scroll map[string]string, mapping Mapping) (map[device.KeyBit]ScrollDirection, error) {
+	if len(scroll) == 0 {
+		return nil, nil
+	}
+
+	loaded := make(map[device.KeyBit]ScrollDirection, len(scroll))
+	for keyName, dirName := range scroll {
+		gKey := device.KeyCode(keyName)
+		if gKey == 0 {
+			return nil, fmt.Errorf("scroll: unknown G13 key name: %s", keyName)
+		}
+		if mapping.binds(gKey) {
+			return nil, fmt.Errorf("scroll: %s is already bound", keyName)
+		}
+		dir := ScrollDirection(dirName)
+		switch dir {
+		case ScrollUp, ScrollDown, ScrollLeft, ScrollRight:
+		default:
+			return nil, fmt.Errorf("scroll: %s: unknown direction %q: expected up, down, left or right", keyName, dirName)
+		}
+		loaded[gKey] = dir
+	}
+	return loaded, nil
+}
+
+// GetScroll returns the directions that G13 keys scroll the mouse wheel in
+// while they're held.
+func (cfg *G13Config) GetScroll() map[device.KeyBit]ScrollDirection {
+	return cfg.mapping.scroll
+}
+
+// HasScrollBindings returns true if the mapping or a profile binds keys to
+// scrolling, which needs the virtual mouse.
+func (cfg *G13Config) HasScrollBindings() bool {
+	if len(cfg.mapping.scroll) > 0 {
+		return true
+	}
+	for _, profile := range cfg.profiles {
+		if len(profile.config.mapping.scroll) > 0 {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,61 @@
+package config
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/achilleas-k/gg13/internal/applet"
+	"github.com/achilleas-k/gg13/internal/lcd"
+)
+
+// defaultTemplatePageInterval is how often the template_page is rendered when
+// no interval is set.
+const defaultTemplatePageInterval = time.Second
+
+type templatePageFileConfig struct {
+	Template string `json:"template"`
+	Interval string `json:"interval"`
+}
+
+type templatePageCfg struct {
+	template string
+	interval time.Duration
+	// the template runs shell commands
+	exec bool
+}
+
+func loadTemplatePage(page *templatePageFileConfig) (*templatePageCfg, error) {
+	if page.Template == "" {
+		return nil, fmt.Errorf("template_page: template is required")
+	}
+
+	interval := defaultTemplatePageInterval
+	if page.Interval != "" {
+		var err error
+		interval, err = time.ParseDuration(page.Interval)
+		if err != nil {
+			return nil, fmt.Errorf("template_page: invalid interval %q: %w", page.Interval, err)
+		}
+		if interval <= 0 {
+			return nil, fmt.Errorf("template_page: interval must be positive: %s", page.Interval)
+		}
+	}
+
+	// validate the template early
+	a, err := applet.NewTemplatePage(page.Template, lcd.Font3x5)
+	if err != nil {
+		return nil, fmt.Errorf("template_page: %w", err)
+	}
+
+	return &templatePageCfg{
+		template: page.Template,
+		interval: interval,
+		exec:     a.RunsCommands(),
+	}, nil
+}
+
+// HasTemplateExec returns true if the template page of the LCD runs shell
+// commands with exec or execi.
+func (cfg *G13Config) HasTemplateExec() bool {
+	return cfg.templatePage != nil && cfg.templatePage.exec
+}
@@ -0,0 +1,64 @@
+package config
+
+import (
+	"fmt"
+	"time"
+)
+
+// DefaultTimerColour is the backlight colour flashed when the timer runs out,
+// when none is set.
+var DefaultTimerColour = [3]uint8{255, 0, 0}
+
+// TimerFlashDuration is how long the backlight shows the timer colour when
+// the timer runs out.
+const TimerFlashDuration = 3 * time.Second
+
+// timerRenderInterval is how often the timer is redrawn. It's shorter than a
+// second so the display follows key presses quickly.
+const timerRenderInterval = 250 * time.Millisecond
+
+type timerFileConfig struct {
+	Duration string `json:"duration"`
+	Colour   string `json:"colour"`
+}
+
+type timerCfg struct {
+	duration time.Duration
+	colour   [3]uint8
+}
+
+var timerActions = map[Action]bool{
+	ActionTimerStartPause: true,
+	ActionTimerReset:      true,
+}
+
+func loadTimer(fc *timerFileConfig) (*timerCfg, error) {
+	if fc.Duration == "" {
+		return nil, fmt.Errorf("timer: duration is required")
+	}
+	duration, err := time.ParseDuration(fc.Duration)
+	if err != nil {
+		return nil, fmt.Errorf("timer: invalid duration %q: %w", fc.Duration, err)
+	}
+	if duration <= 0 {
+		return nil, fmt.Errorf("timer: duration must be positive: %s", fc.Duration)
+	}
+
+	colour := DefaultTimerColour
+	if fc.Colour != "" {
+		colour, err = ParseColour(fc.Colour)
+		if err != nil {
+			return nil, fmt.Errorf("timer: %w", err)
+		}
+	}
+	return &timerCfg{duration: duration, colour: colour}, nil
+}
+
+// GetTimerColour returns the backlight colour to flash when the timer runs
+// out. The second return value is false if no timer is configured.
+func (cfg *G13Config) GetTimerColour() ([3]uint8, bool) {
+	if cfg.timer == nil {
+		return [3]uint8{}, false
+	}
+	return cfg.timer.colour, true
+}
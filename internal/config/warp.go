@@ -0,0 +1,124 @@
+package config
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/achilleas-k/gg13/internal/device"
+)
+
+// MaxScreenSize limits the width and height of the screen, in pixels.
+const MaxScreenSize = 32768
+
+type screenFileConfig struct {
+	Width  int `json:"width"`
+	Height int `json:"height"`
+}
+
+// ScreenSize is the size of the screen in pixels, covering all monitors, that
+// warp bindings move the pointer on.
+type ScreenSize struct {
+	Width  int
+	Height int
+}
+
+func loadScreen(sc *screenFileConfig) (*ScreenSize, error) {
+	if sc == nil {
+		return nil, nil
+	}
+	if sc.Width <= 0 || sc.Width > MaxScreenSize || sc.Height <= 0 || sc.Height > MaxScreenSize {
+		return nil, fmt.Errorf("screen: width and height must be from 1 to %d: %dx%d", MaxScreenSize, sc.Width, sc.Height)
+	}
+	return &ScreenSize{Width: sc.Width, Height: sc.Height}, nil
+}
+
+// Point is an absolute position on the screen, in pixels from the top left
+// corner.
+type Point struct {
+	X int
+	Y int
+}
+
+func (p Point) String() string {
+	return fmt.Sprintf("%d,%d", p.X, p.Y)
+}
+
+// parsePoint parses a position written as "x,y".
+func parsePoint(pos string) (Point, error) {
+	xStr, yStr, ok := strings.Cut(pos, ",")
+	if !ok {
+		return Point{}, fmt.Errorf("invalid position %q: expected x,y", pos)
+	}
+	x, errX := strconv.Atoi(strings.TrimSpace(xStr))
+	y, errY := strconv.Atoi(strings.TrimSpace(yStr))
+	if errX != nil || errY != nil || x < 0 || y < 0 {
+		return Point{}, fmt.Errorf("invalid position %q: expected x,y", pos)
+	}
+	return Point{X: x, Y: y}, nil
+}
+
+// loadWarps returns the positions that G13 keys move the pointer to, which
+// can't also have other bindings in the mapping.
+func loadWarps(warps map[string]string, mapping Mapping) (map[device.KeyBit]Point, error) {
+	if len(warps) == 0 {
+		return nil, nil
+	}
+
+	loaded := make(map[device.KeyBit]Point, len(warps))
+	for keyName, pos := range warps {
+		gKey := device.KeyCode(keyName)
+		if gKey == 0 {
+			return nil, fmt.Errorf("warp: unknown G13 key name: %s", keyName)
+		}
+		if mapping.binds(gKey) {
+			return nil, fmt.Errorf("warp: %s is already bound", keyName)
+		}
+		point, err := parsePoint(pos)
+		if err != nil {
+			return nil, fmt.Errorf("warp: %s: %w", keyName, err)
+		}
+		loaded[gKey] = point
+	}
+	return loaded, nil
+}
+
+// checkWarps returns an error if the positions aren't on the screen, which
+// has to be set for warping the pointer.
+func checkWarps(warps map[device.KeyBit]Point, screen *ScreenSize) error {
+	for gKey, point := range warps {
+		if screen == nil {
+			return fmt.Errorf("warp: %s: moving the pointer requires the screen size", gKey)
+		}
+		if point.X >= screen.Width || point.Y >= screen.Height {
+			return fmt.Errorf("warp: %s: %s is outside the %dx%d screen", gKey, point, screen.Width, screen.Height)
+		}
+	}
+	return nil
+}
+
+// GetWarps returns the positions that G13 keys move the pointer to when
+// they're pressed.
+func (cfg *G13Config) GetWarps() map[device.KeyBit]Point {
+	return cfg.mapping.warps
+}
+
+// GetScreenSize returns the size of the screen that warp bindings move the
+// pointer on, or nil if it isn't set.
+func (cfg *G13Config) GetScreenSize() *ScreenSize {
+	return cfg.screen
+}
+
+// HasWarpBindings returns true if the mapping or a profile binds keys to
+// moving the pointer, which needs the virtual mouse.
+func (cfg *G13Config) HasWarpBindings() bool {
+	if len(cfg.mapping.warps) > 0 {
+		return true
+	}
+	for _, profile := range cfg.profiles {
+		if len(profile.config.mapping.warps) > 0 {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,60 @@
+package config
+
+import (
+	"fmt"
+
+	"github.com/achilleas-k/gg13/internal/desktop"
+	"github.com/achilleas-k/gg13/internal/device"
+)
+
+// loadWindows returns the window management operations that G13 keys run,
+// which can't also have other bindings in the mapping.
+func loadWindows(windows map[string]string, mapping Mapping) (map[device.KeyBit]desktop.Op, error) {
+	if len(windows) == 0 {
+		return nil, nil
+	}
+
+	loaded := make(map[device.KeyBit]desktop.Op, len(windows))
+	for keyName, opStr := range windows {
+		gKey := device.KeyCode(keyName)
+		if gKey == 0 {
+			return nil, fmt.Errorf("windows: unknown G13 key name: %s", keyName)
+		}
+		if mapping.binds(gKey) {
+			return nil, fmt.Errorf("windows: %s is already bound", keyName)
+		}
+		op, err := desktop.ParseOp(opStr)
+		if err != nil {
+			return nil, fmt.Errorf("windows: %s: %w", keyName, err)
+		}
+		loaded[gKey] = op
+	}
+	return loaded, nil
+}
+
+// GetWindows returns the window management operations that G13 keys run when
+// they're pressed.
+func (cfg *G13Config) GetWindows() map[device.KeyBit]desktop.Op {
+	return cfg.mapping.windows
+}
+
+// GetDesktop returns the name of the desktop backend that runs the window
+// management operations, or an empty string if it should be detected from
+// the session.
+func (cfg *G13Config) GetDesktop() string {
+	return cfg.desktop
+}
+
+// HasWindowBindings returns true if the mapping or a profile binds keys to
+// window management operations.
+func (cfg *G13Config) HasWindowBindings() bool {
+	if len(cfg.mapping.windows) > 0 {
+		return true
+	}
+	for _, profile := range cfg.profiles {
+		if len(profile.config.mapping.windows) > 0 {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,257 @@
+// Package control implements the control socket used to communicate with a
+// running gg13 instance.
+//
+// The protocol is one JSON-encoded [Request] per connection, answered by one
+// JSON-encoded [Response]. The server can optionally also listen on TCP for
+// remote control, in which case requests received over TCP must carry the
+// configured token and can only run the commands allowed over TCP. Nothing
+// is encrypted, including the token.
+package control
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/achilleas-k/gg13/internal/i18n"
+)
+
+const (
+	// connTimeout limits how long a single request can take on either side
+	// of the connection.
+	connTimeout = 5 * time.Second
+
+	// maxRequestSize is the most that's read of a request, before its token
+	// is checked.
+	maxRequestSize = 1 << 20
+)
+
+// Request is a command sent to the control socket.
+type Request struct {
+	Command string   `json:"command"`
+	Args    []string `json:"args,omitempty"`
+
+	// Token authenticates requests sent over TCP
+	Token string `json:"token,omitempty"`
+}
+
+// Response is the reply to a [Request]. Error is empty on success.
+type Response struct {
+	Error string          `json:"error,omitempty"`
+	Data  json.RawMessage `json:"data,omitempty"`
+}
+
+// HandlerFunc handles a control command. The returned value is encoded as
+// JSON in the [Response] data.
+type HandlerFunc func(args []string) (any, error)
+
+// Server listens on a unix socket, and optionally TCP, and dispatches
+// requests to the registered handlers.
+type Server struct {
+	mu        sync.Mutex
+	listeners []net.Listener
+	handlers  map[string]HandlerFunc
+}
+
+// DefaultSocketPath returns the default location of the control socket,
+// under $XDG_RUNTIME_DIR if it is set.
+func DefaultSocketPath() string {
+	if runtimeDir := os.Getenv("XDG_RUNTIME_DIR"); runtimeDir != "" {
+		return filepath.Join(runtimeDir, "gg13.sock")
+	}
+	return filepath.Join(os.TempDir(), fmt.Sprintf("gg13-%d.sock", os.Getuid()))
+}
+
+// NewServer returns a [Server] listening on the unix socket at the given path.
+// A stale socket file is removed, but an error is returned if another
+// instance is already listening on it.
+func NewServer(path string) (*Server, error) {
+	if conn, err := net.Dial("unix", path); err == nil {
+		_ = conn.Close()
+		return nil, fmt.Errorf("control socket %q is already in use by another instance", path)
+	}
+	if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("failed to remove stale control socket %q: %w", path, err)
+	}
+
+	listener, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to listen on control socket %q: %w", path, err)
+	}
+
+	s := &Server{
+		listeners: []net.Listener{listener},
+		handlers:  make(map[string]HandlerFunc),
+	}
+	go s.serve(listener, nil)
+	return s, nil
+}
+
+// remoteAuth is what requests received over TCP are checked against.
+type remoteAuth struct {
+	token string
+
+	// the commands allowed over TCP
+	commands map[string]bool
+}
+
+// ListenTCP makes the server also accept requests on the given TCP address.
+// Requests received over TCP are rejected unless they carry the token and
+// run one of the commands.
+func (s *Server) ListenTCP(addr, token string, commands []string) error {
+	if token == "" {
+		return fmt.Errorf("a token is required for listening on TCP")
+	}
+
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return fmt.Errorf("failed to listen on %q: %w", addr, err)
+	}
+
+	s.mu.Lock()
+	s.listeners = append(s.listeners, listener)
+	s.mu.Unlock()
+
+	auth := &remoteAuth{token: token, commands: make(map[string]bool, len(commands))}
+	for _, command := range commands {
+		auth.commands[command] = true
+	}
+	go s.serve(listener, auth)
+	return nil
+}
+
+// Handle registers the handler for the given command, replacing any existing
+// one.
+func (s *Server) Handle(command string, handler HandlerFunc) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.handlers[command] = handler
+}
+
+// Close stops listening. The socket file is removed by the listener.
+func (s *Server) Close() error {
+	if s == nil {
+		return nil
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var errs []error
+	for _, listener := range s.listeners {
+		errs = append(errs, listener.Close())
+	}
+	return errors.Join(errs...)
+}
+
+// serve accepts connections on the listener. If auth is set, requests are
+// checked against it.
+func (s *Server) serve(listener net.Listener, auth *remoteAuth) {
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			if !errors.Is(err, net.ErrClosed) {
+				fmt.Fprintln(os.Stderr, i18n.Sprintf("control socket error: %s", err))
+			}
+			return
+		}
+		go s.handleConn(conn, auth)
+	}
+}
+
+func (s *Server) handleConn(conn net.Conn, auth *remoteAuth) {
+	defer func() { _ = conn.Close() }()
+
+	if err := conn.SetDeadline(time.Now().Add(connTimeout)); err != nil {
+		fmt.Fprintln(os.Stderr, i18n.Sprintf("control socket error: %s", err))
+		return
+	}
+
+	resp := s.dispatch(conn, auth)
+	if err := json.NewEncoder(conn).Encode(resp); err != nil {
+		fmt.Fprintln(os.Stderr, i18n.Sprintf("control socket error: failed sending response: %s", err))
+	}
+}
+
+func (s *Server) dispatch(conn net.Conn, auth *remoteAuth) Response {
+	var req Request
+	if err := json.NewDecoder(io.LimitReader(conn, maxRequestSize)).Decode(&req); err != nil {
+		return Response{Error: fmt.Sprintf("failed decoding request: %s", err)}
+	}
+
+	if auth != nil {
+		if subtle.ConstantTimeCompare([]byte(req.Token), []byte(auth.token)) != 1 {
+			return Response{Error: "unauthorized: invalid token"}
+		}
+		if !auth.commands[req.Command] {
+			return Response{Error: fmt.Sprintf("command %s isn't allowed over TCP", req.Command)}
+		}
+	}
+
+	s.mu.Lock()
+	handler, ok := s.handlers[req.Command]
+	s.mu.Unlock()
+	if !ok {
+		return Response{Error: fmt.Sprintf("unknown command: %s", req.Command)}
+	}
+
+	data, err := handler(req.Args)
+	if err != nil {
+		return Response{Error: err.Error()}
+	}
+
+	raw, err := json.Marshal(data)
+	if err != nil {
+		return Response{Error: fmt.Sprintf("failed encoding response: %s", err)}
+	}
+	return Response{Data: raw}
+}
+
+// Send sends a request to the control socket at the given path and returns
+// the data from the response. An error reported by the server is returned as
+// an error.
+func Send(path string, req Request) (json.RawMessage, error) {
+	conn, err := net.DialTimeout("unix", path, connTimeout)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to control socket %q (is gg13 running?): %w", path, err)
+	}
+	return send(conn, req)
+}
+
+// SendTCP sends a request to a gg13 instance listening on the given TCP
+// address, authenticating with the token.
+func SendTCP(addr, token string, req Request) (json.RawMessage, error) {
+	conn, err := net.DialTimeout("tcp", addr, connTimeout)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to %q: %w", addr, err)
+	}
+	req.Token = token
+	return send(conn, req)
+}
+
+func send(conn net.Conn, req Request) (json.RawMessage, error) {
+	defer func() { _ = conn.Close() }()
+
+	if err := conn.SetDeadline(time.Now().Add(connTimeout)); err != nil {
+		return nil, err
+	}
+
+	if err := json.NewEncoder(conn).Encode(req); err != nil {
+		return nil, fmt.Errorf("failed sending request: %w", err)
+	}
+
+	var resp Response
+	if err := json.NewDecoder(conn).Decode(&resp); err != nil {
+		return nil, fmt.Errorf("failed decoding response: %w", err)
+	}
+	if resp.Error != "" {
+		return nil, errors.New(resp.Error)
+	}
+	return resp.Data, nil
+}
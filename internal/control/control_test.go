@@ -0,0 +1,138 @@
+package control_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/achilleas-k/gg13/internal/control"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestServer(t *testing.T) (*control.Server, string) {
+	t.Helper()
+	socketPath := filepath.Join(t.TempDir(), "gg13.sock")
+	server, err := control.NewServer(socketPath)
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = server.Close() })
+	return server, socketPath
+}
+
+func TestSend(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	server, socketPath := newTestServer(t)
+	server.Handle("echo", func(args []string) (any, error) {
+		return args, nil
+	})
+
+	data, err := control.Send(socketPath, control.Request{Command: "echo", Args: []string{"a", "b"}})
+	require.NoError(err)
+
+	var args []string
+	require.NoError(json.Unmarshal(data, &args))
+	assert.Equal([]string{"a", "b"}, args)
+}
+
+func TestSendErrors(t *testing.T) {
+	t.Run("unknown-command", func(t *testing.T) {
+		_, socketPath := newTestServer(t)
+		_, err := control.Send(socketPath, control.Request{Command: "nope"})
+		assert.EqualError(t, err, "unknown command: nope")
+	})
+
+	t.Run("handler-error", func(t *testing.T) {
+		server, socketPath := newTestServer(t)
+		server.Handle("fail", func([]string) (any, error) {
+			return nil, fmt.Errorf("it failed")
+		})
+		_, err := control.Send(socketPath, control.Request{Command: "fail"})
+		assert.EqualError(t, err, "it failed")
+	})
+
+	t.Run("not-running", func(t *testing.T) {
+		socketPath := filepath.Join(t.TempDir(), "gg13.sock")
+		_, err := control.Send(socketPath, control.Request{Command: "screenshot"})
+		assert.ErrorContains(t, err, "is gg13 running?")
+	})
+}
+
+func TestNewServer(t *testing.T) {
+	t.Run("stale-socket", func(t *testing.T) {
+		socketPath := filepath.Join(t.TempDir(), "gg13.sock")
+		require.NoError(t, os.WriteFile(socketPath, nil, 0o600))
+
+		server, err := control.NewServer(socketPath)
+		require.NoError(t, err)
+		assert.NoError(t, server.Close())
+	})
+
+	t.Run("in-use", func(t *testing.T) {
+		_, socketPath := newTestServer(t)
+		_, err := control.NewServer(socketPath)
+		assert.ErrorContains(t, err, "already in use by another instance")
+	})
+
+	t.Run("removed-on-close", func(t *testing.T) {
+		server, socketPath := newTestServer(t)
+		require.NoError(t, server.Close())
+		assert.NoFileExists(t, socketPath)
+	})
+}
+
+func TestListenTCP(t *testing.T) {
+	server, _ := newTestServer(t)
+	server.Handle("echo", func(args []string) (any, error) {
+		return args, nil
+	})
+
+	// find a free port
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	addr := listener.Addr().String()
+	require.NoError(t, listener.Close())
+
+	server.Handle("local", func(args []string) (any, error) {
+		return nil, nil
+	})
+	require.NoError(t, server.ListenTCP(addr, "s3cret", []string{"echo"}))
+
+	t.Run("valid-token", func(t *testing.T) {
+		data, err := control.SendTCP(addr, "s3cret", control.Request{Command: "echo", Args: []string{"a"}})
+		require.NoError(t, err)
+		assert.JSONEq(t, `["a"]`, string(data))
+	})
+
+	t.Run("invalid-token", func(t *testing.T) {
+		_, err := control.SendTCP(addr, "guess", control.Request{Command: "echo"})
+		assert.EqualError(t, err, "unauthorized: invalid token")
+	})
+
+	t.Run("no-token", func(t *testing.T) {
+		_, err := control.SendTCP(addr, "", control.Request{Command: "echo"})
+		assert.EqualError(t, err, "unauthorized: invalid token")
+	})
+
+	t.Run("not-allowed", func(t *testing.T) {
+		_, err := control.SendTCP(addr, "s3cret", control.Request{Command: "local"})
+		assert.EqualError(t, err, "command local isn't allowed over TCP")
+	})
+
+	t.Run("too-large", func(t *testing.T) {
+		// depending on when the server stops reading, the request fails
+		// while it's sent or the server replies with the error
+		_, err := control.SendTCP(addr, "s3cret", control.Request{Command: "echo", Args: []string{strings.Repeat("a", 2<<20)}})
+		assert.Error(t, err)
+	})
+
+	t.Run("token-required", func(t *testing.T) {
+		err := server.ListenTCP("127.0.0.1:0", "", nil)
+		assert.EqualError(t, err, "a token is required for listening on TCP")
+	})
+}
@@ -0,0 +1,685 @@
+// Package dbus is a minimal D-Bus client for the desktop services that the
+// driver talks to: logind, for the lock state of the user's graphical
+// session, and the Screenshot interface of the XDG desktop portal, which
+// works the same under every desktop that ships a portal backend, X11 and
+// Wayland alike, without external tools.
+//
+// Only a few method calls and signals are needed, so it implements just
+// enough of the wire protocol itself instead of depending on a full client.
+package dbus
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Just enough of the D-Bus wire protocol for watching logind on the system
+// bus and for calling the portal on the session bus and waiting for its
+// response: little endian messages, the EXTERNAL authentication of Unix
+// sockets, and the basic and container types.
+// See https://dbus.freedesktop.org/doc/dbus-specification.html.
+
+// the types of messages
+const (
+	msgMethodCall   = 1
+	msgMethodReturn = 2
+	msgError        = 3
+	msgSignal       = 4
+)
+
+// the codes of the header fields
+const (
+	fieldPath        = 1
+	fieldInterface   = 2
+	fieldMember      = 3
+	fieldErrorName   = 4
+	fieldReplySerial = 5
+	fieldDestination = 6
+	fieldSender      = 7
+	fieldSignature   = 8
+)
+
+const (
+	// dialTimeout limits how long connecting and authenticating to the bus
+	// can take.
+	dialTimeout = 5 * time.Second
+
+	// maxMessageSize is the largest message the bus allows.
+	maxMessageSize = 128 << 20
+)
+
+// objectPath is a D-Bus object path, which is encoded like a string but with
+// its own type.
+type objectPath string
+
+// signature is a D-Bus type signature.
+type signature string
+
+// message is a D-Bus message with the header fields this package uses.
+type message struct {
+	typ         byte
+	serial      uint32
+	path        objectPath
+	iface       string
+	member      string
+	errName     string
+	replySerial uint32
+	dest        string
+	sender      string
+	sig         signature
+	body        []byte
+}
+
+// encoder writes values in the D-Bus wire format. The alignment of the values
+// is relative to the start of the buffer, which has to start at an 8 byte
+// boundary of the message.
+type encoder struct {
+	buf []byte
+}
+
+func (e *encoder) align(n int) {
+	for len(e.buf)%n != 0 {
+		e.buf = append(e.buf, 0)
+	}
+}
+
+func (e *encoder) byte(b byte) {
+	e.buf = append(e.buf, b)
+}
+
+func (e *encoder) uint32(v uint32) {
+	e.align(4)
+	e.buf = binary.LittleEndian.AppendUint32(e.buf, v)
+}
+
+func (e *encoder) bool(b bool) {
+	if b {
+		e.uint32(1)
+	} else {
+		e.uint32(0)
+	}
+}
+
+func (e *encoder) string(s string) {
+	e.uint32(uint32(len(s)))
+	e.buf = append(e.buf, s...)
+	e.buf = append(e.buf, 0)
+}
+
+func (e *encoder) signature(s signature) {
+	e.buf = append(e.buf, byte(len(s)))
+	e.buf = append(e.buf, s...)
+	e.buf = append(e.buf, 0)
+}
+
+// array writes an array of elements with the alignment, written by elems.
+func (e *encoder) array(elemAlign int, elems func()) {
+	e.uint32(0)
+	lenPos := len(e.buf) - 4
+	e.align(elemAlign)
+	start := len(e.buf)
+	elems()
+	binary.LittleEndian.PutUint32(e.buf[lenPos:], uint32(len(e.buf)-start))
+}
+
+// variant writes the value with its signature. Only the types this package
+// sends are supported.
+func (e *encoder) variant(v any) {
+	switch v := v.(type) {
+	case string:
+		e.signature("s")
+		e.string(v)
+	case objectPath:
+		e.signature("o")
+		e.string(string(v))
+	case signature:
+		e.signature("g")
+		e.signature(v)
+	case uint32:
+		e.signature("u")
+		e.uint32(v)
+	case bool:
+		e.signature("b")
+		e.bool(v)
+	default:
+		panic(fmt.Sprintf("dbus: unsupported variant type %T", v))
+	}
+}
+
+// vardict writes a dictionary of strings to variants, a{sv}, with the keys
+// in the order given.
+func (e *encoder) vardict(keys []string, values map[string]any) {
+	e.array(8, func() {
+		for _, key := range keys {
+			e.align(8)
+			e.string(key)
+			e.variant(values[key])
+		}
+	})
+}
+
+// encode returns the message in the wire format.
+func (m *message) encode() []byte {
+	type field struct {
+		code  byte
+		value any
+	}
+	var fields []field
+	if m.path != "" {
+		fields = append(fields, field{fieldPath, m.path})
+	}
+	if m.iface != "" {
+		fields = append(fields, field{fieldInterface, m.iface})
+	}
+	if m.member != "" {
+		fields = append(fields, field{fieldMember, m.member})
+	}
+	if m.errName != "" {
+		fields = append(fields, field{fieldErrorName, m.errName})
+	}
+	if m.replySerial != 0 {
+		fields = append(fields, field{fieldReplySerial, m.replySerial})
+	}
+	if m.dest != "" {
+		fields = append(fields, field{fieldDestination, m.dest})
+	}
+	if m.sender != "" {
+		fields = append(fields, field{fieldSender, m.sender})
+	}
+	if m.sig != "" {
+		fields = append(fields, field{fieldSignature, m.sig})
+	}
+
+	e := &encoder{}
+	e.byte('l')
+	e.byte(m.typ)
+	e.byte(0)
+	e.byte(1)
+	e.uint32(uint32(len(m.body)))
+	e.uint32(m.serial)
+	e.array(8, func() {
+		for _, f := range fields {
+			e.align(8)
+			e.byte(f.code)
+			e.variant(f.value)
+		}
+	})
+	e.align(8)
+	return append(e.buf, m.body...)
+}
+
+// decoder reads values in the D-Bus wire format, aligned relative to the
+// start of the buffer.
+type decoder struct {
+	buf []byte
+	pos int
+}
+
+var errTruncated = errors.New("message truncated")
+
+func (d *decoder) align(n int) error {
+	for d.pos%n != 0 {
+		d.pos++
+	}
+	if d.pos > len(d.buf) {
+		return errTruncated
+	}
+	return nil
+}
+
+func (d *decoder) next(n int) ([]byte, error) {
+	if d.pos+n > len(d.buf) || n < 0 {
+		return nil, errTruncated
+	}
+	b := d.buf[d.pos : d.pos+n]
+	d.pos += n
+	return b, nil
+}
+
+func (d *decoder) fixed(size int) (uint64, error) {
+	if err := d.align(size); err != nil {
+		return 0, err
+	}
+	b, err := d.next(size)
+	if err != nil {
+		return 0, err
+	}
+	switch size {
+	case 1:
+		return uint64(b[0]), nil
+	case 2:
+		return uint64(binary.LittleEndian.Uint16(b)), nil
+	case 4:
+		return uint64(binary.LittleEndian.Uint32(b)), nil
+	}
+	return binary.LittleEndian.Uint64(b), nil
+}
+
+func (d *decoder) string(lenSize int) (string, error) {
+	n, err := d.fixed(lenSize)
+	if err != nil {
+		return "", err
+	}
+	b, err := d.next(int(n) + 1)
+	if err != nil {
+		return "", err
+	}
+	return string(b[:n]), nil
+}
+
+// alignment returns the alignment of the type starting with the code.
+func alignment(code byte) int {
+	switch code {
+	case 'n', 'q':
+		return 2
+	case 'b', 'i', 'u', 'h', 's', 'o', 'a':
+		return 4
+	case 'x', 't', 'd', '(', '{':
+		return 8
+	}
+	return 1
+}
+
+// firstType splits the first complete type off the signature.
+func firstType(sig signature) (signature, signature, error) {
+	if sig == "" {
+		return "", "", fmt.Errorf("missing type in signature")
+	}
+	switch sig[0] {
+	case 'a':
+		elem, rest, err := firstType(sig[1:])
+		if err != nil {
+			return "", "", err
+		}
+		return sig[:len(elem)+1], rest, nil
+	case '(', '{':
+		closing := byte(')')
+		if sig[0] == '{' {
+			closing = '}'
+		}
+		inner := sig[1:]
+		for len(inner) > 0 && inner[0] != closing {
+			var err error
+			_, inner, err = firstType(inner)
+			if err != nil {
+				return "", "", err
+			}
+		}
+		if len(inner) == 0 {
+			return "", "", fmt.Errorf("unterminated %c in signature %q", sig[0], sig)
+		}
+		n := len(sig) - len(inner) + 1
+		return sig[:n], sig[n:], nil
+	}
+	return sig[:1], sig[1:], nil
+}
+
+// value reads a value of the complete type. Arrays of dictionary entries are
+// returned as maps, with string keys, other arrays and structs as slices,
+// and variants as their values.
+func (d *decoder) value(typ signature) (any, error) {
+	switch typ[0] {
+	case 'y':
+		v, err := d.fixed(1)
+		return byte(v), err
+	case 'b':
+		v, err := d.fixed(4)
+		return v != 0, err
+	case 'n', 'q':
+		v, err := d.fixed(2)
+		return uint16(v), err
+	case 'i', 'u', 'h':
+		v, err := d.fixed(4)
+		return uint32(v), err
+	case 'x', 't', 'd':
+		return d.fixed(8)
+	case 's':
+		return d.string(4)
+	case 'o':
+		s, err := d.string(4)
+		return objectPath(s), err
+	case 'g':
+		s, err := d.string(1)
+		return signature(s), err
+	case 'v':
+		sig, err := d.string(1)
+		if err != nil {
+			return nil, err
+		}
+		inner, rest, err := firstType(signature(sig))
+		if err != nil {
+			return nil, err
+		}
+		if rest != "" {
+			return nil, fmt.Errorf("variant with more than one type: %q", sig)
+		}
+		return d.value(inner)
+	case 'a':
+		n, err := d.fixed(4)
+		if err != nil {
+			return nil, err
+		}
+		elem := typ[1:]
+		if err := d.align(alignment(elem[0])); err != nil {
+			return nil, err
+		}
+		end := d.pos + int(n)
+		if end > len(d.buf) {
+			return nil, errTruncated
+		}
+		if elem[0] == '{' {
+			dict := make(map[string]any)
+			for d.pos < end {
+				entry, err := d.value(elem)
+				if err != nil {
+					return nil, err
+				}
+				kv := entry.([]any)
+				dict[fmt.Sprint(kv[0])] = kv[1]
+			}
+			return dict, nil
+		}
+		var values []any
+		for d.pos < end {
+			v, err := d.value(elem)
+			if err != nil {
+				return nil, err
+			}
+			values = append(values, v)
+		}
+		return values, nil
+	case '(', '{':
+		if err := d.align(8); err != nil {
+			return nil, err
+		}
+		fields := typ[1 : len(typ)-1]
+		var values []any
+		for fields != "" {
+			var field signature
+			var err error
+			field, fields, err = firstType(fields)
+			if err != nil {
+				return nil, err
+			}
+			v, err := d.value(field)
+			if err != nil {
+				return nil, err
+			}
+			values = append(values, v)
+		}
+		return values, nil
+	}
+	return nil, fmt.Errorf("unsupported type %q", typ)
+}
+
+// values reads the values of the signature, one for each complete type.
+func (d *decoder) values(sig signature) ([]any, error) {
+	var values []any
+	for sig != "" {
+		var typ signature
+		var err error
+		typ, sig, err = firstType(sig)
+		if err != nil {
+			return nil, err
+		}
+		v, err := d.value(typ)
+		if err != nil {
+			return nil, err
+		}
+		values = append(values, v)
+	}
+	return values, nil
+}
+
+// readMessage reads the next message from r.
+func readMessage(r io.Reader) (*message, error) {
+	fixed := make([]byte, 16)
+	if _, err := io.ReadFull(r, fixed); err != nil {
+		return nil, err
+	}
+	if fixed[0] != 'l' {
+		return nil, fmt.Errorf("unsupported byte order %q", fixed[0])
+	}
+	bodyLen := binary.LittleEndian.Uint32(fixed[4:])
+	fieldsLen := binary.LittleEndian.Uint32(fixed[12:])
+	headerLen := (16 + int(fieldsLen) + 7) &^ 7
+	if uint64(headerLen)+uint64(bodyLen) > maxMessageSize {
+		return nil, fmt.Errorf("message too large")
+	}
+	buf := make([]byte, headerLen+int(bodyLen))
+	copy(buf, fixed)
+	if _, err := io.ReadFull(r, buf[16:]); err != nil {
+		return nil, err
+	}
+
+	m := &message{
+		typ:    fixed[1],
+		serial: binary.LittleEndian.Uint32(fixed[8:]),
+		body:   buf[headerLen:],
+	}
+	d := &decoder{buf: buf[:16+fieldsLen], pos: 12}
+	fields, err := d.value("a(yv)")
+	if err != nil {
+		return nil, fmt.Errorf("invalid header: %w", err)
+	}
+	for _, f := range fields.([]any) {
+		kv := f.([]any)
+		code, value := kv[0].(byte), kv[1]
+		switch code {
+		case fieldPath:
+			m.path, _ = value.(objectPath)
+		case fieldInterface:
+			m.iface, _ = value.(string)
+		case fieldMember:
+			m.member, _ = value.(string)
+		case fieldErrorName:
+			m.errName, _ = value.(string)
+		case fieldReplySerial:
+			m.replySerial, _ = value.(uint32)
+		case fieldDestination:
+			m.dest, _ = value.(string)
+		case fieldSender:
+			m.sender, _ = value.(string)
+		case fieldSignature:
+			m.sig, _ = value.(signature)
+		}
+	}
+	return m, nil
+}
+
+// busConn is a connection to a message bus.
+type busConn struct {
+	conn   net.Conn
+	r      *bufio.Reader
+	serial uint32
+	// the unique name of the connection on the bus
+	name string
+	// signals that arrived while waiting for a reply
+	signals []*message
+}
+
+// socketAddress returns the path of the Unix socket of the first supported
+// bus address in addrs, with a leading @ for abstract sockets.
+func socketAddress(addrs string) (string, error) {
+	for addr := range strings.SplitSeq(addrs, ";") {
+		transport, params, ok := strings.Cut(addr, ":")
+		if !ok || transport != "unix" {
+			continue
+		}
+		for param := range strings.SplitSeq(params, ",") {
+			key, value, _ := strings.Cut(param, "=")
+			switch key {
+			case "path":
+				return unescape(value)
+			case "abstract":
+				abstract, err := unescape(value)
+				return "@" + abstract, err
+			}
+		}
+	}
+	return "", fmt.Errorf("no supported address in %q", addrs)
+}
+
+// unescape decodes the %xx escapes of an address value.
+func unescape(value string) (string, error) {
+	var b strings.Builder
+	for i := 0; i < len(value); i++ {
+		if value[i] != '%' {
+			b.WriteByte(value[i])
+			continue
+		}
+		if i+2 >= len(value) {
+			return "", fmt.Errorf("invalid escape in address value %q", value)
+		}
+		decoded, err := hex.DecodeString(value[i+1 : i+3])
+		if err != nil {
+			return "", fmt.Errorf("invalid escape in address value %q", value)
+		}
+		b.Write(decoded)
+		i += 2
+	}
+	return b.String(), nil
+}
+
+// dialBus connects to the bus at the address, authenticates as the user
+// running the process and registers on the bus.
+func dialBus(addr string) (*busConn, error) {
+	socket, err := socketAddress(addr)
+	if err != nil {
+		return nil, err
+	}
+	conn, err := net.DialTimeout("unix", socket, dialTimeout)
+	if err != nil {
+		return nil, fmt.Errorf("failed connecting to the bus: %w", err)
+	}
+	c := &busConn{conn: conn, r: bufio.NewReader(conn)}
+	if err := c.auth(); err != nil {
+		_ = conn.Close()
+		return nil, fmt.Errorf("failed authenticating to the bus: %w", err)
+	}
+	reply, err := c.call("org.freedesktop.DBus", "/org/freedesktop/DBus", "org.freedesktop.DBus", "Hello", "", nil)
+	if err != nil {
+		_ = conn.Close()
+		return nil, err
+	}
+	values, err := (&decoder{buf: reply.body}).values(reply.sig)
+	if err != nil || len(values) != 1 {
+		_ = conn.Close()
+		return nil, fmt.Errorf("invalid reply to Hello")
+	}
+	c.name, _ = values[0].(string)
+	return c, nil
+}
+
+func (c *busConn) auth() error {
+	if err := c.conn.SetDeadline(time.Now().Add(dialTimeout)); err != nil {
+		return err
+	}
+	uid := hex.EncodeToString([]byte(strconv.Itoa(os.Getuid())))
+	if _, err := io.WriteString(c.conn, "\x00AUTH EXTERNAL "+uid+"\r\n"); err != nil {
+		return err
+	}
+	line, err := c.r.ReadString('\n')
+	if err != nil {
+		return err
+	}
+	if !strings.HasPrefix(line, "OK ") {
+		return fmt.Errorf("rejected: %s", strings.TrimSpace(line))
+	}
+	if _, err := io.WriteString(c.conn, "BEGIN\r\n"); err != nil {
+		return err
+	}
+	return c.conn.SetDeadline(time.Time{})
+}
+
+// send writes a method call and returns its serial.
+func (c *busConn) send(dest string, path objectPath, iface, member string, sig signature, body []byte) (uint32, error) {
+	c.serial++
+	m := &message{
+		typ:    msgMethodCall,
+		serial: c.serial,
+		path:   path,
+		iface:  iface,
+		member: member,
+		dest:   dest,
+		sig:    sig,
+		body:   body,
+	}
+	_, err := c.conn.Write(m.encode())
+	return c.serial, err
+}
+
+// call calls the method and waits for its reply. Signals that arrive in the
+// meantime are kept for [busConn.waitSignal].
+func (c *busConn) call(dest string, path objectPath, iface, member string, sig signature, body []byte) (*message, error) {
+	serial, err := c.send(dest, path, iface, member, sig, body)
+	if err != nil {
+		return nil, err
+	}
+	for {
+		m, err := readMessage(c.r)
+		if err != nil {
+			return nil, err
+		}
+		switch {
+		case m.typ == msgSignal:
+			c.signals = append(c.signals, m)
+		case m.replySerial != serial:
+		case m.typ == msgError:
+			return nil, fmt.Errorf("%s.%s failed: %s%s", iface, member, m.errName, errorText(m))
+		case m.typ == msgMethodReturn:
+			return m, nil
+		}
+	}
+}
+
+// errorText returns the text of an error reply, after a colon, if it has one.
+func errorText(m *message) string {
+	if !strings.HasPrefix(string(m.sig), "s") {
+		return ""
+	}
+	values, err := (&decoder{buf: m.body}).values("s")
+	if err != nil {
+		return ""
+	}
+	return ": " + values[0].(string)
+}
+
+// waitSignal returns the next signal matching the path, interface and
+// member, waiting until the deadline.
+func (c *busConn) waitSignal(path objectPath, iface, member string, deadline time.Time) (*message, error) {
+	matches := func(m *message) bool {
+		return m.typ == msgSignal && m.path == path && m.iface == iface && m.member == member
+	}
+	for idx, m := range c.signals {
+		if matches(m) {
+			c.signals = append(c.signals[:idx], c.signals[idx+1:]...)
+			return m, nil
+		}
+	}
+	if err := c.conn.SetReadDeadline(deadline); err != nil {
+		return nil, err
+	}
+	for {
+		m, err := readMessage(c.r)
+		if err != nil {
+			return nil, err
+		}
+		if matches(m) {
+			return m, nil
+		}
+	}
+}
+
+func (c *busConn) close() error {
+	return c.conn.Close()
+}
@@ -0,0 +1,60 @@
+package dbus
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSocketAddress(t *testing.T) {
+	tests := []struct {
+		address string
+		socket  string
+		err     bool
+	}{
+		{"unix:path=/run/user/1000/bus", "/run/user/1000/bus", false},
+		{"unix:abstract=/tmp/dbus-x,guid=abc", "@/tmp/dbus-x", false},
+		{"tcp:host=localhost,port=1;unix:path=/tmp/a%20b", "/tmp/a b", false},
+		{"tcp:host=localhost,port=1", "", true},
+		{"unix:path=/tmp/%zz", "", true},
+	}
+	for _, test := range tests {
+		socket, err := socketAddress(test.address)
+		if test.err {
+			assert.Error(t, err, test.address)
+			continue
+		}
+		require.NoError(t, err, test.address)
+		assert.Equal(t, test.socket, socket)
+	}
+}
+
+func TestMessageRoundTrip(t *testing.T) {
+	e := &encoder{}
+	e.string(logindSessionIface)
+	e.vardict([]string{"a", "b", "c"}, map[string]any{"a": "x", "b": true, "c": uint32(7)})
+	e.array(4, func() {})
+	m := &message{typ: msgSignal, serial: 3, path: testSession, iface: propertiesIface, member: "PropertiesChanged", sig: "sa{sv}as", body: e.buf}
+
+	decoded, err := readMessage(strings.NewReader(string(m.encode())))
+	require.NoError(t, err)
+	assert.Equal(t, m, decoded)
+	values, err := (&decoder{buf: decoded.body}).values(decoded.sig)
+	require.NoError(t, err)
+	assert.Equal(t, []any{logindSessionIface, map[string]any{"a": "x", "b": true, "c": uint32(7)}, []any(nil)}, values)
+}
+
+func TestFirstType(t *testing.T) {
+	typ, rest, err := firstType("a{sv}u")
+	require.NoError(t, err)
+	assert.Equal(t, signature("a{sv}"), typ)
+	assert.Equal(t, signature("u"), rest)
+	typ, rest, err = firstType("(ya(ss))")
+	require.NoError(t, err)
+	assert.Equal(t, signature("(ya(ss))"), typ)
+	assert.Equal(t, signature(""), rest)
+	_, _, err = firstType("(ss")
+	assert.Error(t, err)
+}
@@ -0,0 +1,199 @@
+package dbus
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+
+	"github.com/achilleas-k/gg13/internal/i18n"
+)
+
+const (
+	logindName         = "org.freedesktop.login1"
+	logindPath         = objectPath("/org/freedesktop/login1")
+	logindManagerIface = "org.freedesktop.login1.Manager"
+	logindUserIface    = "org.freedesktop.login1.User"
+	logindSessionIface = "org.freedesktop.login1.Session"
+	propertiesIface    = "org.freedesktop.DBus.Properties"
+
+	// defaultSystemBus is the address of the system bus when
+	// DBUS_SYSTEM_BUS_ADDRESS isn't set.
+	defaultSystemBus = "unix:path=/var/run/dbus/system_bus_socket"
+)
+
+// ErrNoGraphicalSession is returned when the user has no graphical session
+// whose lock state could be watched.
+var ErrNoGraphicalSession = errors.New("no graphical session")
+
+// LockWatcher reports when the graphical session of the user is locked and
+// unlocked. The state comes from the LockedHint property of the session in
+// logind, which screen lockers set, so it works under any desktop whose
+// locker does, without a session bus. The session is the one that was the
+// user's display session when watching started.
+type LockWatcher struct {
+	bus     *busConn
+	session objectPath
+	changes chan bool
+
+	closeOnce sync.Once
+	done      chan struct{}
+}
+
+// WatchScreenLock starts watching the lock state of the graphical session of
+// the user running the process, on the system bus of DBUS_SYSTEM_BUS_ADDRESS
+// or the default one.
+func WatchScreenLock() (*LockWatcher, error) {
+	address := os.Getenv("DBUS_SYSTEM_BUS_ADDRESS")
+	if address == "" {
+		address = defaultSystemBus
+	}
+	return watchScreenLock(address, uint32(os.Getuid()))
+}
+
+func watchScreenLock(address string, uid uint32) (*LockWatcher, error) {
+	bus, err := dialBus(address)
+	if err != nil {
+		return nil, err
+	}
+	w := &LockWatcher{
+		bus:     bus,
+		changes: make(chan bool, 1),
+		done:    make(chan struct{}),
+	}
+	locked, err := w.start(uid)
+	if err != nil {
+		_ = bus.close()
+		return nil, err
+	}
+	// the current state is the first change
+	w.changes <- locked
+	go w.watch(locked)
+	return w, nil
+}
+
+// start finds the session and subscribes to its changes, and returns whether
+// it's locked.
+func (w *LockWatcher) start(uid uint32) (bool, error) {
+	e := &encoder{}
+	e.uint32(uid)
+	reply, err := w.bus.call(logindName, logindPath, logindManagerIface, "GetUser", "u", e.buf)
+	if err != nil {
+		return false, err
+	}
+	values, err := (&decoder{buf: reply.body}).values(reply.sig)
+	if err != nil || len(values) != 1 {
+		return false, fmt.Errorf("invalid reply to GetUser")
+	}
+	user, _ := values[0].(objectPath)
+
+	// the display session is a struct of its ID and its object path, with
+	// the path "/" if there's none
+	display, err := w.property(user, logindUserIface, "Display")
+	if err != nil {
+		return false, err
+	}
+	if fields, ok := display.([]any); ok && len(fields) == 2 {
+		w.session, _ = fields[1].(objectPath)
+	}
+	if w.session == "" || w.session == "/" {
+		return false, ErrNoGraphicalSession
+	}
+
+	// subscribed before reading the state, to not miss a change in between
+	match := fmt.Sprintf("type='signal',sender='%s',interface='%s',member='PropertiesChanged',path='%s'", logindName, propertiesIface, w.session)
+	e = &encoder{}
+	e.string(match)
+	if _, err := w.bus.call("org.freedesktop.DBus", "/org/freedesktop/DBus", "org.freedesktop.DBus", "AddMatch", "s", e.buf); err != nil {
+		return false, err
+	}
+	hint, err := w.property(w.session, logindSessionIface, "LockedHint")
+	if err != nil {
+		return false, err
+	}
+	locked, ok := hint.(bool)
+	if !ok {
+		return false, fmt.Errorf("invalid LockedHint of session %s", w.session)
+	}
+	return locked, nil
+}
+
+// property returns the value of the property of the logind object.
+func (w *LockWatcher) property(path objectPath, iface, name string) (any, error) {
+	e := &encoder{}
+	e.string(iface)
+	e.string(name)
+	reply, err := w.bus.call(logindName, path, propertiesIface, "Get", "ss", e.buf)
+	if err != nil {
+		return nil, err
+	}
+	values, err := (&decoder{buf: reply.body}).values(reply.sig)
+	if err != nil || len(values) != 1 {
+		return nil, fmt.Errorf("invalid value of %s.%s", iface, name)
+	}
+	return values[0], nil
+}
+
+// watch reads the signals of the session until the watcher is closed and
+// sends the lock state whenever it changes.
+func (w *LockWatcher) watch(locked bool) {
+	defer close(w.changes)
+	for {
+		var m *message
+		if len(w.bus.signals) > 0 {
+			m, w.bus.signals = w.bus.signals[0], w.bus.signals[1:]
+		} else {
+			var err error
+			if m, err = readMessage(w.bus.r); err != nil {
+				select {
+				case <-w.done:
+				default:
+					fmt.Fprintln(os.Stderr, i18n.Sprintf("screen lock: lost the connection to the system bus: %s", err))
+				}
+				return
+			}
+		}
+		hint, ok := lockedHint(m, w.session)
+		if !ok || hint == locked {
+			continue
+		}
+		locked = hint
+		select {
+		case w.changes <- locked:
+		case <-w.done:
+			return
+		}
+	}
+}
+
+// lockedHint returns the new LockedHint in a PropertiesChanged signal of the
+// session, if it has one.
+func lockedHint(m *message, session objectPath) (bool, bool) {
+	if m.typ != msgSignal || m.path != session || m.iface != propertiesIface || m.member != "PropertiesChanged" {
+		return false, false
+	}
+	values, err := (&decoder{buf: m.body}).values(m.sig)
+	if err != nil || len(values) < 2 || values[0] != logindSessionIface {
+		return false, false
+	}
+	changed, _ := values[1].(map[string]any)
+	locked, ok := changed["LockedHint"].(bool)
+	return locked, ok
+}
+
+// Changes returns the channel that the lock state is sent on, starting with
+// the current state, and then every time it changes. It's closed when the
+// watcher stops, after it's closed or if the connection to the bus is lost.
+func (w *LockWatcher) Changes() <-chan bool {
+	return w.changes
+}
+
+// Close stops watching.
+func (w *LockWatcher) Close() error {
+	var err error
+	w.closeOnce.Do(func() {
+		close(w.done)
+		err = w.bus.close()
+	})
+	return err
+}
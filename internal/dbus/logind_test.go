@@ -0,0 +1,156 @@
+package dbus
+
+import (
+	"bufio"
+	"net"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testSession = objectPath("/org/freedesktop/login1/session/_32")
+
+// fakeLogind is a system bus with logind on it, answering a single
+// connection. The user's display session is display, and its LockedHint
+// starts as locked and changes to each value sent on hints.
+type fakeLogind struct {
+	listener net.Listener
+	display  objectPath
+	locked   bool
+	hints    chan bool
+	done     chan error
+}
+
+func startFakeLogind(t *testing.T, display objectPath, locked bool) (*fakeLogind, string) {
+	socket := filepath.Join(t.TempDir(), "system_bus_socket")
+	listener, err := net.Listen("unix", socket)
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = listener.Close() })
+	bus := &fakeLogind{listener: listener, display: display, locked: locked, hints: make(chan bool), done: make(chan error, 1)}
+	go func() { bus.done <- bus.serve() }()
+	return bus, "unix:path=" + socket
+}
+
+func (b *fakeLogind) serve() error {
+	conn, err := b.listener.Accept()
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return err
+		}
+		if strings.HasPrefix(line, "\x00AUTH EXTERNAL ") {
+			_, _ = conn.Write([]byte("OK 0123456789abcdef\r\n"))
+		}
+		if line == "BEGIN\r\n" {
+			break
+		}
+	}
+
+	var serial uint32
+	write := func(m *message) error {
+		serial++
+		m.serial = serial
+		_, err := conn.Write(m.encode())
+		return err
+	}
+	reply := func(to *message, sig signature, body []byte) error {
+		return write(&message{typ: msgMethodReturn, replySerial: to.serial, sig: sig, body: body})
+	}
+	for {
+		m, err := readMessage(r)
+		if err != nil {
+			return err
+		}
+		e := &encoder{}
+		switch m.member {
+		case "Hello":
+			e.string(":1.42")
+			err = reply(m, "s", e.buf)
+		case "GetUser":
+			e.string("/org/freedesktop/login1/user/_1000")
+			err = reply(m, "o", e.buf)
+		case "Get":
+			values, _ := (&decoder{buf: m.body}).values(m.sig)
+			switch values[1] {
+			case "Display":
+				// a variant of the struct (so)
+				e.signature("(so)")
+				e.align(8)
+				e.string("32")
+				e.string(string(b.display))
+			case "LockedHint":
+				e.variant(b.locked)
+			}
+			if err := reply(m, "v", e.buf); err != nil {
+				return err
+			}
+			if values[1] != "LockedHint" {
+				continue
+			}
+			// the state changes after the client read it
+			for locked := range b.hints {
+				e := &encoder{}
+				e.string(logindSessionIface)
+				e.vardict([]string{"LockedHint"}, map[string]any{"LockedHint": locked})
+				e.array(4, func() {})
+				signal := &message{typ: msgSignal, path: testSession, iface: propertiesIface, member: "PropertiesChanged", sig: "sa{sv}as", body: e.buf}
+				if err := write(signal); err != nil {
+					return err
+				}
+			}
+			return nil
+		case "AddMatch":
+			err = reply(m, "", nil)
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+func receive(t *testing.T, changes <-chan bool) bool {
+	select {
+	case locked, ok := <-changes:
+		require.True(t, ok, "changes closed")
+		return locked
+	case <-time.After(time.Second):
+		t.Fatal("no lock state received")
+		return false
+	}
+}
+
+func TestWatchScreenLock(t *testing.T) {
+	bus, address := startFakeLogind(t, testSession, false)
+	watcher, err := watchScreenLock(address, 1000)
+	require.NoError(t, err)
+	defer watcher.Close()
+
+	assert.False(t, receive(t, watcher.Changes()), "current state")
+	bus.hints <- true
+	assert.True(t, receive(t, watcher.Changes()))
+	// the same state again isn't a change
+	bus.hints <- true
+	bus.hints <- false
+	assert.False(t, receive(t, watcher.Changes()))
+
+	close(bus.hints)
+	require.NoError(t, <-bus.done)
+	_, ok := <-watcher.Changes()
+	assert.False(t, ok, "changes closed when the connection is lost")
+	assert.NoError(t, watcher.Close())
+}
+
+func TestWatchScreenLockNoSession(t *testing.T) {
+	_, address := startFakeLogind(t, "/", false)
+	_, err := watchScreenLock(address, 1000)
+	assert.ErrorIs(t, err, ErrNoGraphicalSession)
+}
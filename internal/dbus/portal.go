@@ -0,0 +1,111 @@
+package dbus
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	portalName      = "org.freedesktop.portal.Desktop"
+	portalPath      = objectPath("/org/freedesktop/portal/desktop")
+	screenshotIface = "org.freedesktop.portal.Screenshot"
+	requestIface    = "org.freedesktop.portal.Request"
+
+	// PortalResponseTimeout limits how long to wait for the portal to
+	// answer, which includes the time the user takes in its dialogs.
+	PortalResponseTimeout = 2 * time.Minute
+)
+
+// ErrScreenshotCancelled is returned when the user cancelled the screenshot
+// in the dialog of the portal.
+var ErrScreenshotCancelled = errors.New("screenshot cancelled")
+
+// tokens makes the handle tokens of the requests unique within the process.
+var tokens atomic.Uint32
+
+// Portal calls the XDG desktop portal on the session bus.
+type Portal struct {
+	// the address of the session bus
+	address string
+	// how long to wait for the response
+	timeout time.Duration
+}
+
+// NewPortal returns a [Portal] for the session bus of
+// DBUS_SESSION_BUS_ADDRESS.
+func NewPortal() (*Portal, error) {
+	address := os.Getenv("DBUS_SESSION_BUS_ADDRESS")
+	if address == "" {
+		return nil, fmt.Errorf("no session bus: DBUS_SESSION_BUS_ADDRESS isn't set")
+	}
+	return &Portal{address: address, timeout: PortalResponseTimeout}, nil
+}
+
+// Screenshot asks the portal for a screenshot of the whole screen and
+// returns the path of the image it saved. If interactive is set, the portal
+// lets the user choose what to capture first. The portal may ask the user
+// for permission the first time either way.
+func (c *Portal) Screenshot(interactive bool) (string, error) {
+	bus, err := dialBus(c.address)
+	if err != nil {
+		return "", err
+	}
+	defer func() { _ = bus.close() }()
+
+	// the portal answers on a request object whose path is derived from
+	// the unique name and a token, so the match is added before the call
+	// to not miss a fast response
+	token := fmt.Sprintf("gg13_%d_%d", os.Getpid(), tokens.Add(1))
+	sender := strings.ReplaceAll(strings.TrimPrefix(bus.name, ":"), ".", "_")
+	request := objectPath("/org/freedesktop/portal/desktop/request/" + sender + "/" + token)
+	match := fmt.Sprintf("type='signal',interface='%s',member='Response',path='%s'", requestIface, request)
+	e := &encoder{}
+	e.string(match)
+	if _, err := bus.call("org.freedesktop.DBus", "/org/freedesktop/DBus", "org.freedesktop.DBus", "AddMatch", "s", e.buf); err != nil {
+		return "", err
+	}
+
+	e = &encoder{}
+	e.string("")
+	e.vardict([]string{"handle_token", "interactive"}, map[string]any{
+		"handle_token": token,
+		"interactive":  interactive,
+	})
+	if _, err := bus.call(portalName, portalPath, screenshotIface, "Screenshot", "sa{sv}", e.buf); err != nil {
+		return "", err
+	}
+
+	response, err := bus.waitSignal(request, requestIface, "Response", time.Now().Add(c.timeout))
+	if err != nil {
+		return "", fmt.Errorf("no response from the screenshot portal: %w", err)
+	}
+	return parseResponse(response)
+}
+
+// parseResponse returns the path of the screenshot in the Response signal.
+func parseResponse(m *message) (string, error) {
+	values, err := (&decoder{buf: m.body}).values(m.sig)
+	if err != nil || len(values) != 2 {
+		return "", fmt.Errorf("invalid response from the screenshot portal")
+	}
+	code, _ := values[0].(uint32)
+	results, _ := values[1].(map[string]any)
+	switch code {
+	case 0:
+	case 1:
+		return "", ErrScreenshotCancelled
+	default:
+		return "", fmt.Errorf("the screenshot portal failed")
+	}
+	uri, _ := results["uri"].(string)
+	u, err := url.Parse(uri)
+	if err != nil || u.Scheme != "file" {
+		return "", fmt.Errorf("unexpected screenshot uri %q", uri)
+	}
+	return u.Path, nil
+}
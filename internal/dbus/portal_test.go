@@ -0,0 +1,124 @@
+package dbus
+
+import (
+	"bufio"
+	"net"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeBus is a session bus with the portal on it, answering a single
+// connection with the response code and results.
+type fakeBus struct {
+	listener net.Listener
+	code     uint32
+	uri      string
+	// the options of the Screenshot call
+	options map[string]any
+	done    chan error
+}
+
+func startFakeBus(t *testing.T, code uint32, uri string) (*fakeBus, string) {
+	socket := filepath.Join(t.TempDir(), "bus")
+	listener, err := net.Listen("unix", socket)
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = listener.Close() })
+	bus := &fakeBus{listener: listener, code: code, uri: uri, done: make(chan error, 1)}
+	go func() { bus.done <- bus.serve() }()
+	return bus, "unix:path=" + socket
+}
+
+func (b *fakeBus) serve() error {
+	conn, err := b.listener.Accept()
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return err
+		}
+		if strings.HasPrefix(line, "\x00AUTH EXTERNAL ") {
+			_, _ = conn.Write([]byte("OK 0123456789abcdef\r\n"))
+		}
+		if line == "BEGIN\r\n" {
+			break
+		}
+	}
+
+	var serial uint32
+	reply := func(to *message, sig signature, body []byte) error {
+		serial++
+		m := &message{typ: msgMethodReturn, serial: serial, replySerial: to.serial, sig: sig, body: body}
+		_, err := conn.Write(m.encode())
+		return err
+	}
+	var request objectPath
+	for {
+		m, err := readMessage(r)
+		if err != nil {
+			return err
+		}
+		switch m.member {
+		case "Hello":
+			e := &encoder{}
+			e.string(":1.42")
+			if err := reply(m, "s", e.buf); err != nil {
+				return err
+			}
+		case "AddMatch":
+			values, err := (&decoder{buf: m.body}).values(m.sig)
+			if err != nil {
+				return err
+			}
+			match := values[0].(string)
+			request = objectPath(match[strings.Index(match, "path='")+6 : len(match)-1])
+			if err := reply(m, "", nil); err != nil {
+				return err
+			}
+		case "Screenshot":
+			values, err := (&decoder{buf: m.body}).values(m.sig)
+			if err != nil {
+				return err
+			}
+			b.options = values[1].(map[string]any)
+			e := &encoder{}
+			e.string(string(request))
+			if err := reply(m, "o", e.buf); err != nil {
+				return err
+			}
+			e = &encoder{}
+			e.uint32(b.code)
+			e.vardict([]string{"uri"}, map[string]any{"uri": b.uri})
+			serial++
+			signal := &message{typ: msgSignal, serial: serial, path: request, iface: requestIface, member: "Response", sig: "ua{sv}", body: e.buf}
+			_, err = conn.Write(signal.encode())
+			return err
+		}
+	}
+}
+
+func TestScreenshot(t *testing.T) {
+	bus, address := startFakeBus(t, 0, "file:///home/user/Pictures/Screenshot%20from%20today.png")
+	client := &Portal{address: address, timeout: time.Second}
+	path, err := client.Screenshot(false)
+	require.NoError(t, err)
+	require.NoError(t, <-bus.done)
+	assert.Equal(t, "/home/user/Pictures/Screenshot from today.png", path)
+	assert.Equal(t, false, bus.options["interactive"])
+	assert.True(t, strings.HasPrefix(bus.options["handle_token"].(string), "gg13_"))
+}
+
+func TestScreenshotCancelled(t *testing.T) {
+	_, address := startFakeBus(t, 1, "")
+	client := &Portal{address: address, timeout: time.Second}
+	_, err := client.Screenshot(true)
+	assert.ErrorIs(t, err, ErrScreenshotCancelled)
+}
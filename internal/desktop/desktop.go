@@ -0,0 +1,146 @@
+// Package desktop turns window management operations, like focusing a window
+// or switching workspaces, into the commands that run them on the desktop, in
+// a registry of named backends for the tools of each window manager or
+// compositor.
+package desktop
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// OpKind is what a window management operation does.
+type OpKind string
+
+const (
+	// OpFocus focuses a window of an application, by its class (or app ID
+	// on Wayland).
+	OpFocus OpKind = "focus"
+	// OpWorkspace switches to a workspace, by its number from 1.
+	OpWorkspace OpKind = "workspace"
+)
+
+// Op is a window management operation.
+type Op struct {
+	Kind OpKind
+	// Class is the window class that OpFocus focuses
+	Class string
+	// Workspace is the number of the workspace that OpWorkspace switches
+	// to, from 1
+	Workspace int
+}
+
+func (op Op) String() string {
+	if op.Kind == OpWorkspace {
+		return fmt.Sprintf("%s %d", op.Kind, op.Workspace)
+	}
+	return fmt.Sprintf("%s %s", op.Kind, op.Class)
+}
+
+// ParseOp parses an operation written as "focus <class>" or "workspace
+// <number>".
+func ParseOp(str string) (Op, error) {
+	fields := strings.Fields(str)
+	if len(fields) != 2 {
+		return Op{}, fmt.Errorf("invalid operation %q: expected focus <class> or workspace <number>", str)
+	}
+	switch OpKind(fields[0]) {
+	case OpFocus:
+		return Op{Kind: OpFocus, Class: fields[1]}, nil
+	case OpWorkspace:
+		num, err := strconv.Atoi(fields[1])
+		if err != nil || num < 1 {
+			return Op{}, fmt.Errorf("workspace must be a number from 1: %s", fields[1])
+		}
+		return Op{Kind: OpWorkspace, Workspace: num}, nil
+	}
+	return Op{}, fmt.Errorf("unknown operation: %s", fields[0])
+}
+
+// Backend returns the command, and its arguments, that runs the operation
+// on a desktop.
+type Backend func(op Op) []string
+
+var (
+	registryMu sync.RWMutex
+	registry   = make(map[string]Backend)
+)
+
+// Register makes a backend available under the name. It panics if the name is
+// already registered.
+func Register(name string, backend Backend) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	if _, exists := registry[name]; exists {
+		panic(fmt.Sprintf("desktop: backend %q registered twice", name))
+	}
+	registry[name] = backend
+}
+
+// Names returns the names of the registered backends, sorted.
+func Names() []string {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	return slices.Sorted(maps.Keys(registry))
+}
+
+// Validate returns an error if no backend is registered under the name.
+func Validate(name string) error {
+	registryMu.RLock()
+	_, ok := registry[name]
+	registryMu.RUnlock()
+	if !ok {
+		return fmt.Errorf("unknown desktop %q: available desktops: %s", name, strings.Join(Names(), ", "))
+	}
+	return nil
+}
+
+// Command returns the command that runs the operation with the named backend.
+func Command(name string, op Op) ([]string, error) {
+	if err := Validate(name); err != nil {
+		return nil, err
+	}
+	registryMu.RLock()
+	backend := registry[name]
+	registryMu.RUnlock()
+	return backend(op), nil
+}
+
+// Detect returns the name of the backend for the desktop of the session,
+// from its environment variables, looked up with getenv.
+func Detect(getenv func(string) string) string {
+	switch {
+	case getenv("SWAYSOCK") != "":
+		return "sway"
+	case getenv("HYPRLAND_INSTANCE_SIGNATURE") != "":
+		return "hyprland"
+	}
+	return "x11"
+}
+
+func init() {
+	// wmctrl works with the window managers of X11, which number the
+	// desktops from 0
+	Register("x11", func(op Op) []string {
+		if op.Kind == OpWorkspace {
+			return []string{"wmctrl", "-s", strconv.Itoa(op.Workspace - 1)}
+		}
+		return []string{"wmctrl", "-x", "-a", op.Class}
+	})
+	Register("sway", func(op Op) []string {
+		if op.Kind == OpWorkspace {
+			return []string{"swaymsg", "workspace", "number", strconv.Itoa(op.Workspace)}
+		}
+		return []string{"swaymsg", fmt.Sprintf("[app_id=%q] focus", op.Class)}
+	})
+	Register("hyprland", func(op Op) []string {
+		if op.Kind == OpWorkspace {
+			return []string{"hyprctl", "dispatch", "workspace", strconv.Itoa(op.Workspace)}
+		}
+		return []string{"hyprctl", "dispatch", "focuswindow", "class:" + op.Class}
+	})
+}
@@ -0,0 +1,77 @@
+package desktop_test
+
+import (
+	"testing"
+
+	"github.com/achilleas-k/gg13/internal/desktop"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseOp(t *testing.T) {
+	op, err := desktop.ParseOp("focus firefox")
+	require.NoError(t, err)
+	assert.Equal(t, desktop.Op{Kind: desktop.OpFocus, Class: "firefox"}, op)
+	assert.Equal(t, "focus firefox", op.String())
+
+	op, err = desktop.ParseOp(" workspace  3 ")
+	require.NoError(t, err)
+	assert.Equal(t, desktop.Op{Kind: desktop.OpWorkspace, Workspace: 3}, op)
+	assert.Equal(t, "workspace 3", op.String())
+
+	for str, expectedErr := range map[string]string{
+		"focus":           `invalid operation "focus": expected focus <class> or workspace <number>`,
+		"focus a b":       `invalid operation "focus a b": expected focus <class> or workspace <number>`,
+		"workspace 0":     "workspace must be a number from 1: 0",
+		"workspace next":  "workspace must be a number from 1: next",
+		"minimise window": "unknown operation: minimise",
+	} {
+		_, err := desktop.ParseOp(str)
+		assert.EqualError(t, err, expectedErr, str)
+	}
+}
+
+func TestCommand(t *testing.T) {
+	focus := desktop.Op{Kind: desktop.OpFocus, Class: "firefox"}
+	workspace := desktop.Op{Kind: desktop.OpWorkspace, Workspace: 2}
+
+	testCases := map[string]struct {
+		focus     []string
+		workspace []string
+	}{
+		"x11": {
+			focus:     []string{"wmctrl", "-x", "-a", "firefox"},
+			workspace: []string{"wmctrl", "-s", "1"},
+		},
+		"sway": {
+			focus:     []string{"swaymsg", `[app_id="firefox"] focus`},
+			workspace: []string{"swaymsg", "workspace", "number", "2"},
+		},
+		"hyprland": {
+			focus:     []string{"hyprctl", "dispatch", "focuswindow", "class:firefox"},
+			workspace: []string{"hyprctl", "dispatch", "workspace", "2"},
+		},
+	}
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			cmd, err := desktop.Command(name, focus)
+			require.NoError(t, err)
+			assert.Equal(t, tc.focus, cmd)
+			cmd, err = desktop.Command(name, workspace)
+			require.NoError(t, err)
+			assert.Equal(t, tc.workspace, cmd)
+		})
+	}
+
+	_, err := desktop.Command("cde", focus)
+	assert.EqualError(t, err, `unknown desktop "cde": available desktops: hyprland, sway, x11`)
+}
+
+func TestDetect(t *testing.T) {
+	env := func(vars map[string]string) func(string) string {
+		return func(key string) string { return vars[key] }
+	}
+	assert.Equal(t, "x11", desktop.Detect(env(nil)))
+	assert.Equal(t, "sway", desktop.Detect(env(map[string]string{"SWAYSOCK": "/run/sway.sock"})))
+	assert.Equal(t, "hyprland", desktop.Detect(env(map[string]string{"HYPRLAND_INSTANCE_SIGNATURE": "abc"})))
+}
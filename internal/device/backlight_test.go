@@ -0,0 +1,152 @@
+package device
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newTestDevice returns a device whose output queue records the backlight
+// colours written instead of sending them to a real device.
+func newTestDevice(t *testing.T) (*G13Device, func() [][3]uint8) {
+	t.Helper()
+
+	var mu sync.Mutex
+	var written [][3]uint8
+	d := &G13Device{}
+	d.queue = newOutputQueue(func(kind outputKind, data []byte) error {
+		if kind == outputBacklight {
+			mu.Lock()
+			defer mu.Unlock()
+			written = append(written, [3]uint8{data[1], data[2], data[3]})
+		}
+		return nil
+	})
+	t.Cleanup(d.queue.stop)
+
+	return d, func() [][3]uint8 {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([][3]uint8(nil), written...)
+	}
+}
+
+// currentBacklight returns the colour that should be showing: the override if
+// one is active, otherwise the colour set with SetBacklightColour.
+func (d *G13Device) currentBacklight() [3]uint8 {
+	d.backlightMu.Lock()
+	defer d.backlightMu.Unlock()
+	if d.backlightOverride != nil {
+		return *d.backlightOverride
+	}
+	return d.backlight
+}
+
+func TestBacklightOverride(t *testing.T) {
+	d, written := newTestDevice(t)
+
+	require.NoError(t, d.SetBacklightColour(10, 20, 30))
+	_, err := d.OverrideBacklightColour(255, 0, 0, 0)
+	require.NoError(t, err)
+	assert.Equal(t, [3]uint8{255, 0, 0}, d.currentBacklight())
+
+	// setting the colour while an override is active keeps the override
+	// showing
+	require.NoError(t, d.SetBacklightColour(40, 50, 60))
+	assert.Equal(t, [3]uint8{255, 0, 0}, d.currentBacklight())
+
+	require.NoError(t, d.ClearBacklightOverride())
+	assert.Equal(t, [3]uint8{40, 50, 60}, d.currentBacklight())
+
+	// clearing without an override is a no-op
+	require.NoError(t, d.ClearBacklightOverride())
+
+	assert.Equal(t, [][3]uint8{{10, 20, 30}, {255, 0, 0}, {255, 0, 0}, {40, 50, 60}}, written())
+}
+
+func TestBacklightFlash(t *testing.T) {
+	d, written := newTestDevice(t)
+
+	require.NoError(t, d.SetBacklightColour(10, 20, 30))
+	_, err := d.OverrideBacklightColour(255, 255, 255, 10*time.Millisecond)
+	require.NoError(t, err)
+	assert.Eventually(t, func() bool {
+		return d.currentBacklight() == [3]uint8{10, 20, 30}
+	}, time.Second, time.Millisecond)
+	assert.Eventually(t, func() bool {
+		return len(written()) == 3
+	}, time.Second, time.Millisecond)
+	assert.Equal(t, [][3]uint8{{10, 20, 30}, {255, 255, 255}, {10, 20, 30}}, written())
+}
+
+func TestBacklightFlashReplaced(t *testing.T) {
+	d, _ := newTestDevice(t)
+
+	require.NoError(t, d.SetBacklightColour(10, 20, 30))
+	_, err := d.OverrideBacklightColour(255, 255, 255, 5*time.Millisecond)
+	require.NoError(t, err)
+	// a held override replaces the flash, which must not clear it when it
+	// expires
+	_, err = d.OverrideBacklightColour(255, 0, 0, 0)
+	require.NoError(t, err)
+	time.Sleep(20 * time.Millisecond)
+	assert.Equal(t, [3]uint8{255, 0, 0}, d.currentBacklight())
+}
+
+func TestEndBacklightOverride(t *testing.T) {
+	d, _ := newTestDevice(t)
+
+	require.NoError(t, d.SetBacklightColour(10, 20, 30))
+	first, err := d.OverrideBacklightColour(255, 0, 0, 0)
+	require.NoError(t, err)
+	second, err := d.OverrideBacklightColour(0, 0, 255, 0)
+	require.NoError(t, err)
+	assert.NotZero(t, first)
+	assert.NotEqual(t, first, second)
+
+	// ending a replaced override keeps the newer one showing
+	require.NoError(t, d.EndBacklightOverride(first))
+	assert.Equal(t, [3]uint8{0, 0, 255}, d.currentBacklight())
+	require.NoError(t, d.EndBacklightOverride(0))
+	assert.Equal(t, [3]uint8{0, 0, 255}, d.currentBacklight())
+
+	require.NoError(t, d.EndBacklightOverride(second))
+	assert.Equal(t, [3]uint8{10, 20, 30}, d.currentBacklight())
+}
+
+func TestBacklightOverrideConcurrent(t *testing.T) {
+	d, written := newTestDevice(t)
+
+	// flashes expiring while the colour is set and overridden again
+	var wg sync.WaitGroup
+	for idx := range 50 {
+		wg.Add(3)
+		go func() {
+			defer wg.Done()
+			_, err := d.OverrideBacklightColour(255, 0, uint8(idx), time.Millisecond)
+			assert.NoError(t, err)
+		}()
+		go func() {
+			defer wg.Done()
+			assert.NoError(t, d.SetBacklightColour(0, uint8(idx), 0))
+		}()
+		go func() {
+			defer wg.Done()
+			assert.NoError(t, d.ClearBacklightOverride())
+		}()
+	}
+	wg.Wait()
+
+	// whatever the order, the last colour written is the one showing once
+	// the flashes are over
+	require.Eventually(t, func() bool {
+		d.backlightMu.Lock()
+		defer d.backlightMu.Unlock()
+		return d.backlightOverride == nil
+	}, time.Second, time.Millisecond)
+	all := written()
+	assert.Equal(t, d.currentBacklight(), all[len(all)-1])
+}
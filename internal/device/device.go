@@ -6,15 +6,16 @@
 package device
 
 import (
-	"context"
-	"encoding/binary"
 	"errors"
 	"fmt"
 	"image"
 	"os"
+	"path/filepath"
+	"sync"
 	"time"
 
-	"github.com/google/gousb"
+	"github.com/achilleas-k/gg13/internal/backoff"
+	"github.com/achilleas-k/gg13/internal/i18n"
 )
 
 const (
@@ -24,27 +25,65 @@ const (
 
 type Device interface {
 	Close()
-	ReadBytes() ([]byte, error)
-	ReadInput() (uint64, error)
+	Release()
+	ReadBytes() ([]byte, time.Time, error)
+	ReadInput() (uint64, time.Time, error)
 	SetBacklightColour(r, g, b uint8) error
+	SetBacklightKeepalive(time.Duration)
+	OverrideBacklightColour(r, g, b uint8, dt time.Duration) (uint64, error)
+	ClearBacklightOverride() error
+	EndBacklightOverride(id uint64) error
 	SetLCD(image.Image) error
 	ResetLCD() error
+	LCDFrame() (image.Image, error)
+	LCDWriter
 	SetTimeout(time.Duration) error
 }
 
 var ErrReadTimeout = errors.New("timed out reading from device")
 
 type G13Device struct {
-	ctx  *gousb.Context
-	dev  *gousb.Device
-	cfg  *gousb.Config
-	intf *gousb.Interface
-	iep  *gousb.InEndpoint
-	oep  *gousb.OutEndpoint
+	usb usbDevice
+
+	// closed is set when the device is closed, to reject new writes. It is
+	// guarded by writeMu.
+	closed bool
 
 	routines routines
 
+	// routinesMu guards starting and stopping the background routines
+	routinesMu sync.Mutex
+
+	// queue schedules all control and output transfers, which can be
+	// requested by the background routines, applets, and control socket
+	// commands concurrently
+	queue *outputQueue
+
+	// writeMu guards the transfers against closing the device
+	writeMu sync.Mutex
+
 	timeout time.Duration
+
+	// interval at which the backlight colour is resent to the device; zero
+	// disables the keepalive
+	backlightKeepalive time.Duration
+
+	// backlight colour set by SetBacklightColour and the temporary override
+	// shown on top of it, if any, with the timer that ends it and its number,
+	// which every override increments
+	backlight              [3]uint8
+	backlightOverride      *[3]uint8
+	backlightOverrideTimer *time.Timer
+	backlightOverrideID    uint64
+	backlightMu            sync.Mutex
+
+	// last data written to the LCD, used for reading back the displayed
+	// image
+	lcdFrame   []uint8
+	lcdFrameMu sync.Mutex
+
+	// advisory lock held while the device is open
+	lock *deviceLock
 }
 
 type routines struct {
@@ -52,147 +91,156 @@ type routines struct {
 	image  *routine
 }
 
-// New returns an initialised [G13Device] for a connected G13 gameboard. It
-// contains an initialised [gousb.InEndpoint] which is used by
-// [G13Device.ReadBytes] and [G13Device.ReadInput] for reading button presses.
+// DefaultReconnectPolicy is the backoff between attempts to open the device
+// while it's not connected.
+var DefaultReconnectPolicy = backoff.Policy{Min: time.Second, Max: 30 * time.Second}
+
+// Options configures how [NewWithOptions] opens the device.
+type Options struct {
+	// Reconnect is the backoff between attempts to open the device while
+	// it's not connected
+	Reconnect backoff.Policy
+}
+
+// DefaultOptions returns the options used by [New].
+func DefaultOptions() Options {
+	return Options{Reconnect: DefaultReconnectPolicy}
+}
+
+// New returns an initialised [G13Device] for a connected G13 gameboard,
+// waiting for one to be connected if necessary.
 func New() (Device, error) {
-	ctx := gousb.NewContext()
+	return NewWithOptions(DefaultOptions())
+}
 
+// NewWithOptions is like [New] but waits for the device to be connected
+// according to the options.
+func NewWithOptions(opts Options) (Device, error) {
 	d := G13Device{}
-	var dev *gousb.Device
-	for dev == nil {
-		var err error
-		dev, err = ctx.OpenDeviceWithVIDPID(g13VendorID, g13ProductID)
+	d.queue = newOutputQueue(d.writeOutput)
+	reconnect := backoff.New(opts.Reconnect)
+	for d.usb == nil {
+		usb, err := openUSB(g13VendorID, g13ProductID)
 		if err != nil {
 			d.Close()
-			return nil, fmt.Errorf("failed to open device: %w", err)
+			return nil, fmt.Errorf("failed to open device: %w", usbError(err))
 		}
 
-		if dev == nil {
-			fmt.Fprintf(os.Stderr, "device not found: waiting for device\n")
-			time.Sleep(3 * time.Second)
+		if usb == nil {
+			delay := reconnect.Next()
+			fmt.Fprintln(os.Stderr, i18n.Sprintf("device not found: retrying in %s", delay.Round(time.Millisecond)))
+			time.Sleep(delay)
+		} else if reconnect.Retrying() {
+			fmt.Fprintln(os.Stderr, i18n.T("device found"))
 		}
+		d.usb = usb
 	}
 
-	d.dev = dev
-	cfg, err := dev.Config(1)
-	if err != nil {
-		d.Close()
-		return nil, fmt.Errorf("failed to initialise config: %w", err)
-	}
-	d.cfg = cfg
-
-	if err := dev.SetAutoDetach(true); err != nil {
-		d.Close()
-		return nil, fmt.Errorf("failed to enable automatic kernel driver detachment: %w", err)
-	}
-
-	intf, err := cfg.Interface(0, 0)
+	// lock the device before claiming it, to tell the user which instance
+	// has it instead of failing with a busy error
+	lock, err := lockDevice(filepath.Join(LockDir(), lockName(d.usb.identity())))
 	if err != nil {
-		d.Close()
-		return nil, fmt.Errorf("failed to select interface 0: %w", err)
+		// don't reset the output of the instance holding the device
+		d.close(false)
+		return nil, err
 	}
-	d.intf = intf
+	d.lock = lock
 
-	ep, err := intf.InEndpoint(1)
-	if err != nil {
+	if err := d.usb.claim(); err != nil {
 		d.Close()
-		return nil, fmt.Errorf("failed to initialise input endpoint: %w", err)
+		return nil, err
 	}
 
-	// Probably unnecessary, but good to be sure
-	ep.Desc.TransferType = gousb.TransferTypeInterrupt
-	d.iep = ep
-
-	op, err := intf.OutEndpoint(2)
-	if err != nil {
-		d.Close()
-		return nil, fmt.Errorf("failed to initialise output endpoint: %w", err)
-	}
-	d.oep = op
-
-	// Set default timeout to 100 ms. Feels the best empirically.
+	// Set default timeout to 100 ms. Feels the best empirically. Can be
+	// changed with SetTimeout.
 	d.timeout = 100 * time.Millisecond
 	return &d, nil
 }
 
+// Close resets the backlight and LCD and closes the device.
 func (d *G13Device) Close() {
+	d.close(true)
+}
+
+// Release closes the device without resetting the backlight and LCD, so they
+// keep showing the last output.
+func (d *G13Device) Release() {
+	d.close(false)
+}
+
+func (d *G13Device) close(reset bool) {
 	if d == nil {
 		return
 	}
 
-	if d.dev != nil {
+	if reset && d.usb != nil && !d.closed {
 		if err := d.ResetBacklightColour(); err != nil {
-			fmt.Fprintf(os.Stderr, "error resetting backlight during shutdown: %s\n", err)
+			fmt.Fprintln(os.Stderr, i18n.Sprintf("error resetting backlight during shutdown: %s", err))
 		}
 		if err := d.ResetLCD(); err != nil {
-			fmt.Fprintf(os.Stderr, "error resetting LCD during shutdown: %s\n", err)
+			fmt.Fprintln(os.Stderr, i18n.Sprintf("error resetting LCD during shutdown: %s", err))
 		}
 	}
 
-	if d.ctx != nil {
-		defer func() {
-			if err := d.ctx.Close(); err != nil {
-				fmt.Fprintf(os.Stderr, "error closing USB context during shutdown: %s\n", err)
-			}
-			d.ctx = nil
-		}()
-	}
+	// write anything pending and reject new writes
+	d.queue.stop()
 
-	if d.dev != nil {
-		defer func() {
-			if err := d.dev.Close(); err != nil {
-				fmt.Fprintf(os.Stderr, "error closing USB device during shutdown: %s\n", err)
-			}
-			d.dev = nil
-		}()
-	}
+	// wait for any in-flight transfer and block new ones until the device is
+	// closed
+	d.writeMu.Lock()
+	defer d.writeMu.Unlock()
 
-	if d.cfg != nil {
-		defer func() {
-			if err := d.cfg.Close(); err != nil {
-				fmt.Fprintf(os.Stderr, "error closing USB config during shutdown: %s\n", err)
-			}
-			d.cfg = nil
-		}()
-	}
+	// release the lock once everything below is closed
+	defer func() {
+		if err := d.lock.release(); err != nil {
+			fmt.Fprintln(os.Stderr, i18n.Sprintf("error releasing device lock during shutdown: %s", err))
+		}
+		d.lock = nil
+	}()
 
-	if d.intf != nil {
-		defer func() {
-			d.intf.Close()
-			d.intf = nil
-		}()
+	if d.usb != nil && !d.closed {
+		d.usb.close()
 	}
+	// no more writes after closing
+	d.closed = true
 }
 
-func (d *G13Device) ReadInput() (uint64, error) {
-	buf, err := d.ReadBytes()
+// ReadInput reads the state of the device and returns it as a bitmask along
+// with the time it was read (see [G13Device.ReadBytes]). Reports that can't be
+// decoded return the errors of [ParseReport].
+func (d *G13Device) ReadInput() (uint64, time.Time, error) {
+	buf, readTime, err := d.ReadBytes()
+	if err != nil {
+		return 0, readTime, err
+	}
+	state, err := ParseReport(buf)
 	if err != nil {
-		return 0, err
+		return 0, readTime, err
 	}
-	return binary.LittleEndian.Uint64(buf), nil
+	return uint64(state), readTime, nil
 }
 
-// ReadBytes reads a byte array from the device. The size is the maximum
-// supported. Returns a [ErrReadTimeout] if the read times out. Timeout can
-// be set using [G13Device.SetTimeout].
-func (d *G13Device) ReadBytes() ([]byte, error) {
-	if d.iep == nil {
-		return nil, fmt.Errorf("tried to read bytes from a closed device")
+// ReadBytes reads a report from the device and returns the bytes that were
+// read, which may be fewer than a full report. Returns a [ErrReadTimeout] if
+// the read times out. Timeout can be set using [G13Device.SetTimeout].
+// The returned time is taken as soon as the read completes and includes a
+// monotonic clock reading, so it can be used to measure latency.
+func (d *G13Device) ReadBytes() ([]byte, time.Time, error) {
+	if d.usb == nil {
+		return nil, time.Time{}, fmt.Errorf("tried to read bytes from a closed device")
 	}
 
-	ctx, cancel := context.WithTimeout(context.Background(), d.timeout)
-	defer cancel()
-
-	buf := make([]byte, 1*d.iep.Desc.MaxPacketSize)
-	if _, err := d.iep.ReadContext(ctx, buf); err != nil {
-		if errors.Is(err, gousb.TransferCancelled) {
-			return nil, ErrReadTimeout
-		}
-		return nil, fmt.Errorf("failed reading from device: %w", err)
+	buf := make([]byte, d.usb.inputSize())
+	n, err := d.usb.read(buf, d.timeout)
+	readTime := time.Now()
+	if errors.Is(err, ErrReadTimeout) {
+		return nil, readTime, err
+	}
+	if err != nil {
+		return nil, readTime, fmt.Errorf("failed reading from device: %w", usbError(err))
 	}
 
-	return buf, nil
+	return buf[:n], readTime, nil
 }
 
 // SetTimeout sets the timeout for reads from the device.
@@ -597,3 +597,18 @@ func TestButtonIdentification(t *testing.T) {
 		})
 	}
 }
+
+func TestKeyCode(t *testing.T) {
+	assert := assert.New(t)
+
+	for _, key := range device.AllKeys() {
+		assert.Equal(key, device.KeyCode(key.String()))
+	}
+	// auxiliary keys next to the stick
+	assert.Equal(device.LEFT, device.KeyCode("LEFT"))
+	assert.Equal(device.DOWN, device.KeyCode("DOWN"))
+	assert.Equal(device.TOP, device.KeyCode("TOP"))
+	assert.Equal(device.TOP, device.KeyCode("STICK"))
+
+	assert.Equal(device.KeyBit(0), device.KeyCode("G23"))
+}
@@ -0,0 +1,44 @@
+package device
+
+import (
+	"errors"
+	"fmt"
+)
+
+var (
+	// ErrDeviceGone is returned when the device was disconnected, or its
+	// endpoint stalled, as opposed to transient errors that go away by
+	// retrying. The device should be closed and reinitialised right away.
+	ErrDeviceGone = errors.New("device disconnected")
+
+	// ErrPermission is returned when the user is not allowed to access the
+	// device.
+	ErrPermission = errors.New("permission denied accessing device")
+
+	// ErrLCDWrite is returned when writing an image to the LCD fails.
+	ErrLCDWrite = errors.New("failed writing to LCD")
+
+	// ErrDeviceLocked is returned when another instance is using the device.
+	ErrDeviceLocked = errors.New("device is in use by another gg13 instance")
+
+	// ErrShortReport is returned when an input report is too short to be
+	// decoded.
+	ErrShortReport = errors.New("short input report")
+
+	// ErrNotInputReport is returned when a report read from the device
+	// isn't an input report. It doesn't indicate a problem with the device
+	// and the report can be skipped.
+	ErrNotInputReport = errors.New("not an input report")
+)
+
+// usbError wraps err with the matching exported error type, if any.
+func usbError(err error) error {
+	switch {
+	case errorIn(err, noDeviceErrors):
+		return fmt.Errorf("%w: %w", ErrDeviceGone, err)
+	case errorIn(err, accessErrors):
+		return fmt.Errorf("%w: %w", ErrPermission, err)
+	default:
+		return err
+	}
+}
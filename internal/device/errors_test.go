@@ -0,0 +1,59 @@
+//go:build !usbfs
+
+package device_test
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/achilleas-k/gg13/internal/device"
+	"github.com/google/gousb"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUsbError(t *testing.T) {
+	type testCase struct {
+		err      error
+		expected error
+	}
+
+	testCases := map[string]testCase{
+		"no-device": {
+			err:      gousb.ErrorNoDevice,
+			expected: device.ErrDeviceGone,
+		},
+		"transfer-no-device": {
+			err:      fmt.Errorf("transfer failed: %w", gousb.TransferNoDevice),
+			expected: device.ErrDeviceGone,
+		},
+		"pipe": {
+			err:      gousb.ErrorPipe,
+			expected: device.ErrDeviceGone,
+		},
+		"transfer-stall": {
+			err:      fmt.Errorf("transfer failed: %w", gousb.TransferStall),
+			expected: device.ErrDeviceGone,
+		},
+		"access": {
+			err:      gousb.ErrorAccess,
+			expected: device.ErrPermission,
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			err := device.UsbError(tc.err)
+			assert.ErrorIs(t, err, tc.expected)
+			assert.ErrorIs(t, err, tc.err, "original error should still be wrapped")
+		})
+	}
+
+	t.Run("other", func(t *testing.T) {
+		err := device.UsbError(gousb.ErrorTimeout)
+		assert.Equal(t, gousb.ErrorTimeout, err)
+		for _, typed := range []error{device.ErrDeviceGone, device.ErrPermission, device.ErrLCDWrite} {
+			assert.False(t, errors.Is(err, typed))
+		}
+	})
+}
@@ -2,3 +2,4 @@ package device
 
 // export private functions for testing
 var BtoiLE = btoiLE
+var UsbError = usbError
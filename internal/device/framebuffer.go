@@ -0,0 +1,119 @@
+package device
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+)
+
+// LCDWriter writes frames to the LCD. A frame is [LCDDataLength] bytes in the
+// device format, as returned by [Framebuffer.Frame].
+type LCDWriter interface {
+	WriteFrame(frame []byte) error
+}
+
+// Framebuffer is an image of the LCD stored in the device format. It
+// implements [draw.Image], so it can be drawn on with the image/draw package
+// and then sent to the device with [Framebuffer.Flush]. Pixels are either on
+// (black) or off (white).
+type Framebuffer struct {
+	frame []uint8
+}
+
+// NewFramebuffer returns a blank [Framebuffer].
+func NewFramebuffer() *Framebuffer {
+	fb := &Framebuffer{frame: make([]uint8, LCDDataLength)}
+	fb.frame[0] = LCDMagicNumber
+	return fb
+}
+
+// ParseFrame returns a [Framebuffer] holding a copy of the frame.
+func ParseFrame(frame []byte) (*Framebuffer, error) {
+	if err := validateFrame(frame); err != nil {
+		return nil, err
+	}
+	fb := &Framebuffer{frame: make([]uint8, LCDDataLength)}
+	copy(fb.frame, frame)
+	return fb, nil
+}
+
+func validateFrame(frame []byte) error {
+	if len(frame) != LCDDataLength {
+		return fmt.Errorf("invalid LCD frame: %d bytes: %d required", len(frame), LCDDataLength)
+	}
+	if frame[0] != LCDMagicNumber {
+		return fmt.Errorf("invalid LCD frame: first byte is %d: %d required", frame[0], LCDMagicNumber)
+	}
+	return nil
+}
+
+func (fb *Framebuffer) ColorModel() color.Model {
+	return color.GrayModel
+}
+
+func (fb *Framebuffer) Bounds() image.Rectangle {
+	return image.Rect(0, 0, LCDWidth, LCDHeight)
+}
+
+func (fb *Framebuffer) At(x, y int) color.Color {
+	if !(image.Point{x, y}.In(fb.Bounds())) {
+		return color.Gray{Y: 255}
+	}
+	byteIdx, bit := frameBit(x, y)
+	if fb.frame[byteIdx]&bit != 0 {
+		return color.Gray{Y: 0}
+	}
+	return color.Gray{Y: 255}
+}
+
+// Set turns the pixel on if the colour is dark enough, using the same
+// conversion as [G13Device.SetLCD]. Points outside the LCD are ignored.
+func (fb *Framebuffer) Set(x, y int, c color.Color) {
+	if !(image.Point{x, y}.In(fb.Bounds())) {
+		return
+	}
+	byteIdx, bit := frameBit(x, y)
+	if pixelOn(c) {
+		fb.frame[byteIdx] |= bit
+	} else {
+		fb.frame[byteIdx] &^= bit
+	}
+}
+
+// Clear turns all the pixels off.
+func (fb *Framebuffer) Clear() {
+	clear(fb.frame[LCDImageStartIdx:])
+}
+
+// Frame returns a copy of the frame in the device format.
+func (fb *Framebuffer) Frame() []byte {
+	frame := make([]byte, len(fb.frame))
+	copy(frame, fb.frame)
+	return frame
+}
+
+// Flush writes the frame to w.
+func (fb *Framebuffer) Flush(w LCDWriter) error {
+	return w.WriteFrame(fb.Frame())
+}
+
+// WriteFrame writes a frame to the LCD. Unlike [G13Device.SetLCD], the frame
+// is only written once, so it's up to the caller to write frames as often as
+// the content changes. Any image set with SetLCD stops being refreshed.
+func (d *G13Device) WriteFrame(frame []byte) error {
+	if err := validateFrame(frame); err != nil {
+		return err
+	}
+
+	d.routinesMu.Lock()
+	defer d.routinesMu.Unlock()
+
+	if d.routines.image != nil {
+		d.routines.image.stop()
+		d.routines.image = nil
+	}
+
+	data := make([]byte, len(frame))
+	copy(data, frame)
+	return d.queue.submit(outputLCD, data)
+}
@@ -0,0 +1,77 @@
+package device_test
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"testing"
+
+	"github.com/achilleas-k/gg13/internal/device"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type testLCDWriter struct {
+	frames [][]byte
+}
+
+func (w *testLCDWriter) WriteFrame(frame []byte) error {
+	w.frames = append(w.frames, frame)
+	return nil
+}
+
+func TestFramebuffer(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	fb := device.NewFramebuffer()
+	var _ draw.Image = fb
+	assert.Equal(image.Rect(0, 0, device.LCDWidth, device.LCDHeight), fb.Bounds())
+
+	// draw a black box and clear a pixel inside it
+	draw.Draw(fb, image.Rect(10, 5, 20, 12), image.Black, image.Point{}, draw.Src)
+	fb.Set(15, 8, color.White)
+	// points outside the LCD are ignored
+	fb.Set(-1, 0, color.Black)
+	fb.Set(device.LCDWidth, device.LCDHeight, color.Black)
+
+	assert.Equal(color.Gray{Y: 0}, fb.At(10, 5))
+	assert.Equal(color.Gray{Y: 0}, fb.At(19, 11))
+	assert.Equal(color.Gray{Y: 255}, fb.At(15, 8))
+	assert.Equal(color.Gray{Y: 255}, fb.At(20, 12))
+	assert.Equal(color.Gray{Y: 255}, fb.At(-1, 0))
+
+	// the frame matches what SetLCD sends for the same image
+	img := image.NewGray(fb.Bounds())
+	draw.Draw(img, img.Bounds(), fb, image.Point{}, draw.Src)
+	rendered, err := device.RenderLCD(img)
+	require.NoError(err)
+
+	w := &testLCDWriter{}
+	require.NoError(fb.Flush(w))
+	require.Len(w.frames, 1)
+	require.Len(w.frames[0], device.LCDDataLength)
+	assert.Equal(uint8(device.LCDMagicNumber), w.frames[0][0])
+
+	parsed, err := device.ParseFrame(w.frames[0])
+	require.NoError(err)
+	for y := range device.LCDHeight {
+		for x := range device.LCDWidth {
+			assert.Equal(rendered.At(x, y), parsed.At(x, y), "pixel %d,%d", x, y)
+		}
+	}
+
+	// the flushed frame is a copy
+	fb.Clear()
+	assert.Equal(color.Gray{Y: 255}, fb.At(10, 5))
+	assert.Equal(color.Gray{Y: 0}, parsed.At(10, 5))
+	assert.Equal(device.NewFramebuffer().Frame(), fb.Frame())
+}
+
+func TestParseFrameErrors(t *testing.T) {
+	_, err := device.ParseFrame(make([]byte, 10))
+	assert.EqualError(t, err, "invalid LCD frame: 10 bytes: 992 required")
+
+	_, err = device.ParseFrame(make([]byte, device.LCDDataLength))
+	assert.EqualError(t, err, "invalid LCD frame: first byte is 0: 3 required")
+}
@@ -71,6 +71,12 @@ var (
 	}
 
 	keysByName map[string]KeyBit
+
+	// alternative names accepted by [KeyCode]
+	keyAliases = map[string]KeyBit{
+		// the stick click
+		"STICK": TOP,
+	}
 )
 
 func init() {
@@ -81,7 +87,13 @@ func init() {
 	}
 }
 
+// KeyCode returns the key with the given name, or 0 if there is none. Besides
+// the names returned by [KeyBit.String], the stick click (TOP) can also be
+// called STICK.
 func KeyCode(name string) KeyBit {
+	if kb, ok := keyAliases[name]; ok {
+		return kb
+	}
 	return keysByName[name]
 }
 
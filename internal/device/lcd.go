@@ -1,17 +1,18 @@
 package device
 
 import (
+	"errors"
 	"fmt"
 	"image"
+	"image/color"
 	"os"
 	"time"
-
-	"github.com/google/gousb"
 )
 
 const (
 	// TODO: document source of these values
-	ControlRequestType = uint8(gousb.ControlClass | gousb.ControlInterface)
+	// class request to the interface, host to device
+	ControlRequestType = uint8(0x20 | 0x01)
 
 	BacklightColourVal = uint16(0x307)
 
@@ -36,24 +37,64 @@ const (
 	LCDMagicNumber = 3
 )
 
-func (d *G13Device) setBacklightColour(r, g, b uint8) error {
-	// TODO: set context with timeout
-	data := []byte{5, r, g, b, 0}
-	n, err := d.dev.Control(ControlRequestType, SetupPacketRequest, BacklightColourVal, SetupPacketIndex, data)
-	if err != nil {
-		return fmt.Errorf("failed setting backlight colour %+v: %w", data, err)
+// errDeviceClosed is returned when writing to a device after it's been closed.
+var errDeviceClosed = errors.New("device is closed")
+
+// writeOutput performs a transfer for the output queue.
+func (d *G13Device) writeOutput(kind outputKind, data []byte) error {
+	d.writeMu.Lock()
+	defer d.writeMu.Unlock()
+	if d.usb == nil || d.closed {
+		return errDeviceClosed
 	}
-	if n != len(data) {
-		return fmt.Errorf("sent %d bytes but wrote %d while setting backlight colour", len(data), n)
+
+	switch kind {
+	case outputBacklight:
+		n, err := d.usb.control(ControlRequestType, SetupPacketRequest, BacklightColourVal, SetupPacketIndex, data)
+		if err != nil {
+			return fmt.Errorf("failed setting backlight colour %+v: %w", data, usbError(err))
+		}
+		if n != len(data) {
+			return fmt.Errorf("sent %d bytes but wrote %d while setting backlight colour", len(data), n)
+		}
+	case outputLCD:
+		n, err := d.usb.write(data)
+		if err != nil {
+			return fmt.Errorf("%w: %w", ErrLCDWrite, usbError(err))
+		}
+		if n != len(data) {
+			return fmt.Errorf("%w: sent %d bytes but wrote %d", ErrLCDWrite, len(data), n)
+		}
+		d.storeLCDFrame(data)
+	default:
+		return fmt.Errorf("unknown output kind %d", kind)
 	}
 
 	return nil
 }
 
+func (d *G13Device) setBacklightColour(r, g, b uint8) error {
+	data := []byte{5, r, g, b, 0}
+	return d.queue.submit(outputBacklight, data)
+}
+
+// SetBacklightKeepalive sets the interval at which the backlight colour is
+// resent to the device after [G13Device.SetBacklightColour]. A zero interval
+// disables the keepalive, so the colour is only sent when it's set. It takes
+// effect the next time the colour is set.
+func (d *G13Device) SetBacklightKeepalive(dt time.Duration) {
+	d.routinesMu.Lock()
+	defer d.routinesMu.Unlock()
+	d.backlightKeepalive = dt
+}
+
 // SetBacklightColour sets the LCD and key backlight colour to the given r, g,
-// b values and starts a background routine to keep setting the colour every
-// second.
+// b values. If a keepalive interval is set, it also starts a background
+// routine to keep setting the colour at that interval.
 func (d *G13Device) SetBacklightColour(r, g, b uint8) error {
+	d.routinesMu.Lock()
+	defer d.routinesMu.Unlock()
+
 	// stop the existing routine (if any) before starting a new one
 	if d.routines.colour != nil {
 		d.routines.colour.stop()
@@ -61,33 +102,128 @@ func (d *G13Device) SetBacklightColour(r, g, b uint8) error {
 	}
 
 	// initialise the background colour and catch errors first before starting
-	// the routine
-	if err := d.setBacklightColour(r, g, b); err != nil {
+	// the routine; an active override stays on top until it's cleared
+	d.backlightMu.Lock()
+	d.backlight = [3]uint8{r, g, b}
+	err := d.sendBacklight()
+	d.backlightMu.Unlock()
+	if err != nil {
 		return err
 	}
 
+	if d.backlightKeepalive <= 0 {
+		return nil
+	}
+
 	colourFn := func() {
-		if err := d.setBacklightColour(r, g, b); err != nil {
+		// resend whatever is showing, which may be an override
+		d.backlightMu.Lock()
+		defer d.backlightMu.Unlock()
+		if err := d.sendBacklight(); err != nil {
 			fmt.Fprintln(os.Stderr, err.Error())
 		}
 	}
 
-	d.routines.colour = newRoutine(colourFn, 1000*time.Millisecond)
+	d.routines.colour = newRoutine(colourFn, d.backlightKeepalive)
 	return nil
 }
 
 // ResetBacklightColour sets the background colour to 0, 0, 0 (turns the
 // backlight off) and stops the backlight colour background routine.
 func (d *G13Device) ResetBacklightColour() error {
+	d.routinesMu.Lock()
+	defer d.routinesMu.Unlock()
+
 	if d.routines.colour != nil {
 		d.routines.colour.stop()
 		d.routines.colour = nil
 	}
 
+	d.backlightMu.Lock()
+	defer d.backlightMu.Unlock()
+	d.backlight = [3]uint8{}
+	d.stopOverride()
 	return d.setBacklightColour(uint8(0), uint8(0), uint8(0))
 }
 
-func (d *G13Device) setLCD(img image.Image) error {
+// sendBacklight writes the colour that should be showing to the device. The
+// caller must hold backlightMu, so that changes to the colour and the
+// override are written in the order they're made and the last write is
+// always the colour that should be showing.
+func (d *G13Device) sendBacklight() error {
+	colour := d.backlight
+	if d.backlightOverride != nil {
+		colour = *d.backlightOverride
+	}
+	return d.setBacklightColour(colour[0], colour[1], colour[2])
+}
+
+// stopOverride clears the override and its timer. The caller must hold
+// backlightMu.
+func (d *G13Device) stopOverride() {
+	if d.backlightOverrideTimer != nil {
+		d.backlightOverrideTimer.Stop()
+		d.backlightOverrideTimer = nil
+	}
+	d.backlightOverride = nil
+}
+
+// OverrideBacklightColour temporarily shows the given colour instead of the
+// one set with [G13Device.SetBacklightColour]. If dt is positive, the colour
+// is restored after dt, otherwise the override lasts until
+// [G13Device.ClearBacklightOverride] or [G13Device.EndBacklightOverride] is
+// called. A new override replaces any active one. It returns the number of
+// the override, which is never zero.
+func (d *G13Device) OverrideBacklightColour(r, g, b uint8, dt time.Duration) (uint64, error) {
+	d.backlightMu.Lock()
+	defer d.backlightMu.Unlock()
+	d.stopOverride()
+	d.backlightOverride = &[3]uint8{r, g, b}
+	d.backlightOverrideID++
+	id := d.backlightOverrideID
+	if dt > 0 {
+		d.backlightOverrideTimer = time.AfterFunc(dt, func() {
+			if err := d.clearOverride(id); err != nil {
+				fmt.Fprintln(os.Stderr, err.Error())
+			}
+		})
+	}
+	return id, d.sendBacklight()
+}
+
+// ClearBacklightOverride restores the colour set with
+// [G13Device.SetBacklightColour] if an override is active.
+func (d *G13Device) ClearBacklightOverride() error {
+	return d.clearOverride(0)
+}
+
+// EndBacklightOverride restores the colour set with
+// [G13Device.SetBacklightColour] if the override with the number returned by
+// [G13Device.OverrideBacklightColour] is still active, so that ending it
+// doesn't cancel a newer one.
+func (d *G13Device) EndBacklightOverride(id uint64) error {
+	if id == 0 {
+		return nil
+	}
+	return d.clearOverride(id)
+}
+
+// clearOverride clears the active override and restores the colour. If id
+// is not zero, the override is only cleared if it's the one with that
+// number, so an expired flash doesn't cancel a newer override.
+func (d *G13Device) clearOverride(id uint64) error {
+	d.backlightMu.Lock()
+	defer d.backlightMu.Unlock()
+	if d.backlightOverride == nil || (id != 0 && d.backlightOverrideID != id) {
+		return nil
+	}
+	d.stopOverride()
+	return d.sendBacklight()
+}
+
+// ValidateLCDImage returns an error if the image doesn't have the size of the
+// LCD.
+func ValidateLCDImage(img image.Image) error {
 	bounds := img.Bounds()
 	if bounds.Min.X != 0 || bounds.Min.Y != 0 {
 		return fmt.Errorf("invalid image: bounds to not start at 0,0")
@@ -95,20 +231,21 @@ func (d *G13Device) setLCD(img image.Image) error {
 	if bounds.Max.X != LCDWidth || bounds.Max.Y != LCDHeight {
 		return fmt.Errorf("image data has incorrect size %dx%d: %dx%d required", bounds.Max.X, bounds.Max.Y, LCDWidth, LCDHeight)
 	}
-	data := imageToG13Bytes(img)
+	return nil
+}
 
-	n, err := d.oep.Write(data)
-	if err != nil {
+func (d *G13Device) setLCD(img image.Image) error {
+	if err := ValidateLCDImage(img); err != nil {
 		return err
 	}
-	if n != len(data) {
-		return fmt.Errorf("sent %d bytes but wrote %d while setting LCD", len(data), n)
-	}
-
-	return nil
+	data := imageToG13Bytes(img)
+	return d.queue.submit(outputLCD, data)
 }
 
 func (d *G13Device) SetLCD(img image.Image) error {
+	d.routinesMu.Lock()
+	defer d.routinesMu.Unlock()
+
 	// stop the existing routine (if any) before starting a new one
 	if d.routines.image != nil {
 		d.routines.image.stop()
@@ -132,6 +269,9 @@ func (d *G13Device) SetLCD(img image.Image) error {
 }
 
 func (d *G13Device) ResetLCD() error {
+	d.routinesMu.Lock()
+	defer d.routinesMu.Unlock()
+
 	if d.routines.image != nil {
 		d.routines.image.stop()
 		d.routines.image = nil
@@ -139,14 +279,43 @@ func (d *G13Device) ResetLCD() error {
 
 	blank := make([]uint8, LCDDataLength)
 	blank[0] = 0x03
-	n, err := d.oep.Write(blank)
-	if err != nil {
-		return err
+	return d.queue.submit(outputLCD, blank)
+}
+
+func (d *G13Device) storeLCDFrame(data []uint8) {
+	d.lcdFrameMu.Lock()
+	defer d.lcdFrameMu.Unlock()
+	d.lcdFrame = data
+}
+
+// LCDFrame returns the image currently displayed on the LCD, decoded from the
+// last data written to the device.
+func (d *G13Device) LCDFrame() (image.Image, error) {
+	d.lcdFrameMu.Lock()
+	defer d.lcdFrameMu.Unlock()
+	if d.lcdFrame == nil {
+		return nil, fmt.Errorf("nothing has been written to the LCD yet")
 	}
-	if n != len(blank) {
-		return fmt.Errorf("sent %d bytes but wrote %d while resetting LCD", len(blank), n)
+	return g13BytesToImage(d.lcdFrame), nil
+}
+
+// ImageToG13Bytes converts the image to the frame sent to the LCD, of
+// [LCDDataLength] bytes, using the same conversion as [G13Device.SetLCD]. The
+// image must have the size of the LCD.
+func ImageToG13Bytes(img image.Image) ([]byte, error) {
+	if err := ValidateLCDImage(img); err != nil {
+		return nil, err
 	}
-	return nil
+	return imageToG13Bytes(img), nil
+}
+
+// G13BytesToImage decodes a frame sent to the LCD into a monochrome image
+// where every pixel that is on is black and every other pixel is white.
+func G13BytesToImage(frame []byte) (*image.Gray, error) {
+	if err := validateFrame(frame); err != nil {
+		return nil, err
+	}
+	return g13BytesToImage(frame), nil
 }
 
 func imageToG13Bytes(img image.Image) []uint8 {
@@ -183,17 +352,53 @@ func imageToG13Bytes(img image.Image) []uint8 {
 	bounds := img.Bounds() // must be 160x43
 	for y := range bounds.Max.Y {
 		for x := range bounds.Max.X {
-			r, g, b, _ := img.At(x, y).RGBA()
-			// convert the image to monochrome by turning on any non-white
-			// pixels
-			if r+g+b < 255*3 {
-				byteIdx := y/8*LCDWidth + x // index of the byte that represents the 8-pixel column we're in
-				bitIdx := y % 8             // index of the bit (within the byte) to flip on
-
-				onBit := uint8(1) << bitIdx
-				vbitmap[byteIdx+LCDImageStartIdx] |= onBit
+			if pixelOn(img.At(x, y)) {
+				byteIdx, onBit := frameBit(x, y)
+				vbitmap[byteIdx] |= onBit
 			}
 		}
 	}
 	return vbitmap
 }
+
+var defaultMonochrome = DefaultMonochrome()
+
+// pixelOn converts a colour to monochrome with [DefaultMonochrome].
+func pixelOn(c color.Color) bool {
+	return defaultMonochrome.PixelOn(c)
+}
+
+// frameBit returns the index of the byte in the LCD data that holds the pixel
+// at x, y and the bit of the pixel within the byte.
+func frameBit(x, y int) (int, uint8) {
+	byteIdx := y/8*LCDWidth + x // index of the byte that represents the 8-pixel column we're in
+	bitIdx := y % 8             // index of the bit (within the byte) to flip on
+	return byteIdx + LCDImageStartIdx, uint8(1) << bitIdx
+}
+
+// g13BytesToImage is the inverse of [imageToG13Bytes]. It decodes the byte
+// array sent to the LCD into a monochrome image where every pixel that is on
+// is black and every other pixel is white.
+func g13BytesToImage(data []uint8) *image.Gray {
+	img := image.NewGray(image.Rect(0, 0, LCDWidth, LCDHeight))
+	for y := range LCDHeight {
+		for x := range LCDWidth {
+			byteIdx, bit := frameBit(x, y)
+			if data[byteIdx]&bit != 0 {
+				img.SetGray(x, y, color.Gray{Y: 0})
+			} else {
+				img.SetGray(x, y, color.Gray{Y: 255})
+			}
+		}
+	}
+	return img
+}
+
+// RenderLCD returns the image as it would be displayed on the LCD after
+// conversion to the device format.
+func RenderLCD(img image.Image) (image.Image, error) {
+	if err := ValidateLCDImage(img); err != nil {
+		return nil, err
+	}
+	return g13BytesToImage(imageToG13Bytes(img)), nil
+}
@@ -0,0 +1,95 @@
+package device_test
+
+import (
+	"image"
+	"image/color"
+	"testing"
+
+	"github.com/achilleas-k/gg13/internal/device"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newWhiteImage(width, height int) *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	for y := range height {
+		for x := range width {
+			img.Set(x, y, color.White)
+		}
+	}
+	return img
+}
+
+func TestRenderLCD(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	img := newWhiteImage(device.LCDWidth, device.LCDHeight)
+
+	// pixels that should be on: one in each corner and one on a byte
+	// boundary
+	onPixels := []image.Point{
+		{0, 0},
+		{device.LCDWidth - 1, 0},
+		{0, device.LCDHeight - 1},
+		{device.LCDWidth - 1, device.LCDHeight - 1},
+		{80, 8},
+	}
+	for _, p := range onPixels {
+		img.Set(p.X, p.Y, color.Black)
+	}
+
+	rendered, err := device.RenderLCD(img)
+	require.NoError(err)
+	assert.Equal(image.Rect(0, 0, device.LCDWidth, device.LCDHeight), rendered.Bounds())
+
+	var renderedOn []image.Point
+	for y := range device.LCDHeight {
+		for x := range device.LCDWidth {
+			if r, _, _, _ := rendered.At(x, y).RGBA(); r == 0 {
+				renderedOn = append(renderedOn, image.Point{x, y})
+			}
+		}
+	}
+	assert.ElementsMatch(onPixels, renderedOn)
+}
+
+func TestRenderLCDErrors(t *testing.T) {
+	t.Run("wrong-size", func(t *testing.T) {
+		_, err := device.RenderLCD(newWhiteImage(100, 100))
+		assert.EqualError(t, err, "image data has incorrect size 100x100: 160x43 required")
+	})
+
+	t.Run("bad-bounds", func(t *testing.T) {
+		img := image.NewRGBA(image.Rect(1, 1, device.LCDWidth, device.LCDHeight))
+		_, err := device.RenderLCD(img)
+		assert.EqualError(t, err, "invalid image: bounds to not start at 0,0")
+	})
+}
+
+func TestG13Bytes(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	img := newWhiteImage(device.LCDWidth, device.LCDHeight)
+	img.Set(0, 0, color.Black)
+	img.Set(80, 8, color.Black)
+
+	frame, err := device.ImageToG13Bytes(img)
+	require.NoError(err)
+	require.Len(frame, device.LCDDataLength)
+	assert.Equal(uint8(device.LCDMagicNumber), frame[0])
+	assert.Equal(uint8(1), frame[device.LCDImageStartIdx])
+	assert.Equal(uint8(1), frame[device.LCDImageStartIdx+device.LCDWidth+80])
+
+	decoded, err := device.G13BytesToImage(frame)
+	require.NoError(err)
+	rendered, err := device.RenderLCD(img)
+	require.NoError(err)
+	assert.Equal(rendered, decoded)
+
+	_, err = device.ImageToG13Bytes(newWhiteImage(100, 100))
+	assert.EqualError(err, "image data has incorrect size 100x100: 160x43 required")
+	_, err = device.G13BytesToImage(frame[:10])
+	assert.EqualError(err, "invalid LCD frame: 10 bytes: 992 required")
+}
@@ -0,0 +1,139 @@
+package device
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+// systemLockDir is the standard directory for lock files, and sharedTempDir
+// the fallback where it isn't writable by every user. The temporary directory
+// isn't taken from $TMPDIR, which can differ between users.
+const (
+	systemLockDir = "/run/lock"
+	sharedTempDir = "/tmp"
+)
+
+// deviceLock is an advisory lock on a physical device, held for as long as
+// the device is open so that two instances can't claim it at the same time.
+type deviceLock struct {
+	file *os.File
+}
+
+// LockDir returns the directory for the lock files. It's the same for every
+// user, so that instances of different users, like a system service and a
+// desktop session, see each other's locks: /run/lock if every user can
+// create files in it, /tmp otherwise.
+func LockDir() string {
+	if info, err := os.Stat(systemLockDir); err == nil && info.IsDir() && info.Mode().Perm()&0o002 != 0 {
+		return systemLockDir
+	}
+	return sharedTempDir
+}
+
+// lockName returns the name of the lock file for the device with the given
+// serial number. Devices without one are identified by their bus and
+// address instead.
+func lockName(serial string, bus, address int) string {
+	id := strings.Map(func(r rune) rune {
+		if r == '/' || r == os.PathSeparator || r < ' ' {
+			return '_'
+		}
+		return r
+	}, strings.TrimSpace(serial))
+	if id == "" {
+		id = fmt.Sprintf("bus%d-%d", bus, address)
+	}
+	return fmt.Sprintf("gg13-%s.lock", id)
+}
+
+// lockDevice takes the lock in the file at path and writes the PID of the
+// process to it, if it can. If another process holds the lock, the error is
+// [ErrDeviceLocked] and includes the PID of the holder.
+func lockDevice(path string) (*deviceLock, error) {
+	file, writable, err := openLockFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed opening lock file: %w", err)
+	}
+
+	if err := syscall.Flock(int(file.Fd()), syscall.LOCK_EX|syscall.LOCK_NB); err != nil {
+		defer file.Close()
+		if errors.Is(err, syscall.EWOULDBLOCK) {
+			holder := "unknown PID"
+			if data, err := os.ReadFile(path); err == nil {
+				if pid, err := strconv.Atoi(strings.TrimSpace(string(data))); err == nil {
+					holder = fmt.Sprintf("PID %d", pid)
+				}
+			}
+			return nil, fmt.Errorf("%w (%s, lock file %s)", ErrDeviceLocked, holder, path)
+		}
+		return nil, fmt.Errorf("failed locking %s: %w", path, err)
+	}
+
+	if !writable {
+		// the lock works all the same, only the PID is missing
+		return &deviceLock{file: file}, nil
+	}
+	if err := file.Truncate(0); err != nil {
+		_ = file.Close()
+		return nil, fmt.Errorf("failed writing lock file: %w", err)
+	}
+	if _, err := file.WriteAt([]byte(strconv.Itoa(os.Getpid())+"\n"), 0); err != nil {
+		_ = file.Close()
+		return nil, fmt.Errorf("failed writing lock file: %w", err)
+	}
+	return &deviceLock{file: file}, nil
+}
+
+// openLockFile opens the lock file at path, and returns whether it can be
+// written. A new file is created writable by every user, whatever the umask,
+// so that other users can write their PID when they take the lock after the
+// creator. Links aren't followed, since other users can create them in the
+// shared directories.
+func openLockFile(path string) (*os.File, bool, error) {
+	for {
+		// an existing file is opened without O_CREAT, which fails on files
+		// of other users in sticky directories like /tmp with
+		// fs.protected_regular set
+		file, err := os.OpenFile(path, os.O_RDWR|syscall.O_NOFOLLOW, 0)
+		if errors.Is(err, fs.ErrPermission) {
+			// another user created it without write permission for others:
+			// a read-only file can still be locked
+			file, err = os.OpenFile(path, os.O_RDONLY|syscall.O_NOFOLLOW, 0)
+			if err == nil {
+				return file, false, nil
+			}
+		}
+		if err == nil || !errors.Is(err, fs.ErrNotExist) {
+			return file, err == nil, err
+		}
+
+		file, err = os.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_EXCL|syscall.O_NOFOLLOW, 0o666)
+		if errors.Is(err, fs.ErrExist) {
+			// another process created it meanwhile
+			continue
+		}
+		if err != nil {
+			return nil, false, err
+		}
+		if err := file.Chmod(0o666); err != nil {
+			_ = file.Close()
+			return nil, false, err
+		}
+		return file, true, nil
+	}
+}
+
+// release releases the lock. The file is left in place: removing it could
+// let another process lock a file that is about to be unlinked.
+func (l *deviceLock) release() error {
+	if l == nil {
+		return nil
+	}
+	// closing the file releases the lock
+	return l.file.Close()
+}
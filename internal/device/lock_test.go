@@ -0,0 +1,176 @@
+package device
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLockName(t *testing.T) {
+	testCases := map[string]struct {
+		serial  string
+		bus     int
+		address int
+		expName string
+	}{
+		"serial": {
+			serial:  "A1B2C3",
+			expName: "gg13-A1B2C3.lock",
+		},
+		"no-serial": {
+			bus:     3,
+			address: 7,
+			expName: "gg13-bus3-7.lock",
+		},
+		"blank-serial": {
+			serial:  "  ",
+			bus:     1,
+			address: 2,
+			expName: "gg13-bus1-2.lock",
+		},
+		"separator-in-serial": {
+			serial:  "../A1",
+			expName: "gg13-.._A1.lock",
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, tc.expName, lockName(tc.serial, tc.bus, tc.address))
+		})
+	}
+}
+
+func TestLockDevice(t *testing.T) {
+	require := require.New(t)
+	path := filepath.Join(t.TempDir(), "gg13-test.lock")
+
+	lock, err := lockDevice(path)
+	require.NoError(err)
+
+	data, err := os.ReadFile(path)
+	require.NoError(err)
+	require.Equal(fmt.Sprintf("%d\n", os.Getpid()), string(data))
+
+	// flock locks belong to the open file, so a second open conflicts even
+	// in the same process
+	_, err = lockDevice(path)
+	require.ErrorIs(err, ErrDeviceLocked)
+	require.ErrorContains(err, fmt.Sprintf("PID %d", os.Getpid()))
+	require.ErrorContains(err, path)
+
+	require.NoError(lock.release())
+
+	lock, err = lockDevice(path)
+	require.NoError(err)
+	require.NoError(lock.release())
+
+	// other users can take the lock after the creator, whatever the umask
+	info, err := os.Stat(path)
+	require.NoError(err)
+	require.Equal(os.FileMode(0o666), info.Mode().Perm())
+
+	// links to other files aren't followed
+	link := filepath.Join(t.TempDir(), "gg13-link.lock")
+	require.NoError(os.Symlink(path, link))
+	_, err = lockDevice(link)
+	require.ErrorIs(err, syscall.ELOOP)
+
+	// releasing a lock that was never taken is a no-op
+	var noLock *deviceLock
+	require.NoError(noLock.release())
+}
+
+func TestLockDir(t *testing.T) {
+	// the same for every user
+	t.Setenv("XDG_RUNTIME_DIR", t.TempDir())
+	t.Setenv("TMPDIR", t.TempDir())
+	assert.Contains(t, []string{systemLockDir, sharedTempDir}, LockDir())
+}
+
+// lockHelperEnv is set to the path of the lock file when the test binary runs
+// as a helper process of TestLockDeviceUsers. The helper takes the lock,
+// prints the result, and holds the lock until its input is closed.
+const lockHelperEnv = "GG13_TEST_LOCK_HELPER"
+
+func TestLockHelperProcess(t *testing.T) {
+	path := os.Getenv(lockHelperEnv)
+	if path == "" {
+		t.Skip("only runs as a helper process")
+	}
+	lock, err := lockDevice(path)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(0)
+	}
+	fmt.Println("locked")
+	_, _ = io.Copy(io.Discard, os.Stdin)
+	_ = lock.release()
+	os.Exit(0)
+}
+
+func TestLockDeviceUsers(t *testing.T) {
+	if os.Getuid() != 0 {
+		t.Skip("running processes as other users requires root")
+	}
+	require := require.New(t)
+
+	// a shared directory like /tmp, with the test binary where the users can
+	// run it
+	dir := t.TempDir()
+	require.NoError(os.Chmod(filepath.Dir(dir), 0o755))
+	require.NoError(os.Chmod(dir, 0o777|os.ModeSticky))
+	exe, err := os.Executable()
+	require.NoError(err)
+	data, err := os.ReadFile(exe)
+	require.NoError(err)
+	helper := filepath.Join(dir, "lock.test")
+	require.NoError(os.WriteFile(helper, data, 0o755))
+	path := filepath.Join(dir, "gg13-test.lock")
+
+	// lock starts the helper as the user and returns the first line it
+	// prints, and the pipe that stops it when closed
+	lock := func(uid uint32) (string, io.WriteCloser) {
+		cmd := exec.Command(helper, "-test.run=^TestLockHelperProcess$")
+		cmd.Env = append(os.Environ(), lockHelperEnv+"="+path)
+		cmd.SysProcAttr = &syscall.SysProcAttr{Credential: &syscall.Credential{Uid: uid, Gid: uid}}
+		stdin, err := cmd.StdinPipe()
+		require.NoError(err)
+		stdout, err := cmd.StdoutPipe()
+		require.NoError(err)
+		require.NoError(cmd.Start())
+		t.Cleanup(func() {
+			_ = stdin.Close()
+			_ = cmd.Wait()
+		})
+		line, err := bufio.NewReader(stdout).ReadString('\n')
+		require.NoError(err)
+		return strings.TrimSpace(line), stdin
+	}
+
+	const first, second = 65534, 65533
+	result, stop := lock(first)
+	require.Equal("locked", result)
+
+	// the holder is found by another user
+	result, _ = lock(second)
+	require.Contains(result, ErrDeviceLocked.Error())
+	require.NotContains(result, "unknown PID")
+
+	// and the lock is free for them once released
+	require.NoError(stop.Close())
+	require.Eventually(func() bool {
+		result, _ := lock(second)
+		return result == "locked"
+	}, 5*time.Second, 10*time.Millisecond)
+}
@@ -0,0 +1,75 @@
+package device
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"math"
+)
+
+// Monochrome describes how images are converted to black and white for the
+// LCD. A pixel is turned on (black) if its brightness, from 0 for black to 1
+// for white, is below the threshold. Transparent pixels are drawn over white.
+type Monochrome struct {
+	// Threshold is the brightness below which a pixel is turned on.
+	Threshold float64
+
+	// Gamma is applied to the brightness before comparing it with the
+	// threshold. Values above 1 darken the mid-tones, turning more pixels on,
+	// and values below 1 lighten them.
+	Gamma float64
+
+	// Invert turns on the pixels that would otherwise be off.
+	Invert bool
+}
+
+// DefaultMonochrome returns the conversion used for images without any
+// options: pixels darker than mid-grey are turned on.
+func DefaultMonochrome() Monochrome {
+	return Monochrome{
+		Threshold: 0.5,
+		Gamma:     1,
+	}
+}
+
+// Validate returns an error if the threshold is not between 0 and 1 or the
+// gamma is not positive.
+func (m Monochrome) Validate() error {
+	if m.Threshold < 0 || m.Threshold > 1 || math.IsNaN(m.Threshold) {
+		return fmt.Errorf("threshold must be between 0 and 1: %g", m.Threshold)
+	}
+	if !(m.Gamma > 0) || math.IsInf(m.Gamma, 0) {
+		return fmt.Errorf("gamma must be positive: %g", m.Gamma)
+	}
+	return nil
+}
+
+// PixelOn returns true if a pixel of the colour should be on.
+func (m Monochrome) PixelOn(c color.Color) bool {
+	r, g, b, a := c.RGBA()
+	// the values are premultiplied by alpha, so adding the transparent part
+	// draws them over white
+	bg := 0xffff - a
+	lum := (0.299*float64(r+bg) + 0.587*float64(g+bg) + 0.114*float64(b+bg)) / 0xffff
+	if m.Gamma != 1 {
+		lum = math.Pow(lum, m.Gamma)
+	}
+	return (lum < m.Threshold) != m.Invert
+}
+
+// Apply returns a copy of the image where every pixel is either black (on) or
+// white (off).
+func (m Monochrome) Apply(img image.Image) *image.Gray {
+	bounds := img.Bounds()
+	out := image.NewGray(bounds)
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			if m.PixelOn(img.At(x, y)) {
+				out.SetGray(x, y, color.Gray{Y: 0})
+			} else {
+				out.SetGray(x, y, color.Gray{Y: 255})
+			}
+		}
+	}
+	return out
+}
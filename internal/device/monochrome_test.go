@@ -0,0 +1,57 @@
+package device_test
+
+import (
+	"image"
+	"image/color"
+	"testing"
+
+	"github.com/achilleas-k/gg13/internal/device"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMonochrome(t *testing.T) {
+	darkGrey := color.Gray{Y: 0x60}
+	lightGrey := color.Gray{Y: 0xa0}
+	transparent := color.RGBA{}
+	testCases := map[string]struct {
+		mono     device.Monochrome
+		colour   color.Color
+		expected bool
+	}{
+		"default-black":       {device.DefaultMonochrome(), color.Black, true},
+		"default-white":       {device.DefaultMonochrome(), color.White, false},
+		"default-dark":        {device.DefaultMonochrome(), darkGrey, true},
+		"default-light":       {device.DefaultMonochrome(), lightGrey, false},
+		"default-transparent": {device.DefaultMonochrome(), transparent, false},
+		"low-threshold":       {device.Monochrome{Threshold: 0.3, Gamma: 1}, darkGrey, false},
+		"high-threshold":      {device.Monochrome{Threshold: 0.7, Gamma: 1}, lightGrey, true},
+		"high-gamma":          {device.Monochrome{Threshold: 0.5, Gamma: 2.2}, lightGrey, true},
+		"low-gamma":           {device.Monochrome{Threshold: 0.5, Gamma: 0.4}, darkGrey, false},
+		"inverted-black":      {device.Monochrome{Threshold: 0.5, Gamma: 1, Invert: true}, color.Black, false},
+		"inverted-white":      {device.Monochrome{Threshold: 0.5, Gamma: 1, Invert: true}, color.White, true},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, tc.expected, tc.mono.PixelOn(tc.colour))
+		})
+	}
+}
+
+func TestMonochromeApply(t *testing.T) {
+	img := image.NewRGBA(image.Rect(0, 0, 2, 1))
+	img.Set(0, 0, color.RGBA{R: 0x20, G: 0x20, B: 0xff, A: 0xff})
+	img.Set(1, 0, color.White)
+
+	mono := device.DefaultMonochrome()
+	assert.Equal(t, []uint8{0, 255}, mono.Apply(img).Pix)
+	mono.Invert = true
+	assert.Equal(t, []uint8{255, 0}, mono.Apply(img).Pix)
+}
+
+func TestMonochromeValidate(t *testing.T) {
+	assert := assert.New(t)
+	assert.NoError(device.DefaultMonochrome().Validate())
+	assert.EqualError(device.Monochrome{Threshold: 1.5, Gamma: 1}.Validate(), "threshold must be between 0 and 1: 1.5")
+	assert.EqualError(device.Monochrome{Threshold: 0.5}.Validate(), "gamma must be positive: 0")
+}
@@ -0,0 +1,180 @@
+package device
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+// outputKind identifies the type of an output write. Pending writes of the
+// same kind are coalesced.
+type outputKind int
+
+const (
+	outputBacklight outputKind = iota
+	outputLCD
+
+	numOutputKinds
+)
+
+const (
+	// maximum number of retries for a write that fails with a transient
+	// error
+	outputMaxRetries = 3
+
+	// delay before the first retry, doubled for each subsequent one
+	outputRetryDelay = 10 * time.Millisecond
+)
+
+// outputJob is a pending write along with the channels of everyone waiting
+// for its result, including the waiters of any writes it superseded.
+type outputJob struct {
+	data    []byte
+	waiters []chan error
+}
+
+// outputQueue schedules writes to the device on a single goroutine. Only the
+// latest pending write of each kind is kept: submitting an LCD frame while
+// another is waiting replaces the waiting one, since only the latest frame
+// matters. Writes that fail with a transient USB error are retried with
+// exponential backoff.
+type outputQueue struct {
+	write func(outputKind, []byte) error
+
+	mu      sync.Mutex
+	pending [numOutputKinds]*outputJob
+	closed  bool
+
+	notify   chan struct{}
+	stopChan chan struct{}
+	doneChan chan struct{}
+}
+
+var errQueueClosed = errors.New("output queue is closed")
+
+func newOutputQueue(write func(outputKind, []byte) error) *outputQueue {
+	q := &outputQueue{
+		write:    write,
+		notify:   make(chan struct{}, 1),
+		stopChan: make(chan struct{}),
+		doneChan: make(chan struct{}),
+	}
+	go q.run()
+	return q
+}
+
+// submit queues the data to be written and waits for the result. If the
+// write is superseded by a newer one of the same kind before it's sent, the
+// result of the newer write is returned.
+func (q *outputQueue) submit(kind outputKind, data []byte) error {
+	if q == nil {
+		return errQueueClosed
+	}
+	result := make(chan error, 1)
+
+	q.mu.Lock()
+	if q.closed {
+		q.mu.Unlock()
+		return errQueueClosed
+	}
+	job := &outputJob{data: data, waiters: []chan error{result}}
+	if prev := q.pending[kind]; prev != nil {
+		job.waiters = append(prev.waiters, result)
+	}
+	q.pending[kind] = job
+	q.mu.Unlock()
+
+	select {
+	case q.notify <- struct{}{}:
+	default:
+		// worker already notified
+	}
+
+	return <-result
+}
+
+// stop the queue after writing any pending jobs.
+func (q *outputQueue) stop() {
+	if q == nil {
+		return
+	}
+	q.mu.Lock()
+	if q.closed {
+		q.mu.Unlock()
+		return
+	}
+	q.closed = true
+	q.mu.Unlock()
+
+	close(q.stopChan)
+	<-q.doneChan
+}
+
+func (q *outputQueue) run() {
+	defer close(q.doneChan)
+	for {
+		select {
+		case <-q.notify:
+			q.flush()
+		case <-q.stopChan:
+			q.flush()
+			return
+		}
+	}
+}
+
+// take removes and returns the pending job of the given kind.
+func (q *outputQueue) take(kind outputKind) *outputJob {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	job := q.pending[kind]
+	q.pending[kind] = nil
+	return job
+}
+
+// flush writes all pending jobs.
+func (q *outputQueue) flush() {
+	for kind := range numOutputKinds {
+		job := q.take(kind)
+		if job == nil {
+			continue
+		}
+		superseded, err := q.writeWithRetry(kind, job)
+		if superseded {
+			continue
+		}
+		for _, waiter := range job.waiters {
+			waiter <- err
+		}
+	}
+}
+
+// writeWithRetry writes the job, retrying on transient errors. If a newer
+// job of the same kind is submitted while waiting to retry, the waiters are
+// handed over to the newer job and superseded is true.
+func (q *outputQueue) writeWithRetry(kind outputKind, job *outputJob) (superseded bool, err error) {
+	delay := outputRetryDelay
+	for attempt := 0; ; attempt++ {
+		err = q.write(kind, job.data)
+		if err == nil || !isTransient(err) || attempt >= outputMaxRetries {
+			return false, err
+		}
+
+		time.Sleep(delay)
+		delay *= 2
+
+		q.mu.Lock()
+		if newer := q.pending[kind]; newer != nil {
+			newer.waiters = append(newer.waiters, job.waiters...)
+			q.mu.Unlock()
+			return true, nil
+		}
+		q.mu.Unlock()
+	}
+}
+
+// isTransient returns true for USB errors that are likely to go away if the
+// transfer is retried.
+func isTransient(err error) bool {
+	return errorIn(err, transientErrors)
+}
@@ -0,0 +1,126 @@
+//go:build !usbfs
+
+package device
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/gousb"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOutputQueueCoalesce(t *testing.T) {
+	block := make(chan struct{})
+	var mu sync.Mutex
+	var written [][]byte
+	q := newOutputQueue(func(kind outputKind, data []byte) error {
+		if data[0] == 0 {
+			// hold the worker on the first write so the others pile up
+			<-block
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		written = append(written, data)
+		return nil
+	})
+
+	var wg sync.WaitGroup
+	submit := func(b byte) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			assert.NoError(t, q.submit(outputLCD, []byte{b}))
+		}()
+	}
+
+	submit(0)
+	assert.Eventually(t, func() bool {
+		// wait until the worker picked up the first frame
+		q.mu.Lock()
+		defer q.mu.Unlock()
+		return q.pending[outputLCD] == nil
+	}, time.Second, time.Millisecond)
+	for b := byte(1); b <= 3; b++ {
+		submit(b)
+		assert.Eventually(t, func() bool {
+			q.mu.Lock()
+			defer q.mu.Unlock()
+			job := q.pending[outputLCD]
+			return job != nil && job.data[0] == b
+		}, time.Second, time.Millisecond)
+	}
+	close(block)
+	wg.Wait()
+	q.stop()
+
+	assert.Equal(t, [][]byte{{0}, {3}}, written)
+}
+
+func TestOutputQueueRetry(t *testing.T) {
+	type testCase struct {
+		err      error
+		expCalls int
+	}
+
+	testCases := map[string]testCase{
+		"transient": {
+			err:      gousb.ErrorTimeout,
+			expCalls: outputMaxRetries + 1,
+		},
+		"transient-wrapped": {
+			err:      fmt.Errorf("failed: %w", gousb.TransferTimedOut),
+			expCalls: outputMaxRetries + 1,
+		},
+		"permanent": {
+			err:      gousb.ErrorNoDevice,
+			expCalls: 1,
+		},
+	}
+
+	for name := range testCases {
+		tc := testCases[name]
+		t.Run(name, func(t *testing.T) {
+			calls := 0
+			q := newOutputQueue(func(outputKind, []byte) error {
+				calls++
+				return tc.err
+			})
+			defer q.stop()
+
+			err := q.submit(outputBacklight, []byte{5, 0, 0, 0, 0})
+			require.Error(t, err)
+			assert.True(t, errors.Is(err, tc.err))
+			assert.Equal(t, tc.expCalls, calls)
+		})
+	}
+}
+
+func TestOutputQueueRecover(t *testing.T) {
+	calls := 0
+	q := newOutputQueue(func(outputKind, []byte) error {
+		calls++
+		if calls < 2 {
+			return gousb.ErrorBusy
+		}
+		return nil
+	})
+	defer q.stop()
+
+	assert.NoError(t, q.submit(outputBacklight, []byte{5, 0, 0, 0, 0}))
+	assert.Equal(t, 2, calls)
+}
+
+func TestOutputQueueClosed(t *testing.T) {
+	q := newOutputQueue(func(outputKind, []byte) error { return nil })
+	q.stop()
+	assert.ErrorIs(t, q.submit(outputLCD, []byte{0}), errQueueClosed)
+
+	var nilQueue *outputQueue
+	assert.ErrorIs(t, nilQueue.submit(outputLCD, []byte{0}), errQueueClosed)
+	nilQueue.stop()
+}
@@ -0,0 +1,63 @@
+package device
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testUSBDevice returns queued reports from read.
+type testUSBDevice struct {
+	usbDevice
+
+	reports [][]byte
+}
+
+func (u *testUSBDevice) inputSize() int {
+	return 64
+}
+
+func (u *testUSBDevice) read(buf []byte, _ time.Duration) (int, error) {
+	if len(u.reports) == 0 {
+		return 0, ErrReadTimeout
+	}
+	n := copy(buf, u.reports[0])
+	u.reports = u.reports[1:]
+	return n, nil
+}
+
+func TestReadInput(t *testing.T) {
+	assert := assert.New(t)
+
+	u := &testUSBDevice{
+		reports: [][]byte{
+			{0x01, 0x7f, 0x80, 0x01, 0x00, 0x00, 0x00, 0x00},
+			{0x01, 0x7f},
+			{0x02, 0x7f, 0x80, 0x01, 0x00, 0x00, 0x00, 0x00},
+		},
+	}
+	d := &G13Device{usb: u}
+
+	input, _, err := d.ReadInput()
+	require.NoError(t, err)
+	assert.Equal(G1.Uint64()|0x80<<16|0x7f<<8|0x01, input)
+
+	// only the bytes that were read are returned
+	buf, _, err := d.ReadBytes()
+	require.NoError(t, err)
+	assert.Equal([]byte{0x01, 0x7f}, buf)
+
+	_, _, err = d.ReadInput()
+	assert.ErrorIs(err, ErrNotInputReport)
+
+	_, _, err = d.ReadInput()
+	assert.ErrorIs(err, ErrReadTimeout)
+}
+
+func TestReadInputShort(t *testing.T) {
+	d := &G13Device{usb: &testUSBDevice{reports: [][]byte{{0x01, 0x7f, 0x80}}}}
+	_, _, err := d.ReadInput()
+	assert.ErrorIs(t, err, ErrShortReport)
+}
@@ -0,0 +1,44 @@
+package device
+
+import (
+	"encoding/binary"
+	"fmt"
+)
+
+const (
+	// ReportSize is the size of an input report from the G13.
+	ReportSize = 8
+
+	// inputReportID is the first byte of input reports. Reports with other
+	// IDs don't carry the key state.
+	inputReportID = 0x01
+)
+
+// State is the state of the G13 keys and stick decoded from an input report:
+// a bitmask of the [KeyBit] values of the keys that are down, with the stick
+// position in the second and third bytes (see [StickPosition]).
+type State uint64
+
+// ParseReport decodes an input report read from the device. Bytes after the
+// first [ReportSize] are ignored. It returns [ErrShortReport] if the report
+// is too short to decode and [ErrNotInputReport] if it's a different kind of
+// report, which should be skipped.
+func ParseReport(report []byte) (State, error) {
+	if len(report) < ReportSize {
+		return 0, fmt.Errorf("%w: %d bytes: %d required", ErrShortReport, len(report), ReportSize)
+	}
+	if report[0] != inputReportID {
+		return 0, fmt.Errorf("%w: report ID %d", ErrNotInputReport, report[0])
+	}
+	return State(binary.LittleEndian.Uint64(report[:ReportSize])), nil
+}
+
+// IsDown returns true if the key is down.
+func (s State) IsDown(kb KeyBit) bool {
+	return uint64(s)&kb.Uint64() != 0
+}
+
+// Stick returns the x, y position of the stick.
+func (s State) Stick() (uint8, uint8) {
+	return StickPosition(uint64(s))
+}
@@ -0,0 +1,89 @@
+package device_test
+
+import (
+	"encoding/binary"
+	"slices"
+	"testing"
+
+	"github.com/achilleas-k/gg13/internal/device"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseReport(t *testing.T) {
+	testCases := map[string]struct {
+		report []byte
+		keys   []device.KeyBit
+		x, y   uint8
+	}{
+		"idle": {
+			report: []byte{0x01, 0x7f, 0x7f, 0x00, 0x00, 0x00, 0x00, 0x80},
+			x:      127,
+			y:      127,
+		},
+		"keys-and-stick": {
+			report: []byte{0x01, 0x00, 0xff, 0x05, 0x00, 0x00, 0x40, 0x00},
+			keys:   []device.KeyBit{device.G1, device.G3, device.M2},
+			x:      0,
+			y:      255,
+		},
+		"long": {
+			report: []byte{0x01, 0x10, 0x20, 0x01, 0x00, 0x00, 0x00, 0x00, 0xff, 0xff},
+			keys:   []device.KeyBit{device.G1},
+			x:      16,
+			y:      32,
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			assert := assert.New(t)
+
+			state, err := device.ParseReport(tc.report)
+			require.NoError(t, err)
+			for _, kb := range device.AllKeys() {
+				assert.Equal(slices.Contains(tc.keys, kb), state.IsDown(kb), kb.String())
+			}
+			x, y := state.Stick()
+			assert.Equal(tc.x, x)
+			assert.Equal(tc.y, y)
+		})
+	}
+}
+
+func TestParseReportShort(t *testing.T) {
+	for _, report := range [][]byte{nil, {}, {0x01}, {0x01, 0x7f, 0x7f, 0x00, 0x00, 0x00, 0x00}} {
+		_, err := device.ParseReport(report)
+		assert.ErrorIs(t, err, device.ErrShortReport)
+	}
+}
+
+func TestParseReportNotInput(t *testing.T) {
+	_, err := device.ParseReport([]byte{0x02, 0x7f, 0x7f, 0x01, 0x00, 0x00, 0x00, 0x00})
+	assert.ErrorIs(t, err, device.ErrNotInputReport)
+	assert.EqualError(t, err, "not an input report: report ID 2")
+}
+
+func FuzzParseReport(f *testing.F) {
+	f.Add([]byte{0x01, 0x7f, 0x7f, 0x00, 0x00, 0x00, 0x00, 0x80})
+	f.Add([]byte{0x01, 0x00, 0xff, 0x05, 0x00, 0x00, 0x40, 0x00, 0x00})
+	f.Add([]byte{0x01, 0x7f})
+	f.Add([]byte{})
+
+	f.Fuzz(func(t *testing.T, report []byte) {
+		state, err := device.ParseReport(report)
+		if len(report) < device.ReportSize {
+			assert.ErrorIs(t, err, device.ErrShortReport)
+			return
+		}
+		if report[0] != 0x01 {
+			assert.ErrorIs(t, err, device.ErrNotInputReport)
+			return
+		}
+		require.NoError(t, err)
+		assert.Equal(t, binary.LittleEndian.Uint64(report), uint64(state))
+		x, y := state.Stick()
+		assert.Equal(t, report[1], x)
+		assert.Equal(t, report[2], y)
+	})
+}
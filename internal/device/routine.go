@@ -8,6 +8,7 @@ import (
 // stopped.
 type routine struct {
 	stopChan chan bool
+	doneChan chan struct{}
 }
 
 func newRoutine(fn func(), dt time.Duration) *routine {
@@ -15,7 +16,9 @@ func newRoutine(fn func(), dt time.Duration) *routine {
 
 	tick := time.Tick(dt)
 	r.stopChan = make(chan bool)
+	r.doneChan = make(chan struct{})
 	go func() {
+		defer close(r.doneChan)
 		for {
 			select {
 			case <-r.stopChan:
@@ -29,7 +32,9 @@ func newRoutine(fn func(), dt time.Duration) *routine {
 	return r
 }
 
-// Stop the routine.
+// Stop the routine. Returns after the function has finished running if it
+// was running when stop was called.
 func (r *routine) stop() {
 	close(r.stopChan)
+	<-r.doneChan
 }
@@ -0,0 +1,25 @@
+package device
+
+import (
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRoutineStop(t *testing.T) {
+	var calls atomic.Int32
+	r := newRoutine(func() {
+		calls.Add(1)
+		// simulate a slow transfer so stop is likely called mid-run
+		time.Sleep(5 * time.Millisecond)
+	}, time.Millisecond)
+
+	assert.Eventually(t, func() bool { return calls.Load() > 0 }, time.Second, time.Millisecond)
+
+	r.stop()
+	callsAtStop := calls.Load()
+	time.Sleep(20 * time.Millisecond)
+	assert.Equal(t, callsAtStop, calls.Load(), "routine function ran after stop returned")
+}
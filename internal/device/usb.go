@@ -0,0 +1,46 @@
+package device
+
+import (
+	"errors"
+	"time"
+)
+
+// usbDevice is an open G13. The default implementation uses gousb, which
+// needs libusb and cgo. Building with the usbfs tag replaces it with one that
+// talks to the kernel's usbfs directly, for static binaries without cgo.
+type usbDevice interface {
+	// identity returns the serial number, empty if the device doesn't have
+	// one, and the bus and address of the device.
+	identity() (serial string, bus, address int)
+
+	// claim detaches the kernel driver, claims the interface, and sets up the
+	// endpoints.
+	claim() error
+
+	// inputSize returns the maximum size of an input report.
+	inputSize() int
+
+	// read reads an input report, returning [ErrReadTimeout] if none arrives
+	// within the timeout.
+	read(buf []byte, timeout time.Duration) (int, error)
+
+	// write writes to the output endpoint, which drives the LCD.
+	write(data []byte) (int, error)
+
+	// control sends a control transfer.
+	control(requestType, request uint8, value, index uint16, data []byte) (int, error)
+
+	// close releases the interface and closes the device. Errors are printed
+	// since there is nothing left to do about them.
+	close()
+}
+
+// errorIn returns true if err matches any of the targets.
+func errorIn(err error, targets []error) bool {
+	for _, target := range targets {
+		if errors.Is(err, target) {
+			return true
+		}
+	}
+	return false
+}
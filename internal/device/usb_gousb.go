@@ -0,0 +1,158 @@
+//go:build !usbfs
+
+package device
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/achilleas-k/gg13/internal/i18n"
+	"github.com/google/gousb"
+)
+
+var (
+	// errors on unplugging the device, and pipe errors, which the G13 also
+	// reports when it's unplugged during a transfer and which only
+	// reinitialising the device clears otherwise
+	noDeviceErrors = []error{gousb.ErrorNoDevice, gousb.TransferNoDevice, gousb.ErrorPipe, gousb.TransferStall}
+	accessErrors   = []error{gousb.ErrorAccess}
+
+	// errors that are likely to go away if the transfer is retried
+	transientErrors = []error{
+		gousb.ErrorTimeout,
+		gousb.ErrorBusy,
+		gousb.ErrorInterrupted,
+		gousb.ErrorOverflow,
+		gousb.TransferTimedOut,
+		gousb.TransferOverflow,
+	}
+)
+
+type gousbDevice struct {
+	ctx  *gousb.Context
+	dev  *gousb.Device
+	cfg  *gousb.Config
+	intf *gousb.Interface
+	iep  *gousb.InEndpoint
+	oep  *gousb.OutEndpoint
+}
+
+// openUSB opens the first device with the given IDs. It returns nil if none
+// is connected.
+func openUSB(vendorID, productID uint16) (usbDevice, error) {
+	ctx := gousb.NewContext()
+	dev, err := ctx.OpenDeviceWithVIDPID(gousb.ID(vendorID), gousb.ID(productID))
+	if err != nil || dev == nil {
+		if err := ctx.Close(); err != nil {
+			fmt.Fprintln(os.Stderr, i18n.Sprintf("error closing USB context: %s", err))
+		}
+		return nil, err
+	}
+	return &gousbDevice{ctx: ctx, dev: dev}, nil
+}
+
+func (u *gousbDevice) identity() (string, int, int) {
+	serial, err := u.dev.SerialNumber()
+	if err != nil {
+		// the G13 may not have a serial number
+		serial = ""
+	}
+	return serial, u.dev.Desc.Bus, u.dev.Desc.Address
+}
+
+func (u *gousbDevice) claim() error {
+	cfg, err := u.dev.Config(1)
+	if err != nil {
+		return fmt.Errorf("failed to initialise config: %w", err)
+	}
+	u.cfg = cfg
+
+	if err := u.dev.SetAutoDetach(true); err != nil {
+		return fmt.Errorf("failed to enable automatic kernel driver detachment: %w", usbError(err))
+	}
+
+	intf, err := cfg.Interface(0, 0)
+	if err != nil {
+		return fmt.Errorf("failed to select interface 0: %w", usbError(err))
+	}
+	u.intf = intf
+
+	ep, err := intf.InEndpoint(1)
+	if err != nil {
+		return fmt.Errorf("failed to initialise input endpoint: %w", err)
+	}
+
+	// Probably unnecessary, but good to be sure
+	ep.Desc.TransferType = gousb.TransferTypeInterrupt
+	u.iep = ep
+
+	op, err := intf.OutEndpoint(2)
+	if err != nil {
+		return fmt.Errorf("failed to initialise output endpoint: %w", err)
+	}
+	u.oep = op
+	return nil
+}
+
+func (u *gousbDevice) inputSize() int {
+	return u.iep.Desc.MaxPacketSize
+}
+
+func (u *gousbDevice) read(buf []byte, timeout time.Duration) (int, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	n, err := u.iep.ReadContext(ctx, buf)
+	if errors.Is(err, gousb.TransferCancelled) {
+		return n, ErrReadTimeout
+	}
+	return n, err
+}
+
+func (u *gousbDevice) write(data []byte) (int, error) {
+	return u.oep.Write(data)
+}
+
+func (u *gousbDevice) control(requestType, request uint8, value, index uint16, data []byte) (int, error) {
+	// TODO: set context with timeout
+	return u.dev.Control(requestType, request, value, index, data)
+}
+
+func (u *gousbDevice) close() {
+	if u.ctx != nil {
+		defer func() {
+			if err := u.ctx.Close(); err != nil {
+				fmt.Fprintln(os.Stderr, i18n.Sprintf("error closing USB context during shutdown: %s", err))
+			}
+			u.ctx = nil
+		}()
+	}
+
+	if u.dev != nil {
+		defer func() {
+			if err := u.dev.Close(); err != nil {
+				fmt.Fprintln(os.Stderr, i18n.Sprintf("error closing USB device during shutdown: %s", err))
+			}
+			u.dev = nil
+		}()
+	}
+
+	if u.cfg != nil {
+		defer func() {
+			if err := u.cfg.Close(); err != nil {
+				fmt.Fprintln(os.Stderr, i18n.Sprintf("error closing USB config during shutdown: %s", err))
+			}
+			u.cfg = nil
+		}()
+	}
+
+	if u.intf != nil {
+		defer func() {
+			u.intf.Close()
+			u.intf = nil
+		}()
+	}
+}
@@ -0,0 +1,304 @@
+//go:build usbfs
+
+package device
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+	"unsafe"
+
+	"github.com/achilleas-k/gg13/internal/i18n"
+)
+
+// Definitions from linux/usbdevice_fs.h
+type usbdevfsCtrlTransfer struct {
+	RequestType uint8
+	Request     uint8
+	Value       uint16
+	Index       uint16
+	Length      uint16
+	Timeout     uint32 // in milliseconds
+	Data        unsafe.Pointer
+}
+
+type usbdevfsBulkTransfer struct {
+	Endpoint uint32
+	Length   uint32
+	Timeout  uint32 // in milliseconds
+	Data     unsafe.Pointer
+}
+
+type usbdevfsIoctl struct {
+	Interface int32
+	IoctlCode int32
+	Data      unsafe.Pointer
+}
+
+var (
+	usbdevfsControl          = iocReadWrite(0, unsafe.Sizeof(usbdevfsCtrlTransfer{}))
+	usbdevfsBulk             = iocReadWrite(2, unsafe.Sizeof(usbdevfsBulkTransfer{}))
+	usbdevfsSetConfiguration = iocRead(5, unsafe.Sizeof(uint32(0)))
+	usbdevfsClaimInterface   = iocRead(15, unsafe.Sizeof(uint32(0)))
+	usbdevfsReleaseInterface = iocRead(16, unsafe.Sizeof(uint32(0)))
+	usbdevfsIoctlCmd         = iocReadWrite(18, unsafe.Sizeof(usbdevfsIoctl{}))
+	usbdevfsDisconnect       = ioc(22)
+	usbdevfsConnect          = ioc(23)
+)
+
+const (
+	// sysfs directory listing connected USB devices
+	sysUSBDevices = "/sys/bus/usb/devices"
+
+	// directory containing the USB device nodes
+	devUSB = "/dev/bus/usb"
+
+	g13Config      = 1
+	g13Interface   = 0
+	g13InEndpoint  = 0x81
+	g13OutEndpoint = 0x02
+
+	// usbfs transfers block, so writes need a timeout to not hold up closing
+	// the device forever
+	transferTimeout = time.Second
+)
+
+var (
+	// errors on unplugging the device, and pipe errors, which the G13 also
+	// reports when it's unplugged during a transfer and which only
+	// reinitialising the device clears otherwise
+	noDeviceErrors = []error{syscall.ENODEV, syscall.ESHUTDOWN, syscall.EPIPE}
+	accessErrors   = []error{syscall.EACCES, syscall.EPERM}
+
+	// errors that are likely to go away if the transfer is retried
+	transientErrors = []error{
+		syscall.ETIMEDOUT,
+		syscall.EBUSY,
+		syscall.EINTR,
+		syscall.EAGAIN,
+		syscall.EOVERFLOW,
+	}
+)
+
+func ioc(nr uintptr) uintptr {
+	return 'U'<<8 | nr
+}
+
+func iocRead(nr, size uintptr) uintptr {
+	return 2<<30 | size<<16 | ioc(nr)
+}
+
+func iocReadWrite(nr, size uintptr) uintptr {
+	return 3<<30 | size<<16 | ioc(nr)
+}
+
+type usbfsDevice struct {
+	file *os.File
+
+	// device directory in sysfs
+	sysPath string
+
+	serial       string
+	bus, address int
+	maxPacket    int
+
+	claimed  bool
+	detached bool
+}
+
+// openUSB opens the first device with the given IDs. It returns nil if none
+// is connected.
+func openUSB(vendorID, productID uint16) (usbDevice, error) {
+	sysPath, err := findUSBDevice(sysUSBDevices, vendorID, productID)
+	if err != nil || sysPath == "" {
+		return nil, err
+	}
+
+	u := &usbfsDevice{sysPath: sysPath}
+	if u.bus, err = readSysInt(sysPath, "busnum", 10); err != nil {
+		return nil, err
+	}
+	if u.address, err = readSysInt(sysPath, "devnum", 10); err != nil {
+		return nil, err
+	}
+	// the G13 may not have a serial number
+	u.serial, _ = readSysString(sysPath, "serial")
+
+	u.file, err = os.OpenFile(filepath.Join(devUSB, fmt.Sprintf("%03d", u.bus), fmt.Sprintf("%03d", u.address)), os.O_RDWR, 0)
+	if err != nil {
+		return nil, err
+	}
+	return u, nil
+}
+
+// findUSBDevice returns the sysfs directory of the first device with the
+// given IDs under root, or an empty string if there is none.
+func findUSBDevice(root string, vendorID, productID uint16) (string, error) {
+	entries, err := os.ReadDir(root)
+	if err != nil {
+		return "", fmt.Errorf("failed listing USB devices: %w", err)
+	}
+	for _, entry := range entries {
+		// interfaces are listed next to the devices as <device>:<config>.<interface>
+		if strings.Contains(entry.Name(), ":") {
+			continue
+		}
+		path := filepath.Join(root, entry.Name())
+		vendor, err := readSysInt(path, "idVendor", 16)
+		if err != nil {
+			// root hubs and other entries without IDs
+			continue
+		}
+		product, err := readSysInt(path, "idProduct", 16)
+		if err != nil {
+			continue
+		}
+		if vendor == int(vendorID) && product == int(productID) {
+			return path, nil
+		}
+	}
+	return "", nil
+}
+
+func readSysString(dir, name string) (string, error) {
+	data, err := os.ReadFile(filepath.Join(dir, name))
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(data)), nil
+}
+
+func readSysInt(dir, name string, base int) (int, error) {
+	value, err := readSysString(dir, name)
+	if err != nil {
+		return 0, err
+	}
+	n, err := strconv.ParseInt(value, base, 32)
+	if err != nil {
+		return 0, fmt.Errorf("failed parsing %s of USB device: %w", name, err)
+	}
+	return int(n), nil
+}
+
+func (u *usbfsDevice) identity() (string, int, int) {
+	return u.serial, u.bus, u.address
+}
+
+func (u *usbfsDevice) ioctl(cmd uintptr, arg unsafe.Pointer) (int, error) {
+	r, _, errno := syscall.Syscall(syscall.SYS_IOCTL, u.file.Fd(), cmd, uintptr(arg))
+	if errno != 0 {
+		return 0, errno
+	}
+	return int(r), nil
+}
+
+// interfaceIoctl sends an ioctl to the driver of the interface.
+func (u *usbfsDevice) interfaceIoctl(code uintptr) error {
+	cmd := usbdevfsIoctl{Interface: g13Interface, IoctlCode: int32(code)}
+	_, err := u.ioctl(usbdevfsIoctlCmd, unsafe.Pointer(&cmd))
+	return err
+}
+
+func (u *usbfsDevice) claim() error {
+	if active, err := readSysInt(u.sysPath, "bConfigurationValue", 10); err != nil || active != g13Config {
+		config := uint32(g13Config)
+		if _, err := u.ioctl(usbdevfsSetConfiguration, unsafe.Pointer(&config)); err != nil {
+			return fmt.Errorf("failed to initialise config: %w", usbError(err))
+		}
+	}
+
+	// detach the kernel driver, which the interface is reattached to on close
+	switch err := u.interfaceIoctl(usbdevfsDisconnect); {
+	case err == nil:
+		u.detached = true
+	case errors.Is(err, syscall.ENODATA):
+		// no driver is bound
+	default:
+		return fmt.Errorf("failed to detach kernel driver: %w", usbError(err))
+	}
+
+	intf := uint32(g13Interface)
+	if _, err := u.ioctl(usbdevfsClaimInterface, unsafe.Pointer(&intf)); err != nil {
+		return fmt.Errorf("failed to select interface 0: %w", usbError(err))
+	}
+	u.claimed = true
+
+	maxPacket, err := readSysInt(filepath.Join(u.sysPath+fmt.Sprintf(":%d.%d", g13Config, g13Interface), fmt.Sprintf("ep_%02x", g13InEndpoint)), "wMaxPacketSize", 16)
+	if err != nil {
+		return fmt.Errorf("failed to initialise input endpoint: %w", err)
+	}
+	u.maxPacket = maxPacket
+	return nil
+}
+
+func (u *usbfsDevice) inputSize() int {
+	return u.maxPacket
+}
+
+// transfer performs a bulk or interrupt transfer on the endpoint. The kernel
+// picks the transfer type from the endpoint.
+func (u *usbfsDevice) transfer(endpoint uint32, data []byte, timeout time.Duration) (int, error) {
+	if len(data) == 0 {
+		return 0, nil
+	}
+	xfer := usbdevfsBulkTransfer{
+		Endpoint: endpoint,
+		Length:   uint32(len(data)),
+		Timeout:  uint32(max(timeout.Milliseconds(), 1)),
+		Data:     unsafe.Pointer(&data[0]),
+	}
+	return u.ioctl(usbdevfsBulk, unsafe.Pointer(&xfer))
+}
+
+func (u *usbfsDevice) read(buf []byte, timeout time.Duration) (int, error) {
+	n, err := u.transfer(g13InEndpoint, buf, timeout)
+	if errors.Is(err, syscall.ETIMEDOUT) {
+		return n, ErrReadTimeout
+	}
+	return n, err
+}
+
+func (u *usbfsDevice) write(data []byte) (int, error) {
+	return u.transfer(g13OutEndpoint, data, transferTimeout)
+}
+
+func (u *usbfsDevice) control(requestType, request uint8, value, index uint16, data []byte) (int, error) {
+	xfer := usbdevfsCtrlTransfer{
+		RequestType: requestType,
+		Request:     request,
+		Value:       value,
+		Index:       index,
+		Length:      uint16(len(data)),
+		Timeout:     uint32(transferTimeout.Milliseconds()),
+	}
+	if len(data) > 0 {
+		xfer.Data = unsafe.Pointer(&data[0])
+	}
+	return u.ioctl(usbdevfsControl, unsafe.Pointer(&xfer))
+}
+
+func (u *usbfsDevice) close() {
+	if u.claimed {
+		intf := uint32(g13Interface)
+		if _, err := u.ioctl(usbdevfsReleaseInterface, unsafe.Pointer(&intf)); err != nil {
+			fmt.Fprintln(os.Stderr, i18n.Sprintf("error releasing USB interface during shutdown: %s", err))
+		}
+		u.claimed = false
+	}
+
+	if u.detached {
+		if err := u.interfaceIoctl(usbdevfsConnect); err != nil {
+			fmt.Fprintln(os.Stderr, i18n.Sprintf("error reattaching kernel driver during shutdown: %s", err))
+		}
+		u.detached = false
+	}
+
+	if err := u.file.Close(); err != nil {
+		fmt.Fprintln(os.Stderr, i18n.Sprintf("error closing USB device during shutdown: %s", err))
+	}
+}
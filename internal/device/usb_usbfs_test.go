@@ -0,0 +1,71 @@
+//go:build usbfs
+
+package device
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+	"unsafe"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func writeSysfsDevice(t *testing.T, root, name string, files map[string]string) {
+	dir := filepath.Join(root, name)
+	require.NoError(t, os.MkdirAll(dir, 0o755))
+	for fname, content := range files {
+		require.NoError(t, os.WriteFile(filepath.Join(dir, fname), []byte(content+"\n"), 0o644))
+	}
+}
+
+func TestFindUSBDevice(t *testing.T) {
+	root := t.TempDir()
+	writeSysfsDevice(t, root, "usb1", map[string]string{"idVendor": "1d6b", "idProduct": "0002"})
+	writeSysfsDevice(t, root, "1-1", map[string]string{"idVendor": "046d", "idProduct": "c52b"})
+	writeSysfsDevice(t, root, "1-2:1.0", map[string]string{"bInterfaceNumber": "00"})
+	writeSysfsDevice(t, root, "1-2", map[string]string{"idVendor": "046d", "idProduct": "c21c", "busnum": "1", "devnum": "5"})
+
+	path, err := findUSBDevice(root, g13VendorID, g13ProductID)
+	require.NoError(t, err)
+	assert.Equal(t, filepath.Join(root, "1-2"), path)
+
+	devnum, err := readSysInt(path, "devnum", 10)
+	require.NoError(t, err)
+	assert.Equal(t, 5, devnum)
+
+	path, err = findUSBDevice(root, g13VendorID, 0xffff)
+	require.NoError(t, err)
+	assert.Empty(t, path)
+
+	_, err = findUSBDevice(filepath.Join(root, "missing"), g13VendorID, g13ProductID)
+	assert.Error(t, err)
+}
+
+func TestUsbfsIoctlNumbers(t *testing.T) {
+	if unsafe.Sizeof(uintptr(0)) != 8 {
+		t.Skip("ioctl numbers checked on 64-bit architectures")
+	}
+	// values from linux/usbdevice_fs.h on x86_64
+	assert.Equal(t, uintptr(0xc0185500), usbdevfsControl)
+	assert.Equal(t, uintptr(0xc0185502), usbdevfsBulk)
+	assert.Equal(t, uintptr(0x80045505), usbdevfsSetConfiguration)
+	assert.Equal(t, uintptr(0x8004550f), usbdevfsClaimInterface)
+	assert.Equal(t, uintptr(0x80045510), usbdevfsReleaseInterface)
+	assert.Equal(t, uintptr(0xc0105512), usbdevfsIoctlCmd)
+	assert.Equal(t, uintptr(0x5516), usbdevfsDisconnect)
+	assert.Equal(t, uintptr(0x5517), usbdevfsConnect)
+}
+
+func TestUsbfsErrors(t *testing.T) {
+	assert.ErrorIs(t, usbError(syscall.ENODEV), ErrDeviceGone)
+	assert.ErrorIs(t, usbError(syscall.EPIPE), ErrDeviceGone)
+	assert.ErrorIs(t, usbError(&os.PathError{Op: "open", Path: "/dev/bus/usb/001/005", Err: syscall.EACCES}), ErrPermission)
+	assert.Equal(t, syscall.EIO, usbError(syscall.EIO))
+
+	assert.True(t, isTransient(fmt.Errorf("failed: %w", syscall.ETIMEDOUT)))
+	assert.False(t, isTransient(syscall.ENODEV))
+}
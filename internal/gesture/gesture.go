@@ -0,0 +1,274 @@
+// Package gesture recognises gestures made with the G13 thumb stick: quick
+// flicks in one of the four directions and full circles.
+//
+// A [Detector] is fed the normalised stick position on every report and
+// returns the gestures completed by the movement.
+package gesture
+
+import (
+	"fmt"
+	"math"
+	"time"
+)
+
+// Gesture is a recognised stick movement.
+type Gesture uint8
+
+const (
+	// FlickUp and the other flicks are a quick movement from the centre to
+	// the edge and back.
+	FlickUp Gesture = iota + 1
+	FlickRight
+	FlickDown
+	FlickLeft
+
+	// Circle is a full rotation along the edge, in either direction.
+	Circle
+)
+
+var gestureNames = map[Gesture]string{
+	FlickUp:    "flick_up",
+	FlickRight: "flick_right",
+	FlickDown:  "flick_down",
+	FlickLeft:  "flick_left",
+	Circle:     "circle",
+}
+
+func (g Gesture) String() string {
+	return gestureNames[g]
+}
+
+// Parse returns the gesture with the given name.
+func Parse(name string) (Gesture, error) {
+	for g, n := range gestureNames {
+		if n == name {
+			return g, nil
+		}
+	}
+	return 0, fmt.Errorf("unknown gesture: %s", name)
+}
+
+// Thresholds tune the detection. Distances are from the centre of the stick,
+// where 1 is the edge.
+type Thresholds struct {
+	// Edge is the distance the stick must reach for a flick or to count a
+	// direction towards a circle.
+	Edge float64
+
+	// Rest is the distance under which the stick is considered centred.
+	Rest float64
+
+	// FlickTime is the longest a flick can take, from leaving the centre to
+	// returning to it.
+	FlickTime time.Duration
+
+	// CircleTime is the longest a circle can take.
+	CircleTime time.Duration
+}
+
+const (
+	DefaultEdge       = 0.8
+	DefaultRest       = 0.3
+	DefaultFlickTime  = 250 * time.Millisecond
+	DefaultCircleTime = time.Second
+)
+
+// DefaultThresholds returns the default detection thresholds.
+func DefaultThresholds() Thresholds {
+	return Thresholds{
+		Edge:       DefaultEdge,
+		Rest:       DefaultRest,
+		FlickTime:  DefaultFlickTime,
+		CircleTime: DefaultCircleTime,
+	}
+}
+
+// Validate checks that the thresholds can be used for detection.
+func (th Thresholds) Validate() error {
+	if th.Edge <= 0 || th.Edge > 1 {
+		return fmt.Errorf("edge must be greater than 0 and at most 1: %g", th.Edge)
+	}
+	if th.Rest < 0 || th.Rest >= th.Edge {
+		return fmt.Errorf("rest must be at least 0 and less than edge (%g): %g", th.Edge, th.Rest)
+	}
+	if th.FlickTime <= 0 {
+		return fmt.Errorf("flick time must be positive: %s", th.FlickTime)
+	}
+	if th.CircleTime <= 0 {
+		return fmt.Errorf("circle time must be positive: %s", th.CircleTime)
+	}
+	return nil
+}
+
+// direction is one of the four sectors of the stick range, in clockwise
+// order. It's the index of the matching flick.
+type direction int
+
+const (
+	dirUp direction = iota
+	dirRight
+	dirDown
+	dirLeft
+)
+
+type state uint8
+
+const (
+	// the stick is centred
+	stateRest state = iota
+
+	// the stick has left the centre
+	stateMoving
+)
+
+// edgeVisit is a direction reached at the edge and when it was reached.
+type edgeVisit struct {
+	dir direction
+	at  time.Time
+}
+
+// Detector is a state machine over the stick position stream. It is not safe
+// for concurrent use.
+type Detector struct {
+	thresholds Thresholds
+
+	state state
+
+	// when the stick left the centre
+	start time.Time
+
+	// the directions reached at the edge since leaving the centre, without
+	// consecutive repeats
+	visits []edgeVisit
+
+	// a circle was completed since leaving the centre, so returning to it
+	// isn't a flick
+	circled bool
+}
+
+// NewDetector returns a [Detector] using the thresholds.
+func NewDetector(thresholds Thresholds) *Detector {
+	return &Detector{thresholds: thresholds}
+}
+
+// Reset returns the detector to its initial state, for example when the
+// device is reinitialised.
+func (d *Detector) Reset() {
+	d.state = stateRest
+	d.visits = nil
+	d.circled = false
+}
+
+// Update feeds a new stick position to the detector and returns the gesture
+// completed by it, or 0 if there is none. Each axis is in the [-1, 1] range,
+// with negative y pointing up.
+func (d *Detector) Update(x, y float32, at time.Time) Gesture {
+	dist := math.Hypot(float64(x), float64(y))
+
+	switch d.state {
+	case stateRest:
+		if dist <= d.thresholds.Rest {
+			return 0
+		}
+		d.state = stateMoving
+		d.start = at
+		d.visits = d.visits[:0]
+		d.circled = false
+		// the first report can already be at the edge
+		return d.moving(x, y, dist, at)
+	case stateMoving:
+		if dist <= d.thresholds.Rest {
+			d.state = stateRest
+			return d.flick(at)
+		}
+		return d.moving(x, y, dist, at)
+	}
+	return 0
+}
+
+// moving tracks the directions visited at the edge and returns [Circle] when
+// the last ones make a full rotation.
+func (d *Detector) moving(x, y float32, dist float64, at time.Time) Gesture {
+	if dist < d.thresholds.Edge {
+		return 0
+	}
+
+	dir := sector(x, y)
+	if n := len(d.visits); n > 0 && d.visits[n-1].dir == dir {
+		return 0
+	}
+	d.visits = append(d.visits, edgeVisit{dir: dir, at: at})
+
+	if !isCircle(d.visits) {
+		return 0
+	}
+	first := d.visits[len(d.visits)-circleVisits]
+	if at.Sub(first.at) > d.thresholds.CircleTime {
+		return 0
+	}
+
+	d.circled = true
+	// start counting the next circle from the current direction
+	d.visits = d.visits[len(d.visits)-1:]
+	return Circle
+}
+
+// flick returns the flick completed by returning to the centre, if the stick
+// reached the edge in a single direction quickly enough.
+func (d *Detector) flick(at time.Time) Gesture {
+	if d.circled || len(d.visits) != 1 {
+		return 0
+	}
+	if at.Sub(d.start) > d.thresholds.FlickTime {
+		return 0
+	}
+	return FlickUp + Gesture(d.visits[0].dir)
+}
+
+// circleVisits is the number of edge visits in a full circle: the four
+// directions and back to the first.
+const circleVisits = 5
+
+// isCircle returns true if the last visits go through all the directions and
+// back to the first, each one next to the previous, going the same way round.
+func isCircle(visits []edgeVisit) bool {
+	if len(visits) < circleVisits {
+		return false
+	}
+	last := visits[len(visits)-circleVisits:]
+
+	step := func(a, b direction) int {
+		switch (b - a + 4) % 4 {
+		case 1:
+			return 1
+		case 3:
+			return -1
+		}
+		return 0
+	}
+
+	rotation := step(last[0].dir, last[1].dir)
+	if rotation == 0 {
+		return false
+	}
+	for idx := 1; idx < len(last)-1; idx++ {
+		if step(last[idx].dir, last[idx+1].dir) != rotation {
+			return false
+		}
+	}
+	return true
+}
+
+// sector returns the direction of the position by its dominant axis.
+func sector(x, y float32) direction {
+	if math.Abs(float64(x)) > math.Abs(float64(y)) {
+		if x > 0 {
+			return dirRight
+		}
+		return dirLeft
+	}
+	if y > 0 {
+		return dirDown
+	}
+	return dirUp
+}
@@ -0,0 +1,152 @@
+package gesture_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/achilleas-k/gg13/internal/gesture"
+	"github.com/stretchr/testify/assert"
+)
+
+type sample struct {
+	x, y float32
+	dt   time.Duration
+}
+
+// run feeds the samples to a detector with the default thresholds, each one
+// dt after the previous, and returns the detected gestures.
+func run(samples []sample) []gesture.Gesture {
+	detector := gesture.NewDetector(gesture.DefaultThresholds())
+	now := time.Now()
+	detected := []gesture.Gesture{}
+	for _, s := range samples {
+		now = now.Add(s.dt)
+		if g := detector.Update(s.x, s.y, now); g != 0 {
+			detected = append(detected, g)
+		}
+	}
+	return detected
+}
+
+func TestDetector(t *testing.T) {
+	const step = 20 * time.Millisecond
+
+	circle := []sample{
+		{0, -1, step}, {1, 0, step}, {0, 1, step}, {-1, 0, step}, {0, -1, step},
+	}
+
+	type testCase struct {
+		samples  []sample
+		expected []gesture.Gesture
+	}
+
+	testCases := map[string]testCase{
+		"flick-up": {
+			samples:  []sample{{0, 0, 0}, {0, -0.5, step}, {0, -1, step}, {0, 0, step}},
+			expected: []gesture.Gesture{gesture.FlickUp},
+		},
+		"flick-right": {
+			samples:  []sample{{0.9, 0.1, step}, {0, 0, step}},
+			expected: []gesture.Gesture{gesture.FlickRight},
+		},
+		"flick-down": {
+			samples:  []sample{{0, 1, step}, {0, 0.1, step}},
+			expected: []gesture.Gesture{gesture.FlickDown},
+		},
+		"flick-left": {
+			samples:  []sample{{-1, 0, step}, {0, 0, step}},
+			expected: []gesture.Gesture{gesture.FlickLeft},
+		},
+		"slow-flick": {
+			samples:  []sample{{0, -1, step}, {0, -1, 300 * time.Millisecond}, {0, 0, step}},
+			expected: []gesture.Gesture{},
+		},
+		"no-edge": {
+			samples:  []sample{{0, -0.6, step}, {0, 0, step}},
+			expected: []gesture.Gesture{},
+		},
+		"two-directions": {
+			samples:  []sample{{0, -1, step}, {1, 0, step}, {0, 0, step}},
+			expected: []gesture.Gesture{},
+		},
+		"circle-clockwise": {
+			samples:  append(circle, sample{0, 0, step}),
+			expected: []gesture.Gesture{gesture.Circle},
+		},
+		"circle-anticlockwise": {
+			samples: []sample{
+				{0, -1, step}, {-1, 0, step}, {0, 1, step}, {1, 0, step}, {0, -1, step}, {0, 0, step},
+			},
+			expected: []gesture.Gesture{gesture.Circle},
+		},
+		"two-circles": {
+			samples:  append(append(circle, circle[1:]...), sample{0, 0, step}),
+			expected: []gesture.Gesture{gesture.Circle, gesture.Circle},
+		},
+		"slow-circle": {
+			samples: []sample{
+				{0, -1, step}, {1, 0, 300 * time.Millisecond}, {0, 1, 300 * time.Millisecond}, {-1, 0, 300 * time.Millisecond}, {0, -1, 300 * time.Millisecond},
+			},
+			expected: []gesture.Gesture{},
+		},
+		"three-quarters": {
+			samples:  []sample{{0, -1, step}, {1, 0, step}, {0, 1, step}, {-1, 0, step}, {0, 0, step}},
+			expected: []gesture.Gesture{},
+		},
+		"back-and-forth": {
+			samples: []sample{
+				{0, -1, step}, {1, 0, step}, {0, -1, step}, {-1, 0, step}, {0, 0, step},
+			},
+			expected: []gesture.Gesture{},
+		},
+	}
+
+	for name := range testCases {
+		tc := testCases[name]
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, tc.expected, run(tc.samples))
+		})
+	}
+}
+
+func TestParse(t *testing.T) {
+	assert := assert.New(t)
+	for _, g := range []gesture.Gesture{gesture.FlickUp, gesture.FlickRight, gesture.FlickDown, gesture.FlickLeft, gesture.Circle} {
+		parsed, err := gesture.Parse(g.String())
+		assert.NoError(err)
+		assert.Equal(g, parsed)
+	}
+
+	_, err := gesture.Parse("wiggle")
+	assert.EqualError(err, "unknown gesture: wiggle")
+}
+
+func TestThresholdsValidate(t *testing.T) {
+	type testCase struct {
+		modify   func(*gesture.Thresholds)
+		expected string
+	}
+
+	testCases := map[string]testCase{
+		"default":     {modify: func(*gesture.Thresholds) {}},
+		"edge-zero":   {modify: func(th *gesture.Thresholds) { th.Edge = 0 }, expected: "edge must be greater than 0 and at most 1: 0"},
+		"edge-high":   {modify: func(th *gesture.Thresholds) { th.Edge = 1.5 }, expected: "edge must be greater than 0 and at most 1: 1.5"},
+		"rest-above":  {modify: func(th *gesture.Thresholds) { th.Rest = 0.9 }, expected: "rest must be at least 0 and less than edge (0.8): 0.9"},
+		"flick-time":  {modify: func(th *gesture.Thresholds) { th.FlickTime = 0 }, expected: "flick time must be positive: 0s"},
+		"circle-time": {modify: func(th *gesture.Thresholds) { th.CircleTime = -time.Second }, expected: "circle time must be positive: -1s"},
+	}
+
+	for name := range testCases {
+		tc := testCases[name]
+		t.Run(name, func(t *testing.T) {
+			th := gesture.DefaultThresholds()
+			tc.modify(&th)
+			err := th.Validate()
+			if tc.expected == "" {
+				assert.NoError(t, err)
+			} else {
+				assert.EqualError(t, err, tc.expected)
+			}
+		})
+	}
+}
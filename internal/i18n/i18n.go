@@ -0,0 +1,128 @@
+// Package i18n translates the status and error messages that the command
+// line prints. The translations are catalogues of JSON files in the locales
+// directory, one per language or language and territory, like de.json or
+// pt_BR.json, which map the English format strings in the code to their
+// translation. Messages without a translation are printed in English.
+//
+// A translation has to use the same formatting verbs as the English string,
+// which the tests check, but can reorder them with explicit argument indexes,
+// like %[2]s.
+//
+// Only the terminal output is translated: the LCD font has no characters
+// beyond ASCII. The daemon's status lines, the errors it prints or stops
+// with, and the hints for fixing them are translated, but the details they
+// wrap, like why a config is invalid or what the USB library reported, stay
+// in English. Tests of the command check that every catalogue translates
+// every message, and that nothing is printed on stderr untranslated.
+package i18n
+
+import (
+	"embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"slices"
+	"strings"
+)
+
+//go:embed locales/*.json
+var locales embed.FS
+
+// Catalogue maps English format strings to their translations.
+type Catalogue map[string]string
+
+// current is the catalogue of the messages, set once at startup.
+var current Catalogue
+
+// Locales returns the names of the locales with a catalogue, sorted.
+func Locales() []string {
+	files, _ := fs.Glob(locales, "locales/*.json")
+	names := make([]string, 0, len(files))
+	for _, file := range files {
+		names = append(names, strings.TrimSuffix(path.Base(file), ".json"))
+	}
+	slices.Sort(names)
+	return names
+}
+
+// Load returns the catalogue for the locale, like de_DE.UTF-8, falling back
+// from the language and territory to the language. It returns nil if there's
+// no catalogue for either, which leaves the messages in English.
+func Load(locale string) (Catalogue, error) {
+	// the codeset and modifier don't select a catalogue
+	locale, _, _ = strings.Cut(locale, ".")
+	locale, _, _ = strings.Cut(locale, "@")
+	lang, _, _ := strings.Cut(locale, "_")
+	for _, name := range []string{locale, lang} {
+		if name == "" {
+			continue
+		}
+		data, err := locales.ReadFile("locales/" + name + ".json")
+		if errors.Is(err, fs.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		var catalogue Catalogue
+		if err := json.Unmarshal(data, &catalogue); err != nil {
+			return nil, fmt.Errorf("invalid catalogue %s: %w", name, err)
+		}
+		return catalogue, nil
+	}
+	return nil, nil
+}
+
+// Detect returns the locale of the messages from the environment, like
+// gettext: the first language of LANGUAGE, or LC_ALL, LC_MESSAGES, or LANG,
+// whichever is set first. LANGUAGE is ignored for the C locale. It returns an
+// empty string if none is set.
+func Detect(getenv func(string) string) string {
+	var locale string
+	for _, name := range []string{"LC_ALL", "LC_MESSAGES", "LANG"} {
+		if locale = getenv(name); locale != "" {
+			break
+		}
+	}
+	if locale == "C" || locale == "POSIX" || strings.HasPrefix(locale, "C.") {
+		return ""
+	}
+	if languages := getenv("LANGUAGE"); languages != "" {
+		first, _, _ := strings.Cut(languages, ":")
+		return first
+	}
+	return locale
+}
+
+// SetLocale translates the messages to the locale from now on. It isn't safe
+// to call while messages are translated.
+func SetLocale(locale string) error {
+	catalogue, err := Load(locale)
+	if err != nil {
+		return err
+	}
+	current = catalogue
+	return nil
+}
+
+// T returns the translation of the message, or the message itself if it has
+// none.
+func T(msg string) string {
+	if translated, ok := current[msg]; ok && translated != "" {
+		return translated
+	}
+	return msg
+}
+
+// Sprintf formats the translation of the format string.
+func Sprintf(format string, args ...any) string {
+	return fmt.Sprintf(T(format), args...)
+}
+
+// Errorf returns an error with the translation of the format string, which
+// wraps the %w arguments like [fmt.Errorf].
+func Errorf(format string, args ...any) error {
+	return fmt.Errorf(T(format), args...)
+}
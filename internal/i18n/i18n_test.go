@@ -0,0 +1,114 @@
+package i18n_test
+
+import (
+	"regexp"
+	"testing"
+
+	"github.com/achilleas-k/gg13/internal/i18n"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDetect(t *testing.T) {
+	type testCase struct {
+		env      map[string]string
+		expected string
+	}
+
+	testCases := map[string]testCase{
+		"none": {
+			env:      map[string]string{},
+			expected: "",
+		},
+		"lang": {
+			env:      map[string]string{"LANG": "de_DE.UTF-8"},
+			expected: "de_DE.UTF-8",
+		},
+		"lc-messages-over-lang": {
+			env:      map[string]string{"LANG": "en_GB.UTF-8", "LC_MESSAGES": "de_AT.UTF-8"},
+			expected: "de_AT.UTF-8",
+		},
+		"lc-all-over-lc-messages": {
+			env:      map[string]string{"LC_ALL": "fr_FR", "LC_MESSAGES": "de_AT.UTF-8"},
+			expected: "fr_FR",
+		},
+		"language-first": {
+			env:      map[string]string{"LANGUAGE": "de:fr", "LANG": "en_GB.UTF-8"},
+			expected: "de",
+		},
+		"c-ignores-language": {
+			env:      map[string]string{"LANGUAGE": "de", "LC_ALL": "C.UTF-8"},
+			expected: "",
+		},
+		"posix": {
+			env:      map[string]string{"LANG": "POSIX"},
+			expected: "",
+		},
+	}
+
+	for name := range testCases {
+		tc := testCases[name]
+		t.Run(name, func(t *testing.T) {
+			getenv := func(key string) string { return tc.env[key] }
+			assert.Equal(t, tc.expected, i18n.Detect(getenv))
+		})
+	}
+}
+
+func TestLoad(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	for _, locale := range []string{"de", "de_DE", "de_AT.UTF-8", "de_DE.UTF-8@euro"} {
+		catalogue, err := i18n.Load(locale)
+		require.NoError(err)
+		assert.Equal("Bereit", catalogue["Ready"], locale)
+	}
+
+	for _, locale := range []string{"", "en_GB.UTF-8", "xx"} {
+		catalogue, err := i18n.Load(locale)
+		require.NoError(err)
+		assert.Nil(catalogue, locale)
+	}
+}
+
+func TestSetLocale(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+	t.Cleanup(func() { _ = i18n.SetLocale("") })
+
+	require.NoError(i18n.SetLocale("de_DE.UTF-8"))
+	assert.Equal("Profil drive aktiv", i18n.Sprintf("Profile %s active", "drive"))
+	// untranslated messages stay in English
+	assert.Equal("no translation 1", i18n.Sprintf("no translation %d", 1))
+	assert.EqualError(i18n.Errorf("Switched output to %s", "mqtt"), "Ausgabe auf mqtt umgeschaltet")
+
+	require.NoError(i18n.SetLocale(""))
+	assert.Equal("Profile drive active", i18n.Sprintf("Profile %s active", "drive"))
+}
+
+// verbs matches the formatting verbs of a format string, without the explicit
+// argument indexes.
+var verbs = regexp.MustCompile(`%(?:\[\d+\])?([-+# 0]*[\d.*]*[a-zA-Z%])`)
+
+func TestCataloguesKeepVerbs(t *testing.T) {
+	locales := i18n.Locales()
+	require.NotEmpty(t, locales)
+	for _, locale := range locales {
+		t.Run(locale, func(t *testing.T) {
+			catalogue, err := i18n.Load(locale)
+			require.NoError(t, err)
+			for msg, translated := range catalogue {
+				assert.ElementsMatch(t, verbsOf(msg), verbsOf(translated), "%s: %q", locale, msg)
+			}
+		})
+	}
+}
+
+func verbsOf(format string) []string {
+	var found []string
+	for _, match := range verbs.FindAllStringSubmatch(format, -1) {
+		found = append(found, match[1])
+	}
+	return found
+}
@@ -0,0 +1,149 @@
+package joystick
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"time"
+	"unsafe"
+
+	"github.com/achilleas-k/gg13/internal/i18n"
+)
+
+// Definitions from linux/uinput.h and linux/input.h for handling the force
+// feedback effects that games upload to the virtual joystick.
+const (
+	evUinput = 0x0101
+
+	uiFFUpload = 1
+	uiFFErase  = 2
+
+	uiBeginFFUpload = 0xc06855c8
+	uiEndFFUpload   = 0x406855c9
+	uiBeginFFErase  = 0xc00c55ca
+	uiEndFFErase    = 0x400c55cb
+
+	ffRumble = 0x50
+
+	// codes of EV_FF events from this one on are settings, like the gain,
+	// not effects
+	ffGain = 0x60
+
+	// effects a game can upload at the same time
+	ffEffectsMax = 16
+
+	// offsets in struct ff_effect
+	ffEffectIDOffset     = 2
+	ffEffectLengthOffset = 10
+
+	inputEventSize = int(unsafe.Sizeof(inputEvent{}))
+)
+
+// FFEvent is a force feedback effect started or stopped by a game.
+type FFEvent struct {
+	Playing bool
+
+	// Length is how long the effect plays for, or zero if it plays until
+	// it's stopped.
+	Length time.Duration
+}
+
+// uinputFFUpload is struct uinput_ff_upload, with the two struct ff_effect
+// as bytes.
+type uinputFFUpload struct {
+	RequestID uint32
+	Retval    int32
+	Effect    [48]byte
+	Old       [48]byte
+}
+
+// uinputFFErase is struct uinput_ff_erase.
+type uinputFFErase struct {
+	RequestID uint32
+	Retval    int32
+	EffectID  uint32
+}
+
+// OnForceFeedback sets a function to call, from the goroutine reading the
+// requests of games, when an effect starts or stops. It only has an effect
+// if the joystick was created with force feedback.
+func (vjs *UinputJoystick) OnForceFeedback(f func(FFEvent)) {
+	vjs.ffMu.Lock()
+	defer vjs.ffMu.Unlock()
+	vjs.onFF = f
+}
+
+// readFF accepts the effects that games upload and reports when they're
+// played, until the file is closed.
+func (vjs *UinputJoystick) readFF(file *os.File) {
+	// effect lengths by ID, from their upload
+	lengths := make(map[int16]time.Duration)
+	buf := make([]byte, inputEventSize*16)
+	for {
+		n, err := file.Read(buf)
+		if errors.Is(err, fs.ErrClosed) {
+			return
+		}
+		if err != nil {
+			fmt.Fprintln(os.Stderr, i18n.Sprintf("joystick: failed reading force feedback requests: %s", err))
+			return
+		}
+
+		for data := buf[:n]; len(data) >= inputEventSize; data = data[inputEventSize:] {
+			var ev inputEvent
+			if err := binary.Read(bytes.NewReader(data[:inputEventSize]), binary.LittleEndian, &ev); err != nil {
+				continue
+			}
+			if err := vjs.handleFFEvent(file, ev, lengths); err != nil {
+				fmt.Fprintln(os.Stderr, i18n.Sprintf("joystick: %s", err))
+			}
+		}
+	}
+}
+
+func (vjs *UinputJoystick) handleFFEvent(file *os.File, ev inputEvent, lengths map[int16]time.Duration) error {
+	switch {
+	case ev.Type == evUinput && ev.Code == uiFFUpload:
+		upload := uinputFFUpload{RequestID: uint32(ev.Value)}
+		if err := ioctlPtr(file, uiBeginFFUpload, unsafe.Pointer(&upload)); err != nil {
+			return fmt.Errorf("failed reading force feedback upload: %w", err)
+		}
+		id, length := effectInfo(upload.Effect)
+		lengths[id] = length
+		upload.Retval = 0
+		if err := ioctlPtr(file, uiEndFFUpload, unsafe.Pointer(&upload)); err != nil {
+			return fmt.Errorf("failed accepting force feedback upload: %w", err)
+		}
+
+	case ev.Type == evUinput && ev.Code == uiFFErase:
+		erase := uinputFFErase{RequestID: uint32(ev.Value)}
+		if err := ioctlPtr(file, uiBeginFFErase, unsafe.Pointer(&erase)); err != nil {
+			return fmt.Errorf("failed reading force feedback erase: %w", err)
+		}
+		delete(lengths, int16(erase.EffectID))
+		erase.Retval = 0
+		if err := ioctlPtr(file, uiEndFFErase, unsafe.Pointer(&erase)); err != nil {
+			return fmt.Errorf("failed accepting force feedback erase: %w", err)
+		}
+
+	case ev.Type == evFF && ev.Code < ffGain:
+		vjs.ffMu.Lock()
+		onFF := vjs.onFF
+		vjs.ffMu.Unlock()
+		if onFF != nil {
+			// the value is the number of times to play the effect
+			onFF(FFEvent{Playing: ev.Value > 0, Length: lengths[int16(ev.Code)]})
+		}
+	}
+	return nil
+}
+
+// effectInfo returns the ID and length of the struct ff_effect.
+func effectInfo(effect [48]byte) (int16, time.Duration) {
+	id := int16(binary.LittleEndian.Uint16(effect[ffEffectIDOffset:]))
+	length := binary.LittleEndian.Uint16(effect[ffEffectLengthOffset:])
+	return id, time.Duration(length) * time.Millisecond
+}
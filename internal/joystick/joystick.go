@@ -1,9 +1,22 @@
 package joystick
 
 import (
+	"errors"
 	"fmt"
+	"os"
+	"sync"
+)
+
+// ErrUinputUnavailable is returned when the virtual gamepad can't be created,
+// usually because the uinput module isn't loaded or /dev/uinput isn't
+// writable.
+var ErrUinputUnavailable = errors.New("uinput unavailable")
 
-	"github.com/bendahl/uinput"
+const (
+	uinputPath = "/dev/uinput"
+
+	// MaxAxisValue is the default limit of the stick axes in both directions.
+	MaxAxisValue = 32767
 )
 
 type Joystick interface {
@@ -12,20 +25,154 @@ type Joystick interface {
 	ButtonDown(b int) error
 	ButtonUp(b int) error
 	StickPosition(x, y float32) error
+	HatPosition(x, y int) error
+}
+
+// Options describes the capabilities advertised by the virtual joystick.
+type Options struct {
+	VendorID  uint16
+	ProductID uint16
+
+	// AxisMin and AxisMax are the limits of the stick axes. Fuzz and Flat
+	// are passed to the kernel as the noise filter and the size of the
+	// dead zone in the centre, in axis units.
+	AxisMin int32
+	AxisMax int32
+	Fuzz    int32
+	Flat    int32
+
+	// Buttons is the number of buttons
+	Buttons int
+
+	// Hat adds a hat switch (D-pad)
+	Hat bool
+
+	// Axes are the axes the stick is sent to.
+	Axes StickAxes
+
+	// ForceFeedback advertises rumble support, which some games require.
+	// The effects aren't played, but can be followed with
+	// [UinputJoystick.OnForceFeedback].
+	ForceFeedback bool
+}
+
+// Axis is an absolute axis of the joystick that an axis of the stick can be
+// sent to.
+type Axis string
+
+const (
+	AxisX        Axis = "x"
+	AxisY        Axis = "y"
+	AxisRX       Axis = "rx"
+	AxisRY       Axis = "ry"
+	AxisThrottle Axis = "throttle"
+	AxisRudder   Axis = "rudder"
+)
+
+var axisCodes = map[Axis]uint16{
+	AxisX:        absX,
+	AxisY:        absY,
+	AxisRX:       absRX,
+	AxisRY:       absRY,
+	AxisThrottle: absThrottle,
+	AxisRudder:   absRudder,
+}
+
+// StickAxes are the axes of the joystick that the x and y axes of the stick
+// are sent to, and whether each is inverted. Empty axes use [AxisX] and
+// [AxisY].
+type StickAxes struct {
+	X       Axis
+	Y       Axis
+	InvertX bool
+	InvertY bool
+}
+
+// codes returns the event codes of the axes the stick is sent to.
+func (a StickAxes) codes() (uint16, uint16) {
+	x, y := a.X, a.Y
+	if x == "" {
+		x = AxisX
+	}
+	if y == "" {
+		y = AxisY
+	}
+	return axisCodes[x], axisCodes[y]
+}
+
+// Validate returns an error if an axis is unknown or both stick axes are
+// sent to the same one.
+func (a StickAxes) Validate() error {
+	for _, axis := range []Axis{a.X, a.Y} {
+		if _, ok := axisCodes[axis]; axis != "" && !ok {
+			return fmt.Errorf("unknown axis: %s", axis)
+		}
+	}
+	if x, y := a.codes(); x == y {
+		return fmt.Errorf("the stick axes must be sent to different axes")
+	}
+	return nil
+}
+
+// DefaultOptions returns options for a joystick with a stick using the full
+// axis range and no buttons or hat.
+func DefaultOptions() Options {
+	return Options{
+		VendorID:  12,
+		ProductID: 12,
+		AxisMin:   -MaxAxisValue,
+		AxisMax:   MaxAxisValue,
+	}
+}
+
+func (opts Options) validate() error {
+	if opts.AxisMin >= opts.AxisMax {
+		return fmt.Errorf("axis minimum (%d) must be less than the maximum (%d)", opts.AxisMin, opts.AxisMax)
+	}
+	if opts.Fuzz < 0 || opts.Flat < 0 {
+		return fmt.Errorf("axis fuzz and flat must not be negative")
+	}
+	if opts.Buttons < 0 {
+		return fmt.Errorf("number of buttons must not be negative")
+	}
+	return opts.Axes.Validate()
 }
 
 type UinputJoystick struct {
-	js uinput.Gamepad
+	file *os.File
+	opts Options
+
+	ffMu sync.Mutex
+	onFF func(FFEvent)
 }
 
-func New(name string) (Joystick, error) {
-	js, err := uinput.CreateGamepad("/dev/uinput", []byte(name), 12, 12)
+// New returns a [Joystick] backed by a uinput device with the given name and
+// capabilities.
+func New(name string, opts Options) (Joystick, error) {
+	if err := opts.validate(); err != nil {
+		return nil, fmt.Errorf("invalid joystick options: %w", err)
+	}
+	file, err := createDevice(uinputPath, name, opts)
 	if err != nil {
-		return nil, err
+		return nil, fmt.Errorf("%w: %w", ErrUinputUnavailable, err)
 	}
-	return &UinputJoystick{
-		js: js,
-	}, nil
+	vjs := &UinputJoystick{
+		file: file,
+		opts: opts,
+	}
+	if opts.ForceFeedback {
+		go vjs.readFF(file)
+	}
+	return vjs, nil
+}
+
+// Syspath returns the sysfs directory of the uinput device, for finding its
+// event device node.
+func (vjs *UinputJoystick) Syspath() (string, error) {
+	if !vjs.hasJoystick() {
+		return "", fmt.Errorf("joystick not initialised")
+	}
+	return syspath(vjs.file)
 }
 
 func (vjs *UinputJoystick) Close() error {
@@ -33,37 +180,85 @@ func (vjs *UinputJoystick) Close() error {
 		// just do nothing
 		return nil
 	}
-	return vjs.js.Close()
+	err := destroyDevice(vjs.file)
+	vjs.file = nil
+	return err
 }
 
-func (vjs *UinputJoystick) ButtonPress(k int) error {
-	if !vjs.hasJoystick() {
-		return fmt.Errorf("button press before initialising joystick")
+// ButtonPress presses and releases the button with the given index.
+func (vjs *UinputJoystick) ButtonPress(b int) error {
+	if err := vjs.ButtonDown(b); err != nil {
+		return err
 	}
-	return vjs.js.ButtonPress(k)
+	return vjs.ButtonUp(b)
 }
 
-func (vjs *UinputJoystick) ButtonDown(k int) error {
+// ButtonDown presses the button with the given index.
+func (vjs *UinputJoystick) ButtonDown(b int) error {
 	if !vjs.hasJoystick() {
 		return fmt.Errorf("button down before initialising joystick")
 	}
-	return vjs.js.ButtonDown(k)
+	return vjs.button(b, 1)
 }
 
-func (vjs *UinputJoystick) ButtonUp(k int) error {
+// ButtonUp releases the button with the given index.
+func (vjs *UinputJoystick) ButtonUp(b int) error {
 	if !vjs.hasJoystick() {
 		return fmt.Errorf("button up before initialising joystick")
 	}
-	return vjs.js.ButtonUp(k)
+	return vjs.button(b, 0)
 }
 
+func (vjs *UinputJoystick) button(b int, value int32) error {
+	if b < 0 || b >= vjs.opts.Buttons {
+		return fmt.Errorf("button %d out of range: joystick has %d buttons", b, vjs.opts.Buttons)
+	}
+	return writeEvents(vjs.file, inputEvent{Type: evKey, Code: buttonCode(b), Value: value})
+}
+
+// StickPosition moves the stick. The position on each axis is in the range
+// [-1, 1] and is scaled to the axis limits of the axis it's sent to.
 func (vjs *UinputJoystick) StickPosition(x, y float32) error {
 	if !vjs.hasJoystick() {
 		return fmt.Errorf("stick position set before initialising joystick")
 	}
-	return vjs.js.LeftStickMove(x, y)
+	if vjs.opts.Axes.InvertX {
+		x = -x
+	}
+	if vjs.opts.Axes.InvertY {
+		y = -y
+	}
+	xCode, yCode := vjs.opts.Axes.codes()
+	return writeEvents(vjs.file,
+		inputEvent{Type: evAbs, Code: xCode, Value: scaleAxis(x, vjs.opts.AxisMin, vjs.opts.AxisMax)},
+		inputEvent{Type: evAbs, Code: yCode, Value: scaleAxis(y, vjs.opts.AxisMin, vjs.opts.AxisMax)},
+	)
+}
+
+// HatPosition moves the hat switch. Each direction is -1, 0, or 1.
+func (vjs *UinputJoystick) HatPosition(x, y int) error {
+	if !vjs.hasJoystick() {
+		return fmt.Errorf("hat position set before initialising joystick")
+	}
+	if !vjs.opts.Hat {
+		return fmt.Errorf("joystick has no hat")
+	}
+	if x < -1 || x > 1 || y < -1 || y > 1 {
+		return fmt.Errorf("hat position %d,%d out of range", x, y)
+	}
+	return writeEvents(vjs.file,
+		inputEvent{Type: evAbs, Code: absHat0X, Value: int32(x)},
+		inputEvent{Type: evAbs, Code: absHat0Y, Value: int32(y)},
+	)
 }
 
 func (vjs *UinputJoystick) hasJoystick() bool {
-	return vjs.js != nil
+	return vjs.file != nil
+}
+
+// scaleAxis maps a position in [-1, 1] linearly onto [minVal, maxVal].
+func scaleAxis(pos float32, minVal, maxVal int32) int32 {
+	pos = max(-1, min(1, pos))
+	span := float64(maxVal) - float64(minVal)
+	return minVal + int32((float64(pos)+1)/2*span+0.5)
 }
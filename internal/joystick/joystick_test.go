@@ -0,0 +1,149 @@
+package joystick
+
+import (
+	"testing"
+	"time"
+	"unsafe"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestScaleAxis(t *testing.T) {
+	type testCase struct {
+		pos      float32
+		min      int32
+		max      int32
+		expected int32
+	}
+
+	testCases := map[string]testCase{
+		"centre":          {pos: 0, min: -MaxAxisValue, max: MaxAxisValue, expected: 0},
+		"min":             {pos: -1, min: -MaxAxisValue, max: MaxAxisValue, expected: -MaxAxisValue},
+		"max":             {pos: 1, min: -MaxAxisValue, max: MaxAxisValue, expected: MaxAxisValue},
+		"half":            {pos: 0.5, min: -MaxAxisValue, max: MaxAxisValue, expected: 16384},
+		"clamp-low":       {pos: -2, min: -MaxAxisValue, max: MaxAxisValue, expected: -MaxAxisValue},
+		"clamp-high":      {pos: 2, min: -MaxAxisValue, max: MaxAxisValue, expected: MaxAxisValue},
+		"unsigned-min":    {pos: -1, min: 0, max: 255, expected: 0},
+		"unsigned-max":    {pos: 1, min: 0, max: 255, expected: 255},
+		"unsigned-centre": {pos: 0, min: 0, max: 255, expected: 128},
+	}
+
+	for name := range testCases {
+		tc := testCases[name]
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, tc.expected, scaleAxis(tc.pos, tc.min, tc.max))
+		})
+	}
+}
+
+func TestUserDev(t *testing.T) {
+	assert := assert.New(t)
+
+	opts := DefaultOptions()
+	opts.Fuzz = 16
+	opts.Flat = 128
+	dev := userDev("g13-vjs", opts)
+	assert.Equal("g13-vjs", string(dev.Name[:7]))
+	assert.Equal(uint16(12), dev.ID.Vendor)
+	for _, axis := range []int{absX, absY} {
+		assert.Equal(int32(-MaxAxisValue), dev.Absmin[axis])
+		assert.Equal(int32(MaxAxisValue), dev.Absmax[axis])
+		assert.Equal(int32(16), dev.Absfuzz[axis])
+		assert.Equal(int32(128), dev.Absflat[axis])
+	}
+	assert.Zero(dev.Absmax[absHat0X])
+
+	opts.Hat = true
+	dev = userDev("g13-vjs", opts)
+	assert.Equal(int32(-1), dev.Absmin[absHat0X])
+	assert.Equal(int32(1), dev.Absmax[absHat0Y])
+
+	opts = DefaultOptions()
+	opts.Axes = StickAxes{X: AxisRudder, Y: AxisThrottle}
+	dev = userDev("g13-vjs", opts)
+	for _, axis := range []int{absRudder, absThrottle} {
+		assert.Equal(int32(-MaxAxisValue), dev.Absmin[axis])
+		assert.Equal(int32(MaxAxisValue), dev.Absmax[axis])
+	}
+	assert.Zero(dev.Absmax[absX])
+	assert.Zero(dev.Absmax[absY])
+}
+
+func TestOptionsValidate(t *testing.T) {
+	assert := assert.New(t)
+
+	assert.NoError(DefaultOptions().validate())
+
+	opts := DefaultOptions()
+	opts.AxisMin = opts.AxisMax
+	assert.EqualError(opts.validate(), "axis minimum (32767) must be less than the maximum (32767)")
+
+	opts = DefaultOptions()
+	opts.Flat = -1
+	assert.EqualError(opts.validate(), "axis fuzz and flat must not be negative")
+
+	opts = DefaultOptions()
+	opts.Buttons = -1
+	assert.EqualError(opts.validate(), "number of buttons must not be negative")
+
+	opts = DefaultOptions()
+	opts.Axes = StickAxes{X: "wheel"}
+	assert.EqualError(opts.validate(), "unknown axis: wheel")
+
+	opts = DefaultOptions()
+	opts.Axes = StickAxes{Y: AxisX}
+	assert.EqualError(opts.validate(), "the stick axes must be sent to different axes")
+}
+
+func TestButtonCode(t *testing.T) {
+	assert.Equal(t, uint16(btnJoystick), buttonCode(0))
+	assert.Equal(t, uint16(btnJoystick+15), buttonCode(15))
+	assert.Equal(t, uint16(btnTriggerHappy), buttonCode(16))
+}
+
+func TestFFDefinitions(t *testing.T) {
+	assert := assert.New(t)
+
+	// the size is encoded in bits 16-29 of the ioctl numbers
+	iocSize := func(cmd uintptr) uintptr { return (cmd >> 16) & 0x3fff }
+	assert.Equal(unsafe.Sizeof(uinputFFUpload{}), iocSize(uiBeginFFUpload))
+	assert.Equal(unsafe.Sizeof(uinputFFUpload{}), iocSize(uiEndFFUpload))
+	assert.Equal(unsafe.Sizeof(uinputFFErase{}), iocSize(uiBeginFFErase))
+	assert.Equal(unsafe.Sizeof(uinputFFErase{}), iocSize(uiEndFFErase))
+
+	opts := DefaultOptions()
+	assert.Zero(userDev("g13-vjs", opts).EffectsMax)
+	opts.ForceFeedback = true
+	assert.Equal(uint32(ffEffectsMax), userDev("g13-vjs", opts).EffectsMax)
+}
+
+func TestHandleFFEvent(t *testing.T) {
+	assert := assert.New(t)
+
+	var effect [48]byte
+	effect[ffEffectIDOffset] = 3
+	effect[ffEffectLengthOffset] = 0xf4 // 500 ms
+	effect[ffEffectLengthOffset+1] = 0x01
+	id, length := effectInfo(effect)
+	assert.Equal(int16(3), id)
+	assert.Equal(500*time.Millisecond, length)
+
+	var events []FFEvent
+	vjs := &UinputJoystick{}
+	vjs.OnForceFeedback(func(ev FFEvent) { events = append(events, ev) })
+
+	lengths := map[int16]time.Duration{3: length}
+	assert.NoError(vjs.handleFFEvent(nil, inputEvent{Type: evFF, Code: 3, Value: 1}, lengths))
+	assert.NoError(vjs.handleFFEvent(nil, inputEvent{Type: evFF, Code: 3, Value: 0}, lengths))
+	// unknown effects play until stopped
+	assert.NoError(vjs.handleFFEvent(nil, inputEvent{Type: evFF, Code: 4, Value: 1}, lengths))
+	// gain changes aren't effects
+	assert.NoError(vjs.handleFFEvent(nil, inputEvent{Type: evFF, Code: ffGain, Value: 0xffff}, lengths))
+
+	expected := []FFEvent{
+		{Playing: true, Length: 500 * time.Millisecond},
+		{Playing: false, Length: 500 * time.Millisecond},
+		{Playing: true},
+	}
+	assert.Equal(expected, events)
+}
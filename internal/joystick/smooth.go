@@ -0,0 +1,111 @@
+package joystick
+
+import (
+	"sync"
+	"time"
+)
+
+const (
+	// smoothTick is how often a smoothed stick moves towards the position
+	// last set on it, independently of how often the position is set.
+	smoothTick = 8 * time.Millisecond
+
+	// smoothSettled is how close to the position set on it a smoothed stick
+	// needs to be to stop moving, which is less than a step of the raw G13
+	// stick.
+	smoothSettled = 1.0 / 512
+)
+
+// Smoothed is a [Joystick] whose stick follows the positions set on it
+// through a low-pass filter, so that noise from the stick doesn't reach games
+// as movement. Every tick, the stick moves by a fraction of the remaining
+// distance, which is smaller the stronger the smoothing. It keeps moving
+// until it reaches the position, even if no new position is set.
+type Smoothed struct {
+	Joystick
+
+	// fraction of the remaining distance moved every tick
+	alpha float32
+
+	mu sync.Mutex
+	// position last set and the position of the stick
+	targetX, targetY float32
+	x, y             float32
+	timer            *time.Timer
+	// error from moving the stick on a tick, returned by the next
+	// StickPosition
+	err    error
+	closed bool
+}
+
+// NewSmoothed returns js with the stick smoothed with the strength, from 0
+// for none to less than 1.
+func NewSmoothed(js Joystick, strength float32) *Smoothed {
+	return &Smoothed{
+		Joystick: js,
+		alpha:    1 - strength,
+	}
+}
+
+// StickPosition sets the position the stick moves towards. The first step is
+// taken right away.
+func (s *Smoothed) StickPosition(x, y float32) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.targetX, s.targetY = x, y
+	if err := s.err; err != nil {
+		s.err = nil
+		return err
+	}
+	if s.timer != nil || s.closed {
+		// moving already
+		return nil
+	}
+	return s.step()
+}
+
+// step moves the stick towards the target and schedules the next step until
+// it gets there. The lock must be held.
+func (s *Smoothed) step() error {
+	dx, dy := s.targetX-s.x, s.targetY-s.y
+	settled := abs(dx) < smoothSettled && abs(dy) < smoothSettled
+	if settled {
+		s.x, s.y = s.targetX, s.targetY
+	} else {
+		s.x += s.alpha * dx
+		s.y += s.alpha * dy
+		s.timer = time.AfterFunc(smoothTick, s.tick)
+	}
+	return s.Joystick.StickPosition(s.x, s.y)
+}
+
+func (s *Smoothed) tick() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.timer = nil
+	if s.closed {
+		return
+	}
+	if err := s.step(); err != nil {
+		s.err = err
+	}
+}
+
+// Close stops moving the stick and closes the joystick.
+func (s *Smoothed) Close() error {
+	s.mu.Lock()
+	s.closed = true
+	if s.timer != nil {
+		s.timer.Stop()
+		s.timer = nil
+	}
+	s.mu.Unlock()
+	return s.Joystick.Close()
+}
+
+func abs(v float32) float32 {
+	if v < 0 {
+		return -v
+	}
+	return v
+}
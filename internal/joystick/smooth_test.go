@@ -0,0 +1,67 @@
+package joystick
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// stickRecorder records the stick positions set on it.
+type stickRecorder struct {
+	Joystick
+
+	mu        sync.Mutex
+	positions [][2]float32
+}
+
+func (r *stickRecorder) StickPosition(x, y float32) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.positions = append(r.positions, [2]float32{x, y})
+	return nil
+}
+
+func (r *stickRecorder) Close() error {
+	return nil
+}
+
+func (r *stickRecorder) last() ([2]float32, int) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.positions[len(r.positions)-1], len(r.positions)
+}
+
+func TestSmoothed(t *testing.T) {
+	assert := assert.New(t)
+
+	rec := &stickRecorder{}
+	js := NewSmoothed(rec, 0.75)
+
+	// the first step is taken right away
+	assert.NoError(js.StickPosition(1, -1))
+	pos, _ := rec.last()
+	assert.Equal([2]float32{0.25, -0.25}, pos)
+
+	// and the stick keeps moving until it gets there
+	assert.Eventually(func() bool {
+		pos, _ := rec.last()
+		return pos == [2]float32{1, -1}
+	}, time.Second, smoothTick)
+	_, n := rec.last()
+	assert.Greater(n, 3)
+
+	// it stops moving once it's there
+	time.Sleep(3 * smoothTick)
+	_, settledN := rec.last()
+	assert.Equal(n, settledN)
+
+	// and after it's closed
+	assert.NoError(js.StickPosition(0, 0))
+	assert.NoError(js.Close())
+	_, n = rec.last()
+	time.Sleep(3 * smoothTick)
+	_, closedN := rec.last()
+	assert.Equal(n, closedN)
+}
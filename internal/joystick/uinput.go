@@ -0,0 +1,248 @@
+package joystick
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"os"
+	"path/filepath"
+	"syscall"
+	"unsafe"
+)
+
+// Definitions from linux/uinput.h and linux/input-event-codes.h. The
+// gamepad from the uinput library has a fixed set of capabilities and
+// doesn't set the axis ranges, so the device is set up directly.
+const (
+	uinputMaxNameSize = 80
+	absSize           = 64
+
+	uiDevCreate  = 0x5501
+	uiDevDestroy = 0x5502
+	uiSetEvBit   = 0x40045564
+	uiSetKeyBit  = 0x40045565
+	uiSetAbsBit  = 0x40045567
+	uiSetFFBit   = 0x4004556b
+
+	// UI_GET_SYSNAME with a 65 byte buffer
+	uiGetSysname   = 0x8041552c
+	sysnameBufSize = 65
+
+	sysInputDir = "/sys/devices/virtual/input"
+
+	busUSB = 0x03
+
+	evSyn = 0x00
+	evKey = 0x01
+	evAbs = 0x03
+	evFF  = 0x15
+
+	synReport = 0
+
+	absX        = 0x00
+	absY        = 0x01
+	absRX       = 0x03
+	absRY       = 0x04
+	absThrottle = 0x06
+	absRudder   = 0x07
+	absHat0X    = 0x10
+	absHat0Y    = 0x11
+
+	// the first 16 buttons use the joystick button codes, the rest use the
+	// "trigger happy" range
+	btnJoystick      = 0x120
+	btnJoystickCount = 16
+	btnTriggerHappy  = 0x2c0
+)
+
+type inputID struct {
+	Bustype uint16
+	Vendor  uint16
+	Product uint16
+	Version uint16
+}
+
+type uinputUserDev struct {
+	Name       [uinputMaxNameSize]byte
+	ID         inputID
+	EffectsMax uint32
+	Absmax     [absSize]int32
+	Absmin     [absSize]int32
+	Absfuzz    [absSize]int32
+	Absflat    [absSize]int32
+}
+
+type inputEvent struct {
+	Time  syscall.Timeval
+	Type  uint16
+	Code  uint16
+	Value int32
+}
+
+// buttonCode returns the event code for the button with the given index.
+func buttonCode(idx int) uint16 {
+	if idx < btnJoystickCount {
+		return uint16(btnJoystick + idx)
+	}
+	return uint16(btnTriggerHappy + idx - btnJoystickCount)
+}
+
+// userDev returns the device description for the options.
+func userDev(name string, opts Options) uinputUserDev {
+	dev := uinputUserDev{
+		ID: inputID{
+			Bustype: busUSB,
+			Vendor:  opts.VendorID,
+			Product: opts.ProductID,
+			Version: 1,
+		},
+	}
+	if opts.ForceFeedback {
+		dev.EffectsMax = ffEffectsMax
+	}
+	copy(dev.Name[:uinputMaxNameSize-1], name)
+
+	x, y := opts.Axes.codes()
+	for _, axis := range []uint16{x, y} {
+		dev.Absmin[axis] = opts.AxisMin
+		dev.Absmax[axis] = opts.AxisMax
+		dev.Absfuzz[axis] = opts.Fuzz
+		dev.Absflat[axis] = opts.Flat
+	}
+	if opts.Hat {
+		for _, axis := range []int{absHat0X, absHat0Y} {
+			dev.Absmin[axis] = -1
+			dev.Absmax[axis] = 1
+		}
+	}
+	return dev
+}
+
+// createDevice creates the uinput device at path with the capabilities
+// described by the options.
+func createDevice(path, name string, opts Options) (*os.File, error) {
+	// read-write for the force feedback requests
+	file, err := os.OpenFile(path, os.O_RDWR|syscall.O_NONBLOCK, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	setup := func() error {
+		if opts.Buttons > 0 {
+			if err := ioctl(file, uiSetEvBit, evKey); err != nil {
+				return fmt.Errorf("failed enabling button events: %w", err)
+			}
+			for idx := range opts.Buttons {
+				if err := ioctl(file, uiSetKeyBit, uintptr(buttonCode(idx))); err != nil {
+					return fmt.Errorf("failed enabling button %d: %w", idx, err)
+				}
+			}
+		}
+
+		if err := ioctl(file, uiSetEvBit, evAbs); err != nil {
+			return fmt.Errorf("failed enabling axis events: %w", err)
+		}
+		x, y := opts.Axes.codes()
+		axes := []uintptr{uintptr(x), uintptr(y)}
+		if opts.Hat {
+			axes = append(axes, absHat0X, absHat0Y)
+		}
+		for _, axis := range axes {
+			if err := ioctl(file, uiSetAbsBit, axis); err != nil {
+				return fmt.Errorf("failed enabling axis %d: %w", axis, err)
+			}
+		}
+
+		if opts.ForceFeedback {
+			if err := ioctl(file, uiSetEvBit, evFF); err != nil {
+				return fmt.Errorf("failed enabling force feedback events: %w", err)
+			}
+			if err := ioctl(file, uiSetFFBit, ffRumble); err != nil {
+				return fmt.Errorf("failed enabling rumble: %w", err)
+			}
+		}
+
+		buf := new(bytes.Buffer)
+		if err := binary.Write(buf, binary.LittleEndian, userDev(name, opts)); err != nil {
+			return err
+		}
+		if _, err := file.Write(buf.Bytes()); err != nil {
+			return fmt.Errorf("failed writing device description: %w", err)
+		}
+		if err := ioctl(file, uiDevCreate, 0); err != nil {
+			return fmt.Errorf("failed creating device: %w", err)
+		}
+		return nil
+	}
+
+	if err := setup(); err != nil {
+		_ = file.Close()
+		return nil, err
+	}
+	return file, nil
+}
+
+// destroyDevice removes the uinput device and closes the file.
+func destroyDevice(file *os.File) error {
+	if err := ioctl(file, uiDevDestroy, 0); err != nil {
+		_ = file.Close()
+		return fmt.Errorf("failed destroying device: %w", err)
+	}
+	return file.Close()
+}
+
+// writeEvents writes the events to the device followed by a sync report.
+func writeEvents(file *os.File, events ...inputEvent) error {
+	events = append(events, inputEvent{Type: evSyn, Code: synReport})
+	buf := new(bytes.Buffer)
+	for _, ev := range events {
+		if err := binary.Write(buf, binary.LittleEndian, ev); err != nil {
+			return err
+		}
+	}
+	_, err := file.Write(buf.Bytes())
+	return err
+}
+
+// syspath returns the sysfs directory of the uinput device.
+func syspath(file *os.File) (string, error) {
+	buf := make([]byte, sysnameBufSize)
+	if err := ioctlPtr(file, uiGetSysname, unsafe.Pointer(&buf[0])); err != nil {
+		return "", fmt.Errorf("failed getting device name: %w", err)
+	}
+	return filepath.Join(sysInputDir, string(bytes.TrimRight(buf, "\x00"))), nil
+}
+
+// ioctl runs the ioctl on the device.
+func ioctl(file *os.File, cmd, arg uintptr) error {
+	return control(file, func(fd uintptr) syscall.Errno {
+		_, _, errno := syscall.Syscall(syscall.SYS_IOCTL, fd, cmd, arg)
+		return errno
+	})
+}
+
+// ioctlPtr runs an ioctl taking a pointer on the device.
+func ioctlPtr(file *os.File, cmd uintptr, arg unsafe.Pointer) error {
+	return control(file, func(fd uintptr) syscall.Errno {
+		_, _, errno := syscall.Syscall(syscall.SYS_IOCTL, fd, cmd, uintptr(arg))
+		return errno
+	})
+}
+
+// control runs the system call on the file descriptor of the device. It
+// doesn't use [os.File.Fd], which would make the file blocking, so that
+// reading force feedback requests can be interrupted by closing the file.
+func control(file *os.File, call func(fd uintptr) syscall.Errno) error {
+	conn, err := file.SyscallConn()
+	if err != nil {
+		return err
+	}
+	var errno syscall.Errno
+	if err := conn.Control(func(fd uintptr) { errno = call(fd) }); err != nil {
+		return err
+	}
+	if errno != 0 {
+		return errno
+	}
+	return nil
+}
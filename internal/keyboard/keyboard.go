@@ -1,31 +1,42 @@
 package keyboard
 
 import (
+	"errors"
 	"fmt"
-
-	"github.com/bendahl/uinput"
+	"os"
+	"slices"
 )
 
+// ErrUinputUnavailable is returned when the virtual keyboard can't be created,
+// usually because the uinput module isn't loaded or /dev/uinput isn't
+// writable.
+var ErrUinputUnavailable = errors.New("uinput unavailable")
+
 type Keyboard interface {
 	Close() error
 	KeyPress(k int) error
 	KeyDown(k int) error
 	KeyUp(k int) error
+
+	// TypeChord presses the modifiers in order and then the key, and
+	// releases them in reverse order: the key first and the modifiers last.
+	// The presses are sent together, and so are the releases, so no other
+	// event can come between them.
+	TypeChord(mods []int, key int) error
 }
 
 type UinputKeyboard struct {
-	kb uinput.Keyboard
+	file *os.File
 }
 
-// New returns a [Keyboard] instance with a [uinput.Keyboard] initialised with
-// the provided name.
+// New returns a [Keyboard] backed by a uinput device with the given name.
 func New(name string) (Keyboard, error) {
-	kb, err := uinput.CreateKeyboard("/dev/uinput", []byte(name))
+	file, err := createDevice(uinputPath, name)
 	if err != nil {
-		return nil, err
+		return nil, fmt.Errorf("%w: %w", ErrUinputUnavailable, err)
 	}
 	return &UinputKeyboard{
-		kb: kb,
+		file: file,
 	}, nil
 }
 
@@ -34,30 +45,106 @@ func (vkb *UinputKeyboard) Close() error {
 		// just do nothing
 		return nil
 	}
-	return vkb.kb.Close()
+	err := destroyDevice(vkb.file)
+	vkb.file = nil
+	return err
 }
 
 func (vkb *UinputKeyboard) KeyPress(k int) error {
 	if !vkb.hasKeyboard() {
 		return fmt.Errorf("key press before initialising keyboard")
 	}
-	return vkb.kb.KeyPress(k)
+	if err := checkKey(k); err != nil {
+		return err
+	}
+	if err := writeFrame(vkb.file, keyEvent(k, true)); err != nil {
+		return err
+	}
+	return writeFrame(vkb.file, keyEvent(k, false))
 }
 
 func (vkb *UinputKeyboard) KeyDown(k int) error {
 	if !vkb.hasKeyboard() {
 		return fmt.Errorf("key down before initialising keyboard")
 	}
-	return vkb.kb.KeyDown(k)
+	if err := checkKey(k); err != nil {
+		return err
+	}
+	return writeFrame(vkb.file, keyEvent(k, true))
 }
 
 func (vkb *UinputKeyboard) KeyUp(k int) error {
 	if !vkb.hasKeyboard() {
 		return fmt.Errorf("key up before initialising keyboard")
 	}
-	return vkb.kb.KeyUp(k)
+	if err := checkKey(k); err != nil {
+		return err
+	}
+	return writeFrame(vkb.file, keyEvent(k, false))
+}
+
+// TypeChord implements [Keyboard]. The presses are written in one frame,
+// ending with a single sync report, and the releases in another.
+func (vkb *UinputKeyboard) TypeChord(mods []int, key int) error {
+	if !vkb.hasKeyboard() {
+		return fmt.Errorf("chord before initialising keyboard")
+	}
+	press, release := ChordEvents(mods, key)
+	for _, k := range press {
+		if err := checkKey(k); err != nil {
+			return err
+		}
+	}
+
+	events := make([]inputEvent, 0, len(press))
+	for _, k := range press {
+		events = append(events, keyEvent(k, true))
+	}
+	if err := writeFrame(vkb.file, events...); err != nil {
+		return err
+	}
+	events = events[:0]
+	for _, k := range release {
+		events = append(events, keyEvent(k, false))
+	}
+	return writeFrame(vkb.file, events...)
+}
+
+// ChordEvents returns the order in which the keys of a chord are pressed and
+// released: the modifiers in order followed by the key, and the reverse.
+func ChordEvents(mods []int, key int) (press, release []int) {
+	press = append(slices.Clone(mods), key)
+	release = slices.Clone(press)
+	slices.Reverse(release)
+	return press, release
+}
+
+// TypeChordKeys types the chord on kb one key event at a time, for keyboards
+// that can't send several events together.
+func TypeChordKeys(kb Keyboard, mods []int, key int) error {
+	press, release := ChordEvents(mods, key)
+	for _, k := range press {
+		if err := kb.KeyDown(k); err != nil {
+			return err
+		}
+	}
+	for _, k := range release {
+		if err := kb.KeyUp(k); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Syspath returns the sysfs directory of the uinput device, for finding its
+// event device node.
+func (vkb *UinputKeyboard) Syspath() (string, error) {
+	if !vkb.hasKeyboard() {
+		return "", fmt.Errorf("keyboard not initialised")
+	}
+	return syspath(vkb.file)
 }
 
 func (vkb *UinputKeyboard) hasKeyboard() bool {
-	return vkb.kb != nil
+	return vkb.file != nil
 }
@@ -0,0 +1,69 @@
+package keyboard
+
+import (
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestKeyNames(t *testing.T) {
+	names := KeyNames()
+	assert.True(t, slices.IsSorted(names))
+	assert.Contains(t, names, "KeyA")
+	for _, name := range names {
+		assert.Equal(t, name, KeyName(KeyCode(name)))
+	}
+}
+
+func TestChordEvents(t *testing.T) {
+	assert := assert.New(t)
+
+	mods := []int{KeyCode("KeyLeftctrl"), KeyCode("KeyLeftshift")}
+	key := KeyCode("KeyC")
+	press, release := ChordEvents(mods, key)
+	assert.Equal([]int{mods[0], mods[1], key}, press)
+	assert.Equal([]int{key, mods[1], mods[0]}, release)
+	// the modifiers aren't modified
+	assert.Equal([]int{KeyCode("KeyLeftctrl"), KeyCode("KeyLeftshift")}, mods)
+
+	press, release = ChordEvents(nil, key)
+	assert.Equal([]int{key}, press)
+	assert.Equal([]int{key}, release)
+}
+
+func TestTypeChordFrames(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	// a regular file stands in for the uinput device, which writes the
+	// events the same way
+	file, err := os.Create(filepath.Join(t.TempDir(), "uinput"))
+	require.NoError(err)
+	vkb := &UinputKeyboard{file: file}
+	ctrl, c := KeyCode("KeyLeftctrl"), KeyCode("KeyC")
+	require.NoError(vkb.TypeChord([]int{ctrl}, c))
+	assert.EqualError(vkb.TypeChord([]int{ctrl}, 300), "key code 300 out of range")
+	require.NoError(file.Close())
+
+	data, err := os.ReadFile(file.Name())
+	require.NoError(err)
+	size := binary.Size(inputEvent{})
+	require.Zero(len(data) % size)
+	var events []inputEvent
+	for offset := 0; offset < len(data); offset += size {
+		var ev inputEvent
+		_, err := binary.Decode(data[offset:], binary.LittleEndian, &ev)
+		require.NoError(err)
+		events = append(events, ev)
+	}
+	sync := inputEvent{Type: evSyn, Code: synReport}
+	assert.Equal([]inputEvent{
+		keyEvent(ctrl, true), keyEvent(c, true), sync,
+		keyEvent(c, false), keyEvent(ctrl, false), sync,
+	}, events)
+}
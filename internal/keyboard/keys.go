@@ -1,5 +1,7 @@
 package keyboard
 
+import "slices"
+
 var (
 	keysByName = map[string]int{
 		"KeyEsc":              1,
@@ -245,8 +247,34 @@ var (
 		"KeyRfkill":           247, /*KeyThatControlsAllRadios*/
 		"KeyMicmute":          248, /*Mute/UnmuteTheMicrophone*/
 	}
+
+	keyNames map[int]string
 )
 
+func init() {
+	// reverse the keysByName map to build the keyNames map
+	keyNames = make(map[int]string, len(keysByName))
+	for name, code := range keysByName {
+		keyNames[code] = name
+	}
+}
+
 func KeyCode(name string) int {
 	return keysByName[name]
 }
+
+// KeyNames returns the names of all the keys, sorted.
+func KeyNames() []string {
+	names := make([]string, 0, len(keysByName))
+	for name := range keysByName {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return names
+}
+
+// KeyName returns the name of the key with the given code, or an empty string
+// if the code is unknown.
+func KeyName(code int) string {
+	return keyNames[code]
+}
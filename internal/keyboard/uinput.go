@@ -0,0 +1,206 @@
+package keyboard
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"os"
+	"path/filepath"
+	"syscall"
+	"time"
+	"unsafe"
+)
+
+// Definitions from linux/uinput.h and linux/input-event-codes.h. The keyboard
+// from the uinput library syncs after every key event, so chords couldn't be
+// sent in one frame; the device is set up directly instead.
+const (
+	uinputPath        = "/dev/uinput"
+	uinputMaxNameSize = 80
+	absSize           = 64
+
+	uiDevCreate  = 0x5501
+	uiDevDestroy = 0x5502
+	uiSetEvBit   = 0x40045564
+	uiSetKeyBit  = 0x40045565
+
+	// UI_GET_SYSNAME with a 65 byte buffer
+	uiGetSysname   = 0x8041552c
+	sysnameBufSize = 65
+
+	sysInputDir = "/sys/devices/virtual/input"
+
+	busUSB = 0x03
+
+	// the IDs the keyboard has always been created with, which udev rules
+	// may match
+	vendorID  = 0x4711
+	productID = 0x0815
+
+	evSyn = 0x00
+	evKey = 0x01
+
+	synReport = 0
+
+	// the highest key code the keyboard supports
+	keyMax = 248
+
+	// time for udev to set up the new device before events are sent to it
+	settleTime = 200 * time.Millisecond
+)
+
+type inputID struct {
+	Bustype uint16
+	Vendor  uint16
+	Product uint16
+	Version uint16
+}
+
+type uinputUserDev struct {
+	Name       [uinputMaxNameSize]byte
+	ID         inputID
+	EffectsMax uint32
+	Absmax     [absSize]int32
+	Absmin     [absSize]int32
+	Absfuzz    [absSize]int32
+	Absflat    [absSize]int32
+}
+
+type inputEvent struct {
+	Time  syscall.Timeval
+	Type  uint16
+	Code  uint16
+	Value int32
+}
+
+// keyEvent returns the event pressing (down) or releasing the key.
+func keyEvent(k int, down bool) inputEvent {
+	ev := inputEvent{Type: evKey, Code: uint16(k)}
+	if down {
+		ev.Value = 1
+	}
+	return ev
+}
+
+// checkKey returns an error if the key code is out of the supported range.
+func checkKey(k int) error {
+	if k < 0 || k > keyMax {
+		return fmt.Errorf("key code %d out of range", k)
+	}
+	return nil
+}
+
+// createDevice creates the uinput keyboard at path, with all keys up to
+// keyMax.
+func createDevice(path, name string) (*os.File, error) {
+	if len(name) >= uinputMaxNameSize {
+		return nil, fmt.Errorf("device name %q too long: at most %d bytes", name, uinputMaxNameSize-1)
+	}
+	file, err := os.OpenFile(path, os.O_WRONLY|syscall.O_NONBLOCK, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	setup := func() error {
+		if err := ioctl(file, uiSetEvBit, evKey); err != nil {
+			return fmt.Errorf("failed enabling key events: %w", err)
+		}
+		for k := range keyMax + 1 {
+			if err := ioctl(file, uiSetKeyBit, uintptr(k)); err != nil {
+				return fmt.Errorf("failed enabling key %d: %w", k, err)
+			}
+		}
+
+		dev := uinputUserDev{
+			ID: inputID{
+				Bustype: busUSB,
+				Vendor:  vendorID,
+				Product: productID,
+				Version: 1,
+			},
+		}
+		copy(dev.Name[:], name)
+		buf := new(bytes.Buffer)
+		if err := binary.Write(buf, binary.LittleEndian, dev); err != nil {
+			return err
+		}
+		if _, err := file.Write(buf.Bytes()); err != nil {
+			return fmt.Errorf("failed writing device description: %w", err)
+		}
+		if err := ioctl(file, uiDevCreate, 0); err != nil {
+			return fmt.Errorf("failed creating device: %w", err)
+		}
+		return nil
+	}
+
+	if err := setup(); err != nil {
+		_ = file.Close()
+		return nil, err
+	}
+	time.Sleep(settleTime)
+	return file, nil
+}
+
+// destroyDevice removes the uinput device and closes the file.
+func destroyDevice(file *os.File) error {
+	if err := ioctl(file, uiDevDestroy, 0); err != nil {
+		_ = file.Close()
+		return fmt.Errorf("failed destroying device: %w", err)
+	}
+	return file.Close()
+}
+
+// writeFrame writes the events to the device followed by a sync report, so
+// that readers see them as happening at the same time, in order.
+func writeFrame(file *os.File, events ...inputEvent) error {
+	events = append(events, inputEvent{Type: evSyn, Code: synReport})
+	buf := new(bytes.Buffer)
+	for _, ev := range events {
+		if err := binary.Write(buf, binary.LittleEndian, ev); err != nil {
+			return err
+		}
+	}
+	_, err := file.Write(buf.Bytes())
+	return err
+}
+
+// syspath returns the sysfs directory of the uinput device.
+func syspath(file *os.File) (string, error) {
+	buf := make([]byte, sysnameBufSize)
+	if err := ioctlPtr(file, uiGetSysname, unsafe.Pointer(&buf[0])); err != nil {
+		return "", fmt.Errorf("failed getting device name: %w", err)
+	}
+	return filepath.Join(sysInputDir, string(bytes.TrimRight(buf, "\x00"))), nil
+}
+
+// ioctl runs the ioctl on the device.
+func ioctl(file *os.File, cmd, arg uintptr) error {
+	return control(file, func(fd uintptr) syscall.Errno {
+		_, _, errno := syscall.Syscall(syscall.SYS_IOCTL, fd, cmd, arg)
+		return errno
+	})
+}
+
+// ioctlPtr runs an ioctl taking a pointer on the device.
+func ioctlPtr(file *os.File, cmd uintptr, arg unsafe.Pointer) error {
+	return control(file, func(fd uintptr) syscall.Errno {
+		_, _, errno := syscall.Syscall(syscall.SYS_IOCTL, fd, cmd, uintptr(arg))
+		return errno
+	})
+}
+
+// control runs the system call on the file descriptor of the device.
+func control(file *os.File, call func(fd uintptr) syscall.Errno) error {
+	conn, err := file.SyscallConn()
+	if err != nil {
+		return err
+	}
+	var errno syscall.Errno
+	if err := conn.Control(func(fd uintptr) { errno = call(fd) }); err != nil {
+		return err
+	}
+	if errno != 0 {
+		return errno
+	}
+	return nil
+}
@@ -0,0 +1,121 @@
+package lcd
+
+import (
+	"image"
+	"image/draw"
+	"strings"
+
+	"github.com/achilleas-k/gg13/internal/device"
+)
+
+const (
+	// distance between the tops of consecutive lines of the cheat sheet
+	cheatSheetLineHeight = 7
+
+	// maximum length of a key label in the G key grid
+	cheatSheetLabelLen = 5
+)
+
+// cheatSheetGrid lays out the G keys the way they are arranged on the device.
+// Each row starts at the given column offset.
+var cheatSheetGrid = []struct {
+	offset int
+	keys   []device.KeyBit
+}{
+	{0, []device.KeyBit{device.G1, device.G2, device.G3, device.G4, device.G5, device.G6, device.G7}},
+	{0, []device.KeyBit{device.G8, device.G9, device.G10, device.G11, device.G12, device.G13, device.G14}},
+	{1, []device.KeyBit{device.G15, device.G16, device.G17, device.G18, device.G19}},
+	{2, []device.KeyBit{device.G20, device.G21, device.G22}},
+}
+
+// keys that are not part of the grid and are listed by name
+var (
+	cheatSheetAuxKeys  = []device.KeyBit{device.LEFT, device.DOWN, device.TOP}
+	cheatSheetMetaKeys = []device.KeyBit{device.M1, device.M2, device.M3, device.MR, device.L1, device.L2, device.L3, device.L4}
+)
+
+// short labels for keyboard keys whose names don't fit
+var keyAbbreviations = map[string]string{
+	"Leftctrl":   "LCTL",
+	"Rightctrl":  "RCTL",
+	"Leftshift":  "LSFT",
+	"Rightshift": "RSFT",
+	"Leftalt":    "LALT",
+	"Rightalt":   "RALT",
+	"Leftmeta":   "LSUP",
+	"Rightmeta":  "RSUP",
+	"Space":      "SPC",
+	"Backspace":  "BSPC",
+	"Enter":      "ENT",
+	"Capslock":   "CAPS",
+	"Pageup":     "PGUP",
+	"Pagedown":   "PGDN",
+	"Insert":     "INS",
+	"Delete":     "DEL",
+	"Right":      "RGHT",
+	"Leftbrace":  "[",
+	"Rightbrace": "]",
+	"Minus":      "-",
+	"Equal":      "=",
+	"Semicolon":  ";",
+	"Apostrophe": "'",
+	"Grave":      "`",
+	"Backslash":  "\\",
+	"Comma":      ",",
+	"Dot":        ".",
+	"Slash":      "/",
+}
+
+// keyLabel returns a short label for a keyboard key name, like those defined in
+// the keyboard package, to fit in a cheat sheet cell.
+func keyLabel(name string) string {
+	if name == "" {
+		return "-"
+	}
+	name = strings.TrimPrefix(name, "Key")
+	if abbr, ok := keyAbbreviations[name]; ok {
+		return abbr
+	}
+	if len(name) > cheatSheetLabelLen {
+		name = name[:cheatSheetLabelLen]
+	}
+	return strings.ToUpper(name)
+}
+
+// CheatSheet renders the key bindings as a table that follows the layout of
+// the keys on the device. The bindings map G13 keys to the names of the
+// keyboard keys they are bound to. The stick mode is shown after the
+// auxiliary keys.
+func CheatSheet(bindings map[device.KeyBit]string, stickMode string) *image.Gray {
+	img := image.NewGray(image.Rect(0, 0, device.LCDWidth, device.LCDHeight))
+	draw.Draw(img, img.Bounds(), image.White, image.Point{}, draw.Src)
+
+	cellWidth := device.LCDWidth / len(cheatSheetGrid[0].keys)
+	line := 0
+	for _, row := range cheatSheetGrid {
+		for col, gkey := range row.keys {
+			DrawText(img, (row.offset+col)*cellWidth, line*cheatSheetLineHeight, keyLabel(bindings[gkey]))
+		}
+		line++
+	}
+
+	auxItems := []string{}
+	for _, gkey := range cheatSheetAuxKeys {
+		if name, ok := bindings[gkey]; ok {
+			auxItems = append(auxItems, gkey.String()+":"+keyLabel(name))
+		}
+	}
+	auxItems = append(auxItems, "STICK:"+stickMode)
+	DrawText(img, 0, line*cheatSheetLineHeight, strings.Join(auxItems, " "))
+	line++
+
+	metaItems := []string{}
+	for _, gkey := range cheatSheetMetaKeys {
+		if name, ok := bindings[gkey]; ok {
+			metaItems = append(metaItems, gkey.String()+":"+keyLabel(name))
+		}
+	}
+	DrawText(img, 0, line*cheatSheetLineHeight, strings.Join(metaItems, " "))
+
+	return img
+}
@@ -0,0 +1,124 @@
+package lcd
+
+import (
+	"image"
+	"testing"
+
+	"github.com/achilleas-k/gg13/internal/device"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestKeyLabel(t *testing.T) {
+	testCases := map[string]string{
+		"":              "-",
+		"KeyA":          "A",
+		"Key1":          "1",
+		"KeyF12":        "F12",
+		"KeyLeftctrl":   "LCTL",
+		"KeySpace":      "SPC",
+		"KeySlash":      "/",
+		"KeyVolumeup":   "VOLUM",
+		"KeyKpasterisk": "KPAST",
+	}
+
+	for name, expected := range testCases {
+		assert.Equal(t, expected, keyLabel(name), name)
+	}
+}
+
+// onPixels returns the coordinates of all black pixels in the image.
+func onPixels(img *image.Gray) []image.Point {
+	var points []image.Point
+	bounds := img.Bounds()
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			if img.GrayAt(x, y).Y == 0 {
+				points = append(points, image.Point{x, y})
+			}
+		}
+	}
+	return points
+}
+
+func TestDrawText(t *testing.T) {
+	img := image.NewGray(image.Rect(0, 0, 8, 5))
+	for idx := range img.Pix {
+		img.Pix[idx] = 0xff
+	}
+
+	// lower case is drawn as upper case
+	DrawText(img, 0, 0, "Hi")
+
+	expected := []image.Point{
+		// H
+		{0, 0}, {2, 0},
+		{0, 1}, {2, 1},
+		{0, 2}, {1, 2}, {2, 2},
+		{0, 3}, {2, 3},
+		{0, 4}, {2, 4},
+		// I
+		{4, 0}, {5, 0}, {6, 0},
+		{5, 1},
+		{5, 2},
+		{5, 3},
+		{4, 4}, {5, 4}, {6, 4},
+	}
+	assert.ElementsMatch(t, expected, onPixels(img))
+}
+
+func TestCheatSheet(t *testing.T) {
+	assert := assert.New(t)
+
+	empty := CheatSheet(map[device.KeyBit]string{}, "off")
+	assert.Equal(image.Rect(0, 0, device.LCDWidth, device.LCDHeight), empty.Bounds())
+
+	bindings := map[device.KeyBit]string{
+		device.G1:   "KeyEsc",
+		device.LEFT: "KeySpace",
+		device.M1:   "KeyTab",
+	}
+	sheet := CheatSheet(bindings, "keys")
+
+	// the first cell of the sheet is G1 and contains the label of the bound
+	// key, drawn exactly as DrawText would draw it
+	expected := image.NewGray(image.Rect(0, 0, device.LCDWidth, device.LCDHeight))
+	for idx := range expected.Pix {
+		expected.Pix[idx] = 0xff
+	}
+	DrawText(expected, 0, 0, "ESC")
+	firstCell := image.Rect(0, 0, device.LCDWidth/7, cheatSheetLineHeight)
+	assert.Equal(onPixels(expected.SubImage(firstCell).(*image.Gray)), onPixels(sheet.SubImage(firstCell).(*image.Gray)))
+
+	// more keys bound means more pixels on than the empty sheet
+	assert.Greater(len(onPixels(sheet)), len(onPixels(empty)))
+}
+
+func TestTestPattern(t *testing.T) {
+	assert := assert.New(t)
+
+	img := TestPattern()
+	assert.Equal(image.Rect(0, 0, device.LCDWidth, device.LCDHeight), img.Bounds())
+
+	// border
+	for _, p := range []image.Point{
+		{0, 0}, {device.LCDWidth - 1, 0}, {0, device.LCDHeight - 1}, {device.LCDWidth - 1, device.LCDHeight - 1},
+		{device.LCDWidth / 2, 0}, {device.LCDWidth - 1, device.LCDHeight / 2},
+	} {
+		assert.Equal(uint8(0), img.GrayAt(p.X, p.Y).Y, "%v", p)
+	}
+
+	// checkerboard
+	assert.Equal(uint8(0), img.GrayAt(5, 5).Y)
+	assert.Equal(uint8(255), img.GrayAt(5, 9).Y)
+	assert.Equal(uint8(255), img.GrayAt(9, 5).Y)
+	assert.Equal(uint8(0), img.GrayAt(9, 9).Y)
+
+	// the label is drawn on the right half
+	labelPixels := 0
+	for _, p := range onPixels(img) {
+		if p.X > device.LCDWidth/2 && p.X < device.LCDWidth-1 && p.Y > 0 && p.Y < device.LCDHeight-1 {
+			labelPixels++
+		}
+	}
+	assert.NotZero(labelPixels)
+}
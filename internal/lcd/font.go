@@ -0,0 +1,116 @@
+package lcd
+
+import (
+	"image"
+	"image/color"
+
+	"golang.org/x/image/font/basicfont"
+)
+
+// glyphs3x5 defines the glyphs of [Font3x5] for the printable ASCII characters
+// from ' ' to '`' followed by '{' to '~'. Each glyph is 3 pixels wide and 5
+// pixels tall, written as one string per row, where '#' is a set pixel.
+var glyphs3x5 = [][5]string{
+	{"...", "...", "...", "...", "..."}, // space
+	{".#.", ".#.", ".#.", "...", ".#."}, // !
+	{"#.#", "#.#", "...", "...", "..."}, // "
+	{"#.#", "###", "#.#", "###", "#.#"}, // #
+	{".##", "##.", ".#.", ".##", "##."}, // $
+	{"#..", "..#", ".#.", "#..", "..#"}, // %
+	{".#.", "#.#", ".#.", "#.#", ".##"}, // &
+	{".#.", ".#.", "...", "...", "..."}, // '
+	{"..#", ".#.", ".#.", ".#.", "..#"}, // (
+	{"#..", ".#.", ".#.", ".#.", "#.."}, // )
+	{"...", "#.#", ".#.", "#.#", "..."}, // *
+	{"...", ".#.", "###", ".#.", "..."}, // +
+	{"...", "...", "...", ".#.", "#.."}, // ,
+	{"...", "...", "###", "...", "..."}, // -
+	{"...", "...", "...", "...", ".#."}, // .
+	{"..#", "..#", ".#.", "#..", "#.."}, // /
+	{"###", "#.#", "#.#", "#.#", "###"}, // 0
+	{".#.", "##.", ".#.", ".#.", "###"}, // 1
+	{"###", "..#", "###", "#..", "###"}, // 2
+	{"###", "..#", ".##", "..#", "###"}, // 3
+	{"#.#", "#.#", "###", "..#", "..#"}, // 4
+	{"###", "#..", "###", "..#", "###"}, // 5
+	{"###", "#..", "###", "#.#", "###"}, // 6
+	{"###", "..#", "..#", ".#.", ".#."}, // 7
+	{"###", "#.#", "###", "#.#", "###"}, // 8
+	{"###", "#.#", "###", "..#", "###"}, // 9
+	{"...", ".#.", "...", ".#.", "..."}, // :
+	{"...", ".#.", "...", ".#.", "#.."}, // ;
+	{"..#", ".#.", "#..", ".#.", "..#"}, // <
+	{"...", "###", "...", "###", "..."}, // =
+	{"#..", ".#.", "..#", ".#.", "#.."}, // >
+	{"###", "..#", ".##", "...", ".#."}, // ?
+	{"###", "#.#", "#.#", "#..", ".##"}, // @
+	{".#.", "#.#", "###", "#.#", "#.#"}, // A
+	{"##.", "#.#", "##.", "#.#", "##."}, // B
+	{".##", "#..", "#..", "#..", ".##"}, // C
+	{"##.", "#.#", "#.#", "#.#", "##."}, // D
+	{"###", "#..", "##.", "#..", "###"}, // E
+	{"###", "#..", "##.", "#..", "#.."}, // F
+	{".##", "#..", "#.#", "#.#", ".##"}, // G
+	{"#.#", "#.#", "###", "#.#", "#.#"}, // H
+	{"###", ".#.", ".#.", ".#.", "###"}, // I
+	{"..#", "..#", "..#", "#.#", ".#."}, // J
+	{"#.#", "#.#", "##.", "#.#", "#.#"}, // K
+	{"#..", "#..", "#..", "#..", "###"}, // L
+	{"#.#", "###", "###", "#.#", "#.#"}, // M
+	{"##.", "#.#", "#.#", "#.#", "#.#"}, // N
+	{".#.", "#.#", "#.#", "#.#", ".#."}, // O
+	{"##.", "#.#", "##.", "#..", "#.."}, // P
+	{".#.", "#.#", "#.#", "##.", ".##"}, // Q
+	{"##.", "#.#", "##.", "#.#", "#.#"}, // R
+	{".##", "#..", ".#.", "..#", "##."}, // S
+	{"###", ".#.", ".#.", ".#.", ".#."}, // T
+	{"#.#", "#.#", "#.#", "#.#", "###"}, // U
+	{"#.#", "#.#", "#.#", "#.#", ".#."}, // V
+	{"#.#", "#.#", "###", "###", "#.#"}, // W
+	{"#.#", "#.#", ".#.", "#.#", "#.#"}, // X
+	{"#.#", "#.#", ".#.", ".#.", ".#."}, // Y
+	{"###", "..#", ".#.", "#..", "###"}, // Z
+	{"##.", "#..", "#..", "#..", "##."}, // [
+	{"#..", "#..", ".#.", "..#", "..#"}, // backslash
+	{".##", "..#", "..#", "..#", ".##"}, // ]
+	{".#.", "#.#", "...", "...", "..."}, // ^
+	{"...", "...", "...", "...", "###"}, // _
+	{"#..", ".#.", "...", "...", "..."}, // `
+	{".##", ".#.", "##.", ".#.", ".##"}, // {
+	{".#.", ".#.", ".#.", ".#.", ".#."}, // |
+	{"##.", ".#.", ".##", ".#.", "##."}, // }
+	{"...", "##.", ".##", "...", "..."}, // ~
+}
+
+// Font3x5 is a tiny fixed-width font that fits seven lines of text on the LCD.
+// It only has upper case letters; lower case letters are drawn as upper case.
+var Font3x5 = &basicfont.Face{
+	Advance: 4,
+	Width:   3,
+	Height:  6,
+	Ascent:  5,
+	Descent: 0,
+	Mask:    glyphMask(glyphs3x5, 3, 5),
+	Ranges: []basicfont.Range{
+		{Low: ' ', High: 'a', Offset: 0},
+		{Low: 'a', High: '{', Offset: 'A' - ' '},
+		{Low: '{', High: '\u007f', Offset: 'a' - ' '},
+		{Low: '\ufffd', High: '\ufffe', Offset: '?' - ' '},
+	},
+}
+
+// glyphMask builds the mask for a [basicfont.Face] from glyph definitions,
+// stacking the glyphs vertically.
+func glyphMask(glyphs [][5]string, width, height int) *image.Alpha {
+	mask := image.NewAlpha(image.Rect(0, 0, width, height*len(glyphs)))
+	for idx, glyph := range glyphs {
+		for row, line := range glyph {
+			for col, px := range line {
+				if px == '#' {
+					mask.SetAlpha(col, idx*height+row, color.Alpha{A: 0xff})
+				}
+			}
+		}
+	}
+	return mask
+}
@@ -0,0 +1,39 @@
+package lcd
+
+import (
+	"image"
+	"path/filepath"
+	"testing"
+
+	"github.com/achilleas-k/gg13/internal/device"
+	"github.com/achilleas-k/gg13/internal/lcd/lcdtest"
+)
+
+// Update the golden files after intended changes to the rendering with
+//
+//	go test ./internal/lcd/ -update
+func TestGolden(t *testing.T) {
+	tests := map[string]func() *image.Gray{
+		"test-pattern": TestPattern,
+		"text-page": func() *image.Gray {
+			return TextPage("GG13\nthe quick brown fox\njumps over the lazy dog\n0123456789")
+		},
+		"cheat-sheet": func() *image.Gray {
+			return CheatSheet(map[device.KeyBit]string{
+				device.G1:   "KeyEsc",
+				device.G2:   "Key1",
+				device.G8:   "KeyTab",
+				device.G22:  "KeySpace",
+				device.LEFT: "KeyLeftshift",
+				device.M1:   "KeyF1",
+				device.TOP:  "KeyEnter",
+			}, "keys")
+		},
+	}
+
+	for name, render := range tests {
+		t.Run(name, func(t *testing.T) {
+			lcdtest.AssertGolden(t, filepath.Join("testdata", name+".png"), render())
+		})
+	}
+}
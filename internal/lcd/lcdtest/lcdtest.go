@@ -0,0 +1,128 @@
+// Package lcdtest provides helpers for testing images rendered for the G13
+// LCD against golden files.
+//
+// Golden files are PNG images of the LCD as it would display the image. Run
+// the tests with -update to write the golden files from the current output
+// instead of comparing against them.
+package lcdtest
+
+import (
+	"flag"
+	"fmt"
+	"image"
+	"image/png"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/achilleas-k/gg13/internal/device"
+)
+
+var update = flag.Bool("update", false, "write the golden files of LCD images instead of comparing against them")
+
+// DecodeFrame decodes a frame written to the LCD into an image, failing the
+// test if it isn't a valid frame.
+func DecodeFrame(t testing.TB, frame []byte) *image.Gray {
+	t.Helper()
+	img, err := device.G13BytesToImage(frame)
+	if err != nil {
+		t.Fatalf("decoding LCD frame: %v", err)
+	}
+	return img
+}
+
+// AssertGolden compares the image, as it would be displayed on the LCD, with
+// the golden file at path and fails the test if any pixel differs. The image
+// must have the size of the LCD.
+func AssertGolden(t testing.TB, path string, img image.Image) {
+	t.Helper()
+	frame, err := device.ImageToG13Bytes(img)
+	if err != nil {
+		t.Fatalf("rendering LCD image: %v", err)
+	}
+	AssertFrameGolden(t, path, frame)
+}
+
+// AssertFrameGolden is like [AssertGolden] for a frame in the device format.
+func AssertFrameGolden(t testing.TB, path string, frame []byte) {
+	t.Helper()
+	actual := DecodeFrame(t, frame)
+
+	if *update {
+		if err := writePNG(path, actual); err != nil {
+			t.Fatalf("updating golden file: %v", err)
+		}
+		return
+	}
+
+	expected, err := readGolden(path)
+	if err != nil {
+		t.Fatalf("%v: run the test with -update to create it", err)
+	}
+
+	diff := Diff(expected, actual)
+	if len(diff) == 0 {
+		return
+	}
+	actualPath := filepath.Join(t.TempDir(), filepath.Base(path))
+	if err := writePNG(actualPath, actual); err != nil {
+		t.Logf("failed to write the rendered image: %v", err)
+		actualPath = "(not written)"
+	}
+	t.Errorf("LCD image differs from golden file %s in %d pixels, first at %v: rendered image: %s", path, len(diff), diff[0], actualPath)
+}
+
+// Diff returns the points where one of the images has a pixel on and the
+// other doesn't, in row order. Pixels are compared after converting both
+// images to the LCD format, like [device.RenderLCD].
+func Diff(a, b image.Image) []image.Point {
+	var points []image.Point
+	bounds := image.Rect(0, 0, device.LCDWidth, device.LCDHeight)
+	for y := range bounds.Max.Y {
+		for x := range bounds.Max.X {
+			if pixelOn(a, x, y) != pixelOn(b, x, y) {
+				points = append(points, image.Pt(x, y))
+			}
+		}
+	}
+	return points
+}
+
+func pixelOn(img image.Image, x, y int) bool {
+	if !(image.Point{x, y}.In(img.Bounds())) {
+		return false
+	}
+	return device.DefaultMonochrome().PixelOn(img.At(x, y))
+}
+
+func readGolden(path string) (*image.Gray, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open golden file: %w", err)
+	}
+	defer file.Close()
+	img, err := png.Decode(file)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode golden file %s: %w", path, err)
+	}
+	frame, err := device.ImageToG13Bytes(img)
+	if err != nil {
+		return nil, fmt.Errorf("invalid golden file %s: %w", path, err)
+	}
+	return device.G13BytesToImage(frame)
+}
+
+func writePNG(path string, img image.Image) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := png.Encode(file, img); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
@@ -0,0 +1,67 @@
+package lcdtest
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"path/filepath"
+	"testing"
+
+	"github.com/achilleas-k/gg13/internal/device"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func whiteImage() *image.Gray {
+	img := image.NewGray(image.Rect(0, 0, device.LCDWidth, device.LCDHeight))
+	for idx := range img.Pix {
+		img.Pix[idx] = 0xff
+	}
+	return img
+}
+
+func TestDiff(t *testing.T) {
+	a := whiteImage()
+	b := whiteImage()
+	assert.Empty(t, Diff(a, b))
+
+	a.SetGray(3, 4, color.Gray{})
+	b.SetGray(10, 1, color.Gray{})
+	// light grey is off on the LCD
+	b.SetGray(20, 20, color.Gray{Y: 0xf0})
+	assert.Equal(t, []image.Point{{10, 1}, {3, 4}}, Diff(a, b))
+}
+
+func TestAssertGolden(t *testing.T) {
+	require := require.New(t)
+
+	img := whiteImage()
+	img.SetGray(0, 0, color.Gray{})
+	golden := filepath.Join(t.TempDir(), "testdata", "image.png")
+	require.NoError(writePNG(golden, img))
+
+	AssertGolden(t, golden, img)
+
+	frame, err := device.ImageToG13Bytes(img)
+	require.NoError(err)
+	AssertFrameGolden(t, golden, frame)
+	assert.Equal(t, img, DecodeFrame(t, frame))
+
+	// a failing comparison
+	other := whiteImage()
+	other.SetGray(1, 1, color.Gray{})
+	rec := &recordingTB{TB: t}
+	AssertGolden(rec, golden, other)
+	require.Len(rec.errors, 1)
+	assert.Contains(t, rec.errors[0], "in 2 pixels, first at (0,0)")
+}
+
+// recordingTB records errors instead of failing the test.
+type recordingTB struct {
+	testing.TB
+	errors []string
+}
+
+func (tb *recordingTB) Errorf(format string, args ...any) {
+	tb.errors = append(tb.errors, fmt.Sprintf(format, args...))
+}
@@ -0,0 +1,858 @@
+package lcd
+
+import (
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/achilleas-k/gg13/internal/device"
+	"golang.org/x/image/colornames"
+	"golang.org/x/image/font"
+	"golang.org/x/image/math/fixed"
+	"golang.org/x/image/vector"
+)
+
+// svgCurveSegments is the number of line segments curves and arcs are
+// flattened to. At the size of the LCD, more don't make a visible difference.
+const svgCurveSegments = 16
+
+// RenderSVG rasterises an SVG document at the size of the LCD. The viewBox of
+// the document is scaled to fit the LCD and centred, keeping its aspect ratio.
+//
+// Only the subset of SVG that makes sense on a monochrome display of this size
+// is supported: the basic shapes, paths, groups, transforms, solid fill and
+// stroke colours and text, which is drawn with [Font3x5] regardless of the
+// font size. Definitions, like symbols, masks and patterns, aren't drawn.
+func RenderSVG(r io.Reader) (*image.RGBA, error) {
+	img := image.NewRGBA(image.Rect(0, 0, device.LCDWidth, device.LCDHeight))
+	draw.Draw(img, img.Bounds(), image.White, image.Point{}, draw.Src)
+
+	s := &svgRenderer{dst: img}
+	if err := s.render(xml.NewDecoder(r)); err != nil {
+		return nil, fmt.Errorf("failed to render SVG: %w", err)
+	}
+	return img, nil
+}
+
+// svgPoint is a point in SVG coordinates.
+type svgPoint struct {
+	x, y float64
+}
+
+// svgSubpath is a flattened part of a path.
+type svgSubpath struct {
+	points []svgPoint
+	closed bool
+}
+
+// svgTransform is an affine transform, as the matrix a c e / b d f / 0 0 1.
+type svgTransform [6]float64
+
+var svgIdentity = svgTransform{1, 0, 0, 1, 0, 0}
+
+func (t svgTransform) apply(p svgPoint) svgPoint {
+	return svgPoint{
+		x: t[0]*p.x + t[2]*p.y + t[4],
+		y: t[1]*p.x + t[3]*p.y + t[5],
+	}
+}
+
+// mul returns the transform that applies u and then t.
+func (t svgTransform) mul(u svgTransform) svgTransform {
+	return svgTransform{
+		t[0]*u[0] + t[2]*u[1],
+		t[1]*u[0] + t[3]*u[1],
+		t[0]*u[2] + t[2]*u[3],
+		t[1]*u[2] + t[3]*u[3],
+		t[0]*u[4] + t[2]*u[5] + t[4],
+		t[1]*u[4] + t[3]*u[5] + t[5],
+	}
+}
+
+// scale returns the factor by which the transform scales lengths, on average.
+func (t svgTransform) scale() float64 {
+	return math.Sqrt(math.Abs(t[0]*t[3] - t[1]*t[2]))
+}
+
+// svgNotRendered are the elements whose content is only drawn where it's
+// referenced, which isn't supported, so their subtrees are skipped.
+var svgNotRendered = map[string]bool{
+	"defs":     true,
+	"symbol":   true,
+	"clipPath": true,
+	"mask":     true,
+	"pattern":  true,
+	"marker":   true,
+}
+
+// svgStyle holds the inherited presentation attributes of an element.
+type svgStyle struct {
+	fill        color.Color
+	stroke      color.Color
+	strokeWidth float64
+	transform   svgTransform
+	hidden      bool
+}
+
+type svgRenderer struct {
+	dst *image.RGBA
+
+	// the text element being read, if any
+	text *svgText
+}
+
+type svgText struct {
+	pos   svgPoint
+	style svgStyle
+	data  strings.Builder
+}
+
+func (s *svgRenderer) render(decoder *xml.Decoder) error {
+	root := true
+	// how many elements of a subtree that isn't drawn are open
+	skip := 0
+	stack := []svgStyle{{
+		fill:        color.Black,
+		strokeWidth: 1,
+		transform:   svgIdentity,
+	}}
+	for {
+		token, err := decoder.Token()
+		if errors.Is(err, io.EOF) {
+			if root {
+				return fmt.Errorf("no svg element")
+			}
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		switch token := token.(type) {
+		case xml.StartElement:
+			if skip > 0 || (!root && svgNotRendered[token.Name.Local]) {
+				skip++
+				continue
+			}
+			style, err := parseSVGStyle(stack[len(stack)-1], token.Attr)
+			if err != nil {
+				return fmt.Errorf("%s: %w", token.Name.Local, err)
+			}
+			if root {
+				if token.Name.Local != "svg" {
+					return fmt.Errorf("not an SVG document: root element is %s", token.Name.Local)
+				}
+				viewport, err := svgViewport(attrs(token.Attr))
+				if err != nil {
+					return fmt.Errorf("svg: %w", err)
+				}
+				style.transform = viewport.mul(style.transform)
+				root = false
+			}
+			stack = append(stack, style)
+			if err := s.startElement(token, style); err != nil {
+				return fmt.Errorf("%s: %w", token.Name.Local, err)
+			}
+		case xml.CharData:
+			if s.text != nil {
+				s.text.data.Write(token)
+			}
+		case xml.EndElement:
+			if skip > 0 {
+				skip--
+				continue
+			}
+			stack = stack[:len(stack)-1]
+			if token.Name.Local == "text" && s.text != nil {
+				s.drawText(s.text)
+				s.text = nil
+			}
+		}
+	}
+}
+
+func (s *svgRenderer) startElement(el xml.StartElement, style svgStyle) error {
+	a := attrs(el.Attr)
+	var subpaths []svgSubpath
+	var err error
+	switch el.Name.Local {
+	case "rect":
+		subpaths, err = svgRect(a)
+	case "circle":
+		subpaths, err = svgEllipse(a, "r", "r")
+	case "ellipse":
+		subpaths, err = svgEllipse(a, "rx", "ry")
+	case "line":
+		subpaths, err = svgLine(a)
+	case "polyline", "polygon":
+		subpaths, err = svgPoly(a["points"], el.Name.Local == "polygon")
+	case "path":
+		subpaths, err = parseSVGPath(a["d"])
+	case "text":
+		x, y, err := a.numbers("x", "y")
+		if err != nil {
+			return err
+		}
+		s.text = &svgText{pos: svgPoint{x, y}, style: style}
+		return nil
+	default:
+		// containers and unsupported elements
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if !style.hidden {
+		s.drawShape(subpaths, style)
+	}
+	return nil
+}
+
+func (s *svgRenderer) drawShape(subpaths []svgSubpath, style svgStyle) {
+	size := s.dst.Bounds().Size()
+	if style.fill != nil {
+		r := vector.NewRasterizer(size.X, size.Y)
+		for _, sp := range subpaths {
+			if len(sp.points) < 2 {
+				continue
+			}
+			for idx, p := range sp.points {
+				p = style.transform.apply(p)
+				if idx == 0 {
+					r.MoveTo(float32(p.x), float32(p.y))
+				} else {
+					r.LineTo(float32(p.x), float32(p.y))
+				}
+			}
+			r.ClosePath()
+		}
+		r.Draw(s.dst, s.dst.Bounds(), image.NewUniform(style.fill), image.Point{})
+	}
+
+	if style.stroke != nil && style.strokeWidth > 0 {
+		r := vector.NewRasterizer(size.X, size.Y)
+		half := style.strokeWidth * style.transform.scale() / 2
+		for _, sp := range subpaths {
+			points := sp.points
+			if sp.closed && len(points) > 0 {
+				points = append(points[:len(points):len(points)], points[0])
+			}
+			for idx, p := range points {
+				p = style.transform.apply(p)
+				// a square at every point joins the segments
+				strokeQuad(r, svgPoint{p.x - half, p.y}, svgPoint{p.x + half, p.y}, half)
+				if idx > 0 {
+					strokeQuad(r, style.transform.apply(points[idx-1]), p, half)
+				}
+			}
+		}
+		r.Draw(s.dst, s.dst.Bounds(), image.NewUniform(style.stroke), image.Point{})
+	}
+}
+
+// strokeQuad adds the rectangle around the line from a to b that extends half
+// on either side. All the rectangles have the same winding, so overlapping
+// ones don't cancel out.
+func strokeQuad(r *vector.Rasterizer, a, b svgPoint, half float64) {
+	dx, dy := b.x-a.x, b.y-a.y
+	length := math.Hypot(dx, dy)
+	if length == 0 {
+		return
+	}
+	nx, ny := -dy/length*half, dx/length*half
+	r.MoveTo(float32(a.x+nx), float32(a.y+ny))
+	r.LineTo(float32(b.x+nx), float32(b.y+ny))
+	r.LineTo(float32(b.x-nx), float32(b.y-ny))
+	r.LineTo(float32(a.x-nx), float32(a.y-ny))
+	r.ClosePath()
+}
+
+func (s *svgRenderer) drawText(text *svgText) {
+	if text.style.hidden || text.style.fill == nil {
+		return
+	}
+	pos := text.style.transform.apply(text.pos)
+	drawer := font.Drawer{
+		Dst:  s.dst,
+		Src:  image.NewUniform(text.style.fill),
+		Face: Font3x5,
+		Dot:  fixed.P(int(math.Round(pos.x)), int(math.Round(pos.y))),
+	}
+	drawer.DrawString(strings.TrimSpace(text.data.String()))
+}
+
+// svgAttrs are the attributes of an element by name.
+type svgAttrs map[string]string
+
+func attrs(list []xml.Attr) svgAttrs {
+	a := make(svgAttrs, len(list))
+	for _, attr := range list {
+		a[attr.Name.Local] = attr.Value
+	}
+	return a
+}
+
+// number returns the value of the attribute. Missing attributes are 0.
+func (a svgAttrs) number(name string) (float64, error) {
+	value, ok := a[name]
+	if !ok {
+		return 0, nil
+	}
+	n, err := parseSVGLength(value)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s %q", name, value)
+	}
+	return n, nil
+}
+
+// numbers returns the values of two attributes, like [svgAttrs.number].
+func (a svgAttrs) numbers(first, second string) (float64, float64, error) {
+	x, err := a.number(first)
+	if err != nil {
+		return 0, 0, err
+	}
+	y, err := a.number(second)
+	if err != nil {
+		return 0, 0, err
+	}
+	return x, y, nil
+}
+
+// parseSVGLength parses a length in user units. Pixel units are accepted, but
+// other units aren't.
+func parseSVGLength(value string) (float64, error) {
+	return strconv.ParseFloat(strings.TrimSuffix(strings.TrimSpace(value), "px"), 64)
+}
+
+// svgViewport returns the transform from the viewBox of the document to the
+// LCD.
+func svgViewport(a svgAttrs) (svgTransform, error) {
+	var box [4]float64
+	if viewBox, ok := a["viewBox"]; ok {
+		values, err := parseSVGNumbers(viewBox)
+		if err != nil || len(values) != 4 {
+			return svgIdentity, fmt.Errorf("invalid viewBox %q", viewBox)
+		}
+		copy(box[:], values)
+	} else {
+		width, height, err := a.numbers("width", "height")
+		if err != nil {
+			return svgIdentity, err
+		}
+		if width == 0 || height == 0 {
+			width, height = device.LCDWidth, device.LCDHeight
+		}
+		box = [4]float64{0, 0, width, height}
+	}
+	if box[2] <= 0 || box[3] <= 0 {
+		return svgIdentity, fmt.Errorf("viewBox size must be positive")
+	}
+
+	scale := min(device.LCDWidth/box[2], device.LCDHeight/box[3])
+	offsetX := (device.LCDWidth - box[2]*scale) / 2
+	offsetY := (device.LCDHeight - box[3]*scale) / 2
+	return svgTransform{scale, 0, 0, scale, offsetX - box[0]*scale, offsetY - box[1]*scale}, nil
+}
+
+// parseSVGStyle returns the style of an element from the attributes and the
+// style of its parent.
+func parseSVGStyle(parent svgStyle, list []xml.Attr) (svgStyle, error) {
+	style := parent
+	props := make(map[string]string)
+	for _, attr := range list {
+		props[attr.Name.Local] = attr.Value
+	}
+	// the style attribute overrides the presentation attributes
+	for _, decl := range strings.Split(props["style"], ";") {
+		name, value, ok := strings.Cut(decl, ":")
+		if ok {
+			props[strings.TrimSpace(name)] = strings.TrimSpace(value)
+		}
+	}
+
+	var err error
+	if value, ok := props["fill"]; ok {
+		if style.fill, err = parseSVGColour(value); err != nil {
+			return style, fmt.Errorf("fill: %w", err)
+		}
+	}
+	if value, ok := props["stroke"]; ok {
+		if style.stroke, err = parseSVGColour(value); err != nil {
+			return style, fmt.Errorf("stroke: %w", err)
+		}
+	}
+	if value, ok := props["stroke-width"]; ok {
+		if style.strokeWidth, err = parseSVGLength(value); err != nil {
+			return style, fmt.Errorf("invalid stroke-width %q", value)
+		}
+	}
+	if props["display"] == "none" || props["visibility"] == "hidden" {
+		style.hidden = true
+	}
+	if value, ok := props["transform"]; ok {
+		t, err := parseSVGTransform(value)
+		if err != nil {
+			return style, err
+		}
+		style.transform = style.transform.mul(t)
+	}
+	return style, nil
+}
+
+// svgNamedColours are the colour keywords of CSS that aren't in the table of
+// SVG 1.1, [colornames.Map].
+var svgNamedColours = map[string]color.Color{
+	"currentcolor":  color.Black,
+	"rebeccapurple": color.RGBA{R: 0x66, G: 0x33, B: 0x99, A: 0xff},
+}
+
+// parseSVGColour returns the colour, or nil for none. Gradients and patterns
+// can't be drawn, so a reference to one, url(#id), is replaced by the
+// fallback colour that follows it, or black without one.
+func parseSVGColour(value string) (color.Color, error) {
+	value = strings.ToLower(strings.TrimSpace(value))
+	if value == "none" || value == "transparent" {
+		return nil, nil
+	}
+	if ref, ok := strings.CutPrefix(value, "url("); ok {
+		_, fallback, ok := strings.Cut(ref, ")")
+		if !ok {
+			return nil, fmt.Errorf("invalid colour %q", value)
+		}
+		if fallback = strings.TrimSpace(fallback); fallback == "" {
+			return color.Black, nil
+		}
+		return parseSVGColour(fallback)
+	}
+	if c, ok := svgNamedColours[value]; ok {
+		return c, nil
+	}
+	if c, ok := colornames.Map[value]; ok {
+		return c, nil
+	}
+	if hex, ok := strings.CutPrefix(value, "#"); ok {
+		if len(hex) == 3 {
+			hex = string([]byte{hex[0], hex[0], hex[1], hex[1], hex[2], hex[2]})
+		}
+		rgb, err := strconv.ParseUint(hex, 16, 32)
+		if err != nil || len(hex) != 6 {
+			return nil, fmt.Errorf("invalid colour %q", value)
+		}
+		return color.RGBA{R: uint8(rgb >> 16), G: uint8(rgb >> 8), B: uint8(rgb), A: 0xff}, nil
+	}
+	if args, ok := strings.CutPrefix(value, "rgb("); ok {
+		values, err := parseSVGNumbers(strings.TrimSuffix(args, ")"))
+		if err != nil || len(values) != 3 {
+			return nil, fmt.Errorf("invalid colour %q", value)
+		}
+		channel := func(v float64) uint8 { return uint8(max(0, min(255, v))) }
+		return color.RGBA{R: channel(values[0]), G: channel(values[1]), B: channel(values[2]), A: 0xff}, nil
+	}
+	return nil, fmt.Errorf("unsupported colour %q", value)
+}
+
+// parseSVGTransform parses a list of transform functions.
+func parseSVGTransform(value string) (svgTransform, error) {
+	t := svgIdentity
+	rest := strings.TrimSpace(value)
+	for rest != "" {
+		name, args, ok := strings.Cut(rest, "(")
+		if !ok {
+			return t, fmt.Errorf("invalid transform %q", value)
+		}
+		args, rest, ok = strings.Cut(args, ")")
+		if !ok {
+			return t, fmt.Errorf("invalid transform %q", value)
+		}
+		rest = strings.TrimLeft(rest, ", \t\n")
+		v, err := parseSVGNumbers(args)
+		if err != nil {
+			return t, fmt.Errorf("invalid transform %q", value)
+		}
+
+		var u svgTransform
+		name = strings.TrimSpace(name)
+		switch {
+		case name == "matrix" && len(v) == 6:
+			u = svgTransform(v)
+		case name == "translate" && len(v) == 1:
+			u = svgTransform{1, 0, 0, 1, v[0], 0}
+		case name == "translate" && len(v) == 2:
+			u = svgTransform{1, 0, 0, 1, v[0], v[1]}
+		case name == "scale" && len(v) == 1:
+			u = svgTransform{v[0], 0, 0, v[0], 0, 0}
+		case name == "scale" && len(v) == 2:
+			u = svgTransform{v[0], 0, 0, v[1], 0, 0}
+		case name == "rotate" && (len(v) == 1 || len(v) == 3):
+			sin, cos := math.Sincos(v[0] * math.Pi / 180)
+			u = svgTransform{cos, sin, -sin, cos, 0, 0}
+			if len(v) == 3 {
+				// rotate about cx, cy
+				u = svgTransform{1, 0, 0, 1, v[1], v[2]}.mul(u).mul(svgTransform{1, 0, 0, 1, -v[1], -v[2]})
+			}
+		case name == "skewX" && len(v) == 1:
+			u = svgTransform{1, 0, math.Tan(v[0] * math.Pi / 180), 1, 0, 0}
+		case name == "skewY" && len(v) == 1:
+			u = svgTransform{1, math.Tan(v[0] * math.Pi / 180), 0, 1, 0, 0}
+		default:
+			return t, fmt.Errorf("invalid transform %q", value)
+		}
+		t = t.mul(u)
+	}
+	return t, nil
+}
+
+// parseSVGNumbers parses a list of numbers separated by spaces or commas.
+func parseSVGNumbers(value string) ([]float64, error) {
+	fields := strings.FieldsFunc(value, func(r rune) bool {
+		return r == ',' || r == ' ' || r == '\t' || r == '\n' || r == '\r'
+	})
+	numbers := make([]float64, 0, len(fields))
+	for _, field := range fields {
+		n, err := strconv.ParseFloat(field, 64)
+		if err != nil {
+			return nil, err
+		}
+		numbers = append(numbers, n)
+	}
+	return numbers, nil
+}
+
+func svgRect(a svgAttrs) ([]svgSubpath, error) {
+	x, y, err := a.numbers("x", "y")
+	if err != nil {
+		return nil, err
+	}
+	width, height, err := a.numbers("width", "height")
+	if err != nil {
+		return nil, err
+	}
+	return []svgSubpath{{
+		points: []svgPoint{{x, y}, {x + width, y}, {x + width, y + height}, {x, y + height}},
+		closed: true,
+	}}, nil
+}
+
+func svgEllipse(a svgAttrs, rxAttr, ryAttr string) ([]svgSubpath, error) {
+	cx, cy, err := a.numbers("cx", "cy")
+	if err != nil {
+		return nil, err
+	}
+	rx, ry, err := a.numbers(rxAttr, ryAttr)
+	if err != nil {
+		return nil, err
+	}
+	// more segments than curves, since a circle is closer to its outline
+	const segments = 4 * svgCurveSegments
+	points := make([]svgPoint, segments)
+	for idx := range points {
+		sin, cos := math.Sincos(2 * math.Pi * float64(idx) / segments)
+		points[idx] = svgPoint{cx + rx*cos, cy + ry*sin}
+	}
+	return []svgSubpath{{points: points, closed: true}}, nil
+}
+
+func svgLine(a svgAttrs) ([]svgSubpath, error) {
+	x1, y1, err := a.numbers("x1", "y1")
+	if err != nil {
+		return nil, err
+	}
+	x2, y2, err := a.numbers("x2", "y2")
+	if err != nil {
+		return nil, err
+	}
+	return []svgSubpath{{points: []svgPoint{{x1, y1}, {x2, y2}}}}, nil
+}
+
+func svgPoly(value string, closed bool) ([]svgSubpath, error) {
+	numbers, err := parseSVGNumbers(value)
+	if err != nil || len(numbers)%2 != 0 {
+		return nil, fmt.Errorf("invalid points %q", value)
+	}
+	points := make([]svgPoint, 0, len(numbers)/2)
+	for idx := 0; idx < len(numbers); idx += 2 {
+		points = append(points, svgPoint{numbers[idx], numbers[idx+1]})
+	}
+	return []svgSubpath{{points: points, closed: closed}}, nil
+}
+
+// svgPathScanner splits path data into commands and numbers.
+type svgPathScanner struct {
+	data string
+	pos  int
+}
+
+func (sc *svgPathScanner) skipSeparators() {
+	for sc.pos < len(sc.data) && strings.IndexByte(" \t\r\n,", sc.data[sc.pos]) >= 0 {
+		sc.pos++
+	}
+}
+
+// command returns the next command letter, if the next token is one.
+func (sc *svgPathScanner) command() (byte, bool) {
+	sc.skipSeparators()
+	if sc.pos < len(sc.data) {
+		c := sc.data[sc.pos]
+		if (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') {
+			sc.pos++
+			return c, true
+		}
+	}
+	return 0, false
+}
+
+// hasNumber returns true if the next token is a number.
+func (sc *svgPathScanner) hasNumber() bool {
+	sc.skipSeparators()
+	if sc.pos >= len(sc.data) {
+		return false
+	}
+	c := sc.data[sc.pos]
+	return c == '-' || c == '+' || c == '.' || (c >= '0' && c <= '9')
+}
+
+// number returns the next number. Numbers don't need separators when it's
+// unambiguous, like in "1-2" or "0.5.5".
+func (sc *svgPathScanner) number() (float64, error) {
+	if !sc.hasNumber() {
+		return 0, fmt.Errorf("expected a number at offset %d", sc.pos)
+	}
+	start := sc.pos
+	end := sc.pos
+	if c := sc.data[end]; c == '-' || c == '+' {
+		end++
+	}
+	seenDot, seenExp := false, false
+scan:
+	for ; end < len(sc.data); end++ {
+		c := sc.data[end]
+		switch {
+		case c >= '0' && c <= '9':
+		case c == '.' && !seenDot && !seenExp:
+			seenDot = true
+		case (c == 'e' || c == 'E') && !seenExp:
+			seenExp = true
+			if end+1 < len(sc.data) && (sc.data[end+1] == '-' || sc.data[end+1] == '+') {
+				end++
+			}
+		default:
+			break scan
+		}
+	}
+	n, err := strconv.ParseFloat(sc.data[start:end], 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid number %q", sc.data[start:end])
+	}
+	sc.pos = end
+	return n, nil
+}
+
+// numbers returns the next count numbers.
+func (sc *svgPathScanner) numbers(count int) ([]float64, error) {
+	values := make([]float64, count)
+	for idx := range values {
+		var err error
+		if values[idx], err = sc.number(); err != nil {
+			return nil, err
+		}
+	}
+	return values, nil
+}
+
+// svgPathArgs is the number of arguments of each path command, by the lower
+// case letter of the command.
+var svgPathArgs = map[byte]int{'m': 2, 'l': 2, 'h': 1, 'v': 1, 'c': 6, 's': 4, 'q': 4, 't': 2, 'a': 7, 'z': 0}
+
+// parseSVGPath parses path data and flattens the curves and arcs in it.
+func parseSVGPath(d string) ([]svgSubpath, error) {
+	sc := &svgPathScanner{data: d}
+	var subpaths []svgSubpath
+	var current *svgSubpath
+	var pen, start, lastCtrl svgPoint
+	var lastCmd byte
+
+	lineTo := func(p svgPoint) {
+		if current == nil {
+			subpaths = append(subpaths, svgSubpath{points: []svgPoint{pen}})
+			current = &subpaths[len(subpaths)-1]
+		}
+		current.points = append(current.points, p)
+		pen = p
+	}
+
+	cmd, ok := sc.command()
+	if !ok {
+		if sc.pos < len(d) {
+			return nil, fmt.Errorf("invalid path data: must start with a command")
+		}
+		return nil, nil
+	}
+	for {
+		rel := cmd >= 'a'
+		offset := func(x, y float64) svgPoint {
+			if rel {
+				return svgPoint{pen.x + x, pen.y + y}
+			}
+			return svgPoint{x, y}
+		}
+
+		count, known := svgPathArgs[cmd|0x20]
+		if !known {
+			return nil, fmt.Errorf("invalid path data: unknown command %q", cmd)
+		}
+		for first := true; first || (count > 0 && sc.hasNumber()); first = false {
+			v, err := sc.numbers(count)
+			if err != nil {
+				return nil, fmt.Errorf("invalid path data: %c: %w", cmd, err)
+			}
+			ctrl := svgPoint{}
+			switch cmd | 0x20 {
+			case 'm':
+				p := offset(v[0], v[1])
+				if first {
+					subpaths = append(subpaths, svgSubpath{points: []svgPoint{p}})
+					current = &subpaths[len(subpaths)-1]
+					pen, start = p, p
+				} else {
+					// further pairs are implicit lines
+					lineTo(p)
+				}
+			case 'l':
+				lineTo(offset(v[0], v[1]))
+			case 'h':
+				if rel {
+					lineTo(svgPoint{pen.x + v[0], pen.y})
+				} else {
+					lineTo(svgPoint{v[0], pen.y})
+				}
+			case 'v':
+				if rel {
+					lineTo(svgPoint{pen.x, pen.y + v[0]})
+				} else {
+					lineTo(svgPoint{pen.x, v[0]})
+				}
+			case 'c', 's':
+				var c1 svgPoint
+				if cmd|0x20 == 'c' {
+					c1 = offset(v[0], v[1])
+					v = v[2:]
+				} else {
+					c1 = pen
+					if strings.IndexByte("cCsS", lastCmd) >= 0 {
+						c1 = svgPoint{2*pen.x - lastCtrl.x, 2*pen.y - lastCtrl.y}
+					}
+				}
+				c2, end := offset(v[0], v[1]), offset(v[2], v[3])
+				p0 := pen
+				for idx := 1; idx <= svgCurveSegments; idx++ {
+					t := float64(idx) / svgCurveSegments
+					mt := 1 - t
+					lineTo(svgPoint{
+						mt*mt*mt*p0.x + 3*mt*mt*t*c1.x + 3*mt*t*t*c2.x + t*t*t*end.x,
+						mt*mt*mt*p0.y + 3*mt*mt*t*c1.y + 3*mt*t*t*c2.y + t*t*t*end.y,
+					})
+				}
+				ctrl = c2
+			case 'q', 't':
+				var c svgPoint
+				var end svgPoint
+				if cmd|0x20 == 'q' {
+					c, end = offset(v[0], v[1]), offset(v[2], v[3])
+				} else {
+					c = pen
+					if strings.IndexByte("qQtT", lastCmd) >= 0 {
+						c = svgPoint{2*pen.x - lastCtrl.x, 2*pen.y - lastCtrl.y}
+					}
+					end = offset(v[0], v[1])
+				}
+				p0 := pen
+				for idx := 1; idx <= svgCurveSegments; idx++ {
+					t := float64(idx) / svgCurveSegments
+					mt := 1 - t
+					lineTo(svgPoint{
+						mt*mt*p0.x + 2*mt*t*c.x + t*t*end.x,
+						mt*mt*p0.y + 2*mt*t*c.y + t*t*end.y,
+					})
+				}
+				ctrl = c
+			case 'a':
+				for _, p := range svgArc(pen, v[0], v[1], v[2], v[3] != 0, v[4] != 0, offset(v[5], v[6])) {
+					lineTo(p)
+				}
+			case 'z':
+				if current != nil {
+					current.closed = true
+				}
+				current = nil
+				pen = start
+			}
+			lastCmd, lastCtrl = cmd, ctrl
+		}
+
+		next, ok := sc.command()
+		if !ok {
+			if sc.skipSeparators(); sc.pos < len(d) {
+				return nil, fmt.Errorf("invalid path data: unexpected %q at offset %d", d[sc.pos], sc.pos)
+			}
+			return subpaths, nil
+		}
+		cmd = next
+	}
+}
+
+// svgArc flattens an elliptical arc from p0 to p1, following the endpoint to
+// centre conversion of the SVG specification. It returns the points after p0.
+func svgArc(p0 svgPoint, rx, ry, rotation float64, large, sweep bool, p1 svgPoint) []svgPoint {
+	rx, ry = math.Abs(rx), math.Abs(ry)
+	if rx == 0 || ry == 0 || p0 == p1 {
+		return []svgPoint{p1}
+	}
+	sinPhi, cosPhi := math.Sincos(rotation * math.Pi / 180)
+	dx, dy := (p0.x-p1.x)/2, (p0.y-p1.y)/2
+	x1 := cosPhi*dx + sinPhi*dy
+	y1 := -sinPhi*dx + cosPhi*dy
+
+	// scale up radii that are too small to reach the end point
+	if lambda := x1*x1/(rx*rx) + y1*y1/(ry*ry); lambda > 1 {
+		rx, ry = rx*math.Sqrt(lambda), ry*math.Sqrt(lambda)
+	}
+
+	num := rx*rx*ry*ry - rx*rx*y1*y1 - ry*ry*x1*x1
+	den := rx*rx*y1*y1 + ry*ry*x1*x1
+	coef := math.Sqrt(max(0, num/den))
+	if large == sweep {
+		coef = -coef
+	}
+	cx1, cy1 := coef*rx*y1/ry, -coef*ry*x1/rx
+	cx := cosPhi*cx1 - sinPhi*cy1 + (p0.x+p1.x)/2
+	cy := sinPhi*cx1 + cosPhi*cy1 + (p0.y+p1.y)/2
+
+	angle := func(ux, uy, vx, vy float64) float64 {
+		return math.Atan2(ux*vy-uy*vx, ux*vx+uy*vy)
+	}
+	theta := angle(1, 0, (x1-cx1)/rx, (y1-cy1)/ry)
+	delta := angle((x1-cx1)/rx, (y1-cy1)/ry, (-x1-cx1)/rx, (-y1-cy1)/ry)
+	if !sweep && delta > 0 {
+		delta -= 2 * math.Pi
+	} else if sweep && delta < 0 {
+		delta += 2 * math.Pi
+	}
+
+	points := make([]svgPoint, 0, svgCurveSegments)
+	for idx := 1; idx <= svgCurveSegments; idx++ {
+		sin, cos := math.Sincos(theta + delta*float64(idx)/svgCurveSegments)
+		points = append(points, svgPoint{
+			cx + cosPhi*rx*cos - sinPhi*ry*sin,
+			cy + sinPhi*rx*cos + cosPhi*ry*sin,
+		})
+	}
+	points[len(points)-1] = p1
+	return points
+}
@@ -0,0 +1,197 @@
+package lcd
+
+import (
+	"image"
+	"image/color"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/achilleas-k/gg13/internal/device"
+	"github.com/achilleas-k/gg13/internal/lcd/lcdtest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/image/colornames"
+)
+
+const testSVG = `<?xml version="1.0" encoding="UTF-8"?>
+<svg xmlns="http://www.w3.org/2000/svg" width="320" height="86" viewBox="0 0 320 86">
+  <rect x="2" y="2" width="316" height="82" fill="none" stroke="black" stroke-width="4"/>
+  <circle cx="43" cy="43" r="30" fill="#222"/>
+  <circle cx="43" cy="43" r="14" style="fill:white"/>
+  <g transform="translate(90,12)" fill="none" stroke="black" stroke-width="3">
+    <path d="M0 62 L20 0 L40 62 Z M60 0 h30 v62 h-30 z"/>
+    <path d="M110 31 a20 31 0 1 0 40 0 a20 31 0 1 0 -40 0"/>
+    <path d="M170 62 C170 0 210 0 210 62"/>
+  </g>
+  <polyline points="10,78 30,70 50,78" fill="none" stroke="black" stroke-width="2"/>
+  <text x="250" y="76">GG13</text>
+</svg>`
+
+func TestRenderSVG(t *testing.T) {
+	img, err := RenderSVG(strings.NewReader(testSVG))
+	require.NoError(t, err)
+	assert.Equal(t, image.Rect(0, 0, device.LCDWidth, device.LCDHeight), img.Bounds())
+	lcdtest.AssertGolden(t, filepath.Join("testdata", "svg.png"), img)
+}
+
+func TestRenderSVGViewport(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	// a square viewBox is centred and scaled to the height of the LCD
+	img, err := RenderSVG(strings.NewReader(`<svg viewBox="0 0 10 10"><rect width="10" height="10"/></svg>`))
+	require.NoError(err)
+	offset := (device.LCDWidth - device.LCDHeight) / 2
+	assert.True(isBlack(img.At(offset+1, 1)))
+	assert.True(isBlack(img.At(offset+device.LCDHeight-2, device.LCDHeight-2)))
+	assert.False(isBlack(img.At(offset-2, 1)))
+	assert.False(isBlack(img.At(offset+device.LCDHeight+1, 1)))
+
+	// without a viewBox or size, user units are pixels
+	img, err = RenderSVG(strings.NewReader(`<svg><rect x="5" y="5" width="2" height="2" fill="rgb(0,0,0)"/></svg>`))
+	require.NoError(err)
+	assert.True(isBlack(img.At(5, 5)))
+	assert.True(isBlack(img.At(6, 6)))
+	assert.False(isBlack(img.At(7, 7)))
+	assert.False(isBlack(img.At(4, 4)))
+
+	// hidden elements aren't drawn
+	img, err = RenderSVG(strings.NewReader(`<svg><g display="none"><rect width="160" height="43"/></g></svg>`))
+	require.NoError(err)
+	assert.False(isBlack(img.At(5, 5)))
+}
+
+func TestRenderSVGDefinitions(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	// definitions aren't drawn where they are, and what's unsupported in them
+	// doesn't fail the document
+	img, err := RenderSVG(strings.NewReader(`<svg>
+  <defs>
+    <linearGradient id="grad"><stop offset="0" stop-color="orange"/></linearGradient>
+    <symbol id="icon"><g><rect width="160" height="43"/></g></symbol>
+  </defs>
+  <clipPath id="clip"><rect width="160" height="43"/></clipPath>
+  <mask id="mask"><rect width="160" height="43" fill="white"/></mask>
+  <pattern id="dots" width="4" height="4"><circle cx="2" cy="2" r="1" fill="var(--dot)"/></pattern>
+  <rect x="100" y="20" width="2" height="2"/>
+</svg>`))
+	require.NoError(err)
+	assert.False(isBlack(img.At(5, 5)))
+	assert.True(isBlack(img.At(100, 20)))
+}
+
+func TestParseSVGColour(t *testing.T) {
+	for value, expected := range map[string]any{
+		"orange":             colornames.Orange,
+		"DarkSlateGray":      colornames.Darkslategray,
+		"rebeccapurple":      color.RGBA{R: 0x66, G: 0x33, B: 0x99, A: 0xff},
+		"#f80":               color.RGBA{R: 0xff, G: 0x88, A: 0xff},
+		"url(#grad)":         color.Black,
+		"url(#grad) orange":  colornames.Orange,
+		"url('#grad') none":  nil,
+		" rgb(255, 128, 0) ": color.RGBA{R: 0xff, G: 0x80, A: 0xff},
+	} {
+		c, err := parseSVGColour(value)
+		require.NoError(t, err, value)
+		assert.Equal(t, expected, c, value)
+	}
+
+	// a gradient without a fallback is drawn in black
+	img, err := RenderSVG(strings.NewReader(`<svg><rect width="10" height="10" fill="orange"/><rect x="20" width="10" height="10" fill="url(#grad)"/></svg>`))
+	require.NoError(t, err)
+	assert.Equal(t, colornames.Orange, img.At(5, 5))
+	assert.True(t, isBlack(img.At(25, 5)))
+}
+
+func isBlack(c interface{ RGBA() (r, g, b, a uint32) }) bool {
+	r, g, b, _ := c.RGBA()
+	return r == 0 && g == 0 && b == 0
+}
+
+func TestRenderSVGErrors(t *testing.T) {
+	testCases := map[string]struct {
+		svg    string
+		errMsg string
+	}{
+		"empty": {
+			svg:    "",
+			errMsg: "failed to render SVG: no svg element",
+		},
+		"not-svg": {
+			svg:    "<html></html>",
+			errMsg: "failed to render SVG: not an SVG document: root element is html",
+		},
+		"bad-xml": {
+			svg:    "<svg><rect></svg>",
+			errMsg: "failed to render SVG: XML syntax error on line 1: element <rect> closed by </svg>",
+		},
+		"bad-viewbox": {
+			svg:    `<svg viewBox="0 0 10"></svg>`,
+			errMsg: `failed to render SVG: svg: invalid viewBox "0 0 10"`,
+		},
+		"bad-colour": {
+			svg:    `<svg><rect fill="chartreux"/></svg>`,
+			errMsg: `failed to render SVG: rect: fill: unsupported colour "chartreux"`,
+		},
+		"bad-fallback": {
+			svg:    `<svg><rect fill="url(#grad) chartreux"/></svg>`,
+			errMsg: `failed to render SVG: rect: fill: unsupported colour "chartreux"`,
+		},
+		"bad-transform": {
+			svg:    `<svg><g transform="spin(4)"></g></svg>`,
+			errMsg: `failed to render SVG: g: invalid transform "spin(4)"`,
+		},
+		"bad-length": {
+			svg:    `<svg><rect width="10em"/></svg>`,
+			errMsg: `failed to render SVG: rect: invalid width "10em"`,
+		},
+		"bad-path": {
+			svg:    `<svg><path d="M 0 0 L 10"/></svg>`,
+			errMsg: "failed to render SVG: path: invalid path data: L: expected a number at offset 10",
+		},
+		"bad-path-command": {
+			svg:    `<svg><path d="M 0 0 X 10"/></svg>`,
+			errMsg: `failed to render SVG: path: invalid path data: unknown command 'X'`,
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			_, err := RenderSVG(strings.NewReader(tc.svg))
+			assert.EqualError(t, err, tc.errMsg)
+		})
+	}
+}
+
+func TestParseSVGPath(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	// numbers without separators, implicit lines after a move and relative
+	// commands
+	subpaths, err := parseSVGPath("m1-1 2,0 .5.5v1H0z")
+	require.NoError(err)
+	require.Len(subpaths, 1)
+	assert.True(subpaths[0].closed)
+	assert.Equal([]svgPoint{{1, -1}, {3, -1}, {3.5, -0.5}, {3.5, 0.5}, {0, 0.5}}, subpaths[0].points)
+
+	// curves end on their end points
+	subpaths, err = parseSVGPath("M0 0 Q5 5 10 0 T20 0 C25 5 30 5 35 0 S45 -5 50 0 A5 5 0 0 1 60 0")
+	require.NoError(err)
+	require.Len(subpaths, 1)
+	points := subpaths[0].points
+	assert.Len(points, 1+5*svgCurveSegments)
+	for idx, end := range []svgPoint{{10, 0}, {20, 0}, {35, 0}, {50, 0}, {60, 0}} {
+		p := points[(idx+1)*svgCurveSegments]
+		assert.InDelta(end.x, p.x, 1e-9)
+		assert.InDelta(end.y, p.y, 1e-9)
+	}
+	// the smooth quadratic mirrors the control point below the line and the
+	// arc with the sweep flag goes above it
+	assert.Less(points[svgCurveSegments+svgCurveSegments/2].y, 0.0)
+	assert.Less(points[4*svgCurveSegments+svgCurveSegments/2].y, 0.0)
+	assert.InDelta(-5, points[4*svgCurveSegments+svgCurveSegments/2].y, 1e-9)
+}
@@ -0,0 +1,35 @@
+package lcd
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+
+	"github.com/achilleas-k/gg13/internal/device"
+)
+
+// size of the squares of the test pattern checkerboard
+const testPatternSquare = 4
+
+// TestPattern renders an image for checking the LCD: a one pixel border
+// around the edge, a checkerboard on the left half, and a label on the right
+// half. Dead pixels and misaligned rows show up as breaks in the pattern.
+func TestPattern() *image.Gray {
+	bounds := image.Rect(0, 0, device.LCDWidth, device.LCDHeight)
+	img := image.NewGray(bounds)
+	draw.Draw(img, bounds, image.White, image.Point{}, draw.Src)
+
+	for y := 0; y < device.LCDHeight; y++ {
+		for x := 0; x < device.LCDWidth; x++ {
+			border := x == 0 || y == 0 || x == device.LCDWidth-1 || y == device.LCDHeight-1
+			checker := x < device.LCDWidth/2 && (x/testPatternSquare+y/testPatternSquare)%2 == 0
+			if border || checker {
+				img.SetGray(x, y, color.Gray{Y: 0})
+			}
+		}
+	}
+
+	DrawText(img, device.LCDWidth/2+4, 4, "GG13 SELF-TEST")
+	DrawText(img, device.LCDWidth/2+4, 4+2*Font3x5.Height, "PRESS KEYS")
+	return img
+}
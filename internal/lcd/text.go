@@ -0,0 +1,65 @@
+package lcd
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"strings"
+
+	"github.com/achilleas-k/gg13/internal/device"
+	"golang.org/x/image/font"
+	"golang.org/x/image/math/fixed"
+)
+
+// textThreshold is the coverage above which an anti-aliased pixel is turned
+// on, since the LCD can only show black and white.
+const textThreshold = 0x80
+
+// TextPage renders text on an image the size of the LCD using [Font3x5], one
+// line per row. Lines that don't fit are cut off.
+func TextPage(text string) *image.Gray {
+	return TextPageFace(text, Font3x5)
+}
+
+// TextPageFace renders text on an image the size of the LCD using the face,
+// one line per row. The anti-aliased text is converted to black and white.
+// Lines that don't fit are cut off.
+func TextPageFace(text string, face font.Face) *image.Gray {
+	bounds := image.Rect(0, 0, device.LCDWidth, device.LCDHeight)
+	mask := image.NewAlpha(bounds)
+
+	metrics := face.Metrics()
+	drawer := font.Drawer{
+		Dst:  mask,
+		Src:  image.Opaque,
+		Face: face,
+	}
+	for idx, line := range strings.Split(text, "\n") {
+		drawer.Dot = fixed.Point26_6{
+			X: 0,
+			Y: metrics.Height*fixed.Int26_6(idx) + metrics.Ascent,
+		}
+		drawer.DrawString(line)
+	}
+
+	img := image.NewGray(bounds)
+	draw.Draw(img, bounds, image.White, image.Point{}, draw.Src)
+	for idx, coverage := range mask.Pix {
+		if coverage >= textThreshold {
+			img.Pix[idx] = 0
+		}
+	}
+	return img
+}
+
+// DrawText draws black text on the image using [Font3x5], with the top left of
+// the first character at x, y.
+func DrawText(img draw.Image, x, y int, text string) {
+	drawer := font.Drawer{
+		Dst:  img,
+		Src:  image.NewUniform(color.Black),
+		Face: Font3x5,
+		Dot:  fixed.P(x, y+Font3x5.Ascent),
+	}
+	drawer.DrawString(text)
+}
@@ -0,0 +1,71 @@
+package lcd
+
+import (
+	"image"
+	"image/draw"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/achilleas-k/gg13/internal/device"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/image/font/gofont/goregular"
+)
+
+func TestTextPage(t *testing.T) {
+	img := TextPage("AB\nC")
+
+	// same as drawing each line with the built-in font
+	expected := image.NewGray(image.Rect(0, 0, device.LCDWidth, device.LCDHeight))
+	draw.Draw(expected, expected.Bounds(), image.White, image.Point{}, draw.Src)
+	DrawText(expected, 0, 0, "AB")
+	DrawText(expected, 0, Font3x5.Height, "C")
+	assert.Equal(t, expected.Pix, img.Pix)
+}
+
+func TestTextPageFace(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	path := filepath.Join(t.TempDir(), "goregular.ttf")
+	require.NoError(os.WriteFile(path, goregular.TTF, 0o644))
+	f, err := LoadFont(path)
+	require.NoError(err)
+	face, err := NewFace(f, 16)
+	require.NoError(err)
+
+	img := TextPageFace("Hg\nHg", face)
+	assert.Equal(image.Rect(0, 0, device.LCDWidth, device.LCDHeight), img.Bounds())
+
+	// anti-aliasing is thresholded away
+	for _, px := range img.Pix {
+		require.Contains([]uint8{0, 255}, px)
+	}
+
+	// the second line is drawn one line height below the first
+	lineHeight := face.Metrics().Height.Ceil()
+	var first, second []image.Point
+	for _, p := range onPixels(img) {
+		if p.Y < lineHeight {
+			first = append(first, p)
+		} else {
+			second = append(second, p.Sub(image.Point{0, lineHeight}))
+		}
+	}
+	assert.NotEmpty(first)
+	assert.Equal(first, second)
+
+	// larger than the built-in font
+	assert.Greater(lineHeight, Font3x5.Height)
+}
+
+func TestLoadFontErrors(t *testing.T) {
+	_, err := LoadFont(filepath.Join(t.TempDir(), "missing.ttf"))
+	assert.ErrorContains(t, err, "failed reading font file")
+
+	path := filepath.Join(t.TempDir(), "bad.ttf")
+	require.NoError(t, os.WriteFile(path, []byte("not a font"), 0o644))
+	_, err = LoadFont(path)
+	assert.ErrorContains(t, err, "failed parsing font file")
+}
@@ -0,0 +1,37 @@
+package lcd
+
+import (
+	"fmt"
+	"os"
+
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/opentype"
+)
+
+// LoadFont loads a TrueType or OpenType font from a file.
+func LoadFont(path string) (*opentype.Font, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed reading font file: %w", err)
+	}
+	f, err := opentype.Parse(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed parsing font file %q: %w", path, err)
+	}
+	return f, nil
+}
+
+// NewFace returns a face for the font with the given size in pixels. Faces
+// aren't safe for concurrent use, so each user should have its own.
+func NewFace(f *opentype.Font, size float64) (font.Face, error) {
+	face, err := opentype.NewFace(f, &opentype.FaceOptions{
+		Size: size,
+		// one point per pixel
+		DPI:     72,
+		Hinting: font.HintingFull,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed creating font face: %w", err)
+	}
+	return face, nil
+}
@@ -0,0 +1,107 @@
+// Package mouse provides the virtual mouse that scroll bindings turn the
+// wheel of and warp bindings move the pointer of.
+package mouse
+
+import (
+	"errors"
+	"fmt"
+	"os"
+)
+
+// ErrUinputUnavailable is returned when the virtual mouse can't be created,
+// usually because the uinput module isn't loaded or /dev/uinput isn't
+// writable.
+var ErrUinputUnavailable = errors.New("uinput unavailable")
+
+const uinputPath = "/dev/uinput"
+
+type Mouse interface {
+	Close() error
+
+	// Wheel turns the vertical or horizontal wheel by the number of
+	// notches: up or right when positive, down or left when negative.
+	Wheel(horizontal bool, notches int) error
+
+	// MoveTo moves the pointer to the absolute position on the screen, in
+	// pixels from the top left corner.
+	MoveTo(x, y int) error
+}
+
+// Options describes the capabilities advertised by the virtual mouse.
+type Options struct {
+	// ScreenWidth and ScreenHeight are the size of the screen in pixels,
+	// which the absolute position of the pointer is in. The mouse can only
+	// move the pointer to a position if they're set; it has relative axes
+	// otherwise.
+	ScreenWidth  int
+	ScreenHeight int
+}
+
+// absolute returns true if the mouse has absolute axes.
+func (opts Options) absolute() bool {
+	return opts.ScreenWidth > 0 && opts.ScreenHeight > 0
+}
+
+type UinputMouse struct {
+	file *os.File
+	opts Options
+}
+
+// New returns a [Mouse] backed by a uinput device with the given name.
+func New(name string, opts Options) (Mouse, error) {
+	file, err := createDevice(uinputPath, name, opts)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrUinputUnavailable, err)
+	}
+	return &UinputMouse{file: file, opts: opts}, nil
+}
+
+func (vms *UinputMouse) Close() error {
+	if vms.file == nil {
+		return nil
+	}
+	err := destroyDevice(vms.file)
+	vms.file = nil
+	return err
+}
+
+func (vms *UinputMouse) Wheel(horizontal bool, notches int) error {
+	if vms.file == nil {
+		return fmt.Errorf("wheel before initialising mouse")
+	}
+	return writeFrame(vms.file, wheelEvent(horizontal, notches))
+}
+
+func (vms *UinputMouse) MoveTo(x, y int) error {
+	if vms.file == nil {
+		return fmt.Errorf("move before initialising mouse")
+	}
+	if !vms.opts.absolute() {
+		return fmt.Errorf("can't move the pointer to a position without the screen size")
+	}
+	if x < 0 || x >= vms.opts.ScreenWidth || y < 0 || y >= vms.opts.ScreenHeight {
+		return fmt.Errorf("position %d,%d outside the %dx%d screen", x, y, vms.opts.ScreenWidth, vms.opts.ScreenHeight)
+	}
+	return writeFrame(vms.file,
+		inputEvent{Type: evAbs, Code: absX, Value: int32(x)},
+		inputEvent{Type: evAbs, Code: absY, Value: int32(y)},
+	)
+}
+
+// wheelEvent returns the event turning the wheel by the notches.
+func wheelEvent(horizontal bool, notches int) inputEvent {
+	ev := inputEvent{Type: evRel, Code: relWheel, Value: int32(notches)}
+	if horizontal {
+		ev.Code = relHWheel
+	}
+	return ev
+}
+
+// Syspath returns the sysfs directory of the uinput device, for finding its
+// event device node.
+func (vms *UinputMouse) Syspath() (string, error) {
+	if vms.file == nil {
+		return "", fmt.Errorf("mouse not initialised")
+	}
+	return syspath(vms.file)
+}
@@ -0,0 +1,89 @@
+package mouse
+
+import (
+	"bytes"
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWheelFrames(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	// a regular file stands in for the uinput device, which writes the
+	// events the same way
+	file, err := os.Create(filepath.Join(t.TempDir(), "uinput"))
+	require.NoError(err)
+	vms := &UinputMouse{file: file}
+	require.NoError(vms.Wheel(false, 2))
+	require.NoError(vms.Wheel(true, -1))
+	require.NoError(file.Close())
+
+	data, err := os.ReadFile(file.Name())
+	require.NoError(err)
+	size := binary.Size(inputEvent{})
+	require.Zero(len(data) % size)
+	var events []inputEvent
+	for offset := 0; offset < len(data); offset += size {
+		var ev inputEvent
+		_, err := binary.Decode(data[offset:], binary.LittleEndian, &ev)
+		require.NoError(err)
+		events = append(events, ev)
+	}
+	sync := inputEvent{Type: evSyn, Code: synReport}
+	assert.Equal([]inputEvent{
+		{Type: evRel, Code: relWheel, Value: 2}, sync,
+		{Type: evRel, Code: relHWheel, Value: -1}, sync,
+	}, events)
+
+	vms.file = nil
+	assert.EqualError(vms.Wheel(false, 1), "wheel before initialising mouse")
+}
+
+func TestMoveTo(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	file, err := os.Create(filepath.Join(t.TempDir(), "uinput"))
+	require.NoError(err)
+	vms := &UinputMouse{file: file, opts: Options{ScreenWidth: 1920, ScreenHeight: 1080}}
+	require.NoError(vms.MoveTo(1200, 640))
+	assert.EqualError(vms.MoveTo(1920, 0), "position 1920,0 outside the 1920x1080 screen")
+	require.NoError(file.Close())
+
+	data, err := os.ReadFile(file.Name())
+	require.NoError(err)
+	var events []inputEvent
+	size := binary.Size(inputEvent{})
+	for offset := 0; offset < len(data); offset += size {
+		var ev inputEvent
+		_, err := binary.Decode(data[offset:], binary.LittleEndian, &ev)
+		require.NoError(err)
+		events = append(events, ev)
+	}
+	assert.Equal([]inputEvent{
+		{Type: evAbs, Code: absX, Value: 1200},
+		{Type: evAbs, Code: absY, Value: 640},
+		{Type: evSyn, Code: synReport},
+	}, events)
+
+	vms.opts = Options{}
+	assert.EqualError(vms.MoveTo(0, 0), "can't move the pointer to a position without the screen size")
+}
+
+func TestUserDev(t *testing.T) {
+	assert := assert.New(t)
+
+	dev := userDev("g13-vms", Options{ScreenWidth: 2560, ScreenHeight: 1440})
+	assert.Equal(int32(2559), dev.Absmax[absX])
+	assert.Equal(int32(1439), dev.Absmax[absY])
+	assert.Equal("g13-vms", string(bytes.TrimRight(dev.Name[:], "\x00")))
+
+	dev = userDev("g13-vms", Options{})
+	assert.Zero(dev.Absmax[absX])
+}
@@ -0,0 +1,231 @@
+package mouse
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"os"
+	"path/filepath"
+	"syscall"
+	"time"
+	"unsafe"
+)
+
+// Definitions from linux/uinput.h and linux/input-event-codes.h, for setting
+// up the device directly like the keyboard and joystick.
+const (
+	uinputMaxNameSize = 80
+	absSize           = 64
+
+	uiDevCreate  = 0x5501
+	uiDevDestroy = 0x5502
+	uiSetEvBit   = 0x40045564
+	uiSetKeyBit  = 0x40045565
+	uiSetRelBit  = 0x40045566
+	uiSetAbsBit  = 0x40045567
+
+	// UI_GET_SYSNAME with a 65 byte buffer
+	uiGetSysname   = 0x8041552c
+	sysnameBufSize = 65
+
+	sysInputDir = "/sys/devices/virtual/input"
+
+	busUSB = 0x03
+
+	// the vendor of the keyboard, with the next product ID
+	vendorID  = 0x4711
+	productID = 0x0816
+
+	evSyn = 0x00
+	evKey = 0x01
+	evRel = 0x02
+	evAbs = 0x03
+
+	synReport = 0
+
+	relX      = 0x00
+	relY      = 0x01
+	relHWheel = 0x06
+	relWheel  = 0x08
+
+	absX = 0x00
+	absY = 0x01
+
+	btnLeft   = 0x110
+	btnRight  = 0x111
+	btnMiddle = 0x112
+
+	// time for udev to set up the new device before events are sent to it
+	settleTime = 200 * time.Millisecond
+)
+
+type inputID struct {
+	Bustype uint16
+	Vendor  uint16
+	Product uint16
+	Version uint16
+}
+
+type uinputUserDev struct {
+	Name       [uinputMaxNameSize]byte
+	ID         inputID
+	EffectsMax uint32
+	Absmax     [absSize]int32
+	Absmin     [absSize]int32
+	Absfuzz    [absSize]int32
+	Absflat    [absSize]int32
+}
+
+type inputEvent struct {
+	Time  syscall.Timeval
+	Type  uint16
+	Code  uint16
+	Value int32
+}
+
+// userDev returns the description of the device. The pointer axes are
+// absolute, over the screen, if the screen size is set, and relative
+// otherwise.
+func userDev(name string, opts Options) uinputUserDev {
+	dev := uinputUserDev{
+		ID: inputID{
+			Bustype: busUSB,
+			Vendor:  vendorID,
+			Product: productID,
+			Version: 1,
+		},
+	}
+	copy(dev.Name[:], name)
+	if opts.absolute() {
+		dev.Absmax[absX] = int32(opts.ScreenWidth - 1)
+		dev.Absmax[absY] = int32(opts.ScreenHeight - 1)
+	}
+	return dev
+}
+
+// createDevice creates the uinput mouse at path. The relative pointer axes
+// and the buttons are never sent, but without them the device isn't taken
+// for a mouse and the wheel is ignored.
+func createDevice(path, name string, opts Options) (*os.File, error) {
+	if len(name) >= uinputMaxNameSize {
+		return nil, fmt.Errorf("device name %q too long: at most %d bytes", name, uinputMaxNameSize-1)
+	}
+	file, err := os.OpenFile(path, os.O_WRONLY|syscall.O_NONBLOCK, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	setup := func() error {
+		evs := []uintptr{evKey, evRel}
+		rels := []uintptr{relHWheel, relWheel}
+		if opts.absolute() {
+			evs = append(evs, evAbs)
+		} else {
+			rels = append(rels, relX, relY)
+		}
+		for _, ev := range evs {
+			if err := ioctl(file, uiSetEvBit, ev); err != nil {
+				return fmt.Errorf("failed enabling event type %d: %w", ev, err)
+			}
+		}
+		for _, btn := range []uintptr{btnLeft, btnRight, btnMiddle} {
+			if err := ioctl(file, uiSetKeyBit, btn); err != nil {
+				return fmt.Errorf("failed enabling button %d: %w", btn, err)
+			}
+		}
+		for _, rel := range rels {
+			if err := ioctl(file, uiSetRelBit, rel); err != nil {
+				return fmt.Errorf("failed enabling axis %d: %w", rel, err)
+			}
+		}
+		if opts.absolute() {
+			for _, abs := range []uintptr{absX, absY} {
+				if err := ioctl(file, uiSetAbsBit, abs); err != nil {
+					return fmt.Errorf("failed enabling axis %d: %w", abs, err)
+				}
+			}
+		}
+
+		buf := new(bytes.Buffer)
+		if err := binary.Write(buf, binary.LittleEndian, userDev(name, opts)); err != nil {
+			return err
+		}
+		if _, err := file.Write(buf.Bytes()); err != nil {
+			return fmt.Errorf("failed writing device description: %w", err)
+		}
+		if err := ioctl(file, uiDevCreate, 0); err != nil {
+			return fmt.Errorf("failed creating device: %w", err)
+		}
+		return nil
+	}
+
+	if err := setup(); err != nil {
+		_ = file.Close()
+		return nil, err
+	}
+	time.Sleep(settleTime)
+	return file, nil
+}
+
+// destroyDevice removes the uinput device and closes the file.
+func destroyDevice(file *os.File) error {
+	if err := ioctl(file, uiDevDestroy, 0); err != nil {
+		_ = file.Close()
+		return fmt.Errorf("failed destroying device: %w", err)
+	}
+	return file.Close()
+}
+
+// writeFrame writes the events to the device followed by a sync report.
+func writeFrame(file *os.File, events ...inputEvent) error {
+	events = append(events, inputEvent{Type: evSyn, Code: synReport})
+	buf := new(bytes.Buffer)
+	for _, ev := range events {
+		if err := binary.Write(buf, binary.LittleEndian, ev); err != nil {
+			return err
+		}
+	}
+	_, err := file.Write(buf.Bytes())
+	return err
+}
+
+// syspath returns the sysfs directory of the uinput device.
+func syspath(file *os.File) (string, error) {
+	buf := make([]byte, sysnameBufSize)
+	if err := ioctlPtr(file, uiGetSysname, unsafe.Pointer(&buf[0])); err != nil {
+		return "", fmt.Errorf("failed getting device name: %w", err)
+	}
+	return filepath.Join(sysInputDir, string(bytes.TrimRight(buf, "\x00"))), nil
+}
+
+// ioctl runs the ioctl on the device.
+func ioctl(file *os.File, cmd, arg uintptr) error {
+	return control(file, func(fd uintptr) syscall.Errno {
+		_, _, errno := syscall.Syscall(syscall.SYS_IOCTL, fd, cmd, arg)
+		return errno
+	})
+}
+
+// ioctlPtr runs an ioctl taking a pointer on the device.
+func ioctlPtr(file *os.File, cmd uintptr, arg unsafe.Pointer) error {
+	return control(file, func(fd uintptr) syscall.Errno {
+		_, _, errno := syscall.Syscall(syscall.SYS_IOCTL, fd, cmd, uintptr(arg))
+		return errno
+	})
+}
+
+// control runs the system call on the file descriptor of the device.
+func control(file *os.File, call func(fd uintptr) syscall.Errno) error {
+	conn, err := file.SyscallConn()
+	if err != nil {
+		return err
+	}
+	var errno syscall.Errno
+	if err := conn.Control(func(fd uintptr) { errno = call(fd) }); err != nil {
+		return err
+	}
+	if errno != 0 {
+		return errno
+	}
+	return nil
+}
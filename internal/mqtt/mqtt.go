@@ -0,0 +1,227 @@
+// Package mqtt connects the driver to an MQTT broker for home automation
+// integration. It publishes key events and the device status and accepts
+// backlight and LCD commands.
+//
+// The device topic is the last will: the broker sets it to "disconnected" if
+// the driver goes away without closing the connection.
+//
+// Topics, relative to the configured prefix:
+//
+//	<prefix>/status         "online" or "offline" (retained)
+//	<prefix>/device         "connected" or "disconnected" (retained)
+//	<prefix>/key/<KEY>      "down" or "up" when a G13 key changes state
+//	<prefix>/backlight/set  colour to set the backlight to
+//	<prefix>/lcd/set        text to show on the LCD
+package mqtt
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	paho "github.com/eclipse/paho.mqtt.golang"
+
+	"github.com/achilleas-k/gg13/internal/i18n"
+)
+
+const (
+	// DefaultTopicPrefix is the prefix for all topics when none is set.
+	DefaultTopicPrefix = "gg13"
+
+	// DefaultClientID is the client ID used when none is set.
+	DefaultClientID = "gg13"
+
+	StatusOnline  = "online"
+	StatusOffline = "offline"
+
+	DeviceConnected    = "connected"
+	DeviceDisconnected = "disconnected"
+
+	// how long to wait for the broker to acknowledge an operation
+	opTimeout = 5 * time.Second
+)
+
+// Options configures the connection to the broker.
+type Options struct {
+	// Broker URL, e.g. tcp://localhost:1883
+	Broker      string
+	ClientID    string
+	Username    string
+	Password    string
+	TopicPrefix string
+}
+
+// Handlers are called for commands received from the broker. A nil handler
+// ignores the command.
+type Handlers struct {
+	Backlight func(colour string) error
+	LCD       func(text string) error
+}
+
+// Client is a connection to the MQTT broker.
+type Client struct {
+	client   paho.Client
+	topics   topics
+	handlers Handlers
+
+	// whether the G13 is connected, published again on every (re)connection
+	// to the broker, which may have set the will since
+	deviceMu        sync.Mutex
+	deviceConnected bool
+}
+
+// topics holds the full names of the topics for a prefix.
+type topics struct {
+	status       string
+	device       string
+	key          string
+	backlightSet string
+	lcdSet       string
+}
+
+func newTopics(prefix string) topics {
+	prefix = strings.TrimSuffix(prefix, "/")
+	return topics{
+		status:       prefix + "/status",
+		device:       prefix + "/device",
+		key:          prefix + "/key/",
+		backlightSet: prefix + "/backlight/set",
+		lcdSet:       prefix + "/lcd/set",
+	}
+}
+
+// New connects to the broker, publishes the online status and whether the
+// device is connected, and subscribes to the command topics. The client
+// reconnects automatically if the connection drops.
+func New(opts Options, handlers Handlers, deviceConnected bool) (*Client, error) {
+	if opts.ClientID == "" {
+		opts.ClientID = DefaultClientID
+	}
+	if opts.TopicPrefix == "" {
+		opts.TopicPrefix = DefaultTopicPrefix
+	}
+
+	c := &Client{
+		topics:          newTopics(opts.TopicPrefix),
+		handlers:        handlers,
+		deviceConnected: deviceConnected,
+	}
+
+	pahoOpts := paho.NewClientOptions().
+		AddBroker(opts.Broker).
+		SetClientID(opts.ClientID).
+		SetUsername(opts.Username).
+		SetPassword(opts.Password).
+		SetAutoReconnect(true).
+		SetWill(c.topics.device, DeviceDisconnected, 1, true).
+		SetOnConnectHandler(c.onConnect)
+	c.client = paho.NewClient(pahoOpts)
+
+	if err := wait(c.client.Connect()); err != nil {
+		return nil, fmt.Errorf("failed connecting to MQTT broker %q: %w", opts.Broker, err)
+	}
+	return c, nil
+}
+
+// onConnect runs on every (re)connection: subscriptions don't survive
+// reconnecting with a clean session.
+func (c *Client) onConnect(client paho.Client) {
+	if err := wait(client.Publish(c.topics.status, 1, true, StatusOnline)); err != nil {
+		fmt.Fprintln(os.Stderr, i18n.Sprintf("mqtt: failed publishing status: %s", err))
+	}
+	c.deviceMu.Lock()
+	token := c.publishDevice(client)
+	c.deviceMu.Unlock()
+	if err := wait(token); err != nil {
+		fmt.Fprintln(os.Stderr, i18n.Sprintf("mqtt: failed publishing status: %s", err))
+	}
+
+	subscriptions := map[string]byte{
+		c.topics.backlightSet: 1,
+		c.topics.lcdSet:       1,
+	}
+	if err := wait(client.SubscribeMultiple(subscriptions, c.onMessage)); err != nil {
+		fmt.Fprintln(os.Stderr, i18n.Sprintf("mqtt: failed subscribing to command topics: %s", err))
+	}
+}
+
+func (c *Client) onMessage(_ paho.Client, msg paho.Message) {
+	if err := c.handleCommand(msg.Topic(), string(msg.Payload())); err != nil {
+		fmt.Fprintln(os.Stderr, i18n.Sprintf("mqtt: %s: %s", msg.Topic(), err))
+	}
+}
+
+// handleCommand calls the handler for the command topic.
+func (c *Client) handleCommand(topic, payload string) error {
+	var handler func(string) error
+	switch topic {
+	case c.topics.backlightSet:
+		handler = c.handlers.Backlight
+	case c.topics.lcdSet:
+		handler = c.handlers.LCD
+	default:
+		return fmt.Errorf("unexpected topic")
+	}
+
+	if handler == nil {
+		return nil
+	}
+	return handler(strings.TrimSpace(payload))
+}
+
+// PublishKey publishes the new state of a G13 key. It doesn't wait for the
+// broker, so it's safe to call from the input loop.
+func (c *Client) PublishKey(key string, down bool) {
+	state := "up"
+	if down {
+		state = "down"
+	}
+	c.client.Publish(c.topics.key+key, 0, false, state)
+}
+
+// SetDeviceConnected publishes whether the G13 is connected. It doesn't wait
+// for the broker, so it's safe to call while reconnecting the device.
+func (c *Client) SetDeviceConnected(connected bool) {
+	if c == nil {
+		return
+	}
+	c.deviceMu.Lock()
+	defer c.deviceMu.Unlock()
+	c.deviceConnected = connected
+	c.publishDevice(c.client)
+}
+
+// publishDevice publishes the connection state of the device. It's called
+// with deviceMu held, so that the last state is published last.
+func (c *Client) publishDevice(client paho.Client) paho.Token {
+	state := DeviceDisconnected
+	if c.deviceConnected {
+		state = DeviceConnected
+	}
+	return client.Publish(c.topics.device, 1, true, state)
+}
+
+// Close publishes the offline status and the disconnected device, since
+// nothing drives it anymore, and disconnects.
+func (c *Client) Close() {
+	if c == nil {
+		return
+	}
+	if err := wait(c.client.Publish(c.topics.status, 1, true, StatusOffline)); err != nil {
+		fmt.Fprintln(os.Stderr, i18n.Sprintf("mqtt: failed publishing status: %s", err))
+	}
+	if err := wait(c.client.Publish(c.topics.device, 1, true, DeviceDisconnected)); err != nil {
+		fmt.Fprintln(os.Stderr, i18n.Sprintf("mqtt: failed publishing status: %s", err))
+	}
+	c.client.Disconnect(250)
+}
+
+// wait waits for the token to complete and returns its error.
+func wait(token paho.Token) error {
+	if !token.WaitTimeout(opTimeout) {
+		return fmt.Errorf("timed out waiting for broker")
+	}
+	return token.Error()
+}
@@ -0,0 +1,100 @@
+package mqtt
+
+import (
+	"fmt"
+	"testing"
+
+	paho "github.com/eclipse/paho.mqtt.golang"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewTopics(t *testing.T) {
+	expected := topics{
+		status:       "home/g13/status",
+		device:       "home/g13/device",
+		key:          "home/g13/key/",
+		backlightSet: "home/g13/backlight/set",
+		lcdSet:       "home/g13/lcd/set",
+	}
+	assert.Equal(t, expected, newTopics("home/g13"))
+	assert.Equal(t, expected, newTopics("home/g13/"))
+}
+
+func TestHandleCommand(t *testing.T) {
+	var backlight, text string
+	c := &Client{
+		topics: newTopics(DefaultTopicPrefix),
+		handlers: Handlers{
+			Backlight: func(colour string) error {
+				backlight = colour
+				return nil
+			},
+			LCD: func(payload string) error {
+				text = payload
+				return fmt.Errorf("lcd failed")
+			},
+		},
+	}
+
+	assert.NoError(t, c.handleCommand("gg13/backlight/set", " #ff8800\n"))
+	assert.Equal(t, "#ff8800", backlight)
+
+	assert.EqualError(t, c.handleCommand("gg13/lcd/set", "HELLO"), "lcd failed")
+	assert.Equal(t, "HELLO", text)
+
+	assert.EqualError(t, c.handleCommand("gg13/other", ""), "unexpected topic")
+
+	// commands without a handler are ignored
+	c.handlers = Handlers{}
+	assert.NoError(t, c.handleCommand("gg13/backlight/set", "red"))
+}
+
+// testPahoClient records the messages published with it and accepts
+// subscriptions. Calling any other method panics.
+type testPahoClient struct {
+	paho.Client
+
+	published []string
+}
+
+func (c *testPahoClient) Publish(topic string, _ byte, retained bool, payload any) paho.Token {
+	c.published = append(c.published, fmt.Sprintf("%s %v %s", topic, retained, payload))
+	return &paho.DummyToken{}
+}
+
+func (c *testPahoClient) SubscribeMultiple(map[string]byte, paho.MessageHandler) paho.Token {
+	return &paho.DummyToken{}
+}
+
+func (c *testPahoClient) Disconnect(uint) {}
+
+func TestDeviceConnected(t *testing.T) {
+	client := &testPahoClient{}
+	c := &Client{
+		client:          client,
+		topics:          newTopics(DefaultTopicPrefix),
+		deviceConnected: true,
+	}
+
+	// the state is published again on reconnection
+	c.onConnect(client)
+	c.SetDeviceConnected(false)
+	c.onConnect(client)
+	c.SetDeviceConnected(true)
+	c.Close()
+
+	assert.Equal(t, []string{
+		"gg13/status true online",
+		"gg13/device true connected",
+		"gg13/device true disconnected",
+		"gg13/status true online",
+		"gg13/device true disconnected",
+		"gg13/device true connected",
+		"gg13/status true offline",
+		"gg13/device true disconnected",
+	}, client.published)
+
+	// a disabled integration ignores the device
+	var disabled *Client
+	disabled.SetDeviceConnected(true)
+}
@@ -0,0 +1,124 @@
+package output
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"sync"
+
+	"github.com/achilleas-k/gg13/internal/keyboard"
+)
+
+func init() {
+	Register("dry-run", func(opts Options) (Sink, error) {
+		return NewDryRun(os.Stdout, opts.Joystick != nil, opts.Mouse != nil), nil
+	})
+}
+
+// NewDryRun returns a [Sink] that doesn't send any input and instead writes a
+// line to w for each event, for testing bindings without affecting the
+// system.
+func NewDryRun(w io.Writer, withJoystick, withMouse bool) Sink {
+	log := &dryRunLog{w: w}
+	sink := Sink{Keyboard: &dryRunKeyboard{log}}
+	if withJoystick {
+		sink.Joystick = &dryRunJoystick{log}
+	}
+	if withMouse {
+		sink.Mouse = &dryRunMouse{log}
+	}
+	return sink
+}
+
+type dryRunLog struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+func (l *dryRunLog) printf(format string, args ...any) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	_, err := fmt.Fprintf(l.w, format+"\n", args...)
+	return err
+}
+
+type dryRunKeyboard struct {
+	log *dryRunLog
+}
+
+func (kb *dryRunKeyboard) Close() error {
+	return nil
+}
+
+func (kb *dryRunKeyboard) KeyPress(k int) error {
+	return kb.log.printf("key press %s", keyName(k))
+}
+
+func (kb *dryRunKeyboard) KeyDown(k int) error {
+	return kb.log.printf("key down %s", keyName(k))
+}
+
+func (kb *dryRunKeyboard) KeyUp(k int) error {
+	return kb.log.printf("key up %s", keyName(k))
+}
+
+// TypeChord logs the key events of the chord in the order they're sent.
+func (kb *dryRunKeyboard) TypeChord(mods []int, key int) error {
+	return keyboard.TypeChordKeys(kb, mods, key)
+}
+
+// keyName returns the name of the keyboard key, or its code if it has none.
+func keyName(k int) string {
+	if name := keyboard.KeyName(k); name != "" {
+		return name
+	}
+	return strconv.Itoa(k)
+}
+
+type dryRunJoystick struct {
+	log *dryRunLog
+}
+
+func (js *dryRunJoystick) Close() error {
+	return nil
+}
+
+func (js *dryRunJoystick) ButtonPress(b int) error {
+	return js.log.printf("button press %d", b)
+}
+
+func (js *dryRunJoystick) ButtonDown(b int) error {
+	return js.log.printf("button down %d", b)
+}
+
+func (js *dryRunJoystick) ButtonUp(b int) error {
+	return js.log.printf("button up %d", b)
+}
+
+func (js *dryRunJoystick) StickPosition(x, y float32) error {
+	return js.log.printf("stick %.3f %.3f", x, y)
+}
+
+func (js *dryRunJoystick) HatPosition(x, y int) error {
+	return js.log.printf("hat %d %d", x, y)
+}
+
+type dryRunMouse struct {
+	log *dryRunLog
+}
+
+func (ms *dryRunMouse) Close() error {
+	return nil
+}
+
+func (ms *dryRunMouse) Wheel(horizontal bool, notches int) error {
+	if horizontal {
+		return ms.log.printf("hwheel %d", notches)
+	}
+	return ms.log.printf("wheel %d", notches)
+}
+
+func (ms *dryRunMouse) MoveTo(x, y int) error {
+	return ms.log.printf("pointer %d %d", x, y)
+}
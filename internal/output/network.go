@@ -0,0 +1,453 @@
+package output
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/achilleas-k/gg13/internal/backoff"
+	"github.com/achilleas-k/gg13/internal/i18n"
+	"github.com/achilleas-k/gg13/internal/joystick"
+	"github.com/achilleas-k/gg13/internal/mouse"
+)
+
+const (
+	// netHandshakeTimeout limits how long connecting to the receiver and
+	// authenticating can take on either side.
+	netHandshakeTimeout = 5 * time.Second
+
+	// netSendTimeout limits how long sending an event can block the input
+	// loop when the receiver stops reading.
+	netSendTimeout = time.Second
+
+	// netHelloLimit is the most the receiver reads from a connection before
+	// it's authenticated.
+	netHelloLimit = 64 << 10
+)
+
+// netReconnectPolicy is the backoff between attempts to connect to the
+// receiver again after the connection broke.
+var netReconnectPolicy = backoff.Policy{Min: 100 * time.Millisecond, Max: 10 * time.Second}
+
+// ErrDisconnected is returned for the events sent while the network output
+// is reconnecting to the receiver. They're dropped: the receiver releases the
+// keys of its devices when the connection breaks.
+var ErrDisconnected = errors.New("network output: disconnected from the receiver")
+
+func init() {
+	Register("network", openNetwork)
+}
+
+// netHello is the first message on a connection to the receiver, describing
+// the devices to create for the events that follow.
+type netHello struct {
+	Token        string            `json:"token"`
+	KeyboardName string            `json:"keyboard_name"`
+	JoystickName string            `json:"joystick_name,omitempty"`
+	Joystick     *joystick.Options `json:"joystick,omitempty"`
+	MouseName    string            `json:"mouse_name,omitempty"`
+	Mouse        *mouse.Options    `json:"mouse,omitempty"`
+}
+
+// netAck is the receiver's reply to the [netHello]. Error is empty if the
+// receiver is ready for events.
+type netAck struct {
+	Error string `json:"error,omitempty"`
+}
+
+// netEvent is a keyboard, joystick or mouse event. Code is the key or button,
+// or the notches the wheel turns, and X and Y the stick, hat or pointer
+// position, depending on the type. Mods are the modifiers of a chord.
+type netEvent struct {
+	Type string  `json:"type"`
+	Code int     `json:"code,omitempty"`
+	X    float32 `json:"x,omitempty"`
+	Y    float32 `json:"y,omitempty"`
+	Mods []int   `json:"mods,omitempty"`
+}
+
+// openNetwork connects to a receiver started with [Receive] on another
+// machine, which creates the keyboard and joystick there and replays the
+// events sent to them.
+func openNetwork(opts Options) (Sink, error) {
+	if opts.Address == "" {
+		return Sink{}, fmt.Errorf("network output: no receiver address set")
+	}
+	if opts.Token == "" {
+		return Sink{}, fmt.Errorf("network output: a token is required")
+	}
+
+	sender := &netSender{
+		addr: opts.Address,
+		stop: make(chan struct{}),
+		hello: netHello{
+			Token:        opts.Token,
+			KeyboardName: opts.KeyboardName,
+			JoystickName: opts.JoystickName,
+			Joystick:     opts.Joystick,
+			MouseName:    opts.MouseName,
+			Mouse:        opts.Mouse,
+		},
+	}
+	conn, err := sender.connect()
+	if err != nil {
+		return Sink{}, err
+	}
+	sender.setConn(conn)
+
+	sink := Sink{Keyboard: &netKeyboard{sender}}
+	if opts.Joystick != nil {
+		sink.Joystick = &netJoystick{sender}
+	}
+	if opts.Mouse != nil {
+		sink.Mouse = &netMouse{sender}
+	}
+	return sink, nil
+}
+
+// netSender sends events to the receiver over one connection. If sending
+// fails, it reconnects in the background, so the input loop doesn't wait for
+// the receiver, and drops the events sent in the meantime.
+type netSender struct {
+	mu    sync.Mutex
+	addr  string
+	hello netHello
+	conn  net.Conn
+	enc   *json.Encoder
+
+	// a goroutine is reconnecting
+	reconnecting bool
+
+	closed bool
+	stop   chan struct{}
+}
+
+// connect connects and authenticates to the receiver.
+func (s *netSender) connect() (net.Conn, error) {
+	conn, err := net.DialTimeout("tcp", s.addr, netHandshakeTimeout)
+	if err != nil {
+		return nil, fmt.Errorf("network output: failed to connect to %q: %w", s.addr, err)
+	}
+	if err := s.handshake(conn); err != nil {
+		_ = conn.Close()
+		return nil, fmt.Errorf("network output: %q: %w", s.addr, err)
+	}
+	return conn, nil
+}
+
+// setConn sends the events over the connection from now on. The lock must be
+// held if the sender is in use.
+func (s *netSender) setConn(conn net.Conn) {
+	s.conn = conn
+	s.enc = json.NewEncoder(conn)
+}
+
+// startReconnect starts reconnecting in the background, unless it's already
+// reconnecting. The lock must be held.
+func (s *netSender) startReconnect() {
+	if s.reconnecting || s.closed {
+		return
+	}
+	s.reconnecting = true
+	go s.reconnect()
+}
+
+// reconnect connects to the receiver again, with increasing delays between
+// the attempts, until it succeeds or the sender is closed.
+func (s *netSender) reconnect() {
+	retry := backoff.New(netReconnectPolicy)
+	for {
+		conn, err := s.connect()
+		s.mu.Lock()
+		if s.closed {
+			s.reconnecting = false
+			s.mu.Unlock()
+			if conn != nil {
+				_ = conn.Close()
+			}
+			return
+		}
+		if err == nil {
+			s.setConn(conn)
+			s.reconnecting = false
+			s.mu.Unlock()
+			fmt.Fprintln(os.Stderr, i18n.Sprintf("network output: reconnected to %q", s.addr))
+			return
+		}
+		s.mu.Unlock()
+
+		delay := retry.Next()
+		fmt.Fprintln(os.Stderr, i18n.Sprintf("%s: retrying in %s", err, delay.Round(time.Millisecond)))
+		select {
+		case <-time.After(delay):
+		case <-s.stop:
+			return
+		}
+	}
+}
+
+func (s *netSender) handshake(conn net.Conn) error {
+	if err := conn.SetDeadline(time.Now().Add(netHandshakeTimeout)); err != nil {
+		return err
+	}
+	if err := json.NewEncoder(conn).Encode(s.hello); err != nil {
+		return fmt.Errorf("failed sending handshake: %w", err)
+	}
+	var ack netAck
+	if err := json.NewDecoder(conn).Decode(&ack); err != nil {
+		return fmt.Errorf("failed reading handshake reply: %w", err)
+	}
+	if ack.Error != "" {
+		return errors.New(ack.Error)
+	}
+	return conn.SetDeadline(time.Time{})
+}
+
+func (s *netSender) send(ev netEvent) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.conn == nil {
+		s.startReconnect()
+		return ErrDisconnected
+	}
+
+	err := s.conn.SetWriteDeadline(time.Now().Add(netSendTimeout))
+	if err == nil {
+		err = s.enc.Encode(ev)
+	}
+	if err != nil {
+		_ = s.conn.Close()
+		s.conn = nil
+		s.startReconnect()
+		return fmt.Errorf("network output: failed sending to %q: %w", s.addr, err)
+	}
+	return nil
+}
+
+func (s *netSender) close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.closed {
+		s.closed = true
+		close(s.stop)
+	}
+	if s.conn == nil {
+		return nil
+	}
+	err := s.conn.Close()
+	s.conn = nil
+	return err
+}
+
+// netKeyboard sends keyboard events to the receiver. Closing it closes the
+// connection, which the receiver takes as closing both devices.
+type netKeyboard struct {
+	sender *netSender
+}
+
+func (kb *netKeyboard) Close() error {
+	return kb.sender.close()
+}
+
+func (kb *netKeyboard) KeyPress(k int) error {
+	return kb.sender.send(netEvent{Type: "key_press", Code: k})
+}
+
+func (kb *netKeyboard) KeyDown(k int) error {
+	return kb.sender.send(netEvent{Type: "key_down", Code: k})
+}
+
+func (kb *netKeyboard) KeyUp(k int) error {
+	return kb.sender.send(netEvent{Type: "key_up", Code: k})
+}
+
+// TypeChord sends the chord as one event, which the receiver types with its
+// own keyboard.
+func (kb *netKeyboard) TypeChord(mods []int, key int) error {
+	return kb.sender.send(netEvent{Type: "key_chord", Code: key, Mods: mods})
+}
+
+// netJoystick sends joystick events to the receiver over the connection of
+// the keyboard.
+type netJoystick struct {
+	sender *netSender
+}
+
+func (js *netJoystick) Close() error {
+	return nil
+}
+
+func (js *netJoystick) ButtonPress(b int) error {
+	return js.sender.send(netEvent{Type: "button_press", Code: b})
+}
+
+func (js *netJoystick) ButtonDown(b int) error {
+	return js.sender.send(netEvent{Type: "button_down", Code: b})
+}
+
+func (js *netJoystick) ButtonUp(b int) error {
+	return js.sender.send(netEvent{Type: "button_up", Code: b})
+}
+
+func (js *netJoystick) StickPosition(x, y float32) error {
+	return js.sender.send(netEvent{Type: "stick", X: x, Y: y})
+}
+
+func (js *netJoystick) HatPosition(x, y int) error {
+	return js.sender.send(netEvent{Type: "hat", X: float32(x), Y: float32(y)})
+}
+
+// netMouse sends mouse events to the receiver over the connection of the
+// keyboard.
+type netMouse struct {
+	sender *netSender
+}
+
+func (ms *netMouse) Close() error {
+	return nil
+}
+
+func (ms *netMouse) Wheel(horizontal bool, notches int) error {
+	if horizontal {
+		return ms.sender.send(netEvent{Type: "hwheel", Code: notches})
+	}
+	return ms.sender.send(netEvent{Type: "wheel", Code: notches})
+}
+
+func (ms *netMouse) MoveTo(x, y int) error {
+	return ms.sender.send(netEvent{Type: "pointer", X: float32(x), Y: float32(y)})
+}
+
+// Receive accepts connections from the network backend of gg13 instances on
+// other machines and replays their events on sinks opened with the named
+// backend, one per connection, until the listener is closed. Connections that
+// don't authenticate with the token are rejected. Nothing is encrypted, not
+// even the token: only receive on a trusted network.
+func Receive(listener net.Listener, token, backend string) error {
+	if token == "" {
+		return fmt.Errorf("a token is required for receiving output")
+	}
+	if err := Validate(backend); err != nil {
+		return err
+	}
+
+	for {
+		conn, err := listener.Accept()
+		if errors.Is(err, net.ErrClosed) {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("failed accepting connection: %w", err)
+		}
+		go func() {
+			if err := receiveConn(conn, token, backend); err != nil {
+				fmt.Fprintf(os.Stderr, "%s: %s\n", conn.RemoteAddr(), err)
+			}
+		}()
+	}
+}
+
+func receiveConn(conn net.Conn, token, backend string) error {
+	defer func() { _ = conn.Close() }()
+
+	if err := conn.SetDeadline(time.Now().Add(netHandshakeTimeout)); err != nil {
+		return err
+	}
+	// don't read more than the handshake needs until it's authenticated
+	limited := &io.LimitedReader{R: conn, N: netHelloLimit}
+	dec := json.NewDecoder(limited)
+	enc := json.NewEncoder(conn)
+	var hello netHello
+	if err := dec.Decode(&hello); err != nil {
+		return fmt.Errorf("failed reading handshake: %w", err)
+	}
+	if subtle.ConstantTimeCompare([]byte(hello.Token), []byte(token)) != 1 {
+		_ = enc.Encode(netAck{Error: "unauthorized: invalid token"})
+		return fmt.Errorf("unauthorized: invalid token")
+	}
+	limited.N = math.MaxInt64
+
+	sink, err := Open(backend, Options{
+		KeyboardName: hello.KeyboardName,
+		JoystickName: hello.JoystickName,
+		Joystick:     hello.Joystick,
+		MouseName:    hello.MouseName,
+		Mouse:        hello.Mouse,
+	})
+	if err != nil {
+		_ = enc.Encode(netAck{Error: fmt.Sprintf("receiver failed opening output: %s", err)})
+		return err
+	}
+	defer func() { _ = sink.Close() }()
+
+	if err := enc.Encode(netAck{}); err != nil {
+		return fmt.Errorf("failed sending handshake reply: %w", err)
+	}
+	if err := conn.SetDeadline(time.Time{}); err != nil {
+		return err
+	}
+	fmt.Println(i18n.Sprintf("Receiving output from %s", conn.RemoteAddr()))
+
+	for {
+		var ev netEvent
+		if err := dec.Decode(&ev); err != nil {
+			if errors.Is(err, io.EOF) {
+				fmt.Println(i18n.Sprintf("%s disconnected", conn.RemoteAddr()))
+				return nil
+			}
+			return fmt.Errorf("failed reading event: %w", err)
+		}
+		if err := replay(sink, ev); err != nil {
+			return err
+		}
+	}
+}
+
+// replay sends a received event to the sink.
+func replay(sink Sink, ev netEvent) error {
+	switch ev.Type {
+	case "key_press":
+		return sink.Keyboard.KeyPress(ev.Code)
+	case "key_down":
+		return sink.Keyboard.KeyDown(ev.Code)
+	case "key_up":
+		return sink.Keyboard.KeyUp(ev.Code)
+	case "key_chord":
+		return sink.Keyboard.TypeChord(ev.Mods, ev.Code)
+	}
+
+	switch ev.Type {
+	case "wheel", "hwheel", "pointer":
+		if sink.Mouse == nil {
+			return fmt.Errorf("unexpected %s event: no mouse was requested", ev.Type)
+		}
+		if ev.Type == "pointer" {
+			return sink.Mouse.MoveTo(int(ev.X), int(ev.Y))
+		}
+		return sink.Mouse.Wheel(ev.Type == "hwheel", ev.Code)
+	}
+
+	if sink.Joystick == nil {
+		return fmt.Errorf("unexpected %s event: no joystick was requested", ev.Type)
+	}
+	switch ev.Type {
+	case "button_press":
+		return sink.Joystick.ButtonPress(ev.Code)
+	case "button_down":
+		return sink.Joystick.ButtonDown(ev.Code)
+	case "button_up":
+		return sink.Joystick.ButtonUp(ev.Code)
+	case "stick":
+		return sink.Joystick.StickPosition(ev.X, ev.Y)
+	case "hat":
+		return sink.Joystick.HatPosition(int(ev.X), int(ev.Y))
+	}
+	return fmt.Errorf("unknown event type %q", ev.Type)
+}
@@ -0,0 +1,213 @@
+package output_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/achilleas-k/gg13/internal/joystick"
+	"github.com/achilleas-k/gg13/internal/mouse"
+	"github.com/achilleas-k/gg13/internal/output"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// syncBuffer is a [bytes.Buffer] that can be read while the receiver writes
+// to it.
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
+// startReceiver starts a receiver replaying events on a dry run sink writing
+// to the returned buffer.
+func startReceiver(t *testing.T, token string) (string, *syncBuffer) {
+	buf := &syncBuffer{}
+	backend := registerTestBackend(func(opts output.Options) (output.Sink, error) {
+		return output.NewDryRun(buf, opts.Joystick != nil, opts.Mouse != nil), nil
+	})
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = listener.Close() })
+	go func() {
+		assert.NoError(t, output.Receive(listener, token, backend))
+	}()
+	return listener.Addr().String(), buf
+}
+
+func TestNetwork(t *testing.T) {
+	assert := assert.New(t)
+
+	addr, buf := startReceiver(t, "secret")
+
+	jsOpts := joystick.DefaultOptions()
+	sink, err := output.Open("network", output.Options{
+		KeyboardName: "g13-vkb",
+		JoystickName: "g13-vjs",
+		Joystick:     &jsOpts,
+		MouseName:    "g13-vms",
+		Mouse:        &mouse.Options{ScreenWidth: 1920, ScreenHeight: 1080},
+		Address:      addr,
+		Token:        "secret",
+	})
+	require.NoError(t, err)
+
+	assert.NoError(sink.Keyboard.KeyDown(30))
+	assert.NoError(sink.Keyboard.KeyUp(30))
+	assert.NoError(sink.Keyboard.TypeChord([]int{29, 42}, 46))
+	assert.NoError(sink.Joystick.StickPosition(0.25, -0.5))
+	assert.NoError(sink.Joystick.HatPosition(1, 0))
+	assert.NoError(sink.Mouse.Wheel(false, 3))
+	assert.NoError(sink.Mouse.MoveTo(1200, 640))
+	assert.NoError(sink.Close())
+
+	expected := `key down KeyA
+key up KeyA
+key down KeyLeftctrl
+key down KeyLeftshift
+key down KeyC
+key up KeyC
+key up KeyLeftshift
+key up KeyLeftctrl
+stick 0.250 -0.500
+hat 1 0
+wheel 3
+pointer 1200 640
+`
+	assert.Eventually(func() bool {
+		return buf.String() == expected
+	}, time.Second, 10*time.Millisecond)
+}
+
+func TestNetworkErrors(t *testing.T) {
+	addr, _ := startReceiver(t, "secret")
+
+	testCases := map[string]struct {
+		opts        output.Options
+		expectedErr string
+	}{
+		"no-address": {
+			opts:        output.Options{Token: "secret"},
+			expectedErr: "network output: no receiver address set",
+		},
+		"no-token": {
+			opts:        output.Options{Address: addr},
+			expectedErr: "network output: a token is required",
+		},
+		"bad-token": {
+			opts:        output.Options{Address: addr, Token: "guess"},
+			expectedErr: "network output: \"" + addr + "\": unauthorized: invalid token",
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			_, err := output.Open("network", tc.opts)
+			assert.EqualError(t, err, tc.expectedErr)
+		})
+	}
+}
+
+func TestReceiveNoToken(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer listener.Close()
+	assert.EqualError(t, output.Receive(listener, "", "dry-run"), "a token is required for receiving output")
+}
+
+// serveHandshake accepts one connection on the listener, completes the
+// handshake of the network output and sends the connection to accepted.
+func serveHandshake(t *testing.T, listener net.Listener, accepted chan<- net.Conn) {
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		var hello map[string]any
+		assert.NoError(t, json.NewDecoder(conn).Decode(&hello))
+		assert.NoError(t, json.NewEncoder(conn).Encode(map[string]any{}))
+		accepted <- conn
+	}()
+}
+
+func TestNetworkReconnect(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(err)
+	addr := listener.Addr().String()
+	accepted := make(chan net.Conn, 1)
+	serveHandshake(t, listener, accepted)
+
+	sink, err := output.Open("network", output.Options{Address: addr, Token: "secret"})
+	require.NoError(err)
+	defer sink.Close()
+	conn := <-accepted
+
+	// the receiver goes away: sending fails, and then drops the events
+	// without waiting for it while reconnecting
+	require.NoError(conn.Close())
+	require.NoError(listener.Close())
+	require.Eventually(func() bool {
+		return errors.Is(sink.Keyboard.KeyDown(30), output.ErrDisconnected)
+	}, time.Second, 10*time.Millisecond)
+	start := time.Now()
+	assert.ErrorIs(sink.Keyboard.KeyDown(30), output.ErrDisconnected)
+	assert.Less(time.Since(start), 100*time.Millisecond)
+
+	// and comes back
+	listener, err = net.Listen("tcp", addr)
+	require.NoError(err)
+	defer listener.Close()
+	serveHandshake(t, listener, accepted)
+	select {
+	case conn = <-accepted:
+	case <-time.After(5 * time.Second):
+		require.Fail("didn't reconnect")
+	}
+	defer conn.Close()
+	require.Eventually(func() bool {
+		return sink.Keyboard.KeyUp(30) == nil
+	}, time.Second, 10*time.Millisecond)
+	var ev map[string]any
+	require.NoError(json.NewDecoder(conn).Decode(&ev))
+	assert.Equal("key_up", ev["type"])
+}
+
+func TestReceiveHandshakeLimit(t *testing.T) {
+	addr, _ := startReceiver(t, "secret")
+	conn, err := net.Dial("tcp", addr)
+	require.NoError(t, err)
+	defer conn.Close()
+
+	// a handshake that never ends is cut off before the handshake times out
+	go func() {
+		_, _ = conn.Write([]byte(`{"token":"` + strings.Repeat("a", 128<<10)))
+	}()
+	require.NoError(t, conn.SetReadDeadline(time.Now().Add(2*time.Second)))
+	_, err = io.ReadAll(conn)
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		assert.False(t, netErr.Timeout(), "the receiver kept reading")
+	}
+}
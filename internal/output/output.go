@@ -0,0 +1,117 @@
+// Package output provides the sinks that the mapped G13 input is sent to, in
+// a registry of named backends, so the backend can be chosen in the config and
+// new ones added without changing the input loop.
+package output
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/achilleas-k/gg13/internal/joystick"
+	"github.com/achilleas-k/gg13/internal/keyboard"
+	"github.com/achilleas-k/gg13/internal/mouse"
+)
+
+// DefaultSink is the backend used when the config doesn't set one.
+const DefaultSink = "uinput"
+
+// Sink is an open output backend: the keyboard that key bindings are sent to
+// and, if they were requested, the joystick that the stick is sent to and the
+// mouse that scroll bindings turn the wheel of.
+type Sink struct {
+	Keyboard keyboard.Keyboard
+
+	// Joystick is nil unless Options.Joystick was set.
+	Joystick joystick.Joystick
+
+	// Mouse is nil unless Options.Mouse was set.
+	Mouse mouse.Mouse
+}
+
+// Close closes the keyboard, joystick and mouse of the sink.
+func (s Sink) Close() error {
+	var errs []error
+	if s.Keyboard != nil {
+		errs = append(errs, s.Keyboard.Close())
+	}
+	if s.Joystick != nil {
+		errs = append(errs, s.Joystick.Close())
+	}
+	if s.Mouse != nil {
+		errs = append(errs, s.Mouse.Close())
+	}
+	return errors.Join(errs...)
+}
+
+// Options configures the sink opened by [Open].
+type Options struct {
+	// KeyboardName, JoystickName and MouseName are the names of the
+	// devices, for backends that create them.
+	KeyboardName string
+	JoystickName string
+	MouseName    string
+
+	// Joystick is the joystick to open, or nil if none is needed.
+	Joystick *joystick.Options
+
+	// Mouse is the mouse to open, or nil if none is needed.
+	Mouse *mouse.Options
+
+	// Address and Token are where to send the output and how to
+	// authenticate, for backends that send it to another machine.
+	Address string
+	Token   string
+}
+
+// Factory opens a [Sink] for a backend.
+type Factory func(opts Options) (Sink, error)
+
+var (
+	registryMu sync.RWMutex
+	registry   = make(map[string]Factory)
+)
+
+// Register makes a backend available under the name. It panics if the name is
+// already registered, like registering the same backend twice from init
+// functions would.
+func Register(name string, factory Factory) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	if _, exists := registry[name]; exists {
+		panic(fmt.Sprintf("output: backend %q registered twice", name))
+	}
+	registry[name] = factory
+}
+
+// Names returns the names of the registered backends, sorted.
+func Names() []string {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	return slices.Sorted(maps.Keys(registry))
+}
+
+// Validate returns an error if no backend is registered under the name.
+func Validate(name string) error {
+	registryMu.RLock()
+	_, ok := registry[name]
+	registryMu.RUnlock()
+	if !ok {
+		return fmt.Errorf("unknown output %q: available outputs: %s", name, strings.Join(Names(), ", "))
+	}
+	return nil
+}
+
+// Open opens the sink of the named backend.
+func Open(name string, opts Options) (Sink, error) {
+	if err := Validate(name); err != nil {
+		return Sink{}, err
+	}
+	registryMu.RLock()
+	factory := registry[name]
+	registryMu.RUnlock()
+	return factory(opts)
+}
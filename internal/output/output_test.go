@@ -0,0 +1,90 @@
+package output_test
+
+import (
+	"bytes"
+	"fmt"
+	"sync/atomic"
+	"testing"
+
+	"github.com/achilleas-k/gg13/internal/joystick"
+	"github.com/achilleas-k/gg13/internal/output"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRegistry(t *testing.T) {
+	assert := assert.New(t)
+
+	assert.Contains(output.Names(), output.DefaultSink)
+	assert.Contains(output.Names(), "dry-run")
+	assert.NoError(output.Validate("dry-run"))
+	assert.ErrorContains(output.Validate("carrier-pigeon"), `unknown output "carrier-pigeon": available outputs: dry-run, network`)
+
+	_, err := output.Open("carrier-pigeon", output.Options{})
+	assert.Error(err)
+
+	assert.Panics(func() {
+		output.Register("dry-run", nil)
+	})
+}
+
+var testBackends atomic.Int32
+
+// registerTestBackend registers the factory under a new name, so tests can
+// run more than once, and returns the name.
+func registerTestBackend(factory output.Factory) string {
+	name := fmt.Sprintf("test-%d", testBackends.Add(1))
+	output.Register(name, factory)
+	return name
+}
+
+func TestRegisterCustom(t *testing.T) {
+	assert := assert.New(t)
+
+	var buf bytes.Buffer
+	var gotOpts output.Options
+	backend := registerTestBackend(func(opts output.Options) (output.Sink, error) {
+		gotOpts = opts
+		return output.NewDryRun(&buf, opts.Joystick != nil, opts.Mouse != nil), nil
+	})
+
+	jsOpts := joystick.DefaultOptions()
+	opts := output.Options{KeyboardName: "kb", JoystickName: "js", Joystick: &jsOpts}
+	sink, err := output.Open(backend, opts)
+	assert.NoError(err)
+	assert.Equal(opts, gotOpts)
+	assert.NotNil(sink.Joystick)
+	assert.NoError(sink.Close())
+}
+
+func TestDryRun(t *testing.T) {
+	assert := assert.New(t)
+
+	var buf bytes.Buffer
+	sink := output.NewDryRun(&buf, true, true)
+	assert.NoError(sink.Keyboard.KeyDown(30))
+	assert.NoError(sink.Keyboard.KeyUp(30))
+	assert.NoError(sink.Keyboard.KeyPress(31))
+	assert.NoError(sink.Joystick.ButtonDown(304))
+	assert.NoError(sink.Joystick.StickPosition(0.5, -1))
+	assert.NoError(sink.Joystick.HatPosition(-1, 0))
+	assert.NoError(sink.Mouse.Wheel(false, -2))
+	assert.NoError(sink.Mouse.Wheel(true, 1))
+	assert.NoError(sink.Mouse.MoveTo(10, 20))
+	assert.NoError(sink.Close())
+
+	expected := `key down KeyA
+key up KeyA
+key press KeyS
+button down 304
+stick 0.500 -1.000
+hat -1 0
+wheel -2
+hwheel 1
+pointer 10 20
+`
+	assert.Equal(expected, buf.String())
+
+	sink = output.NewDryRun(&buf, false, false)
+	assert.Nil(sink.Joystick)
+	assert.Nil(sink.Mouse)
+}
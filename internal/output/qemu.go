@@ -0,0 +1,164 @@
+package output
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/achilleas-k/gg13/internal/joystick"
+	"github.com/achilleas-k/gg13/internal/keyboard"
+	"github.com/achilleas-k/gg13/internal/mouse"
+)
+
+func init() {
+	Register("qemu", openQEMU)
+}
+
+// syspather is implemented by the uinput devices, which can look up their
+// sysfs directory.
+type syspather interface {
+	Syspath() (string, error)
+}
+
+// LinkDir returns the directory where the qemu backend links the event
+// device nodes, under $XDG_RUNTIME_DIR if it is set.
+func LinkDir() string {
+	if runtimeDir := os.Getenv("XDG_RUNTIME_DIR"); runtimeDir != "" {
+		return filepath.Join(runtimeDir, "gg13", "input")
+	}
+	return filepath.Join(os.TempDir(), fmt.Sprintf("gg13-%d-input", os.Getuid()))
+}
+
+// EventNode returns the event device node, like /dev/input/event5, of the
+// input device with the sysfs directory syspath.
+func EventNode(syspath string) (string, error) {
+	entries, err := os.ReadDir(syspath)
+	if err != nil {
+		return "", fmt.Errorf("failed reading input device directory: %w", err)
+	}
+	for _, entry := range entries {
+		if strings.HasPrefix(entry.Name(), "event") {
+			return filepath.Join("/dev/input", entry.Name()), nil
+		}
+	}
+	return "", fmt.Errorf("input device %q has no event device", syspath)
+}
+
+// openQEMU creates the uinput devices like the uinput backend and links their
+// event device nodes, which change every time the devices are created, from
+// fixed paths in [LinkDir], named after the devices. The links can be passed
+// to QEMU's input-linux object for passing the devices through to a virtual
+// machine.
+func openQEMU(opts Options) (Sink, error) {
+	sink, err := openUinput(opts)
+	if err != nil {
+		return Sink{}, err
+	}
+
+	kbLink, err := linkEventNode(sink.Keyboard, opts.KeyboardName)
+	if err != nil {
+		_ = sink.Close()
+		return Sink{}, fmt.Errorf("qemu output: keyboard: %w", err)
+	}
+	sink.Keyboard = &linkedKeyboard{Keyboard: sink.Keyboard, link: kbLink}
+
+	if sink.Joystick != nil {
+		jsLink, err := linkEventNode(sink.Joystick, opts.JoystickName)
+		if err != nil {
+			_ = sink.Close()
+			return Sink{}, fmt.Errorf("qemu output: joystick: %w", err)
+		}
+		sink.Joystick = &linkedJoystick{Joystick: sink.Joystick, link: jsLink}
+	}
+
+	if sink.Mouse != nil {
+		msLink, err := linkEventNode(sink.Mouse, opts.MouseName)
+		if err != nil {
+			_ = sink.Close()
+			return Sink{}, fmt.Errorf("qemu output: mouse: %w", err)
+		}
+		sink.Mouse = &linkedMouse{Mouse: sink.Mouse, link: msLink}
+	}
+	return sink, nil
+}
+
+// linkEventNode links the event device node of the device from
+// <LinkDir>/<name>-event, replacing any existing link, and returns the path
+// of the link.
+func linkEventNode(dev any, name string) (string, error) {
+	sp, ok := dev.(syspather)
+	if !ok {
+		return "", fmt.Errorf("device has no sysfs path")
+	}
+	syspath, err := sp.Syspath()
+	if err != nil {
+		return "", err
+	}
+	node, err := EventNode(syspath)
+	if err != nil {
+		return "", err
+	}
+
+	link := filepath.Join(LinkDir(), name+"-event")
+	if err := replaceSymlink(node, link); err != nil {
+		return "", err
+	}
+	fmt.Printf("Linked %s event device %s from %s\n", name, node, link)
+	return link, nil
+}
+
+// replaceSymlink creates a symlink at link pointing to target, replacing any
+// existing file, and creates the directory of the link if needed.
+func replaceSymlink(target, link string) error {
+	if err := os.MkdirAll(filepath.Dir(link), 0o700); err != nil {
+		return fmt.Errorf("failed creating link directory: %w", err)
+	}
+	if err := os.Remove(link); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("failed removing old link: %w", err)
+	}
+	if err := os.Symlink(target, link); err != nil {
+		return fmt.Errorf("failed linking event device: %w", err)
+	}
+	return nil
+}
+
+// removeLink removes the link, ignoring links that are already gone.
+func removeLink(link string) error {
+	if err := os.Remove(link); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("failed removing link: %w", err)
+	}
+	return nil
+}
+
+// linkedKeyboard removes the link to its event device when it's closed.
+type linkedKeyboard struct {
+	keyboard.Keyboard
+	link string
+}
+
+func (kb *linkedKeyboard) Close() error {
+	return errors.Join(removeLink(kb.link), kb.Keyboard.Close())
+}
+
+// linkedJoystick removes the link to its event device when it's closed.
+type linkedJoystick struct {
+	joystick.Joystick
+	link string
+}
+
+func (js *linkedJoystick) Close() error {
+	return errors.Join(removeLink(js.link), js.Joystick.Close())
+}
+
+// linkedMouse removes the link to its event device when it's closed.
+type linkedMouse struct {
+	mouse.Mouse
+	link string
+}
+
+func (ms *linkedMouse) Close() error {
+	return errors.Join(removeLink(ms.link), ms.Mouse.Close())
+}
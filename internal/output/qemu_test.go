@@ -0,0 +1,67 @@
+package output
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEventNode(t *testing.T) {
+	assert := assert.New(t)
+
+	syspath := t.TempDir()
+	_, err := EventNode(syspath)
+	assert.EqualError(err, "input device \""+syspath+"\" has no event device")
+
+	for _, name := range []string{"capabilities", "event7", "name"} {
+		require.NoError(t, os.Mkdir(filepath.Join(syspath, name), 0o700))
+	}
+	node, err := EventNode(syspath)
+	assert.NoError(err)
+	assert.Equal("/dev/input/event7", node)
+
+	_, err = EventNode(filepath.Join(syspath, "missing"))
+	assert.ErrorContains(err, "failed reading input device directory")
+}
+
+// fakeSyspathKeyboard is a dry run keyboard with a sysfs directory.
+type fakeSyspathKeyboard struct {
+	dryRunKeyboard
+	syspath string
+}
+
+func (kb *fakeSyspathKeyboard) Syspath() (string, error) {
+	return kb.syspath, nil
+}
+
+func TestLinkEventNode(t *testing.T) {
+	assert := assert.New(t)
+	t.Setenv("XDG_RUNTIME_DIR", t.TempDir())
+
+	syspath := t.TempDir()
+	require.NoError(t, os.Mkdir(filepath.Join(syspath, "event3"), 0o700))
+	kb := &fakeSyspathKeyboard{syspath: syspath}
+
+	_, err := linkEventNode(&dryRunKeyboard{}, "g13-vkb")
+	assert.EqualError(err, "device has no sysfs path")
+
+	// replaces the link left by a previous run
+	expectedLink := filepath.Join(LinkDir(), "g13-vkb-event")
+	require.NoError(t, replaceSymlink("/dev/input/event1", expectedLink))
+
+	link, err := linkEventNode(kb, "g13-vkb")
+	require.NoError(t, err)
+	assert.Equal(expectedLink, link)
+	target, err := os.Readlink(link)
+	assert.NoError(err)
+	assert.Equal("/dev/input/event3", target)
+
+	linked := &linkedKeyboard{Keyboard: kb, link: link}
+	assert.NoError(linked.Close())
+	assert.NoFileExists(link)
+	// closing twice is fine
+	assert.NoError(linked.Close())
+}
@@ -0,0 +1,42 @@
+package output
+
+import (
+	"fmt"
+
+	"github.com/achilleas-k/gg13/internal/joystick"
+	"github.com/achilleas-k/gg13/internal/keyboard"
+	"github.com/achilleas-k/gg13/internal/mouse"
+)
+
+func init() {
+	Register("uinput", openUinput)
+}
+
+// openUinput creates a virtual keyboard, joystick and mouse with uinput, which the
+// rest of the system sees as real input devices.
+func openUinput(opts Options) (Sink, error) {
+	vkb, err := keyboard.New(opts.KeyboardName)
+	if err != nil {
+		return Sink{}, fmt.Errorf("virtual keyboard initialisation failed: %w", err)
+	}
+	sink := Sink{Keyboard: vkb}
+
+	if opts.Joystick != nil {
+		vjs, err := joystick.New(opts.JoystickName, *opts.Joystick)
+		if err != nil {
+			_ = sink.Close()
+			return Sink{}, fmt.Errorf("virtual joystick initialisation failed: %w", err)
+		}
+		sink.Joystick = vjs
+	}
+
+	if opts.Mouse != nil {
+		vms, err := mouse.New(opts.MouseName, *opts.Mouse)
+		if err != nil {
+			_ = sink.Close()
+			return Sink{}, fmt.Errorf("virtual mouse initialisation failed: %w", err)
+		}
+		sink.Mouse = vms
+	}
+	return sink, nil
+}
@@ -0,0 +1,80 @@
+// Package pipeline passes the input read from the G13 through a chain of
+// stages, like middleware, so features that change what the keys do can be
+// added as a stage instead of in the input loop.
+//
+// Each stage receives an [Event] and the rest of the pipeline, which it calls
+// to pass on the event. A stage can pass on the event as is, change it, hold
+// it back (for example to debounce keys), pass on several events (for
+// example for turbo keys), or only observe it.
+package pipeline
+
+import "time"
+
+// Event is the state of the G13 keys and stick at the time it was read.
+type Event struct {
+	// Input is the key and stick state, as returned by
+	// [device.Device.ReadInput].
+	Input uint64
+
+	// PrevInput is the Input of the event passed on before this one. A stage
+	// that changes Input must set it to the Input it last passed on, so later
+	// stages see consistent key presses and releases.
+	PrevInput uint64
+
+	// Time is when the input was read.
+	Time time.Time
+}
+
+// Next passes an event to the rest of the pipeline.
+type Next func(ev Event)
+
+// Stage is a step of a [Pipeline].
+type Stage interface {
+	// Handle handles the event and calls next zero or more times to pass
+	// events on to the following stages.
+	Handle(ev Event, next Next)
+}
+
+// StageFunc is a function implementing [Stage].
+type StageFunc func(ev Event, next Next)
+
+// Handle implements [Stage].
+func (f StageFunc) Handle(ev Event, next Next) {
+	f(ev, next)
+}
+
+// Observer returns a [Stage] calling f with each event and passing it on
+// unchanged.
+func Observer(f func(ev Event)) Stage {
+	return StageFunc(func(ev Event, next Next) {
+		f(ev)
+		next(ev)
+	})
+}
+
+// Pipeline is a chain of stages handling events in order. It's meant to be
+// used from the input loop only and isn't safe for concurrent use.
+type Pipeline struct {
+	// entry point of the chain: each stage bound to the stages after it
+	first Next
+}
+
+// New returns a [Pipeline] running the stages in order. Events passed on by
+// the last stage are dropped.
+func New(stages ...Stage) *Pipeline {
+	// bind the stages once, instead of for each event, so that handling an
+	// event doesn't allocate
+	next := Next(func(Event) {})
+	for idx := len(stages) - 1; idx >= 0; idx-- {
+		stage, rest := stages[idx], next
+		next = func(ev Event) {
+			stage.Handle(ev, rest)
+		}
+	}
+	return &Pipeline{first: next}
+}
+
+// Handle passes the event through the pipeline.
+func (p *Pipeline) Handle(ev Event) {
+	p.first(ev)
+}
@@ -0,0 +1,67 @@
+package pipeline_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/achilleas-k/gg13/internal/pipeline"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPipeline(t *testing.T) {
+	assert := assert.New(t)
+
+	var seen []string
+	record := func(name string) pipeline.Stage {
+		return pipeline.Observer(func(ev pipeline.Event) {
+			seen = append(seen, name)
+		})
+	}
+
+	var output []uint64
+	p := pipeline.New(
+		record("first"),
+		// drops events without input
+		pipeline.StageFunc(func(ev pipeline.Event, next pipeline.Next) {
+			if ev.Input != 0 {
+				next(ev)
+			}
+		}),
+		// passes on each event twice, the second time with an extra key
+		pipeline.StageFunc(func(ev pipeline.Event, next pipeline.Next) {
+			next(ev)
+			ev.PrevInput = ev.Input
+			ev.Input |= 0x100
+			next(ev)
+		}),
+		record("last"),
+		pipeline.Observer(func(ev pipeline.Event) {
+			output = append(output, ev.Input)
+		}),
+	)
+
+	now := time.Now()
+	p.Handle(pipeline.Event{Input: 0, Time: now})
+	p.Handle(pipeline.Event{Input: 0x1, Time: now})
+	assert.Equal([]string{"first", "first", "last", "last"}, seen)
+	assert.Equal([]uint64{0x1, 0x101}, output)
+}
+
+func TestPipelineEmpty(t *testing.T) {
+	p := pipeline.New()
+	p.Handle(pipeline.Event{Input: 0x1})
+}
+
+func TestPipelineAllocs(t *testing.T) {
+	var count int
+	p := pipeline.New(
+		pipeline.StageFunc(func(ev pipeline.Event, next pipeline.Next) { next(ev) }),
+		pipeline.Observer(func(pipeline.Event) { count++ }),
+	)
+	ev := pipeline.Event{Input: 0x1}
+	allocs := testing.AllocsPerRun(100, func() {
+		p.Handle(ev)
+	})
+	assert.Zero(t, allocs)
+	assert.Positive(t, count)
+}
@@ -0,0 +1,136 @@
+package sandbox
+
+import (
+	"errors"
+	"fmt"
+	"syscall"
+	"unsafe"
+)
+
+// Definitions from linux/landlock.h
+const (
+	sysLandlockCreateRuleset = 444
+	sysLandlockAddRule       = 445
+	sysLandlockRestrictSelf  = 446
+
+	// O_PATH from fcntl.h, missing from the syscall package
+	oPath = 0x200000
+
+	landlockCreateRulesetVersion = 1
+	landlockRulePathBeneath      = 1
+
+	accessExecute    = 1 << 0
+	accessWriteFile  = 1 << 1
+	accessReadFile   = 1 << 2
+	accessReadDir    = 1 << 3
+	accessRemoveDir  = 1 << 4
+	accessRemoveFile = 1 << 5
+	accessMakeChar   = 1 << 6
+	accessMakeDir    = 1 << 7
+	accessMakeReg    = 1 << 8
+	accessMakeSock   = 1 << 9
+	accessMakeFifo   = 1 << 10
+	accessMakeBlock  = 1 << 11
+	accessMakeSym    = 1 << 12
+	accessRefer      = 1 << 13 // ABI 2
+	accessTruncate   = 1 << 14 // ABI 3
+	accessIoctlDev   = 1 << 15 // ABI 5
+
+	// rights that apply to files, as opposed to directories
+	accessFile = accessExecute | accessWriteFile | accessReadFile | accessTruncate | accessIoctlDev
+
+	accessRead      = accessReadFile | accessReadDir
+	accessReadWrite = accessRead | accessWriteFile | accessTruncate | accessIoctlDev |
+		accessRemoveFile | accessMakeReg | accessMakeSock
+)
+
+type landlockRulesetAttr struct {
+	HandledAccessFS uint64
+}
+
+// the kernel struct is packed, which only removes the trailing padding
+type landlockPathBeneathAttr struct {
+	AllowedAccess uint64
+	ParentFd      int32
+}
+
+// handledAccess returns the file system rights known to the landlock ABI
+// version. Rights that aren't handled are always allowed.
+func handledAccess(abi int) uint64 {
+	handled := uint64(accessRefer - 1)
+	if abi >= 2 {
+		handled |= accessRefer
+	}
+	if abi >= 3 {
+		handled |= accessTruncate
+	}
+	if abi >= 5 {
+		handled |= accessIoctlDev
+	}
+	return handled
+}
+
+// applyLandlock restricts file system access for all the threads of the
+// process to the paths.
+func applyLandlock(paths Paths) error {
+	abi, _, errno := syscall.Syscall(sysLandlockCreateRuleset, 0, 0, landlockCreateRulesetVersion)
+	if errno != 0 {
+		if errno == syscall.ENOSYS || errno == syscall.EOPNOTSUPP {
+			return fmt.Errorf("landlock: %w by the kernel", ErrUnsupported)
+		}
+		return fmt.Errorf("failed checking landlock version: %w", errno)
+	}
+	handled := handledAccess(int(abi))
+
+	attr := landlockRulesetAttr{HandledAccessFS: handled}
+	fd, _, errno := syscall.Syscall(sysLandlockCreateRuleset, uintptr(unsafe.Pointer(&attr)), unsafe.Sizeof(attr), 0)
+	if errno != 0 {
+		return fmt.Errorf("failed creating landlock ruleset: %w", errno)
+	}
+	ruleset := int(fd)
+	defer syscall.Close(ruleset)
+
+	for _, path := range paths.ReadOnly {
+		if err := addPathRule(ruleset, path, accessRead&handled); err != nil {
+			return err
+		}
+	}
+	for _, path := range paths.ReadWrite {
+		if err := addPathRule(ruleset, path, accessReadWrite&handled); err != nil {
+			return err
+		}
+	}
+
+	if _, _, errno := syscall.AllThreadsSyscall(sysLandlockRestrictSelf, uintptr(ruleset), 0, 0); errno != 0 {
+		return fmt.Errorf("failed applying landlock ruleset: %w", errno)
+	}
+	return nil
+}
+
+// addPathRule allows access to everything under path. Missing paths are
+// skipped.
+func addPathRule(ruleset int, path string, access uint64) error {
+	fd, err := syscall.Open(path, oPath|syscall.O_CLOEXEC, 0)
+	if errors.Is(err, syscall.ENOENT) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed opening %s for landlock rule: %w", path, err)
+	}
+	defer syscall.Close(fd)
+
+	var st syscall.Stat_t
+	if err := syscall.Fstat(fd, &st); err != nil {
+		return fmt.Errorf("failed opening %s for landlock rule: %w", path, err)
+	}
+	if st.Mode&syscall.S_IFMT != syscall.S_IFDIR {
+		// directory rights on a file are rejected
+		access &= accessFile
+	}
+
+	attr := landlockPathBeneathAttr{AllowedAccess: access, ParentFd: int32(fd)}
+	if _, _, errno := syscall.Syscall6(sysLandlockAddRule, uintptr(ruleset), landlockRulePathBeneath, uintptr(unsafe.Pointer(&attr)), 0, 0, 0); errno != 0 {
+		return fmt.Errorf("failed adding landlock rule for %s: %w", path, errno)
+	}
+	return nil
+}
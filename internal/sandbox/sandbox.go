@@ -0,0 +1,80 @@
+// Package sandbox restricts what the running daemon can do, for users who run
+// it as a service and want to limit the damage if it is ever compromised.
+//
+// Two independent mechanisms are applied to every thread of the process:
+//   - a seccomp filter that denies system calls the daemon never needs, like
+//     executing programs, tracing other processes, or loading kernel modules
+//   - landlock rules that limit file system access to the paths the daemon
+//     uses: the USB and uinput devices, the config, and its runtime files
+//
+// Neither can be undone once applied. Landlock has to restrict every thread at
+// once, which Go can only do without cgo: a binary built with cgo, like the
+// default libusb build, only gets the seccomp filter.
+package sandbox
+
+import (
+	"errors"
+	"fmt"
+	"runtime"
+	"syscall"
+)
+
+// ErrUnsupported is returned when the kernel or the architecture doesn't
+// support a sandboxing mechanism.
+var ErrUnsupported = errors.New("not supported")
+
+// ErrCgo is returned, wrapped with [ErrUnsupported], when landlock can't be
+// applied because the binary was built with cgo.
+var ErrCgo = errors.New("binaries built with cgo can't restrict every thread")
+
+const prSetNoNewPrivs = 38
+
+// Paths are the file system paths the process keeps access to. Files and
+// directories that don't exist are skipped.
+type Paths struct {
+	// ReadOnly paths can be read, including everything under directories
+	ReadOnly []string
+
+	// ReadWrite paths can also be written, and files can be created and
+	// removed under directories
+	ReadWrite []string
+}
+
+// Apply sandboxes the process. The seccomp filter is always applied and an
+// error from it means nothing changed. Landlock needs a kernel with landlock
+// enabled and a binary built without cgo. If it can't be applied, the error
+// wraps [ErrUnsupported] but the seccomp filter is in place.
+func Apply(paths Paths) error {
+	// the process-wide syscalls below act on the calling thread
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+
+	// needed by both mechanisms for an unprivileged process, and inherited
+	// by the other threads with the seccomp filter
+	allThreads := true
+	if _, _, errno := syscall.AllThreadsSyscall(syscall.SYS_PRCTL, prSetNoNewPrivs, 1, 0); errno != 0 {
+		if errno != syscall.ENOTSUP {
+			return fmt.Errorf("failed setting no_new_privs: %w", errno)
+		}
+		// with cgo, only the calling thread can be changed directly
+		allThreads = false
+		if _, _, errno := syscall.RawSyscall(syscall.SYS_PRCTL, prSetNoNewPrivs, 1, 0); errno != 0 {
+			return fmt.Errorf("failed setting no_new_privs: %w", errno)
+		}
+	}
+
+	var landlockErr error
+	if allThreads {
+		landlockErr = applyLandlock(paths)
+	} else {
+		landlockErr = fmt.Errorf("landlock: %w: %w", ErrUnsupported, ErrCgo)
+	}
+	if landlockErr != nil && !errors.Is(landlockErr, ErrUnsupported) {
+		return landlockErr
+	}
+
+	if err := applySeccomp(); err != nil {
+		return err
+	}
+	return landlockErr
+}
@@ -0,0 +1,141 @@
+package sandbox
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"syscall"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// runFilter evaluates the subset of classic BPF used by the seccomp filter.
+func runFilter(t *testing.T, prog []sockFilter, arch, nr uint32) uint32 {
+	var acc uint32
+	for pc := 0; pc < len(prog); pc++ {
+		ins := prog[pc]
+		switch ins.Code {
+		case bpfLd | bpfW | bpfAbs:
+			switch ins.K {
+			case seccompDataNr:
+				acc = nr
+			case seccompDataArch:
+				acc = arch
+			default:
+				t.Fatalf("unexpected load offset %d", ins.K)
+			}
+		case bpfJmp | bpfJeq | bpfK:
+			if acc == ins.K {
+				pc += int(ins.Jt)
+			} else {
+				pc += int(ins.Jf)
+			}
+		case bpfJmp | bpfJge | bpfK:
+			if acc >= ins.K {
+				pc += int(ins.Jt)
+			} else {
+				pc += int(ins.Jf)
+			}
+		case bpfRet | bpfK:
+			return ins.K
+		default:
+			t.Fatalf("unexpected instruction %#x", ins.Code)
+		}
+	}
+	t.Fatal("filter ended without returning")
+	return 0
+}
+
+func TestSeccompFilter(t *testing.T) {
+	const arch = 0xc000003e
+	deny := seccompRetErrno | uint32(syscall.EPERM)
+
+	testCases := map[string]struct {
+		limit  uint32
+		arch   uint32
+		nr     uint32
+		expRet uint32
+	}{
+		"allowed":       {arch: arch, nr: 0, expRet: seccompRetAllow},
+		"denied-first":  {arch: arch, nr: 59, expRet: deny},
+		"denied-last":   {arch: arch, nr: 250, expRet: deny},
+		"other-arch":    {arch: 0x40000003, nr: 0, expRet: seccompRetKillProcess},
+		"over-limit":    {limit: 0x40000000, arch: arch, nr: 0x40000000 + 1, expRet: deny},
+		"under-limit":   {limit: 0x40000000, arch: arch, nr: 1, expRet: seccompRetAllow},
+		"limit-denied":  {limit: 0x40000000, arch: arch, nr: 101, expRet: deny},
+		"limit-allowed": {limit: 0x40000000, arch: arch, nr: 102, expRet: seccompRetAllow},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			prog := seccompFilter(arch, tc.limit, []uint32{59, 101, 250})
+			assert.Equal(t, tc.expRet, runFilter(t, prog, tc.arch, tc.nr))
+		})
+	}
+}
+
+func TestHandledAccess(t *testing.T) {
+	assert.Equal(t, uint64(accessRefer-1), handledAccess(1))
+	assert.Equal(t, uint64(accessTruncate-1), handledAccess(2))
+	assert.Equal(t, uint64(accessIoctlDev-1), handledAccess(4))
+	assert.Equal(t, uint64(accessIoctlDev<<1-1), handledAccess(6))
+}
+
+const sandboxChildEnv = "GG13_SANDBOX_TEST_CHILD"
+
+// TestApply runs the test binary again to sandbox the child process and
+// checks what it can still do.
+func TestApply(t *testing.T) {
+	if sysSeccomp == 0 {
+		t.Skip("seccomp not supported on this architecture")
+	}
+	if dir := os.Getenv(sandboxChildEnv); dir != "" {
+		sandboxChild(dir)
+		return
+	}
+
+	dir := t.TempDir()
+	allowed := filepath.Join(dir, "allowed")
+	require.NoError(t, os.Mkdir(allowed, 0o755))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "denied"), []byte("x"), 0o644))
+
+	cmd := exec.Command(os.Args[0], "-test.run=^TestApply$")
+	cmd.Env = append(os.Environ(), sandboxChildEnv+"="+dir)
+	out, err := cmd.CombinedOutput()
+	if exitErr := (*exec.ExitError)(nil); errors.As(err, &exitErr) && exitErr.ExitCode() == 3 {
+		t.Skipf("sandboxing unavailable: %s", out)
+	}
+	require.NoError(t, err, string(out))
+}
+
+// sandboxChild exits with 3 if the sandbox can't be applied, 1 if it doesn't
+// behave as expected, and 0 otherwise.
+func sandboxChild(dir string) {
+	fail := func(code int, args ...any) {
+		fmt.Fprintln(os.Stderr, args...)
+		os.Exit(code)
+	}
+
+	err := Apply(Paths{ReadWrite: []string{filepath.Join(dir, "allowed")}})
+	landlock := true
+	if errors.Is(err, ErrUnsupported) {
+		landlock = false
+	} else if err != nil {
+		fail(3, "apply:", err)
+	}
+
+	if err := syscall.Exec("/bin/true", []string{"true"}, nil); !errors.Is(err, syscall.EPERM) {
+		fail(1, "exec not denied:", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "allowed", "file"), []byte("x"), 0o644); err != nil {
+		fail(1, "write under allowed path failed:", err)
+	}
+	if _, err := os.ReadFile(filepath.Join(dir, "denied")); landlock && err == nil {
+		fail(1, "read outside allowed paths not denied")
+	}
+	os.Exit(0)
+}
@@ -0,0 +1,97 @@
+package sandbox
+
+import (
+	"fmt"
+	"syscall"
+	"unsafe"
+)
+
+// Definitions from linux/seccomp.h, linux/filter.h, and linux/bpf_common.h
+const (
+	seccompSetModeFilter   = 1
+	seccompFilterFlagTsync = 1
+
+	seccompRetKillProcess = 0x80000000
+	seccompRetErrno       = 0x00050000
+	seccompRetAllow       = 0x7fff0000
+
+	bpfLd  = 0x00
+	bpfJmp = 0x05
+	bpfRet = 0x06
+	bpfW   = 0x00
+	bpfAbs = 0x20
+	bpfJeq = 0x10
+	bpfJge = 0x30
+	bpfK   = 0x00
+
+	// offsets in struct seccomp_data
+	seccompDataNr   = 0
+	seccompDataArch = 4
+)
+
+type sockFilter struct {
+	Code uint16
+	Jt   uint8
+	Jf   uint8
+	K    uint32
+}
+
+type sockFprog struct {
+	Len    uint16
+	Filter *sockFilter
+}
+
+func bpfStmt(code uint16, k uint32) sockFilter {
+	return sockFilter{Code: code, K: k}
+}
+
+func bpfJump(code uint16, k uint32, jt, jf uint8) sockFilter {
+	return sockFilter{Code: code, Jt: jt, Jf: jf, K: k}
+}
+
+// seccompFilter returns the filter program for the architecture. Calls from
+// other architectures kill the process and the denied syscalls fail with
+// EPERM. Everything else is allowed.
+func seccompFilter(arch uint32, syscallLimit uint32, denied []uint32) []sockFilter {
+	prog := []sockFilter{
+		bpfStmt(bpfLd|bpfW|bpfAbs, seccompDataArch),
+		bpfJump(bpfJmp|bpfJeq|bpfK, arch, 1, 0),
+		bpfStmt(bpfRet|bpfK, seccompRetKillProcess),
+		bpfStmt(bpfLd|bpfW|bpfAbs, seccompDataNr),
+	}
+
+	// every check jumps to the deny return after the allow return at the end
+	checks := len(denied)
+	if syscallLimit > 0 {
+		checks++
+		prog = append(prog, bpfJump(bpfJmp|bpfJge|bpfK, syscallLimit, uint8(checks), 0))
+	}
+	for _, nr := range denied {
+		prog = append(prog, bpfJump(bpfJmp|bpfJeq|bpfK, nr, uint8(checks-len(prog)+4), 0))
+	}
+	return append(prog,
+		bpfStmt(bpfRet|bpfK, seccompRetAllow),
+		bpfStmt(bpfRet|bpfK, seccompRetErrno|uint32(syscall.EPERM)),
+	)
+}
+
+// applySeccomp installs the filter on all the threads of the process.
+func applySeccomp() error {
+	if sysSeccomp == 0 {
+		return fmt.Errorf("seccomp: %w on this architecture", ErrUnsupported)
+	}
+	filter := seccompFilter(auditArch, syscallLimit, deniedSyscalls)
+	prog := sockFprog{
+		Len:    uint16(len(filter)),
+		Filter: &filter[0],
+	}
+	tid, _, errno := syscall.RawSyscall(sysSeccomp, seccompSetModeFilter, seccompFilterFlagTsync, uintptr(unsafe.Pointer(&prog)))
+	if errno != 0 {
+		return fmt.Errorf("failed installing seccomp filter: %w", errno)
+	}
+	if tid != 0 {
+		// the filter can't be applied to all threads and wasn't installed
+		return fmt.Errorf("failed installing seccomp filter: thread %d can't be synchronised", tid)
+	}
+	return nil
+}
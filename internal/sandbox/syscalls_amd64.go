@@ -0,0 +1,48 @@
+package sandbox
+
+import "syscall"
+
+const (
+	sysSeccomp = 317
+
+	// AUDIT_ARCH_X86_64
+	auditArch = 0xc000003e
+
+	// x32 syscalls have this bit set and are denied entirely
+	syscallLimit = 0x40000000
+)
+
+// deniedSyscalls are the syscalls the daemon never makes
+var deniedSyscalls = []uint32{
+	syscall.SYS_EXECVE,
+	322, // execveat
+	syscall.SYS_PTRACE,
+	310, // process_vm_readv
+	311, // process_vm_writev
+	syscall.SYS_MOUNT,
+	syscall.SYS_UMOUNT2,
+	syscall.SYS_PIVOT_ROOT,
+	syscall.SYS_CHROOT,
+	428, // open_tree
+	429, // move_mount
+	430, // fsopen
+	431, // fsconfig
+	432, // fsmount
+	syscall.SYS_UNSHARE,
+	308, // setns
+	syscall.SYS_INIT_MODULE,
+	313, // finit_module
+	syscall.SYS_DELETE_MODULE,
+	syscall.SYS_KEXEC_LOAD,
+	320, // kexec_file_load
+	syscall.SYS_REBOOT,
+	syscall.SYS_SWAPON,
+	syscall.SYS_SWAPOFF,
+	321, // bpf
+	syscall.SYS_PERF_EVENT_OPEN,
+	323, // userfaultfd
+	304, // open_by_handle_at
+	syscall.SYS_ADD_KEY,
+	syscall.SYS_REQUEST_KEY,
+	syscall.SYS_KEYCTL,
+}
@@ -0,0 +1,47 @@
+package sandbox
+
+import "syscall"
+
+const (
+	sysSeccomp = syscall.SYS_SECCOMP
+
+	// AUDIT_ARCH_AARCH64
+	auditArch = 0xc00000b7
+
+	syscallLimit = 0
+)
+
+// deniedSyscalls are the syscalls the daemon never makes
+var deniedSyscalls = []uint32{
+	syscall.SYS_EXECVE,
+	syscall.SYS_EXECVEAT,
+	syscall.SYS_PTRACE,
+	syscall.SYS_PROCESS_VM_READV,
+	syscall.SYS_PROCESS_VM_WRITEV,
+	syscall.SYS_MOUNT,
+	syscall.SYS_UMOUNT2,
+	syscall.SYS_PIVOT_ROOT,
+	syscall.SYS_CHROOT,
+	428, // open_tree
+	429, // move_mount
+	430, // fsopen
+	431, // fsconfig
+	432, // fsmount
+	syscall.SYS_UNSHARE,
+	syscall.SYS_SETNS,
+	syscall.SYS_INIT_MODULE,
+	syscall.SYS_FINIT_MODULE,
+	syscall.SYS_DELETE_MODULE,
+	syscall.SYS_KEXEC_LOAD,
+	294, // kexec_file_load
+	syscall.SYS_REBOOT,
+	syscall.SYS_SWAPON,
+	syscall.SYS_SWAPOFF,
+	syscall.SYS_BPF,
+	syscall.SYS_PERF_EVENT_OPEN,
+	282, // userfaultfd
+	syscall.SYS_OPEN_BY_HANDLE_AT,
+	syscall.SYS_ADD_KEY,
+	syscall.SYS_REQUEST_KEY,
+	syscall.SYS_KEYCTL,
+}
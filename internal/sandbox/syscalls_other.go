@@ -0,0 +1,12 @@
+//go:build !amd64 && !arm64
+
+package sandbox
+
+// seccomp isn't supported on other architectures
+const (
+	sysSeccomp   = 0
+	auditArch    = 0
+	syscallLimit = 0
+)
+
+var deniedSyscalls []uint32
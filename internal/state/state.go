@@ -0,0 +1,117 @@
+// Package state persists the output last applied to the device, so it can be
+// restored after a crash or unclean shutdown.
+//
+// The state file is rewritten shortly after the output changes and removed on
+// a clean shutdown, so finding one at startup means the previous instance
+// didn't shut down cleanly.
+package state
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"image"
+	"image/png"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+// State is the output applied to the device.
+type State struct {
+	// Backlight is nil until a colour is set
+	Backlight *[3]uint8 `json:"backlight,omitempty"`
+
+	// LCD is the PNG encoded LCD image, empty until an image is set
+	LCD []byte `json:"lcd,omitempty"`
+}
+
+// DefaultPath returns the default location of the state file, under
+// $XDG_RUNTIME_DIR if it is set.
+func DefaultPath() string {
+	if runtimeDir := os.Getenv("XDG_RUNTIME_DIR"); runtimeDir != "" {
+		return filepath.Join(runtimeDir, "gg13-state.json")
+	}
+	return filepath.Join(os.TempDir(), fmt.Sprintf("gg13-%d-state.json", os.Getuid()))
+}
+
+// SetLCD stores the LCD image.
+func (s *State) SetLCD(img image.Image) error {
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		return fmt.Errorf("failed encoding LCD image: %w", err)
+	}
+	s.LCD = buf.Bytes()
+	return nil
+}
+
+// LCDImage returns the stored LCD image, or nil if there is none.
+func (s *State) LCDImage() (image.Image, error) {
+	if len(s.LCD) == 0 {
+		return nil, nil
+	}
+	img, err := png.Decode(bytes.NewReader(s.LCD))
+	if err != nil {
+		return nil, fmt.Errorf("failed decoding LCD image: %w", err)
+	}
+	return img, nil
+}
+
+// Load reads the state file at path. It returns nil without an error if the
+// file doesn't exist.
+func Load(path string) (*State, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed reading state file %q: %w", path, err)
+	}
+
+	var s State
+	if err := json.Unmarshal(data, &s); err != nil {
+		return nil, fmt.Errorf("failed decoding state file %q: %w", path, err)
+	}
+	return &s, nil
+}
+
+// Save writes the state to the file at path. The file is replaced in one
+// step, so a crash while saving leaves the previous state in place.
+func (s *State) Save(path string) error {
+	data, err := json.Marshal(s)
+	if err != nil {
+		return fmt.Errorf("failed encoding state: %w", err)
+	}
+
+	tmpFile, err := os.CreateTemp(filepath.Dir(path), ".gg13-state-*.json")
+	if err != nil {
+		return fmt.Errorf("failed creating temporary state file: %w", err)
+	}
+	tmpPath := tmpFile.Name()
+	defer func() {
+		// no-op if the file was moved into place
+		_ = os.Remove(tmpPath)
+	}()
+
+	if _, err := tmpFile.Write(data); err != nil {
+		_ = tmpFile.Close()
+		return fmt.Errorf("failed writing state file: %w", err)
+	}
+	if err := tmpFile.Close(); err != nil {
+		return fmt.Errorf("failed writing state file: %w", err)
+	}
+	if err := os.Rename(tmpPath, path); err != nil {
+		return fmt.Errorf("failed writing state file %q: %w", path, err)
+	}
+	return nil
+}
+
+// Remove deletes the state file at path. It's not an error if it doesn't
+// exist.
+func Remove(path string) error {
+	if err := os.Remove(path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("failed removing state file %q: %w", path, err)
+	}
+	return nil
+}
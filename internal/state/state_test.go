@@ -0,0 +1,75 @@
+package state_test
+
+import (
+	"image"
+	"image/color"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/achilleas-k/gg13/internal/state"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSaveLoad(t *testing.T) {
+	assert := assert.New(t)
+	path := filepath.Join(t.TempDir(), "state.json")
+
+	loaded, err := state.Load(path)
+	assert.NoError(err)
+	assert.Nil(loaded)
+
+	img := image.NewGray(image.Rect(0, 0, 160, 43))
+	img.SetGray(3, 4, color.Gray{Y: 255})
+
+	s := &state.State{Backlight: &[3]uint8{1, 2, 3}}
+	require.NoError(t, s.SetLCD(img))
+	require.NoError(t, s.Save(path))
+
+	loaded, err = state.Load(path)
+	require.NoError(t, err)
+	assert.Equal(&[3]uint8{1, 2, 3}, loaded.Backlight)
+	loadedImg, err := loaded.LCDImage()
+	require.NoError(t, err)
+	assert.Equal(img.Bounds(), loadedImg.Bounds())
+	assert.Equal(color.Gray{Y: 255}, color.GrayModel.Convert(loadedImg.At(3, 4)))
+	assert.Equal(color.Gray{Y: 0}, color.GrayModel.Convert(loadedImg.At(4, 3)))
+
+	// no temporary files are left behind
+	entries, err := os.ReadDir(filepath.Dir(path))
+	require.NoError(t, err)
+	assert.Len(entries, 1)
+
+	assert.NoError(state.Remove(path))
+	assert.NoFileExists(path)
+	assert.NoError(state.Remove(path))
+}
+
+func TestEmptyState(t *testing.T) {
+	assert := assert.New(t)
+	path := filepath.Join(t.TempDir(), "state.json")
+
+	require.NoError(t, (&state.State{}).Save(path))
+	loaded, err := state.Load(path)
+	require.NoError(t, err)
+	assert.Nil(loaded.Backlight)
+	img, err := loaded.LCDImage()
+	assert.NoError(err)
+	assert.Nil(img)
+}
+
+func TestLoadErrors(t *testing.T) {
+	assert := assert.New(t)
+	path := filepath.Join(t.TempDir(), "state.json")
+
+	require.NoError(t, os.WriteFile(path, []byte("{"), 0o600))
+	_, err := state.Load(path)
+	assert.ErrorContains(err, "failed decoding state file")
+
+	require.NoError(t, os.WriteFile(path, []byte(`{"lcd":"bm90IGEgcG5n"}`), 0o600))
+	loaded, err := state.Load(path)
+	require.NoError(t, err)
+	_, err = loaded.LCDImage()
+	assert.ErrorContains(err, "failed decoding LCD image")
+}
@@ -0,0 +1,86 @@
+package stats
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+
+	"github.com/achilleas-k/gg13/internal/device"
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/basicfont"
+	"golang.org/x/image/math/fixed"
+)
+
+const (
+	// size of the square drawn for each key, in pixels
+	heatmapCell = 48
+
+	// space around each key
+	heatmapGap = 4
+)
+
+// heatmapLayout arranges the keys roughly the way they are on the device: the
+// LCD keys, the M keys, the G keys, and the stick keys. Each row starts at the
+// given offset, in half keys.
+var heatmapLayout = []struct {
+	offset int
+	keys   []device.KeyBit
+}{
+	{3, []device.KeyBit{device.L1, device.L2, device.L3, device.L4}},
+	{3, []device.KeyBit{device.M1, device.M2, device.M3, device.MR}},
+	{0, []device.KeyBit{device.G1, device.G2, device.G3, device.G4, device.G5, device.G6, device.G7}},
+	{0, []device.KeyBit{device.G8, device.G9, device.G10, device.G11, device.G12, device.G13, device.G14}},
+	{2, []device.KeyBit{device.G15, device.G16, device.G17, device.G18, device.G19}},
+	{4, []device.KeyBit{device.G20, device.G21, device.G22}},
+	{8, []device.KeyBit{device.LEFT, device.TOP, device.DOWN}},
+}
+
+// Heatmap draws the keys in the layout of the device, coloured from white for
+// keys that were never pressed to red for the most pressed key, and labelled
+// with their press counts.
+func (s *Stats) Heatmap() image.Image {
+	var maxPresses uint64
+	for _, ks := range s.Keys {
+		maxPresses = max(maxPresses, ks.Presses)
+	}
+
+	columns := 0
+	for _, row := range heatmapLayout {
+		columns = max(columns, (row.offset+1)/2+len(row.keys))
+	}
+	pitch := heatmapCell + heatmapGap
+	img := image.NewRGBA(image.Rect(0, 0, columns*pitch+heatmapGap, len(heatmapLayout)*pitch+heatmapGap))
+	draw.Draw(img, img.Bounds(), image.NewUniform(color.RGBA{64, 64, 64, 255}), image.Point{}, draw.Src)
+
+	drawer := font.Drawer{
+		Dst:  img,
+		Src:  image.Black,
+		Face: basicfont.Face7x13,
+	}
+	for rowIdx, row := range heatmapLayout {
+		for colIdx, key := range row.keys {
+			ks := s.Keys[key.String()]
+			x := heatmapGap + row.offset*pitch/2 + colIdx*pitch
+			y := heatmapGap + rowIdx*pitch
+			cell := image.Rect(x, y, x+heatmapCell, y+heatmapCell)
+			draw.Draw(img, cell, image.NewUniform(heat(ks.Presses, maxPresses)), image.Point{}, draw.Src)
+
+			drawer.Dot = fixed.P(x+3, y+14)
+			drawer.DrawString(key.String())
+			drawer.Dot = fixed.P(x+3, y+heatmapCell-6)
+			drawer.DrawString(fmt.Sprint(ks.Presses))
+		}
+	}
+	return img
+}
+
+// heat returns the colour for a key pressed n times out of a maximum of
+// maxN: white, through orange, to red.
+func heat(n, maxN uint64) color.RGBA {
+	if maxN == 0 {
+		return color.RGBA{255, 255, 255, 255}
+	}
+	cold := 1 - float64(n)/float64(maxN)
+	return color.RGBA{255, uint8(255 * cold), uint8(255 * cold * cold), 255}
+}
@@ -0,0 +1,171 @@
+// Package stats records how often and how long each G13 key is pressed, to
+// help with choosing bindings. The statistics accumulate across runs in a
+// file.
+package stats
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"maps"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/achilleas-k/gg13/internal/device"
+)
+
+// KeyStats is the usage of a single key.
+type KeyStats struct {
+	Presses uint64        `json:"presses"`
+	Held    time.Duration `json:"held"`
+}
+
+// MeanHeld returns the average time the key was held for each press.
+func (ks KeyStats) MeanHeld() time.Duration {
+	if ks.Presses == 0 {
+		return 0
+	}
+	return ks.Held / time.Duration(ks.Presses)
+}
+
+// Stats is the usage of the G13 keys, by key name.
+type Stats struct {
+	Keys map[string]KeyStats `json:"keys"`
+}
+
+// DefaultPath returns the default location of the statistics file, under
+// $XDG_STATE_HOME, or ~/.local/state if it isn't set, since the statistics
+// should survive reboots.
+func DefaultPath() string {
+	stateDir := os.Getenv("XDG_STATE_HOME")
+	if stateDir == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return filepath.Join(os.TempDir(), fmt.Sprintf("gg13-%d-stats.json", os.Getuid()))
+		}
+		stateDir = filepath.Join(home, ".local", "state")
+	}
+	return filepath.Join(stateDir, "gg13", "stats.json")
+}
+
+// Load reads the statistics file at path. It returns empty statistics if the
+// file doesn't exist.
+func Load(path string) (*Stats, error) {
+	s := &Stats{Keys: make(map[string]KeyStats)}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed reading statistics file %q: %w", path, err)
+	}
+
+	if err := json.Unmarshal(data, s); err != nil {
+		return nil, fmt.Errorf("failed decoding statistics file %q: %w", path, err)
+	}
+	if s.Keys == nil {
+		s.Keys = make(map[string]KeyStats)
+	}
+	return s, nil
+}
+
+// Save writes the statistics to the file at path, creating its directory if
+// needed. The file is replaced in one step, so a crash while saving leaves
+// the previous statistics in place.
+func (s *Stats) Save(path string) error {
+	data, err := json.Marshal(s)
+	if err != nil {
+		return fmt.Errorf("failed encoding statistics: %w", err)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return fmt.Errorf("failed creating statistics directory: %w", err)
+	}
+	tmpFile, err := os.CreateTemp(filepath.Dir(path), ".gg13-stats-*.json")
+	if err != nil {
+		return fmt.Errorf("failed creating temporary statistics file: %w", err)
+	}
+	tmpPath := tmpFile.Name()
+	defer func() {
+		// no-op if the file was moved into place
+		_ = os.Remove(tmpPath)
+	}()
+
+	if _, err := tmpFile.Write(data); err != nil {
+		_ = tmpFile.Close()
+		return fmt.Errorf("failed writing statistics file: %w", err)
+	}
+	if err := tmpFile.Close(); err != nil {
+		return fmt.Errorf("failed writing statistics file: %w", err)
+	}
+	if err := os.Rename(tmpPath, path); err != nil {
+		return fmt.Errorf("failed writing statistics file %q: %w", path, err)
+	}
+	return nil
+}
+
+// Recorder adds the key presses read from the device to [Stats]. It is safe
+// for concurrent use, so the statistics can be saved while recording.
+type Recorder struct {
+	mu    sync.Mutex
+	stats *Stats
+
+	// when each key that is down was pressed
+	pressed map[device.KeyBit]time.Time
+}
+
+// NewRecorder returns a [Recorder] adding to the statistics.
+func NewRecorder(s *Stats) *Recorder {
+	return &Recorder{
+		stats:   s,
+		pressed: make(map[device.KeyBit]time.Time),
+	}
+}
+
+// Record counts a press, and the time it was held for, for each key released
+// since the previous input. at is the time the input was read.
+func (r *Recorder) Record(input, prevInput uint64, at time.Time) {
+	changed := input ^ prevInput
+	if changed == 0 {
+		return
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, key := range device.AllKeys() {
+		if key.Uint64()&changed == 0 {
+			continue
+		}
+		if key.Uint64()&input != 0 {
+			r.pressed[key] = at
+			continue
+		}
+
+		ks := r.stats.Keys[key.String()]
+		ks.Presses++
+		if pressedAt, ok := r.pressed[key]; ok {
+			ks.Held += at.Sub(pressedAt)
+			delete(r.pressed, key)
+		}
+		r.stats.Keys[key.String()] = ks
+	}
+}
+
+// Reset forgets the keys that are down, for example when the device is
+// reinitialised and their release won't be read.
+func (r *Recorder) Reset() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	clear(r.pressed)
+}
+
+// Save writes the statistics recorded so far to the file at path.
+func (r *Recorder) Save(path string) error {
+	r.mu.Lock()
+	s := &Stats{Keys: maps.Clone(r.stats.Keys)}
+	r.mu.Unlock()
+	return s.Save(path)
+}
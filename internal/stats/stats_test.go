@@ -0,0 +1,85 @@
+package stats_test
+
+import (
+	"image/color"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/achilleas-k/gg13/internal/device"
+	"github.com/achilleas-k/gg13/internal/stats"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRecorder(t *testing.T) {
+	assert := assert.New(t)
+
+	s := &stats.Stats{Keys: map[string]stats.KeyStats{"G1": {Presses: 2, Held: time.Second}}}
+	r := stats.NewRecorder(s)
+
+	start := time.Now()
+	at := func(ms int) time.Time { return start.Add(time.Duration(ms) * time.Millisecond) }
+
+	g1 := device.G1.Uint64()
+	g2 := device.G2.Uint64()
+	r.Record(g1, 0, at(0))
+	r.Record(g1|g2, g1, at(100))
+	r.Record(g1|g2, g1|g2, at(150)) // no change
+	r.Record(g2, g1|g2, at(200))
+	r.Record(0, g2, at(400))
+
+	assert.Equal(map[string]stats.KeyStats{
+		"G1": {Presses: 3, Held: time.Second + 200*time.Millisecond},
+		"G2": {Presses: 1, Held: 300 * time.Millisecond},
+	}, s.Keys)
+	assert.Equal(400*time.Millisecond, s.Keys["G1"].MeanHeld())
+
+	// a release after a reset counts the press without a duration
+	r.Record(g1, 0, at(500))
+	r.Reset()
+	r.Record(0, g1, at(600))
+	assert.Equal(stats.KeyStats{Presses: 4, Held: time.Second + 200*time.Millisecond}, s.Keys["G1"])
+}
+
+func TestLoadSave(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	path := filepath.Join(t.TempDir(), "gg13", "stats.json")
+
+	// a missing file is empty statistics
+	s, err := stats.Load(path)
+	require.NoError(err)
+	assert.Empty(s.Keys)
+
+	r := stats.NewRecorder(s)
+	r.Record(device.M1.Uint64(), 0, time.Now())
+	r.Record(0, device.M1.Uint64(), time.Now())
+	require.NoError(r.Save(path))
+
+	loaded, err := stats.Load(path)
+	require.NoError(err)
+	assert.Equal(s, loaded)
+
+	require.NoError(os.WriteFile(path, []byte("{"), 0o600))
+	_, err = stats.Load(path)
+	assert.ErrorContains(err, "failed decoding statistics file")
+}
+
+func TestHeatmap(t *testing.T) {
+	s := &stats.Stats{Keys: map[string]stats.KeyStats{
+		"G1": {Presses: 10},
+		"G2": {Presses: 5},
+	}}
+	img := s.Heatmap()
+
+	// the inside of the G1 and G2 cells, below the labels
+	g1 := img.At(30, 2*52+30)
+	g2 := img.At(52+30, 2*52+30)
+	g3 := img.At(2*52+30, 2*52+30)
+	assert.Equal(t, color.RGBA{255, 0, 0, 255}, g1)
+	assert.Equal(t, color.RGBA{255, 127, 63, 255}, g2)
+	assert.Equal(t, color.RGBA{255, 255, 255, 255}, g3)
+}
@@ -0,0 +1,116 @@
+// Package update finds the latest release of the driver and compares it with
+// the running version.
+package update
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// DefaultURL is the GitHub API endpoint describing the latest release of the
+// driver.
+const DefaultURL = "https://api.github.com/repos/achilleas-k/gg13/releases/latest"
+
+// maxResponseSize limits the size of the release description that's read.
+const maxResponseSize = 1 << 20
+
+// Release is a published release of the driver.
+type Release struct {
+	Tag string `json:"tag_name"`
+	URL string `json:"html_url"`
+}
+
+// Latest returns the latest release from the GitHub API endpoint at url.
+func Latest(ctx context.Context, client *http.Client, url string) (Release, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return Release{}, err
+	}
+	req.Header.Set("Accept", "application/vnd.github+json")
+	resp, err := client.Do(req)
+	if err != nil {
+		return Release{}, err
+	}
+	defer func() { _ = resp.Body.Close() }()
+	if resp.StatusCode != http.StatusOK {
+		return Release{}, fmt.Errorf("unexpected response: %s", resp.Status)
+	}
+
+	var release Release
+	if err := json.NewDecoder(io.LimitReader(resp.Body, maxResponseSize)).Decode(&release); err != nil {
+		return Release{}, fmt.Errorf("failed decoding release: %w", err)
+	}
+	if release.Tag == "" {
+		return Release{}, fmt.Errorf("release has no tag")
+	}
+	return release, nil
+}
+
+// version is a parsed vMAJOR.MINOR.PATCH[-PRERELEASE] version.
+type version struct {
+	parts      [3]int
+	prerelease string
+}
+
+func parseVersion(s string) (version, error) {
+	var v version
+	core, prerelease, _ := strings.Cut(strings.TrimPrefix(s, "v"), "-")
+	// build metadata doesn't affect the order
+	core, _, _ = strings.Cut(core, "+")
+	prerelease, _, _ = strings.Cut(prerelease, "+")
+	fields := strings.Split(core, ".")
+	if len(fields) > 3 {
+		return v, fmt.Errorf("invalid version: %s", s)
+	}
+	for idx, field := range fields {
+		n, err := strconv.Atoi(field)
+		if err != nil || n < 0 {
+			return v, fmt.Errorf("invalid version: %s", s)
+		}
+		v.parts[idx] = n
+	}
+	v.prerelease = prerelease
+	return v, nil
+}
+
+func (v version) compare(other version) int {
+	for idx := range v.parts {
+		if v.parts[idx] != other.parts[idx] {
+			if v.parts[idx] < other.parts[idx] {
+				return -1
+			}
+			return 1
+		}
+	}
+	// a pre-release comes before the release
+	switch {
+	case v.prerelease == other.prerelease:
+		return 0
+	case v.prerelease == "":
+		return 1
+	case other.prerelease == "":
+		return -1
+	default:
+		return strings.Compare(v.prerelease, other.prerelease)
+	}
+}
+
+// Newer returns true if the latest version comes after the current one.
+// Versions are release tags like v1.2.3, optionally with a pre-release
+// suffix like v1.2.3-rc1.
+func Newer(current, latest string) (bool, error) {
+	currentVersion, err := parseVersion(current)
+	if err != nil {
+		return false, err
+	}
+	latestVersion, err := parseVersion(latest)
+	if err != nil {
+		return false, err
+	}
+	return currentVersion.compare(latestVersion) < 0, nil
+}
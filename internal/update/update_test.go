@@ -0,0 +1,80 @@
+package update_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/achilleas-k/gg13/internal/update"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLatest(t *testing.T) {
+	responses := map[string]string{
+		"/ok":     `{"tag_name":"v1.2.0","html_url":"https://example.com/v1.2.0","name":"ignored"}`,
+		"/notag":  `{"html_url":"https://example.com"}`,
+		"/broken": `{`,
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		response, ok := responses[r.URL.Path]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		_, _ = w.Write([]byte(response))
+	}))
+	defer server.Close()
+
+	release, err := update.Latest(context.Background(), server.Client(), server.URL+"/ok")
+	require.NoError(t, err)
+	assert.Equal(t, update.Release{Tag: "v1.2.0", URL: "https://example.com/v1.2.0"}, release)
+
+	_, err = update.Latest(context.Background(), server.Client(), server.URL+"/notag")
+	assert.EqualError(t, err, "release has no tag")
+	_, err = update.Latest(context.Background(), server.Client(), server.URL+"/broken")
+	assert.ErrorContains(t, err, "failed decoding release")
+	_, err = update.Latest(context.Background(), server.Client(), server.URL+"/missing")
+	assert.EqualError(t, err, "unexpected response: 404 Not Found")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = update.Latest(ctx, server.Client(), server.URL+"/ok")
+	assert.ErrorIs(t, err, context.Canceled)
+}
+
+func TestNewer(t *testing.T) {
+	type testCase struct {
+		current string
+		latest  string
+		newer   bool
+	}
+
+	testCases := map[string]testCase{
+		"same":               {"v1.2.3", "v1.2.3", false},
+		"patch":              {"v1.2.3", "v1.2.4", true},
+		"minor":              {"v1.2.3", "v1.10.0", true},
+		"major":              {"v1.2.3", "v2.0.0", true},
+		"older":              {"v1.2.3", "v1.2.2", false},
+		"no-prefix":          {"1.2.3", "v1.2.4", true},
+		"short":              {"v1.2", "v1.2.1", true},
+		"release-after-pre":  {"v1.3.0-rc1", "v1.3.0", true},
+		"pre-before-release": {"v1.3.0", "v1.3.0-rc1", false},
+		"pre":                {"v1.3.0-rc1", "v1.3.0-rc2", true},
+		"build-metadata":     {"v1.2.3+abc", "v1.2.3", false},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			newer, err := update.Newer(tc.current, tc.latest)
+			require.NoError(t, err)
+			assert.Equal(t, tc.newer, newer)
+		})
+	}
+
+	_, err := update.Newer("devel", "v1.0.0")
+	assert.EqualError(t, err, "invalid version: devel")
+	_, err = update.Newer("v1.0.0", "v1.0.0.0")
+	assert.EqualError(t, err, "invalid version: v1.0.0.0")
+}